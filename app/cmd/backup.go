@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store/backup"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// minBackupInterval prevents too frequent backups which produce a lot of archives with the same data
+const minBackupInterval = time.Minute
+
+// RunCommand executes a sub-command defined with options instead of the main server
+func RunCommand(options *Options) error {
+	switch options.Command {
+	case "backup":
+		return runArchiveCommand(options.Store, options.BackupCmd.File, exportArchive)
+	case "restore":
+		return runArchiveCommand(options.Store, options.RestoreCmd.File, importArchive)
+	default:
+		return errors.Errorf("unknown command %q", options.Command)
+	}
+}
+
+func runArchiveCommand(storeOpts StoreGroup, fileName string, fn func(ctx context.Context, storage engine.Interface, fileName string) error) error {
+	if fileName == "" {
+		return errors.New("path to backup archive file required")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dataStore, err := makeDataStore(ctx, storeOpts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if errClose := dataStore.Close(ctx); errClose != nil {
+			log.Printf("[WARN] failed to close storage: %v", errClose)
+		}
+	}()
+
+	return fn(ctx, dataStore, fileName)
+}
+
+func exportArchive(ctx context.Context, storage engine.Interface, fileName string) (err error) {
+	fh, err := os.OpenFile(filepath.Clean(fileName), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create backup file")
+	}
+
+	defer func() {
+		if errClose := fh.Close(); err == nil && errClose != nil {
+			err = errors.Wrap(errClose, "failed to close backup file")
+		}
+	}()
+
+	if err = backup.Export(ctx, storage, fh); err != nil {
+		return err
+	}
+	log.Printf("[INFO] backup saved to %s", fileName)
+	return nil
+}
+
+func importArchive(ctx context.Context, storage engine.Interface, fileName string) error {
+	fh, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return errors.Wrap(err, "failed to open backup file")
+	}
+	defer func() { _ = fh.Close() }()
+
+	if err = backup.Import(ctx, storage, fh); err != nil {
+		return err
+	}
+	log.Printf("[INFO] backup restored from %s", fileName)
+	return nil
+}
+
+// makeBackupScheduler returns nil when scheduled backups disabled
+func makeBackupScheduler(backupOpts BackupGroup, storage engine.Interface) (*backup.Scheduler, error) {
+	if backupOpts.Interval == "" {
+		return nil, nil
+	}
+
+	interval, err := time.ParseDuration(backupOpts.Interval)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse backup interval")
+	}
+
+	if interval < minBackupInterval {
+		return nil, errors.Errorf("backup interval should be equal or more %s", minBackupInterval)
+	}
+
+	if backupOpts.Dir == "" {
+		return nil, errors.New("backup directory undefined")
+	}
+
+	return &backup.Scheduler{
+		Storage:   storage,
+		Dir:       backupOpts.Dir,
+		Interval:  interval,
+		Retention: backupOpts.Retention,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunCommand(t *testing.T) {
+	dir := t.TempDir()
+	var options Options
+	options.Store.Type = "embed"
+	options.Store.AdminPassword = "super-secret"
+	options.Store.Embed.Path = filepath.Join(dir, "source.db")
+
+	options.Command = "backup"
+	assert.Error(t, RunCommand(&options), "archive file is required")
+
+	options.BackupCmd.File = filepath.Join(dir, "backup.json")
+	require.NoError(t, RunCommand(&options))
+
+	options.Command = "restore"
+	options.Store.Type = "embed-purego"
+	options.Store.Embed.Path = filepath.Join(dir, "target.db")
+	options.RestoreCmd.File = filepath.Join(dir, "unknown.json")
+	assert.Error(t, RunCommand(&options))
+
+	options.RestoreCmd.File = options.BackupCmd.File
+	require.NoError(t, RunCommand(&options))
+
+	options.Command = "unknown"
+	assert.Error(t, RunCommand(&options))
+}
+
+func Test_makeBackupScheduler(t *testing.T) {
+	s, err := makeBackupScheduler(BackupGroup{Dir: "./backup"}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, s, "scheduled backups disabled without interval")
+
+	s, err = makeBackupScheduler(BackupGroup{Dir: "./backup", Interval: "12h", Retention: 3}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "./backup", s.Dir)
+	assert.Equal(t, 3, s.Retention)
+
+	_, err = makeBackupScheduler(BackupGroup{Dir: "./backup", Interval: "wrong"}, nil)
+	assert.Error(t, err)
+
+	_, err = makeBackupScheduler(BackupGroup{Dir: "./backup", Interval: "1s"}, nil)
+	assert.Error(t, err)
+
+	_, err = makeBackupScheduler(BackupGroup{Interval: "1h"}, nil)
+	assert.Error(t, err)
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/zebox/registry-admin/app/server"
 	"github.com/zebox/registry-admin/app/store/engine"
 	"github.com/zebox/registry-admin/app/store/engine/embedded"
+	"github.com/zebox/registry-admin/app/store/engine/mysql"
+	"github.com/zebox/registry-admin/app/store/engine/postgres"
 	"gopkg.in/natefinch/lumberjack.v2"
 
 	log "github.com/go-pkgz/lgr"
@@ -57,6 +59,11 @@ func Execute(options *Options, webContent embed.FS) error {
 		return errCookieDuration
 	}
 
+	refreshTokenTTL, errRefreshTTL := time.ParseDuration(opts.Registry.RefreshTokenTTL)
+	if errRefreshTTL != nil {
+		return errors.Wrap(errRefreshTTL, "failed to parse refresh token TTL")
+	}
+
 	sslConfig, sslErr := makeSSLConfig()
 	if sslErr != nil {
 		return fmt.Errorf("failed to make config of ssl server params: %w", sslErr)
@@ -84,6 +91,41 @@ func Execute(options *Options, webContent embed.FS) error {
 		return storeErr
 	}
 
+	backupScheduler, errBackup := makeBackupScheduler(opts.Backup, dataStore)
+	if errBackup != nil {
+		cancel()
+		return errBackup
+	}
+	if backupScheduler != nil {
+		go backupScheduler.Run(ctx)
+	}
+
+	ldapAuthenticator, ldapSyncer, errLDAP := makeLDAP(ctx, opts.LDAP, dataStore)
+	if errLDAP != nil {
+		cancel()
+		return errLDAP
+	}
+	if ldapSyncer != nil {
+		go ldapSyncer.Run(ctx)
+	}
+
+	if err := checkTOTPRequiredRoles(ctx, opts.Auth.TOTPRequiredRoles, dataStore); err != nil {
+		cancel()
+		return err
+	}
+
+	lockoutPolicy, errLockout := makeLockoutPolicy(opts.Auth)
+	if errLockout != nil {
+		cancel()
+		return errLockout
+	}
+
+	passwordPolicy, errPassword := makePasswordPolicy(opts.Auth)
+	if errPassword != nil {
+		cancel()
+		return errPassword
+	}
+
 	srv := server.Server{
 		Hostname:                 checkHostnameForURL(opts.HostName, opts.SSL.Type),
 		Listen:                   opts.Listen,
@@ -94,11 +136,23 @@ func Execute(options *Options, webContent embed.FS) error {
 		Storage:                  dataStore,
 		RegistryService:          registryService,
 		GarbageCollectorInterval: opts.Registry.GarbageCollectorInterval,
+		RefreshTokenTTL:          refreshTokenTTL,
+		RegistryAddress:          registryAddress(opts.Registry.Host, opts.Registry.Port),
+		SSOProviders:             makeSSOProviders(opts.Auth.Providers),
+		TOTPIssuer:               opts.Auth.TOTPIssuer,
+		TOTPRequiredRoles:        opts.Auth.TOTPRequiredRoles,
+		Lockout:                  lockoutPolicy,
+		PasswordPolicy:           passwordPolicy,
 
 		// this use embed.FS which required that web directory has content
 		WebContentFS: &webContent,
 	}
 
+	// a nil authenticator mustn't be assigned to the interface field
+	if ldapAuthenticator != nil {
+		srv.Directory = ldapAuthenticator
+	}
+
 	authOptions := auth.Opts{
 		SecretReader: token.SecretFunc(func(string) (string, error) { // secret key for JWT
 			return opts.Auth.TokenSecret, nil
@@ -119,6 +173,10 @@ func Execute(options *Options, webContent embed.FS) error {
 
 	authService := auth.NewService(authOptions)
 	authService.AddDirectProvider("local", &srv)
+	if err := srv.AddSSOProviders(ctx, authService); err != nil {
+		cancel()
+		return err
+	}
 	srv.Authenticator = authService
 	go func() {
 		if x := recover(); x != nil {
@@ -162,6 +220,40 @@ func checkHostnameForURL(hostname, sslMode string) string {
 	return hostname
 }
 
+// registryAddress returns registry host without scheme and with port which docker clients use for pull and push images
+func registryAddress(host string, port uint) string {
+	host = strings.TrimRight(host, "/")
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	if port == 0 || port == 443 {
+		return host
+	}
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+// makeSSOProviders converts single sign-on options to providers settings of server, 'oidc' is a default type of provider
+func makeSSOProviders(providers []SSOProviderOptions) []server.SSOProvider {
+	result := make([]server.SSOProvider, 0, len(providers))
+	for _, p := range providers {
+		if p.Type == "" {
+			p.Type = server.SSOTypeOIDC
+		}
+		result = append(result, server.SSOProvider{
+			Name:          p.Name,
+			Type:          p.Type,
+			Issuer:        p.Issuer,
+			ClientID:      p.ClientID,
+			ClientSecret:  p.ClientSecret,
+			Scopes:        p.Scopes,
+			LoginClaim:    p.LoginClaim,
+			RoleClaim:     p.RoleClaim,
+			RoleMapping:   p.RoleMapping,
+			DefaultRole:   p.DefaultRole,
+			AutoProvision: p.AutoProvision,
+		})
+	}
+	return result
+}
+
 // createRegistryConnection will prepare registry connection instance
 func createRegistryConnection(opts RegistryGroup) (*registry.Registry, error) {
 
@@ -216,6 +308,15 @@ func createRegistryConnection(opts RegistryGroup) (*registry.Registry, error) {
 		// required for append to certificate extension
 		registrySettings.CertificatesPaths.IP = opts.Certs.IP
 		registrySettings.CertificatesPaths.FQDNs = opts.Certs.FQDNs
+		registrySettings.KeyAlgorithm = opts.Certs.Algorithm
+
+		if opts.KeyValidity != "" {
+			keyValidity, err := time.ParseDuration(opts.KeyValidity)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse validity period of signing keys")
+			}
+			registrySettings.KeyValidity = keyValidity
+		}
 	}
 
 	return registry.NewRegistry(opts.Login, opts.Password, registrySettings)
@@ -265,14 +366,30 @@ func makeDataStore(ctx context.Context, storeOpts StoreGroup) (iStore engine.Int
 	log.Printf("[INFO] make data store, type=%s", storeOpts.Type)
 
 	switch storeOpts.Type {
-	case "embed":
+	case "embed", "embed-purego":
 		e := embedded.NewEmbedded(storeOpts.Embed.Path)
+		if storeOpts.Type == "embed-purego" {
+			e = embedded.NewPureGoEmbedded(storeOpts.Embed.Path)
+		}
 		passwdCtx := engine.SetAdminDefaultPassword(ctx, &storeOpts.AdminPassword)
-		err = e.Connect(passwdCtx)
-		if err != nil && !errors.Is(err, embedded.ErrTableAlreadyExist) {
+		if err = e.Connect(passwdCtx); err != nil {
 			return nil, err
 		}
 		return e, nil
+	case "postgres":
+		p := postgres.NewPostgres(storeOpts.Postgres.DSN)
+		passwdCtx := engine.SetAdminDefaultPassword(ctx, &storeOpts.AdminPassword)
+		if err = p.Connect(passwdCtx); err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "mysql":
+		m := mysql.NewMySQL(storeOpts.MySQL.DSN)
+		passwdCtx := engine.SetAdminDefaultPassword(ctx, &storeOpts.AdminPassword)
+		if err = m.Connect(passwdCtx); err != nil {
+			return nil, err
+		}
+		return m, nil
 	default:
 		return nil, fmt.Errorf("unsupported store type %s", storeOpts.Type)
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/server"
 	"github.com/zebox/registry-admin/app/store/engine"
 	"os"
 	"strconv"
@@ -99,6 +100,36 @@ func Test_checkHostnameForURL(t *testing.T) {
 	}
 }
 
+func Test_registryAddress(t *testing.T) {
+	tbl := []struct {
+		host   string
+		port   uint
+		result string
+	}{
+		{"https://registry.example.com", 5000, "registry.example.com:5000"},
+		{"http://registry.example.com/", 5000, "registry.example.com:5000"},
+		{"https://registry.example.com", 443, "registry.example.com"},
+		{"registry.example.com", 0, "registry.example.com"},
+	}
+
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.result, registryAddress(tt.host, tt.port))
+		})
+	}
+}
+
+func Test_makeSSOProviders(t *testing.T) {
+	providers := makeSSOProviders([]SSOProviderOptions{
+		{Name: "corp", Issuer: "https://sso.example.com", ClientID: "cid", ClientSecret: "secret", DefaultRole: "user"},
+		{Type: "github", ClientID: "cid", ClientSecret: "secret"},
+	})
+	require.Len(t, providers, 2)
+	assert.Equal(t, server.SSOProvider{Name: "corp", Type: server.SSOTypeOIDC, Issuer: "https://sso.example.com",
+		ClientID: "cid", ClientSecret: "secret", DefaultRole: "user"}, providers[0])
+	assert.Equal(t, "github", providers[1].Type)
+}
+
 func Test_makeDataStore(t *testing.T) {
 	var sg = StoreGroup{
 		Type: "embed",
@@ -126,6 +157,23 @@ func Test_makeDataStore(t *testing.T) {
 	assert.NotNil(t, iStore)
 	assert.NoError(t, iStore.Close(ctx))
 
+	sg.Type = "embed-purego"
+	iStore, errNo = makeDataStore(ctx, sg)
+	assert.NoError(t, errNo)
+	assert.NotNil(t, iStore)
+	assert.NoError(t, iStore.Close(ctx))
+
+	// postgres storage without connection string
+	sg.Type = "postgres"
+	iStore, errIs = makeDataStore(ctx, sg)
+	assert.Error(t, errIs)
+	assert.Equal(t, iStore, nil)
+
+	sg.Type = "mysql"
+	iStore, errIs = makeDataStore(ctx, sg)
+	assert.Error(t, errIs)
+	assert.Equal(t, iStore, nil)
+
 	sg.Type = "unknown"
 	iStore, errIs = makeDataStore(ctx, sg)
 	assert.Error(t, errIs)
@@ -156,6 +204,7 @@ func Test_createRegistryConnection(t *testing.T) {
 				FQDNs     []string `long:"fqdn" env:"FQDN" env-delim:"," description:"FQDN(s) for registry certificates" json:"fqdns" yaml:"fqdns"`
 				IP        string   `long:"ip" env:"IP" description:"Address which appends to certificate SAN (Subject Alternative Name)" json:"ip" yaml:"ip"`
 				HTTPSCert string   `long:"https-cert" env:"CERT_HTTPS" description:"A path to HTTPS certificate used for TLS access to registry instance" json:"https_cert" yaml:"https_cert"`
+				Algorithm string   `long:"key-algorithm" env:"KEY_ALGORITHM" choice:"ecdsa-p256" choice:"ecdsa-p384" choice:"rsa-3072" choice:"rsa-4096" default:"ecdsa-p256" description:"Algorithm of new self-signed keys for token signing, existed keys are loaded with any algorithm" json:"key_algorithm" yaml:"key_algorithm"`
 			}(struct {
 				Path      string
 				Key       string
@@ -164,7 +213,8 @@ func Test_createRegistryConnection(t *testing.T) {
 				FQDNs     []string `long:"fqdn" env:"FQDN" env-delim:"," description:"FQDN(s) for registry certificates" json:"fqdns" yaml:"fqdns"`
 				IP        string   `long:"ip" env:"IP" description:"Address which appends to certificate SAN (Subject Alternative Name)" json:"ip" yaml:"ip"`
 				HTTPSCert string   `long:"https-cert" env:"CERT_HTTPS" description:"A path to HTTPS certificate used for TLS access to registry instance" json:"https_cert" yaml:"https_cert"`
-			}{Path: tmpDir + "/", Key: tmpDir + "/test.key", PublicKey: tmpDir + "/test.pub", CARoot: tmpDir + "/test.crt"}),
+				Algorithm string
+			}{Path: tmpDir + "/", Key: tmpDir + "/test.key", PublicKey: tmpDir + "/test.pub", CARoot: tmpDir + "/test.crt", Algorithm: "ecdsa-p384"}),
 		},
 	}
 
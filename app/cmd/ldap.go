@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/ldap"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// minLDAPSyncInterval prevents too frequent requests to the directory
+const minLDAPSyncInterval = time.Minute
+
+// makeLDAP returns nil authenticator when LDAP disabled and nil syncer when synchronization disabled
+func makeLDAP(ctx context.Context, ldapOpts LDAPGroup, storage engine.Interface) (*ldap.Authenticator, *ldap.Syncer, error) {
+	if ldapOpts.URL == "" {
+		return nil, nil, nil
+	}
+
+	if err := engine.CheckRoleExists(ctx, storage, ldapOpts.DefaultRole); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid default role of LDAP users")
+	}
+
+	var timeout time.Duration
+	if ldapOpts.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(ldapOpts.Timeout); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to parse LDAP timeout")
+		}
+	}
+
+	client, err := ldap.NewClient(ldap.Settings{
+		URL:                ldapOpts.URL,
+		StartTLS:           ldapOpts.StartTLS,
+		InsecureSkipVerify: ldapOpts.Insecure,
+		BindDN:             ldapOpts.BindDN,
+		BindPassword:       ldapOpts.BindPassword,
+		UserBaseDN:         ldapOpts.UserBaseDN,
+		UserFilter:         ldapOpts.UserFilter,
+		LoginAttr:          ldapOpts.LoginAttr,
+		NameAttr:           ldapOpts.NameAttr,
+		DisabledAttr:       ldapOpts.DisabledAttr,
+		GroupBaseDN:        ldapOpts.GroupBaseDN,
+		GroupFilter:        ldapOpts.GroupFilter,
+		GroupNameAttr:      ldapOpts.GroupNameAttr,
+		GroupMemberAttr:    ldapOpts.GroupMemberAttr,
+		Timeout:            timeout,
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid LDAP settings")
+	}
+
+	authenticator := &ldap.Authenticator{Client: client, Storage: storage, DefaultRole: ldapOpts.DefaultRole}
+	if ldapOpts.SyncInterval == "" {
+		return authenticator, nil, nil
+	}
+
+	interval, err := time.ParseDuration(ldapOpts.SyncInterval)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse LDAP sync interval")
+	}
+
+	if interval < minLDAPSyncInterval {
+		return nil, nil, errors.Errorf("LDAP sync interval should be equal or more %s", minLDAPSyncInterval)
+	}
+
+	return authenticator, &ldap.Syncer{Client: client, Storage: storage, Interval: interval}, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_makeLDAP(t *testing.T) {
+	ctx, storage := context.Background(), testRolesStorage()
+	opts := LDAPGroup{UserBaseDN: "ou=users,dc=example,dc=org", DefaultRole: "user", Timeout: "5s"}
+
+	a, s, err := makeLDAP(ctx, opts, storage)
+	require.NoError(t, err)
+	assert.Nil(t, a, "LDAP disabled without URL")
+	assert.Nil(t, s)
+
+	opts.URL = "ldap://localhost:389"
+	a, s, err = makeLDAP(ctx, opts, storage)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.Nil(t, s, "synchronization disabled without interval")
+	assert.Equal(t, 5*time.Second, a.Client.Timeout)
+	assert.Equal(t, "user", a.DefaultRole)
+
+	opts.SyncInterval = "15m"
+	_, s, err = makeLDAP(ctx, opts, storage)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, 15*time.Minute, s.Interval)
+
+	// custom roles are allowed as well as built-in ones
+	opts.DefaultRole = "ci-operator"
+	a, _, err = makeLDAP(ctx, opts, storage)
+	require.NoError(t, err)
+	assert.Equal(t, "ci-operator", a.DefaultRole)
+
+	for _, wrong := range []LDAPGroup{
+		{URL: opts.URL, UserBaseDN: opts.UserBaseDN, DefaultRole: "root"},
+		{URL: opts.URL, UserBaseDN: opts.UserBaseDN, DefaultRole: "user", Timeout: "wrong"},
+		{URL: opts.URL, UserBaseDN: opts.UserBaseDN, DefaultRole: "user", SyncInterval: "wrong"},
+		{URL: opts.URL, UserBaseDN: opts.UserBaseDN, DefaultRole: "user", SyncInterval: "1s"},
+		{URL: "http://localhost", UserBaseDN: opts.UserBaseDN, DefaultRole: "user"},
+		{URL: opts.URL, DefaultRole: "user"},
+	} {
+		_, _, err = makeLDAP(ctx, wrong, storage)
+		assert.Error(t, err, "%+v", wrong)
+	}
+}
@@ -52,6 +52,16 @@ port: 8080
 
 auth:
   token_secret: super-secret-test-token
+  providers:
+    - name: corp
+      issuer: https://sso.example.com/realms/corp
+      client_id: registry-admin
+      client_secret: secret
+      role_claim: groups
+      role_mapping:
+        registry-admins: admin
+      default_role: user
+      auto_provision: true
 
 store:
   embed:
@@ -107,6 +117,13 @@ func Test_parseArgs(t *testing.T) {
 	testMatcherOptions.Auth.IssuerName = "test-issuer"
 	testMatcherOptions.Auth.TokenDuration = "20s"
 	testMatcherOptions.Auth.CookieDuration = "30d"
+	testMatcherOptions.Auth.TOTPIssuer = "RegistryAdmin"
+	testMatcherOptions.Auth.LockoutAttempts = 5
+	testMatcherOptions.Auth.LockoutIPAttempts = 20
+	testMatcherOptions.Auth.LockoutWindow = "15m"
+	testMatcherOptions.Auth.LockoutDuration = "1m"
+	testMatcherOptions.Auth.LockoutMaxDuration = "1h"
+	testMatcherOptions.Auth.PasswordMinLength = 8
 
 	testMatcherOptions.Logger.StdOut = true
 	testMatcherOptions.Logger.Enabled = true
@@ -126,10 +143,23 @@ func Test_parseArgs(t *testing.T) {
 	testMatcherOptions.Registry.Host = "test.registry-host.local"
 	testMatcherOptions.Registry.Port = 5000
 	testMatcherOptions.Registry.AuthType = "basic"
+	testMatcherOptions.Registry.RefreshTokenTTL = "720h"
+	testMatcherOptions.Registry.KeyValidity = "8760h"
+	testMatcherOptions.Registry.Certs.Algorithm = "ecdsa-p256"
 
 	testMatcherOptions.Store.Type = "embed"
 	testMatcherOptions.Store.AdminPassword = "admin"
 	testMatcherOptions.Store.Embed.Path = "./db/data.db"
+	testMatcherOptions.Backup.Dir = "./backup"
+	testMatcherOptions.Backup.Retention = 7
+	testMatcherOptions.LDAP.UserFilter = "(objectClass=person)"
+	testMatcherOptions.LDAP.LoginAttr = "uid"
+	testMatcherOptions.LDAP.NameAttr = "cn"
+	testMatcherOptions.LDAP.GroupFilter = "(objectClass=groupOfNames)"
+	testMatcherOptions.LDAP.GroupNameAttr = "cn"
+	testMatcherOptions.LDAP.GroupMemberAttr = "member"
+	testMatcherOptions.LDAP.DefaultRole = "user"
+	testMatcherOptions.LDAP.Timeout = "10s"
 	testMatcherOptions.Debug = true
 
 	os.Args = []string{os.Args[0]} // clear Go test flags
@@ -144,6 +174,19 @@ func Test_parseArgs(t *testing.T) {
 	require.NoError(t, errParse)
 	require.NotNil(t, testOpts)
 	assert.NotEmpty(t, testOpts.Auth.TokenSecret)
+
+	// test for sub-command args
+	os.Args = []string{os.Args[0], "restore", "--file", "backup.json"}
+	testOpts, errParse = ParseArgs()
+	require.NoError(t, errParse)
+	assert.Equal(t, "restore", testOpts.Command)
+	assert.Equal(t, "backup.json", testOpts.RestoreCmd.File)
+
+	// Docker Registry doesn't verify EdDSA signatures, so ed25519 keys can't be chosen
+	os.Args = []string{os.Args[0], "--registry.certs.key-algorithm=ed25519"}
+	_, errParse = ParseArgs()
+	assert.Error(t, errParse)
+	os.Args = []string{os.Args[0]}
 }
 
 func TestJsonConfigParser_ReadConfigFromFile(t *testing.T) {
@@ -196,6 +239,10 @@ func TestYamlConfigParser_ReadConfigFromFile(t *testing.T) {
 	errParse = ycp.ReadConfigFromFile(f.Name(), &testOptions)
 	assert.NoError(t, errParse)
 	assert.Equal(t, testOptions.Auth.TokenSecret, "super-secret-test-token")
+	require.Len(t, testOptions.Auth.Providers, 1)
+	assert.Equal(t, "https://sso.example.com/realms/corp", testOptions.Auth.Providers[0].Issuer)
+	assert.Equal(t, map[string]string{"registry-admins": "admin"}, testOptions.Auth.Providers[0].RoleMapping)
+	assert.True(t, testOptions.Auth.Providers[0].AutoProvision)
 
 	// test with fake file
 	errParse = ycp.ReadConfigFromFile("unknown.file", &testOptions)
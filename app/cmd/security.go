@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/server"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// makeLockoutPolicy parses thresholds of failed login attempts, lockout is disabled when both of them are zero
+func makeLockoutPolicy(authOpts AuthGroup) (server.LockoutPolicy, error) {
+	policy := server.LockoutPolicy{MaxAttempts: authOpts.LockoutAttempts, MaxIPAttempts: authOpts.LockoutIPAttempts}
+	if policy.MaxAttempts <= 0 && policy.MaxIPAttempts <= 0 {
+		return policy, nil
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"window", authOpts.LockoutWindow, &policy.Window},
+		{"duration", authOpts.LockoutDuration, &policy.Duration},
+		{"max duration", authOpts.LockoutMaxDuration, &policy.MaxDuration},
+	} {
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return server.LockoutPolicy{}, errors.Wrapf(err, "failed to parse lockout %s", d.name)
+		}
+		if v <= 0 {
+			return server.LockoutPolicy{}, errors.Errorf("lockout %s should be positive", d.name)
+		}
+		*d.dst = v
+	}
+
+	if policy.MaxDuration < policy.Duration {
+		return server.LockoutPolicy{}, errors.New("lockout max duration should be equal or more than lockout duration")
+	}
+	return policy, nil
+}
+
+// makePasswordPolicy returns policy with the built-in denylist extended by the file of denylist if it's set
+func makePasswordPolicy(authOpts AuthGroup) (*store.PasswordPolicy, error) {
+	if authOpts.PasswordMinLength < 1 {
+		return nil, errors.New("minimal password length should be positive")
+	}
+
+	policy := store.NewPasswordPolicy(authOpts.PasswordMinLength)
+	if authOpts.PasswordDenylist == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(authOpts.PasswordDenylist)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open password denylist")
+	}
+	defer f.Close() // nolint
+
+	if err = policy.ReadDenylist(f); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// checkTOTPRequiredRoles checks that roles which require two-factor authentication exist, custom roles are allowed
+func checkTOTPRequiredRoles(ctx context.Context, roles []string, storage engine.Interface) error {
+	for _, role := range roles {
+		if err := engine.CheckRoleExists(ctx, storage, role); err != nil {
+			return errors.Wrap(err, "invalid role which requires two-factor authentication")
+		}
+	}
+	return nil
+}
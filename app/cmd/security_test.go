@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zebox/registry-admin/app/server"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func Test_makeLockoutPolicy(t *testing.T) {
+	opts := AuthGroup{LockoutAttempts: 5, LockoutIPAttempts: 20, LockoutWindow: "15m", LockoutDuration: "1m", LockoutMaxDuration: "1h"}
+
+	policy, err := makeLockoutPolicy(opts)
+	require.NoError(t, err)
+	assert.Equal(t, server.LockoutPolicy{MaxAttempts: 5, MaxIPAttempts: 20, Window: 15 * time.Minute,
+		Duration: time.Minute, MaxDuration: time.Hour}, policy)
+
+	policy, err = makeLockoutPolicy(AuthGroup{LockoutWindow: "wrong"})
+	require.NoError(t, err, "durations aren't parsed when lockout disabled")
+	assert.Equal(t, server.LockoutPolicy{}, policy)
+
+	for _, wrong := range []AuthGroup{
+		{LockoutAttempts: 5, LockoutWindow: "wrong", LockoutDuration: "1m", LockoutMaxDuration: "1h"},
+		{LockoutAttempts: 5, LockoutWindow: "15m", LockoutDuration: "0s", LockoutMaxDuration: "1h"},
+		{LockoutIPAttempts: 5, LockoutWindow: "15m", LockoutDuration: "1h", LockoutMaxDuration: "1m"},
+	} {
+		_, err = makeLockoutPolicy(wrong)
+		assert.Error(t, err, "%+v", wrong)
+	}
+}
+
+func Test_makePasswordPolicy(t *testing.T) {
+	policy, err := makePasswordPolicy(AuthGroup{PasswordMinLength: 8})
+	require.NoError(t, err)
+	assert.NoError(t, policy.Validate("john", "corp-2023-pass"))
+	assert.Error(t, policy.Validate("john", "password123"))
+
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(denylist, []byte("# company passwords\ncorp-2023-pass\n"), 0o600))
+
+	policy, err = makePasswordPolicy(AuthGroup{PasswordMinLength: 8, PasswordDenylist: denylist})
+	require.NoError(t, err)
+	assert.Error(t, policy.Validate("john", "corp-2023-pass"))
+
+	_, err = makePasswordPolicy(AuthGroup{PasswordMinLength: 8, PasswordDenylist: filepath.Join(t.TempDir(), "unknown.txt")})
+	assert.Error(t, err)
+
+	_, err = makePasswordPolicy(AuthGroup{})
+	assert.Error(t, err)
+}
+
+func Test_checkTOTPRequiredRoles(t *testing.T) {
+	storage := testRolesStorage()
+
+	assert.NoError(t, checkTOTPRequiredRoles(context.Background(), nil, storage))
+	assert.NoError(t, checkTOTPRequiredRoles(context.Background(), []string{"admin", "ci-operator"}, storage))
+	assert.Error(t, checkTOTPRequiredRoles(context.Background(), []string{"admin", "root"}, storage))
+}
+
+// testRolesStorage returns a storage with built-in roles and the custom role 'ci-operator'
+func testRolesStorage() engine.Interface {
+	return &engine.InterfaceMock{GetRoleFunc: func(_ context.Context, id interface{}) (store.Role, error) {
+		if id == "ci-operator" || store.CheckRoleInList(id.(string)) {
+			return store.Role{Name: id.(string)}, nil
+		}
+		return store.Role{}, engine.ErrNotFound
+	}}
+}
@@ -0,0 +1,114 @@
+package ldap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// Authenticator checks credentials of users with the directory and keeps directory users in the store
+type Authenticator struct {
+	Client      *Client
+	Storage     engine.Interface
+	DefaultRole string // role of a user which is created in the store on first login
+}
+
+// Authenticate checks credentials with the directory and returns the store user of login. A user is created in the store
+// on first login with a random password, so the store password hash never authenticates a directory user.
+// ErrUserNotFound returns when the directory doesn't know the login and the store password should be checked instead,
+// ErrLocalUser returns when the login belongs to a store user which isn't created from the directory, e.g. a local admin,
+// a disabled store user is returned when the directory account disabled.
+func (a *Authenticator) Authenticate(ctx context.Context, login, password string) (store.User, error) {
+	// the store treats numeric login as user ID, such directory users can't be matched with store users by login
+	if _, err := strconv.ParseInt(login, 10, 64); err == nil {
+		return store.User{}, ErrUserNotFound
+	}
+
+	// local users aren't checked with the directory at all, so a directory account can't lock or take over them
+	if err := a.checkDirectoryUser(ctx, login); err != nil {
+		return store.User{}, err
+	}
+
+	entry, err := a.Client.Authenticate(login, password)
+	if err != nil {
+		return store.User{}, err
+	}
+
+	// the directory may match login case-insensitively, the login of entry is checked again
+	user, err := a.Storage.GetUser(ctx, entry.Login)
+	if errors.Is(err, engine.ErrNotFound) {
+		if entry.Disabled {
+			return store.User{}, ErrUserDisabled
+		}
+		return a.createUser(ctx, entry)
+	}
+	if err != nil {
+		return store.User{}, err
+	}
+	if !isDirectoryUser(user) {
+		return store.User{}, ErrLocalUser
+	}
+
+	// a disabled directory account blocks the store user, it isn't unblocked automatically when the account enabled again
+	if entry.Disabled && !user.Disabled {
+		user.Disabled = true
+		user.Password = "" // keep the stored password
+		if err = a.Storage.UpdateUser(ctx, user); err != nil {
+			return store.User{}, errors.Wrapf(err, "failed to disable user %q", user.Login)
+		}
+		log.Printf("[INFO] user %q disabled because directory account disabled", user.Login)
+	}
+	return user, nil
+}
+
+func (a *Authenticator) createUser(ctx context.Context, entry User) (store.User, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return store.User{}, errors.Wrap(err, "failed to generate password")
+	}
+
+	user := store.User{
+		Login:       entry.Login,
+		Name:        entry.Name,
+		Password:    hex.EncodeToString(password),
+		Role:        a.DefaultRole,
+		Description: "created on first login with LDAP directory",
+		SSOID:       directoryID(entry.Login),
+	}
+	if err := a.Storage.CreateUser(ctx, &user); err != nil {
+		return store.User{}, errors.Wrapf(err, "failed to create user %q", entry.Login)
+	}
+	log.Printf("[INFO] user %q created on first login with LDAP directory", user.Login)
+	return user, nil
+}
+
+// checkDirectoryUser returns ErrLocalUser when a store user with the login exists and isn't created from the directory
+func (a *Authenticator) checkDirectoryUser(ctx context.Context, login string) error {
+	user, err := a.Storage.GetUser(ctx, login)
+	if errors.Is(err, engine.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isDirectoryUser(user) {
+		return ErrLocalUser
+	}
+	return nil
+}
+
+// directoryID is the identity of a user created from the directory, it's kept in SSOID of the store user like identities
+// of single sign-on users, and local users and users of single sign-on providers never have it
+func directoryID(login string) string {
+	return "ldap_" + login
+}
+
+func isDirectoryUser(user store.User) bool {
+	return user.SSOID == directoryID(user.Login)
+}
@@ -0,0 +1,104 @@
+package ldap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"github.com/zebox/registry-admin/app/store/engine/embedded"
+)
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	storage := prepareStorage(ctx, t)
+	a := &Authenticator{Client: prepareTestClient(t, testDirectoryEntries()), Storage: storage, DefaultRole: store.UserRole}
+
+	// a user is created on first login
+	user, err := a.Authenticate(ctx, "john", "john_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "john", user.Login)
+	assert.Equal(t, "John Doe", user.Name)
+	assert.Equal(t, store.UserRole, user.Role)
+	assert.Equal(t, "ldap_john", user.SSOID)
+	assert.NotZero(t, user.ID)
+
+	// next login returns the same user
+	next, err := a.Authenticate(ctx, "john", "john_secret")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, next.ID)
+
+	_, err = a.Authenticate(ctx, "john", "bad_password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(ctx, "unknown", "secret")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = a.Authenticate(ctx, "1", "secret")
+	assert.ErrorIs(t, err, ErrUserNotFound, "numeric login is refused")
+
+	// a disabled directory account isn't created in the store
+	_, err = a.Authenticate(ctx, "bob", "bob_secret")
+	assert.ErrorIs(t, err, ErrUserDisabled)
+	_, err = storage.GetUser(ctx, "bob")
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	// a disabled directory account disables existed store user
+	bob := store.User{Login: "bob", Name: "Bob", Password: "bob_password", Role: store.ManagerRole, SSOID: "ldap_bob"}
+	require.NoError(t, storage.CreateUser(ctx, &bob))
+	user, err = a.Authenticate(ctx, "bob", "bob_secret")
+	require.NoError(t, err)
+	assert.True(t, user.Disabled)
+
+	bob, err = storage.GetUser(ctx, "bob")
+	require.NoError(t, err)
+	assert.True(t, bob.Disabled)
+	assert.True(t, store.ComparePassword(bob.Password, "bob_password"), "stored password kept")
+}
+
+func TestAuthenticator_AuthenticateLocalUser(t *testing.T) {
+	ctx := context.Background()
+	storage := prepareStorage(ctx, t)
+	a := &Authenticator{Client: prepareTestClient(t, testDirectoryEntries()), Storage: storage, DefaultRole: store.UserRole}
+
+	// local users and users of single sign-on with logins of directory accounts aren't bound to the directory
+	john := store.User{Login: "john", Name: "John", Password: "john_password", Role: store.AdminRole}
+	require.NoError(t, storage.CreateUser(ctx, &john))
+	alice := store.User{Login: "alice", Name: "Alice", Password: "alice_password", Role: store.UserRole, SSOID: "corp_0a1b2c"}
+	require.NoError(t, storage.CreateUser(ctx, &alice))
+
+	_, err := a.Authenticate(ctx, "john", "john_secret")
+	assert.ErrorIs(t, err, ErrLocalUser)
+	_, err = a.Authenticate(ctx, "john", "bad_password")
+	assert.ErrorIs(t, err, ErrLocalUser, "the directory isn't asked for local users")
+	_, err = a.Authenticate(ctx, "alice", "alice_secret")
+	assert.ErrorIs(t, err, ErrLocalUser)
+
+	// the login returned by the directory is checked too
+	_, err = a.Authenticate(ctx, "John", "john_secret")
+	assert.ErrorIs(t, err, ErrLocalUser)
+
+	// a disabled directory account doesn't disable the local user
+	bob := store.User{Login: "bob", Name: "Bob", Password: "bob_password", Role: store.ManagerRole}
+	require.NoError(t, storage.CreateUser(ctx, &bob))
+	_, err = a.Authenticate(ctx, "bob", "bob_secret")
+	assert.ErrorIs(t, err, ErrLocalUser)
+	bob, err = storage.GetUser(ctx, "bob")
+	require.NoError(t, err)
+	assert.False(t, bob.Disabled)
+}
+
+func prepareStorage(ctx context.Context, t *testing.T) engine.Interface {
+	dbPath := filepath.Join(t.TempDir(), "ldap_test.db")
+	storage := embedded.NewPureGoEmbedded(dbPath)
+	require.NoError(t, storage.Connect(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, storage.Close(ctx))
+		_ = os.Remove(dbPath)
+	})
+	return storage
+}
@@ -0,0 +1,275 @@
+// Package ldap implements authentication of users with a LDAP directory and a synchronization of directory groups
+// to the store groups.
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+)
+
+// userAccountControlAttr is an Active Directory attribute with account flags, accountDisabled is a flag of disabled account
+const (
+	userAccountControlAttr = "userAccountControl"
+	accountDisabled        = 0x2
+)
+
+var (
+	// ErrUserNotFound returns when the directory doesn't contain a user with login
+	ErrUserNotFound = errors.New("user not found in directory")
+
+	// ErrInvalidCredentials returns when the directory rejects a password of user
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrUserDisabled returns when the directory account of user disabled
+	ErrUserDisabled = errors.New("directory account disabled")
+
+	// ErrLocalUser returns when the login belongs to a store user which isn't created from the directory
+	ErrLocalUser = errors.New("login belongs to a local user")
+)
+
+// Settings defines a connection to the directory and a layout of users and groups entries
+type Settings struct {
+	URL                string        // ldap://host:389 or ldaps://host:636
+	StartTLS           bool          // upgrade plain ldap:// connection to TLS
+	InsecureSkipVerify bool          // skip verification of server certificate
+	BindDN             string        // DN of service account which searches users and groups, anonymous bind when empty
+	BindPassword       string        // password of service account
+	UserBaseDN         string        // base DN for users search
+	UserFilter         string        // filter which selects users entries, e.g. (objectClass=person)
+	LoginAttr          string        // attribute with user login, e.g. 'uid' or 'sAMAccountName'
+	NameAttr           string        // attribute with user full name, e.g. 'cn' or 'displayName'
+	DisabledAttr       string        // attribute which marks disabled account, e.g. 'nsAccountLock' or 'userAccountControl'
+	GroupBaseDN        string        // base DN for groups search, groups aren't synced when empty
+	GroupFilter        string        // filter which selects groups entries, e.g. (objectClass=groupOfNames)
+	GroupNameAttr      string        // attribute with group name
+	GroupMemberAttr    string        // attribute with DNs of group members
+	Timeout            time.Duration // timeout of connection and requests
+}
+
+// User is a user entry of the directory
+type User struct {
+	DN       string
+	Login    string
+	Name     string
+	Disabled bool
+}
+
+// Group is a group entry of the directory, members contain DNs of users
+type Group struct {
+	DN      string
+	Name    string
+	Members []string
+}
+
+// Client makes requests to the directory, every request uses a new connection
+type Client struct {
+	Settings
+}
+
+// NewClient checks settings and sets defaults for undefined attributes and filters
+func NewClient(settings Settings) (*Client, error) {
+	u, err := url.Parse(settings.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse directory URL")
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, errors.Errorf("unsupported directory URL scheme %q, allowed 'ldap' and 'ldaps'", u.Scheme)
+	}
+
+	if settings.UserBaseDN == "" {
+		return nil, errors.New("users base DN required")
+	}
+
+	setDefault := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	setDefault(&settings.UserFilter, "(objectClass=person)")
+	setDefault(&settings.LoginAttr, "uid")
+	setDefault(&settings.NameAttr, "cn")
+	setDefault(&settings.GroupFilter, "(objectClass=groupOfNames)")
+	setDefault(&settings.GroupNameAttr, "cn")
+	setDefault(&settings.GroupMemberAttr, "member")
+
+	if settings.Timeout <= 0 {
+		settings.Timeout = 10 * time.Second
+	}
+
+	return &Client{Settings: settings}, nil
+}
+
+// Authenticate checks a password of user with a bind by user DN. A disabled user is returned without password check.
+func (c *Client) Authenticate(login, password string) (user User, err error) {
+	// an empty password makes unauthenticated bind which succeeds on many servers
+	if password == "" {
+		return user, ErrInvalidCredentials
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return user, err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf("(&%s(%s=%s))", c.UserFilter, c.LoginAttr, goldap.EscapeFilter(login))
+	users, err := c.searchUsers(conn, filter)
+	if err != nil {
+		return user, err
+	}
+
+	switch len(users) {
+	case 0:
+		return user, ErrUserNotFound
+	case 1:
+		user = users[0]
+	default:
+		return user, errors.Errorf("login %q matches %d directory entries", login, len(users))
+	}
+
+	if user.Disabled {
+		return user, nil
+	}
+
+	if err = conn.Bind(user.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return user, ErrInvalidCredentials
+		}
+		return user, errors.Wrap(err, "failed to bind user")
+	}
+	return user, nil
+}
+
+// Users returns all users entries which match users filter
+func (c *Client) Users() ([]User, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return c.searchUsers(conn, c.UserFilter)
+}
+
+// Groups returns all groups entries which match groups filter
+func (c *Client) Groups() ([]Group, error) {
+	if c.GroupBaseDN == "" {
+		return nil, errors.New("groups base DN undefined")
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result, err := conn.Search(goldap.NewSearchRequest(c.GroupBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, int(c.Timeout.Seconds()), false, c.GroupFilter, []string{c.GroupNameAttr, c.GroupMemberAttr}, nil))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search groups")
+	}
+
+	groups := make([]Group, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		group := Group{
+			DN:      e.DN,
+			Name:    e.GetEqualFoldAttributeValue(c.GroupNameAttr),
+			Members: e.GetEqualFoldAttributeValues(c.GroupMemberAttr),
+		}
+		if group.Name == "" {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// connect dials the directory and binds the service account
+func (c *Client) connect() (*goldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify} //nolint:gosec // user defines it explicitly
+	if u, err := url.Parse(c.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := goldap.DialURL(c.URL, goldap.DialWithTLSConfig(tlsConfig),
+		goldap.DialWithDialer(&net.Dialer{Timeout: c.Timeout}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to directory")
+	}
+	conn.SetTimeout(c.Timeout)
+
+	if c.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to start TLS")
+		}
+	}
+
+	// requests are anonymous when service account undefined
+	if c.BindDN != "" {
+		if err = conn.Bind(c.BindDN, c.BindPassword); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to bind service account")
+		}
+	}
+	return conn, nil
+}
+
+func (c *Client) searchUsers(conn *goldap.Conn, filter string) ([]User, error) {
+	attributes := []string{c.LoginAttr, c.NameAttr}
+	if c.DisabledAttr != "" {
+		attributes = append(attributes, c.DisabledAttr)
+	}
+
+	result, err := conn.Search(goldap.NewSearchRequest(c.UserBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, int(c.Timeout.Seconds()), false, filter, attributes, nil))
+	if err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to search users")
+	}
+
+	users := make([]User, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		user := User{
+			DN:    e.DN,
+			Login: e.GetEqualFoldAttributeValue(c.LoginAttr),
+			Name:  e.GetEqualFoldAttributeValue(c.NameAttr),
+		}
+		if user.Login == "" {
+			continue
+		}
+		if user.Name == "" {
+			user.Name = user.Login
+		}
+		if c.DisabledAttr != "" {
+			user.Disabled = isDisabled(c.DisabledAttr, e.GetEqualFoldAttributeValue(c.DisabledAttr))
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// isDisabled checks a value of attribute which marks disabled account. Active Directory keeps a flag in
+// userAccountControl bitmask, other directories set an attribute like nsAccountLock=true or pwdAccountLockedTime.
+func isDisabled(attr, value string) bool {
+	if strings.EqualFold(attr, userAccountControlAttr) {
+		flags, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && flags&accountDisabled != 0
+	}
+
+	switch strings.ToLower(value) {
+	case "", "false", "0", "no":
+		return false
+	}
+	return true
+}
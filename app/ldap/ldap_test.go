@@ -0,0 +1,261 @@
+package ldap
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBindDN       = "cn=admin,dc=example,dc=org"
+	testBindPassword = "admin_secret"
+	testUserBaseDN   = "ou=users,dc=example,dc=org"
+	testGroupBaseDN  = "ou=groups,dc=example,dc=org"
+)
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient(Settings{URL: "ldap://localhost:389", UserBaseDN: testUserBaseDN})
+	require.NoError(t, err)
+	assert.Equal(t, "(objectClass=person)", c.UserFilter)
+	assert.Equal(t, "uid", c.LoginAttr)
+	assert.Equal(t, "cn", c.NameAttr)
+	assert.Equal(t, "(objectClass=groupOfNames)", c.GroupFilter)
+	assert.Equal(t, "cn", c.GroupNameAttr)
+	assert.Equal(t, "member", c.GroupMemberAttr)
+	assert.Equal(t, 10*time.Second, c.Timeout)
+
+	c, err = NewClient(Settings{URL: "ldaps://localhost:636", UserBaseDN: testUserBaseDN, LoginAttr: "sAMAccountName"})
+	require.NoError(t, err)
+	assert.Equal(t, "sAMAccountName", c.LoginAttr)
+
+	_, err = NewClient(Settings{URL: "http://localhost:389", UserBaseDN: testUserBaseDN})
+	assert.Error(t, err)
+
+	_, err = NewClient(Settings{URL: "ldap://localhost:389"})
+	assert.Error(t, err)
+
+	_, err = NewClient(Settings{URL: "ldap://local host:389", UserBaseDN: testUserBaseDN})
+	assert.Error(t, err)
+}
+
+func TestClient_Authenticate(t *testing.T) {
+	c := prepareTestClient(t, testDirectoryEntries())
+
+	user, err := c.Authenticate("john", "john_secret")
+	require.NoError(t, err)
+	assert.Equal(t, User{DN: "uid=john,ou=users,dc=example,dc=org", Login: "john", Name: "John Doe"}, user)
+
+	_, err = c.Authenticate("john", "bad_password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = c.Authenticate("john", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = c.Authenticate("unknown", "secret")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	// a disabled user is returned without password check
+	user, err = c.Authenticate("bob", "any")
+	require.NoError(t, err)
+	assert.True(t, user.Disabled)
+
+	// service account password is wrong
+	c.BindPassword = "bad_password"
+	_, err = c.Authenticate("john", "john_secret")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+
+	// directory unreachable
+	c.URL = "ldap://127.0.0.1:1"
+	_, err = c.Authenticate("john", "john_secret")
+	assert.Error(t, err)
+}
+
+func TestClient_Users(t *testing.T) {
+	c := prepareTestClient(t, testDirectoryEntries())
+
+	users, err := c.Users()
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+	assert.Equal(t, "john", users[0].Login)
+	assert.False(t, users[0].Disabled)
+	assert.Equal(t, "bob", users[1].Login)
+	assert.True(t, users[1].Disabled)
+	assert.Equal(t, "alice", users[2].Login)
+	assert.Equal(t, "alice", users[2].Name, "name defaults to login")
+}
+
+func TestClient_Groups(t *testing.T) {
+	c := prepareTestClient(t, testDirectoryEntries())
+
+	groups, err := c.Groups()
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, Group{
+		DN:      "cn=developers,ou=groups,dc=example,dc=org",
+		Name:    "developers",
+		Members: []string{"uid=john,ou=users,dc=example,dc=org", "uid=alice,ou=users,dc=example,dc=org"},
+	}, groups[0])
+	assert.Equal(t, "operators", groups[1].Name)
+
+	c.GroupBaseDN = ""
+	_, err = c.Groups()
+	assert.Error(t, err)
+}
+
+func TestIsDisabled(t *testing.T) {
+	tbl := []struct {
+		attr, value string
+		disabled    bool
+	}{
+		{"nsAccountLock", "TRUE", true},
+		{"nsAccountLock", "false", false},
+		{"nsAccountLock", "", false},
+		{"pwdAccountLockedTime", "20230101000000Z", true},
+		{"userAccountControl", "514", true},
+		{"userAccountControl", "512", false},
+		{"userAccountControl", "bad", false},
+	}
+
+	for i, tt := range tbl {
+		assert.Equal(t, tt.disabled, isDisabled(tt.attr, tt.value), "case %d", i)
+	}
+}
+
+// testEntry is an entry of in-process test directory
+type testEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// filterTermRe matches equality and presence terms of filters, test filters are conjunctions of terms only
+var filterTermRe = regexp.MustCompile(`\(([^()=&|!]+)=([^()]*)\)`)
+
+func (e testEntry) match(filter string) bool {
+	for _, term := range filterTermRe.FindAllStringSubmatch(filter, -1) {
+		var matched bool
+		for name, values := range e.attrs {
+			if !strings.EqualFold(name, term[1]) {
+				continue
+			}
+			for _, v := range values {
+				if term[2] == "*" || strings.EqualFold(v, term[2]) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func testDirectoryEntries() []testEntry {
+	return []testEntry{
+		{dn: "uid=john,ou=users,dc=example,dc=org", attrs: map[string][]string{
+			"objectClass": {"person"}, "uid": {"john"}, "cn": {"John Doe"}, "userPassword": {"john_secret"},
+		}},
+		{dn: "uid=bob,ou=users,dc=example,dc=org", attrs: map[string][]string{
+			"objectClass": {"person"}, "uid": {"bob"}, "cn": {"Bob"}, "userPassword": {"bob_secret"}, "nsAccountLock": {"true"},
+		}},
+		{dn: "uid=alice,ou=users,dc=example,dc=org", attrs: map[string][]string{
+			"objectClass": {"person"}, "uid": {"alice"}, "userPassword": {"alice_secret"},
+		}},
+		{dn: "cn=developers,ou=groups,dc=example,dc=org", attrs: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"developers"},
+			"member": {"uid=john,ou=users,dc=example,dc=org", "uid=alice,ou=users,dc=example,dc=org"},
+		}},
+		{dn: "cn=operators,ou=groups,dc=example,dc=org", attrs: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"operators"}, "member": {"uid=bob,ou=users,dc=example,dc=org"},
+		}},
+	}
+}
+
+// prepareTestClient starts in-process directory server with entries and returns a client which is connected to it
+func prepareTestClient(t *testing.T, entries []testEntry) *Client {
+	t.Helper()
+
+	srv, err := gldap.NewServer()
+	require.NoError(t, err)
+	mux, err := gldap.NewMux()
+	require.NoError(t, err)
+
+	require.NoError(t, mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+		defer func() { _ = w.Write(resp) }()
+
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			return
+		}
+		if m.UserName == testBindDN && string(m.Password) == testBindPassword {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+		for _, e := range entries {
+			if e.dn == m.UserName && len(e.attrs["userPassword"]) > 0 && e.attrs["userPassword"][0] == string(m.Password) {
+				resp.SetResultCode(gldap.ResultSuccess)
+				return
+			}
+		}
+	}))
+
+	require.NoError(t, mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultNoSuchObject))
+		defer func() { _ = w.Write(resp) }()
+
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			return
+		}
+
+		var found bool
+		for _, e := range entries {
+			if !strings.HasSuffix(e.dn, ","+m.BaseDN) {
+				continue
+			}
+			found = true
+			if !e.match(m.Filter) {
+				continue
+			}
+			entry := r.NewSearchResponseEntry(e.dn)
+			for name, values := range e.attrs {
+				entry.AddAttribute(name, values)
+			}
+			_ = w.Write(entry)
+		}
+		if found {
+			resp.SetResultCode(gldap.ResultSuccess)
+		}
+	}))
+	require.NoError(t, srv.Router(mux))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	go func() { _ = srv.Run(addr) }()
+	t.Cleanup(func() { _ = srv.Stop() })
+	require.Eventually(t, srv.Ready, time.Second, 10*time.Millisecond)
+
+	c, err := NewClient(Settings{
+		URL:          fmt.Sprintf("ldap://%s", addr),
+		BindDN:       testBindDN,
+		BindPassword: testBindPassword,
+		UserBaseDN:   testUserBaseDN,
+		GroupBaseDN:  testGroupBaseDN,
+		DisabledAttr: "nsAccountLock",
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+	return c
+}
@@ -0,0 +1,192 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// Syncer mirrors directory groups and membership to the store periodically. Only users which exist in the store
+// are synced, directory users are created in the store on first login.
+type Syncer struct {
+	Client   *Client
+	Storage  engine.Interface
+	Interval time.Duration // period between synchronizations
+}
+
+// Run synchronizes the directory until context canceled
+func (s *Syncer) Run(ctx context.Context) {
+	log.Printf("[INFO] ldap synchronization enabled, interval %s", s.Interval)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx); err != nil {
+				log.Printf("[ERROR] ldap synchronization failed: %v", err)
+				continue
+			}
+			log.Printf("[DEBUG] ldap synchronization completed")
+		}
+	}
+}
+
+// Sync disables store users which accounts disabled in the directory, creates store groups for directory groups
+// and makes membership of store users equal to membership in the directory groups
+func (s *Syncer) Sync(ctx context.Context) error {
+	entries, err := s.Client.Users()
+	if err != nil {
+		return err
+	}
+
+	storeUsers, err := s.storeUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+
+	// users keeps store users by lower-cased DN because group members refer to users by DN
+	users := make(map[string]store.User, len(entries))
+	for _, entry := range entries {
+		user, ok := storeUsers[entry.Login]
+		if !ok {
+			continue
+		}
+
+		// a disabled directory account blocks the store user, it isn't unblocked automatically when the account enabled again
+		if entry.Disabled && !user.Disabled {
+			user.Disabled = true
+			if err = s.Storage.UpdateUser(ctx, user); err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "failed to disable user %q", user.Login))
+				continue
+			}
+			log.Printf("[INFO] user %q disabled because directory account disabled", user.Login)
+		}
+		users[strings.ToLower(entry.DN)] = user
+	}
+
+	if s.Client.GroupBaseDN == "" {
+		return errs
+	}
+
+	groups, err := s.Client.Groups()
+	if err != nil {
+		return multierror.Append(errs, err)
+	}
+
+	storeGroups, err := s.storeGroups(ctx)
+	if err != nil {
+		return multierror.Append(errs, err)
+	}
+
+	for _, g := range groups {
+		if err = s.syncGroup(ctx, g, storeGroups, users); err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "failed to sync group %q", g.Name))
+		}
+	}
+	return errs
+}
+
+// syncGroup creates a store group with the directory group name when it doesn't exist and updates its members.
+// Users aren't removed from own primary group because it's a part of the user record.
+func (s *Syncer) syncGroup(ctx context.Context, g Group, storeGroups map[string]store.Group, users map[string]store.User) error {
+	group, ok := storeGroups[g.Name]
+	if !ok {
+		group = store.Group{Name: g.Name, Description: fmt.Sprintf("synced from LDAP group %s", g.DN)}
+		if err := s.Storage.CreateGroup(ctx, &group); err != nil {
+			return errors.Wrap(err, "failed to create group")
+		}
+		storeGroups[g.Name] = group
+		log.Printf("[INFO] group %q created from LDAP group %s", group.Name, g.DN)
+	}
+
+	expected := make(map[int64]bool, len(g.Members))
+	for _, dn := range g.Members {
+		if user, found := users[strings.ToLower(dn)]; found {
+			expected[user.ID] = true
+		}
+	}
+
+	members, err := s.Storage.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"group_id": group.ID}})
+	if err != nil {
+		return errors.Wrap(err, "failed to get group members")
+	}
+
+	// directory users are known by DN, members which are local users only are kept in the group
+	directoryUsers := make(map[int64]store.User, len(users))
+	for _, u := range users {
+		directoryUsers[u.ID] = u
+	}
+
+	var errs error
+	current := make(map[int64]bool, len(members.Data))
+	for _, m := range members.Data {
+		member := m.(store.GroupMember)
+		current[member.UserID] = true
+
+		user, isDirectoryUser := directoryUsers[member.UserID]
+		if expected[member.UserID] || !isDirectoryUser || user.Group == group.ID {
+			continue
+		}
+		if err = s.Storage.DeleteGroupMember(ctx, member); err != nil && !errors.Is(err, engine.ErrNotFound) {
+			errs = multierror.Append(errs, errors.Wrapf(err, "failed to remove user %q", user.Login))
+			continue
+		}
+		log.Printf("[INFO] user %q removed from group %q", user.Login, group.Name)
+	}
+
+	for userID := range expected {
+		if current[userID] {
+			continue
+		}
+		err = s.Storage.AddGroupMember(ctx, store.GroupMember{GroupID: group.ID, UserID: userID})
+		if err != nil && !errors.Is(err, engine.ErrDuplicateEntry) {
+			errs = multierror.Append(errs, errors.Wrapf(err, "failed to add user %q", directoryUsers[userID].Login))
+			continue
+		}
+		log.Printf("[INFO] user %q added to group %q", directoryUsers[userID].Login, group.Name)
+	}
+	return errs
+}
+
+func (s *Syncer) storeUsers(ctx context.Context) (map[string]store.User, error) {
+	list, err := s.Storage.FindUsers(ctx, engine.QueryFilter{}, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users")
+	}
+
+	// local users with logins of directory accounts aren't synced, they aren't bound to the directory
+	users := make(map[string]store.User, len(list.Data))
+	for _, u := range list.Data {
+		user := u.(store.User)
+		if isDirectoryUser(user) {
+			users[user.Login] = user
+		}
+	}
+	return users, nil
+}
+
+func (s *Syncer) storeGroups(ctx context.Context) (map[string]store.Group, error) {
+	list, err := s.Storage.FindGroups(ctx, engine.QueryFilter{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get groups")
+	}
+
+	groups := make(map[string]store.Group, len(list.Data))
+	for _, g := range list.Data {
+		group := g.(store.Group)
+		groups[group.Name] = group
+	}
+	return groups, nil
+}
@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestSyncer_Sync(t *testing.T) {
+	ctx := context.Background()
+	storage := prepareStorage(ctx, t)
+	s := &Syncer{Client: prepareTestClient(t, testDirectoryEntries()), Storage: storage, Interval: time.Minute}
+
+	users := map[string]*store.User{}
+	for _, login := range []string{"john", "bob", "alice", "local"} {
+		u := &store.User{Login: login, Name: login, Password: login + "_password", Role: store.UserRole, SSOID: directoryID(login)}
+		if login == "local" {
+			u.SSOID = ""
+		}
+		require.NoError(t, storage.CreateUser(ctx, u))
+		users[login] = u
+	}
+
+	// a stale member of directory group and a local user which isn't known by the directory
+	developers := store.Group{Name: "developers", Description: "existed group"}
+	require.NoError(t, storage.CreateGroup(ctx, &developers))
+	require.NoError(t, storage.AddGroupMember(ctx, store.GroupMember{GroupID: developers.ID, UserID: users["bob"].ID}))
+	require.NoError(t, storage.AddGroupMember(ctx, store.GroupMember{GroupID: developers.ID, UserID: users["local"].ID}))
+
+	require.NoError(t, s.Sync(ctx))
+
+	bob, err := storage.GetUser(ctx, "bob")
+	require.NoError(t, err)
+	assert.True(t, bob.Disabled)
+	john, err := storage.GetUser(ctx, "john")
+	require.NoError(t, err)
+	assert.False(t, john.Disabled)
+
+	assert.ElementsMatch(t, []int64{users["john"].ID, users["alice"].ID, users["local"].ID}, groupMembers(ctx, t, storage, developers.ID))
+
+	groups, err := storage.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"name": "operators"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), groups.Total)
+	operators := groups.Data[0].(store.Group)
+	assert.Equal(t, "synced from LDAP group cn=operators,ou=groups,dc=example,dc=org", operators.Description)
+	assert.Equal(t, []int64{users["bob"].ID}, groupMembers(ctx, t, storage, operators.ID))
+
+	// repeated sync doesn't change anything
+	require.NoError(t, s.Sync(ctx))
+	assert.ElementsMatch(t, []int64{users["john"].ID, users["alice"].ID, users["local"].ID}, groupMembers(ctx, t, storage, developers.ID))
+
+	// groups aren't synced without groups base DN
+	s.Client.GroupBaseDN = ""
+	require.NoError(t, storage.DeleteGroupMember(ctx, store.GroupMember{GroupID: operators.ID, UserID: users["bob"].ID}))
+	require.NoError(t, s.Sync(ctx))
+	assert.Empty(t, groupMembers(ctx, t, storage, operators.ID))
+
+	s.Client.URL = "ldap://127.0.0.1:1"
+	assert.Error(t, s.Sync(ctx))
+}
+
+func TestSyncer_SyncLocalUser(t *testing.T) {
+	ctx := context.Background()
+	storage := prepareStorage(ctx, t)
+	s := &Syncer{Client: prepareTestClient(t, testDirectoryEntries()), Storage: storage, Interval: time.Minute}
+
+	// a local user with a login of disabled directory account which is a member of directory group isn't synced
+	bob := store.User{Login: "bob", Name: "Bob", Password: "bob_password", Role: store.AdminRole}
+	require.NoError(t, storage.CreateUser(ctx, &bob))
+	require.NoError(t, s.Sync(ctx))
+
+	bob, err := storage.GetUser(ctx, "bob")
+	require.NoError(t, err)
+	assert.False(t, bob.Disabled)
+
+	groups, err := storage.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"name": "operators"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), groups.Total)
+	assert.Empty(t, groupMembers(ctx, t, storage, groups.Data[0].(store.Group).ID))
+}
+
+func groupMembers(ctx context.Context, t *testing.T, storage engine.Interface, groupID int64) []int64 {
+	members, err := storage.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"group_id": groupID}})
+	require.NoError(t, err)
+
+	var result []int64
+	for _, m := range members.Data {
+		result = append(result, m.(store.GroupMember).UserID)
+	}
+	return result
+}
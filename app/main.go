@@ -31,6 +31,13 @@ func main() {
 	}
 	setupLog(opts.Debug)
 
+	if opts.Command != "" {
+		if err = cmd.RunCommand(opts); err != nil {
+			log.Fatalf("[ERROR] failed to run %s command: %v", opts.Command, err)
+		}
+		return
+	}
+
 	log.Print("[INFO] server starting...")
 	log.Printf("[DEBUG] current configuration: %+v", opts)
 
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/docker/libtrust"
+	"github.com/pkg/errors"
+)
+
+// algorithms of signing keys which can be generated, RSA-2048 keys aren't generated anymore, but existed ones are loaded.
+// Ed25519 keys aren't supported because Docker Registry verifies RSA and ECDSA token signatures only.
+const (
+	KeyAlgorithmECDSAP256 = "ecdsa-p256"
+	KeyAlgorithmECDSAP384 = "ecdsa-p384"
+	KeyAlgorithmRSA3072   = "rsa-3072"
+	KeyAlgorithmRSA4096   = "rsa-4096"
+
+	defaultKeyAlgorithm = KeyAlgorithmECDSAP256
+)
+
+// keyGenerators contains generators of keys for each supported algorithm
+var keyGenerators = map[string]func() (libtrust.PrivateKey, error){
+	KeyAlgorithmECDSAP256: libtrust.GenerateECP256PrivateKey,
+	KeyAlgorithmECDSAP384: libtrust.GenerateECP384PrivateKey,
+	KeyAlgorithmRSA3072:   libtrust.GenerateRSA3072PrivateKey,
+	KeyAlgorithmRSA4096:   libtrust.GenerateRSA4096PrivateKey,
+}
+
+// checkKeyAlgorithm returns error when keys of the algorithm can't be generated
+func checkKeyAlgorithm(algorithm string) error {
+	if _, ok := keyGenerators[algorithm]; !ok {
+		return errors.Errorf("key algorithm '%s' not supported", algorithm)
+	}
+	return nil
+}
+
+// generatePrivateKey generates a new key with the algorithm
+func generatePrivateKey(algorithm string) (libtrust.PrivateKey, error) {
+	if err := checkKeyAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+	return keyGenerators[algorithm]()
+}
+
+// signingAlgorithm returns the JWT 'alg' value of signatures which the key makes
+func signingAlgorithm(publicKey libtrust.PublicKey) (string, error) {
+	switch key := publicKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+	}
+	return "", errors.Errorf("signing algorithm of key %s unknown", publicKey.KeyID())
+}
+
+// loadPrivateKey loads a private key from PEM file, besides formats which libtrust supports
+// it loads PKCS #8 keys which are made by external tools, e.g. 'openssl genpkey'
+func loadPrivateKey(path string) (libtrust.PrivateKey, error) {
+	block, err := readPEMBlock(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return libtrust.LoadKeyFile(path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode PKCS #8 Private Key PEM data: %s", err)
+	}
+	return libtrust.FromCryptoPrivateKey(key)
+}
+
+func readPEMBlock(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	return block, nil
+}
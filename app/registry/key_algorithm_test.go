@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/libtrust"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessToken_KeyAlgorithms(t *testing.T) {
+	testTable := []struct {
+		algorithm string
+		jwtAlg    string
+	}{
+		{algorithm: KeyAlgorithmECDSAP256, jwtAlg: "ES256"},
+		{algorithm: KeyAlgorithmECDSAP384, jwtAlg: "ES384"},
+		{algorithm: KeyAlgorithmRSA3072, jwtAlg: "RS256"},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			certs := testCertsPaths(t.TempDir())
+			rt, err := NewRegistryToken(TokenKeyAlgorithm(tt.algorithm), CertsName(certs))
+			require.NoError(t, err)
+
+			clientToken, err := rt.generate(&TokenRequest{Account: "john", Service: "registry"})
+			require.NoError(t, err)
+
+			// the signature is verified with the algorithm of token header
+			parts := strings.Split(clientToken.Token, ".")
+			require.Len(t, parts, 3)
+			parsed, _, err := new(jwt.Parser).ParseUnverified(clientToken.Token, jwt.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.jwtAlg, parsed.Header["alg"])
+			assert.Equal(t, rt.publicKey.KeyID(), parsed.Header["kid"])
+
+			sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+			require.NoError(t, err)
+			assert.NoError(t, rt.publicKey.Verify(strings.NewReader(parts[0]+"."+parts[1]), tt.jwtAlg, sig))
+
+			keys := rt.Keys()
+			require.Len(t, keys, 1)
+			assert.Equal(t, tt.jwtAlg, keys[0].Algorithm)
+
+			// existed keys are loaded and published in the CA bundle
+			loaded, err := NewRegistryToken(CertsName(certs))
+			require.NoError(t, err)
+			assert.Equal(t, rt.publicKey.KeyID(), loaded.publicKey.KeyID())
+			assert.Equal(t, rt.caRoot.Raw, loaded.caRoot.Raw)
+			assert.NoError(t, loaded.publicKey.Verify(strings.NewReader(parts[0]+"."+parts[1]), tt.jwtAlg, sig))
+		})
+	}
+
+	_, err := NewRegistryToken(TokenKeyAlgorithm("rsa-2048"), CertsName(testCertsPaths(t.TempDir())))
+	assert.Error(t, err, "new RSA-2048 keys aren't allowed")
+
+	_, err = NewRegistryToken(TokenKeyAlgorithm("ed25519"), CertsName(testCertsPaths(t.TempDir())))
+	assert.Error(t, err, "registry doesn't verify EdDSA signatures")
+}
+
+func TestAccessToken_ECDSATokenVerify(t *testing.T) {
+	rt, err := NewRegistryToken(CertsName(testCertsPaths(t.TempDir())))
+	require.NoError(t, err)
+
+	clientToken, err := rt.generate(&TokenRequest{Account: "john", Service: "registry"})
+	require.NoError(t, err)
+
+	// the signature format is compatible with other JWT implementations
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(clientToken.Token, claims, func(token *jwt.Token) (interface{}, error) {
+		return rt.publicKey.CryptoPublicKey(), nil
+	})
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+	assert.Equal(t, "john", claims["sub"])
+}
+
+func TestAccessToken_LoadExistedKeys(t *testing.T) {
+	// keys which were generated by previous versions
+	certs := testCertsPaths(t.TempDir())
+	rsaKey, err := libtrust.GenerateRSA2048PrivateKey()
+	require.NoError(t, err)
+	writeTestKeys(t, certs, rsaKey)
+
+	rt, err := NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+	assert.Equal(t, rsaKey.KeyID(), rt.publicKey.KeyID())
+	assert.Equal(t, "RS256", rt.Keys()[0].Algorithm)
+
+	// PKCS #8 keys which were made by external tools
+	certs = testCertsPaths(t.TempDir())
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	key, err := libtrust.FromCryptoPrivateKey(ecKey)
+	require.NoError(t, err)
+	writeTestKeys(t, certs, key)
+
+	der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certs.KeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	rt, err = NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+	assert.Equal(t, key.KeyID(), rt.privateKey.KeyID())
+	clientToken, err := rt.generate(&TokenRequest{Account: "john"})
+	require.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(clientToken.Token, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "ES384", parsed.Header["alg"])
+
+	require.NoError(t, os.WriteFile(certs.KeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("bad")}), 0o600))
+	_, err = NewRegistryToken(CertsName(certs))
+	assert.Error(t, err)
+
+	// registry doesn't verify signatures of Ed25519 keys
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certs.KeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	_, err = NewRegistryToken(CertsName(certs))
+	assert.Error(t, err)
+}
+
+func testCertsPaths(dir string) Certs {
+	return Certs{
+		RootPath:      dir,
+		KeyPath:       filepath.Join(dir, "test.key"),
+		PublicKeyPath: filepath.Join(dir, "test.pub"),
+		CARootPath:    filepath.Join(dir, "test_ca.crt"),
+	}
+}
+
+func writeTestKeys(t *testing.T, certs Certs, key libtrust.PrivateKey) {
+	require.NoError(t, libtrust.SaveKey(certs.KeyPath, key))
+	require.NoError(t, libtrust.SavePublicKey(certs.PublicKeyPath, key.PublicKey()))
+	cert, err := libtrust.GenerateCACert(key, key.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certs.CARootPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600))
+}
@@ -0,0 +1,375 @@
+package registry
+
+// Signing keys of tokens are rotated without downtime of the registry. A new key is staged first, its certificate is
+// published in the CA bundle together with the active key and the registry should be restarted for reload the bundle.
+// Then the staged key is switched to be active, tokens are signed with it, but the previous key is still trusted by
+// the registry for tokens which were issued before. At last, the previous key is retired and removed from the bundle.
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/libtrust"
+	"github.com/pkg/errors"
+)
+
+// statuses of signing keys in rotation
+const (
+	KeyStatusActive   = "active"   // the key signs new tokens
+	KeyStatusStaged   = "staged"   // the key is published in the CA bundle, but doesn't sign tokens yet
+	KeyStatusPrevious = "previous" // the key doesn't sign tokens, but is published in the CA bundle until it's retired
+)
+
+// keyExpiryWarningPeriod defines how long before expiry of a key certificate warnings are reported
+const keyExpiryWarningPeriod = 30 * 24 * time.Hour
+
+// names of slots which are appended to file names of keys
+const (
+	stagedKeySlot   = "staged"
+	previousKeySlot = "previous"
+)
+
+// ErrKeyRotation returns when a rotation step isn't allowed with the current state of keys
+var ErrKeyRotation = errors.New("key rotation step not allowed")
+
+// ErrTokenAuthRequired returns when signing keys are requested, but registry uses basic auth
+var ErrTokenAuthRequired = errors.New("signing keys are available with token auth only")
+
+// SigningKey describes a key of token signature and its state in rotation
+type SigningKey struct {
+	KeyID     string   `json:"kid"`
+	Algorithm string   `json:"alg"`
+	Status    string   `json:"status"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// signingKey is a key pair with a certificate which is published in the CA bundle
+type signingKey struct {
+	privateKey libtrust.PrivateKey
+	publicKey  libtrust.PublicKey
+	cert       *x509.Certificate
+}
+
+// Keys returns all published signing keys, the active key is the first
+func (rt *AccessToken) Keys() []SigningKey {
+	rt.keysLock.RLock()
+	defer rt.keysLock.RUnlock()
+
+	now := time.Now()
+	keys := []SigningKey{rt.activeKey().describe(KeyStatusActive, now)}
+	if rt.stagedKey != nil {
+		keys = append(keys, rt.stagedKey.describe(KeyStatusStaged, now))
+	}
+	if rt.previousKey != nil {
+		keys = append(keys, rt.previousKey.describe(KeyStatusPrevious, now))
+	}
+	return keys
+}
+
+// StageKey generates a new key and publishes it in the CA bundle, the key doesn't sign tokens until it's switched.
+// Only one key can be staged at the same time.
+func (rt *AccessToken) StageKey() (SigningKey, error) {
+	rt.keysLock.Lock()
+	defer rt.keysLock.Unlock()
+
+	if rt.stagedKey != nil {
+		return SigningKey{}, errors.Wrap(ErrKeyRotation, "a key is staged already")
+	}
+
+	key, err := rt.newSigningKey()
+	if err != nil {
+		return SigningKey{}, errors.Wrap(err, "failed to generate a new key")
+	}
+
+	if err = rt.saveSlotKey(stagedKeySlot, key); err != nil {
+		return SigningKey{}, err
+	}
+
+	rt.stagedKey = key
+	if err = rt.saveBundle(); err != nil {
+		rt.stagedKey = nil
+		rt.removeSlotKey(stagedKeySlot)
+		return SigningKey{}, err
+	}
+
+	rt.l.Logf("[INFO] signing key %s staged, restart the registry for reload the CA bundle before switch", key.publicKey.KeyID())
+	return key.describe(KeyStatusStaged, time.Now()), nil
+}
+
+// SwitchKey makes the staged key active, the active key becomes previous and is kept in the CA bundle
+// for verify tokens which were signed with it. The previous key should be retired before the next switch.
+func (rt *AccessToken) SwitchKey() (SigningKey, error) {
+	rt.keysLock.Lock()
+	defer rt.keysLock.Unlock()
+
+	if rt.stagedKey == nil {
+		return SigningKey{}, errors.Wrap(ErrKeyRotation, "there is no staged key")
+	}
+
+	if rt.previousKey != nil {
+		return SigningKey{}, errors.Wrap(ErrKeyRotation, "the previous key should be retired before switch")
+	}
+
+	// files of the active key move to the previous slot, files of the staged key take their place
+	moves := [][2]string{
+		{rt.KeyPath, slotPath(rt.KeyPath, previousKeySlot)},
+		{rt.PublicKeyPath, slotPath(rt.PublicKeyPath, previousKeySlot)},
+		{slotPath(rt.KeyPath, stagedKeySlot), rt.KeyPath},
+		{slotPath(rt.PublicKeyPath, stagedKeySlot), rt.PublicKeyPath},
+	}
+	for i, move := range moves {
+		if err := os.Rename(move[0], move[1]); err != nil {
+			// rollback files which were moved already
+			for j := i - 1; j >= 0; j-- {
+				_ = os.Rename(moves[j][1], moves[j][0])
+			}
+			return SigningKey{}, errors.Wrap(err, "failed to move key files")
+		}
+	}
+
+	rt.previousKey = rt.activeKey()
+	rt.privateKey, rt.publicKey, rt.caRoot = rt.stagedKey.privateKey, rt.stagedKey.publicKey, rt.stagedKey.cert
+	rt.stagedKey = nil
+
+	// the bundle keeps the same certificates, but the active one should be the first
+	if err := rt.saveBundle(); err != nil {
+		rt.l.Logf("[WARN] failed to reorder CA bundle after switch of keys: %v", err)
+	}
+
+	rt.l.Logf("[INFO] tokens are signed with key %s, the previous key is %s", rt.publicKey.KeyID(), rt.previousKey.publicKey.KeyID())
+	return rt.activeKey().describe(KeyStatusActive, time.Now()), nil
+}
+
+// RetireKey removes the previous key from the CA bundle, tokens which were signed with it aren't trusted by the registry
+// after reload of the bundle.
+func (rt *AccessToken) RetireKey() (SigningKey, error) {
+	rt.keysLock.Lock()
+	defer rt.keysLock.Unlock()
+
+	if rt.previousKey == nil {
+		return SigningKey{}, errors.Wrap(ErrKeyRotation, "there is no previous key")
+	}
+
+	retired := rt.previousKey
+	rt.previousKey = nil
+	if err := rt.saveBundle(); err != nil {
+		rt.previousKey = retired
+		return SigningKey{}, err
+	}
+	rt.removeSlotKey(previousKeySlot)
+
+	rt.l.Logf("[INFO] signing key %s retired", retired.publicKey.KeyID())
+	return retired.describe(KeyStatusPrevious, time.Now()), nil
+}
+
+// publishedKeys returns keys which certificates are in the CA bundle, the active key is the first
+func (rt *AccessToken) publishedKeys() []*signingKey {
+	keys := []*signingKey{rt.activeKey()}
+	for _, key := range []*signingKey{rt.stagedKey, rt.previousKey} {
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// bundleCerts returns certificates of the CA bundle, certificates of published keys are followed by unmanaged ones
+func (rt *AccessToken) bundleCerts() []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, key := range rt.publishedKeys() {
+		certs = appendUniqueCert(certs, key.cert)
+	}
+	for _, cert := range rt.unmanagedCerts {
+		certs = appendUniqueCert(certs, cert)
+	}
+	return certs
+}
+
+// unmanagedBundleCerts returns certificates of the bundle which don't belong to published keys
+func (rt *AccessToken) unmanagedBundleCerts(bundle []*x509.Certificate) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, cert := range bundle {
+		managed := false
+		for _, key := range rt.publishedKeys() {
+			if cert.Equal(key.cert) {
+				managed = true
+				break
+			}
+		}
+		if !managed {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+func (rt *AccessToken) activeKey() *signingKey {
+	return &signingKey{privateKey: rt.privateKey, publicKey: rt.publicKey, cert: rt.caRoot}
+}
+
+// newSigningKey generates a key pair with a self-signed certificate which expires after the key validity period
+func (rt *AccessToken) newSigningKey() (*signingKey, error) {
+	algorithm := rt.keyAlgorithm
+	if algorithm == "" {
+		algorithm = defaultKeyAlgorithm
+	}
+
+	privateKey, err := generatePrivateKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := privateKey.PublicKey()
+
+	template, err := libtrust.GenerateCACert(privateKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	validity := rt.keyValidity
+	if validity <= 0 {
+		validity = defaultKeyValidity
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate serial number of certificate")
+	}
+
+	now := time.Now()
+	template.SerialNumber = serial
+	template.NotBefore = now
+	template.NotAfter = now.Add(validity)
+
+	// generated keys hasn't Subject Alternative Name for requested IP and Domain when creating with libtrust
+	// and that should add this values after certificate created and extracting raw bytes after that
+	rt.appendDSnToCertificate(template)
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, publicKey.CryptoPublicKey(), privateKey.CryptoPrivateKey())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create certificate")
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse certificate")
+	}
+
+	return &signingKey{privateKey: privateKey, publicKey: publicKey, cert: cert}, nil
+}
+
+// loadSlotKey loads a key of the slot if files of it exist, a certificate of the key should be in the CA bundle
+func (rt *AccessToken) loadSlotKey(slot string, bundle []*x509.Certificate) (*signingKey, error) {
+	keyPath := slotPath(rt.KeyPath, slot)
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	privateKey, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s key", slot)
+	}
+
+	publicKey, err := libtrust.LoadPublicKeyFile(slotPath(rt.PublicKeyPath, slot))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load public %s key", slot)
+	}
+
+	cert := findKeyCertificate(bundle, publicKey)
+	if cert == nil {
+		return nil, errors.Errorf("certificate of %s key %s not found in CA bundle", slot, publicKey.KeyID())
+	}
+
+	return &signingKey{privateKey: privateKey, publicKey: publicKey, cert: cert}, nil
+}
+
+// saveSlotKey writes files of a key to the slot, existed files aren't overwritten
+func (rt *AccessToken) saveSlotKey(slot string, key *signingKey) error {
+	keyPath, publicKeyPath := slotPath(rt.KeyPath, slot), slotPath(rt.PublicKeyPath, slot)
+	for _, path := range []string{keyPath, publicKeyPath} {
+		if _, err := os.Stat(path); err == nil {
+			return errors.Errorf(errTemplateCertFileAlreadyExist, path)
+		}
+	}
+
+	if err := libtrust.SaveKey(keyPath, key.privateKey); err != nil {
+		return errors.Wrapf(err, "failed to save %s key to file", slot)
+	}
+
+	if err := libtrust.SavePublicKey(publicKeyPath, key.publicKey); err != nil {
+		_ = os.Remove(keyPath)
+		return errors.Wrapf(err, "failed to save public %s key to file", slot)
+	}
+	return nil
+}
+
+func (rt *AccessToken) removeSlotKey(slot string) {
+	for _, path := range []string{slotPath(rt.KeyPath, slot), slotPath(rt.PublicKeyPath, slot)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			rt.l.Logf("[WARN] failed to remove file of %s key: %v", slot, err)
+		}
+	}
+}
+
+// describe returns a public description of the key with warnings about expiry of its certificate
+func (k *signingKey) describe(status string, now time.Time) SigningKey {
+	algorithm, _ := signingAlgorithm(k.publicKey)
+	key := SigningKey{
+		KeyID:     k.publicKey.KeyID(),
+		Algorithm: algorithm,
+		Status:    status,
+		CreatedAt: k.cert.NotBefore.Unix(),
+		ExpiresAt: k.cert.NotAfter.Unix(),
+	}
+
+	// a previous key doesn't sign tokens and its expiry doesn't matter
+	if status == KeyStatusPrevious {
+		return key
+	}
+
+	switch {
+	case !now.Before(k.cert.NotAfter):
+		key.Warnings = append(key.Warnings, fmt.Sprintf("certificate of the key expired at %s", k.cert.NotAfter.UTC().Format(time.RFC3339)))
+	case k.cert.NotAfter.Sub(now) < keyExpiryWarningPeriod:
+		warning := fmt.Sprintf("certificate of the key expires in %d days", int(k.cert.NotAfter.Sub(now).Hours()/24))
+		if status == KeyStatusActive {
+			warning += ", a new key should be staged and switched"
+		}
+		key.Warnings = append(key.Warnings, warning)
+	}
+	return key
+}
+
+// findKeyCertificate returns a certificate of the bundle with the public key
+func findKeyCertificate(bundle []*x509.Certificate, publicKey libtrust.PublicKey) *x509.Certificate {
+	for _, cert := range bundle {
+		certKey, err := libtrust.FromCryptoPublicKey(cert.PublicKey)
+		if err == nil && certKey.KeyID() == publicKey.KeyID() {
+			return cert
+		}
+	}
+	return nil
+}
+
+// appendUniqueCert appends a certificate unless the same certificate is in the list already
+func appendUniqueCert(certs []*x509.Certificate, cert *x509.Certificate) []*x509.Certificate {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return certs
+		}
+	}
+	return append(certs, cert)
+}
+
+// slotPath inserts a slot name before the extension of file, e.g. 'registry_auth.key' becomes 'registry_auth.staged.key'
+func slotPath(path, slot string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + slot + ext
+}
@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessToken_KeyRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	certs := Certs{
+		RootPath:      tmpDir,
+		KeyPath:       filepath.Join(tmpDir, "test.key"),
+		PublicKeyPath: filepath.Join(tmpDir, "test.pub"),
+		CARootPath:    filepath.Join(tmpDir, "test_ca.crt"),
+	}
+
+	rt, err := NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+
+	keys := rt.Keys()
+	require.Len(t, keys, 1)
+	initialKey := keys[0]
+	assert.Equal(t, KeyStatusActive, initialKey.Status)
+	assert.Equal(t, rt.publicKey.KeyID(), initialKey.KeyID)
+	assert.InDelta(t, time.Now().Add(defaultKeyValidity).Unix(), initialKey.ExpiresAt, 5)
+	assert.Empty(t, initialKey.Warnings)
+
+	// kid of token header defines a key which signed the token
+	tokenKeyID := func() string {
+		clientToken, errGenerate := rt.generate(&TokenRequest{Account: "john"})
+		require.NoError(t, errGenerate)
+		parsed, _, errParse := new(jwt.Parser).ParseUnverified(clientToken.Token, jwt.MapClaims{})
+		require.NoError(t, errParse)
+		return parsed.Header["kid"].(string)
+	}
+
+	bundleKeyIDs := func() []string {
+		bundle, errBundle := libtrust.LoadCertificateBundle(certs.CARootPath)
+		require.NoError(t, errBundle)
+		var ids []string
+		for _, cert := range bundle {
+			key, errKey := libtrust.FromCryptoPublicKey(cert.PublicKey)
+			require.NoError(t, errKey)
+			ids = append(ids, key.KeyID())
+		}
+		return ids
+	}
+
+	// a staged key is published, but doesn't sign tokens
+	stagedKey, err := rt.StageKey()
+	require.NoError(t, err)
+	assert.Equal(t, KeyStatusStaged, stagedKey.Status)
+	assert.NotEqual(t, initialKey.KeyID, stagedKey.KeyID)
+	assert.Equal(t, []string{initialKey.KeyID, stagedKey.KeyID}, bundleKeyIDs())
+	assert.Equal(t, initialKey.KeyID, tokenKeyID())
+	assert.FileExists(t, filepath.Join(tmpDir, "test.staged.key"))
+	assert.FileExists(t, filepath.Join(tmpDir, "test.staged.pub"))
+
+	_, err = rt.StageKey()
+	assert.True(t, errors.Is(err, ErrKeyRotation))
+	_, err = rt.RetireKey()
+	assert.True(t, errors.Is(err, ErrKeyRotation), "nothing to retire")
+
+	// keys are kept after restart
+	rt, err = NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+	assert.Equal(t, []SigningKey{initialKey, stagedKey}, rt.Keys())
+
+	// the switched key signs tokens, the previous one is still published
+	activeKey, err := rt.SwitchKey()
+	require.NoError(t, err)
+	assert.Equal(t, stagedKey.KeyID, activeKey.KeyID)
+	assert.Equal(t, KeyStatusActive, activeKey.Status)
+	assert.Equal(t, stagedKey.KeyID, tokenKeyID())
+	assert.Equal(t, []string{stagedKey.KeyID, initialKey.KeyID}, bundleKeyIDs())
+	assert.NoFileExists(t, filepath.Join(tmpDir, "test.staged.key"))
+	assert.FileExists(t, filepath.Join(tmpDir, "test.previous.key"))
+
+	_, err = rt.SwitchKey()
+	assert.True(t, errors.Is(err, ErrKeyRotation), "nothing to switch")
+
+	rt, err = NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+	keys = rt.Keys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, stagedKey.KeyID, keys[0].KeyID)
+	assert.Equal(t, KeyStatusPrevious, keys[1].Status)
+	assert.Equal(t, initialKey.KeyID, keys[1].KeyID)
+
+	// a next key can be staged, but not switched until the previous key is retired
+	nextKey, err := rt.StageKey()
+	require.NoError(t, err)
+	assert.Len(t, bundleKeyIDs(), 3)
+	_, err = rt.SwitchKey()
+	assert.True(t, errors.Is(err, ErrKeyRotation))
+
+	retiredKey, err := rt.RetireKey()
+	require.NoError(t, err)
+	assert.Equal(t, initialKey.KeyID, retiredKey.KeyID)
+	assert.Equal(t, []string{stagedKey.KeyID, nextKey.KeyID}, bundleKeyIDs())
+	assert.NoFileExists(t, filepath.Join(tmpDir, "test.previous.key"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "test.previous.pub"))
+
+	_, err = rt.SwitchKey()
+	require.NoError(t, err)
+	assert.Equal(t, nextKey.KeyID, tokenKeyID())
+}
+
+func TestAccessToken_KeyExpiryWarnings(t *testing.T) {
+	tmpDir := t.TempDir()
+	rt, err := NewRegistryToken(TokenKeyValidity(10*24*time.Hour), CertsName(Certs{
+		RootPath:      tmpDir,
+		KeyPath:       filepath.Join(tmpDir, "test.key"),
+		PublicKeyPath: filepath.Join(tmpDir, "test.pub"),
+		CARootPath:    filepath.Join(tmpDir, "test_ca.crt"),
+	}))
+	require.NoError(t, err)
+
+	keys := rt.Keys()
+	require.Len(t, keys, 1)
+	require.Len(t, keys[0].Warnings, 1)
+	assert.Contains(t, keys[0].Warnings[0], "expires in 9 days")
+
+	key := rt.activeKey()
+	key.cert.NotAfter = time.Now().Add(-time.Hour)
+	assert.Contains(t, key.describe(KeyStatusActive, time.Now()).Warnings[0], "expired")
+	assert.Empty(t, key.describe(KeyStatusPrevious, time.Now()).Warnings, "expiry of previous key doesn't matter")
+}
+
+func TestAccessToken_LoadStagedKeyWithoutCertificate(t *testing.T) {
+	tmpDir := t.TempDir()
+	certs := Certs{
+		RootPath:      tmpDir,
+		KeyPath:       filepath.Join(tmpDir, "test.key"),
+		PublicKeyPath: filepath.Join(tmpDir, "test.pub"),
+		CARootPath:    filepath.Join(tmpDir, "test_ca.crt"),
+	}
+
+	_, err := NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+
+	// a key which certificate isn't published in the bundle
+	key, err := libtrust.GenerateRSA2048PrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, libtrust.SaveKey(filepath.Join(tmpDir, "test.staged.key"), key))
+	require.NoError(t, libtrust.SavePublicKey(filepath.Join(tmpDir, "test.staged.pub"), key.PublicKey()))
+
+	_, err = NewRegistryToken(CertsName(certs))
+	assert.Error(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "test.staged.pub")))
+	_, err = NewRegistryToken(CertsName(certs))
+	assert.Error(t, err)
+}
+
+func TestAccessToken_KeyRotationKeepsUnmanagedCertificates(t *testing.T) {
+	tmpDir := t.TempDir()
+	certs := Certs{
+		RootPath:      tmpDir,
+		KeyPath:       filepath.Join(tmpDir, "test.key"),
+		PublicKeyPath: filepath.Join(tmpDir, "test.pub"),
+		CARootPath:    filepath.Join(tmpDir, "test_ca.crt"),
+	}
+
+	_, err := NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+
+	// a certificate of custom CA chain which is appended to the bundle by a user
+	customDir := t.TempDir()
+	_, err = NewRegistryToken(CertsName(Certs{
+		RootPath:      customDir,
+		KeyPath:       filepath.Join(customDir, "custom.key"),
+		PublicKeyPath: filepath.Join(customDir, "custom.pub"),
+		CARootPath:    filepath.Join(customDir, "custom_ca.crt"),
+	}))
+	require.NoError(t, err)
+	customCA, err := os.ReadFile(filepath.Join(customDir, "custom_ca.crt"))
+	require.NoError(t, err)
+	customBundle, err := libtrust.LoadCertificateBundle(filepath.Join(customDir, "custom_ca.crt"))
+	require.NoError(t, err)
+	require.Len(t, customBundle, 1)
+
+	bundle, err := os.ReadFile(certs.CARootPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certs.CARootPath, append(bundle, customCA...), 0o600))
+
+	rt, err := NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+
+	bundleCerts := func() []*x509.Certificate {
+		loaded, errBundle := libtrust.LoadCertificateBundle(certs.CARootPath)
+		require.NoError(t, errBundle)
+		return loaded
+	}
+
+	_, err = rt.StageKey()
+	require.NoError(t, err)
+	published := bundleCerts()
+	require.Len(t, published, 3)
+	assert.True(t, published[2].Equal(customBundle[0]), "custom certificate follows certificates of keys")
+
+	_, err = rt.SwitchKey()
+	require.NoError(t, err)
+	published = bundleCerts()
+	require.Len(t, published, 3)
+	assert.True(t, published[2].Equal(customBundle[0]))
+
+	// keys and the custom certificate are loaded again after restart
+	rt, err = NewRegistryToken(CertsName(certs))
+	require.NoError(t, err)
+	require.Len(t, rt.unmanagedCerts, 1)
+
+	_, err = rt.RetireKey()
+	require.NoError(t, err)
+	published = bundleCerts()
+	require.Len(t, published, 2)
+	assert.True(t, published[0].Equal(rt.caRoot))
+	assert.True(t, published[1].Equal(customBundle[0]))
+}
+
+func TestSlotPath(t *testing.T) {
+	assert.Equal(t, "/certs/registry_auth.staged.key", slotPath("/certs/registry_auth.key", stagedKeySlot))
+	assert.Equal(t, "/certs/auth.previous", slotPath("/certs/auth", previousKeySlot))
+}
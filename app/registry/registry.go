@@ -82,6 +82,12 @@ type Settings struct {
 	// Override default token expiration time (in seconds), default 60 seconds
 	TokenTTL int64
 
+	// Validity period of certificates which are generated for new signing keys of tokens, default one year
+	KeyValidity time.Duration
+
+	// Algorithm of new signing keys of tokens, default 'ecdsa-p256'
+	KeyAlgorithm string
+
 	// CertificatesPaths define a path to private, public keys and CA certificate.
 	// If CertificatesPaths has all fields are empty, AccessToken will create keys by default, with default path.
 	// If CertificatesPaths has all fields are empty, but certificates files exist AccessToken try to load existed keys and CA file.
@@ -211,11 +217,13 @@ func NewRegistry(login, password string, settings Settings) (*Registry, error) {
 		r.htpasswd = nil // not needed for token auth
 		var err error
 		if certsPathIsFilled {
-			if r.registryToken, err = NewRegistryToken(TokenIssuer(settings.Issuer), CertsName(settings.CertificatesPaths), TokenExpiration(settings.TokenTTL)); err != nil {
+			if r.registryToken, err = NewRegistryToken(TokenIssuer(settings.Issuer), CertsName(settings.CertificatesPaths),
+				TokenExpiration(settings.TokenTTL), TokenKeyValidity(settings.KeyValidity), TokenKeyAlgorithm(settings.KeyAlgorithm)); err != nil {
 				return nil, err
 			}
 		} else {
-			r.registryToken, err = NewRegistryToken(TokenIssuer(settings.Issuer), TokenExpiration(settings.TokenTTL))
+			r.registryToken, err = NewRegistryToken(TokenIssuer(settings.Issuer), TokenExpiration(settings.TokenTTL),
+				TokenKeyValidity(settings.KeyValidity), TokenKeyAlgorithm(settings.KeyAlgorithm))
 			if err != nil {
 				return nil, err
 			}
@@ -272,6 +280,38 @@ func (r *Registry) Token(authRequest TokenRequest) (string, error) {
 	return string(tokenBytes), nil
 }
 
+// SigningKeys returns keys of token signature which are published in the CA bundle
+func (r *Registry) SigningKeys() ([]SigningKey, error) {
+	if r.registryToken == nil {
+		return nil, ErrTokenAuthRequired
+	}
+	return r.registryToken.Keys(), nil
+}
+
+// StageSigningKey generates a new key of token signature and publishes it in the CA bundle
+func (r *Registry) StageSigningKey() (SigningKey, error) {
+	if r.registryToken == nil {
+		return SigningKey{}, ErrTokenAuthRequired
+	}
+	return r.registryToken.StageKey()
+}
+
+// SwitchSigningKey makes the staged key active for signing of new tokens
+func (r *Registry) SwitchSigningKey() (SigningKey, error) {
+	if r.registryToken == nil {
+		return SigningKey{}, ErrTokenAuthRequired
+	}
+	return r.registryToken.SwitchKey()
+}
+
+// RetireSigningKey removes the previous key of token signature from the CA bundle
+func (r *Registry) RetireSigningKey() (SigningKey, error) {
+	if r.registryToken == nil {
+		return SigningKey{}, ErrTokenAuthRequired
+	}
+	return r.registryToken.RetireKey()
+}
+
 // UpdateHtpasswd update user access list every time when user add/update/delete
 func (r *Registry) UpdateHtpasswd(usersFn FetchUsers) error {
 
@@ -609,14 +649,9 @@ func (r *Registry) ParseAuthenticateHeaderRequest(headerValue string) (authReque
 			authRequest.Service = value
 			isMatched = true
 		case "scope":
-			scope := strings.Split(value, ":")
-			if len(scope) != 3 {
-				return authRequest, fmt.Errorf("failed to parse scope value: %s", value)
+			if authRequest.Scopes, err = ParseScopes([]string{value}); err != nil {
+				return authRequest, err
 			}
-
-			authRequest.Type = scope[0]
-			authRequest.Name = scope[1]
-			authRequest.Actions = strings.Split(scope[2], ",")
 			isMatched = true
 		}
 
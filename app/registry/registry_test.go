@@ -277,19 +277,24 @@ func TestParseAuthenticateHeaderRequest(t *testing.T) {
 
 	expectedAuthRequest := TokenRequest{
 		Service: "registry.docker.io",
-		Type:    "repository",
-		Name:    "samalba/my-app",
-		Actions: []string{"pull", "push"},
+		Scopes:  []ResourceScope{{Type: "repository", Name: "samalba/my-app", Actions: []string{"pull", "push"}}},
 	}
 	assert.Equal(t, expectedAuthRequest, authRequest)
 
 	// test with wildcard action value
 	testRequestHeaderValue = `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:samalba/my-app:*"`
-	expectedAuthRequest.Actions = []string{"*"}
+	expectedAuthRequest.Scopes[0].Actions = []string{"*"}
 	authRequest, err = r.ParseAuthenticateHeaderRequest(testRequestHeaderValue)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedAuthRequest, authRequest)
 
+	// test with several scopes separated by space
+	testRequestHeaderValue = `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:samalba/my-app:pull repository:samalba/base:pull"`
+	authRequest, err = r.ParseAuthenticateHeaderRequest(testRequestHeaderValue)
+	assert.NoError(t, err)
+	require.Len(t, authRequest.Scopes, 2)
+	assert.Equal(t, "samalba/base", authRequest.Scopes[1].Name)
+
 	// test with error
 	testRequestHeaderValue = "fake_params"
 	_, err = r.ParseAuthenticateHeaderRequest(testRequestHeaderValue)
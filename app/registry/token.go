@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/distribution/registry/auth/token"
@@ -26,13 +27,13 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 
-	crand "crypto/rand"
 	log "github.com/go-pkgz/lgr"
 )
 
 const (
 	defaultTokenIssuer     = "127.0.0.1"
 	defaultTokenExpiration = 60
+	defaultKeyValidity     = 365 * 24 * time.Hour
 
 	// default names of generated certificate
 	certsDirName   = ".registry-certs"
@@ -58,8 +59,19 @@ type TokenRequest struct {
 	// the token to authorize the client/subject.
 	Service string
 
-	// The subject of the token; the name or id of the client which requested it.
-	// This should be empty (`""`) if the client did not authenticate.
+	// Resources and actions which the client requests, each scope is issued as a separate access entry of the token.
+	// It's empty for login requests.
+	Scopes []ResourceScope
+
+	// Custom TTL for a new token
+	ExpireTime int64
+}
+
+// ResourceScope is the access to a resource requested with a 'scope' parameter,
+// for detailed description go to https://docs.docker.com/registry/spec/auth/scope/
+type ResourceScope struct {
+
+	// The type of resource, e.g. 'repository' or 'registry'
 	Type string
 
 	// The name of the resource of the given type hosted by the service.
@@ -67,9 +79,51 @@ type TokenRequest struct {
 
 	// An array of strings which give the actions authorized on this resource.
 	Actions []string
+}
 
-	// Custom TTL for a new token
-	ExpireTime int64
+// ParseScopes parses values of 'scope' parameters with format 'type:name:actions', e.g. 'repository:samalba/my-app:pull,push'.
+// A value can contain several scopes separated by spaces, scopes of the same resource are merged into one.
+func ParseScopes(values []string) ([]ResourceScope, error) {
+	var scopes []ResourceScope
+	index := make(map[string]int)
+
+	for _, value := range values {
+		for _, field := range strings.Fields(value) {
+			parts := strings.Split(field, ":")
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("failed to parse scope value: %s", field)
+			}
+
+			key := parts[0] + ":" + parts[1]
+			i, ok := index[key]
+			if !ok {
+				i = len(scopes)
+				index[key] = i
+				scopes = append(scopes, ResourceScope{Type: parts[0], Name: parts[1]})
+			}
+
+			for _, action := range strings.Split(parts[2], ",") {
+				if !containsString(scopes[i].Actions, action) {
+					scopes[i].Actions = append(scopes[i].Actions, action)
+				}
+			}
+		}
+	}
+	return scopes, nil
+}
+
+// String returns the scope in format of 'scope' parameter
+func (s ResourceScope) String() string {
+	return s.Type + ":" + s.Name + ":" + strings.Join(s.Actions, ",")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // Certs will define a path to certs either for loading private, public and CARoot files or path to save ones when createCerts call.
@@ -92,6 +146,12 @@ type ClientToken struct {
 	// At least one of these fields must be specified, but both may also appear (for compatibility with older clients).
 	// When both are specified, they should be equivalent; if they differ the client's choice is undefined.
 	AccessToken string `json:"access_token"`
+
+	// The duration in seconds since the token was issued that it will remain valid.
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+
+	// The RFC3339-serialized UTC standard time at which a given token was issued.
+	IssuedAt string `json:"issued_at,omitempty"`
 }
 
 // AccessToken is a token instance using for authorization in registryal
@@ -104,11 +164,26 @@ type AccessToken struct {
 	// AccessToken life
 	tokenExpiration int64
 
+	// validity period of certificates which are generated for new keys
+	keyValidity time.Duration
+
+	// algorithm of new keys, existed keys are loaded with any supported algorithm
+	keyAlgorithm string
+
 	// certificates for generate JWT signature
 	privateKey libtrust.PrivateKey
 	publicKey  libtrust.PublicKey
 	caRoot     *x509.Certificate
 
+	// keys which are published in the CA bundle together with the active key while rotation is in progress
+	stagedKey   *signingKey
+	previousKey *signingKey
+	keysLock    sync.RWMutex
+
+	// certificates of the CA bundle which don't belong to signing keys (e.g. a custom CA chain),
+	// they are kept when the bundle is rewritten
+	unmanagedCerts []*x509.Certificate
+
 	l log.L
 }
 
@@ -132,6 +207,24 @@ func TokenIssuer(issuer string) TokenOption {
 	}
 }
 
+// TokenKeyValidity option define validity period of certificates which are generated for new keys
+func TokenKeyValidity(validity time.Duration) TokenOption {
+	return func(rt *AccessToken) {
+		if validity > 0 {
+			rt.keyValidity = validity
+		}
+	}
+}
+
+// TokenKeyAlgorithm option define algorithm of new signing keys, e.g. 'ecdsa-p256' or 'rsa-3072'
+func TokenKeyAlgorithm(algorithm string) TokenOption {
+	return func(rt *AccessToken) {
+		if algorithm != "" {
+			rt.keyAlgorithm = algorithm
+		}
+	}
+}
+
 // TokenLogger define logger instance
 func TokenLogger(l log.L) TokenOption {
 	return func(rt *AccessToken) {
@@ -158,6 +251,8 @@ func NewRegistryToken(opts ...TokenOption) (*AccessToken, error) {
 	rt := &AccessToken{
 		tokenExpiration: defaultTokenExpiration,
 		tokenIssuer:     defaultTokenIssuer,
+		keyValidity:     defaultKeyValidity,
+		keyAlgorithm:    defaultKeyAlgorithm,
 		l:               log.Default(),
 	}
 
@@ -188,6 +283,10 @@ func NewRegistryToken(opts ...TokenOption) (*AccessToken, error) {
 		return nil, errors.Errorf("token expiration time is invalid, should great more than one")
 	}
 
+	if err = checkKeyAlgorithm(rt.keyAlgorithm); err != nil {
+		return nil, err
+	}
+
 	if err = rt.loadCerts(); err != nil {
 
 		// throw error when certs files exist but load is fail, otherwise tries to generate new certs
@@ -205,9 +304,12 @@ func NewRegistryToken(opts ...TokenOption) (*AccessToken, error) {
 }
 
 func (rt *AccessToken) generate(tokenRequest *TokenRequest) (ClientToken, error) {
-	// sign any string to get the used signing Algorithm for the private key
-	_, algo, err := rt.privateKey.Sign(strings.NewReader(""), 0)
+	rt.keysLock.RLock()
+	privateKey, publicKey := rt.privateKey, rt.publicKey
+	rt.keysLock.RUnlock()
 
+	// the signing algorithm of token header should match the key
+	algo, err := signingAlgorithm(publicKey)
 	if err != nil {
 		return ClientToken{}, err
 	}
@@ -215,7 +317,7 @@ func (rt *AccessToken) generate(tokenRequest *TokenRequest) (ClientToken, error)
 	header := token.Header{
 		Type:       "JWT",
 		SigningAlg: algo,
-		KeyID:      rt.publicKey.KeyID(),
+		KeyID:      publicKey.KeyID(),
 	}
 
 	headerJSON, err := json.Marshal(header)
@@ -246,11 +348,13 @@ func (rt *AccessToken) generate(tokenRequest *TokenRequest) (ClientToken, error)
 		Access:     []*token.ResourceActions{},
 	}
 
-	claim.Access = append(claim.Access, &token.ResourceActions{
-		Type:    tokenRequest.Type,
-		Name:    tokenRequest.Name,
-		Actions: tokenRequest.Actions,
-	})
+	for _, scope := range tokenRequest.Scopes {
+		claim.Access = append(claim.Access, &token.ResourceActions{
+			Type:    scope.Type,
+			Name:    scope.Name,
+			Actions: scope.Actions,
+		})
+	}
 
 	claimJSON, err := json.Marshal(claim)
 	if err != nil {
@@ -262,33 +366,30 @@ func (rt *AccessToken) generate(tokenRequest *TokenRequest) (ClientToken, error)
 	}
 
 	payload := fmt.Sprintf("%s%s%s", encodeToBase64(headerJSON), token.TokenSeparator, encodeToBase64(claimJSON))
-	sig, sigAlgo, err := rt.privateKey.Sign(strings.NewReader(payload), 0)
-	if err != nil && sigAlgo != algo {
+	sig, sigAlgo, err := privateKey.Sign(strings.NewReader(payload), 0)
+	if err != nil {
 		return ClientToken{}, err
 	}
 
-	tokenString := fmt.Sprintf("%s%s%s", payload, token.TokenSeparator, encodeToBase64(sig))
-	return ClientToken{Token: tokenString, AccessToken: tokenString}, nil
-}
-
-func (rt *AccessToken) createCerts() (err error) {
-
-	rt.privateKey, err = libtrust.GenerateRSA2048PrivateKey()
-	if err != nil {
-		return err
+	if sigAlgo != algo {
+		return ClientToken{}, errors.Errorf("token signed with algorithm %s, but %s is expected", sigAlgo, algo)
 	}
 
-	rt.publicKey, err = libtrust.FromCryptoPublicKey(rt.privateKey.CryptoPublicKey())
-	if err != nil {
-		return err
-	}
+	tokenString := fmt.Sprintf("%s%s%s", payload, token.TokenSeparator, encodeToBase64(sig))
+	return ClientToken{
+		Token:       tokenString,
+		AccessToken: tokenString,
+		ExpiresIn:   expr - now,
+		IssuedAt:    time.Unix(now, 0).UTC().Format(time.RFC3339),
+	}, nil
+}
 
-	rt.caRoot, err = libtrust.GenerateCACert(rt.privateKey, rt.publicKey)
+func (rt *AccessToken) createCerts() error {
+	key, err := rt.newSigningKey()
 	if err != nil {
 		return err
 	}
-
-	rt.appendDSnToCertificate()
+	rt.privateKey, rt.publicKey, rt.caRoot = key.privateKey, key.publicKey, key.cert
 
 	return rt.saveKeys()
 }
@@ -325,7 +426,7 @@ func (rt *AccessToken) loadCerts() (err error) {
 		return errStat
 	}
 
-	rt.privateKey, err = libtrust.LoadKeyFile(rt.Certs.KeyPath)
+	rt.privateKey, err = loadPrivateKey(rt.Certs.KeyPath)
 	if err != nil {
 		return err
 	}
@@ -344,8 +445,22 @@ func (rt *AccessToken) loadCerts() (err error) {
 	if len(bundle) == 0 {
 		return errors.New("certificates bundle not found in CA file")
 	}
+
+	// the bundle of custom certificates can contain a CA certificate without the public key of token
 	rt.caRoot = bundle[0]
+	if cert := findKeyCertificate(bundle, rt.publicKey); cert != nil {
+		rt.caRoot = cert
+	}
+
+	if rt.stagedKey, err = rt.loadSlotKey(stagedKeySlot, bundle); err != nil {
+		return err
+	}
+
+	if rt.previousKey, err = rt.loadSlotKey(previousKeySlot, bundle); err != nil {
+		return err
+	}
 
+	rt.unmanagedCerts = rt.unmanagedBundleCerts(bundle)
 	return nil
 }
 
@@ -382,25 +497,26 @@ func (rt *AccessToken) saveKeys() error {
 		return errors.Wrap(err, "failed to save public key to file")
 	}
 
-	// generated keys hasn't Subject Alternative Name for requested IP and Domain when creating with libtrust
-	// and that should add this values after certificate created and extracting raw bytes after that
-	caBytes, err := x509.CreateCertificate(crand.Reader, rt.caRoot, rt.caRoot, rt.publicKey.CryptoPublicKey(), rt.privateKey.CryptoPrivateKey())
-	if err != nil {
-		return errors.Wrap(err, "failed to create certificate")
-	}
+	return rt.saveBundle()
+}
 
+// saveBundle writes certificates of all published keys to the CA bundle file, a certificate of the active key is the first,
+// certificates which don't belong to signing keys follow them
+func (rt *AccessToken) saveBundle() error {
 	certPEM := new(bytes.Buffer)
-	err = pem.Encode(certPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: caBytes,
-	})
+	for _, cert := range rt.bundleCerts() {
+		if err := pem.Encode(certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return errors.Wrap(err, "failed to encode certificate for file save")
+		}
+	}
 
-	if err != nil {
-		return errors.Wrap(err, "failed to encode certificate for file save")
+	// the registry can read the bundle at any moment and shouldn't get a partially written file
+	tmpPath := rt.CARootPath + ".tmp"
+	if err := os.WriteFile(tmpPath, certPEM.Bytes(), os.ModePerm); err != nil {
+		return errors.Wrap(err, "failed to save CA bundle to file")
 	}
 
-	err = os.WriteFile(rt.CARootPath, certPEM.Bytes(), os.ModePerm)
-	if err != nil {
+	if err := os.Rename(tmpPath, rt.CARootPath); err != nil {
 		return errors.Wrap(err, "failed to save CA bundle to file")
 	}
 	return nil
@@ -418,20 +534,20 @@ func (rt *AccessToken) parseToken(tokenString string) (ct ClientToken, err error
 // It's require for append Subject Alternative Name for requested IP and Domain to certificate
 // and prevents untrusted error with HTTPS client request
 // https://oidref.com/2.5.29.17
-func (rt *AccessToken) appendDSnToCertificate() {
+func (rt *AccessToken) appendDSnToCertificate(cert *x509.Certificate) {
 	if rt.Certs.IP != "" {
 		var ipAddressRegExp = regexp.MustCompile(`(?m)^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])$`)
 		if ipAddressRegExp.MatchString(rt.Certs.IP) {
-			rt.caRoot.IPAddresses = append(rt.caRoot.IPAddresses, net.ParseIP(rt.Certs.IP))
+			cert.IPAddresses = append(cert.IPAddresses, net.ParseIP(rt.Certs.IP))
 		} else {
 			rt.l.Logf("failed to append ip address to certificate SN, ip address is invalid")
 		}
 	}
 
-	rt.caRoot.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
-	rt.caRoot.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
+	cert.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
 
 	if len(rt.FQDNs) > 0 {
-		rt.caRoot.DNSNames = append(rt.caRoot.DNSNames, rt.FQDNs...)
+		cert.DNSNames = append(cert.DNSNames, rt.FQDNs...)
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewRegistryToken(t *testing.T) {
@@ -190,11 +191,12 @@ func TestRegistryToken_Generate(t *testing.T) {
 	require.NoError(t, err)
 
 	authReq := TokenRequest{
-		Account:    "Martian",
-		Service:    "127.0.0.1",
-		Type:       "registry",
-		Name:       "test-resource",
-		Actions:    []string{"pull", "push"},
+		Account: "Martian",
+		Service: "127.0.0.1",
+		Scopes: []ResourceScope{
+			{Type: "repository", Name: "test-resource", Actions: []string{"pull", "push"}},
+			{Type: "repository", Name: "test-base", Actions: []string{"pull"}},
+		},
 		ExpireTime: 120,
 	}
 
@@ -210,7 +212,34 @@ func TestRegistryToken_Generate(t *testing.T) {
 	assert.Equal(t, rt.tokenIssuer, claims["iss"])
 	assert.Equal(t, authReq.Account, claims["sub"])
 	assert.Equal(t, authReq.Service, claims["aud"])
+	assert.Equal(t, int64(120), jwtToken.ExpiresIn)
+	issuedAt, errTime := time.Parse(time.RFC3339, jwtToken.IssuedAt)
+	require.NoError(t, errTime)
+	assert.Equal(t, int64(claims["iat"].(float64)), issuedAt.Unix())
+
+	// each scope is a separate access entry
+	access, ok := claims["access"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, access, 2)
+	assert.Equal(t, map[string]interface{}{"type": "repository", "name": "test-base", "actions": []interface{}{"pull"}}, access[1])
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"repository:foo/bar:pull", "repository:foo/baz:pull,push repository:foo/bar:push,pull"})
+	require.NoError(t, err)
+	assert.Equal(t, []ResourceScope{
+		{Type: "repository", Name: "foo/bar", Actions: []string{"pull", "push"}},
+		{Type: "repository", Name: "foo/baz", Actions: []string{"pull", "push"}},
+	}, scopes)
+
+	assert.Equal(t, "repository:foo/bar:pull,push", scopes[0].String())
 
+	scopes, err = ParseScopes(nil)
+	require.NoError(t, err)
+	assert.Empty(t, scopes)
+
+	_, err = ParseScopes([]string{"repository:foo/bar:pull", "repository"})
+	assert.Error(t, err)
 }
 
 func TestRegistryToken_CreateCerts(t *testing.T) {
@@ -9,6 +9,7 @@ import (
 	"github.com/zebox/registry-admin/app/store/engine"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // accessHandlers implement controllers which allow manipulation with Access model using REST API endpoints
@@ -26,6 +27,11 @@ func (ah *accessHandlers) accessAddCtrl(w http.ResponseWriter, r *http.Request)
 	}
 	defer func() { _ = r.Body.Close() }()
 
+	if err = access.Validate(); err != nil {
+		SendErrorJSON(w, r, ah.l, http.StatusBadRequest, err, "invalid access data")
+		return
+	}
+
 	err = ah.dataStore.CreateAccess(r.Context(), &access)
 	if err != nil {
 		SendErrorJSON(w, r, ah.l, http.StatusInternalServerError, err, "failed add access with api")
@@ -65,9 +71,30 @@ func (ah *accessHandlers) accessFindCtrl(w http.ResponseWriter, r *http.Request)
 		SendErrorJSON(w, r, ah.l, http.StatusInternalServerError, err, "failed to parse URL parameters for make query filter")
 		return
 	}
+
+	// select enabled accesses which expire within the period, e.g. 'expires_in=72h', the earliest ones first
+	if expiresIn := r.URL.Query().Get("expires_in"); expiresIn != "" {
+		period, errPeriod := time.ParseDuration(expiresIn)
+		if errPeriod != nil || period <= 0 {
+			SendErrorJSON(w, r, ah.l, http.StatusBadRequest, errPeriod, "invalid period of access expiry")
+			return
+		}
+
+		if filter.Filters == nil {
+			filter.Filters = make(map[string]interface{})
+		}
+		filter.Filters[engine.AccessExpiresBefore] = time.Now().Add(period).Unix()
+		if _, ok := filter.Filters["disabled"]; !ok {
+			filter.Filters["disabled"] = false
+		}
+		if len(filter.Sort) == 0 {
+			filter.Sort = []string{"expires_at", "asc"}
+		}
+	}
+
 	result, err := ah.dataStore.FindAccesses(r.Context(), filter)
 	if err != nil {
-		SendErrorJSON(w, r, ah.l, http.StatusInternalServerError, err, "failed to find access")
+		SendErrorJSON(w, r, ah.l, queryErrorStatus(err), err, "failed to find access")
 		return
 	}
 	w.Header().Add("Content-Range", fmt.Sprintf("accesses %d-%d/%d", filter.Range[0], filter.Range[1], result.Total))
@@ -95,6 +122,11 @@ func (ah *accessHandlers) accessUpdateCtrl(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if err = access.Validate(); err != nil {
+		SendErrorJSON(w, r, ah.l, http.StatusBadRequest, err, "invalid access data")
+		return
+	}
+
 	if err = ah.dataStore.UpdateAccess(r.Context(), access); err != nil {
 		SendErrorJSON(w, r, ah.l, http.StatusInternalServerError, err, "failed to update access data with api")
 		return
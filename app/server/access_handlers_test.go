@@ -11,6 +11,7 @@ import (
 	"github.com/zebox/registry-admin/app/store/engine"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func Test_accessAddCtrl(t *testing.T) {
@@ -20,9 +21,9 @@ func Test_accessAddCtrl(t *testing.T) {
 	access := store.Access{
 		Name:         "test_access_1",
 		Owner:        1,
-		Type:         "registry",
+		Type:         "repository",
 		ResourceName: "test_resource",
-		Action:       "pull:push",
+		Action:       "pull",
 	}
 
 	{
@@ -54,6 +55,30 @@ func Test_accessAddCtrl(t *testing.T) {
 		// try request with body unmarshalling error
 		request(t, "POST", "/api/v1/access", testAccessHandlers.accessAddCtrl, nil, http.StatusInternalServerError)
 
+		// patterns of resource names may contain repository name characters and wildcards only
+		access.ID = 0
+		access.ResourceName = "test_(resource|other)/*"
+		accessData, err = json.Marshal(access)
+		require.NoError(t, err)
+		request(t, "POST", "/api/v1/access", testAccessHandlers.accessAddCtrl, accessData, http.StatusBadRequest)
+
+		// actions and resource names are validated against resource type
+		invalid := []store.Access{
+			{Name: "unknown action", Owner: 1, Type: "repository", ResourceName: "test_resource", Action: "pull:push"},
+			{Name: "unknown type", Owner: 1, Type: "*", ResourceName: "test_resource", Action: "pull"},
+			{Name: "registry resource", Owner: 1, Type: "registry", ResourceName: "test_resource", Action: "*"},
+			{Name: "registry action", Owner: 1, Type: "registry", ResourceName: "catalog", Action: "pull"},
+		}
+		for _, v := range invalid {
+			accessData, err = json.Marshal(v)
+			require.NoError(t, err)
+			request(t, "POST", "/api/v1/access", testAccessHandlers.accessAddCtrl, accessData, http.StatusBadRequest)
+		}
+
+		catalog := store.Access{Name: "catalog", Owner: 1, Type: "registry", ResourceName: "catalog", Action: "*"}
+		accessData, err = json.Marshal(catalog)
+		require.NoError(t, err)
+		request(t, "POST", "/api/v1/access", testAccessHandlers.accessAddCtrl, accessData, http.StatusOK)
 	}
 }
 
@@ -64,9 +89,9 @@ func Test_accessInfoCtrl(t *testing.T) {
 	access := store.Access{
 		Name:         "test_access_1",
 		Owner:        1,
-		Type:         "registry",
+		Type:         "repository",
 		ResourceName: "test_resource",
-		Action:       "pull:push",
+		Action:       "pull",
 	}
 
 	accessData, err := json.Marshal(access)
@@ -94,23 +119,23 @@ func Test_accessFindCtrl(t *testing.T) {
 		{
 			Name:         "test_access_1",
 			Owner:        1,
-			Type:         "registry",
+			Type:         "repository",
 			ResourceName: "test_resource_1",
-			Action:       "pull:push",
+			Action:       "pull",
 		},
 		{
 			Name:         "test_access_2",
 			Owner:        1,
-			Type:         "registry",
+			Type:         "repository",
 			ResourceName: "test_resource_2",
 			Action:       "push",
 		},
 		{
 			Name:         "test_access_3",
 			Owner:        1,
-			Type:         "*",
-			ResourceName: "test_resource_3",
-			Action:       "pull",
+			Type:         "registry",
+			ResourceName: "catalog",
+			Action:       "*",
 		},
 	}
 	for _, a := range accesses {
@@ -137,6 +162,31 @@ func Test_accessFindCtrl(t *testing.T) {
 
 	request(t, "GET", `/api/v1/access?filter={"ids":[a,b]}`, testAccessHandlers.accessFindCtrl, nil, http.StatusInternalServerError)
 	request(t, "GET", `/api/v1/access?filter={"ids":[4]}`, testAccessHandlers.accessFindCtrl, nil, http.StatusInternalServerError)
+
+	// accesses which expire soon
+	expiring := store.Access{Name: "test_access_4", Owner: 1, Type: "repository", ResourceName: "test_resource_1",
+		Action: "push", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	accessData, err := json.Marshal(expiring)
+	require.NoError(t, err)
+	request(t, "POST", "/api/v1/access", testAccessHandlers.accessAddCtrl, accessData, http.StatusOK)
+	{
+		testWriter := request(t, "GET", `/api/v1/access?expires_in=72h`, testAccessHandlers.accessFindCtrl, nil, http.StatusOK)
+		var responseList engine.ListResponse
+		err = json.NewDecoder(testWriter.Body).Decode(&responseList)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), responseList.Total)
+		assert.Equal(t, "test_access_4", responseList.Data[0].(map[string]interface{})["name"])
+	}
+	{
+		testWriter := request(t, "GET", `/api/v1/access?expires_in=30m`, testAccessHandlers.accessFindCtrl, nil, http.StatusOK)
+		var responseList engine.ListResponse
+		err = json.NewDecoder(testWriter.Body).Decode(&responseList)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), responseList.Total)
+	}
+
+	request(t, "GET", `/api/v1/access?expires_in=soon`, testAccessHandlers.accessFindCtrl, nil, http.StatusBadRequest)
+	request(t, "GET", `/api/v1/access?expires_in=-1h`, testAccessHandlers.accessFindCtrl, nil, http.StatusBadRequest)
 }
 
 func Test_accessUpdateCtrl(t *testing.T) {
@@ -146,9 +196,9 @@ func Test_accessUpdateCtrl(t *testing.T) {
 	access := store.Access{
 		Name:         "test_access_1",
 		Owner:        1,
-		Type:         "registry",
+		Type:         "repository",
 		ResourceName: "test_resource",
-		Action:       "pull:push",
+		Action:       "pull",
 	}
 
 	// create access first
@@ -211,9 +261,9 @@ func Test_accessDeleteCtrl(t *testing.T) {
 	access := store.Access{
 		Name:         "test_access_1",
 		Owner:        1,
-		Type:         "registry",
+		Type:         "repository",
 		ResourceName: "test_resource",
-		Action:       "pull:push",
+		Action:       "pull",
 	}
 
 	// get existed access info by id
@@ -239,6 +289,7 @@ func prepareAccessMock() engine.Interface {
 	var testAccessIndex int64
 
 	return &engine.InterfaceMock{
+		GetRoleFunc: getBuiltInRole,
 		CreateAccessFunc: func(ctx context.Context, access *store.Access) error {
 
 			if _, ok := testAccessStorage[access.ID]; ok {
@@ -260,6 +311,17 @@ func prepareAccessMock() engine.Interface {
 		FindAccessesFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
 			testListResponse := engine.ListResponse{}
 
+			// fetch enabled accesses which expire before the time
+			if expiresBefore, ok := filter.Filters[engine.AccessExpiresBefore]; ok {
+				for _, v := range testAccessStorage {
+					if !v.Disabled && v.ExpiresAt > 0 && v.ExpiresAt <= expiresBefore.(int64) {
+						testListResponse.Total++
+						testListResponse.Data = append(testListResponse.Data, v)
+					}
+				}
+				return testListResponse, nil
+			}
+
 			// fetch by ids
 			if filter.Filters != nil {
 				if val, ok := filter.Filters["ids"]; ok {
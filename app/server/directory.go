@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+
+	"github.com/zebox/registry-admin/app/ldap"
+	"github.com/zebox/registry-admin/app/store"
+)
+
+// directoryAuthenticator checks credentials of users with an external directory, e.g. LDAP, and returns the store user
+type directoryAuthenticator interface {
+	Authenticate(ctx context.Context, login, password string) (store.User, error)
+}
+
+// directoryUser checks credentials with the directory. When the directory doesn't know the login, the login belongs to
+// a local user or the directory unavailable the found value is false and the credentials should be checked with the store. An error with found user means access denied.
+func directoryUser(ctx context.Context, directory directoryAuthenticator, login, password string) (user store.User, found bool, err error) {
+	if directory == nil {
+		return user, false, nil
+	}
+
+	user, err = directory.Authenticate(ctx, login, password)
+	switch {
+	case err == nil:
+		return user, true, nil
+	case errors.Is(err, ldap.ErrUserNotFound), errors.Is(err, ldap.ErrLocalUser):
+		return user, false, nil
+	case errors.Is(err, ldap.ErrInvalidCredentials), errors.Is(err, ldap.ErrUserDisabled):
+		return user, true, err
+	}
+
+	// directory users have random store passwords, so only local users are able to login when directory unavailable
+	log.Printf("[WARN] failed to check credentials of user %q with directory: %v", login, err)
+	return store.User{}, false, nil
+}
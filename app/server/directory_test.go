@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zebox/registry-admin/app/ldap"
+	"github.com/zebox/registry-admin/app/store"
+)
+
+// testDirectory knows logins with 'ldap_' prefix, 'ldap_disabled' is disabled, 'ldap_local' belongs to a local user
+// and 'ldap_down' fails as unreachable directory
+type testDirectory struct{}
+
+func (testDirectory) Authenticate(_ context.Context, login, password string) (store.User, error) {
+	switch login {
+	case "ldap_down":
+		return store.User{}, errors.New("failed to connect to directory")
+	case "ldap_disabled":
+		return store.User{}, ldap.ErrUserDisabled
+	case "ldap_local":
+		return store.User{}, ldap.ErrLocalUser
+	case "ldap_blocked":
+		return store.User{ID: 102, Login: login, Name: login, Role: store.UserRole, Disabled: true}, nil
+	case "ldap_user":
+		if password != "ldap_password" {
+			return store.User{}, ldap.ErrInvalidCredentials
+		}
+		return store.User{ID: 101, Login: login, Name: "LDAP User", Role: store.UserRole}, nil
+	}
+	return store.User{}, ldap.ErrUserNotFound
+}
+
+func TestDirectoryUser(t *testing.T) {
+	ctx := context.Background()
+
+	_, found, err := directoryUser(ctx, nil, "ldap_user", "ldap_password")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	user, found, err := directoryUser(ctx, testDirectory{}, "ldap_user", "ldap_password")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(101), user.ID)
+
+	_, found, err = directoryUser(ctx, testDirectory{}, "ldap_user", "bad_password")
+	assert.ErrorIs(t, err, ldap.ErrInvalidCredentials)
+	assert.True(t, found)
+
+	_, found, err = directoryUser(ctx, testDirectory{}, "ldap_disabled", "any")
+	assert.ErrorIs(t, err, ldap.ErrUserDisabled)
+	assert.True(t, found)
+
+	// unknown logins and unavailable directory fall back to the store
+	_, found, err = directoryUser(ctx, testDirectory{}, "test_user", "test_password")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, found, err = directoryUser(ctx, testDirectory{}, "ldap_down", "any")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// a local user with a login of directory account is checked with the store password only
+	_, found, err = directoryUser(ctx, testDirectory{}, "ldap_local", "any")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestServer_BasicAuthCheckerFnWithDirectory(t *testing.T) {
+	srv := Server{Storage: prepareTestStorage(t), Directory: testDirectory{}, ctx: context.Background()}
+
+	ok, claim, err := srv.BasicAuthCheckerFn("ldap_user", "ldap_password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, strconv.FormatInt(101, 10), claim.ID)
+	assert.Equal(t, "LDAP User", claim.Name)
+	assert.Equal(t, store.UserRole, claim.Role)
+
+	ok, _, err = srv.BasicAuthCheckerFn("ldap_user", "bad_password")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, _, err = srv.BasicAuthCheckerFn("ldap_disabled", "any")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, _, err = srv.BasicAuthCheckerFn("ldap_blocked", "any")
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	// local users are checked with the store
+	ok, _, err = srv.BasicAuthCheckerFn("test_user", "test_password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _, err = srv.BasicAuthCheckerFn("test_user", "bad_password")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRegistryHandlers_tokenAuthWithDirectory(t *testing.T) {
+	registryMock := prepareRegistryMock(t)
+	registryMock.LoginFunc = func(user store.User) (string, error) { return "ok", nil }
+
+	rh := registryHandlers{registryService: registryMock, directory: testDirectory{}}
+	rh.l = log.Default()
+	rh.dataStore = prepareTestStorage(t)
+
+	ctx := context.Background()
+	query := "/api/v1/registry/auth?client_id=docker&offline_token=true&service=container_registry&account="
+
+	requestWithCredentials(ctx, t, "ldap_user", "ldap_password", "GET", query+"ldap_user", rh.tokenAuth, nil, http.StatusOK)
+	calls := registryMock.LoginCalls()
+	if assert.NotEmpty(t, calls) {
+		assert.Equal(t, int64(101), calls[len(calls)-1].User.ID)
+	}
+
+	requestWithCredentials(ctx, t, "ldap_user", "bad_password", "GET", query+"ldap_user", rh.tokenAuth, nil, http.StatusForbidden)
+	requestWithCredentials(ctx, t, "ldap_disabled", "any", "GET", query+"ldap_disabled", rh.tokenAuth, nil, http.StatusForbidden)
+	requestWithCredentials(ctx, t, "ldap_blocked", "any", "GET", query+"ldap_blocked", rh.tokenAuth, nil, http.StatusForbidden)
+
+	// directory error doesn't grant access, the login is checked with the store
+	requestWithCredentials(ctx, t, "ldap_down", "any", "GET", query+"ldap_down", rh.tokenAuth, nil, http.StatusForbidden)
+
+	// a user which isn't known by directory is checked with the store password
+	requestWithCredentials(ctx, t, "test_user", "bad_password", "GET", query+"test_user", rh.tokenAuth, nil, http.StatusForbidden)
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	R "github.com/go-pkgz/rest"
+	"github.com/pkg/errors"
 	"github.com/zebox/registry-admin/app/store"
 	"github.com/zebox/registry-admin/app/store/engine"
 	"net/http"
@@ -68,7 +70,7 @@ func (g *groupHandlers) groupFindCtrl(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := g.dataStore.FindGroups(r.Context(), filter)
 	if err != nil {
-		SendErrorJSON(w, r, g.l, http.StatusInternalServerError, err, "failed to find groups")
+		SendErrorJSON(w, r, g.l, queryErrorStatus(err), err, "failed to find groups")
 		return
 	}
 
@@ -117,10 +119,130 @@ func (g *groupHandlers) groupDeleteCtrl(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := g.dataStore.DeleteGroup(r.Context(), id); err != nil {
-		SendErrorJSON(w, r, g.l, http.StatusInternalServerError, err, "failed to deleteDigest group with api")
+	// group and its accesses delete together, otherwise access records of deleted group apply to a group with the same id
+	errMsg := "failed to deleteDigest group with api"
+	err = g.dataStore.WithTx(r.Context(), func(ctx context.Context) error {
+		if errDelete := g.dataStore.DeleteGroup(ctx, id); errDelete != nil {
+			return errDelete
+		}
+
+		errMsg = fmt.Sprintf("failed to delete accesses for deleted group with id - %d", id)
+		return g.dataStore.DeleteOwnerAccesses(ctx, id, true)
+	})
+	if err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusInternalServerError, err, errMsg)
 		return
 	}
 
 	R.RenderJSON(w, responseMessage{Message: "group deleted"})
 }
+
+// groupMembersFindCtrl returns users which are members of the group, the list supports the same filters,
+// sorting and pagination as the users list
+func (g *groupHandlers) groupMembersFindCtrl(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusBadRequest, err, "failed to parse group id with api")
+		return
+	}
+
+	filter, err := engine.FilterFromURLExtractor(r.URL)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusInternalServerError, err, "failed to parse URL parameters for make query filter")
+		return
+	}
+
+	members, err := g.dataStore.FindGroupMembers(r.Context(), engine.QueryFilter{Filters: map[string]interface{}{"group_id": groupID}})
+	if err != nil {
+		SendErrorJSON(w, r, g.l, storeErrorStatus(err), err, "failed to find group members")
+		return
+	}
+
+	ids := make([]interface{}, 0, len(members.Data))
+	for _, v := range members.Data {
+		ids = append(ids, v.(store.GroupMember).UserID)
+	}
+	if filter.Filters == nil {
+		filter.Filters = make(map[string]interface{})
+	}
+	filter.Filters["ids"] = ids
+
+	result, err := g.dataStore.FindUsers(r.Context(), filter, false)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, queryErrorStatus(err), err, "failed to find group members")
+		return
+	}
+
+	w.Header().Add("Content-Range", fmt.Sprintf("users %d-%d/%d", filter.Range[0], filter.Range[1], result.Total))
+	R.RenderJSON(w, result)
+}
+
+// groupMemberAddCtrl adds a user which ID passed with request body to the group
+func (g *groupHandlers) groupMemberAddCtrl(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusBadRequest, err, "failed to parse group id with api")
+		return
+	}
+
+	member := store.GroupMember{}
+	if err = json.NewDecoder(r.Body).Decode(&member); err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusBadRequest, err, "failed to parse group member data with api")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+	member.GroupID = groupID
+
+	if _, err = g.dataStore.GetGroup(r.Context(), member.GroupID); err != nil {
+		SendErrorJSON(w, r, g.l, storeErrorStatus(err), err, "failed to get group with api")
+		return
+	}
+
+	if _, err = g.dataStore.GetUser(r.Context(), member.UserID); err != nil {
+		SendErrorJSON(w, r, g.l, storeErrorStatus(err), err, "failed to get user with api")
+		return
+	}
+
+	if err = g.dataStore.AddGroupMember(r.Context(), member); err != nil {
+		SendErrorJSON(w, r, g.l, storeErrorStatus(err), err, "failed to add group member with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "group member added", ID: member.GroupID, Data: member})
+}
+
+// groupMemberDeleteCtrl removes a user from the group, a user can't be removed from the primary group
+// while the user data refers to it
+func (g *groupHandlers) groupMemberDeleteCtrl(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusBadRequest, err, "failed to parse group id with api")
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "user_id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, http.StatusBadRequest, err, "failed to parse user id with api")
+		return
+	}
+
+	user, err := g.dataStore.GetUser(r.Context(), userID)
+	if err != nil {
+		SendErrorJSON(w, r, g.l, storeErrorStatus(err), err, "failed to get user with api")
+		return
+	}
+
+	if user.Group == groupID {
+		SendErrorJSON(w, r, g.l, http.StatusConflict, errors.New("group is the primary group of user"),
+			"change the user group before remove the user from it")
+		return
+	}
+
+	member := store.GroupMember{GroupID: groupID, UserID: userID}
+	if err = g.dataStore.DeleteGroupMember(r.Context(), member); err != nil {
+		SendErrorJSON(w, r, g.l, storeErrorStatus(err), err, "failed to delete group member with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "group member deleted", ID: member.GroupID, Data: member})
+}
@@ -380,6 +380,12 @@ func Test_groupDeleteCtrl(t *testing.T) {
 		handler.ServeHTTP(testWriter, req)
 		assert.Equal(t, http.StatusOK, testWriter.Code)
 
+		// rules of the deleted group are deleted with it
+		calls := testGroupHandlers.dataStore.(*engine.InterfaceMock).DeleteOwnerAccessesCalls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, int64(2), calls[0].OwnerID)
+		assert.True(t, calls[0].IsGroup)
+
 		// try to deleteDigest not existed group
 		req, errReq = http.NewRequest("DELETE", "/api/v1/groups/2", http.NoBody)
 		require.NoError(t, errReq)
@@ -407,6 +413,84 @@ func Test_groupDeleteCtrl(t *testing.T) {
 
 }
 
+func Test_groupMembersCtrl(t *testing.T) {
+	testGroupHandlers := groupHandlers{}
+	testGroupHandlers.dataStore = prepareGroupMock()
+
+	memberRequest := func(method, url string, body []byte, params map[string]string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req, errReq := http.NewRequest(method, url, bytes.NewBuffer(body))
+		require.NoError(t, errReq)
+
+		rctx := chi.NewRouteContext()
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		for k, v := range params {
+			rctx.URLParams.Add(k, v)
+		}
+
+		testWriter := httptest.NewRecorder()
+		handler.ServeHTTP(testWriter, req)
+		return testWriter
+	}
+
+	{
+		// add the second user to the default group which isn't the primary one for the user
+		memberData, err := json.Marshal(store.GroupMember{UserID: 2})
+		require.NoError(t, err)
+		testWriter := memberRequest("POST", "/api/v1/groups/1/members", memberData, map[string]string{"id": "1"}, testGroupHandlers.groupMemberAddCtrl)
+		assert.Equal(t, http.StatusOK, testWriter.Code)
+
+		testResponse := responseMessage{}
+		require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&testResponse))
+		assert.Equal(t, "group member added", testResponse.Message)
+		assert.Equal(t, map[string]interface{}{"group_id": float64(1), "user_id": float64(2)}, testResponse.Data)
+
+		// the same membership can't be added twice
+		testWriter = memberRequest("POST", "/api/v1/groups/1/members", memberData, map[string]string{"id": "1"}, testGroupHandlers.groupMemberAddCtrl)
+		assert.Equal(t, http.StatusConflict, testWriter.Code)
+
+		// user should exist
+		memberData, err = json.Marshal(store.GroupMember{UserID: 100})
+		require.NoError(t, err)
+		testWriter = memberRequest("POST", "/api/v1/groups/1/members", memberData, map[string]string{"id": "1"}, testGroupHandlers.groupMemberAddCtrl)
+		assert.Equal(t, http.StatusNotFound, testWriter.Code)
+
+		testWriter = memberRequest("POST", "/api/v1/groups/1/members", []byte("{bad json"), map[string]string{"id": "1"}, testGroupHandlers.groupMemberAddCtrl)
+		assert.Equal(t, http.StatusBadRequest, testWriter.Code)
+
+		testWriter = memberRequest("POST", "/api/v1/groups/abc/members", memberData, map[string]string{"id": "abc"}, testGroupHandlers.groupMemberAddCtrl)
+		assert.Equal(t, http.StatusBadRequest, testWriter.Code)
+	}
+	{
+		// both users are members of the default group now
+		testWriter := memberRequest("GET", "/api/v1/groups/1/members", nil, map[string]string{"id": "1"}, testGroupHandlers.groupMembersFindCtrl)
+		assert.Equal(t, http.StatusOK, testWriter.Code)
+
+		testResponse := engine.ListResponse{}
+		require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&testResponse))
+		assert.Equal(t, int64(2), testResponse.Total)
+
+		testWriter = memberRequest("GET", "/api/v1/groups/abc/members", nil, map[string]string{"id": "abc"}, testGroupHandlers.groupMembersFindCtrl)
+		assert.Equal(t, http.StatusBadRequest, testWriter.Code)
+	}
+	{
+		// user can't be removed from the primary group
+		params := map[string]string{"id": "1", "user_id": "1"}
+		testWriter := memberRequest("DELETE", "/api/v1/groups/1/members/1", nil, params, testGroupHandlers.groupMemberDeleteCtrl)
+		assert.Equal(t, http.StatusConflict, testWriter.Code)
+
+		params["user_id"] = "2"
+		testWriter = memberRequest("DELETE", "/api/v1/groups/1/members/2", nil, params, testGroupHandlers.groupMemberDeleteCtrl)
+		assert.Equal(t, http.StatusOK, testWriter.Code)
+
+		testWriter = memberRequest("DELETE", "/api/v1/groups/1/members/2", nil, params, testGroupHandlers.groupMemberDeleteCtrl)
+		assert.Equal(t, http.StatusNotFound, testWriter.Code)
+
+		params["user_id"] = "abc"
+		testWriter = memberRequest("DELETE", "/api/v1/groups/1/members/abc", nil, params, testGroupHandlers.groupMemberDeleteCtrl)
+		assert.Equal(t, http.StatusBadRequest, testWriter.Code)
+	}
+}
+
 func prepareGroupMock() engine.Interface {
 	testGroupStorage := make(map[int64]store.Group)
 
@@ -420,7 +504,15 @@ func prepareGroupMock() engine.Interface {
 		Description: "test default group description",
 	}
 
+	// the first user has the default group as primary, the second one has another primary group
+	testGroupUsers := map[int64]store.User{
+		1: {ID: 1, Login: "test_member_1", Group: 1},
+		2: {ID: 2, Login: "test_member_2", Group: 10},
+	}
+	testGroupMembers := []store.GroupMember{{GroupID: 1, UserID: 1}, {GroupID: 10, UserID: 2}}
+
 	return &engine.InterfaceMock{
+		GetRoleFunc: getBuiltInRole,
 		CreateGroupFunc: func(ctx context.Context, group *store.Group) error {
 			if group.ID != 0 {
 				return fmt.Errorf("group with id [%d] not allowed for create", group.ID)
@@ -491,5 +583,65 @@ func prepareGroupMock() engine.Interface {
 			delete(testGroupStorage, id)
 			return nil
 		},
+
+		DeleteOwnerAccessesFunc: func(ctx context.Context, ownerID int64, isGroup bool) error {
+			if !isGroup {
+				return errors.New("rules of a user deleted instead of rules of the group")
+			}
+			return nil
+		},
+
+		WithTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		},
+
+		GetUserFunc: func(ctx context.Context, id interface{}) (store.User, error) {
+			if user, ok := testGroupUsers[id.(int64)]; ok {
+				return user, nil
+			}
+			return store.User{}, engine.ErrNotFound
+		},
+
+		FindUsersFunc: func(ctx context.Context, filter engine.QueryFilter, withPassword bool) (engine.ListResponse, error) {
+			testListResponse := engine.ListResponse{}
+			for _, id := range filter.Filters["ids"].([]interface{}) {
+				if user, ok := testGroupUsers[id.(int64)]; ok {
+					testListResponse.Total++
+					testListResponse.Data = append(testListResponse.Data, user)
+				}
+			}
+			return testListResponse, nil
+		},
+
+		AddGroupMemberFunc: func(ctx context.Context, member store.GroupMember) error {
+			for _, m := range testGroupMembers {
+				if m == member {
+					return engine.ErrDuplicateEntry
+				}
+			}
+			testGroupMembers = append(testGroupMembers, member)
+			return nil
+		},
+
+		FindGroupMembersFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+			testListResponse := engine.ListResponse{}
+			for _, m := range testGroupMembers {
+				if m.GroupID == filter.Filters["group_id"].(int64) {
+					testListResponse.Total++
+					testListResponse.Data = append(testListResponse.Data, m)
+				}
+			}
+			return testListResponse, nil
+		},
+
+		DeleteGroupMemberFunc: func(ctx context.Context, member store.GroupMember) error {
+			for i, m := range testGroupMembers {
+				if m == member {
+					testGroupMembers = append(testGroupMembers[:i], testGroupMembers[i+1:]...)
+					return nil
+				}
+			}
+			return engine.ErrNotFound
+		},
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"github.com/go-pkgz/rest/logger"
 	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store/engine"
 	"net/http"
 	"net/url"
 	"runtime"
@@ -27,6 +28,28 @@ func SendErrorJSON(w http.ResponseWriter, r *http.Request, l logger.Backend, cod
 	renderJSONWithStatus(w, errorResponse, code)
 }
 
+// queryErrorStatus returns http status code for an error of storage query,
+// query which refers to not allowed fields is a client error
+func queryErrorStatus(err error) int {
+	var keyErr *engine.InvalidKeyError
+	if errors.As(err, &keyErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// storeErrorStatus returns http status code for an error of storage call, a missed record and a record
+// which already exists are client errors as well as a query to not allowed fields
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, engine.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, engine.ErrDuplicateEntry):
+		return http.StatusConflict
+	}
+	return queryErrorStatus(err)
+}
+
 // renderJSONWithStatus sends data as json and enforces status code
 func renderJSONWithStatus(w http.ResponseWriter, data interface{}, code int) {
 	buf := &bytes.Buffer{}
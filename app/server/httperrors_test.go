@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store/engine"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -52,7 +53,7 @@ func TestErrorDetailsMsg(t *testing.T) {
 		msg := errDetailsMsg(req, 500, errors.New("error 500"), "error details 123456")
 		assert.Contains(t, msg, "error details 123456 - error 500 - 500 - 1.2.3.4 - https://example."+
 			"com/test?k1=v1&k2=v2 [caused by")
-		assert.Contains(t, msg, "app/server/httperrors_test.go:58 server.TestErrorDetailsMsg]", msg)
+		assert.Contains(t, msg, "app/server/httperrors_test.go:59 server.TestErrorDetailsMsg]", msg)
 
 	}
 	callerFn()
@@ -65,7 +66,20 @@ func TestErrorDetailsMsgNoError(t *testing.T) {
 		req.RemoteAddr = "1.2.3.4"
 		msg := errDetailsMsg(req, 500, nil, "error details 123456")
 		assert.Contains(t, msg, "error details 123456 - no error - 500 - 1.2.3.4 - https://example.com/test?k1=v1&k2=v2 [caused by")
-		assert.Contains(t, msg, "app/server/httperrors_test.go:70 server.TestErrorDetailsMsgNoError]", msg)
+		assert.Contains(t, msg, "app/server/httperrors_test.go:71 server.TestErrorDetailsMsgNoError]", msg)
 	}
 	callerFn()
 }
+
+func TestQueryErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, queryErrorStatus(&engine.InvalidKeyError{Key: "foo"}))
+	assert.Equal(t, http.StatusBadRequest, queryErrorStatus(errors.Wrap(&engine.InvalidKeyError{Key: "foo"}, "failed")))
+	assert.Equal(t, http.StatusInternalServerError, queryErrorStatus(errors.New("error 500")))
+}
+
+func TestStoreErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, storeErrorStatus(fmt.Errorf("group %w", engine.ErrNotFound)))
+	assert.Equal(t, http.StatusConflict, storeErrorStatus(errors.Wrap(engine.ErrDuplicateEntry, "failed")))
+	assert.Equal(t, http.StatusBadRequest, storeErrorStatus(&engine.InvalidKeyError{Key: "foo"}))
+	assert.Equal(t, http.StatusInternalServerError, storeErrorStatus(errors.New("error 500")))
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// maxLockoutEntries limits memory of attempts tracking of logins and of addresses each, entries which don't affect
+// locks are purged and the oldest unlocked ones are evicted above it
+const maxLockoutEntries = 10000
+
+// LockoutPolicy defines thresholds of failed login attempts. Every next lock of the same login or address is twice
+// longer than the previous one until a successful login.
+type LockoutPolicy struct {
+	MaxAttempts   int           // failed attempts of a login before lock, tracking of logins is disabled when zero
+	MaxIPAttempts int           // failed attempts from an address before lock, tracking of addresses is disabled when zero
+	Window        time.Duration // failed attempts are counted within the window since the first of them
+	Duration      time.Duration // duration of the first lock
+	MaxDuration   time.Duration // limit of lock duration
+}
+
+// lockStatus describes lock of a login which is exposed with users API
+type lockStatus struct {
+	Locked   bool       `json:"locked"`
+	Until    *time.Time `json:"until,omitempty"`
+	Failures int        `json:"failures"` // failed attempts since the last lock or successful login
+}
+
+// attempts holds failed attempts of a login or an address
+type attempts struct {
+	failures    int
+	first       time.Time // time of the first counted failure
+	lockedUntil time.Time
+	locks       int // number of locks in a row, it defines duration of the next lock
+}
+
+// loginGuard tracks failed login attempts by login and source address and denies logins when one of them is locked.
+// Attempts are kept in memory, so locks are reset on restart. A nil guard allows all logins.
+type loginGuard struct {
+	policy     LockoutPolicy
+	lock       sync.Mutex
+	logins     map[string]*attempts
+	ips        map[string]*attempts
+	maxEntries int // limit of entries of logins and of addresses each
+}
+
+// loginFailureKey is a context key of the flag which handlers set with loginFailed
+type loginFailureKey struct{}
+
+func newLoginGuard(policy LockoutPolicy) *loginGuard {
+	if policy.MaxAttempts <= 0 && policy.MaxIPAttempts <= 0 {
+		return nil
+	}
+	return &loginGuard{policy: policy, logins: make(map[string]*attempts), ips: make(map[string]*attempts),
+		maxEntries: maxLockoutEntries}
+}
+
+// loginFailed marks the request as a failed login for the guard middleware, it's used by handlers which respond
+// with the same status to wrong credentials and to other denials, e.g. to a scope which isn't allowed
+func loginFailed(r *http.Request) {
+	if failed, ok := r.Context().Value(loginFailureKey{}).(*bool); ok {
+		*failed = true
+	}
+}
+
+// middleware denies requests with credentials of locked login or from locked address and counts responses with
+// failStatus as failed attempts, only requests which the handler marks with loginFailed are counted when failStatus
+// is zero. The login function returns login of request credentials, empty when request hasn't them.
+func (g *loginGuard) middleware(failStatus int, login func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if g == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := login(r)
+			if name == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := clientIP(r)
+			if wait := g.check(name, ip, time.Now()); wait > 0 {
+				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(wait.Seconds())))
+				SendErrorJSON(w, r, nil, http.StatusTooManyRequests, errors.New("login temporarily locked"),
+					"too many failed login attempts")
+				return
+			}
+
+			failed := false
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), loginFailureKey{}, &failed)))
+
+			switch status := ww.Status(); {
+			case failed, failStatus != 0 && status == failStatus:
+				g.fail(name, ip, time.Now())
+			case status < http.StatusBadRequest:
+				g.succeed(name)
+			}
+		})
+	}
+}
+
+// check returns remaining duration of lock of the login or the address
+func (g *loginGuard) check(login, ip string, now time.Time) time.Duration {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var wait time.Duration
+	for _, a := range []*attempts{g.logins[login], g.ips[ip]} {
+		if a != nil && a.lockedUntil.Sub(now) > wait {
+			wait = a.lockedUntil.Sub(now)
+		}
+	}
+	return wait
+}
+
+// fail counts a failed attempt and locks the login or the address when failures exceed the policy
+func (g *loginGuard) fail(login, ip string, now time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.policy.MaxAttempts > 0 {
+		g.count(g.logins, "login "+login, login, g.policy.MaxAttempts, now)
+	}
+	if g.policy.MaxIPAttempts > 0 && ip != "" {
+		g.count(g.ips, "address "+ip, ip, g.policy.MaxIPAttempts, now)
+	}
+}
+
+func (g *loginGuard) count(entries map[string]*attempts, subject, key string, limit int, now time.Time) {
+	a, ok := entries[key]
+	if !ok {
+		if !g.makeRoom(entries, now) {
+			log.Printf("[WARN] failed login attempts of %s aren't tracked, all tracked entries are locked", subject)
+			return
+		}
+		a = &attempts{}
+		entries[key] = a
+	}
+
+	if a.failures == 0 || now.Sub(a.first) > g.policy.Window {
+		a.failures, a.first = 0, now
+	}
+	a.failures++
+	if a.failures < limit {
+		return
+	}
+
+	d := g.lockDuration(a.locks)
+	log.Printf("[WARN] %s locked for %s after %d failed login attempts", subject, d, a.failures)
+	a.lockedUntil = now.Add(d)
+	a.locks++
+	a.failures = 0
+}
+
+// lockDuration doubles duration for every previous lock in a row
+func (g *loginGuard) lockDuration(locks int) time.Duration {
+	d := g.policy.Duration
+	for i := 0; i < locks && (g.policy.MaxDuration <= 0 || d < g.policy.MaxDuration); i++ {
+		d *= 2
+	}
+	if g.policy.MaxDuration > 0 && d > g.policy.MaxDuration {
+		d = g.policy.MaxDuration
+	}
+	return d
+}
+
+// succeed resets attempts of the login, attempts of the address are kept because one valid account
+// shouldn't allow to continue guessing of others
+func (g *loginGuard) succeed(login string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.logins, login)
+}
+
+// status returns lock status of the login
+func (g *loginGuard) status(login string, now time.Time) lockStatus {
+	if g == nil {
+		return lockStatus{}
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	a, ok := g.logins[login]
+	if !ok {
+		return lockStatus{}
+	}
+
+	st := lockStatus{Failures: a.failures}
+	if a.lockedUntil.After(now) {
+		until := a.lockedUntil
+		st.Locked, st.Until = true, &until
+	}
+	return st
+}
+
+// unlock resets lock and attempts of the login
+func (g *loginGuard) unlock(login string) {
+	if g == nil {
+		return
+	}
+	g.succeed(login)
+}
+
+// purge removes entries which are neither locked nor counted within the window
+func (g *loginGuard) purge(now time.Time) {
+	// entries with locks in a row are kept while the next lock can be longer
+	keep := g.policy.Window
+	if g.policy.MaxDuration > keep {
+		keep = g.policy.MaxDuration
+	}
+
+	for _, entries := range []map[string]*attempts{g.logins, g.ips} {
+		for k, a := range entries {
+			if now.Sub(a.lockedUntil) > keep && now.Sub(a.first) > g.policy.Window {
+				delete(entries, k)
+			}
+		}
+	}
+}
+
+// makeRoom purges entries and evicts the oldest unlocked ones when entries are full, so spraying of logins or addresses
+// can't grow memory unbounded. It returns false when all entries are locked and a new one can't be added.
+func (g *loginGuard) makeRoom(entries map[string]*attempts, now time.Time) bool {
+	if len(entries) < g.maxEntries {
+		return true
+	}
+
+	g.purge(now)
+	if len(entries) < g.maxEntries {
+		return true
+	}
+
+	unlocked := make([]string, 0, len(entries))
+	for k, a := range entries {
+		if !a.lockedUntil.After(now) {
+			unlocked = append(unlocked, k)
+		}
+	}
+	sort.Slice(unlocked, func(i, j int) bool { return entries[unlocked[i]].first.Before(entries[unlocked[j]].first) })
+
+	// a tenth of entries is evicted at once, so next failures don't sort entries again
+	n := len(entries) - g.maxEntries + 1 + g.maxEntries/10
+	if n > len(unlocked) {
+		n = len(unlocked)
+	}
+	for _, k := range unlocked[:n] {
+		delete(entries, k)
+	}
+	return len(entries) < g.maxEntries
+}
+
+// clientIP returns address of the client, RealIP middleware replaces it with the X-Real-IP or X-Forwarded-For
+// header value, so the address is trusted only behind a proxy which overwrites these headers
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// basicAuthLogin returns login of basic authentication, requests without a password are anonymous
+func basicAuthLogin(r *http.Request) string {
+	if user, password, ok := r.BasicAuth(); ok && password != "" {
+		return user
+	}
+	return ""
+}
+
+// oauthLogin returns login of OAuth2 token request with the password grant type, requests with refresh tokens
+// aren't tracked because they haven't a login
+func oauthLogin(r *http.Request) string {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != grantTypePassword {
+		return ""
+	}
+	return r.PostForm.Get("username")
+}
+
+// directLoginName returns login of portal login request of direct provider which passes credentials
+// with query params, a JSON body or a form
+func directLoginName(r *http.Request) string {
+	if !strings.HasSuffix(r.URL.Path, "/"+localProviderName+"/login") {
+		return ""
+	}
+
+	if r.Method == http.MethodGet {
+		return r.URL.Query().Get("user")
+	}
+
+	if r.Method != http.MethodPost || r.Body == nil {
+		return ""
+	}
+
+	// the body is read by the provider again
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1024*1024))
+	if err != nil {
+		return ""
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		var creds struct {
+			User string `json:"user"`
+		}
+		if err = json.Unmarshal(body, &creds); err != nil {
+			return ""
+		}
+		return creds.User
+	}
+
+	req := r.Clone(r.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err = req.ParseForm(); err != nil {
+		return ""
+	}
+	return req.Form.Get("user")
+}
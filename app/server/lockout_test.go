@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine/embedded"
+)
+
+func TestLoginGuard_backoff(t *testing.T) {
+	assert.Nil(t, newLoginGuard(LockoutPolicy{}), "guard disabled without thresholds")
+
+	g := newLoginGuard(LockoutPolicy{MaxAttempts: 3, Window: time.Minute, Duration: time.Minute, MaxDuration: 3 * time.Minute})
+	require.NotNil(t, g)
+	now := time.Now()
+
+	g.fail("john", "10.0.0.1", now)
+	g.fail("john", "10.0.0.1", now)
+	assert.Equal(t, time.Duration(0), g.check("john", "10.0.0.1", now))
+	assert.Equal(t, lockStatus{Failures: 2}, g.status("john", now))
+
+	// failures out of the window aren't counted together
+	g.fail("john", "10.0.0.1", now.Add(2*time.Minute))
+	assert.Equal(t, time.Duration(0), g.check("john", "10.0.0.1", now.Add(2*time.Minute)))
+
+	now = now.Add(2 * time.Minute)
+	g.fail("john", "10.0.0.1", now)
+	g.fail("john", "10.0.0.1", now)
+	assert.Equal(t, time.Minute, g.check("john", "10.0.0.2", now), "login locked from any address")
+	st := g.status("john", now)
+	assert.True(t, st.Locked)
+	assert.Equal(t, now.Add(time.Minute), *st.Until)
+	assert.Equal(t, time.Duration(0), g.check("bob", "10.0.0.1", now), "address tracking disabled")
+
+	// every next lock is twice longer until the limit
+	for _, expected := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		now = now.Add(5 * time.Minute)
+		assert.Equal(t, time.Duration(0), g.check("john", "10.0.0.1", now))
+		for i := 0; i < 3; i++ {
+			g.fail("john", "10.0.0.1", now)
+		}
+		assert.Equal(t, expected, g.check("john", "10.0.0.1", now))
+	}
+
+	g.unlock("john")
+	assert.Equal(t, time.Duration(0), g.check("john", "10.0.0.1", now))
+	assert.Equal(t, lockStatus{}, g.status("john", now))
+
+	// the first lock after unlock has the initial duration
+	for i := 0; i < 3; i++ {
+		g.fail("john", "10.0.0.1", now)
+	}
+	assert.Equal(t, time.Minute, g.check("john", "10.0.0.1", now))
+}
+
+func TestLoginGuard_address(t *testing.T) {
+	g := newLoginGuard(LockoutPolicy{MaxIPAttempts: 3, Window: time.Minute, Duration: time.Minute})
+	now := time.Now()
+
+	// guessing of different logins from the same address
+	for _, login := range []string{"john", "bob", "alice"} {
+		g.fail(login, "10.0.0.1", now)
+	}
+	assert.Equal(t, time.Minute, g.check("mary", "10.0.0.1", now))
+	assert.Equal(t, time.Duration(0), g.check("mary", "10.0.0.2", now))
+	assert.Equal(t, lockStatus{}, g.status("john", now), "login tracking disabled")
+
+	// a successful login doesn't reset attempts of address
+	g.succeed("mary")
+	assert.Equal(t, time.Minute, g.check("mary", "10.0.0.1", now))
+}
+
+func TestLoginGuard_purge(t *testing.T) {
+	g := newLoginGuard(LockoutPolicy{MaxAttempts: 2, Window: time.Minute, Duration: time.Minute, MaxDuration: time.Hour})
+	now := time.Now()
+
+	g.fail("stale", "", now.Add(-2*time.Hour))
+	g.fail("locked", "", now)
+	g.fail("locked", "", now)
+	g.fail("recent", "", now)
+
+	g.purge(now)
+	assert.NotContains(t, g.logins, "stale")
+	assert.Contains(t, g.logins, "locked")
+	assert.Contains(t, g.logins, "recent")
+}
+
+func TestLoginGuard_limit(t *testing.T) {
+	g := newLoginGuard(LockoutPolicy{MaxAttempts: 2, MaxIPAttempts: 100, Window: time.Minute, Duration: time.Minute})
+	g.maxEntries = 10
+	now := time.Now()
+
+	g.fail("locked", "10.0.0.1", now)
+	g.fail("locked", "10.0.0.1", now)
+	for i := 0; i < 20; i++ {
+		g.fail(fmt.Sprintf("login_%d", i), "10.0.0.1", now.Add(time.Duration(i)*time.Second))
+		assert.LessOrEqual(t, len(g.logins), 10)
+	}
+
+	// the oldest unlocked entries are evicted, locked ones are kept
+	assert.Contains(t, g.logins, "locked")
+	assert.Contains(t, g.logins, "login_19")
+	assert.NotContains(t, g.logins, "login_0")
+	assert.Equal(t, time.Minute, g.check("locked", "10.0.0.2", now))
+
+	// new entries aren't tracked when all entries are locked
+	for i := 0; i < 20; i++ {
+		login := fmt.Sprintf("login_%d", i)
+		g.fail(login, "", now.Add(30*time.Second))
+		g.fail(login, "", now.Add(30*time.Second))
+	}
+	assert.Len(t, g.logins, 10)
+	assert.Contains(t, g.logins, "locked")
+	for _, a := range g.logins {
+		assert.True(t, a.lockedUntil.After(now.Add(30*time.Second)))
+	}
+	g.fail("new", "", now.Add(30*time.Second))
+	assert.NotContains(t, g.logins, "new")
+}
+
+func TestLoginGuard_middleware(t *testing.T) {
+	g := newLoginGuard(LockoutPolicy{MaxAttempts: 2, Window: time.Minute, Duration: time.Minute})
+
+	handler := g.middleware(http.StatusUnauthorized, basicAuthLogin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	request := func(login, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/users", http.NoBody)
+		if login != "" {
+			req.SetBasicAuth(login, password)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("john", "bad").Code)
+	assert.Equal(t, http.StatusOK, request("john", "secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("john", "bad").Code, "success resets failures")
+	assert.Equal(t, http.StatusUnauthorized, request("john", "bad").Code)
+
+	w := request("john", "secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "locked login denied with valid password")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, request("bob", "secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("", "").Code, "requests without credentials aren't tracked")
+	assert.Equal(t, http.StatusUnauthorized, request("", "").Code)
+
+	// the handler marks failed logins when the fail status is ambiguous
+	marked := g.middleware(0, basicAuthLogin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "secret" {
+			loginFailed(r)
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/api/v1/registry/auth", http.NoBody)
+		req.SetBasicAuth("alice", "secret")
+		w = httptest.NewRecorder()
+		marked.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "denial with valid credentials isn't counted")
+	}
+	for _, expected := range []int{http.StatusForbidden, http.StatusForbidden, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/api/v1/registry/auth", http.NoBody)
+		req.SetBasicAuth("alice", "bad")
+		w = httptest.NewRecorder()
+		marked.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Code)
+	}
+	loginFailed(httptest.NewRequest("GET", "/", http.NoBody))
+
+	// nil guard passes all requests
+	var nilGuard *loginGuard
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	assert.NotNil(t, nilGuard.middleware(http.StatusForbidden, basicAuthLogin)(next))
+	assert.Equal(t, lockStatus{}, nilGuard.status("john", time.Now()))
+	nilGuard.unlock("john")
+}
+
+func TestDirectLoginName(t *testing.T) {
+	req := httptest.NewRequest("GET", "/auth/local/login?user=john&passwd=secret", http.NoBody)
+	assert.Equal(t, "john", directLoginName(req))
+
+	req = httptest.NewRequest("GET", "/auth/github/login?user=john", http.NoBody)
+	assert.Empty(t, directLoginName(req), "other providers don't check passwords")
+
+	req = httptest.NewRequest("POST", "/auth/local/login", strings.NewReader(`{"user":"bob","passwd":"secret"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	assert.Equal(t, "bob", directLoginName(req))
+
+	// body is kept for the provider
+	body := make([]byte, 64)
+	n, _ := req.Body.Read(body)
+	assert.Equal(t, `{"user":"bob","passwd":"secret"}`, string(body[:n]))
+
+	form := url.Values{"user": {"alice"}, "passwd": {"secret"}}
+	req = httptest.NewRequest("POST", "/auth/local/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.Equal(t, "alice", directLoginName(req))
+	require.NoError(t, req.ParseForm())
+	assert.Equal(t, "alice", req.Form.Get("user"))
+
+	req = httptest.NewRequest("POST", "/auth/local/login", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	assert.Empty(t, directLoginName(req))
+}
+
+func TestIntegrationLoginLockout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := embedded.NewEmbedded(filepath.Join(t.TempDir(), "lockout.db"))
+	require.NoError(t, db.Connect(ctx))
+	defer func() { assert.NoError(t, db.Close(ctx)) }()
+
+	user := store.User{Login: "john", Name: "John", Password: "john_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, db.CreateUser(ctx, &user))
+	admin := store.User{Login: "lock_admin", Name: "Admin", Password: "admin_password", Role: store.AdminRole, Group: 1}
+	require.NoError(t, db.CreateUser(ctx, &admin))
+
+	ts := prepareAuthTestServer(ctx, t, &Server{
+		Storage: db,
+		Lockout: LockoutPolicy{MaxAttempts: 3, Window: time.Minute, Duration: time.Minute},
+	})
+	defer ts.Close()
+
+	login := func(password string) int {
+		resp, err := http.Get(ts.URL + "/auth/local/login?user=john&passwd=" + password)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	basicAuth := func(path, password string) int {
+		req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+path, http.NoBody)
+		require.NoError(t, err)
+		req.SetBasicAuth("john", password)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	failures := func() float64 {
+		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/users/%d", ts.URL, user.ID), http.NoBody)
+		require.NoError(t, err)
+		req.SetBasicAuth("lock_admin", "admin_password")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, resp.Body.Close()) }()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var msg responseMessage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&msg))
+		return msg.Data.(map[string]interface{})["lockout"].(map[string]interface{})["failures"].(float64)
+	}
+
+	// a denied scope with valid credentials isn't a failed login
+	for i := 0; i < 3; i++ {
+		path := "/api/v1/registry/auth?account=john&service=container_registry&scope=repository:foo/bar:pull"
+		assert.Equal(t, http.StatusForbidden, basicAuth(path, "john_password"))
+	}
+	assert.Equal(t, float64(0), failures())
+
+	// failures of portal login, API basic authentication and docker login are counted together,
+	// the registry token endpoint is guarded once, so every failure is counted once
+	assert.Equal(t, http.StatusForbidden, basicAuth("/api/v1/registry/auth?service=container_registry", "bad"))
+	assert.Equal(t, float64(1), failures())
+	assert.Equal(t, http.StatusForbidden, login("bad"))
+	assert.Equal(t, http.StatusUnauthorized, basicAuth("/api/v1/registry/catalog", "bad"))
+
+	assert.Equal(t, http.StatusTooManyRequests, login("john_password"))
+	assert.Equal(t, http.StatusTooManyRequests, basicAuth("/api/v1/registry/catalog", "john_password"))
+}
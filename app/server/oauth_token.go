@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zebox/registry-admin/app/registry"
+	"github.com/zebox/registry-admin/app/store"
+)
+
+// grant types of OAuth2 token requests which registry clients send
+const (
+	grantTypePassword     = "password"
+	grantTypeRefreshToken = "refresh_token"
+)
+
+// tokenOAuth implements the OAuth2 flow of token requests, for detailed description go to
+// https://docs.docker.com/registry/spec/auth/oauth/. A client gets a refresh token with credentials of user once
+// and uses it instead of credentials for getting access tokens later.
+func (rh *registryHandlers) tokenOAuth(w http.ResponseWriter, r *http.Request) {
+	renderError := func(status int, code, message string) {
+		renderJSONWithStatus(w, registryResponseError(registry.APIError{Code: code, Message: message}), status)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		renderError(http.StatusBadRequest, "UNSUPPORTED", "failed to parse token request form")
+		return
+	}
+
+	form := r.PostForm
+	clientID := form.Get("client_id")
+	if clientID == "" {
+		renderError(http.StatusBadRequest, "UNSUPPORTED", "client_id required")
+		return
+	}
+
+	scopes, err := registry.ParseScopes(form["scope"])
+	if err != nil {
+		renderError(http.StatusBadRequest, "UNSUPPORTED", err.Error())
+		return
+	}
+
+	var (
+		account     store.User
+		checkAccess scopeAccessChecker
+		offline     bool
+	)
+
+	switch form.Get("grant_type") {
+	case grantTypePassword:
+		username, password := form.Get("username"), form.Get("password")
+		if robotName, isRobot := store.RobotNameFromLogin(username); isRobot {
+			robot, ok := rh.robotByCredentials(r.Context(), robotName, password)
+			if !ok {
+				rh.l.Logf("wrong robot credentials or robot disabled or expired: %s", robotName)
+				renderError(http.StatusUnauthorized, "UNAUTHORIZED", "access denied")
+				return
+			}
+			account, checkAccess = robotAccount(robot)
+			break
+		}
+
+		user, _, errUser := rh.userByCredentials(r.Context(), username, password)
+		if errUser != nil {
+			rh.l.Logf("wrong user credentials or account disabled: %s", username)
+			renderError(http.StatusUnauthorized, "UNAUTHORIZED", "access denied")
+			return
+		}
+		account, checkAccess = user, rh.userAccessChecker(user)
+		offline = form.Get("access_type") == "offline"
+
+	case grantTypeRefreshToken:
+		user, errToken := rh.userByRefreshToken(r.Context(), form.Get("refresh_token"), clientID)
+		if errToken != nil {
+			rh.l.Logf("[WARN] refresh token of client %s denied: %v", clientID, errToken)
+			renderError(http.StatusUnauthorized, "UNAUTHORIZED", "invalid refresh token")
+			return
+		}
+		account, checkAccess = user, rh.userAccessChecker(user)
+
+	default:
+		renderError(http.StatusBadRequest, "UNSUPPORTED", "unsupported grant type")
+		return
+	}
+
+	// a request without scopes is a login, the token doesn't grant any access
+	tokenRequest := registry.TokenRequest{Account: account.Login, Service: form.Get("service")}
+	var grantedScopes []string
+	if len(scopes) > 0 {
+		if tokenRequest.Scopes, err = rh.grantScopes(r.Context(), account, scopes, checkAccess); err != nil {
+			rh.l.Logf("%v", err)
+			renderError(http.StatusForbidden, "DENIED", "access denied")
+			return
+		}
+		for _, scope := range tokenRequest.Scopes {
+			grantedScopes = append(grantedScopes, scope.String())
+		}
+	}
+
+	tokenString, err := rh.registryService.Token(tokenRequest)
+	if err != nil {
+		rh.l.Logf("[ERROR] failed to issue token for user %s: %v", account.Login, err)
+		renderError(http.StatusInternalServerError, "UNSUPPORTED", "failed to issue token")
+		return
+	}
+
+	rh.renderToken(w, r, tokenString, account, clientID, offline, map[string]string{"scope": strings.Join(grantedScopes, " ")})
+}
+
+// renderToken sends a token of registry service with extra fields, a refresh token is added when the client requests
+// offline access. Robots and anonymous users don't get refresh tokens, they send credentials with every request.
+func (rh *registryHandlers) renderToken(w http.ResponseWriter, r *http.Request, tokenString string, user store.User,
+	clientID string, offline bool, fields map[string]string) {
+
+	var err error
+	if offline && user.ID > 0 && rh.refreshTokenTTL > 0 {
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		if fields["refresh_token"], err = rh.issueRefreshToken(r.Context(), user.ID, clientID); err != nil {
+			rh.l.Logf("[ERROR] failed to issue refresh token for user %s: %v", user.Login, err)
+			renderJSONWithStatus(w, registryResponseError(registry.APIError{Code: "UNSUPPORTED", Message: "failed to issue refresh token"}),
+				http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if tokenString, err = withTokenFields(tokenString, fields); err != nil {
+		rh.l.Logf("[ERROR] failed to make token response: %v", err)
+		renderJSONWithStatus(w, registryResponseError(registry.APIError{Code: "UNSUPPORTED", Message: "failed to issue token"}),
+			http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if _, err = w.Write([]byte(tokenString)); err != nil {
+		rh.l.Logf("[ERROR] failed to response for token request: %v", err)
+	}
+}
+
+// issueRefreshToken creates a refresh token of the user for the client and returns a plain value of it
+func (rh *registryHandlers) issueRefreshToken(ctx context.Context, userID int64, clientID string) (string, error) {
+	value, hash, err := store.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := store.RefreshToken{
+		UserID:    userID,
+		ClientID:  clientID,
+		Hash:      hash,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(rh.refreshTokenTTL).Unix(),
+	}
+	if err = rh.dataStore.CreateRefreshToken(ctx, &token); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// userByRefreshToken returns the user of refresh token which is valid for the client, the user should be enabled
+func (rh *registryHandlers) userByRefreshToken(ctx context.Context, value, clientID string) (store.User, error) {
+	if value == "" {
+		return store.User{}, errors.New("refresh token required")
+	}
+
+	token, err := rh.dataStore.GetRefreshToken(ctx, store.HashRefreshToken(value))
+	if err != nil {
+		return store.User{}, err
+	}
+
+	now := time.Now()
+	if token.IsExpired(now) {
+		return store.User{}, errors.New("refresh token expired")
+	}
+
+	if token.ClientID != clientID {
+		return store.User{}, errors.New("refresh token issued for other client")
+	}
+
+	user, err := rh.dataStore.GetUser(ctx, token.UserID)
+	if err != nil {
+		return store.User{}, err
+	}
+
+	if user.Disabled {
+		return store.User{}, errors.New("user disabled")
+	}
+
+	if err = rh.dataStore.UpdateRefreshTokenUsage(ctx, token.ID, now.Unix()); err != nil {
+		rh.l.Logf("[WARN] failed to update usage of refresh token %d: %v", token.ID, err)
+	}
+	return user, nil
+}
+
+// withTokenFields adds non-empty fields to JSON response of registry service with a token
+func withTokenFields(tokenString string, fields map[string]string) (string, error) {
+	extra := make(map[string]string, len(fields))
+	for k, v := range fields {
+		if v != "" {
+			extra[k] = v
+		}
+	}
+
+	if len(extra) == 0 {
+		return tokenString, nil
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal([]byte(tokenString), &resp); err != nil {
+		return "", err
+	}
+
+	for k, v := range extra {
+		resp[k] = v
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/go-pkgz/lgr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zebox/registry-admin/app/registry"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"github.com/zebox/registry-admin/app/store/engine/embedded"
+)
+
+func TestIntegrationOAuthToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := embedded.NewEmbedded(filepath.Join(t.TempDir(), "oauth.db"))
+	require.NoError(t, db.Connect(ctx))
+	defer func() { assert.NoError(t, db.Close(ctx)) }()
+
+	user := store.User{Login: "john", Name: "John", Password: "john_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, db.CreateUser(ctx, &user))
+	require.NoError(t, db.CreateAccess(ctx, &store.Access{Owner: user.ID, Name: "john pull", Type: store.RepositoryResourceType,
+		ResourceName: "team/app", Action: "pull"}))
+
+	robot := store.Robot{Name: "ci", Secret: "robot_secret", Permissions: []store.RobotPermission{
+		{Type: store.RepositoryResourceType, ResourceName: "team/*", Actions: []string{"push"}},
+	}}
+	require.NoError(t, db.CreateRobot(ctx, &robot))
+
+	// the token of registry mock contains the subject and granted scopes
+	registryMock := &registryInterfaceMock{
+		TokenFunc: func(authRequest registry.TokenRequest) (string, error) {
+			var scopes []string
+			for _, scope := range authRequest.Scopes {
+				scopes = append(scopes, scope.String())
+			}
+			tkn := authRequest.Account + "|" + strings.Join(scopes, " ")
+			b, err := json.Marshal(registry.ClientToken{Token: tkn, AccessToken: tkn, ExpiresIn: 60})
+			return string(b), err
+		},
+		LoginFunc: func(user store.User) (string, error) {
+			return `{"token":"login","access_token":"login"}`, nil
+		},
+		UpdateHtpasswdFunc: func(usersFn registry.FetchUsers) error { return nil },
+	}
+
+	ts := prepareAuthTestServer(ctx, t, &Server{Storage: db, RegistryService: registryMock, RefreshTokenTTL: time.Hour})
+	defer ts.Close()
+
+	type tokenResponse struct {
+		Token        string `json:"token"`
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Scope        string `json:"scope"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	post := func(form url.Values) (int, tokenResponse) {
+		time.Sleep(100 * time.Millisecond) // keep requests under the rate limit of routes
+		resp, err := http.PostForm(ts.URL+"/api/v1/registry/auth", form)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, resp.Body.Close()) }()
+
+		var result tokenResponse
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		}
+		return resp.StatusCode, result
+	}
+
+	// docker login requests a refresh token with the GET flow
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		ts.URL+"/api/v1/registry/auth?account=john&client_id=docker&offline_token=true&service=container_registry", http.NoBody)
+	require.NoError(t, err)
+	req.SetBasicAuth("john", "john_password")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "login", login.Token)
+	require.NotEmpty(t, login.RefreshToken)
+
+	tokens, err := db.FindRefreshTokens(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), tokens.Total)
+	stored := tokens.Data[0].(store.RefreshToken)
+	assert.Equal(t, "docker", stored.ClientID)
+	assert.Equal(t, store.HashRefreshToken(login.RefreshToken), stored.Hash)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), stored.ExpiresAt, 5)
+
+	// the refresh token is used instead of credentials, the token contains permitted actions only
+	status, result := post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {login.RefreshToken}, "client_id": {"docker"},
+		"service": {"container_registry"}, "scope": {"repository:team/app:pull,push"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "john|repository:team/app:pull", result.AccessToken)
+	assert.Equal(t, "repository:team/app:pull", result.Scope)
+	assert.Equal(t, int64(60), result.ExpiresIn)
+	assert.Empty(t, result.RefreshToken)
+
+	stored, err = db.GetRefreshToken(ctx, stored.Hash)
+	require.NoError(t, err)
+	assert.Greater(t, stored.LastUsedAt, int64(0))
+
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {login.RefreshToken}, "client_id": {"docker"},
+		"scope": {"repository:team/other:pull"}})
+	assert.Equal(t, http.StatusForbidden, status, "no scopes granted")
+
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {login.RefreshToken}, "client_id": {"containerd"}})
+	assert.Equal(t, http.StatusUnauthorized, status, "token of other client")
+
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"unknown"}, "client_id": {"docker"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// the password grant issues a new refresh token with offline access only
+	status, result = post(url.Values{"grant_type": {"password"}, "username": {"john"}, "password": {"john_password"},
+		"client_id": {"containerd"}, "access_type": {"offline"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "john|", result.AccessToken)
+	require.NotEmpty(t, result.RefreshToken)
+	assert.NotEqual(t, login.RefreshToken, result.RefreshToken)
+
+	status, result = post(url.Values{"grant_type": {"password"}, "username": {"john"}, "password": {"john_password"}, "client_id": {"containerd"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, result.RefreshToken)
+
+	status, _ = post(url.Values{"grant_type": {"password"}, "username": {"john"}, "password": {"wrong"}, "client_id": {"containerd"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// robots get access tokens without refresh tokens
+	status, result = post(url.Values{"grant_type": {"password"}, "username": {"robot$ci"}, "password": {"robot_secret"},
+		"client_id": {"docker"}, "access_type": {"offline"}, "scope": {"repository:team/app:pull,push"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "robot$ci|repository:team/app:push", result.AccessToken)
+	assert.Empty(t, result.RefreshToken)
+
+	status, _ = post(url.Values{"grant_type": {"client_credentials"}, "client_id": {"docker"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = post(url.Values{"grant_type": {"password"}, "username": {"john"}, "password": {"john_password"}})
+	assert.Equal(t, http.StatusBadRequest, status, "client_id required")
+
+	// refresh tokens of disabled user aren't accepted
+	user.Disabled = true
+	user.Password = ""
+	require.NoError(t, db.UpdateUser(ctx, user))
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {login.RefreshToken}, "client_id": {"docker"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	user.Disabled = false
+	require.NoError(t, db.UpdateUser(ctx, user))
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {login.RefreshToken}, "client_id": {"docker"}})
+	assert.Equal(t, http.StatusOK, status)
+
+	// revoked and expired tokens aren't accepted
+	require.NoError(t, db.DeleteRefreshToken(ctx, "user_id", user.ID))
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {login.RefreshToken}, "client_id": {"docker"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	expired, hash, err := store.NewRefreshToken()
+	require.NoError(t, err)
+	require.NoError(t, db.CreateRefreshToken(ctx, &store.RefreshToken{UserID: user.ID, ClientID: "docker", Hash: hash,
+		CreatedAt: time.Now().Add(-2 * time.Hour).Unix(), ExpiresAt: time.Now().Add(-time.Hour).Unix()}))
+	status, _ = post(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {expired}, "client_id": {"docker"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func Test_userTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := embedded.NewEmbedded(filepath.Join(t.TempDir(), "tokens.db"))
+	require.NoError(t, db.Connect(ctx))
+	defer func() { assert.NoError(t, db.Close(ctx)) }()
+
+	user := store.User{Login: "john", Name: "John", Password: "john_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, db.CreateUser(ctx, &user))
+
+	rh := registryHandlers{endpointsHandler: endpointsHandler{dataStore: db, l: log.NoOp}, refreshTokenTTL: time.Hour}
+	var ids []int64
+	for _, client := range []string{"docker", "containerd"} {
+		_, err := rh.issueRefreshToken(ctx, user.ID, client)
+		require.NoError(t, err)
+	}
+	_, err := rh.issueRefreshToken(ctx, 1, "docker")
+	require.NoError(t, err)
+
+	uh := userHandlers{endpointsHandler: endpointsHandler{dataStore: db, l: log.NoOp}}
+	request := func(method, path string, handler http.HandlerFunc, params map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, http.NoBody)
+		rctx := chi.NewRouteContext()
+		for k, v := range params {
+			rctx.URLParams.Add(k, v)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	userID := strconv.FormatInt(user.ID, 10)
+	w := request("GET", "/api/v1/users/"+userID+"/tokens", uh.userTokensFindCtrl, map[string]string{"id": userID})
+	require.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Total int64                `json:"total"`
+		Data  []store.RefreshToken `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	require.Equal(t, int64(2), result.Total)
+	assert.NotContains(t, w.Body.String(), "hash")
+	for _, tkn := range result.Data {
+		assert.Equal(t, user.ID, tkn.UserID)
+		ids = append(ids, tkn.ID)
+	}
+
+	// a token of other user can't be revoked with the user path
+	adminTokens, err := db.FindRefreshTokens(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": 1}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), adminTokens.Total)
+	adminTokenID := strconv.FormatInt(adminTokens.Data[0].(store.RefreshToken).ID, 10)
+	w = request("DELETE", "/", asAdmin(uh.userTokenRevokeCtrl), map[string]string{"id": userID, "token_id": adminTokenID})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = request("DELETE", "/", asAdmin(uh.userTokenRevokeCtrl), map[string]string{"id": userID, "token_id": strconv.FormatInt(ids[0], 10)})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request("DELETE", "/", asAdmin(uh.userTokenRevokeCtrl), map[string]string{"id": userID, "token_id": "wrong"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request("DELETE", "/", asAdmin(uh.userTokensRevokeCtrl), map[string]string{"id": userID})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request("DELETE", "/", asAdmin(uh.userTokensRevokeCtrl), map[string]string{"id": userID})
+	assert.Equal(t, http.StatusOK, w.Code, "user without tokens")
+
+	tokens, err := db.FindRefreshTokens(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tokens.Total, "tokens of other users are kept")
+}
@@ -11,10 +11,11 @@ import (
 	"github.com/zebox/registry-admin/app/registry"
 	"github.com/zebox/registry-admin/app/store"
 	"github.com/zebox/registry-admin/app/store/engine"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
+	"time"
 )
 
 // dataServiceInterface implement dataService instance
@@ -29,8 +30,13 @@ type registryHandlers struct {
 	endpointsHandler
 	registryService registryInterface
 	dataService     dataServiceInterface
+	directory       directoryAuthenticator // checks credentials of directory users before the store
+	refreshTokenTTL time.Duration          // lifetime of refresh tokens which clients get instead of credentials, ones aren't issued when zero
 }
 
+// scopeAccessChecker returns the subset of requested actions which an account is allowed to perform on the scope resource
+type scopeAccessChecker func(ctx context.Context, scope registry.ResourceScope) ([]string, error)
+
 func (rh *registryHandlers) tokenAuth(w http.ResponseWriter, r *http.Request) {
 
 	username, password, ok := r.BasicAuth()
@@ -38,19 +44,42 @@ func (rh *registryHandlers) tokenAuth(w http.ResponseWriter, r *http.Request) {
 		// check access for all (guest), public repository access
 		// owner_id equal '0' for anonymous user
 		user := store.User{ID: engine.AnonymousUserID}
-		rh.parseTokenRequestParams(w, r, user)
+		rh.parseTokenRequestParams(w, r, user, rh.userAccessChecker(user))
+		return
+	}
+
+	if robotName, isRobot := store.RobotNameFromLogin(username); isRobot {
+		rh.robotTokenAuth(w, r, robotName, password)
 		return
 	}
 
-	user, errUser := rh.dataStore.GetUser(r.Context(), username)
-	if errUser != nil {
+	user, found, err := rh.userByCredentials(r.Context(), username, password)
+	if !found {
 		anonUser := store.User{ID: engine.AnonymousUserID}
-		rh.parseTokenRequestParams(w, r, anonUser)
+		rh.parseTokenRequestParams(w, r, anonUser, rh.userAccessChecker(anonUser))
 		return
 	}
 
-	if !store.ComparePassword(user.Password, password) || user.Disabled {
-		rh.l.Logf("wrong user credentials or account disabled: %s", user.Login)
+	if err != nil {
+		rh.l.Logf("wrong user credentials or account disabled: %s", username)
+		loginFailed(r)
+		renderJSONWithStatus(
+			w,
+			registryResponseError(registry.APIError{Code: "DENIED", Message: "access denied"}),
+			http.StatusForbidden,
+		)
+		return
+	}
+
+	rh.parseTokenRequestParams(w, r, user, rh.userAccessChecker(user))
+}
+
+// robotTokenAuth checks robot credentials, a token of robot contains actions granted by robot permissions only
+func (rh *registryHandlers) robotTokenAuth(w http.ResponseWriter, r *http.Request, name, secret string) {
+	robot, ok := rh.robotByCredentials(r.Context(), name, secret)
+	if !ok {
+		rh.l.Logf("wrong robot credentials or robot disabled or expired: %s", name)
+		loginFailed(r)
 		renderJSONWithStatus(
 			w,
 			registryResponseError(registry.APIError{Code: "DENIED", Message: "access denied"}),
@@ -59,7 +88,57 @@ func (rh *registryHandlers) tokenAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rh.parseTokenRequestParams(w, r, user)
+	account, checkAccess := robotAccount(robot)
+	rh.parseTokenRequestParams(w, r, account, checkAccess)
+}
+
+// userByCredentials returns the user with valid credentials, found is false when the login is unknown.
+// A password of directory user is checked by the directory, a disabled user is denied.
+func (rh *registryHandlers) userByCredentials(ctx context.Context, login, password string) (user store.User, found bool, err error) {
+	user, found, err = directoryUser(ctx, rh.directory, login, password)
+	if !found {
+		if user, err = rh.dataStore.GetUser(ctx, login); err != nil {
+			return store.User{}, false, err
+		}
+		if !store.ComparePassword(user.Password, password) {
+			return user, true, errors.New("wrong user password")
+		}
+	}
+
+	if err != nil {
+		return user, true, err
+	}
+
+	if user.Disabled {
+		return user, true, errors.New("user disabled")
+	}
+	return user, true, nil
+}
+
+// robotByCredentials returns the robot when the secret is valid and the robot is enabled and isn't expired
+func (rh *registryHandlers) robotByCredentials(ctx context.Context, name, secret string) (store.Robot, bool) {
+	robot, err := rh.dataStore.GetRobot(ctx, name)
+	if err != nil || !store.ComparePassword(robot.Secret, secret) || robot.Disabled || robot.IsExpired(time.Now()) {
+		return store.Robot{}, false
+	}
+	return robot, true
+}
+
+// robotAccount returns an account of the robot and a checker of robot permissions
+func robotAccount(robot store.Robot) (store.User, scopeAccessChecker) {
+	// robot acts as an account which has the robot login and the distinct identity, but it isn't a user,
+	// so the account parameter is checked like for users and access rules don't apply to it
+	account := store.User{ID: engine.RobotUserID, Login: robot.Login(), Name: robot.Name}
+	return account, func(_ context.Context, scope registry.ResourceScope) ([]string, error) {
+		return robot.AllowedActions(scope.Type, scope.Name, scope.Actions), nil
+	}
+}
+
+// userAccessChecker returns a checker which evaluates access rules of the user
+func (rh *registryHandlers) userAccessChecker(user store.User) scopeAccessChecker {
+	return func(ctx context.Context, scope registry.ResourceScope) ([]string, error) {
+		return rh.checkUserAccess(ctx, user, scope)
+	}
 }
 
 // health checks availability a registry service
@@ -136,6 +215,49 @@ func (rh *registryHandlers) syncRepositories(w http.ResponseWriter, r *http.Requ
 	rest.RenderJSON(w, responseMessage{Message: "ok", Data: []interface{}{}})
 }
 
+// signingKeysList returns keys of token signature with their rotation status and expiry warnings
+func (rh *registryHandlers) signingKeysList(w http.ResponseWriter, r *http.Request) {
+	keys, err := rh.registryService.SigningKeys()
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, signingKeyErrorStatus(err), err, "failed to get signing keys")
+		return
+	}
+	rest.RenderJSON(w, responseMessage{Message: "ok", Data: keys})
+}
+
+func (rh *registryHandlers) signingKeyStage(w http.ResponseWriter, r *http.Request) {
+	rh.rotateSigningKey(w, r, rh.registryService.StageSigningKey, "stage")
+}
+
+func (rh *registryHandlers) signingKeySwitch(w http.ResponseWriter, r *http.Request) {
+	rh.rotateSigningKey(w, r, rh.registryService.SwitchSigningKey, "switch")
+}
+
+func (rh *registryHandlers) signingKeyRetire(w http.ResponseWriter, r *http.Request) {
+	rh.rotateSigningKey(w, r, rh.registryService.RetireSigningKey, "retire")
+}
+
+// rotateSigningKey runs a step of signing keys rotation and responses with the key which the step applies to
+func (rh *registryHandlers) rotateSigningKey(w http.ResponseWriter, r *http.Request, step func() (registry.SigningKey, error), name string) {
+	key, err := step()
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, signingKeyErrorStatus(err), err, fmt.Sprintf("failed to %s signing key", name))
+		return
+	}
+	rh.l.Logf("[INFO] signing key %s: %s", key.KeyID, name)
+	rest.RenderJSON(w, responseMessage{Message: "ok", Data: key})
+}
+
+func signingKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, registry.ErrTokenAuthRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, registry.ErrKeyRotation):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // catalogList returns list of repositories entry
 func (rh *registryHandlers) catalogList(w http.ResponseWriter, r *http.Request) {
 	filter, err := engine.FilterFromURLExtractor(r.URL)
@@ -152,7 +274,14 @@ func (rh *registryHandlers) catalogList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if user.GetRole() == store.UserRole {
+	// users without permission of browse all repositories see repositories which access rules allow them to pull only
+	browseAll, err := rolePermits(r.Context(), rh.dataStore, user.GetRole(), store.PermissionRegistryRead)
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusInternalServerError, err, "failed to check user permissions")
+		return
+	}
+
+	if !browseAll {
 		if filter.Filters == nil {
 			filter.Filters = map[string]interface{}{engine.RepositoriesByUserAccess: user.Attributes["uid"]}
 		} else {
@@ -164,7 +293,7 @@ func (rh *registryHandlers) catalogList(w http.ResponseWriter, r *http.Request)
 	repoList, errReposList := rh.dataStore.FindRepositories(r.Context(), filter)
 
 	if errReposList != nil {
-		SendErrorJSON(w, r, rh.l, http.StatusInternalServerError, errReposList, "failed to fetch list of repositories")
+		SendErrorJSON(w, r, rh.l, queryErrorStatus(errReposList), errReposList, "failed to fetch list of repositories")
 		return
 	}
 	w.Header().Add("Content-Range", fmt.Sprintf("registry/catalog %d-%d/%d", filter.Range[0], filter.Range[1], repoList.Total))
@@ -172,7 +301,7 @@ func (rh *registryHandlers) catalogList(w http.ResponseWriter, r *http.Request)
 
 }
 
-func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *http.Request, user store.User) {
+func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *http.Request, user store.User, checkAccess scopeAccessChecker) {
 	queryParams, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -186,12 +315,15 @@ func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// a client requests a refresh token for use it instead of credentials later
+	offline := queryParams.Get("offline_token") == "true"
+
 	// processing push and pull requests
-	if queryParams.Get("scope") != "" {
-		scopeParts := strings.Split(queryParams.Get("scope"), ":")
+	if scopeValues := queryParams["scope"]; len(scopeValues) > 0 {
+		scopes, errScopes := registry.ParseScopes(scopeValues)
 
 		// skips account scope check for anonymous users only
-		if len(scopeParts) != 3 || (user.ID != engine.AnonymousUserID && queryParams.Get("account") != user.Login) {
+		if errScopes != nil || len(scopes) == 0 || (user.ID != engine.AnonymousUserID && queryParams.Get("account") != user.Login) {
 			rh.l.Logf("[ERROR] wrong scope or user and account doesn't match: %s :user %s", r.RequestURI, user.Login)
 
 			renderJSONWithStatus(
@@ -207,9 +339,6 @@ func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *ht
 		tokenRequest := registry.TokenRequest{
 			Account: queryParams.Get("account"),
 			Service: queryParams.Get("service"),
-			Type:    scopeParts[0],
-			Name:    scopeParts[1],
-			Actions: strings.Split(scopeParts[2], ","),
 		}
 
 		if expireTime := queryParams.Get("expire"); expireTime != "" {
@@ -226,13 +355,11 @@ func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *ht
 			tokenRequest.ExpireTime = expireValue
 		}
 
-		if allow, errCheck := rh.checkUserAccess(r.Context(), user, tokenRequest); !allow || errCheck != nil {
-			errMsg := fmt.Errorf("[ERROR] access to registry resource not allowed for user %s: %v", user.Login, errCheck)
-
-			rh.l.Logf("%v", errMsg)
+		if tokenRequest.Scopes, err = rh.grantScopes(r.Context(), user, scopes, checkAccess); err != nil {
+			rh.l.Logf("%v", err)
 			renderJSONWithStatus(
 				w,
-				registryResponseError(registry.APIError{Code: "", Message: errMsg.Error()}),
+				registryResponseError(registry.APIError{Code: "", Message: err.Error()}),
 				http.StatusForbidden)
 			return
 		}
@@ -247,10 +374,7 @@ func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *ht
 			return
 		}
 
-		w.Header().Add("Content-Type", "application/json")
-		if _, err = w.Write([]byte(tokenString)); err != nil {
-			rh.l.Logf("[ERROR] failed to response for token request: %v", err)
-		}
+		rh.renderToken(w, r, tokenString, user, queryParams.Get("client_id"), offline, nil)
 		return
 	}
 
@@ -268,10 +392,7 @@ func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *ht
 			return
 		}
 
-		w.Header().Add("Content-Type", "application/json")
-		if _, err = w.Write([]byte(userToken)); err != nil {
-			rh.l.Logf("[ERROR] failed to response for docker login: %v", err)
-		}
+		rh.renderToken(w, r, userToken, user, queryParams.Get("client_id"), offline, nil)
 		return
 	}
 
@@ -283,42 +404,195 @@ func (rh *registryHandlers) parseTokenRequestParams(w http.ResponseWriter, r *ht
 
 }
 
-func (rh *registryHandlers) checkUserAccess(ctx context.Context, user store.User, tokenRequest registry.TokenRequest) (bool, error) {
+// grantScopes returns requested scopes with actions which the account is allowed to perform. Each scope is checked
+// independently, the token contains granted scopes with the permitted actions only. It fails when no scopes granted.
+func (rh *registryHandlers) grantScopes(ctx context.Context, user store.User, scopes []registry.ResourceScope,
+	checkAccess scopeAccessChecker) (granted []registry.ResourceScope, err error) {
+
+	for _, scope := range scopes {
+		allowed, errCheck := checkAccess(ctx, scope)
+		if errCheck != nil {
+			return nil, fmt.Errorf("[ERROR] failed to check access to registry resource for user %s: %v", user.Login, errCheck)
+		}
+
+		if len(allowed) == 0 {
+			rh.l.Logf("[WARN] access to registry resource %s:%s not allowed for user %s", scope.Type, scope.Name, user.Login)
+			continue
+		}
+		if len(allowed) < len(scope.Actions) {
+			rh.l.Logf("[INFO] access to registry resource %s:%s granted partially for user %s: %v of %v",
+				scope.Type, scope.Name, user.Login, allowed, scope.Actions)
+		}
+		scope.Actions = allowed
+		granted = append(granted, scope)
+	}
+
+	if len(granted) == 0 {
+		return nil, fmt.Errorf("[ERROR] access to registry resource not allowed for user %s", user.Login)
+	}
+	return granted, nil
+}
+
+// checkUserAccess returns the subset of requested actions which the user is allowed to perform on the scope resource,
+// each action is evaluated separately. A rule with the '*' action grants all actions of the resource type, e.g.
+// the registry catalog listing is requested with 'registry:catalog:*' scope. Actions which deny rules forbid
+// aren't allowed even when other rules grant them. Users which role has 'registry:all' permission are allowed all actions.
+func (rh *registryHandlers) checkUserAccess(ctx context.Context, user store.User, scope registry.ResourceScope) ([]string, error) {
+	fullAccess, err := rolePermits(ctx, rh.dataStore, user.Role, store.PermissionRegistryAll)
+	if err != nil {
+		return nil, err
+	}
+
+	if fullAccess {
+		return scope.Actions, nil
+	}
+
+	granted, err := rh.grantedActions(ctx, user, scope.Type, scope.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []string
+	for _, action := range scope.Actions {
+		if granted[action] || granted[store.ActionAll] {
+			allowed = append(allowed, action)
+		}
+	}
+	return allowed, nil
+}
 
-	if user.Role == "admin" {
-		return true, nil
+// grantedActions returns actions which the user is allowed to perform on the resource. Rules of the user, all groups
+// of the user and rules shared for all users apply together, each rule applies within its validity window only.
+// Resource names of rules can be patterns, when the resource matches several allow rules the most specific ones define
+// the allowed actions. Specificity is resolved separately for personal rules (the user and its groups) and for shared rules,
+// so a shared rule doesn't override rules of the user and vice versa, actions granted by both owner classes are combined.
+// Deny rules take precedence over allow rules regardless of their specificity and owner,
+// a deny rule with the '*' action forbids all actions of the resource.
+func (rh *registryHandlers) grantedActions(ctx context.Context, user store.User, resourceType, resourceName string) (map[string]bool, error) {
+	groups, err := rh.userGroups(ctx, user)
+	if err != nil {
+		return nil, err
 	}
 
 	filter := engine.QueryFilter{
 		Filters: map[string]interface{}{
-			"owner_id":      user.ID,
-			"resource_type": tokenRequest.Type,
-			"resource_name": tokenRequest.Name,
-			"action":        tokenRequest.Actions,
+			"resource_type": resourceType,
 			"disabled":      false,
 		},
 	}
-	access, err := rh.dataStore.FindAccesses(ctx, filter)
-	if err != nil {
-		// check access to repository with for all users permission
-		if !errors.Is(err, engine.ErrNotFound) {
-			return false, err
+
+	now := time.Now()
+	granted, denied := make(map[bool]map[string]bool), make(map[string]bool)
+	weight := map[bool]int{true: math.MinInt, false: math.MinInt}
+	for _, owner := range accessOwners(user, groups) {
+		filter.Filters["owner_id"] = owner.id
+		filter.Filters["is_group"] = owner.isGroup
+
+		accesses, err := rh.dataStore.FindAccesses(ctx, filter)
+		if err != nil && !errors.Is(err, engine.ErrNotFound) {
+			return nil, err
 		}
-		if user.ID > 0 {
-			filter.Filters["owner_id"] = engine.RegisteredUserID
-			if access, err = rh.dataStore.FindAccesses(ctx, filter); access.Total > 0 {
-				return true, err
+
+		for _, v := range accesses.Data {
+			access := v.(store.Access)
+			if !access.IsActive(now) || !store.MatchResource(access.ResourceName, resourceName) {
+				continue
 			}
+
+			if access.IsDeny() {
+				denied[access.Action] = true
+				continue
+			}
+
+			shared, specificity := owner.isShared(), store.ResourceSpecificity(access.ResourceName)
+			if specificity < weight[shared] {
+				continue
+			}
+			if specificity > weight[shared] {
+				weight[shared] = specificity
+				granted[shared] = make(map[string]bool)
+			}
+			granted[shared][access.Action] = true
 		}
+	}
 
-		filter.Filters["owner_id"] = engine.AnonymousUserID
-		if access, err = rh.dataStore.FindAccesses(ctx, filter); err != nil {
-			return false, err
+	actions := make(map[string]bool)
+	for _, classActions := range granted {
+		for action := range classActions {
+			actions[action] = true
 		}
 	}
+	return withoutDeniedActions(actions, denied, resourceType), nil
+}
+
+// withoutDeniedActions removes denied actions from granted ones, the '*' action is expanded to separate actions
+// of the resource type when some of them are denied
+func withoutDeniedActions(granted, denied map[string]bool, resourceType string) map[string]bool {
+	if len(denied) == 0 {
+		return granted
+	}
+	if denied[store.ActionAll] {
+		return map[string]bool{}
+	}
 
-	// if at least one item exist it's mean that access for user exist
-	return access.Total > 0, nil
+	if granted[store.ActionAll] {
+		delete(granted, store.ActionAll)
+		for _, action := range store.ResourceActions(resourceType) {
+			if action != store.ActionAll {
+				granted[action] = true
+			}
+		}
+	}
+
+	for action := range denied {
+		delete(granted, action)
+	}
+	return granted
+}
+
+type accessOwner struct {
+	id      int64
+	isGroup bool
+}
+
+// isShared reports whether rules of the owner are shared for all users rather than personal rules of the user or its groups
+func (o accessOwner) isShared() bool {
+	return !o.isGroup && (o.id == engine.RegisteredUserID || o.id == engine.AnonymousUserID)
+}
+
+// userGroups returns IDs of all groups which the user is a member of
+func (rh *registryHandlers) userGroups(ctx context.Context, user store.User) ([]int64, error) {
+	if user.ID <= 0 {
+		return nil, nil
+	}
+
+	members, err := rh.dataStore.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}})
+	if err != nil && !errors.Is(err, engine.ErrNotFound) {
+		return nil, err
+	}
+
+	groups := make([]int64, 0, len(members.Data))
+	for _, v := range members.Data {
+		groups = append(groups, v.(store.GroupMember).GroupID)
+	}
+	return groups, nil
+}
+
+// accessOwners returns owners of access rules which apply to the user: the user itself, groups of the user,
+// all registered users (for registered users only) and anonymous users
+func accessOwners(user store.User, groups []int64) []accessOwner {
+	if user.ID == engine.AnonymousUserID {
+		return []accessOwner{{id: engine.AnonymousUserID}}
+	}
+
+	owners := []accessOwner{{id: user.ID}}
+	for _, id := range groups {
+		owners = append(owners, accessOwner{id: id, isGroup: true})
+	}
+	if user.ID > 0 {
+		owners = append(owners, accessOwner{id: engine.RegisteredUserID})
+	}
+	return append(owners, accessOwner{id: engine.AnonymousUserID})
 }
 
 // registryErrors when registry response is failure, covered in detail in their relevant sections, are reported as part of 4xx responses, in a json response body.
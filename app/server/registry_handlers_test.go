@@ -17,6 +17,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 type ctxKeyName string
@@ -25,6 +26,9 @@ var (
 	ctxKey       ctxKeyName = "testCtxKey"
 	testUsers               = make(map[int64]store.User)
 	testAccesses            = make(map[int64]store.Access)
+
+	// testGroupMembers contains memberships of users in groups other than the primary one
+	testGroupMembers = []store.GroupMember{{GroupID: 4, UserID: 3}}
 )
 
 func TestRegistryHandlers_tokenAuth(t *testing.T) {
@@ -130,6 +134,76 @@ func TestRegistryHandlers_tokenAuth(t *testing.T) {
 			query:          "?account=baz&scope=repository:test_resource_6:pull&service=container_registry",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "test access granted to the user group",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:test_resource_7:pull&service=container_registry",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test access granted to the additional user group",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:test_resource_9:pull&service=container_registry",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test access granted by a namespace pattern",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:team-a/nested/app:push&service=container_registry",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test access granted by a group rule with more specific pattern",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:team-a/app:pull&service=container_registry",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test access denied by a more specific pattern without the action",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:team-a/app:push&service=container_registry",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "test access denied by an exact rule without the action",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:team-a/readonly:push&service=container_registry",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "test access granted by a personal pattern which a shared rule doesn't override",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:team-a/shared/app:push&service=container_registry",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "test access denied to a resource outside of pattern",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:team-b/app:push&service=container_registry",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "test access of a group with ID equal to the user ID",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:test_resource_8:pull&service=container_registry",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "test access of a user with ID equal to the user group ID",
+			login:          "baz",
+			password:       "baz_password",
+			query:          "?account=baz&scope=repository:test_resource_2:push&service=container_registry",
+			expectedStatus: http.StatusForbidden,
+		},
 		{
 			name:           "test with resource fetch params for user role, but with denied scope",
 			login:          "baz",
@@ -175,6 +249,188 @@ func TestRegistryHandlers_tokenAuth(t *testing.T) {
 	}
 }
 
+func TestRegistryHandlers_tokenAuthScopes(t *testing.T) {
+	testRegistryHandlers := registryHandlers{}
+	testRegistryHandlers.l = log.Default()
+
+	registryMock := prepareRegistryMock(t)
+	testRegistryHandlers.registryService = registryMock
+	testRegistryHandlers.dataStore = prepareAccessStoreMock(t)
+
+	filledTestEntries(t, &testRegistryHandlers)
+	ctx := context.Background()
+
+	// every scope is checked independently, denied ones are excluded from the token
+	// and granted ones contain permitted actions only
+	query := "?account=baz&service=container_registry" +
+		"&scope=repository:test_resource_3:pull" +
+		"&scope=repository:test_resource_7:pull" +
+		"&scope=repository:test_resource_8:pull" +
+		"&scope=repository:test_resource_3:push"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls := registryMock.TokenCalls()
+	require.NotEmpty(t, calls)
+	assert.Equal(t, []registry.ResourceScope{
+		{Type: "repository", Name: "test_resource_3", Actions: []string{"pull"}},
+		{Type: "repository", Name: "test_resource_7", Actions: []string{"pull"}},
+	}, calls[len(calls)-1].AuthRequest.Scopes)
+
+	// read-only user receives pull-only token for pull and push request
+	query = "?account=baz&service=container_registry&scope=repository:test_resource_3:pull,push"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []registry.ResourceScope{
+		{Type: "repository", Name: "test_resource_3", Actions: []string{"pull"}},
+	}, calls[len(calls)-1].AuthRequest.Scopes)
+
+	// admin receives all requested actions
+	query = "?account=bar&service=container_registry&scope=repository:test_resource_3:pull,push,delete"
+	requestWithCredentials(ctx, t, "bar", "bar_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []string{"pull", "push", "delete"}, calls[len(calls)-1].AuthRequest.Scopes[0].Actions)
+
+	// several scopes can be passed with one parameter separated by spaces
+	query = "?account=baz&service=container_registry&scope=repository:test_resource_3:pull%20repository:test_resource_7:pull"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []registry.ResourceScope{
+		{Type: "repository", Name: "test_resource_3", Actions: []string{"pull"}},
+		{Type: "repository", Name: "test_resource_7", Actions: []string{"pull"}},
+	}, calls[len(calls)-1].AuthRequest.Scopes)
+
+	// the request is denied when none of scopes is granted
+	query = "?account=baz&service=container_registry&scope=repository:test_resource_3:push&scope=repository:test_resource_8:pull"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	// catalog listing is granted with the registry resource type and the token contains the catalog scope
+	query = "?account=baz&service=container_registry&scope=registry:catalog:*"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []registry.ResourceScope{
+		{Type: "registry", Name: "catalog", Actions: []string{"*"}},
+	}, calls[len(calls)-1].AuthRequest.Scopes)
+
+	// anonymous users don't have access to the catalog which shared for registered users
+	query = "?service=container_registry&scope=registry:catalog:*"
+	requestWithCredentials(ctx, t, "", "", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	// the '*' action of a rule grants all actions
+	query = "?account=baz&service=container_registry&scope=repository:team-c/app:pull,push,delete"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []string{"pull", "push", "delete"}, calls[len(calls)-1].AuthRequest.Scopes[0].Actions)
+
+	// rules apply within their validity window only
+	query = "?account=baz&service=container_registry&scope=repository:team-d/app:pull,push,delete"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []string{"pull"}, calls[len(calls)-1].AuthRequest.Scopes[0].Actions)
+
+	// deny rules take precedence over allow rules with any specificity and over the '*' action of allow rules
+	query = "?account=baz&service=container_registry&scope=repository:team-a/secrets:pull"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	query = "?account=baz&service=container_registry&scope=repository:team-c/internal:pull,push,delete"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []string{"pull", "delete"}, calls[len(calls)-1].AuthRequest.Scopes[0].Actions)
+
+	// a deny rule of the user blocks a repository which is shared for all registered users
+	query = "?account=baz&service=container_registry&scope=repository:shared/app:pull"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+	query = "?account=qux&service=container_registry&scope=repository:shared/app:pull"
+	requestWithCredentials(ctx, t, "qux", "qux_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	// specificity is resolved per owner class: an exact rule shared for all users doesn't override a pattern
+	// of the user and actions of both rules are granted
+	query = "?account=baz&service=container_registry&scope=repository:team-c/public:pull,push,delete"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls = registryMock.TokenCalls()
+	assert.Equal(t, []string{"pull", "push", "delete"}, calls[len(calls)-1].AuthRequest.Scopes[0].Actions)
+
+	query = "?account=baz&service=container_registry&scope=repository:test_resource_3:pull&scope=repository"
+	requestWithCredentials(ctx, t, "baz", "baz_password", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusBadRequest)
+}
+
+func TestRegistryHandlers_tokenAuthRobot(t *testing.T) {
+	testRegistryHandlers := registryHandlers{}
+	testRegistryHandlers.l = log.Default()
+
+	registryMock := prepareRegistryMock(t)
+	registryMock.TokenFunc = func(authRequest registry.TokenRequest) (string, error) { return "ok", nil }
+	registryMock.LoginFunc = func(user store.User) (string, error) { return "ok", nil }
+	testRegistryHandlers.registryService = registryMock
+
+	robotStore := prepareRobotMock()
+	testRegistryHandlers.dataStore = robotStore
+	ctx := context.Background()
+
+	robots := []store.Robot{
+		{Name: "ci-builder", Secret: "ci_secret", Permissions: []store.RobotPermission{
+			{Type: store.RepositoryResourceType, ResourceName: "team-a/*", Actions: []string{"pull", "push"}},
+		}},
+		{Name: "disabled", Secret: "disabled_secret", Disabled: true, Permissions: []store.RobotPermission{
+			{Type: store.RepositoryResourceType, ResourceName: "team-a/*", Actions: []string{"pull"}},
+		}},
+		{Name: "expired", Secret: "expired_secret", ExpiresAt: time.Now().Add(-time.Minute).Unix(), Permissions: []store.RobotPermission{
+			{Type: store.RepositoryResourceType, ResourceName: "team-a/*", Actions: []string{"pull"}},
+		}},
+	}
+	for i := range robots {
+		require.NoError(t, robotStore.CreateRobot(ctx, &robots[i]))
+	}
+
+	// robot receives actions granted by own permissions only
+	query := "?account=robot$ci-builder&service=container_registry&scope=repository:team-a/app:pull,push,delete"
+	requestWithCredentials(ctx, t, "robot$ci-builder", "ci_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	calls := registryMock.TokenCalls()
+	require.NotEmpty(t, calls)
+	assert.Equal(t, "robot$ci-builder", calls[len(calls)-1].AuthRequest.Account)
+	assert.Equal(t, []registry.ResourceScope{
+		{Type: "repository", Name: "team-a/app", Actions: []string{"pull", "push"}},
+	}, calls[len(calls)-1].AuthRequest.Scopes)
+
+	query = "?account=robot$ci-builder&service=container_registry&scope=repository:team-b/app:pull"
+	requestWithCredentials(ctx, t, "robot$ci-builder", "ci_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	// account should match the robot login
+	query = "?account=ci-builder&service=container_registry&scope=repository:team-a/app:pull"
+	requestWithCredentials(ctx, t, "robot$ci-builder", "ci_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusBadRequest)
+
+	// robot has the own identity, the account check isn't skipped like for anonymous users
+	query = "?service=container_registry&scope=repository:team-a/app:pull"
+	requestWithCredentials(ctx, t, "robot$ci-builder", "ci_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusBadRequest)
+	account, _ := robotAccount(store.Robot{Name: "ci-builder"})
+	assert.Equal(t, engine.RobotUserID, account.ID)
+
+	// docker login with robot credentials
+	query = "?account=robot$ci-builder&client_id=docker&offline_token=true&service=container_registry"
+	requestWithCredentials(ctx, t, "robot$ci-builder", "ci_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusOK)
+
+	// wrong secret, disabled, expired and unknown robots are denied
+	query = "?account=robot$ci-builder&service=container_registry&scope=repository:team-a/app:pull"
+	requestWithCredentials(ctx, t, "robot$ci-builder", "wrong_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	query = "?account=robot$disabled&service=container_registry&scope=repository:team-a/app:pull"
+	requestWithCredentials(ctx, t, "robot$disabled", "disabled_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	query = "?account=robot$expired&service=container_registry&scope=repository:team-a/app:pull"
+	requestWithCredentials(ctx, t, "robot$expired", "expired_secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+
+	query = "?account=robot$unknown&service=container_registry&scope=repository:team-a/app:pull"
+	requestWithCredentials(ctx, t, "robot$unknown", "secret", "GET", "/api/v1/registry/auth"+query, testRegistryHandlers.tokenAuth, nil, http.StatusForbidden)
+}
+
 func TestRegistryHandlers_health(t *testing.T) {
 	testRegistryHandlers := registryHandlers{}
 	testRegistryHandlers.l = log.Default()
@@ -263,6 +519,41 @@ func TestRegistryHandlers_syncRepositories(t *testing.T) {
 	requestWithCredentials(ctx, t, "bar", "bar_password", "GET", "/api/v1/registry/events", testRegistryHandlers.syncRepositories, nil, http.StatusInternalServerError)
 }
 
+func TestRegistryHandlers_signingKeys(t *testing.T) {
+	staged := false
+	registryMock := &registryInterfaceMock{
+		SigningKeysFunc: func() ([]registry.SigningKey, error) {
+			return []registry.SigningKey{{KeyID: "AAAA:BBBB", Status: registry.KeyStatusActive}}, nil
+		},
+		StageSigningKeyFunc: func() (registry.SigningKey, error) {
+			if staged {
+				return registry.SigningKey{}, errors.Wrap(registry.ErrKeyRotation, "a key is staged already")
+			}
+			staged = true
+			return registry.SigningKey{KeyID: "CCCC:DDDD", Status: registry.KeyStatusStaged}, nil
+		},
+		SwitchSigningKeyFunc: func() (registry.SigningKey, error) {
+			return registry.SigningKey{}, registry.ErrTokenAuthRequired
+		},
+		RetireSigningKeyFunc: func() (registry.SigningKey, error) {
+			return registry.SigningKey{}, errors.New("failed to save CA bundle")
+		},
+	}
+
+	testRegistryHandlers := registryHandlers{registryService: registryMock}
+	testRegistryHandlers.l = log.Default()
+	ctx := context.Background()
+
+	resp := requestWithCredentials(ctx, t, "admin", "admin_password", "GET", "/api/v1/registry/keys", testRegistryHandlers.signingKeysList, nil, http.StatusOK)
+	assert.Contains(t, resp.Body.String(), `"kid":"AAAA:BBBB"`)
+
+	resp = requestWithCredentials(ctx, t, "admin", "admin_password", "POST", "/api/v1/registry/keys/stage", testRegistryHandlers.signingKeyStage, nil, http.StatusOK)
+	assert.Contains(t, resp.Body.String(), `"status":"staged"`)
+	requestWithCredentials(ctx, t, "admin", "admin_password", "POST", "/api/v1/registry/keys/stage", testRegistryHandlers.signingKeyStage, nil, http.StatusConflict)
+	requestWithCredentials(ctx, t, "admin", "admin_password", "POST", "/api/v1/registry/keys/switch", testRegistryHandlers.signingKeySwitch, nil, http.StatusBadRequest)
+	requestWithCredentials(ctx, t, "admin", "admin_password", "POST", "/api/v1/registry/keys/retire", testRegistryHandlers.signingKeyRetire, nil, http.StatusInternalServerError)
+}
+
 func TestRegistryHandlers_imageConfig(t *testing.T) {
 	testRegistryHandlers := registryHandlers{}
 	testRegistryHandlers.l = log.Default()
@@ -482,6 +773,137 @@ func filledTestEntries(t *testing.T, testRegistryHandlers *registryHandlers) {
 			ResourceName: "test_resource_6",
 			Action:       "pull",
 		},
+		{
+			Name:         "test_access_7",
+			Owner:        2,
+			IsGroup:      true,
+			Type:         "repository",
+			ResourceName: "test_resource_7",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_8",
+			Owner:        3,
+			IsGroup:      true,
+			Type:         "repository",
+			ResourceName: "test_resource_8",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_9",
+			Owner:        4,
+			IsGroup:      true,
+			Type:         "repository",
+			ResourceName: "test_resource_9",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_10",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-a/**",
+			Action:       "push",
+		},
+		{
+			Name:         "test_access_11",
+			Owner:        2,
+			IsGroup:      true,
+			Type:         "repository",
+			ResourceName: "team-a/*",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_shared",
+			Owner:        engine.RegisteredUserID,
+			Type:         "repository",
+			ResourceName: "team-a/shared/app",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_12",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-a/readonly",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_13",
+			Owner:        engine.RegisteredUserID,
+			Type:         "registry",
+			ResourceName: "catalog",
+			Action:       "*",
+		},
+		{
+			Name:         "test_access_14",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-c/*",
+			Action:       "*",
+		},
+		{
+			Name:         "test_access_15",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-d/app",
+			Action:       "pull",
+			NotBefore:    time.Now().Add(-time.Hour).Unix(),
+			ExpiresAt:    time.Now().Add(time.Hour).Unix(),
+		},
+		{
+			Name:         "test_access_16",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-d/app",
+			Action:       "push",
+			ExpiresAt:    time.Now().Add(-time.Minute).Unix(),
+		},
+		{
+			Name:         "test_access_17",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-d/app",
+			Action:       "delete",
+			NotBefore:    time.Now().Add(time.Hour).Unix(),
+		},
+		{
+			Name:         "test_access_18",
+			Owner:        2,
+			IsGroup:      true,
+			Type:         "repository",
+			ResourceName: "team-a/secrets",
+			Action:       "pull",
+			Effect:       store.AccessEffectDeny,
+		},
+		{
+			Name:         "test_access_19",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "team-c/internal",
+			Action:       "push",
+			Effect:       store.AccessEffectDeny,
+		},
+		{
+			Name:         "test_access_20",
+			Owner:        engine.RegisteredUserID,
+			Type:         "repository",
+			ResourceName: "shared/*",
+			Action:       "pull",
+		},
+		{
+			Name:         "test_access_21",
+			Owner:        3,
+			Type:         "repository",
+			ResourceName: "shared/**",
+			Action:       "*",
+			Effect:       store.AccessEffectDeny,
+		},
+		{
+			Name:         "test_access_22",
+			Owner:        engine.RegisteredUserID,
+			Type:         "repository",
+			ResourceName: "team-c/public",
+			Action:       "pull",
+		},
 	}
 
 	ctx := context.Background()
@@ -571,6 +993,11 @@ func prepareAccessStoreMock(t *testing.T) *engine.InterfaceMock {
 	var testUsersIndex, testAccessIndex int64
 
 	return &engine.InterfaceMock{
+		GetRoleFunc: getBuiltInRole,
+		WithTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		},
+
 		// ----------------------- USERS mock--------------------------------------
 		CreateUserFunc: func(ctx context.Context, user *store.User) error {
 
@@ -597,6 +1024,24 @@ func prepareAccessStoreMock(t *testing.T) *engine.InterfaceMock {
 			return store.User{}, fmt.Errorf("user with id [%d] not found", id)
 		},
 
+		FindGroupMembersFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+			testListResponse := engine.ListResponse{}
+			userID := filter.Filters["user_id"].(int64)
+
+			// a user is always a member of the primary group
+			members := testGroupMembers
+			if user, ok := testUsers[userID]; ok {
+				members = append([]store.GroupMember{{GroupID: user.Group, UserID: user.ID}}, members...)
+			}
+			for _, m := range members {
+				if m.UserID == userID {
+					testListResponse.Total++
+					testListResponse.Data = append(testListResponse.Data, m)
+				}
+			}
+			return testListResponse, nil
+		},
+
 		// ----------------------- ACCESSES mock--------------------------------------
 
 		CreateAccessFunc: func(ctx context.Context, access *store.Access) error {
@@ -617,9 +1062,9 @@ func prepareAccessStoreMock(t *testing.T) *engine.InterfaceMock {
 			// fetch by ids and filter values
 			for _, val := range testAccesses {
 				if val.Type == filter.Filters["resource_type"].(string) &&
-					val.ResourceName == filter.Filters["resource_name"].(string) &&
-					val.Action == filter.Filters["action"].([]string)[0] &&
-					val.Owner == filter.Filters["owner_id"].(int64) {
+					val.Disabled == filter.Filters["disabled"].(bool) &&
+					val.Owner == filter.Filters["owner_id"].(int64) &&
+					val.IsGroup == filter.Filters["is_group"].(bool) {
 
 					testListResponse.Total++
 					testListResponse.Data = append(testListResponse.Data, val)
@@ -44,6 +44,18 @@ var _ registryInterface = &registryInterfaceMock{}
 // 			ParseAuthenticateHeaderRequestFunc: func(headerValue string) (registry.TokenRequest, error) {
 // 				panic("mock out the ParseAuthenticateHeaderRequest method")
 // 			},
+// 			RetireSigningKeyFunc: func() (registry.SigningKey, error) {
+// 				panic("mock out the RetireSigningKey method")
+// 			},
+// 			SigningKeysFunc: func() ([]registry.SigningKey, error) {
+// 				panic("mock out the SigningKeys method")
+// 			},
+// 			StageSigningKeyFunc: func() (registry.SigningKey, error) {
+// 				panic("mock out the StageSigningKey method")
+// 			},
+// 			SwitchSigningKeyFunc: func() (registry.SigningKey, error) {
+// 				panic("mock out the SwitchSigningKey method")
+// 			},
 // 			TokenFunc: func(authRequest registry.TokenRequest) (string, error) {
 // 				panic("mock out the Token method")
 // 			},
@@ -81,6 +93,18 @@ type registryInterfaceMock struct {
 	// ParseAuthenticateHeaderRequestFunc mocks the ParseAuthenticateHeaderRequest method.
 	ParseAuthenticateHeaderRequestFunc func(headerValue string) (registry.TokenRequest, error)
 
+	// RetireSigningKeyFunc mocks the RetireSigningKey method.
+	RetireSigningKeyFunc func() (registry.SigningKey, error)
+
+	// SigningKeysFunc mocks the SigningKeys method.
+	SigningKeysFunc func() ([]registry.SigningKey, error)
+
+	// StageSigningKeyFunc mocks the StageSigningKey method.
+	StageSigningKeyFunc func() (registry.SigningKey, error)
+
+	// SwitchSigningKeyFunc mocks the SwitchSigningKey method.
+	SwitchSigningKeyFunc func() (registry.SigningKey, error)
+
 	// TokenFunc mocks the Token method.
 	TokenFunc func(authRequest registry.TokenRequest) (string, error)
 
@@ -151,6 +175,18 @@ type registryInterfaceMock struct {
 			// HeaderValue is the headerValue argument value.
 			HeaderValue string
 		}
+		// RetireSigningKey holds details about calls to the RetireSigningKey method.
+		RetireSigningKey []struct {
+		}
+		// SigningKeys holds details about calls to the SigningKeys method.
+		SigningKeys []struct {
+		}
+		// StageSigningKey holds details about calls to the StageSigningKey method.
+		StageSigningKey []struct {
+		}
+		// SwitchSigningKey holds details about calls to the SwitchSigningKey method.
+		SwitchSigningKey []struct {
+		}
 		// Token holds details about calls to the Token method.
 		Token []struct {
 			// AuthRequest is the authRequest argument value.
@@ -170,6 +206,10 @@ type registryInterfaceMock struct {
 	lockLogin                          sync.RWMutex
 	lockManifest                       sync.RWMutex
 	lockParseAuthenticateHeaderRequest sync.RWMutex
+	lockRetireSigningKey               sync.RWMutex
+	lockSigningKeys                    sync.RWMutex
+	lockStageSigningKey                sync.RWMutex
+	lockSwitchSigningKey               sync.RWMutex
 	lockToken                          sync.RWMutex
 	lockUpdateHtpasswd                 sync.RWMutex
 }
@@ -466,6 +506,110 @@ func (mock *registryInterfaceMock) ParseAuthenticateHeaderRequestCalls() []struc
 	return calls
 }
 
+// RetireSigningKey calls RetireSigningKeyFunc.
+func (mock *registryInterfaceMock) RetireSigningKey() (registry.SigningKey, error) {
+	if mock.RetireSigningKeyFunc == nil {
+		panic("registryInterfaceMock.RetireSigningKeyFunc: method is nil but registryInterface.RetireSigningKey was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockRetireSigningKey.Lock()
+	mock.calls.RetireSigningKey = append(mock.calls.RetireSigningKey, callInfo)
+	mock.lockRetireSigningKey.Unlock()
+	return mock.RetireSigningKeyFunc()
+}
+
+// RetireSigningKeyCalls gets all the calls that were made to RetireSigningKey.
+// Check the length with:
+//     len(mockedregistryInterface.RetireSigningKeyCalls())
+func (mock *registryInterfaceMock) RetireSigningKeyCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockRetireSigningKey.RLock()
+	calls = mock.calls.RetireSigningKey
+	mock.lockRetireSigningKey.RUnlock()
+	return calls
+}
+
+// SigningKeys calls SigningKeysFunc.
+func (mock *registryInterfaceMock) SigningKeys() ([]registry.SigningKey, error) {
+	if mock.SigningKeysFunc == nil {
+		panic("registryInterfaceMock.SigningKeysFunc: method is nil but registryInterface.SigningKeys was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockSigningKeys.Lock()
+	mock.calls.SigningKeys = append(mock.calls.SigningKeys, callInfo)
+	mock.lockSigningKeys.Unlock()
+	return mock.SigningKeysFunc()
+}
+
+// SigningKeysCalls gets all the calls that were made to SigningKeys.
+// Check the length with:
+//     len(mockedregistryInterface.SigningKeysCalls())
+func (mock *registryInterfaceMock) SigningKeysCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockSigningKeys.RLock()
+	calls = mock.calls.SigningKeys
+	mock.lockSigningKeys.RUnlock()
+	return calls
+}
+
+// StageSigningKey calls StageSigningKeyFunc.
+func (mock *registryInterfaceMock) StageSigningKey() (registry.SigningKey, error) {
+	if mock.StageSigningKeyFunc == nil {
+		panic("registryInterfaceMock.StageSigningKeyFunc: method is nil but registryInterface.StageSigningKey was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockStageSigningKey.Lock()
+	mock.calls.StageSigningKey = append(mock.calls.StageSigningKey, callInfo)
+	mock.lockStageSigningKey.Unlock()
+	return mock.StageSigningKeyFunc()
+}
+
+// StageSigningKeyCalls gets all the calls that were made to StageSigningKey.
+// Check the length with:
+//     len(mockedregistryInterface.StageSigningKeyCalls())
+func (mock *registryInterfaceMock) StageSigningKeyCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockStageSigningKey.RLock()
+	calls = mock.calls.StageSigningKey
+	mock.lockStageSigningKey.RUnlock()
+	return calls
+}
+
+// SwitchSigningKey calls SwitchSigningKeyFunc.
+func (mock *registryInterfaceMock) SwitchSigningKey() (registry.SigningKey, error) {
+	if mock.SwitchSigningKeyFunc == nil {
+		panic("registryInterfaceMock.SwitchSigningKeyFunc: method is nil but registryInterface.SwitchSigningKey was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockSwitchSigningKey.Lock()
+	mock.calls.SwitchSigningKey = append(mock.calls.SwitchSigningKey, callInfo)
+	mock.lockSwitchSigningKey.Unlock()
+	return mock.SwitchSigningKeyFunc()
+}
+
+// SwitchSigningKeyCalls gets all the calls that were made to SwitchSigningKey.
+// Check the length with:
+//     len(mockedregistryInterface.SwitchSigningKeyCalls())
+func (mock *registryInterfaceMock) SwitchSigningKeyCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockSwitchSigningKey.RLock()
+	calls = mock.calls.SwitchSigningKey
+	mock.lockSwitchSigningKey.RUnlock()
+	return calls
+}
+
 // Token calls TokenFunc.
 func (mock *registryInterfaceMock) Token(authRequest registry.TokenRequest) (string, error) {
 	if mock.TokenFunc == nil {
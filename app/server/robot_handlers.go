@@ -0,0 +1,282 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	R "github.com/go-pkgz/rest"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// pull secret formats which robotPullSecretCtrl renders
+const (
+	pullSecretDockerConfig = "dockerconfigjson"
+	pullSecretKubernetes   = "kubernetes"
+)
+
+// robotHandlers implement controllers which allow manipulation with robot accounts using REST API endpoints
+type robotHandlers struct {
+	endpointsHandler
+	registryAddress string // host[:port] of registry which is written to pull secrets by default
+}
+
+// robotPullSecretRequest contains params of pull secret, the robot secret is required because storage keeps hash of it only
+type robotPullSecretRequest struct {
+	Secret    string `json:"secret"`
+	Format    string `json:"format"`    // 'dockerconfigjson' (default) or 'kubernetes'
+	Registry  string `json:"registry"`  // overrides the registry address
+	Name      string `json:"name"`      // name of kubernetes secret
+	Namespace string `json:"namespace"` // namespace of kubernetes secret
+}
+
+// dockerConfig is a content of docker config.json with registry credentials
+type dockerConfig struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// kubernetesSecret is a manifest of kubernetes secret which is used as imagePullSecret
+type kubernetesSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   kubernetesMeta    `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type kubernetesMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// robotCreateCtrl creates a robot account with generated secret, a plain secret value is returned in response only once
+func (rb *robotHandlers) robotCreateCtrl(w http.ResponseWriter, r *http.Request) {
+	robot := store.Robot{}
+	if err := json.NewDecoder(r.Body).Decode(&robot); err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "failed to parse robot data for create with api")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := robot.Validate(); err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "invalid robot data")
+		return
+	}
+
+	if robot.IsExpired(time.Now()) {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, errors.New("expiry date is in the past"), "invalid robot data")
+		return
+	}
+
+	secret, err := store.NewRobotSecret()
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusInternalServerError, err, "failed to generate robot secret")
+		return
+	}
+
+	robot.ID, robot.CreatedAt, robot.Secret = 0, 0, secret
+	if err = rb.dataStore.CreateRobot(r.Context(), &robot); err != nil {
+		SendErrorJSON(w, r, rb.l, storeErrorStatus(err), err, "failed create robot with api")
+		return
+	}
+
+	robot.Secret = secret
+	R.RenderJSON(w, responseMessage{Error: false, Message: "robot created", ID: robot.ID, Data: robot})
+}
+
+func (rb *robotHandlers) robotInfoCtrl(w http.ResponseWriter, r *http.Request) {
+	robot, ok := rb.robotFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	robot.Secret = ""
+	R.RenderJSON(w, responseMessage{
+		Error: false,
+		ID:    robot.ID,
+		Data:  robot,
+	})
+}
+
+func (rb *robotHandlers) robotFindCtrl(w http.ResponseWriter, r *http.Request) {
+	filter, err := engine.FilterFromURLExtractor(r.URL)
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusInternalServerError, err, "failed to parse URL parameters for make query filter")
+		return
+	}
+
+	result, err := rb.dataStore.FindRobots(r.Context(), filter, false)
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, queryErrorStatus(err), err, "failed to find robots")
+		return
+	}
+
+	w.Header().Add("Content-Range", fmt.Sprintf("robots %d-%d/%d", filter.Range[0], filter.Range[1], result.Total))
+	R.RenderJSON(w, result)
+}
+
+// robotUpdateCtrl updates robot data, the secret can't be changed with it, robotSecretCtrl regenerates one
+func (rb *robotHandlers) robotUpdateCtrl(w http.ResponseWriter, r *http.Request) {
+	robot, ok := rb.robotFromURL(w, r)
+	if !ok {
+		return
+	}
+	id, createdAt := robot.ID, robot.CreatedAt
+
+	if err := json.NewDecoder(r.Body).Decode(&robot); err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "failed to decode robot data for update with api")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	robot.ID, robot.CreatedAt, robot.Secret = id, createdAt, ""
+	if err := robot.Validate(); err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "invalid robot data")
+		return
+	}
+
+	if err := rb.dataStore.UpdateRobot(r.Context(), robot); err != nil {
+		SendErrorJSON(w, r, rb.l, storeErrorStatus(err), err, "failed to update robot data with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{
+		Error: false,
+		ID:    robot.ID,
+		Data:  robot,
+	})
+}
+
+func (rb *robotHandlers) robotDeleteCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "failed to parse robot id with api")
+		return
+	}
+
+	if err = rb.dataStore.DeleteRobot(r.Context(), id); err != nil {
+		SendErrorJSON(w, r, rb.l, storeErrorStatus(err), err, "failed to delete robot with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "robot deleted"})
+}
+
+// robotSecretCtrl generates a new secret of robot, the previous secret stops working immediately
+func (rb *robotHandlers) robotSecretCtrl(w http.ResponseWriter, r *http.Request) {
+	robot, ok := rb.robotFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := store.NewRobotSecret()
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusInternalServerError, err, "failed to generate robot secret")
+		return
+	}
+
+	robot.Secret = secret
+	if err = rb.dataStore.UpdateRobot(r.Context(), robot); err != nil {
+		SendErrorJSON(w, r, rb.l, storeErrorStatus(err), err, "failed to update robot secret with api")
+		return
+	}
+
+	robot.Secret = secret
+	R.RenderJSON(w, responseMessage{Error: false, Message: "robot secret regenerated", ID: robot.ID, Data: robot})
+}
+
+// robotPullSecretCtrl renders robot credentials as a docker config.json or a kubernetes imagePullSecret manifest
+func (rb *robotHandlers) robotPullSecretCtrl(w http.ResponseWriter, r *http.Request) {
+	robot, ok := rb.robotFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var params robotPullSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "failed to parse pull secret params")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if !store.ComparePassword(robot.Secret, params.Secret) {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, errors.New("secret doesn't match"), "invalid robot secret")
+		return
+	}
+
+	if params.Registry == "" {
+		params.Registry = rb.registryAddress
+	}
+	if params.Registry == "" {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, errors.New("registry address undefined"), "registry address required")
+		return
+	}
+
+	config, err := json.Marshal(dockerConfig{Auths: map[string]dockerConfigAuth{
+		params.Registry: {
+			Username: robot.Login(),
+			Password: params.Secret,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(robot.Login() + ":" + params.Secret)),
+		},
+	}})
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusInternalServerError, err, "failed to make docker config")
+		return
+	}
+
+	switch params.Format {
+	case "", pullSecretDockerConfig:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(config)
+	case pullSecretKubernetes:
+		if params.Name == "" {
+			params.Name = strings.ToLower(strings.ReplaceAll(robot.Name, "_", "-")) + "-pull-secret"
+		}
+
+		manifest, errMarshal := yaml.Marshal(kubernetesSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   kubernetesMeta{Name: params.Name, Namespace: params.Namespace},
+			Type:       "kubernetes.io/dockerconfigjson",
+			Data:       map[string]string{".dockerconfigjson": base64.StdEncoding.EncodeToString(config)},
+		})
+		if errMarshal != nil {
+			SendErrorJSON(w, r, rb.l, http.StatusInternalServerError, errMarshal, "failed to make kubernetes secret")
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		_, _ = w.Write(manifest)
+	default:
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, errors.Errorf("unknown format %q", params.Format), "invalid pull secret format")
+	}
+}
+
+// robotFromURL returns robot by id from URL, it sends an error response when the robot can't be fetched
+func (rb *robotHandlers) robotFromURL(w http.ResponseWriter, r *http.Request) (store.Robot, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, http.StatusBadRequest, err, "failed to parse robot id with api")
+		return store.Robot{}, false
+	}
+
+	robot, err := rb.dataStore.GetRobot(r.Context(), id)
+	if err != nil {
+		SendErrorJSON(w, r, rb.l, storeErrorStatus(err), err, "failed to get robot with api")
+		return store.Robot{}, false
+	}
+	return robot, true
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_robotCreateCtrl(t *testing.T) {
+	testRobotHandlers := robotHandlers{}
+	testRobotHandlers.dataStore = prepareRobotMock()
+
+	robot := store.Robot{Name: "ci-builder", Permissions: []store.RobotPermission{
+		{Type: store.RepositoryResourceType, ResourceName: "team-a/*", Actions: []string{"pull", "push"}},
+	}}
+	robotData, err := json.Marshal(robot)
+	require.NoError(t, err)
+
+	// a plain secret is returned once on create, storage keeps hash of it
+	testWriter := request(t, "POST", "/api/v1/robots", testRobotHandlers.robotCreateCtrl, robotData, http.StatusOK)
+	var created struct {
+		ID   int64       `json:"id"`
+		Data store.Robot `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&created))
+	assert.Equal(t, int64(1), created.ID)
+	assert.Len(t, created.Data.Secret, 64)
+
+	stored, err := testRobotHandlers.dataStore.GetRobot(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.True(t, store.ComparePassword(stored.Secret, created.Data.Secret))
+
+	request(t, "POST", "/api/v1/robots", testRobotHandlers.robotCreateCtrl, robotData, http.StatusConflict)
+
+	robot.Name = "ci$builder"
+	robotData, err = json.Marshal(robot)
+	require.NoError(t, err)
+	request(t, "POST", "/api/v1/robots", testRobotHandlers.robotCreateCtrl, robotData, http.StatusBadRequest)
+
+	robot.Name = "deployer"
+	robot.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	robotData, err = json.Marshal(robot)
+	require.NoError(t, err)
+	request(t, "POST", "/api/v1/robots", testRobotHandlers.robotCreateCtrl, robotData, http.StatusBadRequest)
+
+	request(t, "POST", "/api/v1/robots", testRobotHandlers.robotCreateCtrl, nil, http.StatusBadRequest)
+}
+
+func Test_robotInfoAndFindCtrl(t *testing.T) {
+	testRobotHandlers := robotHandlers{}
+	testRobotHandlers.dataStore = prepareRobotMock()
+	robot := store.Robot{Name: "ci-builder", Secret: "secret"}
+	require.NoError(t, testRobotHandlers.dataStore.CreateRobot(context.Background(), &robot))
+
+	testWriter := request(t, "GET", "/api/v1/robots/1", testRobotHandlers.robotInfoCtrl, nil, http.StatusOK)
+	var info struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&info))
+	assert.Equal(t, "ci-builder", info.Data["name"])
+	assert.NotContains(t, info.Data, "secret")
+
+	request(t, "GET", "/api/v1/robots/2", testRobotHandlers.robotInfoCtrl, nil, http.StatusNotFound)
+	request(t, "GET", "/api/v1/robots/abc", testRobotHandlers.robotInfoCtrl, nil, http.StatusBadRequest)
+
+	testWriter = request(t, "GET", "/api/v1/robots", testRobotHandlers.robotFindCtrl, nil, http.StatusOK)
+	assert.Equal(t, "robots 0-0/1", testWriter.Header().Get("Content-Range"))
+	var list engine.ListResponse
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&list))
+	require.Len(t, list.Data, 1)
+	assert.NotContains(t, list.Data[0], "secret")
+}
+
+func Test_robotUpdateCtrl(t *testing.T) {
+	testRobotHandlers := robotHandlers{}
+	testRobotHandlers.dataStore = prepareRobotMock()
+	robot := store.Robot{Name: "ci-builder", Secret: "secret"}
+	require.NoError(t, testRobotHandlers.dataStore.CreateRobot(context.Background(), &robot))
+
+	// the secret isn't changed with update
+	update := []byte(`{"description":"updated","disabled":true,"secret":"new_secret",` +
+		`"permissions":[{"type":"repository","resource_name":"team-a/app","actions":["pull"]}]}`)
+	request(t, "PUT", "/api/v1/robots/1", testRobotHandlers.robotUpdateCtrl, update, http.StatusOK)
+
+	stored, err := testRobotHandlers.dataStore.GetRobot(context.Background(), int64(1))
+	require.NoError(t, err)
+	assert.Equal(t, "updated", stored.Description)
+	assert.True(t, stored.Disabled)
+	assert.Equal(t, robot.CreatedAt, stored.CreatedAt)
+	assert.Len(t, stored.Permissions, 1)
+	assert.True(t, store.ComparePassword(stored.Secret, "secret"))
+
+	update = []byte(`{"permissions":[{"type":"repository","resource_name":"team-a/app","actions":["pull:push"]}]}`)
+	request(t, "PUT", "/api/v1/robots/1", testRobotHandlers.robotUpdateCtrl, update, http.StatusBadRequest)
+	request(t, "PUT", "/api/v1/robots/2", testRobotHandlers.robotUpdateCtrl, []byte(`{}`), http.StatusNotFound)
+	request(t, "PUT", "/api/v1/robots/1", testRobotHandlers.robotUpdateCtrl, []byte(`{`), http.StatusBadRequest)
+}
+
+func Test_robotDeleteCtrl(t *testing.T) {
+	testRobotHandlers := robotHandlers{}
+	testRobotHandlers.dataStore = prepareRobotMock()
+	require.NoError(t, testRobotHandlers.dataStore.CreateRobot(context.Background(), &store.Robot{Name: "ci-builder", Secret: "secret"}))
+
+	request(t, "DELETE", "/api/v1/robots/1", testRobotHandlers.robotDeleteCtrl, nil, http.StatusOK)
+	request(t, "DELETE", "/api/v1/robots/1", testRobotHandlers.robotDeleteCtrl, nil, http.StatusNotFound)
+	request(t, "DELETE", "/api/v1/robots/abc", testRobotHandlers.robotDeleteCtrl, nil, http.StatusBadRequest)
+}
+
+func Test_robotSecretCtrl(t *testing.T) {
+	testRobotHandlers := robotHandlers{}
+	testRobotHandlers.dataStore = prepareRobotMock()
+	require.NoError(t, testRobotHandlers.dataStore.CreateRobot(context.Background(), &store.Robot{Name: "ci-builder", Secret: "secret"}))
+
+	testWriter := request(t, "POST", "/api/v1/robots/1/secret", testRobotHandlers.robotSecretCtrl, nil, http.StatusOK)
+	var response struct {
+		Data store.Robot `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&response))
+	assert.NotEmpty(t, response.Data.Secret)
+
+	stored, err := testRobotHandlers.dataStore.GetRobot(context.Background(), int64(1))
+	require.NoError(t, err)
+	assert.False(t, store.ComparePassword(stored.Secret, "secret"))
+	assert.True(t, store.ComparePassword(stored.Secret, response.Data.Secret))
+
+	request(t, "POST", "/api/v1/robots/2/secret", testRobotHandlers.robotSecretCtrl, nil, http.StatusNotFound)
+}
+
+func Test_robotPullSecretCtrl(t *testing.T) {
+	testRobotHandlers := robotHandlers{registryAddress: "registry.example.com:5000"}
+	testRobotHandlers.dataStore = prepareRobotMock()
+	require.NoError(t, testRobotHandlers.dataStore.CreateRobot(context.Background(), &store.Robot{Name: "CI_builder", Secret: "secret"}))
+
+	expectedAuth := base64.StdEncoding.EncodeToString([]byte("robot$CI_builder:secret"))
+
+	// docker config.json format is used by default
+	testWriter := request(t, "POST", "/api/v1/robots/1/pull-secret", testRobotHandlers.robotPullSecretCtrl,
+		[]byte(`{"secret":"secret"}`), http.StatusOK)
+	var config dockerConfig
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&config))
+	assert.Equal(t, dockerConfig{Auths: map[string]dockerConfigAuth{
+		"registry.example.com:5000": {Username: "robot$CI_builder", Password: "secret", Auth: expectedAuth},
+	}}, config)
+
+	testWriter = request(t, "POST", "/api/v1/robots/1/pull-secret", testRobotHandlers.robotPullSecretCtrl,
+		[]byte(`{"secret":"secret","format":"kubernetes","namespace":"ci","registry":"registry.local"}`), http.StatusOK)
+	assert.Equal(t, "application/yaml; charset=utf-8", testWriter.Header().Get("Content-Type"))
+	var manifest kubernetesSecret
+	require.NoError(t, yaml.NewDecoder(testWriter.Body).Decode(&manifest))
+	assert.Equal(t, "Secret", manifest.Kind)
+	assert.Equal(t, "kubernetes.io/dockerconfigjson", manifest.Type)
+	assert.Equal(t, kubernetesMeta{Name: "ci-builder-pull-secret", Namespace: "ci"}, manifest.Metadata)
+
+	configData, err := base64.StdEncoding.DecodeString(manifest.Data[".dockerconfigjson"])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(configData, &config))
+	assert.Equal(t, expectedAuth, config.Auths["registry.local"].Auth)
+
+	request(t, "POST", "/api/v1/robots/1/pull-secret", testRobotHandlers.robotPullSecretCtrl,
+		[]byte(`{"secret":"wrong"}`), http.StatusBadRequest)
+	request(t, "POST", "/api/v1/robots/1/pull-secret", testRobotHandlers.robotPullSecretCtrl,
+		[]byte(`{"secret":"secret","format":"unknown"}`), http.StatusBadRequest)
+	request(t, "POST", "/api/v1/robots/2/pull-secret", testRobotHandlers.robotPullSecretCtrl,
+		[]byte(`{"secret":"secret"}`), http.StatusNotFound)
+
+	testRobotHandlers.registryAddress = ""
+	request(t, "POST", "/api/v1/robots/1/pull-secret", testRobotHandlers.robotPullSecretCtrl,
+		[]byte(`{"secret":"secret"}`), http.StatusBadRequest)
+}
+
+func prepareRobotMock() *engine.InterfaceMock {
+	testRobotStorage := make(map[int64]store.Robot)
+	var testRobotIndex int64
+
+	return &engine.InterfaceMock{
+		GetRoleFunc: getBuiltInRole,
+		CreateRobotFunc: func(ctx context.Context, robot *store.Robot) error {
+			for _, r := range testRobotStorage {
+				if r.Name == robot.Name {
+					return fmt.Errorf("robot %q: %w", robot.Name, engine.ErrDuplicateEntry)
+				}
+			}
+			if err := robot.HashSecret(); err != nil {
+				return err
+			}
+			testRobotIndex++
+			robot.ID, robot.CreatedAt = testRobotIndex, time.Now().Unix()
+			testRobotStorage[robot.ID] = *robot
+			return nil
+		},
+
+		GetRobotFunc: func(ctx context.Context, id interface{}) (store.Robot, error) {
+			for _, r := range testRobotStorage {
+				if r.ID == id || r.Name == id {
+					return r, nil
+				}
+			}
+			return store.Robot{}, fmt.Errorf("robot %v: %w", id, engine.ErrNotFound)
+		},
+
+		FindRobotsFunc: func(ctx context.Context, filter engine.QueryFilter, withSecret bool) (engine.ListResponse, error) {
+			result := engine.ListResponse{Data: []interface{}{}}
+			for _, r := range testRobotStorage {
+				if !withSecret {
+					r.Secret = ""
+				}
+				result.Total++
+				result.Data = append(result.Data, r)
+			}
+			return result, nil
+		},
+
+		UpdateRobotFunc: func(ctx context.Context, robot store.Robot) error {
+			current, ok := testRobotStorage[robot.ID]
+			if !ok {
+				return fmt.Errorf("robot %d: %w", robot.ID, engine.ErrNotFound)
+			}
+			if robot.Secret == "" {
+				robot.Secret = current.Secret
+			} else if err := robot.HashSecret(); err != nil {
+				return err
+			}
+			testRobotStorage[robot.ID] = robot
+			return nil
+		},
+
+		DeleteRobotFunc: func(ctx context.Context, id int64) error {
+			if _, ok := testRobotStorage[id]; !ok {
+				return engine.ErrNotFound
+			}
+			delete(testRobotStorage, id)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-pkgz/auth/token"
+	R "github.com/go-pkgz/rest"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// permissionsAttr is a token claims attribute which contains permissions of the user role, UI shows allowed sections by it
+const permissionsAttr = "permissions"
+
+// roleHandlers implement controllers which allow manipulation with roles using REST API endpoints
+type roleHandlers struct {
+	endpointsHandler
+}
+
+// permissionRequired allows requests of users which role grants the permission, it's used after auth middleware
+func (eh *endpointsHandler) permissionRequired(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := token.GetUserInfo(r)
+			if err != nil {
+				SendErrorJSON(w, r, eh.l, http.StatusUnauthorized, err, "authorization required")
+				return
+			}
+
+			permitted, err := rolePermits(r.Context(), eh.dataStore, user.GetRole(), permission)
+			if err != nil {
+				SendErrorJSON(w, r, eh.l, http.StatusInternalServerError, err, "failed to check user permissions")
+				return
+			}
+
+			if !permitted {
+				SendErrorJSON(w, r, eh.l, http.StatusForbidden, errors.Errorf("permission %q required", permission), "access denied")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rolePermits checks that the role grants the permission, a role which doesn't exist grants nothing
+func rolePermits(ctx context.Context, dataStore engine.Interface, roleName, permission string) (bool, error) {
+	permissions, err := rolePermissions(ctx, dataStore, roleName)
+	if err != nil {
+		return false, err
+	}
+	role := store.Role{Permissions: permissions}
+	return role.HasPermission(permission), nil
+}
+
+// rolePermissions returns permissions which the role grants, a role which doesn't exist grants nothing
+func rolePermissions(ctx context.Context, dataStore engine.Interface, roleName string) ([]string, error) {
+	if roleName == "" {
+		return []string{}, nil
+	}
+
+	role, err := dataStore.GetRole(ctx, roleName)
+	if errors.Is(err, engine.ErrNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get role %q", roleName)
+	}
+	return role.Permissions, nil
+}
+
+// permissionsGranted checks that the role of the request user grants all the permissions, so users can't make roles
+// or assign them to users with more rights than they have. An error is sent to the client when it returns false.
+func (eh *endpointsHandler) permissionsGranted(w http.ResponseWriter, r *http.Request, permissions []string) bool {
+	user, err := token.GetUserInfo(r)
+	if err != nil {
+		SendErrorJSON(w, r, eh.l, http.StatusUnauthorized, err, "authorization required")
+		return false
+	}
+
+	granted, err := rolePermissions(r.Context(), eh.dataStore, user.GetRole())
+	if err != nil {
+		SendErrorJSON(w, r, eh.l, http.StatusInternalServerError, err, "failed to check user permissions")
+		return false
+	}
+
+	callerRole := store.Role{Permissions: granted}
+	for _, permission := range permissions {
+		if !callerRole.HasPermission(permission) {
+			SendErrorJSON(w, r, eh.l, http.StatusForbidden, errors.Errorf("permission %q isn't granted to role %q", permission,
+				user.GetRole()), "permissions of the role exceed permissions of the current user")
+			return false
+		}
+	}
+	return true
+}
+
+// rolesGranted checks that the role of the request user grants all permissions of the roles
+func (eh *endpointsHandler) rolesGranted(w http.ResponseWriter, r *http.Request, roleNames ...string) bool {
+	var permissions []string
+	for _, name := range roleNames {
+		p, err := rolePermissions(r.Context(), eh.dataStore, name)
+		if err != nil {
+			SendErrorJSON(w, r, eh.l, http.StatusInternalServerError, err, "failed to get permissions of role")
+			return false
+		}
+		permissions = append(permissions, p...)
+	}
+	return eh.permissionsGranted(w, r, permissions)
+}
+
+// roleCreateCtrl creates a custom role, built-in roles exist in every storage and can't be created with api
+func (rh *roleHandlers) roleCreateCtrl(w http.ResponseWriter, r *http.Request) {
+	role := store.Role{}
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusBadRequest, err, "failed to parse role data for create with api")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	role.ID, role.BuiltIn = 0, false
+	if err := role.Validate(); err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusBadRequest, err, "invalid role data")
+		return
+	}
+
+	if !rh.permissionsGranted(w, r, role.Permissions) {
+		return
+	}
+
+	if err := rh.dataStore.CreateRole(r.Context(), &role); err != nil {
+		SendErrorJSON(w, r, rh.l, storeErrorStatus(err), err, "failed create role with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Error: false, Message: "role created", ID: role.ID, Data: role})
+}
+
+func (rh *roleHandlers) roleInfoCtrl(w http.ResponseWriter, r *http.Request) {
+	role, ok := rh.roleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{
+		Error: false,
+		ID:    role.ID,
+		Data:  role,
+	})
+}
+
+func (rh *roleHandlers) roleFindCtrl(w http.ResponseWriter, r *http.Request) {
+	filter, err := engine.FilterFromURLExtractor(r.URL)
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusInternalServerError, err, "failed to parse URL parameters for make query filter")
+		return
+	}
+
+	result, err := rh.dataStore.FindRoles(r.Context(), filter)
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, queryErrorStatus(err), err, "failed to find roles")
+		return
+	}
+
+	w.Header().Add("Content-Range", fmt.Sprintf("roles %d-%d/%d", filter.Range[0], filter.Range[1], result.Total))
+	R.RenderJSON(w, result)
+}
+
+// rolePermissionsCtrl returns all permissions which roles can grant
+func (rh *roleHandlers) rolePermissionsCtrl(w http.ResponseWriter, _ *http.Request) {
+	R.RenderJSON(w, responseMessage{Error: false, Data: store.Permissions()})
+}
+
+// roleUpdateCtrl updates description and permissions of a custom role, the role name can't be changed
+// because users refer to roles by name
+func (rh *roleHandlers) roleUpdateCtrl(w http.ResponseWriter, r *http.Request) {
+	role, ok := rh.customRoleFromURL(w, r)
+	if !ok {
+		return
+	}
+	id, name := role.ID, role.Name
+
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusBadRequest, err, "failed to decode role data for update with api")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	role.ID, role.Name, role.BuiltIn = id, name, false
+	if err := role.Validate(); err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusBadRequest, err, "invalid role data")
+		return
+	}
+
+	if !rh.permissionsGranted(w, r, role.Permissions) {
+		return
+	}
+
+	if err := rh.dataStore.UpdateRole(r.Context(), role); err != nil {
+		SendErrorJSON(w, r, rh.l, storeErrorStatus(err), err, "failed to update role data with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{
+		Error: false,
+		ID:    role.ID,
+		Data:  role,
+	})
+}
+
+// roleDeleteCtrl deletes a custom role which isn't assigned to any user
+func (rh *roleHandlers) roleDeleteCtrl(w http.ResponseWriter, r *http.Request) {
+	role, ok := rh.customRoleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	users, err := rh.dataStore.FindUsers(r.Context(), engine.QueryFilter{Filters: map[string]interface{}{"role": role.Name}}, false)
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusInternalServerError, err, "failed to find users of role")
+		return
+	}
+
+	if users.Total > 0 {
+		SendErrorJSON(w, r, rh.l, http.StatusConflict, errors.Errorf("role %q assigned to %d users", role.Name, users.Total),
+			"role assigned to users can't be deleted")
+		return
+	}
+
+	if err = rh.dataStore.DeleteRole(r.Context(), role.ID); err != nil {
+		SendErrorJSON(w, r, rh.l, storeErrorStatus(err), err, "failed to delete role with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "role deleted"})
+}
+
+func (rh *roleHandlers) roleFromURL(w http.ResponseWriter, r *http.Request) (store.Role, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, http.StatusBadRequest, err, "failed to parse role id with api")
+		return store.Role{}, false
+	}
+
+	role, err := rh.dataStore.GetRole(r.Context(), id)
+	if err != nil {
+		SendErrorJSON(w, r, rh.l, storeErrorStatus(err), err, "failed to get role with api")
+		return store.Role{}, false
+	}
+	return role, true
+}
+
+// customRoleFromURL returns a role which can be changed, built-in roles are refused
+func (rh *roleHandlers) customRoleFromURL(w http.ResponseWriter, r *http.Request) (store.Role, bool) {
+	role, ok := rh.roleFromURL(w, r)
+	if !ok {
+		return store.Role{}, false
+	}
+
+	if role.BuiltIn {
+		SendErrorJSON(w, r, rh.l, http.StatusForbidden, errors.Errorf("role %q is built-in", role.Name),
+			"built-in role can't be changed")
+		return store.Role{}, false
+	}
+	return role, true
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-pkgz/auth/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func Test_permissionRequired(t *testing.T) {
+	eh := endpointsHandler{dataStore: prepareRoleMock()}
+	require.NoError(t, eh.dataStore.CreateRole(context.Background(),
+		&store.Role{Name: "auditor", Permissions: []string{store.PermissionUsersRead}}))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	testCases := []struct {
+		user       *token.User
+		permission string
+		expected   int
+	}{
+		{user: &token.User{Name: "admin", Role: store.AdminRole}, permission: store.PermissionUsersWrite, expected: http.StatusOK},
+		{user: &token.User{Name: "manager", Role: store.ManagerRole}, permission: store.PermissionUsersRead, expected: http.StatusOK},
+		{user: &token.User{Name: "manager", Role: store.ManagerRole}, permission: store.PermissionUsersWrite, expected: http.StatusForbidden},
+		{user: &token.User{Name: "user", Role: store.UserRole}, permission: store.PermissionRegistryRead, expected: http.StatusForbidden},
+		{user: &token.User{Name: "audit", Role: "auditor"}, permission: store.PermissionUsersRead, expected: http.StatusOK},
+		{user: &token.User{Name: "audit", Role: "auditor"}, permission: store.PermissionAccessRead, expected: http.StatusForbidden},
+		{user: &token.User{Name: "unknown", Role: "deleted"}, permission: store.PermissionUsersRead, expected: http.StatusForbidden},
+		{user: nil, permission: store.PermissionUsersRead, expected: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/api/v1/users", http.NoBody)
+		if tc.user != nil {
+			req = token.SetUserInfo(req, *tc.user)
+		}
+		testWriter := httptest.NewRecorder()
+		eh.permissionRequired(tc.permission)(next).ServeHTTP(testWriter, req)
+		assert.Equal(t, tc.expected, testWriter.Code, "user %+v, permission %s", tc.user, tc.permission)
+	}
+}
+
+func Test_roleCreateCtrl(t *testing.T) {
+	testRoleHandlers := roleHandlers{}
+	testRoleHandlers.dataStore = prepareRoleMock()
+
+	// built-in flag can't be set with api
+	roleData := []byte(`{"name":"auditor","description":"test role","built_in":true,"permissions":["users:read","access:read"]}`)
+	testWriter := request(t, "POST", "/api/v1/roles", asAdmin(testRoleHandlers.roleCreateCtrl), roleData, http.StatusOK)
+	var created struct {
+		ID   int64      `json:"id"`
+		Data store.Role `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&created))
+	assert.Equal(t, store.Role{ID: created.ID, Name: "auditor", Description: "test role",
+		Permissions: []string{store.PermissionUsersRead, store.PermissionAccessRead}}, created.Data)
+
+	request(t, "POST", "/api/v1/roles", asAdmin(testRoleHandlers.roleCreateCtrl), roleData, http.StatusConflict)
+	request(t, "POST", "/api/v1/roles", asAdmin(testRoleHandlers.roleCreateCtrl), []byte(`{"name":"admin"}`), http.StatusConflict)
+	request(t, "POST", "/api/v1/roles", asAdmin(testRoleHandlers.roleCreateCtrl), []byte(`{"name":"bad name"}`), http.StatusBadRequest)
+	request(t, "POST", "/api/v1/roles", asAdmin(testRoleHandlers.roleCreateCtrl),
+		[]byte(`{"name":"writer","permissions":["users:delete"]}`), http.StatusBadRequest)
+	request(t, "POST", "/api/v1/roles", asAdmin(testRoleHandlers.roleCreateCtrl), nil, http.StatusBadRequest)
+
+	// a role can't grant permissions which the current user doesn't have
+	manager := token.User{Name: "manager", Role: store.ManagerRole}
+	request(t, "POST", "/api/v1/roles", asUser(manager, testRoleHandlers.roleCreateCtrl),
+		[]byte(`{"name":"writer","permissions":["users:read","users:write"]}`), http.StatusForbidden)
+	request(t, "POST", "/api/v1/roles", asUser(manager, testRoleHandlers.roleCreateCtrl),
+		[]byte(`{"name":"reader","permissions":["users:read"]}`), http.StatusOK)
+	request(t, "POST", "/api/v1/roles", testRoleHandlers.roleCreateCtrl,
+		[]byte(`{"name":"guest","permissions":[]}`), http.StatusUnauthorized)
+}
+
+func Test_roleInfoAndFindCtrl(t *testing.T) {
+	testRoleHandlers := roleHandlers{}
+	testRoleHandlers.dataStore = prepareRoleMock()
+
+	testWriter := request(t, "GET", "/api/v1/roles/1", testRoleHandlers.roleInfoCtrl, nil, http.StatusOK)
+	var info struct {
+		Data store.Role `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&info))
+	assert.Equal(t, store.AdminRole, info.Data.Name)
+	assert.True(t, info.Data.BuiltIn)
+
+	request(t, "GET", "/api/v1/roles/100", testRoleHandlers.roleInfoCtrl, nil, http.StatusNotFound)
+	request(t, "GET", "/api/v1/roles/abc", testRoleHandlers.roleInfoCtrl, nil, http.StatusBadRequest)
+
+	testWriter = request(t, "GET", "/api/v1/roles", testRoleHandlers.roleFindCtrl, nil, http.StatusOK)
+	assert.Equal(t, "roles 0-0/3", testWriter.Header().Get("Content-Range"))
+	var list engine.ListResponse
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&list))
+	assert.Len(t, list.Data, 3)
+
+	testWriter = request(t, "GET", "/api/v1/roles/permissions", testRoleHandlers.rolePermissionsCtrl, nil, http.StatusOK)
+	var permissions struct {
+		Data []string `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(testWriter.Body).Decode(&permissions))
+	assert.Equal(t, store.Permissions(), permissions.Data)
+}
+
+func Test_roleUpdateCtrl(t *testing.T) {
+	testRoleHandlers := roleHandlers{}
+	testRoleHandlers.dataStore = prepareRoleMock()
+	role := store.Role{Name: "auditor", Permissions: []string{store.PermissionUsersRead}}
+	require.NoError(t, testRoleHandlers.dataStore.CreateRole(context.Background(), &role))
+	url := fmt.Sprintf("/api/v1/roles/%d", role.ID)
+
+	// the role name can't be changed
+	update := []byte(`{"name":"renamed","description":"updated","permissions":["registry:read"]}`)
+	request(t, "PUT", url, asAdmin(testRoleHandlers.roleUpdateCtrl), update, http.StatusOK)
+
+	stored, err := testRoleHandlers.dataStore.GetRole(context.Background(), role.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.Role{ID: role.ID, Name: "auditor", Description: "updated",
+		Permissions: []string{store.PermissionRegistryRead}}, stored)
+
+	request(t, "PUT", url, asAdmin(testRoleHandlers.roleUpdateCtrl), []byte(`{"permissions":["registry:write"]}`), http.StatusBadRequest)
+	request(t, "PUT", url, asUser(token.User{Name: "manager", Role: store.ManagerRole}, testRoleHandlers.roleUpdateCtrl),
+		[]byte(`{"permissions":["registry:delete"]}`), http.StatusForbidden)
+	request(t, "PUT", url, asAdmin(testRoleHandlers.roleUpdateCtrl), []byte(`{`), http.StatusBadRequest)
+	request(t, "PUT", "/api/v1/roles/100", asAdmin(testRoleHandlers.roleUpdateCtrl), []byte(`{}`), http.StatusNotFound)
+
+	// built-in roles are read only
+	request(t, "PUT", "/api/v1/roles/2", asAdmin(testRoleHandlers.roleUpdateCtrl), []byte(`{"permissions":[]}`), http.StatusForbidden)
+}
+
+func Test_roleDeleteCtrl(t *testing.T) {
+	testRoleHandlers := roleHandlers{}
+	dataStore := prepareRoleMock()
+	testRoleHandlers.dataStore = dataStore
+
+	roles := []store.Role{{Name: "auditor"}, {Name: "deployer"}}
+	for i := range roles {
+		require.NoError(t, dataStore.CreateRole(context.Background(), &roles[i]))
+	}
+
+	dataStore.FindUsersFunc = func(ctx context.Context, filter engine.QueryFilter, withPassword bool) (engine.ListResponse, error) {
+		if filter.Filters["role"] == "deployer" {
+			return engine.ListResponse{Total: 1, Data: []interface{}{store.User{ID: 1, Login: "deployer", Role: "deployer"}}}, nil
+		}
+		return engine.ListResponse{Data: []interface{}{}}, nil
+	}
+
+	request(t, "DELETE", fmt.Sprintf("/api/v1/roles/%d", roles[0].ID), testRoleHandlers.roleDeleteCtrl, nil, http.StatusOK)
+	request(t, "DELETE", fmt.Sprintf("/api/v1/roles/%d", roles[0].ID), testRoleHandlers.roleDeleteCtrl, nil, http.StatusNotFound)
+
+	// roles of users and built-in roles can't be deleted
+	request(t, "DELETE", fmt.Sprintf("/api/v1/roles/%d", roles[1].ID), testRoleHandlers.roleDeleteCtrl, nil, http.StatusConflict)
+	request(t, "DELETE", "/api/v1/roles/3", testRoleHandlers.roleDeleteCtrl, nil, http.StatusForbidden)
+	request(t, "DELETE", "/api/v1/roles/abc", testRoleHandlers.roleDeleteCtrl, nil, http.StatusBadRequest)
+}
+
+// getBuiltInRole is GetRole of storage mocks which have built-in roles only
+func getBuiltInRole(_ context.Context, id interface{}) (store.Role, error) {
+	for _, role := range store.BuiltInRoles() {
+		if role.Name == id {
+			return role, nil
+		}
+	}
+	return store.Role{}, fmt.Errorf("role %w", engine.ErrNotFound)
+}
+
+// prepareRoleMock returns a storage mock which has built-in roles with IDs 1-3
+func prepareRoleMock() *engine.InterfaceMock {
+	testRoleStorage := make(map[int64]store.Role)
+	var testRoleIndex int64
+	for _, role := range store.BuiltInRoles() {
+		testRoleIndex++
+		role.ID = testRoleIndex
+		testRoleStorage[role.ID] = role
+	}
+
+	return &engine.InterfaceMock{
+		CreateRoleFunc: func(ctx context.Context, role *store.Role) error {
+			for _, r := range testRoleStorage {
+				if r.Name == role.Name {
+					return fmt.Errorf("role %q: %w", role.Name, engine.ErrDuplicateEntry)
+				}
+			}
+			testRoleIndex++
+			role.ID = testRoleIndex
+			testRoleStorage[role.ID] = *role
+			return nil
+		},
+
+		GetRoleFunc: func(ctx context.Context, id interface{}) (store.Role, error) {
+			for _, r := range testRoleStorage {
+				if r.ID == id || r.Name == id {
+					return r, nil
+				}
+			}
+			return store.Role{}, fmt.Errorf("role %v: %w", id, engine.ErrNotFound)
+		},
+
+		FindRolesFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+			result := engine.ListResponse{Data: []interface{}{}}
+			for _, r := range testRoleStorage {
+				result.Total++
+				result.Data = append(result.Data, r)
+			}
+			return result, nil
+		},
+
+		UpdateRoleFunc: func(ctx context.Context, role store.Role) error {
+			current, ok := testRoleStorage[role.ID]
+			if !ok {
+				return fmt.Errorf("role %d: %w", role.ID, engine.ErrNotFound)
+			}
+			current.Description, current.Permissions = role.Description, role.Permissions
+			testRoleStorage[role.ID] = current
+			return nil
+		},
+
+		DeleteRoleFunc: func(ctx context.Context, id int64) error {
+			if _, ok := testRoleStorage[id]; !ok {
+				return engine.ErrNotFound
+			}
+			delete(testRoleStorage, id)
+			return nil
+		},
+	}
+}
@@ -43,6 +43,14 @@ type Server struct {
 	Storage                  engine.Interface  // main storage instance interface
 	RegistryService          registryInterface // main instance for connection to registry service
 	GarbageCollectorInterval int64
+	RegistryAddress          string                 // registry host[:port] which clients use for pull images, e.g. in robot pull secrets
+	SSOProviders             []SSOProvider          // external identity providers for single sign-on to the portal
+	Directory                directoryAuthenticator // external directory which is checked for credentials before the store, e.g. LDAP
+	TOTPIssuer               string                 // issuer name which authenticator apps show for one-time passwords
+	TOTPRequiredRoles        []string               // roles which users have to use two-factor authentication for portal login
+	Lockout                  LockoutPolicy          // thresholds of failed login attempts, logins aren't locked when undefined
+	PasswordPolicy           *store.PasswordPolicy  // requirements to passwords which are set with API
+	RefreshTokenTTL          time.Duration          // lifetime of refresh tokens which registry clients get instead of credentials
 	WebContentFS             *embed.FS
 
 	ctx         context.Context
@@ -93,6 +101,18 @@ type registryInterface interface {
 	// DeleteTag will deleteDigest the manifest identified by name and reference. Note that a manifest can only be deleted
 	// by digest.
 	DeleteTag(ctx context.Context, repoName, digest string) error
+
+	// SigningKeys returns keys of token signature which are published in the CA bundle
+	SigningKeys() ([]registry.SigningKey, error)
+
+	// StageSigningKey generates a new key of token signature and publishes it in the CA bundle
+	StageSigningKey() (registry.SigningKey, error)
+
+	// SwitchSigningKey makes the staged key active for signing of new tokens
+	SwitchSigningKey() (registry.SigningKey, error)
+
+	// RetireSigningKey removes the previous key of token signature from the CA bundle
+	RetireSigningKey() (registry.SigningKey, error)
 }
 
 // responseMessage is the uniform response message pattern for various frontend framework like react-admin and other
@@ -222,9 +242,13 @@ func (s *Server) routes() chi.Router {
 	authHandler, _ := s.Authenticator.Handlers()
 	authMiddleware := s.Authenticator.Middleware()
 
+	// failed logins are counted by the responses of endpoints which check credentials
+	guard := newLoginGuard(s.Lockout)
+
 	router.Group(func(r chi.Router) {
 		r.Use(middleware.Timeout(5 * time.Second))
 		r.Use(tollbooth_chi.LimitHandler(tollbooth.NewLimiter(10, nil)), middleware.NoCache)
+		r.Use(guard.middleware(http.StatusForbidden, directLoginName))
 		r.Mount("/auth", authHandler)
 	})
 
@@ -236,16 +260,43 @@ func (s *Server) routes() chi.Router {
 		ctx:           s.ctx,
 	}
 
-	// main endpoints routes
+	// this route expose api for manipulation with Registry service entries
+	rh := registryHandlers{
+		endpointsHandler: eh,
+		registryService:  s.RegistryService,
+		directory:        s.Directory,
+		refreshTokenTTL:  s.RefreshTokenTTL,
+		dataService: &service.DataService{
+			Registry: s.RegistryService,
+			Storage:  s.Storage,
+		},
+	}
+
+	// main endpoints routes share the same rate limiter
+	apiLimiter := tollbooth.NewLimiter(10, nil)
 	router.Route("/api/v1", func(rootApi chi.Router) {
+
+		// registry token endpoints check credentials themselves and have own guards, they are outside of the root route
+		// guard, so every login is checked and counted once. The GET endpoint denies scopes with the same status
+		// as credentials, so it marks failed logins itself.
+		rootApi.Group(func(routeToken chi.Router) {
+			routeToken.Use(tollbooth_chi.LimitHandler(apiLimiter))
+			routeToken.Use(authMiddleware.Trace, middleware.NoCache)
+			routeToken.With(guard.middleware(0, basicAuthLogin)).Get("/registry/auth", rh.tokenAuth)
+			routeToken.With(guard.middleware(http.StatusUnauthorized, oauthLogin)).Post("/registry/auth", rh.tokenOAuth)
+		})
+
 		rootApi.Group(func(rootRoute chi.Router) {
-			rootRoute.Use(tollbooth_chi.LimitHandler(tollbooth.NewLimiter(10, nil)))
+			rootRoute.Use(tollbooth_chi.LimitHandler(apiLimiter))
 			rootRoute.Use(authMiddleware.Trace, middleware.NoCache)
+			rootRoute.Use(guard.middleware(http.StatusUnauthorized, basicAuthLogin))
 
 			uh := userHandlers{
 				endpointsHandler: eh,
 				registryService:  s.RegistryService,
 				userAdapter:      newUsersRegistryAdapter(s.ctx, engine.QueryFilter{}, s.Storage.FindUsers),
+				guard:            guard,
+				passwordPolicy:   s.PasswordPolicy,
 			}
 
 			// try to update users list in htpasswd from store if htpasswd is defined
@@ -256,18 +307,41 @@ func (s *Server) routes() chi.Router {
 			// this route expose api for manipulation with User entries
 			rootRoute.Route("/users", func(routeUser chi.Router) {
 				routeUser.Use(authMiddleware.Auth, middleware.NoCache)
-				routeUser.Use(authMiddleware.RBAC("admin", "manager"))
+				routeUser.Use(eh.permissionRequired(store.PermissionUsersRead))
 
 				routeUser.Get("/{id}", uh.userInfoCtrl)
 				routeUser.Get("/", uh.userFindCtrl)
+				routeUser.Get("/{id}/tokens", uh.userTokensFindCtrl)
 
-				// operation create/update/deleteDigest with User items allow for admin only
+				// operation create/update/delete with User items allow for roles with write permission only
 				routeUser.Group(func(routeAdminUser chi.Router) {
-					routeAdminUser.Use(authMiddleware.RBAC("admin"))
+					routeAdminUser.Use(eh.permissionRequired(store.PermissionUsersWrite))
 
 					routeAdminUser.Post("/", uh.userCreateCtrl)
 					routeAdminUser.Put("/{id}", uh.userUpdateCtrl)
 					routeAdminUser.Delete("/{id}", uh.userDeleteCtrl)
+					routeAdminUser.Delete("/{id}/totp", uh.userTOTPResetCtrl)
+					routeAdminUser.Delete("/{id}/lockout", uh.userUnlockCtrl)
+					routeAdminUser.Delete("/{id}/tokens", uh.userTokensRevokeCtrl)
+					routeAdminUser.Delete("/{id}/tokens/{token_id}", uh.userTokenRevokeCtrl)
+				})
+			})
+
+			// this route expose api for the second factor of portal login, it's available with a token of pending second factor
+			th := totpHandlers{endpointsHandler: eh, issuer: s.TOTPIssuer, requiredRoles: s.TOTPRequiredRoles,
+				ssoProviders: s.SSOProviders}
+			rootRoute.Route("/totp", func(routeTOTP chi.Router) {
+				routeTOTP.Use(th.totpAuth, middleware.NoCache)
+				routeTOTP.Get("/", th.totpStatusCtrl)
+				routeTOTP.Post("/enroll", th.totpEnrollCtrl)
+
+				// wrong codes are counted as failed logins
+				routeTOTP.Group(func(routeCode chi.Router) {
+					routeCode.Use(guard.middleware(http.StatusForbidden, totpSessionLogin))
+					routeCode.Post("/confirm", th.totpConfirmCtrl)
+					routeCode.Post("/verify", th.totpVerifyCtrl)
+					routeCode.Post("/recovery-codes", th.totpRecoveryCodesCtrl)
+					routeCode.Delete("/", th.totpDisableCtrl)
 				})
 			})
 
@@ -275,18 +349,21 @@ func (s *Server) routes() chi.Router {
 			gh := groupHandlers{eh}
 			rootRoute.Route("/groups", func(routeGroup chi.Router) {
 				routeGroup.Use(authMiddleware.Auth, middleware.NoCache)
-				routeGroup.Use(authMiddleware.RBAC("admin", "manager"))
+				routeGroup.Use(eh.permissionRequired(store.PermissionGroupsRead))
 
 				routeGroup.Get("/{id}", gh.groupInfoCtrl)
+				routeGroup.Get("/{id}/members", gh.groupMembersFindCtrl)
 				routeGroup.Get("/", gh.groupFindCtrl)
 
-				// operation create/update/deleteDigest with Group items allow for admins only
+				// operation create/update/delete with Group items allow for roles with write permission only
 				routeGroup.Group(func(routeAdminGroup chi.Router) {
-					routeAdminGroup.Use(authMiddleware.RBAC("admin"))
+					routeAdminGroup.Use(eh.permissionRequired(store.PermissionGroupsWrite))
 
 					routeAdminGroup.Post("/", gh.groupCreateCtrl)
 					routeAdminGroup.Put("/{id}", gh.groupUpdateCtrl)
 					routeAdminGroup.Delete("/{id}", gh.groupDeleteCtrl)
+					routeAdminGroup.Post("/{id}/members", gh.groupMemberAddCtrl)
+					routeAdminGroup.Delete("/{id}/members/{user_id}", gh.groupMemberDeleteCtrl)
 				})
 			})
 
@@ -294,37 +371,64 @@ func (s *Server) routes() chi.Router {
 			ah := accessHandlers{eh}
 			rootRoute.Route("/access", func(routeAccess chi.Router) {
 				routeAccess.Use(authMiddleware.Auth, middleware.NoCache)
-				routeAccess.Use(authMiddleware.RBAC("admin", "manager"))
+				routeAccess.Use(eh.permissionRequired(store.PermissionAccessRead))
 
 				routeAccess.Get("/{id}", ah.accessInfoCtrl)
 				routeAccess.Get("/", ah.accessFindCtrl)
 
-				// operation create/update/deleteDigest with Access items allow for admins only
+				// operation create/update/delete with Access items allow for roles with write permission only
 				routeAccess.Group(func(routeAdminAccess chi.Router) {
-					routeAdminAccess.Use(authMiddleware.RBAC("admin"))
+					routeAdminAccess.Use(eh.permissionRequired(store.PermissionAccessWrite))
 					routeAdminAccess.Post("/", ah.accessAddCtrl)
 					routeAdminAccess.Put("/{id}", ah.accessUpdateCtrl)
 					routeAdminAccess.Delete("/{id}", ah.accessDeleteCtrl)
 				})
 			})
 
-			// this route expose api for manipulation with Registry service entries
-			rh := registryHandlers{
-				endpointsHandler: eh,
-				registryService:  s.RegistryService,
-				dataService: &service.DataService{
-					Registry: s.RegistryService,
-					Storage:  s.Storage,
-				},
-			}
+			// this route expose api for manipulation with Robot accounts
+			rb := robotHandlers{endpointsHandler: eh, registryAddress: s.RegistryAddress}
+			rootRoute.Route("/robots", func(routeRobot chi.Router) {
+				routeRobot.Use(authMiddleware.Auth, middleware.NoCache)
+				routeRobot.Use(eh.permissionRequired(store.PermissionRobotsRead))
+
+				routeRobot.Get("/{id}", rb.robotInfoCtrl)
+				routeRobot.Get("/", rb.robotFindCtrl)
+
+				// operation create/update/delete with Robot items and issue of robot credentials allow for roles with write permission only
+				routeRobot.Group(func(routeAdminRobot chi.Router) {
+					routeAdminRobot.Use(eh.permissionRequired(store.PermissionRobotsWrite))
+					routeAdminRobot.Post("/", rb.robotCreateCtrl)
+					routeAdminRobot.Put("/{id}", rb.robotUpdateCtrl)
+					routeAdminRobot.Delete("/{id}", rb.robotDeleteCtrl)
+					routeAdminRobot.Post("/{id}/secret", rb.robotSecretCtrl)
+					routeAdminRobot.Post("/{id}/pull-secret", rb.robotPullSecretCtrl)
+				})
+			})
+
+			// this route expose api for manipulation with Roles, built-in roles are read only
+			roh := roleHandlers{eh}
+			rootRoute.Route("/roles", func(routeRole chi.Router) {
+				routeRole.Use(authMiddleware.Auth, middleware.NoCache)
+				routeRole.Use(eh.permissionRequired(store.PermissionRolesRead))
+
+				routeRole.Get("/permissions", roh.rolePermissionsCtrl)
+				routeRole.Get("/{id}", roh.roleInfoCtrl)
+				routeRole.Get("/", roh.roleFindCtrl)
+
+				// operation create/update/delete with Role items allow for roles with write permission only
+				routeRole.Group(func(routeAdminRole chi.Router) {
+					routeAdminRole.Use(eh.permissionRequired(store.PermissionRolesWrite))
+					routeAdminRole.Post("/", roh.roleCreateCtrl)
+					routeAdminRole.Put("/{id}", roh.roleUpdateCtrl)
+					routeAdminRole.Delete("/{id}", roh.roleDeleteCtrl)
+				})
+			})
 
 			// starting Data Service maintenance tasks such as garbage collector and repositories auto sync
 			rh.dataService.RepositoriesMaintenance(s.ctx, s.GarbageCollectorInterval)
 
 			// route API for manipulations registry entries (catalog/tags/manifest/deleteDigest)
 			rootRoute.Route("/registry", func(routeRegistry chi.Router) {
-				routeRegistry.Get("/auth", rh.tokenAuth)
-
 				routeRegistry.Group(func(registryApiEventsRegistry chi.Router) {
 					registryApiEventsRegistry.Use(authMiddleware.Auth, middleware.NoCache)
 					registryApiEventsRegistry.Post("/events", rh.events)
@@ -333,21 +437,31 @@ func (s *Server) routes() chi.Router {
 
 				routeRegistry.Group(func(routeApiRegistry chi.Router) {
 
-					// allows any users list repositories, but user role can see allowed repositories only
+					// allows any users list repositories, but users without 'registry:read' permission see allowed repositories only
 					routeApiRegistry.Use(authMiddleware.Auth, middleware.NoCache)
 					routeApiRegistry.Get("/catalog", rh.catalogList)
 				})
 
 				routeRegistry.Group(func(routeApiManagerRegistry chi.Router) {
 					routeApiManagerRegistry.Use(authMiddleware.Auth, middleware.NoCache)
-					routeApiManagerRegistry.Use(authMiddleware.RBAC("admin", "manager"))
+					routeApiManagerRegistry.Use(eh.permissionRequired(store.PermissionRegistryRead))
 					routeApiManagerRegistry.Get("/catalog/blobs", rh.imageConfig)
 				})
 
 				routeRegistry.Group(func(routeApiAdminRegistry chi.Router) {
-					routeApiAdminRegistry.Use(authMiddleware.RBAC("admin"))
-					routeApiAdminRegistry.Get("/sync", rh.syncRepositories)
-					routeApiAdminRegistry.Delete("/catalog/*", rh.deleteDigest)
+					routeApiAdminRegistry.Use(authMiddleware.Auth, middleware.NoCache)
+					routeApiAdminRegistry.With(eh.permissionRequired(store.PermissionRegistrySync)).Get("/sync", rh.syncRepositories)
+					routeApiAdminRegistry.With(eh.permissionRequired(store.PermissionRegistryDelete)).Delete("/catalog/*", rh.deleteDigest)
+				})
+
+				// signing keys of tokens are rotated by roles with keys permission only
+				routeRegistry.Route("/keys", func(routeKeys chi.Router) {
+					routeKeys.Use(authMiddleware.Auth, middleware.NoCache)
+					routeKeys.Use(eh.permissionRequired(store.PermissionRegistryKeys))
+					routeKeys.Get("/", rh.signingKeysList)
+					routeKeys.Post("/stage", rh.signingKeyStage)
+					routeKeys.Post("/switch", rh.signingKeySwitch)
+					routeKeys.Post("/retire", rh.signingKeyRetire)
 				})
 			})
 		})
@@ -423,6 +537,15 @@ func (s *Server) serveStaticWeb(r chi.Router, path, rootWebDir string) {
 // ClaimUpdateFn will either add or update token extra data with token claims it call when new token or refresh
 func (s *Server) ClaimUpdateFn(claims token.Claims) token.Claims {
 
+	// handshake claims of OAuth2 login don't have user yet
+	if claims.User == nil {
+		return claims
+	}
+
+	if p, ok := s.ssoProvider(claims.User.ID); ok {
+		return s.ssoClaimUpdate(p, claims)
+	}
+
 	u, err := s.Storage.GetUser(s.ctx, claims.User.Name)
 	if err != nil {
 		log.Printf("[ERROR] can't get user info from store %v", err)
@@ -436,28 +559,34 @@ func (s *Server) ClaimUpdateFn(claims token.Claims) token.Claims {
 
 	claims.User.SetBoolAttr("disabled", u.Disabled)
 	claims.User.Attributes["uid"] = u.ID
-	return claims
+	s.setPermissionsAttr(claims.User, u.Role)
+	return s.totpClaimUpdate(u, claims)
 }
 
-// BasicAuthCheckerFn will be checking credentials with basic authenticate method
-func (s *Server) BasicAuthCheckerFn(user, password string) (bool, token.User, error) {
-	claim := token.User{}
-
-	u, err := s.Storage.GetUser(s.ctx, user)
+// setPermissionsAttr adds permissions of the user role to token claims, UI shows sections which are allowed by them.
+// Endpoints check permissions of the role in storage, so changes of a role apply to issued tokens too.
+func (s *Server) setPermissionsAttr(user *token.User, role string) {
+	permissions, err := rolePermissions(s.ctx, s.Storage, role)
 	if err != nil {
-		log.Printf("[WARN] failed to check login credentials for user [%s]  err: %v", user, err)
-		return false, claim, nil
+		log.Printf("[ERROR] can't get permissions of role %q: %v", role, err)
+		permissions = []string{}
 	}
+	user.Attributes[permissionsAttr] = permissions
+}
 
-	if u.Disabled {
-		return false, claim, errors.Errorf("User with login '%s' disabled", user)
-	}
+// BasicAuthCheckerFn will be checking credentials with basic authenticate method. Users which use the second factor
+// can't authenticate with basic method because it doesn't pass a code.
+func (s *Server) BasicAuthCheckerFn(user, password string) (bool, token.User, error) {
+	claim := token.User{}
 
-	ok := store.ComparePassword(u.Password, password)
+	u, ok, err := s.checkCredentials(user, password)
+	if !ok || err != nil {
+		return false, claim, err
+	}
 
-	if !ok {
+	if u.TOTP.Enabled || totpRequired(s.TOTPRequiredRoles, u.Role) {
+		log.Printf("[WARN] basic authentication denied for user [%s] with two-factor authentication", user)
 		return false, claim, nil
-
 	}
 
 	claim.Name = u.Name
@@ -467,21 +596,49 @@ func (s *Server) BasicAuthCheckerFn(user, password string) (bool, token.User, er
 		claim.Attributes = make(map[string]interface{})
 	}
 	claim.Attributes["uid"] = u.ID
+	s.setPermissionsAttr(&claim, u.Role)
 	claim.ID = strconv.FormatInt(u.ID, 10)
 	return true, claim, nil
 }
 
 // Check will be checking user credentials with OAuth method
-// It's method pass when add auth local provider
+// It's method pass when add auth local provider, the second factor is checked after login with totp endpoints
 func (s *Server) Check(user, password string) (ok bool, err error) {
-	ok, _, err = s.BasicAuthCheckerFn(user, password)
+	_, ok, err = s.checkCredentials(user, password)
 	return ok, err
 }
 
+// checkCredentials checks login and password with the directory and the store
+func (s *Server) checkCredentials(user, password string) (store.User, bool, error) {
+	u, found, err := directoryUser(s.ctx, s.Directory, user, password)
+	if err != nil {
+		log.Printf("[WARN] directory denied login credentials for user [%s]  err: %v", user, err)
+		return u, false, nil
+	}
+
+	if !found {
+		if u, err = s.Storage.GetUser(s.ctx, user); err != nil {
+			log.Printf("[WARN] failed to check login credentials for user [%s]  err: %v", user, err)
+			return u, false, nil
+		}
+	}
+
+	if u.Disabled {
+		return u, false, errors.Errorf("User with login '%s' disabled", user)
+	}
+
+	// a password of directory user is checked by the directory already
+	if !found && !store.ComparePassword(u.Password, password) {
+		return u, false, nil
+	}
+	return u, true, nil
+}
+
 // Validate will validate token claims for OAuth provider
 func (s *Server) Validate(_ string, claims token.Claims) bool {
 	if claims.User == nil {
 		return false
 	}
-	return !claims.User.BoolAttr("disabled")
+	// a token of pending second factor allows access to totp endpoints only
+	return !claims.User.BoolAttr("disabled") && claims.User.StrAttr(totpClaimAttr) == ""
 }
@@ -226,6 +226,7 @@ func TestServer_ClaimUpdateFn(t *testing.T) {
 	extraClaims := srv.ClaimUpdateFn(baseClaims)
 	assert.Equal(t, extraClaims.Id, strUserID)
 	assert.Equal(t, extraClaims.User.Role, "user")
+	assert.Equal(t, []string{}, extraClaims.User.Attributes[permissionsAttr])
 
 	baseClaims.User = nil // reset User claims
 	baseClaims.Id = "-1"
@@ -566,6 +567,24 @@ func TestIntegrationUserOperationWithEmbeddedStore(t *testing.T) {
 			resp, errClient := client.Do(req)
 			require.NoError(t, errClient)
 			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			// manager can browse roles but can't change them
+			req, err = http.NewRequestWithContext(ctx, "GET", baseURL+"/roles", http.NoBody)
+			require.NoError(t, err)
+			addCookiesFn(req, cookies)
+
+			resp, errClient = client.Do(req)
+			require.NoError(t, errClient)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "roles 0-0/3", resp.Header.Get("Content-Range"))
+
+			req, err = http.NewRequestWithContext(ctx, "POST", baseURL+"/roles", bytes.NewBufferString(`{"name":"auditor"}`))
+			require.NoError(t, err)
+			addCookiesFn(req, cookies)
+
+			resp, errClient = client.Do(req)
+			require.NoError(t, errClient)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
 		}
 	}
 	ts.Shutdown()
@@ -604,9 +623,22 @@ func request(t *testing.T, method, url string, handler http.HandlerFunc, body []
 	return testWriter
 }
 
+// asUser calls the handler with the user of auth token, handlers are called without auth middleware in tests
+func asUser(user token.User, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, token.SetUserInfo(r, user))
+	}
+}
+
+// asAdmin calls the handler with a user of the admin role
+func asAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return asUser(token.User{Name: "admin", Role: store.AdminRole}, handler)
+}
+
 func prepareTestStorage(t *testing.T) *engine.InterfaceMock {
 
 	return &engine.InterfaceMock{
+		GetRoleFunc: getBuiltInRole,
 		GetUserFunc: func(ctx context.Context, id interface{}) (store.User, error) {
 
 			testUser := store.User{
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // used for hash of user ID only, the same as auth library does
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-pkgz/auth"
+	"github.com/go-pkgz/auth/provider"
+	"github.com/go-pkgz/auth/token"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// localProviderName is a name of direct provider which checks credentials of users with the store
+const localProviderName = "local"
+
+// SSOTypeOIDC is a type of generic OpenID Connect provider which endpoints are discovered from an issuer URL
+const SSOTypeOIDC = "oidc"
+
+// ssoBuiltinProviders are OAuth2 providers with well-known endpoints which are supported by the auth library
+var ssoBuiltinProviders = []string{"github", "google", "microsoft", "facebook", "yandex", "battlenet"}
+
+// ssoLoginClaims are claims which are tried in order for the login of user when a provider doesn't define own claim
+var ssoLoginClaims = []string{"preferred_username", "email", "sub"}
+
+// SSOProvider holds settings of an external identity provider which is used for single sign-on to the portal
+type SSOProvider struct {
+	Name          string            // provider name which is a part of auth URLs, e.g. /auth/{name}/login
+	Type          string            // 'oidc' or a name of builtin OAuth2 provider, e.g. 'github' or 'google'
+	Issuer        string            // OIDC issuer URL which endpoints are discovered with, 'oidc' type only
+	ClientID      string            // client ID which is registered at the provider
+	ClientSecret  string            // client secret which is registered at the provider
+	Scopes        []string          // extra scopes which are requested besides 'openid'
+	LoginClaim    string            // a claim which value is used as user login
+	RoleClaim     string            // a claim with a role or a list of groups, e.g. 'groups'
+	RoleMapping   map[string]string // maps values of RoleClaim to user roles
+	DefaultRole   string            // a role of provisioned users when none of claim values is mapped, login is denied when empty
+	AutoProvision bool              // create a user in the store on first login
+}
+
+// oidcConfiguration is a part of OpenID provider metadata which is required for authorization code flow
+type oidcConfiguration struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// AddSSOProviders registers single sign-on providers in the auth service, a generic OIDC provider fetches
+// its endpoints from the issuer, so the issuer should be reachable on start
+func (s *Server) AddSSOProviders(ctx context.Context, authService *auth.Service) error {
+	for i, p := range s.SSOProviders {
+		p := p
+		if err := p.validate(ctx, s.Storage); err != nil {
+			return errors.Wrapf(err, "invalid settings of sso provider %q", p.Name)
+		}
+
+		if p.Type != SSOTypeOIDC {
+			// builtin providers are registered with type name which is a part of user ID
+			s.SSOProviders[i].Name = p.Type
+			authService.AddProvider(p.Type, p.ClientID, p.ClientSecret)
+			log.Printf("[INFO] sso provider %q registered", p.Type)
+			continue
+		}
+
+		cfg, err := discoverOIDC(ctx, http.DefaultClient, p.Issuer)
+		if err != nil {
+			return errors.Wrapf(err, "failed to discover oidc provider %q", p.Name)
+		}
+
+		// the portal URL is the same which auth service is created with, callback URL of provider is based on it
+		params := provider.Params{
+			URL:        s.Hostname,
+			JwtService: authService.TokenService(),
+			Issuer:     authService.TokenService().Issuer,
+			Cid:        p.ClientID,
+			Csecret:    p.ClientSecret,
+			L:          log.Default(),
+		}
+		if proxy := authService.AvatarProxy(); proxy != nil {
+			params.AvatarSaver = proxy
+		}
+
+		// roles of claims are mapped with the store, so custom roles can be mapped too
+		mapUser := func(data provider.UserData, _ []byte) token.User { return p.mapUser(ctx, s.Storage, data) }
+		authService.AddCustomHandler(provider.NewCustom(p.Name, params, provider.CustomHandlerOpt{
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  cfg.AuthorizationEndpoint,
+				TokenURL: cfg.TokenEndpoint,
+			},
+			InfoURL:   cfg.UserinfoEndpoint,
+			MapUserFn: mapUser,
+			Scopes:    append([]string{"openid"}, p.Scopes...),
+		}))
+		log.Printf("[INFO] oidc provider %q registered with issuer %s", p.Name, p.Issuer)
+	}
+	return nil
+}
+
+// validate checks provider settings, roles which settings refer to should exist in the store, custom roles are allowed
+func (p SSOProvider) validate(ctx context.Context, dataStore engine.Interface) error {
+	if p.ClientID == "" || p.ClientSecret == "" {
+		return errors.New("client id and client secret required")
+	}
+
+	switch {
+	case p.Type == SSOTypeOIDC:
+		if p.Name == "" || p.Issuer == "" {
+			return errors.New("name and issuer required for oidc provider")
+		}
+		if p.Name == localProviderName || strings.ContainsAny(p.Name, "_/") {
+			return errors.Errorf("name %q is reserved or contains '_' or '/'", p.Name)
+		}
+	case !isBuiltinSSOProvider(p.Type):
+		return errors.Errorf("unknown provider type %q, allowed: %s, %s", p.Type, SSOTypeOIDC, strings.Join(ssoBuiltinProviders, ", "))
+	case p.Name != "" && p.Name != p.Type:
+		return errors.Errorf("name of builtin provider should be equal to type %q", p.Type)
+	}
+
+	if p.DefaultRole != "" {
+		if err := engine.CheckRoleExists(ctx, dataStore, p.DefaultRole); err != nil {
+			return errors.Wrap(err, "default role not allowed")
+		}
+	}
+	for value, role := range p.RoleMapping {
+		if err := engine.CheckRoleExists(ctx, dataStore, role); err != nil {
+			return errors.Wrapf(err, "role mapped to %q not allowed", value)
+		}
+	}
+	return nil
+}
+
+// mapUser makes user of token from OIDC userinfo claims, the role is set when one of role claim values is mapped.
+// The user ID consists of the provider name and a hash of the subject, it binds a provisioned user to the identity.
+func (p SSOProvider) mapUser(ctx context.Context, dataStore engine.Interface, data provider.UserData) token.User {
+	login := data.Value(p.LoginClaim)
+	for _, claim := range ssoLoginClaims {
+		if login != "" {
+			break
+		}
+		login = data.Value(claim)
+	}
+
+	user := token.User{
+		ID:      p.Name + "_" + token.HashID(sha1.New(), data.Value("sub")), //nolint:gosec // hash of ID only
+		Name:    login,
+		Email:   data.Value("email"),
+		Picture: data.Value("picture"),
+	}
+	user.SetRole(p.mapRole(ctx, dataStore, data))
+	return user
+}
+
+// mapRole returns the most privileged role which is mapped to one of the role claim values, a role which grants
+// more permissions is more privileged. Roles are got from the store, so custom roles can be mapped too.
+func (p SSOProvider) mapRole(ctx context.Context, dataStore engine.Interface, data provider.UserData) string {
+	if p.RoleClaim == "" {
+		return ""
+	}
+
+	var values []string
+	switch val := data[p.RoleClaim].(type) {
+	case string:
+		values = append(values, val)
+	case []interface{}:
+		for _, v := range val {
+			values = append(values, fmt.Sprintf("%v", v))
+		}
+	}
+
+	var mapped *store.Role
+	for _, v := range values {
+		name, ok := p.RoleMapping[v]
+		if !ok {
+			continue
+		}
+
+		role, err := dataStore.GetRole(ctx, name)
+		if err != nil {
+			log.Printf("[WARN] role %q mapped to %q by sso provider %q skipped: %v", name, v, p.Name, err)
+			continue
+		}
+
+		// roles which grant the same number of permissions are ordered by name to get the same result for any claims order
+		if mapped == nil || len(role.Permissions) > len(mapped.Permissions) ||
+			(len(role.Permissions) == len(mapped.Permissions) && role.Name < mapped.Name) {
+			mapped = &role
+		}
+	}
+
+	if mapped == nil {
+		return ""
+	}
+	return mapped.Name
+}
+
+// ssoProvider returns the provider which issued the user of token, user IDs of providers have a provider name prefix
+func (s *Server) ssoProvider(userID string) (SSOProvider, bool) {
+	return findSSOProvider(s.SSOProviders, userID)
+}
+
+func findSSOProvider(providers []SSOProvider, userID string) (SSOProvider, bool) {
+	for _, p := range providers {
+		name := p.Name
+		if name == "" {
+			name = p.Type
+		}
+		if strings.HasPrefix(userID, name+"_") {
+			return p, true
+		}
+	}
+	return SSOProvider{}, false
+}
+
+// ssoClaimUpdate resolves a store user of single sign-on login, the user is created on first login when provider
+// allows provisioning. The stored role and disabled flag always take precedence over a role mapped from claims.
+// A login is mapped only onto a user which was provisioned for the same identity of the same provider,
+// local users and users of other providers or identities with the same login are refused. Users of roles which
+// require the second factor pass it after single sign-on like users of password login.
+func (s *Server) ssoClaimUpdate(p SSOProvider, claims token.Claims) token.Claims {
+	u, err := s.ssoUser(claims.User.Name)
+	if errors.Is(err, engine.ErrNotFound) && p.AutoProvision {
+		u, err = s.provisionSSOUser(p, claims.User)
+	}
+	if err == nil && u.SSOID != claims.User.ID {
+		err = errors.Errorf("user %q isn't bound to the identity of provider", u.Login)
+	}
+
+	if claims.User.Attributes == nil {
+		claims.User.Attributes = make(map[string]interface{})
+	}
+
+	if err != nil {
+		log.Printf("[WARN] sso login of user %q with provider %q denied: %v", claims.User.Name, p.Name, err)
+		claims.User.SetRole("")
+		claims.User.SetBoolAttr("disabled", true)
+		return claims
+	}
+
+	claims.User.SetRole(u.Role)
+	claims.User.SetBoolAttr("disabled", u.Disabled)
+	claims.User.Attributes["uid"] = u.ID
+	s.setPermissionsAttr(claims.User, u.Role)
+	return s.totpClaimUpdate(u, claims)
+}
+
+// ssoUser returns a user by login, numeric logins are refused because store treats them as user ID
+func (s *Server) ssoUser(login string) (store.User, error) {
+	if login == "" {
+		return store.User{}, errors.New("login claim is empty")
+	}
+	if _, err := strconv.ParseInt(login, 10, 64); err == nil {
+		return store.User{}, errors.Errorf("numeric login %q not allowed", login)
+	}
+	return s.Storage.GetUser(s.ctx, login)
+}
+
+func (s *Server) provisionSSOUser(p SSOProvider, tokenUser *token.User) (store.User, error) {
+	role := tokenUser.Role
+	if role == "" {
+		role = p.DefaultRole
+	}
+	if role == "" {
+		return store.User{}, errors.New("none of user claims mapped to a role")
+	}
+
+	// SSO users don't know the password, it's random and only keeps the login with password disabled
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return store.User{}, errors.Wrap(err, "failed to generate password")
+	}
+
+	user := store.User{
+		Login:       tokenUser.Name,
+		Name:        tokenUser.Name,
+		Password:    hex.EncodeToString(password),
+		Role:        role,
+		Description: fmt.Sprintf("provisioned by %s single sign-on", p.Name),
+		SSOID:       tokenUser.ID,
+	}
+	if err := s.Storage.CreateUser(s.ctx, &user); err != nil {
+		return store.User{}, errors.Wrap(err, "failed to provision user")
+	}
+	log.Printf("[INFO] user %q provisioned with role %q by sso provider %q", user.Login, user.Role, p.Name)
+	return user, nil
+}
+
+// discoverOIDC fetches OpenID provider metadata from the well-known endpoint of issuer
+func discoverOIDC(ctx context.Context, client *http.Client, issuer string) (cfg oidcConfiguration, err error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", http.NoBody)
+	if err != nil {
+		return cfg, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return cfg, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return cfg, errors.Errorf("unexpected status code %d of discovery request", resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "failed to decode provider metadata")
+	}
+
+	if strings.TrimSuffix(cfg.Issuer, "/") != issuer {
+		return cfg, errors.Errorf("issuer %q of provider metadata doesn't match %q", cfg.Issuer, issuer)
+	}
+
+	if cfg.AuthorizationEndpoint == "" || cfg.TokenEndpoint == "" || cfg.UserinfoEndpoint == "" {
+		return cfg, errors.New("provider metadata doesn't contain required endpoints")
+	}
+	return cfg, nil
+}
+
+func isBuiltinSSOProvider(name string) bool {
+	for _, p := range ssoBuiltinProviders {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
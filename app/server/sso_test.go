@@ -0,0 +1,395 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-pkgz/auth"
+	"github.com/go-pkgz/auth/avatar"
+	"github.com/go-pkgz/auth/token"
+	log "github.com/go-pkgz/lgr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestServer_AddSSOProviders(t *testing.T) {
+	issuer := newTestOIDCIssuer(t, map[string]interface{}{"sub": "1"})
+
+	tbl := []struct {
+		provider SSOProvider
+		ok       bool
+	}{
+		{SSOProvider{Name: "corp", Type: SSOTypeOIDC, Issuer: issuer.URL, ClientID: "cid", ClientSecret: "secret"}, true},
+		{SSOProvider{Type: "github", ClientID: "cid", ClientSecret: "secret", DefaultRole: "user"}, true},
+		{SSOProvider{Name: "corp", Type: SSOTypeOIDC, Issuer: issuer.URL}, false},
+		{SSOProvider{Type: SSOTypeOIDC, Issuer: issuer.URL, ClientID: "cid", ClientSecret: "secret"}, false},
+		{SSOProvider{Name: "local", Type: SSOTypeOIDC, Issuer: issuer.URL, ClientID: "cid", ClientSecret: "secret"}, false},
+		{SSOProvider{Name: "my_corp", Type: SSOTypeOIDC, Issuer: issuer.URL, ClientID: "cid", ClientSecret: "secret"}, false},
+		{SSOProvider{Name: "corp", Type: SSOTypeOIDC, Issuer: issuer.URL + "/unknown", ClientID: "cid", ClientSecret: "secret"}, false},
+		{SSOProvider{Type: "unknown", ClientID: "cid", ClientSecret: "secret"}, false},
+		{SSOProvider{Name: "gh", Type: "github", ClientID: "cid", ClientSecret: "secret"}, false},
+		{SSOProvider{Type: "github", ClientID: "cid", ClientSecret: "secret", DefaultRole: "root"}, false},
+		{SSOProvider{Type: "github", ClientID: "cid", ClientSecret: "secret", RoleMapping: map[string]string{"ops": "root"}}, false},
+		{SSOProvider{Type: "github", ClientID: "cid", ClientSecret: "secret", DefaultRole: "ci-operator",
+			RoleMapping: map[string]string{"ops": "ci-operator"}}, true},
+	}
+
+	for i, tt := range tbl {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			srv := Server{Storage: prepareSSOUserMock(), SSOProviders: []SSOProvider{tt.provider}}
+			authService := auth.NewService(auth.Opts{
+				SecretReader: token.SecretFunc(func(string) (string, error) { return "test_secret", nil }),
+				URL:          "http://localhost",
+				Logger:       log.NoOp,
+			})
+			err := srv.AddSSOProviders(context.Background(), authService)
+			if !tt.ok {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, authService.Providers(), 1)
+			_, ok := srv.ssoProvider(srv.SSOProviders[0].Name + "_123")
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestSSOProvider_mapUser(t *testing.T) {
+	ctx, dataStore := context.Background(), prepareSSOUserMock()
+	p := SSOProvider{Name: "corp", RoleClaim: "groups", RoleMapping: map[string]string{
+		"developers": store.UserRole, "registry-admins": store.AdminRole, "ops": store.ManagerRole, "ci": "ci-operator",
+		"removed": "removed-role",
+	}}
+
+	user := p.mapUser(ctx, dataStore, map[string]interface{}{
+		"sub": "42", "preferred_username": "john", "email": "john@example.com",
+		"groups": []interface{}{"developers", "ops", "unknown"},
+	})
+	assert.Equal(t, "john", user.Name)
+	assert.Equal(t, "john@example.com", user.Email)
+	assert.Equal(t, store.ManagerRole, user.Role)
+	assert.Regexp(t, "^corp_", user.ID)
+
+	// login falls back to email when preferred username is absent, a single claim value is mapped too
+	user = p.mapUser(ctx, dataStore, map[string]interface{}{"sub": "42", "email": "john@example.com", "groups": "registry-admins"})
+	assert.Equal(t, "john@example.com", user.Name)
+	assert.Equal(t, store.AdminRole, user.Role)
+
+	// custom roles are mapped too, a role which the store doesn't have is skipped
+	user = p.mapUser(ctx, dataStore, map[string]interface{}{"sub": "42", "email": "john@example.com",
+		"groups": []interface{}{"developers", "ci", "removed"}})
+	assert.Equal(t, "ci-operator", user.Role)
+
+	user = p.mapUser(ctx, dataStore, map[string]interface{}{"sub": "42", "email": "john@example.com", "groups": "removed"})
+	assert.Equal(t, "", user.Role)
+
+	// the same subject gets the same ID, other subjects or providers get other IDs
+	assert.Equal(t, user.ID, p.mapUser(ctx, dataStore, map[string]interface{}{"sub": "42"}).ID)
+	assert.NotEqual(t, user.ID, p.mapUser(ctx, dataStore, map[string]interface{}{"sub": "43"}).ID)
+	assert.NotEqual(t, user.ID, SSOProvider{Name: "partner"}.mapUser(ctx, dataStore, map[string]interface{}{"sub": "42"}).ID)
+
+	p.LoginClaim = "upn"
+	user = p.mapUser(ctx, dataStore, map[string]interface{}{"sub": "42", "upn": "jdoe", "preferred_username": "john"})
+	assert.Equal(t, "jdoe", user.Name)
+	assert.Equal(t, "", user.Role)
+}
+
+func TestServer_ssoClaimUpdate(t *testing.T) {
+	testStore := prepareSSOUserMock()
+	require.NoError(t, testStore.CreateUser(context.Background(), &store.User{Login: "john", Role: store.UserRole, SSOID: "corp_1"}))
+	require.NoError(t, testStore.CreateUser(context.Background(), &store.User{Login: "blocked", Role: store.AdminRole, Disabled: true,
+		SSOID: "corp_2"}))
+	require.NoError(t, testStore.CreateUser(context.Background(), &store.User{Login: "admin", Role: store.AdminRole}))
+
+	srv := Server{
+		Storage: testStore,
+		ctx:     context.Background(),
+		SSOProviders: []SSOProvider{
+			{Name: "corp", Type: SSOTypeOIDC, AutoProvision: true, DefaultRole: store.UserRole},
+			{Name: "partner", Type: SSOTypeOIDC},
+		},
+	}
+
+	claimsOf := func(id, name, role string) token.Claims {
+		u := &token.User{ID: id, Name: name}
+		u.SetRole(role)
+		return token.Claims{User: u}
+	}
+
+	// the stored role wins over the mapped one
+	claims := srv.ClaimUpdateFn(claimsOf("corp_1", "john", store.AdminRole))
+	assert.Equal(t, store.UserRole, claims.User.Role)
+	assert.False(t, claims.User.BoolAttr("disabled"))
+
+	claims = srv.ClaimUpdateFn(claimsOf("corp_2", "blocked", ""))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+
+	// a login isn't mapped onto a local user or a user of another identity or provider with the same login
+	claims = srv.ClaimUpdateFn(claimsOf("corp_7", "admin", store.AdminRole))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+	assert.Equal(t, "", claims.User.Role)
+	assert.Nil(t, claims.User.Attributes["uid"])
+
+	claims = srv.ClaimUpdateFn(claimsOf("corp_8", "john", ""))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+
+	claims = srv.ClaimUpdateFn(claimsOf("partner_1", "john", ""))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+
+	// a new user is provisioned with the mapped role or with the default one
+	claims = srv.ClaimUpdateFn(claimsOf("corp_3", "jane", store.ManagerRole))
+	assert.Equal(t, store.ManagerRole, claims.User.Role)
+	assert.False(t, claims.User.BoolAttr("disabled"))
+	jane, err := testStore.GetUser(context.Background(), "jane")
+	require.NoError(t, err)
+	assert.Equal(t, store.ManagerRole, jane.Role)
+	assert.Equal(t, "corp_3", jane.SSOID)
+	assert.Equal(t, jane.ID, claims.User.Attributes["uid"])
+
+	claims = srv.ClaimUpdateFn(claimsOf("corp_3", "jane", ""))
+	assert.False(t, claims.User.BoolAttr("disabled"))
+	assert.Equal(t, store.ManagerRole, claims.User.Role)
+
+	claims = srv.ClaimUpdateFn(claimsOf("corp_4", "bob", ""))
+	assert.Equal(t, store.UserRole, claims.User.Role)
+
+	// the second factor is required for single sign-on users of the role too
+	srv.TOTPRequiredRoles = []string{store.ManagerRole}
+	claims = srv.ClaimUpdateFn(claimsOf("corp_3", "jane", ""))
+	assert.Equal(t, totpStepSetup, claims.User.StrAttr(totpClaimAttr))
+	assert.False(t, srv.Validate("", claims))
+	claims = srv.ClaimUpdateFn(claimsOf("corp_4", "bob", ""))
+	assert.Empty(t, claims.User.StrAttr(totpClaimAttr))
+	assert.True(t, srv.Validate("", claims))
+
+	// provisioning is disabled for partner provider and there isn't a default role
+	claims = srv.ClaimUpdateFn(claimsOf("partner_1", "alice", store.UserRole))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+	_, err = testStore.GetUser(context.Background(), "alice")
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	// numeric login can't be used because store treats it as user ID
+	claims = srv.ClaimUpdateFn(claimsOf("corp_5", "1", ""))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+
+	srv.SSOProviders[0].DefaultRole = ""
+	claims = srv.ClaimUpdateFn(claimsOf("corp_6", "eve", ""))
+	assert.True(t, claims.User.BoolAttr("disabled"))
+
+	// handshake claims are passed as is
+	assert.Nil(t, srv.ClaimUpdateFn(token.Claims{}).User)
+}
+
+func TestIntegrationSSOLogin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	issuer := newTestOIDCIssuer(t, map[string]interface{}{
+		"sub": "f2a9", "preferred_username": "john", "email": "john@example.com", "groups": []string{"registry-admins"},
+	})
+
+	var portalHandler http.Handler
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		portalHandler.ServeHTTP(w, r)
+	}))
+	defer portal.Close()
+
+	testStore := prepareSSOUserMock()
+	srv := Server{
+		Hostname: portal.URL,
+		Storage:  testStore,
+		ctx:      ctx,
+		SSOProviders: []SSOProvider{{
+			Name: "corp", Type: SSOTypeOIDC, Issuer: issuer.URL, ClientID: "cid", ClientSecret: "secret",
+			RoleClaim: "groups", RoleMapping: map[string]string{"registry-admins": store.AdminRole}, AutoProvision: true,
+		}},
+		TOTPRequiredRoles: []string{store.ManagerRole},
+	}
+
+	authService := auth.NewService(auth.Opts{
+		SecretReader:   token.SecretFunc(func(string) (string, error) { return "test_secret", nil }),
+		ClaimsUpd:      token.ClaimsUpdFunc(srv.ClaimUpdateFn),
+		TokenDuration:  time.Minute,
+		CookieDuration: time.Minute,
+		Issuer:         "zebox tester",
+		URL:            portal.URL,
+		AvatarStore:    avatar.NewNoOp(),
+		DisableXSRF:    true,
+		Validator:      &srv,
+		Logger:         log.NoOp,
+	})
+	require.NoError(t, srv.AddSSOProviders(ctx, authService))
+
+	authHandler, _ := authService.Handlers()
+	router := chi.NewRouter()
+	router.Mount("/auth", authHandler)
+	authMiddleware := authService.Middleware()
+	router.With(authMiddleware.Auth).Get("/me", func(w http.ResponseWriter, r *http.Request) {
+		user, err := token.GetUserInfo(r)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(user)
+	})
+	th := totpHandlers{endpointsHandler: endpointsHandler{dataStore: testStore, authenticator: authService, l: log.NoOp},
+		requiredRoles: srv.TOTPRequiredRoles, ssoProviders: srv.SSOProviders}
+	router.With(th.totpAuth).Get("/totp", th.totpStatusCtrl)
+	portalHandler = router
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := http.Client{Jar: jar, Timeout: 5 * time.Second}
+
+	resp, err := client.Get(portal.URL + "/auth/corp/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the user is provisioned with the role mapped from groups claim
+	user, err := testStore.GetUser(ctx, "john")
+	require.NoError(t, err)
+	assert.Equal(t, store.AdminRole, user.Role)
+	assert.Regexp(t, "^corp_", user.SSOID)
+
+	resp, err = client.Get(portal.URL + "/me")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, resp.Body.Close()) }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var me token.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	assert.Equal(t, "john", me.Name)
+	assert.Equal(t, store.AdminRole, me.Role)
+
+	// a role which requires the second factor allows access to totp endpoints only until it passed
+	user.Role = store.ManagerRole
+	require.NoError(t, testStore.UpdateUser(ctx, user))
+	jar, err = cookiejar.New(nil)
+	require.NoError(t, err)
+	client.Jar = jar
+
+	resp, err = client.Get(portal.URL + "/auth/corp/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = client.Get(portal.URL + "/me")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = client.Get(portal.URL + "/totp")
+	require.NoError(t, err)
+	var msg responseMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&msg))
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, totpStepSetup, msg.Data.(map[string]interface{})["step"])
+
+	// a disabled user can't access the portal after login
+	user.Disabled = true
+	require.NoError(t, testStore.UpdateUser(ctx, user))
+	jar, err = cookiejar.New(nil)
+	require.NoError(t, err)
+	client.Jar = jar
+
+	resp, err = client.Get(portal.URL + "/auth/corp/login")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = client.Get(portal.URL + "/me")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// newTestOIDCIssuer starts a fake OpenID Connect issuer which authorizes any request and returns the userinfo claims
+func newTestOIDCIssuer(t *testing.T, userinfo map[string]interface{}) *httptest.Server {
+	router := chi.NewRouter()
+	issuer := httptest.NewServer(router)
+	t.Cleanup(issuer.Close)
+
+	router.Get("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidcConfiguration{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			UserinfoEndpoint:      issuer.URL + "/userinfo",
+		})
+	})
+
+	router.Get("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "cid", r.URL.Query().Get("client_id"))
+		assert.Contains(t, r.URL.Query().Get("scope"), "openid")
+		redirectURL, err := url.Parse(r.URL.Query().Get("redirect_uri"))
+		require.NoError(t, err)
+		q := redirectURL.Query()
+		q.Set("code", "test_code")
+		q.Set("state", r.URL.Query().Get("state"))
+		redirectURL.RawQuery = q.Encode()
+		http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+	})
+
+	router.Post("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("code") != "test_code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "test_access_token", "token_type": "Bearer", "expires_in": 60})
+	})
+
+	router.Get("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test_access_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(userinfo)
+	})
+
+	return issuer
+}
+
+func prepareSSOUserMock() *engine.InterfaceMock {
+	testUsersStorage := make(map[string]store.User)
+	var testUserIndex int64
+
+	return &engine.InterfaceMock{
+		GetRoleFunc: func(ctx context.Context, id interface{}) (store.Role, error) {
+			if id == "ci-operator" {
+				return store.Role{Name: "ci-operator", Permissions: []string{store.PermissionRegistryRead, store.PermissionRobotsRead}}, nil
+			}
+			return getBuiltInRole(ctx, id)
+		},
+		CreateUserFunc: func(ctx context.Context, user *store.User) error {
+			if _, ok := testUsersStorage[user.Login]; ok {
+				return fmt.Errorf("user %q: %w", user.Login, engine.ErrDuplicateEntry)
+			}
+			testUserIndex++
+			user.ID = testUserIndex
+			testUsersStorage[user.Login] = *user
+			return nil
+		},
+		GetUserFunc: func(ctx context.Context, id interface{}) (store.User, error) {
+			for _, u := range testUsersStorage {
+				if u.Login == id || fmt.Sprint(u.ID) == id {
+					return u, nil
+				}
+			}
+			return store.User{}, engine.ErrNotFound
+		},
+		UpdateUserFunc: func(ctx context.Context, user store.User) error {
+			testUsersStorage[user.Login] = user
+			return nil
+		},
+	}
+}
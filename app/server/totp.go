@@ -0,0 +1,376 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-pkgz/auth/token"
+	R "github.com/go-pkgz/rest"
+	"github.com/pkg/errors"
+
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// defaultTOTPIssuer is shown in authenticator apps when issuer isn't defined
+const defaultTOTPIssuer = "RegistryAdmin"
+
+// token attributes of the second factor, a token with non-empty 'totp' attribute allows access to totp endpoints only
+const (
+	totpClaimAttr    = "totp"          // pending step of the second factor
+	totpVerifiedAttr = "totp_verified" // set when the second factor passed in the current session
+	totpStepVerify   = "verify"        // a code required
+	totpStepSetup    = "setup"         // enrolment required by role of the user
+)
+
+// totpHandlers implement controllers of two-factor authentication for portal logins
+type totpHandlers struct {
+	endpointsHandler
+	issuer        string
+	requiredRoles []string
+	ssoProviders  []SSOProvider // users of single sign-on pass the second factor like users of password login
+}
+
+// totpSessionKey is a context key of the current session which totpAuth middleware sets
+type totpSessionKey struct{}
+
+type totpSession struct {
+	claims token.Claims
+	user   store.User
+}
+
+// totpRequest contains a code from authenticator app or one of recovery codes
+type totpRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// totpEnrollment contains provisioning data which authenticator app is set up with, URI is used for QR code
+type totpEnrollment struct {
+	Secret  string `json:"secret"`
+	URI     string `json:"uri"`
+	Issuer  string `json:"issuer"`
+	Account string `json:"account"`
+}
+
+// totpStatus describes the second factor of the current user
+type totpStatus struct {
+	Enabled       bool   `json:"enabled"`
+	Required      bool   `json:"required"`
+	Step          string `json:"step"` // pending step of the current session, 'verify' or 'setup', empty when passed
+	RecoveryCodes int    `json:"recovery_codes"`
+}
+
+// totpRequired checks that the role has to use the second factor
+func totpRequired(requiredRoles []string, role string) bool {
+	for _, r := range requiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// totpClaimUpdate sets pending step of the second factor to claims of local and single sign-on users,
+// Validate denies access with token which has a pending step
+func (s *Server) totpClaimUpdate(u store.User, claims token.Claims) token.Claims {
+	switch {
+	case u.TOTP.Enabled && !claims.User.BoolAttr(totpVerifiedAttr):
+		claims.User.SetStrAttr(totpClaimAttr, totpStepVerify)
+	case !u.TOTP.Enabled && totpRequired(s.TOTPRequiredRoles, u.Role):
+		claims.User.SetStrAttr(totpClaimAttr, totpStepSetup)
+	default:
+		delete(claims.User.Attributes, totpClaimAttr)
+	}
+	return claims
+}
+
+// totpAuth checks token without validation of the second factor step because the endpoints are used for pass it.
+// Only users which login with local or single sign-on providers use the second factor, basic authentication doesn't.
+func (th *totpHandlers) totpAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenService := th.authenticator.TokenService()
+		claims, _, err := tokenService.Get(r)
+		if err != nil || claims.User == nil || tokenService.IsExpired(claims) {
+			if err == nil {
+				err = errors.New("token expired")
+			}
+			SendErrorJSON(w, r, th.l, http.StatusUnauthorized, err, "authorization required")
+			return
+		}
+
+		_, isSSO := findSSOProvider(th.ssoProviders, claims.User.ID)
+		if !isSSO && !strings.HasPrefix(claims.User.ID, localProviderName+"_") {
+			SendErrorJSON(w, r, th.l, http.StatusForbidden, errors.New("not a portal login"),
+				"two-factor authentication available for portal logins only")
+			return
+		}
+
+		user, err := th.dataStore.GetUser(r.Context(), claims.User.Name)
+		if err != nil {
+			SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to get user")
+			return
+		}
+
+		if user.Disabled {
+			SendErrorJSON(w, r, th.l, http.StatusForbidden, errors.Errorf("user %q disabled", user.Login), "access denied")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), totpSessionKey{}, totpSession{claims: claims, user: user})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// totpSessionLogin returns login of the session which totpAuth middleware sets
+func totpSessionLogin(r *http.Request) string {
+	if session, ok := r.Context().Value(totpSessionKey{}).(totpSession); ok {
+		return session.user.Login
+	}
+	return ""
+}
+
+func (th *totpHandlers) totpStatusCtrl(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(totpSessionKey{}).(totpSession)
+	R.RenderJSON(w, responseMessage{
+		ID: session.user.ID,
+		Data: totpStatus{
+			Enabled:       session.user.TOTP.Enabled,
+			Required:      totpRequired(th.requiredRoles, session.user.Role),
+			Step:          session.claims.User.StrAttr(totpClaimAttr),
+			RecoveryCodes: len(session.user.TOTP.RecoveryCodes),
+		},
+	})
+}
+
+// totpEnrollCtrl generates a new secret which becomes active after confirmation with a code
+func (th *totpHandlers) totpEnrollCtrl(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(totpSessionKey{}).(totpSession)
+	if session.user.TOTP.Enabled {
+		SendErrorJSON(w, r, th.l, http.StatusConflict, errors.New("already enabled"), "two-factor authentication enabled")
+		return
+	}
+
+	secret, err := store.NewTOTPSecret()
+	if err != nil {
+		SendErrorJSON(w, r, th.l, http.StatusInternalServerError, err, "failed to generate totp secret")
+		return
+	}
+
+	totp := store.TOTP{Secret: secret}
+	if err = th.dataStore.UpdateUserTOTP(r.Context(), session.user.ID, totp); err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to save totp secret")
+		return
+	}
+
+	issuer := th.issuer
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+
+	R.RenderJSON(w, responseMessage{
+		Message: "confirm enrolment with a code",
+		ID:      session.user.ID,
+		Data: totpEnrollment{
+			Secret:  secret,
+			URI:     totp.ProvisioningURI(issuer, session.user.Login),
+			Issuer:  issuer,
+			Account: session.user.Login,
+		},
+	})
+}
+
+// totpConfirmCtrl enables the second factor and returns recovery codes, they are shown once
+func (th *totpHandlers) totpConfirmCtrl(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(totpSessionKey{}).(totpSession)
+	totp := session.user.TOTP
+	if totp.Enabled {
+		SendErrorJSON(w, r, th.l, http.StatusConflict, errors.New("already enabled"), "two-factor authentication enabled")
+		return
+	}
+
+	if totp.Secret == "" {
+		SendErrorJSON(w, r, th.l, http.StatusBadRequest, errors.New("secret undefined"), "enrolment not started")
+		return
+	}
+
+	req, ok := th.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	accepted, err := th.acceptCode(r.Context(), session.user.ID, totp, req.Code)
+	if err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to confirm enrolment")
+		return
+	}
+	if !accepted {
+		SendErrorJSON(w, r, th.l, http.StatusForbidden, errors.New("invalid code"), "failed to confirm enrolment")
+		return
+	}
+
+	codes, err := totp.NewRecoveryCodes()
+	if err != nil {
+		SendErrorJSON(w, r, th.l, http.StatusInternalServerError, err, "failed to generate recovery codes")
+		return
+	}
+
+	totp.Enabled = true
+	if err = th.dataStore.UpdateUserTOTP(r.Context(), session.user.ID, totp); err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to enable two-factor authentication")
+		return
+	}
+
+	if err = th.setVerifiedToken(w, session.claims); err != nil {
+		SendErrorJSON(w, r, th.l, http.StatusInternalServerError, err, "failed to issue token")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "two-factor authentication enabled", ID: session.user.ID, Data: codes})
+}
+
+// totpVerifyCtrl passes the second factor of the current session with a code or one of recovery codes
+func (th *totpHandlers) totpVerifyCtrl(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(totpSessionKey{}).(totpSession)
+	totp := session.user.TOTP
+	if !totp.Enabled {
+		SendErrorJSON(w, r, th.l, http.StatusBadRequest, errors.New("not enabled"), "two-factor authentication disabled")
+		return
+	}
+
+	req, ok := th.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	accepted, err := th.acceptCode(r.Context(), session.user.ID, totp, req.Code)
+	if err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to verify second factor")
+		return
+	}
+
+	switch {
+	case accepted:
+	case req.RecoveryCode != "" && totp.UseRecoveryCode(req.RecoveryCode):
+		// used recovery code is removed
+		if err := th.dataStore.UpdateUserTOTP(r.Context(), session.user.ID, totp); err != nil {
+			SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to update recovery codes")
+			return
+		}
+		th.l.Logf("[INFO] user %q passed second factor with recovery code, %d codes left", session.user.Login, len(totp.RecoveryCodes))
+	default:
+		SendErrorJSON(w, r, th.l, http.StatusForbidden, errors.New("invalid code"), "failed to verify second factor")
+		return
+	}
+
+	if err := th.setVerifiedToken(w, session.claims); err != nil {
+		SendErrorJSON(w, r, th.l, http.StatusInternalServerError, err, "failed to issue token")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "ok", ID: session.user.ID})
+}
+
+// totpRecoveryCodesCtrl replaces recovery codes with new ones, a valid code is required
+func (th *totpHandlers) totpRecoveryCodesCtrl(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(totpSessionKey{}).(totpSession)
+	totp, ok := th.checkCode(w, r, session)
+	if !ok {
+		return
+	}
+
+	codes, err := totp.NewRecoveryCodes()
+	if err != nil {
+		SendErrorJSON(w, r, th.l, http.StatusInternalServerError, err, "failed to generate recovery codes")
+		return
+	}
+
+	if err = th.dataStore.UpdateUserTOTP(r.Context(), session.user.ID, totp); err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to update recovery codes")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "recovery codes updated", ID: session.user.ID, Data: codes})
+}
+
+// totpDisableCtrl disables the second factor with a valid code, users of roles which require it can't disable it
+func (th *totpHandlers) totpDisableCtrl(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(totpSessionKey{}).(totpSession)
+	if totpRequired(th.requiredRoles, session.user.Role) {
+		SendErrorJSON(w, r, th.l, http.StatusForbidden, errors.Errorf("required for role %q", session.user.Role),
+			"failed to disable two-factor authentication")
+		return
+	}
+
+	if _, ok := th.checkCode(w, r, session); !ok {
+		return
+	}
+
+	if err := th.dataStore.UpdateUserTOTP(r.Context(), session.user.ID, store.TOTP{}); err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to disable two-factor authentication")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "two-factor authentication disabled", ID: session.user.ID})
+}
+
+// checkCode validates a code of enabled second factor, it sends error response when check failed
+func (th *totpHandlers) checkCode(w http.ResponseWriter, r *http.Request, session totpSession) (store.TOTP, bool) {
+	totp := session.user.TOTP
+	if !totp.Enabled {
+		SendErrorJSON(w, r, th.l, http.StatusBadRequest, errors.New("not enabled"), "two-factor authentication disabled")
+		return totp, false
+	}
+
+	req, ok := th.decodeRequest(w, r)
+	if !ok {
+		return totp, false
+	}
+
+	accepted, err := th.acceptCode(r.Context(), session.user.ID, totp, req.Code)
+	if err != nil {
+		SendErrorJSON(w, r, th.l, storeErrorStatus(err), err, "failed to check second factor")
+		return totp, false
+	}
+	if !accepted {
+		SendErrorJSON(w, r, th.l, http.StatusForbidden, errors.New("invalid code"), "failed to check second factor")
+		return totp, false
+	}
+	return totp, true
+}
+
+// acceptCode validates a code and stores its time step, a code of the stored step or an earlier one is refused,
+// so every code is accepted once only
+func (th *totpHandlers) acceptCode(ctx context.Context, userID int64, totp store.TOTP, code string) (bool, error) {
+	step, ok := totp.MatchStep(code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	err := th.dataStore.UpdateUserTOTPStep(ctx, userID, step)
+	if errors.Is(err, engine.ErrNotFound) {
+		// the code has been accepted by a concurrent request
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (th *totpHandlers) decodeRequest(w http.ResponseWriter, r *http.Request) (req totpRequest, ok bool) {
+	defer func() { _ = r.Body.Close() }()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErrorJSON(w, r, th.l, http.StatusBadRequest, err, "failed to parse totp request")
+		return req, false
+	}
+	return req, true
+}
+
+// setVerifiedToken issues a new token of the session with passed second factor
+func (th *totpHandlers) setVerifiedToken(w http.ResponseWriter, claims token.Claims) error {
+	claims.User.SetBoolAttr(totpVerifiedAttr, true)
+	claims.ExpiresAt = 0 // new expiration is set from token duration
+	_, err := th.authenticator.TokenService().Set(w, claims)
+	return err
+}
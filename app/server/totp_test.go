@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // RFC 6238 default algorithm
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-pkgz/auth"
+	"github.com/go-pkgz/auth/avatar"
+	"github.com/go-pkgz/auth/token"
+	log "github.com/go-pkgz/lgr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"github.com/zebox/registry-admin/app/store/engine/embedded"
+)
+
+func TestServer_totpClaimUpdate(t *testing.T) {
+	srv := Server{TOTPRequiredRoles: []string{store.AdminRole}}
+	newClaims := func() token.Claims {
+		return token.Claims{User: &token.User{Name: "test_user", Attributes: map[string]interface{}{}}}
+	}
+
+	claims := srv.totpClaimUpdate(store.User{Role: store.UserRole}, newClaims())
+	assert.Empty(t, claims.User.StrAttr(totpClaimAttr))
+
+	claims = srv.totpClaimUpdate(store.User{Role: store.AdminRole}, newClaims())
+	assert.Equal(t, totpStepSetup, claims.User.StrAttr(totpClaimAttr))
+
+	enabled := store.User{Role: store.UserRole, TOTP: store.TOTP{Enabled: true}}
+	claims = srv.totpClaimUpdate(enabled, newClaims())
+	assert.Equal(t, totpStepVerify, claims.User.StrAttr(totpClaimAttr))
+	assert.False(t, srv.Validate("", claims))
+
+	claims.User.SetBoolAttr(totpVerifiedAttr, true)
+	claims = srv.totpClaimUpdate(enabled, claims)
+	assert.Empty(t, claims.User.StrAttr(totpClaimAttr))
+	assert.True(t, srv.Validate("", claims))
+
+	// the second factor which reset by admin is required again for role
+	claims = srv.totpClaimUpdate(store.User{Role: store.AdminRole}, claims)
+	assert.Equal(t, totpStepSetup, claims.User.StrAttr(totpClaimAttr))
+}
+
+func TestIntegrationTOTPLogin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := embedded.NewEmbedded(filepath.Join(t.TempDir(), "totp.db"))
+	require.NoError(t, db.Connect(ctx))
+	defer func() { assert.NoError(t, db.Close(ctx)) }()
+
+	admin := store.User{Login: "totp_admin", Name: "TOTP Admin", Password: "totp_password", Role: store.AdminRole, Group: 1}
+	require.NoError(t, db.CreateUser(ctx, &admin))
+
+	ts := prepareAuthTestServer(ctx, t, &Server{Storage: db, TOTPRequiredRoles: []string{store.AdminRole}})
+	defer ts.Close()
+
+	newClient := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		return &http.Client{Jar: jar, Timeout: 5 * time.Second}
+	}
+
+	login := func(client *http.Client) {
+		resp, err := client.Get(ts.URL + "/auth/local/login?user=totp_admin&passwd=totp_password")
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	call := func(client *http.Client, method, path string, body interface{}, status int) responseMessage {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequestWithContext(ctx, method, ts.URL+path, bytes.NewReader(data))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, resp.Body.Close()) }()
+		require.Equal(t, status, resp.StatusCode, "%s %s", method, path)
+
+		var msg responseMessage
+		if resp.Header.Get("Content-Type") == "application/json; charset=utf-8" {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&msg))
+		}
+		return msg
+	}
+
+	// the second factor is required for admin, so a token after login allows access to totp endpoints only
+	client := newClient()
+	login(client)
+	call(client, "GET", "/api/v1/users", nil, http.StatusUnauthorized)
+
+	msg := call(client, "GET", "/api/v1/totp", nil, http.StatusOK)
+	assert.Equal(t, map[string]interface{}{"enabled": false, "required": true, "step": "setup", "recovery_codes": float64(0)}, msg.Data)
+
+	call(client, "POST", "/api/v1/totp/confirm", totpRequest{Code: "000000"}, http.StatusBadRequest)
+
+	msg = call(client, "POST", "/api/v1/totp/enroll", nil, http.StatusOK)
+	enrollment := msg.Data.(map[string]interface{})
+	secret := enrollment["secret"].(string)
+	assert.Equal(t, "totp_admin", enrollment["account"])
+	assert.Equal(t, defaultTOTPIssuer, enrollment["issuer"])
+	assert.Contains(t, enrollment["uri"], "otpauth://totp/RegistryAdmin:totp_admin?")
+
+	call(client, "POST", "/api/v1/totp/confirm", totpRequest{Code: "not-a-code"}, http.StatusForbidden)
+	msg = call(client, "POST", "/api/v1/totp/confirm", totpRequest{Code: nextTOTPCode(ctx, t, db, admin.ID, secret)}, http.StatusOK)
+	recoveryCodes := msg.Data.([]interface{})
+	require.Len(t, recoveryCodes, store.TOTPRecoveryCodesCount)
+
+	// confirmation passes the second factor of the current session
+	call(client, "GET", "/api/v1/users", nil, http.StatusOK)
+	call(client, "POST", "/api/v1/totp/enroll", nil, http.StatusConflict)
+
+	// basic authentication doesn't pass a code
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/v1/users", http.NoBody)
+	require.NoError(t, err)
+	req.SetBasicAuth("totp_admin", "totp_password")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// a new session requires a code or a recovery code which can be used once
+	client = newClient()
+	login(client)
+	msg = call(client, "GET", "/api/v1/totp", nil, http.StatusOK)
+	assert.Equal(t, "verify", msg.Data.(map[string]interface{})["step"])
+	call(client, "GET", "/api/v1/users", nil, http.StatusUnauthorized)
+	call(client, "POST", "/api/v1/totp/verify", totpRequest{RecoveryCode: recoveryCodes[0].(string)}, http.StatusOK)
+	call(client, "GET", "/api/v1/users", nil, http.StatusOK)
+
+	client = newClient()
+	login(client)
+	call(client, "POST", "/api/v1/totp/verify", totpRequest{RecoveryCode: recoveryCodes[0].(string)}, http.StatusForbidden)
+	code := nextTOTPCode(ctx, t, db, admin.ID, secret)
+	call(client, "POST", "/api/v1/totp/verify", totpRequest{Code: code}, http.StatusOK)
+
+	// an accepted code can't be replayed in another session
+	replayClient := newClient()
+	login(replayClient)
+	call(replayClient, "POST", "/api/v1/totp/verify", totpRequest{Code: code}, http.StatusForbidden)
+
+	msg = call(client, "GET", "/api/v1/totp", nil, http.StatusOK)
+	assert.Equal(t, float64(store.TOTPRecoveryCodesCount-1), msg.Data.(map[string]interface{})["recovery_codes"])
+
+	msg = call(client, "POST", "/api/v1/totp/recovery-codes", totpRequest{Code: nextTOTPCode(ctx, t, db, admin.ID, secret)}, http.StatusOK)
+	assert.Len(t, msg.Data, store.TOTPRecoveryCodesCount)
+
+	// the role requires the second factor, so it can be reset by another admin only
+	call(client, "DELETE", "/api/v1/totp", totpRequest{Code: nextTOTPCode(ctx, t, db, admin.ID, secret)}, http.StatusForbidden)
+	call(client, "DELETE", fmt.Sprintf("/api/v1/users/%d/totp", admin.ID), nil, http.StatusOK)
+
+	// the step of the last accepted code is kept, so codes used before reset can't be replayed after enrolment
+	user, err := db.GetUser(ctx, admin.ID)
+	require.NoError(t, err)
+	assert.NotZero(t, user.TOTP.LastStep)
+	assert.Equal(t, store.TOTP{LastStep: user.TOTP.LastStep}, user.TOTP)
+
+	// a token without login with local provider isn't allowed for totp endpoints
+	call(http.DefaultClient, "GET", "/api/v1/totp", nil, http.StatusUnauthorized)
+}
+
+func TestIntegrationTOTPDisable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := embedded.NewEmbedded(filepath.Join(t.TempDir(), "totp.db"))
+	require.NoError(t, db.Connect(ctx))
+	defer func() { assert.NoError(t, db.Close(ctx)) }()
+
+	user := store.User{Login: "totp_user", Name: "TOTP User", Password: "totp_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, db.CreateUser(ctx, &user))
+
+	totp := store.TOTP{Enabled: true, Secret: "JBSWY3DPEHPK3PXP"}
+	require.NoError(t, db.UpdateUserTOTP(ctx, user.ID, totp))
+	user, err := db.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+
+	th := totpHandlers{endpointsHandler: endpointsHandler{dataStore: db, l: log.NoOp}}
+	session := totpSession{claims: token.Claims{User: &token.User{Name: user.Login}}, user: user}
+
+	disable := func(code string) int {
+		body, errMarshal := json.Marshal(totpRequest{Code: code})
+		require.NoError(t, errMarshal)
+		req := httptest.NewRequest("DELETE", "/api/v1/totp", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), totpSessionKey{}, session))
+		w := httptest.NewRecorder()
+		th.totpDisableCtrl(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, disable("000000"))
+	assert.Equal(t, http.StatusOK, disable(nextTOTPCode(ctx, t, db, user.ID, totp.Secret)))
+
+	user, err = db.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, user.TOTP.Enabled)
+}
+
+// prepareAuthTestServer starts routes of the server with auth service which has the local provider,
+// the storage is required and the registry is mocked
+func prepareAuthTestServer(ctx context.Context, t *testing.T, srv *Server) *httptest.Server {
+	if srv.RegistryService == nil {
+		srv.RegistryService = prepareRegistryMock(t)
+	}
+	srv.L = log.NoOp
+	srv.AccessLog = nopWriteCloser{io.Discard}
+	srv.ctx = ctx
+
+	srv.Authenticator = auth.NewService(auth.Opts{
+		SecretReader:     token.SecretFunc(func(string) (string, error) { return "test_secret", nil }),
+		ClaimsUpd:        token.ClaimsUpdFunc(srv.ClaimUpdateFn),
+		TokenDuration:    time.Minute,
+		CookieDuration:   time.Minute,
+		Issuer:           "zebox tester",
+		URL:              "http://localhost",
+		AvatarStore:      avatar.NewNoOp(),
+		DisableXSRF:      true,
+		Validator:        srv,
+		BasicAuthChecker: srv.BasicAuthCheckerFn,
+		Logger:           log.NoOp,
+	})
+	srv.Authenticator.AddDirectProvider(localProviderName, srv)
+
+	return httptest.NewServer(srv.routes())
+}
+
+// nextTOTPCode calculates a code which isn't used yet, it's a code of the step after the last accepted one
+// or of the previous step which is accepted for clock drift
+func nextTOTPCode(ctx context.Context, t *testing.T, db engine.Interface, userID int64, secret string) string {
+	user, err := db.GetUser(ctx, userID)
+	require.NoError(t, err)
+
+	step := time.Now().Unix()/30 - 1
+	if user.TOTP.LastStep >= step {
+		step = user.TOTP.LastStep + 1
+	}
+	return testTOTPCode(t, secret, step)
+}
+
+// testTOTPCode calculates a code of the time step as authenticator app does
+func testTOTPCode(t *testing.T, secret string, step int64) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/zebox/registry-admin/app/registry"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	R "github.com/go-pkgz/rest"
@@ -20,6 +21,14 @@ type userHandlers struct {
 	endpointsHandler
 	registryService registryInterface
 	userAdapter     *usersRegistryAdapter
+	guard           *loginGuard           // tracks failed logins, lock status of users is exposed with API
+	passwordPolicy  *store.PasswordPolicy // checks passwords which are set with API, store requirements are applied only when nil
+}
+
+// userView is a user with lock status of the login
+type userView struct {
+	store.User
+	Lockout lockStatus `json:"lockout"`
 }
 
 // usersRegistryAdapter need for bind FindUsers func in store engine with registry instance
@@ -71,6 +80,20 @@ func (u *userHandlers) userCreateCtrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err = checkUserLogin(user.Login); err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "invalid user login")
+		return
+	}
+
+	if !u.rolesGranted(w, r, user.Role) {
+		return
+	}
+
+	if err = u.checkPassword(user.Login, user.Password); err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "password doesn't meet requirements")
+		return
+	}
+
 	err = u.dataStore.CreateUser(r.Context(), &user)
 	if err != nil {
 		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed create user with api")
@@ -107,7 +130,7 @@ func (u *userHandlers) userInfoCtrl(w http.ResponseWriter, r *http.Request) {
 	R.RenderJSON(w, responseMessage{
 		Error: false,
 		ID:    user.ID,
-		Data:  user,
+		Data:  userView{User: user, Lockout: u.guard.status(user.Login, time.Now())},
 	})
 }
 
@@ -119,9 +142,15 @@ func (u *userHandlers) userFindCtrl(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := u.dataStore.FindUsers(r.Context(), filter, true)
 	if err != nil {
-		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to find users")
+		SendErrorJSON(w, r, u.l, queryErrorStatus(err), err, "failed to find users")
 		return
 	}
+	now := time.Now()
+	for i, v := range result.Data {
+		user := v.(store.User)
+		result.Data[i] = userView{User: user, Lockout: u.guard.status(user.Login, now)}
+	}
+
 	w.Header().Add("Content-Range", fmt.Sprintf("users %d-%d/%d", filter.Range[0], filter.Range[1], result.Total))
 	R.RenderJSON(w, result)
 }
@@ -141,16 +170,46 @@ func (u *userHandlers) userUpdateCtrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hash, storedRole := user.Password, user.Role
 	if err = json.NewDecoder(r.Body).Decode(&user); err != nil {
 		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to decode user data for update with api")
 		return
 	}
 
+	if err = checkUserLogin(user.Login); err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "invalid user login")
+		return
+	}
+
+	// users with more rights than the current user can't be changed, e.g. a password of an admin can't be reset
+	if !u.rolesGranted(w, r, storedRole, user.Role) {
+		return
+	}
+
+	// the stored hash which is sent back means password isn't changed
+	if user.Password == hash {
+		user.Password = ""
+	}
+
+	if user.Password != "" {
+		if err = u.checkPassword(user.Login, user.Password); err != nil {
+			SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "password doesn't meet requirements")
+			return
+		}
+	}
+
 	if err = u.dataStore.UpdateUser(r.Context(), user); err != nil {
 		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to update user data with api")
 		return
 	}
 
+	// refresh tokens issued with the previous password or for the disabled user are revoked
+	if user.Password != "" || user.Disabled {
+		if err = u.dataStore.DeleteRefreshToken(r.Context(), "user_id", user.ID); err != nil && !errors.Is(err, engine.ErrNotFound) {
+			u.l.Logf("[WARN] failed to revoke refresh tokens of user %s: %v", user.Login, err)
+		}
+	}
+
 	R.RenderJSON(w, responseMessage{
 		Error:   false,
 		Message: "ok",
@@ -172,13 +231,22 @@ func (u *userHandlers) userDeleteCtrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = u.dataStore.DeleteUser(r.Context(), id); err != nil {
-		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to delete user with api")
+	if _, ok := u.grantedUser(w, r, id); !ok {
 		return
 	}
 
-	if err = u.dataStore.DeleteAccess(r.Context(), "owner_id", id); err != nil && err != engine.ErrNotFound {
-		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, fmt.Sprintf("failed to delete accesses for deleted user with id - %q", id))
+	// user and his accesses delete together, otherwise access records of deleted user will be orphaned
+	errMsg := "failed to delete user with api"
+	err = u.dataStore.WithTx(r.Context(), func(ctx context.Context) error {
+		if errDelete := u.dataStore.DeleteUser(ctx, id); errDelete != nil {
+			return errDelete
+		}
+
+		errMsg = fmt.Sprintf("failed to delete accesses for deleted user with id - %q", id)
+		return u.dataStore.DeleteOwnerAccesses(ctx, id, false)
+	})
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, errMsg)
 		return
 	}
 
@@ -188,3 +256,160 @@ func (u *userHandlers) userDeleteCtrl(w http.ResponseWriter, r *http.Request) {
 		u.l.Logf("failed to update htpasswd: %v", err)
 	}
 }
+
+// userTOTPResetCtrl disables the second factor of a user who lost the device and recovery codes
+func (u *userHandlers) userTOTPResetCtrl(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "failed to parse user id with api")
+		return
+	}
+
+	if _, ok := u.grantedUser(w, r, id); !ok {
+		return
+	}
+
+	if err = u.dataStore.UpdateUserTOTP(r.Context(), id, store.TOTP{}); err != nil {
+		SendErrorJSON(w, r, u.l, storeErrorStatus(err), err, "failed to reset two-factor authentication with api")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "two-factor authentication reset", ID: id})
+}
+
+// userUnlockCtrl resets failed login attempts and lock of the user login
+func (u *userHandlers) userUnlockCtrl(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "failed to parse user id with api")
+		return
+	}
+
+	user, ok := u.grantedUser(w, r, id)
+	if !ok {
+		return
+	}
+
+	u.guard.unlock(user.Login)
+	R.RenderJSON(w, responseMessage{Message: "user unlocked", ID: user.ID})
+}
+
+// userTokensFindCtrl returns refresh tokens which registry clients got for the user, values of tokens aren't available
+func (u *userHandlers) userTokensFindCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "failed to parse user id with api")
+		return
+	}
+
+	filter, err := engine.FilterFromURLExtractor(r.URL)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to parse URL parameters for make query filter")
+		return
+	}
+	if filter.Filters == nil {
+		filter.Filters = make(map[string]interface{})
+	}
+	filter.Filters["user_id"] = id
+
+	result, err := u.dataStore.FindRefreshTokens(r.Context(), filter)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, queryErrorStatus(err), err, "failed to find refresh tokens of user")
+		return
+	}
+
+	w.Header().Add("Content-Range", fmt.Sprintf("tokens %d-%d/%d", filter.Range[0], filter.Range[1], result.Total))
+	R.RenderJSON(w, result)
+}
+
+// userTokensRevokeCtrl revokes all refresh tokens of the user, registry clients have to log in with credentials again
+func (u *userHandlers) userTokensRevokeCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "failed to parse user id with api")
+		return
+	}
+
+	if _, ok := u.grantedUser(w, r, id); !ok {
+		return
+	}
+
+	if err = u.dataStore.DeleteRefreshToken(r.Context(), "user_id", id); err != nil && !errors.Is(err, engine.ErrNotFound) {
+		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to revoke refresh tokens of user")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "refresh tokens revoked", ID: id})
+}
+
+// userTokenRevokeCtrl revokes a refresh token of the user
+func (u *userHandlers) userTokenRevokeCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "failed to parse user id with api")
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "token_id"), 10, 64)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusBadRequest, err, "failed to parse refresh token id with api")
+		return
+	}
+
+	if _, ok := u.grantedUser(w, r, id); !ok {
+		return
+	}
+
+	// the token should belong to the user from the path
+	tokens, err := u.dataStore.FindRefreshTokens(r.Context(), engine.QueryFilter{Filters: map[string]interface{}{"id": tokenID, "user_id": id}})
+	if err != nil {
+		SendErrorJSON(w, r, u.l, http.StatusInternalServerError, err, "failed to get refresh token of user")
+		return
+	}
+	if tokens.Total == 0 {
+		SendErrorJSON(w, r, u.l, http.StatusNotFound, engine.ErrNotFound, "refresh token of user not found")
+		return
+	}
+
+	if err = u.dataStore.DeleteRefreshToken(r.Context(), "id", tokenID); err != nil {
+		SendErrorJSON(w, r, u.l, storeErrorStatus(err), err, "failed to revoke refresh token")
+		return
+	}
+
+	R.RenderJSON(w, responseMessage{Message: "refresh token revoked", ID: tokenID})
+}
+
+// checkUserLogin refuses logins with the robot prefix, such logins are reserved for robot accounts
+// and a user with them would be authenticated as a robot by the registry
+func checkUserLogin(login string) error {
+	if _, isRobot := store.RobotNameFromLogin(login); isRobot {
+		return fmt.Errorf("login can't start with %q", store.RobotLoginPrefix)
+	}
+	return nil
+}
+
+// checkPassword validates a new password with the policy
+func (u *userHandlers) checkPassword(login, password string) error {
+	if u.passwordPolicy == nil {
+		return nil
+	}
+	return u.passwordPolicy.Validate(login, password)
+}
+
+// grantedUser returns the user with the id when the request user has all rights of the user, so users with less rights
+// can't delete, unlock or log out an admin. An error is sent to the client when it returns false.
+func (u *userHandlers) grantedUser(w http.ResponseWriter, r *http.Request, id int64) (store.User, bool) {
+	user, err := u.dataStore.GetUser(r.Context(), id)
+	if err != nil {
+		SendErrorJSON(w, r, u.l, storeErrorStatus(err), err, "failed to get user with api")
+		return store.User{}, false
+	}
+	if !u.rolesGranted(w, r, user.Role) {
+		return store.User{}, false
+	}
+	return user, true
+}
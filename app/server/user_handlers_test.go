@@ -8,8 +8,10 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-pkgz/auth/token"
 	log "github.com/go-pkgz/lgr"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -46,7 +48,7 @@ func Test_userCreateCtrl(t *testing.T) {
 	require.NoError(t, errReq)
 
 	testWriter := httptest.NewRecorder()
-	handler := http.HandlerFunc(testUserHandlers.userCreateCtrl)
+	handler := http.HandlerFunc(asAdmin(testUserHandlers.userCreateCtrl))
 	handler.ServeHTTP(testWriter, req)
 	assert.Equal(t, http.StatusOK, testWriter.Code)
 
@@ -92,6 +94,18 @@ func Test_userCreateCtrl(t *testing.T) {
 	handler.ServeHTTP(testWriter, req)
 	assert.Equal(t, http.StatusInternalServerError, testWriter.Code)
 
+	// logins with the robot prefix are reserved for robot accounts
+	user.Login = store.RobotLoginPrefix + "ci-builder"
+	userData, err = json.Marshal(user)
+	require.NoError(t, err)
+
+	req, errReq = http.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(userData))
+	require.NoError(t, errReq)
+
+	testWriter = httptest.NewRecorder()
+	handler.ServeHTTP(testWriter, req)
+	assert.Equal(t, http.StatusBadRequest, testWriter.Code)
+
 }
 
 func Test_userInfoCtrl(t *testing.T) {
@@ -156,7 +170,7 @@ func Test_userUpdateCtrl(t *testing.T) {
 	rctx.URLParams.Add("id", "10001")
 
 	testWriter := httptest.NewRecorder()
-	handler := http.HandlerFunc(testUserHandlers.userUpdateCtrl)
+	handler := http.HandlerFunc(asAdmin(testUserHandlers.userUpdateCtrl))
 	handler.ServeHTTP(testWriter, req)
 	assert.Equal(t, http.StatusOK, testWriter.Code)
 
@@ -173,7 +187,7 @@ func Test_userUpdateCtrl(t *testing.T) {
 		chiCtx.URLParams.Add("id", "10002")
 
 		tWriter := httptest.NewRecorder()
-		handler = testUserHandlers.userUpdateCtrl
+		handler = asAdmin(testUserHandlers.userUpdateCtrl)
 		handler.ServeHTTP(tWriter, req)
 		assert.Equal(t, http.StatusInternalServerError, tWriter.Code)
 	}
@@ -187,7 +201,7 @@ func Test_userUpdateCtrl(t *testing.T) {
 		chiCtx.URLParams.Add("id", "10001")
 
 		tWriter := httptest.NewRecorder()
-		handler = testUserHandlers.userUpdateCtrl
+		handler = asAdmin(testUserHandlers.userUpdateCtrl)
 		handler.ServeHTTP(tWriter, reqUsers)
 		assert.Equal(t, http.StatusInternalServerError, tWriter.Code)
 	}
@@ -210,8 +224,28 @@ func Test_userUpdateCtrl(t *testing.T) {
 	err = json.Unmarshal(userData, &targetUser)
 	assert.NoError(t, err)
 	targetUser["password"] = ""
+	targetUser["lockout"] = map[string]interface{}{"locked": false, "failures": float64(0)}
 	assert.Equal(t, response.Data, targetUser)
 
+	{
+		// logins with the robot prefix are reserved for robot accounts
+		robotUser := user
+		robotUser.ID, robotUser.Login = 10001, store.RobotLoginPrefix+"ci-builder"
+		robotData, errJSON := json.Marshal(robotUser)
+		require.NoError(t, errJSON)
+
+		reqRobot, errReqRobot := http.NewRequest("PUT", "/api/v1/users/10001", bytes.NewBuffer(robotData))
+		require.NoError(t, errReqRobot)
+		chiCtx := chi.NewRouteContext()
+		reqRobot = reqRobot.WithContext(context.WithValue(reqRobot.Context(), chi.RouteCtxKey, chiCtx))
+		chiCtx.URLParams.Add("id", "10001")
+
+		tWriter := httptest.NewRecorder()
+		handler = asAdmin(testUserHandlers.userUpdateCtrl)
+		handler.ServeHTTP(tWriter, reqRobot)
+		assert.Equal(t, http.StatusBadRequest, tWriter.Code)
+	}
+
 	// test with unknown id
 	req, errReq = http.NewRequest("PUT", "/api/v1/users/-1", bytes.NewBuffer(userData))
 	require.NoError(t, errReq)
@@ -221,7 +255,7 @@ func Test_userUpdateCtrl(t *testing.T) {
 	rctx.URLParams.Add("id", "-1")
 
 	testWriter = httptest.NewRecorder()
-	handler = testUserHandlers.userUpdateCtrl
+	handler = asAdmin(testUserHandlers.userUpdateCtrl)
 	handler.ServeHTTP(testWriter, req)
 	assert.Equal(t, http.StatusInternalServerError, testWriter.Code)
 
@@ -231,7 +265,7 @@ func Test_userUpdateCtrl(t *testing.T) {
 	rctx.URLParams.Add("id", "not_parsed")
 
 	testWriter = httptest.NewRecorder()
-	handler = testUserHandlers.userUpdateCtrl
+	handler = asAdmin(testUserHandlers.userUpdateCtrl)
 	handler.ServeHTTP(testWriter, req)
 	assert.Equal(t, http.StatusBadRequest, testWriter.Code)
 }
@@ -295,7 +329,7 @@ func Test_userDeleteCtr(t *testing.T) {
 		rctx := chi.NewRouteContext()
 		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 		rctx.URLParams.Add("id", "10001")
-		handler = testUserHandlers.userDeleteCtrl
+		handler = asAdmin(testUserHandlers.userDeleteCtrl)
 		testWriter = httptest.NewRecorder()
 		handler.ServeHTTP(testWriter, req)
 		assert.Equal(t, http.StatusOK, testWriter.Code)
@@ -402,6 +436,10 @@ func prepareUserMock(t *testing.T) *engine.InterfaceMock {
 	}
 
 	return &engine.InterfaceMock{
+		GetRoleFunc: getBuiltInRole,
+		WithTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		},
 
 		CreateUserFunc: func(ctx context.Context, user *store.User) error {
 			if user.ID == 1 {
@@ -437,6 +475,11 @@ func prepareUserMock(t *testing.T) *engine.InterfaceMock {
 				if val.(int64) == user.ID {
 					return user, nil
 				}
+				for _, u := range users {
+					if u.ID == val.(int64) {
+						return u, nil
+					}
+				}
 			default:
 				return user, errors.New("unsupported val type")
 			}
@@ -525,16 +568,167 @@ func prepareUserMock(t *testing.T) *engine.InterfaceMock {
 			return errors.Errorf("user with id=%d not found", id)
 		},
 
-		DeleteAccessFunc: func(ctx context.Context, key string, id interface{}) error {
+		DeleteRefreshTokenFunc: func(ctx context.Context, key string, id interface{}) error {
+			return engine.ErrNotFound
+		},
+
+		UpdateUserTOTPFunc: func(ctx context.Context, id int64, totp store.TOTP) error {
+			return nil
+		},
+
+		DeleteOwnerAccessesFunc: func(ctx context.Context, ownerID int64, isGroup bool) error {
 
 			if value := ctx.Value(ctxKey); value != nil {
 				return errors.New("failed to delete access by user id")
 			}
 
-			if key == "owner_id" {
+			if !isGroup {
 				return nil
 			}
-			return errors.New("wrong field name for delete access by user id")
+			return errors.New("rules of a group deleted instead of rules of the user")
 		},
 	}
 }
+
+func Test_userPasswordPolicy(t *testing.T) {
+	testUserHandlers := userHandlers{passwordPolicy: store.NewPasswordPolicy(store.DefaultPasswordMinLength)}
+	testUserHandlers.l = log.Default()
+	mock := prepareUserMock(t)
+	testUserHandlers.dataStore = mock
+	testUserHandlers.registryService = prepareRegistryMock(t)
+	testUserHandlers.userAdapter = newUsersRegistryAdapter(context.Background(), engine.QueryFilter{}, mock.FindUsers)
+
+	request := func(method, id string, handler http.HandlerFunc, body interface{}) int {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, "/api/v1/users/"+id, bytes.NewBuffer(data))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(context.WithValue(req.Context(), ctxErrorKey, false), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	createUser, updateUser := asAdmin(testUserHandlers.userCreateCtrl), asAdmin(testUserHandlers.userUpdateCtrl)
+
+	newUser := store.User{Login: "new_user", Name: "new_user", Role: store.UserRole}
+	for _, password := range []string{"short", "password123", "New_User"} {
+		newUser.Password = password
+		assert.Equal(t, http.StatusBadRequest, request("POST", "", createUser, newUser), password)
+	}
+	assert.Empty(t, mock.CreateUserCalls())
+
+	newUser.Password = "correct-horse-battery"
+	assert.Equal(t, http.StatusOK, request("POST", "", createUser, newUser))
+
+	assert.Equal(t, http.StatusBadRequest, request("PUT", "10001", updateUser, map[string]string{"password": "qwerty123"}))
+	assert.Empty(t, mock.UpdateUserCalls())
+
+	assert.Equal(t, http.StatusOK, request("PUT", "10001", updateUser, map[string]string{"password": "correct-horse-battery"}))
+	calls := mock.UpdateUserCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "correct-horse-battery", calls[0].User.Password)
+
+	// an update without password keeps the stored one
+	assert.Equal(t, http.StatusOK, request("PUT", "10001", updateUser, map[string]string{"name": "renamed"}))
+	calls = mock.UpdateUserCalls()
+	require.Len(t, calls, 2)
+	assert.Empty(t, calls[1].User.Password)
+	assert.Equal(t, "renamed", calls[1].User.Name)
+}
+
+func Test_userRoleAssignment(t *testing.T) {
+	testUserHandlers := userHandlers{}
+	testUserHandlers.l = log.Default()
+	mock := prepareUserMock(t)
+	testUserHandlers.dataStore = mock
+	testUserHandlers.registryService = prepareRegistryMock(t)
+	testUserHandlers.userAdapter = newUsersRegistryAdapter(context.Background(), engine.QueryFilter{}, mock.FindUsers)
+
+	manager := token.User{Name: "qux", Role: store.ManagerRole}
+	request := func(method, id string, handler http.HandlerFunc, body interface{}) int {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, "/api/v1/users/"+id, bytes.NewBuffer(data))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(context.WithValue(req.Context(), ctxErrorKey, false), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// a user can't assign a role which grants more than the role of the user
+	newUser := store.User{Login: "new_user", Name: "new_user", Password: "correct-horse-battery", Role: store.AdminRole}
+	assert.Equal(t, http.StatusForbidden, request("POST", "", asUser(manager, testUserHandlers.userCreateCtrl), newUser))
+	assert.Equal(t, http.StatusUnauthorized, request("POST", "", testUserHandlers.userCreateCtrl, newUser))
+	assert.Empty(t, mock.CreateUserCalls())
+
+	newUser.Role = store.ManagerRole
+	assert.Equal(t, http.StatusOK, request("POST", "", asUser(manager, testUserHandlers.userCreateCtrl), newUser))
+
+	updateUser := asUser(manager, testUserHandlers.userUpdateCtrl)
+	assert.Equal(t, http.StatusForbidden, request("PUT", "10001", updateUser, map[string]string{"role": store.AdminRole}))
+	assert.Empty(t, mock.UpdateUserCalls())
+	assert.Equal(t, http.StatusOK, request("PUT", "10001", updateUser, map[string]string{"role": store.ManagerRole}))
+
+	// users with more rights can't be changed, even if the role isn't changed
+	promote := map[string]string{"role": store.AdminRole}
+	assert.Equal(t, http.StatusOK, request("PUT", "10001", asAdmin(testUserHandlers.userUpdateCtrl), promote))
+	assert.Equal(t, http.StatusForbidden, request("PUT", "10001", updateUser, map[string]string{"password": "correct-horse-staple"}))
+	assert.Equal(t, http.StatusForbidden, request("PUT", "10001", updateUser, map[string]string{"role": store.UserRole}))
+	assert.Len(t, mock.UpdateUserCalls(), 2)
+
+	// the user with more rights can't be deleted, unlocked or logged out either
+	testUserHandlers.guard = newLoginGuard(LockoutPolicy{MaxAttempts: 1, Window: time.Minute, Duration: time.Minute})
+	testUserHandlers.guard.fail("test_login", "10.0.0.1", time.Now())
+	for _, h := range []http.HandlerFunc{testUserHandlers.userTOTPResetCtrl, testUserHandlers.userUnlockCtrl,
+		testUserHandlers.userTokensRevokeCtrl, testUserHandlers.userDeleteCtrl} {
+		assert.Equal(t, http.StatusForbidden, request("DELETE", "10001", asUser(manager, h), nil))
+		assert.Equal(t, http.StatusOK, request("DELETE", "10003", asUser(manager, h), nil))
+	}
+	assert.True(t, testUserHandlers.guard.status("test_login", time.Now()).Locked)
+	require.Len(t, mock.DeleteUserCalls(), 1)
+	assert.Equal(t, int64(10003), mock.DeleteUserCalls()[0].ID)
+	require.Len(t, mock.UpdateUserTOTPCalls(), 1)
+	assert.Equal(t, int64(10003), mock.UpdateUserTOTPCalls()[0].ID)
+	require.Len(t, mock.DeleteRefreshTokenCalls(), 1)
+	assert.Equal(t, int64(10003), mock.DeleteRefreshTokenCalls()[0].ID)
+}
+
+func Test_userLockout(t *testing.T) {
+	testUserHandlers := userHandlers{guard: newLoginGuard(LockoutPolicy{MaxAttempts: 2, Window: time.Minute, Duration: time.Minute})}
+	testUserHandlers.l = log.Default()
+	testUserHandlers.dataStore = prepareUserMock(t)
+
+	testUserHandlers.guard.fail("test_login", "10.0.0.1", time.Now())
+	testUserHandlers.guard.fail("test_login", "10.0.0.1", time.Now())
+
+	request := func(method, id string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/users/"+id, http.NoBody)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	var response struct {
+		Data userView `json:"data"`
+	}
+	w := request("GET", "10001", testUserHandlers.userInfoCtrl)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.True(t, response.Data.Lockout.Locked)
+	assert.NotNil(t, response.Data.Lockout.Until)
+
+	assert.Equal(t, http.StatusBadRequest, request("DELETE", "wrong_id", testUserHandlers.userUnlockCtrl).Code)
+	assert.Equal(t, http.StatusInternalServerError, request("DELETE", "20001", asAdmin(testUserHandlers.userUnlockCtrl)).Code)
+	assert.Equal(t, http.StatusOK, request("DELETE", "10001", asAdmin(testUserHandlers.userUnlockCtrl)).Code)
+
+	w = request("GET", "10001", testUserHandlers.userInfoCtrl)
+	response.Data = userView{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, lockStatus{}, response.Data.Lockout)
+}
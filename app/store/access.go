@@ -2,6 +2,57 @@
 
 package store
 
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// RepositoryResourceType represents a single repository within a registry
+	RepositoryResourceType = "repository"
+
+	// RegistryResourceType represents the entire registry, it's used for administrative actions
+	// or lookup operations that span an entire registry, e.g. the catalog listing
+	RegistryResourceType = "registry"
+
+	// RegistryCatalogName is the name of the catalog resource of the registry type
+	RegistryCatalogName = "catalog"
+
+	// ActionAll grants all actions of the resource type
+	ActionAll = "*"
+
+	// ResourceAnyName used as the whole resource name of an access matches names of all resources
+	ResourceAnyName = "*"
+
+	// resourceSegmentWildcard matches any characters within a single segment of the resource name, e.g. 'team-a/*'
+	resourceSegmentWildcard = "*"
+
+	// resourceNamespaceWildcard matches any characters including nested segments, e.g. 'team-a/**'
+	resourceNamespaceWildcard = "**"
+
+	// AccessEffectAllow grants the action of an access, it's the default effect
+	AccessEffectAllow = "allow"
+
+	// AccessEffectDeny forbids the action of an access, deny rules take precedence over allow rules of any owner
+	AccessEffectDeny = "deny"
+)
+
+// resourcePatternRegExp allows only characters of repository names with wildcards in resource patterns
+var resourcePatternRegExp = regexp.MustCompile(`^[a-zA-Z0-9._/*-]+$`)
+
+// resourceActions defines actions which can be granted for each resource type and names of resources
+// which the type allows, any name is allowed when names list is empty
+var resourceActions = map[string]struct {
+	actions []string
+	names   []string
+}{
+	RepositoryResourceType: {actions: []string{"pull", "push", "delete", ActionAll}},
+	RegistryResourceType:   {actions: []string{ActionAll}, names: []string{RegistryCatalogName}},
+}
+
 // AccessInterface defines methods provided for Access manipulation
 type AccessInterface interface {
 	// CheckAccessPermit will check access for a specify entry
@@ -32,4 +83,142 @@ type Access struct {
 
 	// Marks a access item as disabled
 	Disabled bool `json:"disabled"`
+
+	// Validity window of the access, values are unix time and zero means the access isn't limited
+	NotBefore int64 `json:"not_before"` // the access applies since the time
+	ExpiresAt int64 `json:"expires_at"` // the access doesn't apply since the time
+
+	// Effect of the access, 'allow' grants the action and 'deny' forbids it regardless of allow rules
+	Effect string `json:"effect"`
+}
+
+// Validate checks that the access has a known resource type, an action and a resource name which allowed for the type
+func (a *Access) Validate() error {
+	if a.NotBefore < 0 || a.ExpiresAt < 0 {
+		return fmt.Errorf("validity window of access can't be negative")
+	}
+	if a.ExpiresAt > 0 && a.ExpiresAt <= a.NotBefore {
+		return fmt.Errorf("access expires before it applies")
+	}
+	if a.Effect != "" && a.Effect != AccessEffectAllow && a.Effect != AccessEffectDeny {
+		return fmt.Errorf("unknown access effect %q, allowed effects: %s, %s", a.Effect, AccessEffectAllow, AccessEffectDeny)
+	}
+	return validateResourceAction(a.Type, a.ResourceName, a.Action)
+}
+
+// IsExpired checks that the access expired at the moment
+func (a *Access) IsExpired(now time.Time) bool {
+	return a.ExpiresAt > 0 && now.Unix() >= a.ExpiresAt
+}
+
+// IsActive checks that the access applies at the moment, i.e. the moment is within its validity window
+func (a *Access) IsActive(now time.Time) bool {
+	return now.Unix() >= a.NotBefore && !a.IsExpired(now)
+}
+
+// IsDeny checks that the access forbids the action, an access without the effect allows it
+func (a *Access) IsDeny() bool {
+	return a.Effect == AccessEffectDeny
+}
+
+// validateResourceAction checks that the resource type is known and the action and the resource name are allowed for it
+func validateResourceAction(resourceType, resourceName, action string) error {
+	rules, ok := resourceActions[resourceType]
+	if !ok {
+		return fmt.Errorf("unknown resource type %q", resourceType)
+	}
+
+	if !containsString(rules.actions, action) {
+		return fmt.Errorf("action %q isn't allowed for resource type %q, allowed actions: %s",
+			action, resourceType, strings.Join(rules.actions, ", "))
+	}
+
+	if len(rules.names) > 0 && !containsString(rules.names, resourceName) {
+		return fmt.Errorf("resource name %q isn't allowed for resource type %q, allowed names: %s",
+			resourceName, resourceType, strings.Join(rules.names, ", "))
+	}
+
+	return ValidateResourcePattern(resourceName)
+}
+
+// ResourceActions returns actions which can be granted for the resource type
+func ResourceActions(resourceType string) []string {
+	return resourceActions[resourceType].actions
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsResourcePattern returns true when the resource name contains wildcards
+func IsResourcePattern(resourceName string) bool {
+	return strings.Contains(resourceName, resourceSegmentWildcard)
+}
+
+// ValidateResourcePattern checks a resource name which contains wildcards, a pattern may consist of characters
+// allowed in repository names and wildcards only. Names without wildcards aren't checked.
+func ValidateResourcePattern(resourceName string) error {
+	if !IsResourcePattern(resourceName) {
+		return nil
+	}
+	if !resourcePatternRegExp.MatchString(resourceName) {
+		return fmt.Errorf("resource pattern %q contains not allowed characters", resourceName)
+	}
+	return nil
+}
+
+// MatchResource reports whether the resource name matches the resource name of an access which can be a pattern
+func MatchResource(pattern, resourceName string) bool {
+	if pattern == resourceName || pattern == ResourceAnyName {
+		return true
+	}
+	if !IsResourcePattern(pattern) || ValidateResourcePattern(pattern) != nil {
+		return false
+	}
+
+	re, err := regexp.Compile(resourcePatternToRegExp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(resourceName)
+}
+
+// ResourceSpecificity returns the weight of a resource name of an access, when a resource matches several accesses
+// the ones with the greatest weight apply only. An exact name is the most specific, patterns are weighted by number
+// of literal characters, the segment wildcard is more specific than the namespace one with the same literal part.
+func ResourceSpecificity(pattern string) int {
+	if !IsResourcePattern(pattern) {
+		return math.MaxInt
+	}
+
+	weight := 2 * len(strings.ReplaceAll(pattern, resourceSegmentWildcard, ""))
+	if strings.Contains(pattern, resourceNamespaceWildcard) {
+		weight--
+	}
+	return weight
+}
+
+// resourcePatternToRegExp converts a resource pattern to the regular expression,
+// storage engines use the same conversion in queries
+func resourcePatternToRegExp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], resourceNamespaceWildcard):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
 }
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMatchResource(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{pattern: "team-a/app", name: "team-a/app", match: true},
+		{pattern: "team-a/app", name: "team-a/app2", match: false},
+		{pattern: "*", name: "team-a/nested/app", match: true},
+		{pattern: "**", name: "team-a/nested/app", match: true},
+		{pattern: "team-a/*", name: "team-a/app", match: true},
+		{pattern: "team-a/*", name: "team-a/nested/app", match: false},
+		{pattern: "team-a/*", name: "team-b/app", match: false},
+		{pattern: "team-a/**", name: "team-a/nested/app", match: true},
+		{pattern: "team-a/**", name: "team-ab/app", match: false},
+		{pattern: "team-*/app", name: "team-b/app", match: true},
+		{pattern: "team-*/app", name: "team-b/c/app", match: false},
+		{pattern: "team.a/*", name: "teamxa/app", match: false},
+		{pattern: "team+a/*", name: "team+a/app", match: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, MatchResource(tt.pattern, tt.name), "pattern %s, name %s", tt.pattern, tt.name)
+	}
+}
+
+func TestResourceSpecificity(t *testing.T) {
+	ordered := []string{"team-a/app", "team-a/ap*", "team-a/*", "team-a/**", "team-*", "*", "**"}
+	for i := 1; i < len(ordered); i++ {
+		assert.Greater(t, ResourceSpecificity(ordered[i-1]), ResourceSpecificity(ordered[i]), "%s > %s", ordered[i-1], ordered[i])
+	}
+}
+
+func TestValidateResourcePattern(t *testing.T) {
+	assert.NoError(t, ValidateResourcePattern("team-a/**"))
+	assert.NoError(t, ValidateResourcePattern("any name (without wildcards)"))
+	assert.Error(t, ValidateResourcePattern("team-a/(a|b)*"))
+	assert.Error(t, ValidateResourcePattern("team#/*"))
+}
+
+func TestAccess_Validate(t *testing.T) {
+	tests := []struct {
+		access Access
+		valid  bool
+	}{
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull"}, valid: true},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/*", Action: ActionAll}, valid: true},
+		{access: Access{Type: RegistryResourceType, ResourceName: RegistryCatalogName, Action: ActionAll}, valid: true},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull:push"}},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/(app)*", Action: "pull"}},
+		{access: Access{Type: RegistryResourceType, ResourceName: RegistryCatalogName, Action: "pull"}},
+		{access: Access{Type: RegistryResourceType, ResourceName: "team-a/app", Action: ActionAll}},
+		{access: Access{Type: "unknown", ResourceName: "team-a/app", Action: "pull"}},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", NotBefore: 100, ExpiresAt: 200}, valid: true},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", ExpiresAt: 200}, valid: true},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", NotBefore: 200, ExpiresAt: 200}},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", ExpiresAt: -1}},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", Effect: AccessEffectDeny}, valid: true},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", Effect: AccessEffectAllow}, valid: true},
+		{access: Access{Type: RepositoryResourceType, ResourceName: "team-a/app", Action: "pull", Effect: "block"}},
+	}
+
+	for _, tt := range tests {
+		err := tt.access.Validate()
+		if tt.valid {
+			assert.NoError(t, err, "access %+v", tt.access)
+			continue
+		}
+		assert.Error(t, err, "access %+v", tt.access)
+	}
+}
+
+func TestAccess_IsActive(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		access  Access
+		active  bool
+		expired bool
+	}{
+		{access: Access{}, active: true},
+		{access: Access{NotBefore: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(time.Hour).Unix()}, active: true},
+		{access: Access{NotBefore: now.Add(time.Hour).Unix()}},
+		{access: Access{ExpiresAt: now.Unix()}, expired: true},
+		{access: Access{NotBefore: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()}, expired: true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.active, tt.access.IsActive(now), "access %+v", tt.access)
+		assert.Equal(t, tt.expired, tt.access.IsExpired(now), "access %+v", tt.access)
+	}
+}
+
+func TestAccess_IsDeny(t *testing.T) {
+	assert.True(t, (&Access{Effect: AccessEffectDeny}).IsDeny())
+	assert.False(t, (&Access{Effect: AccessEffectAllow}).IsDeny())
+	assert.False(t, (&Access{}).IsDeny())
+}
@@ -0,0 +1,442 @@
+// Package backup implements export of storage data to an archive and import it back to any storage engine.
+// An archive is a versioned JSON document which contains users (with password hashes), groups, groups membership,
+// access rules, repositories entries, robot accounts (with secret hashes) and custom roles.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// FormatVersion is a version of archive format which produced by Export.
+// It should be increased when archive structure changes in a backward incompatible way.
+const FormatVersion = 1
+
+// Archive is a snapshot of storage data
+type Archive struct {
+	Version      int                   `json:"version"`
+	CreatedAt    time.Time             `json:"created_at"`
+	Groups       []store.Group         `json:"groups"`
+	Users        []store.User          `json:"users"`
+	Members      []store.GroupMember   `json:"members"`
+	Accesses     []store.Access        `json:"accesses"`
+	Repositories []store.RegistryEntry `json:"repositories"`
+	Robots       []store.Robot         `json:"robots"`
+	Roles        []store.Role          `json:"roles,omitempty"`
+}
+
+// Export reads all records from storage and writes them as an archive to w
+func Export(ctx context.Context, storage engine.Interface, w io.Writer) error {
+	archive, err := Snapshot(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(archive); err != nil {
+		return errors.Wrap(err, "failed to write backup archive")
+	}
+	return nil
+}
+
+// Snapshot reads all records from storage in a single transaction, so the archive is consistent
+func Snapshot(ctx context.Context, storage engine.Interface) (archive Archive, err error) {
+	archive = Archive{Version: FormatVersion, CreatedAt: time.Now().UTC()}
+
+	err = storage.WithTx(ctx, func(ctx context.Context) error {
+		groups, errFind := storage.FindGroups(ctx, engine.QueryFilter{})
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read groups")
+		}
+		for _, v := range groups.Data {
+			archive.Groups = append(archive.Groups, v.(store.Group))
+		}
+
+		users, errFind := storage.FindUsers(ctx, engine.QueryFilter{}, true)
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read users")
+		}
+		for _, v := range users.Data {
+			archive.Users = append(archive.Users, v.(store.User))
+		}
+
+		members, errFind := storage.FindGroupMembers(ctx, engine.QueryFilter{})
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read groups members")
+		}
+		for _, v := range members.Data {
+			archive.Members = append(archive.Members, v.(store.GroupMember))
+		}
+
+		accesses, errFind := storage.FindAccesses(ctx, engine.QueryFilter{})
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read accesses")
+		}
+		for _, v := range accesses.Data {
+			archive.Accesses = append(archive.Accesses, v.(store.Access))
+		}
+
+		entries, errFind := storage.FindRepositories(ctx, engine.QueryFilter{})
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read repositories")
+		}
+		for _, v := range entries.Data {
+			archive.Repositories = append(archive.Repositories, v.(store.RegistryEntry))
+		}
+
+		robots, errFind := storage.FindRobots(ctx, engine.QueryFilter{}, true)
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read robots")
+		}
+		for _, v := range robots.Data {
+			archive.Robots = append(archive.Robots, v.(store.Robot))
+		}
+
+		// built-in roles are created by every storage, so only custom ones are saved
+		roles, errFind := storage.FindRoles(ctx, engine.QueryFilter{Filters: map[string]interface{}{"built_in": false}})
+		if errFind != nil {
+			return errors.Wrap(errFind, "failed to read roles")
+		}
+		for _, v := range roles.Data {
+			archive.Roles = append(archive.Roles, v.(store.Role))
+		}
+		return nil
+	})
+
+	return archive, err
+}
+
+// Import reads an archive from r and restores it to storage
+func Import(ctx context.Context, storage engine.Interface, r io.Reader) error {
+	var archive Archive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return errors.Wrap(err, "failed to read backup archive")
+	}
+	return Restore(ctx, storage, archive)
+}
+
+// Restore writes archive records to storage in a single transaction.
+// Records match existing ones by natural keys: groups by name, users by login, accesses by owner, resource and action,
+// repositories by name and tag, robots and roles by name. Matched records are updated with archive data, other ones are created.
+// Because record IDs may differ between storages, user groups and access owners are remapped to IDs of restored records.
+func Restore(ctx context.Context, storage engine.Interface, archive Archive) error {
+	if archive.Version < 1 || archive.Version > FormatVersion {
+		return errors.Errorf("unsupported backup archive version %d", archive.Version)
+	}
+
+	// passwords and robot secrets in archive are hashed already
+	ctx = engine.SetPasswordHashed(ctx)
+
+	return storage.WithTx(ctx, func(ctx context.Context) error {
+		// roles are restored first, because users can be assigned to custom roles only when they exist
+		if err := restoreRoles(ctx, storage, archive.Roles); err != nil {
+			return err
+		}
+
+		groupsID, err := restoreGroups(ctx, storage, archive.Groups)
+		if err != nil {
+			return err
+		}
+
+		usersID, err := restoreUsers(ctx, storage, archive.Users, groupsID)
+		if err != nil {
+			return err
+		}
+
+		if err = restoreMembers(ctx, storage, archive.Members, groupsID, usersID); err != nil {
+			return err
+		}
+
+		if err = restoreAccesses(ctx, storage, archive.Accesses, groupsID, usersID); err != nil {
+			return err
+		}
+
+		if err = restoreRepositories(ctx, storage, archive.Repositories); err != nil {
+			return err
+		}
+
+		if err = restoreRobots(ctx, storage, archive.Robots); err != nil {
+			return err
+		}
+
+		log.Printf("[INFO] restored %d roles, %d groups, %d users, %d groups members, %d accesses, %d repositories entries "+
+			"and %d robots", len(archive.Roles), len(archive.Groups), len(archive.Users), len(archive.Members),
+			len(archive.Accesses), len(archive.Repositories), len(archive.Robots))
+		return nil
+	})
+}
+
+// restoreGroups returns map of archive group IDs to storage group IDs
+func restoreGroups(ctx context.Context, storage engine.Interface, groups []store.Group) (map[int64]int64, error) {
+	result, err := storage.FindGroups(ctx, engine.QueryFilter{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read groups")
+	}
+
+	existing := make(map[string]store.Group, len(result.Data))
+	for _, v := range result.Data {
+		g := v.(store.Group)
+		existing[g.Name] = g
+	}
+
+	ids := make(map[int64]int64, len(groups))
+	for _, g := range groups {
+		archiveID := g.ID
+		if current, ok := existing[g.Name]; ok {
+			g.ID = current.ID
+			if g != current {
+				if err = storage.UpdateGroup(ctx, g); err != nil {
+					return nil, errors.Wrapf(err, "failed to update group %q", g.Name)
+				}
+			}
+		} else if err = storage.CreateGroup(ctx, &g); err != nil {
+			return nil, errors.Wrapf(err, "failed to create group %q", g.Name)
+		}
+		ids[archiveID] = g.ID
+	}
+	return ids, nil
+}
+
+// restoreUsers returns map of archive user IDs to storage user IDs
+func restoreUsers(ctx context.Context, storage engine.Interface, users []store.User, groupsID map[int64]int64) (map[int64]int64, error) {
+	result, err := storage.FindUsers(ctx, engine.QueryFilter{}, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read users")
+	}
+
+	existing := make(map[string]store.User, len(result.Data))
+	for _, v := range result.Data {
+		u := v.(store.User)
+		existing[u.Login] = u
+	}
+
+	ids := make(map[int64]int64, len(users))
+	for _, u := range users {
+		archiveID := u.ID
+		if id, ok := groupsID[u.Group]; ok {
+			u.Group = id
+		}
+
+		if current, ok := existing[u.Login]; ok {
+			u.ID = current.ID
+			u.TOTP = current.TOTP // two-factor authentication settings aren't a part of archive and kept as is
+			if !reflect.DeepEqual(u, current) {
+				if err = storage.UpdateUser(ctx, u); err != nil {
+					return nil, errors.Wrapf(err, "failed to update user %q", u.Login)
+				}
+			}
+		} else if err = storage.CreateUser(ctx, &u); err != nil {
+			return nil, errors.Wrapf(err, "failed to create user %q", u.Login)
+		}
+		ids[archiveID] = u.ID
+	}
+	return ids, nil
+}
+
+// restoreMembers adds users to groups, membership in primary groups is created with users already.
+// Archives made before groups membership was introduced don't have members, users keep primary groups only then.
+func restoreMembers(ctx context.Context, storage engine.Interface, members []store.GroupMember, groupsID, usersID map[int64]int64) error {
+	result, err := storage.FindGroupMembers(ctx, engine.QueryFilter{})
+	if err != nil {
+		return errors.Wrap(err, "failed to read groups members")
+	}
+
+	existing := make(map[store.GroupMember]bool, len(result.Data))
+	for _, v := range result.Data {
+		existing[v.(store.GroupMember)] = true
+	}
+
+	for _, m := range members {
+		groupID, okGroup := groupsID[m.GroupID]
+		userID, okUser := usersID[m.UserID]
+		if !okGroup || !okUser {
+			log.Printf("[WARN] member of group skipped, group %d or user %d not found in backup", m.GroupID, m.UserID)
+			continue
+		}
+
+		m = store.GroupMember{GroupID: groupID, UserID: userID}
+		if existing[m] {
+			continue
+		}
+
+		if err = storage.AddGroupMember(ctx, m); err != nil {
+			return errors.Wrapf(err, "failed to add user %d to group %d", m.UserID, m.GroupID)
+		}
+	}
+	return nil
+}
+
+func restoreAccesses(ctx context.Context, storage engine.Interface, accesses []store.Access, groupsID, usersID map[int64]int64) error {
+	result, err := storage.FindAccesses(ctx, engine.QueryFilter{})
+	if err != nil {
+		return errors.Wrap(err, "failed to read accesses")
+	}
+
+	// a user and a group can have the same id, so a kind of owner is a part of the key,
+	// an allow rule and a deny rule for the same resource and action are different rules
+	accessKey := func(a store.Access) string {
+		return fmt.Sprintf("%d:%t:%s:%s:%s:%s", a.Owner, a.IsGroup, a.Type, a.ResourceName, a.Action, a.Effect)
+	}
+
+	existing := make(map[string]store.Access, len(result.Data))
+	for _, v := range result.Data {
+		a := v.(store.Access)
+		existing[accessKey(a)] = a
+	}
+
+	for _, a := range accesses {
+		// archives of versions without deny rules contain allow rules only
+		if a.Effect == "" {
+			a.Effect = store.AccessEffectAllow
+		}
+
+		// special owners, e.g. anonymous or any registered user, haven't records in storage
+		if a.Owner > 0 {
+			ids := usersID
+			if a.IsGroup {
+				ids = groupsID
+			}
+
+			id, ok := ids[a.Owner]
+			if !ok {
+				log.Printf("[WARN] access %q skipped, owner with id %d not found in backup", a.Name, a.Owner)
+				continue
+			}
+			a.Owner = id
+		}
+
+		if current, ok := existing[accessKey(a)]; ok {
+			a.ID = current.ID
+			if a != current {
+				if err = storage.UpdateAccess(ctx, a); err != nil {
+					return errors.Wrapf(err, "failed to update access %q", a.Name)
+				}
+			}
+			continue
+		}
+
+		if err = storage.CreateAccess(ctx, &a); err != nil {
+			return errors.Wrapf(err, "failed to create access %q", a.Name)
+		}
+	}
+	return nil
+}
+
+func restoreRepositories(ctx context.Context, storage engine.Interface, entries []store.RegistryEntry) error {
+	result, err := storage.FindRepositories(ctx, engine.QueryFilter{})
+	if err != nil {
+		return errors.Wrap(err, "failed to read repositories")
+	}
+
+	existing := make(map[string]store.RegistryEntry, len(result.Data))
+	for _, v := range result.Data {
+		e := v.(store.RegistryEntry)
+		existing[e.RepositoryName+":"+e.Tag] = e
+	}
+
+	for _, e := range entries {
+		current, ok := existing[e.RepositoryName+":"+e.Tag]
+		if !ok {
+			if err = storage.CreateRepository(ctx, &e); err != nil {
+				return errors.Wrapf(err, "failed to create repository entry %s:%s", e.RepositoryName, e.Tag)
+			}
+			continue
+		}
+
+		e.ID = current.ID
+		if e == current {
+			continue
+		}
+
+		conditionClause := map[string]interface{}{
+			store.RegistryRepositoryNameField: e.RepositoryName,
+			store.RegistryTagField:            e.Tag,
+		}
+		data := map[string]interface{}{
+			store.RegistryContentDigestField: e.Digest,
+			store.RegistryConfigDigestField:  e.ConfigDigest,
+			store.RegistrySizeNameField:      e.Size,
+			store.RegistryPullCounterField:   e.PullCounter,
+			store.RegistryTimestampField:     e.Timestamp,
+			store.RegistryRawField:           e.Raw,
+		}
+		if err = storage.UpdateRepository(ctx, conditionClause, data); err != nil {
+			return errors.Wrapf(err, "failed to update repository entry %s:%s", e.RepositoryName, e.Tag)
+		}
+	}
+	return nil
+}
+
+func restoreRobots(ctx context.Context, storage engine.Interface, robots []store.Robot) error {
+	result, err := storage.FindRobots(ctx, engine.QueryFilter{}, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to read robots")
+	}
+
+	existing := make(map[string]int64, len(result.Data))
+	for _, v := range result.Data {
+		r := v.(store.Robot)
+		existing[r.Name] = r.ID
+	}
+
+	for _, r := range robots {
+		if id, ok := existing[r.Name]; ok {
+			r.ID = id
+			if err = storage.UpdateRobot(ctx, r); err != nil {
+				return errors.Wrapf(err, "failed to update robot %q", r.Name)
+			}
+			continue
+		}
+
+		if err = storage.CreateRobot(ctx, &r); err != nil {
+			return errors.Wrapf(err, "failed to create robot %q", r.Name)
+		}
+	}
+	return nil
+}
+
+// restoreRoles restores custom roles, archive roles which have names of built-in ones are skipped,
+// because built-in roles can't be changed
+func restoreRoles(ctx context.Context, storage engine.Interface, roles []store.Role) error {
+	result, err := storage.FindRoles(ctx, engine.QueryFilter{})
+	if err != nil {
+		return errors.Wrap(err, "failed to read roles")
+	}
+
+	existing := make(map[string]store.Role, len(result.Data))
+	for _, v := range result.Data {
+		r := v.(store.Role)
+		existing[r.Name] = r
+	}
+
+	for _, r := range roles {
+		current, ok := existing[r.Name]
+		if ok && current.BuiltIn {
+			continue
+		}
+
+		r.BuiltIn = false
+		if ok {
+			r.ID = current.ID
+			if err = storage.UpdateRole(ctx, r); err != nil {
+				return errors.Wrapf(err, "failed to update role %q", r.Name)
+			}
+			continue
+		}
+
+		if err = storage.CreateRole(ctx, &r); err != nil {
+			return errors.Wrapf(err, "failed to create role %q", r.Name)
+		}
+	}
+	return nil
+}
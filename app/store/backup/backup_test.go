@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"github.com/zebox/registry-admin/app/store/engine/embedded"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := prepareStorage(ctx, t, "source.db")
+	group := store.Group{Name: "developers", Description: "test group"}
+	require.NoError(t, source.CreateGroup(ctx, &group))
+
+	role := store.Role{Name: "auditor", Description: "test role", Permissions: []string{store.PermissionAccessRead}}
+	require.NoError(t, source.CreateRole(ctx, &role))
+
+	user := store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: role.Name, Group: group.ID}
+	require.NoError(t, source.CreateUser(ctx, &user))
+
+	secondGroup := store.Group{Name: "testers"}
+	require.NoError(t, source.CreateGroup(ctx, &secondGroup))
+	require.NoError(t, source.AddGroupMember(ctx, store.GroupMember{GroupID: secondGroup.ID, UserID: user.ID}))
+
+	accesses := []store.Access{
+		{Name: "user access", Owner: user.ID, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+		{Name: "group access", Owner: group.ID, IsGroup: true, Type: "repository", ResourceName: "foo/bar", Action: "push"},
+		{Name: "anonymous access", Owner: engine.AnonymousUserID, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+	}
+	for i := range accesses {
+		require.NoError(t, source.CreateAccess(ctx, &accesses[i]))
+	}
+
+	entry := store.RegistryEntry{RepositoryName: "foo/bar", Tag: "latest", Digest: "sha256:1", ConfigDigest: "sha256:2",
+		Size: 100, PullCounter: 5, Timestamp: 1000, Raw: `{"test":"raw"}`}
+	require.NoError(t, source.CreateRepository(ctx, &entry))
+
+	robot := store.Robot{Name: "ci-builder", Secret: "robot_secret", ExpiresAt: 2000, Permissions: []store.RobotPermission{
+		{Type: store.RepositoryResourceType, ResourceName: "foo/*", Actions: []string{"pull"}},
+	}}
+	require.NoError(t, source.CreateRobot(ctx, &robot))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, Export(ctx, source, buf))
+
+	// records IDs of the target storage differ from the source one
+	target := prepareStorage(ctx, t, "target.db")
+	require.NoError(t, target.CreateGroup(ctx, &store.Group{Name: "other"}))
+	require.NoError(t, target.CreateUser(ctx, &store.User{Login: "bar", Name: "bar", Password: "bar_password", Role: store.UserRole}))
+
+	archive := buf.Bytes()
+	require.NoError(t, Import(ctx, target, bytes.NewReader(archive)))
+
+	restoredUser, err := target.GetUser(ctx, "foo")
+	require.NoError(t, err)
+	assert.NotEqual(t, user.ID, restoredUser.ID)
+	assert.Equal(t, role.Name, restoredUser.Role)
+	assert.True(t, store.ComparePassword(restoredUser.Password, "foo_password"))
+
+	restoredRole, err := target.GetRole(ctx, role.Name)
+	require.NoError(t, err)
+	role.ID = restoredRole.ID
+	assert.Equal(t, role, restoredRole)
+
+	restoredGroup, err := target.GetGroup(ctx, restoredUser.Group)
+	require.NoError(t, err)
+	assert.Equal(t, group.Name, restoredGroup.Name)
+
+	members, err := target.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": restoredUser.ID}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), members.Total)
+	groups, err := target.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"name": "testers"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), groups.Total)
+	assert.ElementsMatch(t, []interface{}{
+		store.GroupMember{GroupID: restoredGroup.ID, UserID: restoredUser.ID},
+		store.GroupMember{GroupID: groups.Data[0].(store.Group).ID, UserID: restoredUser.ID},
+	}, members.Data)
+
+	result, err := target.FindAccesses(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), result.Total)
+	for _, v := range result.Data {
+		a := v.(store.Access)
+		switch a.Name {
+		case "user access":
+			assert.Equal(t, restoredUser.ID, a.Owner)
+		case "group access":
+			assert.Equal(t, restoredGroup.ID, a.Owner)
+		case "anonymous access":
+			assert.Equal(t, engine.AnonymousUserID, a.Owner)
+		default:
+			t.Errorf("unexpected access %q", a.Name)
+		}
+	}
+
+	result, err = target.FindRepositories(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	restoredEntry := result.Data[0].(store.RegistryEntry)
+	entry.ID = restoredEntry.ID
+	assert.Equal(t, entry, restoredEntry)
+
+	restoredRobot, err := target.GetRobot(ctx, "ci-builder")
+	require.NoError(t, err)
+	robot.ID = restoredRobot.ID
+	assert.Equal(t, robot, restoredRobot)
+	assert.True(t, store.ComparePassword(restoredRobot.Secret, "robot_secret"))
+
+	// repeated restore updates existing records without duplicates
+	require.NoError(t, source.UpdateRepository(ctx, map[string]interface{}{"repository_name": "foo/bar", "tag": "latest"},
+		map[string]interface{}{"pull_counter": 10}))
+	buf.Reset()
+	require.NoError(t, Export(ctx, source, buf))
+	require.NoError(t, Import(ctx, target, buf))
+
+	users, err := target.FindUsers(ctx, engine.QueryFilter{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), users.Total)
+
+	members, err = target.FindGroupMembers(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), members.Total)
+
+	result, err = target.FindAccesses(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+
+	result, err = target.FindRepositories(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, int64(10), result.Data[0].(store.RegistryEntry).PullCounter)
+
+	result, err = target.FindRobots(ctx, engine.QueryFilter{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+
+	result, err = target.FindRoles(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(store.BuiltInRoles())+1), result.Total)
+}
+
+func TestRestore_unsupportedVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := prepareStorage(ctx, t, "version.db")
+	err := Restore(ctx, storage, Archive{Version: FormatVersion + 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported backup archive version")
+
+	err = Import(ctx, storage, bytes.NewBufferString("not a json"))
+	assert.Error(t, err)
+}
+
+func TestRestore_rollback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := prepareStorage(ctx, t, "rollback.db")
+	archive := Archive{
+		Version: FormatVersion,
+		Groups:  []store.Group{{ID: 10, Name: "restored"}},
+		Users:   []store.User{{ID: 10, Login: "restored", Name: "restored", Password: "hash", Role: "unknown"}},
+	}
+
+	// a user with unknown role fails the whole restore
+	require.Error(t, Restore(ctx, storage, archive))
+
+	groups, err := storage.FindGroups(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), groups.Total)
+}
+
+func TestRestore_accessOwnerKind(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the default admin user and the default group of a new storage have the same id
+	storage := prepareStorage(ctx, t, "owner_kind.db")
+	admin, err := storage.GetUser(ctx, "admin")
+	require.NoError(t, err)
+	userAccess := store.Access{Name: "admin pull", Owner: admin.ID, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, storage.CreateAccess(ctx, &userAccess))
+
+	archive := Archive{
+		Version: FormatVersion,
+		Groups:  []store.Group{{ID: 10, Name: "default", Description: "Default administration group"}},
+		Accesses: []store.Access{
+			{Name: "group pull", Owner: 10, IsGroup: true, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+		},
+	}
+	require.NoError(t, Restore(ctx, storage, archive))
+
+	result, err := storage.GetAccess(ctx, userAccess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, userAccess, result, "a rule of the user with the same id as the group shouldn't be changed")
+
+	accesses, err := storage.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"is_group": true}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), accesses.Total)
+	assert.Equal(t, "group pull", accesses.Data[0].(store.Access).Name)
+	assert.Equal(t, admin.Group, accesses.Data[0].(store.Access).Owner)
+}
+
+func TestRestore_accessEffect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := prepareStorage(ctx, t, "effect.db")
+	allowAccess := store.Access{Name: "anonymous pull", Owner: engine.AnonymousUserID, Type: "repository",
+		ResourceName: "foo/bar", Action: "pull", Effect: store.AccessEffectAllow}
+	require.NoError(t, storage.CreateAccess(ctx, &allowAccess))
+
+	archive := Archive{
+		Version: FormatVersion,
+		Accesses: []store.Access{
+			{Name: "anonymous pull", Owner: engine.AnonymousUserID, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+			{Name: "anonymous deny", Owner: engine.AnonymousUserID, Type: "repository", ResourceName: "foo/bar", Action: "pull",
+				Effect: store.AccessEffectDeny},
+		},
+	}
+
+	// repeated restore doesn't duplicate rules, a rule without effect of old archives matches an allow rule
+	for i := 0; i < 2; i++ {
+		require.NoError(t, Restore(ctx, storage, archive))
+
+		result, err := storage.FindAccesses(ctx, engine.QueryFilter{Sort: []string{"id", "asc"}})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), result.Total)
+		assert.Equal(t, allowAccess, result.Data[0].(store.Access), "the allow rule shouldn't be replaced by the deny rule")
+		assert.Equal(t, store.AccessEffectDeny, result.Data[1].(store.Access).Effect)
+	}
+}
+
+func prepareStorage(ctx context.Context, t *testing.T, name string) engine.Interface {
+	dbPath := filepath.Join(t.TempDir(), name)
+	storage := embedded.NewPureGoEmbedded(dbPath)
+	require.NoError(t, storage.Connect(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, storage.Close(ctx))
+		_ = os.Remove(dbPath)
+	})
+	return storage
+}
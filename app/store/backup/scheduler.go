@@ -0,0 +1,118 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const (
+	archivePrefix     = "registry-admin-"
+	archiveExt        = ".json"
+	archiveTimeLayout = "20060102T150405Z"
+)
+
+// Scheduler makes backups of storage data periodically and keeps only a limited number of the latest archives
+type Scheduler struct {
+	Storage   engine.Interface
+	Dir       string        // a directory where archives are stored
+	Interval  time.Duration // period between backups
+	Retention int           // count of the latest archives which should be kept, all archives keep when value is zero
+}
+
+// Run makes backups until context canceled
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("[INFO] scheduled backup enabled, interval %s, directory %s", s.Interval, s.Dir)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fileName, err := s.Backup(ctx)
+			if err != nil {
+				log.Printf("[ERROR] scheduled backup failed: %v", err)
+				continue
+			}
+			log.Printf("[INFO] scheduled backup saved to %s", fileName)
+
+			if err = s.cleanup(); err != nil {
+				log.Printf("[WARN] failed to remove outdated backups: %v", err)
+			}
+		}
+	}
+}
+
+// Backup writes a new archive file to the backups directory and returns its path
+func (s *Scheduler) Backup(ctx context.Context) (fileName string, err error) {
+	if err = os.MkdirAll(s.Dir, 0o700); err != nil {
+		return "", errors.Wrap(err, "failed to create backup directory")
+	}
+
+	// write to a temporary file first, an incomplete archive mustn't be taken for a valid one
+	tmp, err := os.CreateTemp(s.Dir, ".backup-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create backup file")
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	err = Export(ctx, s.Storage, tmp)
+	if errClose := tmp.Close(); err == nil && errClose != nil {
+		err = errors.Wrap(errClose, "failed to close backup file")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	fileName = filepath.Join(s.Dir, archivePrefix+time.Now().UTC().Format(archiveTimeLayout)+archiveExt)
+	if err = os.Rename(tmp.Name(), fileName); err != nil {
+		return "", errors.Wrap(err, "failed to save backup file")
+	}
+	return fileName, nil
+}
+
+// cleanup removes the oldest archives which exceed retention count
+func (s *Scheduler) cleanup() error {
+	if s.Retention <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	var archives []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), archivePrefix) && strings.HasSuffix(e.Name(), archiveExt) {
+			archives = append(archives, e.Name())
+		}
+	}
+
+	if len(archives) <= s.Retention {
+		return nil
+	}
+
+	// archive names contain creation time, so lexical order is chronological
+	sort.Strings(archives)
+	for _, name := range archives[:len(archives)-s.Retention] {
+		if err = os.Remove(filepath.Join(s.Dir, name)); err != nil {
+			return err
+		}
+		log.Printf("[DEBUG] outdated backup %s removed", name)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Backup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := Scheduler{
+		Storage:   prepareStorage(ctx, t, "scheduler.db"),
+		Dir:       filepath.Join(t.TempDir(), "backups"),
+		Retention: 2,
+	}
+
+	fileName, err := s.Backup(ctx)
+	require.NoError(t, err)
+
+	fh, err := os.Open(fileName) //nolint:gosec // test file
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, fh.Close()) }()
+
+	target := prepareStorage(ctx, t, "scheduler_target.db")
+	require.NoError(t, Import(ctx, target, fh))
+
+	info, err := os.Stat(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestScheduler_cleanup(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		archivePrefix + "20230101T000000Z" + archiveExt,
+		archivePrefix + "20230102T000000Z" + archiveExt,
+		archivePrefix + "20230103T000000Z" + archiveExt,
+		archivePrefix + "20230104T000000Z" + archiveExt,
+		"unrelated.json",
+	}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600))
+	}
+
+	s := Scheduler{Dir: dir, Retention: 2}
+	require.NoError(t, s.cleanup())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var rest []string
+	for _, e := range entries {
+		rest = append(rest, e.Name())
+	}
+	assert.ElementsMatch(t, []string{names[2], names[3], "unrelated.json"}, rest)
+
+	// zero retention keeps all archives
+	s.Retention = 0
+	require.NoError(t, s.cleanup())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := Scheduler{
+		Storage:   prepareStorage(ctx, t, "run.db"),
+		Dir:       t.TempDir(),
+		Interval:  50 * time.Millisecond,
+		Retention: 1,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(s.Dir)
+		return err == nil && len(entries) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
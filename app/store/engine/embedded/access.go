@@ -39,6 +39,10 @@ func (e *Embedded) CreateAccess(ctx context.Context, access *store.Access) (err
 		return fmt.Errorf("required access fields not set: %s", strings.Join(emptyParams, ", "))
 	}
 
+	if access.Effect == "" {
+		access.Effect = store.AccessEffectAllow
+	}
+
 	createAccessSQL := fmt.Sprintf(`INSERT INTO %s (
 		owner_id,
 		is_group,
@@ -46,18 +50,22 @@ func (e *Embedded) CreateAccess(ctx context.Context, access *store.Access) (err
 		resource_type,
 		resource_name,
 		action,
-		disabled
-	) values (?, ?, ?, ?, ?, ?, ?)`, accessTable)
-	stmt, err := e.db.PrepareContext(ctx, createAccessSQL)
+		disabled,
+		not_before,
+		expires_at,
+		effect
+	) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, accessTable)
+	stmt, err := e.conn(ctx).PrepareContext(ctx, createAccessSQL)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to add new access")
 	}
 
 	defer func() { _ = stmt.Close() }()
-	result, err := stmt.ExecContext(ctx, access.Owner, access.IsGroup, access.Name, access.Type, access.ResourceName, access.Action, access.Disabled)
+	result, err := stmt.ExecContext(ctx, access.Owner, access.IsGroup, access.Name, access.Type, access.ResourceName, access.Action, access.Disabled,
+		access.NotBefore, access.ExpiresAt, access.Effect)
 	if err != nil {
-		return errors.Wrap(err, "failed to add new access")
+		return errors.Wrap(normalizeError(err), "failed to add new access")
 	}
 
 	id, err := result.LastInsertId()
@@ -71,7 +79,7 @@ func (e *Embedded) CreateAccess(ctx context.Context, access *store.Access) (err
 func (e *Embedded) GetAccess(ctx context.Context, id int64) (access store.Access, err error) {
 
 	queryFilter := fmt.Sprintf("select * from %s where id = ?", accessTable)
-	stmt, err := e.db.PrepareContext(ctx, queryFilter)
+	stmt, err := e.conn(ctx).PrepareContext(ctx, queryFilter)
 	if err != nil {
 		return access, errors.Wrap(err, "failed to prepare query for group get")
 	}
@@ -86,23 +94,27 @@ func (e *Embedded) GetAccess(ctx context.Context, id int64) (access store.Access
 	emptyResult := true
 	for rows.Next() {
 
-		if err = rows.Scan(&access.ID, &access.Owner, &access.IsGroup, &access.Name, &access.Type, &access.ResourceName, &access.Action, &access.Disabled); err != nil {
+		if err = rows.Scan(&access.ID, &access.Owner, &access.IsGroup, &access.Name, &access.Type, &access.ResourceName, &access.Action, &access.Disabled,
+			&access.NotBefore, &access.ExpiresAt, &access.Effect); err != nil {
 			return access, errors.Wrap(err, "failed scan access data")
 		}
 		emptyResult = false
 	}
 	if emptyResult {
-		return access, errors.New("access record not found")
+		return access, fmt.Errorf("access %w", engine.ErrNotFound)
 	}
 	return access, nil
 }
 
 // FindAccesses get list of existed users access
 func (e *Embedded) FindAccesses(ctx context.Context, filter engine.QueryFilter) (accesses engine.ListResponse, err error) {
-	f := filtersBuilder(filter, "name", "resource_name")
-	queryString := fmt.Sprintf("SELECT * FROM %s %s", accessTable, f.allClauses) //nolint:gosec // query sanitizing calling before
+	f, err := filtersBuilder(accessTable, filter, "name", "resource_name")
+	if err != nil {
+		return accesses, err
+	}
+	queryString := fmt.Sprintf("SELECT * FROM %s %s", accessTable, f.allClauses) //nolint:gosec // values passed as bound params
 
-	rows, err := e.db.QueryContext(ctx, queryString)
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, f.args...)
 	if err != nil {
 		return accesses, errors.Wrap(err, "failed to get access list")
 	}
@@ -111,13 +123,14 @@ func (e *Embedded) FindAccesses(ctx context.Context, filter engine.QueryFilter)
 	}()
 	accesses.Data = []interface{}{}
 
-	if accesses.Total = e.getTotalRecordsExcludeRange(accessTable, filter, []string{"owner_id", "resource_name"}); accesses.Total == 0 {
+	if accesses.Total = e.getTotalRecordsExcludeRange(ctx, accessTable, filter, []string{"name", "resource_name"}); accesses.Total == 0 {
 		return accesses, nil // may be error handler catch
 	}
 
 	for rows.Next() {
 		var tmpAccess store.Access
-		if err = rows.Scan(&tmpAccess.ID, &tmpAccess.Owner, &tmpAccess.IsGroup, &tmpAccess.Name, &tmpAccess.Type, &tmpAccess.ResourceName, &tmpAccess.Action, &tmpAccess.Disabled); err != nil {
+		if err = rows.Scan(&tmpAccess.ID, &tmpAccess.Owner, &tmpAccess.IsGroup, &tmpAccess.Name, &tmpAccess.Type, &tmpAccess.ResourceName, &tmpAccess.Action, &tmpAccess.Disabled,
+			&tmpAccess.NotBefore, &tmpAccess.ExpiresAt, &tmpAccess.Effect); err != nil {
 			return accesses, errors.Wrap(err, "failed scan access data")
 		}
 		accesses.Data = append(accesses.Data, tmpAccess)
@@ -129,11 +142,17 @@ func (e *Embedded) FindAccesses(ctx context.Context, filter engine.QueryFilter)
 // UpdateAccess will update access record
 func (e *Embedded) UpdateAccess(ctx context.Context, access store.Access) (err error) {
 
-	// fields order: owner_id, is_group, name, resource_type, resource_name, action, disabled
-	res, err := e.db.ExecContext(ctx, "UPDATE access SET owner_id=?, is_group=?, name=?, resource_type=?, resource_name=?, action=?, disabled=? WHERE id = ?",
-		access.Owner, access.IsGroup, access.Name, access.Type, access.ResourceName, access.Action, access.Disabled, access.ID)
+	if access.Effect == "" {
+		access.Effect = store.AccessEffectAllow
+	}
+
+	// fields order: owner_id, is_group, name, resource_type, resource_name, action, disabled, not_before, expires_at, effect
+	res, err := e.conn(ctx).ExecContext(ctx, "UPDATE access SET owner_id=?, is_group=?, name=?, resource_type=?, resource_name=?, action=?, disabled=?, "+
+		"not_before=?, expires_at=?, effect=? WHERE id = ?",
+		access.Owner, access.IsGroup, access.Name, access.Type, access.ResourceName, access.Action, access.Disabled, access.NotBefore, access.ExpiresAt,
+		access.Effect, access.ID)
 	if err != nil {
-		return errors.Wrap(err, "failed to update access data")
+		return errors.Wrap(normalizeError(err), "failed to update access data")
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -151,9 +170,13 @@ func (e *Embedded) UpdateAccess(ctx context.Context, access store.Access) (err e
 // DeleteAccess delete access record by ID
 func (e *Embedded) DeleteAccess(ctx context.Context, key string, id interface{}) (err error) {
 
-	//nolint:gosec // key value not passed from user input and can be change in code only
+	if !isAllowedField(accessTable, key) {
+		return &engine.InvalidKeyError{Key: key}
+	}
+
+	//nolint:gosec // key value checked with list of allowed fields
 	query := fmt.Sprintf("DELETE FROM access WHERE %s = ?", key)
-	res, err := e.db.ExecContext(ctx, query, id)
+	res, err := e.conn(ctx).ExecContext(ctx, query, id)
 	if err != nil {
 		return errors.Wrapf(err, "failed execute query for access delete")
 	}
@@ -169,9 +192,18 @@ func (e *Embedded) DeleteAccess(ctx context.Context, key string, id interface{})
 	return err
 }
 
-// AccessGarbageCollector check outdated repositories in repositories table and delete ones from access list
+// DeleteOwnerAccesses deletes all accesses of a user or a group
+func (e *Embedded) DeleteOwnerAccesses(ctx context.Context, ownerID int64, isGroup bool) error {
+	_, err := e.conn(ctx).ExecContext(ctx, "DELETE FROM access WHERE owner_id = ? AND is_group = ?", ownerID, isGroup)
+	return errors.Wrap(err, "failed execute query for delete accesses of owner")
+}
+
+// AccessGarbageCollector deletes accesses to repositories which don't exist in repositories table anymore
 func (e *Embedded) AccessGarbageCollector(ctx context.Context) error {
-	res, err := e.db.ExecContext(ctx, "DELETE FROM access WHERE resource_name NOT IN (SELECT repository_name FROM repositories)")
+	// patterns and resources of other types never equal names of repositories, they are kept until they're deleted with API,
+	// deny rules are kept too, they can be made before a repository is pushed and access shouldn't open up without them
+	res, err := e.conn(ctx).ExecContext(ctx, "DELETE FROM access WHERE resource_type = ? AND effect <> ? AND resource_name NOT LIKE '%*%' "+
+		"AND resource_name NOT IN (SELECT repository_name FROM repositories)", store.RepositoryResourceType, store.AccessEffectDeny)
 	if err != nil {
 		return errors.Wrapf(err, "failed execute query for execute garbage collector for access ")
 	}
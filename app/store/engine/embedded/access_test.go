@@ -125,7 +125,7 @@ func TestEmbedded_FindAccesses(t *testing.T) {
 	for i, a := range testAccesses {
 		tmpAccess := a
 		err := db.CreateAccess(ctx, &tmpAccess)
-		testAccesses[i].ID = tmpAccess.ID
+		testAccesses[i] = tmpAccess
 		require.NoError(t, err)
 	}
 
@@ -214,6 +214,7 @@ func TestEmbedded_UpdateAccess(t *testing.T) {
 		Type:         "repository",
 		ResourceName: "test_per/test",
 		Action:       "pull",
+		Effect:       store.AccessEffectDeny,
 	}
 
 	err = db.UpdateAccess(ctx, *testAccess)
@@ -267,6 +268,10 @@ func TestEmbedded_DeleteAccess(t *testing.T) {
 	err = db.DeleteAccess(ctx, "id", -1)
 	assert.Error(t, err)
 
+	var keyErr *engine.InvalidKeyError
+	err = db.DeleteAccess(ctx, "1=1 OR id", -1)
+	assert.ErrorAs(t, err, &keyErr)
+
 	// try with bad or closed connection
 	badConn := Embedded{}
 	err = badConn.Connect(ctx)
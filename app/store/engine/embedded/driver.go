@@ -0,0 +1,126 @@
+package embedded
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"modernc.org/sqlite"
+)
+
+const (
+	// DriverCGO is SQLite driver which binds the C library, it requires CGO enabled when the application builds
+	DriverCGO = "sqlite3"
+
+	// DriverPureGo is SQLite driver translated to Go, it allows to build static binaries without C toolchain
+	DriverPureGo = "sqlite"
+
+	// busyTimeoutPragma sets the same waiting time for locked database as CGO driver uses by default
+	busyTimeoutPragma = "_pragma=busy_timeout(5000)"
+)
+
+// cgoDriverWithFunctions is the name which DriverCGO registered with the application functions
+const cgoDriverWithFunctions = "sqlite3_registry_admin"
+
+// resourceMatchFunction is SQL function which reports whether a repository name matches the resource name of an access,
+// both drivers register it with the same implementation as the access check uses
+const resourceMatchFunction = "resource_match"
+
+// resourceSpecificityFunction is SQL function which returns the weight of a resource name of an access,
+// both drivers register it with the same implementation as the access check uses
+const resourceSpecificityFunction = "resource_specificity"
+
+func init() {
+	sql.Register(cgoDriverWithFunctions, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc(resourceMatchFunction, store.MatchResource, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc(resourceSpecificityFunction, store.ResourceSpecificity, true)
+		},
+	})
+
+	sqlite.MustRegisterDeterministicScalarFunction(resourceMatchFunction, 2,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			pattern, okPattern := args[0].(string)
+			name, okName := args[1].(string)
+			if !okPattern || !okName {
+				return nil, fmt.Errorf("%s arguments should be strings", resourceMatchFunction)
+			}
+			return store.MatchResource(pattern, name), nil
+		})
+
+	sqlite.MustRegisterDeterministicScalarFunction(resourceSpecificityFunction, 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			pattern, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s argument should be a string", resourceSpecificityFunction)
+			}
+			return int64(store.ResourceSpecificity(pattern)), nil
+		})
+}
+
+// pureGoErrorRegExp matches error messages of DriverPureGo which have format 'error string: error message (code)'
+var pureGoErrorRegExp = regexp.MustCompile(`^(?:[^:]+: )?(.+?) \(\d+\)(?: \(SQLITE_BUSY\))?$`)
+
+func (e *Embedded) driverName() string {
+	if e.Driver == "" || e.Driver == DriverCGO {
+		return cgoDriverWithFunctions
+	}
+	return e.Driver
+}
+
+func (e *Embedded) dataSourceName() string {
+	if e.driverName() != DriverPureGo || e.Path == "" {
+		return e.Path
+	}
+
+	if strings.Contains(e.Path, "?") {
+		return e.Path + "&" + busyTimeoutPragma
+	}
+
+	if strings.HasPrefix(e.Path, "file:") {
+		return e.Path + "?" + busyTimeoutPragma
+	}
+	return "file:" + e.Path + "?" + busyTimeoutPragma
+}
+
+// uniqueConstraintPrefix starts messages of unique constraint violation errors for both drivers
+const uniqueConstraintPrefix = "UNIQUE constraint failed"
+
+// duplicateError keeps the original message of unique constraint violation error and matches engine.ErrDuplicateEntry
+type duplicateError struct {
+	msg string
+}
+
+func (e *duplicateError) Error() string { return e.msg }
+
+// Is allows checking the error with errors.Is(err, engine.ErrDuplicateEntry)
+func (e *duplicateError) Is(target error) bool { return target == engine.ErrDuplicateEntry }
+
+// normalizeError brings an error of DriverPureGo to the view of DriverCGO errors,
+// e.g. 'UNIQUE constraint failed: ...', because other packages can check error messages.
+// Unique constraint violations of both drivers match engine.ErrDuplicateEntry.
+func normalizeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		if match := pureGoErrorRegExp.FindStringSubmatch(sqliteErr.Error()); len(match) == 2 {
+			err = errors.New(match[1])
+		}
+	}
+
+	if strings.HasPrefix(err.Error(), uniqueConstraintPrefix) {
+		return &duplicateError{msg: err.Error()}
+	}
+	return err
+}
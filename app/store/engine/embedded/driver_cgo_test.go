@@ -0,0 +1,54 @@
+//go:build cgo
+
+package embedded
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// testDriver defines SQLite driver which uses by tests, the same tests run with DriverPureGo when CGO disabled
+const testDriver = DriverCGO
+
+func TestEmbedded_PureGoOpensCGODatabase(t *testing.T) {
+	dbPath := os.TempDir() + "/test_migrate.db"
+	_ = os.Remove(dbPath)
+	defer func() { assert.NoError(t, os.Remove(dbPath)) }()
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	cgoDB := NewEmbedded(dbPath)
+	require.NoError(t, cgoDB.Connect(ctx))
+
+	user := store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: "user"}
+	require.NoError(t, cgoDB.CreateUser(ctx, &user))
+	require.NoError(t, cgoDB.CreateRepository(ctx, &store.RegistryEntry{RepositoryName: "foo/bar", Tag: "latest",
+		Digest: "sha256:1", ConfigDigest: "sha256:2", Size: 100}))
+	require.NoError(t, cgoDB.Close(ctx))
+
+	// existing database file opens without any conversion, tables already exist
+	pureDB := NewPureGoEmbedded(dbPath)
+	require.NoError(t, pureDB.Connect(ctx))
+
+	result, err := pureDB.GetUser(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, user, result)
+
+	entries, err := pureDB.FindRepositories(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), entries.Total)
+
+	// check that the constraint errors have the same view for both drivers
+	err = pureDB.CreateRepository(ctx, &store.RegistryEntry{RepositoryName: "foo/bar", Tag: "latest",
+		Digest: "sha256:1", ConfigDigest: "sha256:2"})
+	require.Error(t, err)
+	assert.Equal(t, "UNIQUE constraint failed: repositories.repository_name, repositories.tag", err.Error())
+	require.NoError(t, pureDB.Close(ctx))
+}
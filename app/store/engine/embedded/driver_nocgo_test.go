@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package embedded
+
+// testDriver defines SQLite driver which uses by tests, the same tests run with DriverCGO when CGO enabled
+const testDriver = DriverPureGo
@@ -4,45 +4,114 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"os"
-	"reflect"
-	"regexp"
+	"sort"
 	"strings"
 
-	"github.com/hashicorp/go-multierror"
 	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"github.com/zebox/registry-admin/app/store"
 	"github.com/zebox/registry-admin/app/store/engine"
-
-	_ "github.com/mattn/go-sqlite3" // required for sql driver init
 )
 
 const (
-	usersTable        = "users"
-	groupsTable       = "groups"
-	accessTable       = "access"
-	repositoriesTable = "repositories"
-)
-
-var (
-	// ErrTableAlreadyExist for indicate table already exist error
-	ErrTableAlreadyExist = errors.New("table already exist or has an error")
+	usersTable         = "users"
+	groupsTable        = "groups"
+	accessTable        = "access"
+	repositoriesTable  = "repositories"
+	groupMembersTable  = "group_members"
+	robotsTable        = "robots"
+	refreshTokensTable = "refresh_tokens"
+	rolesTable         = "roles"
+
+	// currentUnixTime is SQL expression of the current unix time, validity window of rules is checked by database time
+	currentUnixTime = "CAST(strftime('%s', 'now') AS INTEGER)"
 )
 
 // Embedded implement internal data storage for users, access, group  and repositories
 type Embedded struct {
-	Path string `json:"path"`
-	db   *sql.DB
+	Path   string `json:"path"`
+	Driver string `json:"driver"` // name of SQLite driver, DriverCGO uses when it's empty
+	db     *sql.DB
+}
+
+// userAccessCondition is the condition of engine.RepositoriesByUserAccess filter, weights of patterns are compared
+// with the function which the driver registers
+var userAccessCondition = fmt.Sprintf("(EXISTS (%[1]s AND NOT EXISTS (%[2]s AND %[6]s(resource_name) > %[6]s(allowed.resource_name)) "+
+	"AND NOT EXISTS (%[3]s AND action IN ('%[5]s', allowed.action))) AND NOT EXISTS (%[4]s AND action IN ('%[5]s', 'pull')))",
+	matchingRulesQuery("allowed", store.AccessEffectAllow, "owner_id = ?"),
+	matchingRulesQuery("specific", store.AccessEffectAllow, "owner_id = ?"),
+	matchingRulesQuery("denied_action", store.AccessEffectDeny, deniedOwnersCondition),
+	matchingRulesQuery("denied", store.AccessEffectDeny, deniedOwnersCondition),
+	store.ActionAll, resourceSpecificityFunction)
+
+// deniedOwnersCondition defines owners of deny rules which apply to a user besides the user groups: the user itself
+// and owners of rules shared for all users
+var deniedOwnersCondition = fmt.Sprintf("owner_id IN (?, %d, %d)", engine.RegisteredUserID, engine.AnonymousUserID)
+
+// matchingRulesQuery returns a query of enabled rules with the effect which match a repository within their validity
+// window, rules belong to owners which the condition defines or to groups of the user. Resource names of rules can be patterns.
+// The alias allows nested queries to refer to the rules of the query.
+func matchingRulesQuery(alias, effect, ownerCondition string) string {
+	return fmt.Sprintf("SELECT 1 FROM %[1]s AS %[8]s WHERE effect = '%[6]s' AND NOT disabled AND "+
+		"((%[7]s AND NOT is_group) OR (is_group AND owner_id IN (SELECT group_id FROM %[2]s WHERE user_id = ?))) "+
+		"AND not_before <= %[5]s AND (expires_at = 0 OR expires_at > %[5]s) "+
+		"AND %[4]s(resource_name, %[3]s.repository_name)",
+		accessTable, groupMembersTable, repositoriesTable, resourceMatchFunction, currentUnixTime, effect, ownerCondition, alias)
+}
+
+// allowedFields defines columns of each table which can be used in filter, sort and update clauses,
+// any other keys are rejected with engine.InvalidKeyError
+var allowedFields = map[string]map[string]bool{
+	usersTable: {
+		"id": true, "login": true, "name": true, "role": true, "user_group": true, "disabled": true, "description": true,
+	},
+	groupsTable: {
+		"id": true, "name": true, "description": true,
+	},
+	accessTable: {
+		"id": true, "owner_id": true, "is_group": true, "name": true, "resource_type": true, "resource_name": true,
+		"action": true, "disabled": true, "not_before": true, "expires_at": true, "effect": true,
+	},
+	repositoriesTable: {
+		"id": true, "repository_name": true, "tag": true, "digest": true, "config_digest": true, "size": true,
+		"pull_counter": true, "timestamp": true, "raw": true,
+	},
+	groupMembersTable: {
+		"id": true, "group_id": true, "user_id": true,
+	},
+	robotsTable: {
+		"id": true, "name": true, "description": true, "disabled": true, "expires_at": true, "created_at": true,
+	},
+	refreshTokensTable: {
+		"id": true, "user_id": true, "client_id": true, "created_at": true, "expires_at": true, "last_used_at": true,
+	},
+	rolesTable: {
+		"id": true, "name": true, "description": true, "built_in": true,
+	},
 }
 
 type queryFilter struct {
-	skipLimit  string // an offset and a limit params
-	order      string // an order by clause
-	in         string // in array values
-	where      string // where without limit and offset params, return all items when math where clause
-	allClauses string // raw where clause with skip and limit
-	groupBy    string
+	skipLimit  string        // an offset and a limit params
+	order      string        // an order by clause
+	where      string        // where without limit and offset params, return all items when math where clause
+	allClauses string        // where clause with group by, order, skip and limit
+	groupBy    string        // a group by clause
+	args       []interface{} // values for bound params in where clause
+}
+
+// sqlConn is a common part of sql.DB and sql.Tx which storage uses for queries
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txKey is a context key of transaction, it is bound to a database for avoid using a transaction of other storage instance
+type txKey struct {
+	db *sql.DB
 }
 
 // NewEmbedded builder for create new instance of embedded storage
@@ -50,10 +119,16 @@ func NewEmbedded(pathToDB string) *Embedded {
 	return &Embedded{Path: pathToDB}
 }
 
+// NewPureGoEmbedded builder for create new instance of embedded storage which doesn't require CGO.
+// It uses the same database file format, that's why a file created by NewEmbedded instance can be opened as is.
+func NewPureGoEmbedded(pathToDB string) *Embedded {
+	return &Embedded{Path: pathToDB, Driver: DriverPureGo}
+}
+
 // Connect init connection to embedded storage instance
 func (e *Embedded) Connect(ctx context.Context) (err error) {
 
-	e.db, err = sql.Open("sqlite3", e.Path)
+	e.db, err = sql.Open(e.driverName(), e.dataSourceName())
 	if err != nil || e.Path == "" {
 		return err
 	}
@@ -63,25 +138,10 @@ func (e *Embedded) Connect(ctx context.Context) (err error) {
 		<-ctx.Done()
 		_ = e.db.Close()
 	}()
-	return e.initTables(ctx)
-
-}
-
-func (e *Embedded) initTables(ctx context.Context) (errs error) {
-	if err := e.initUserTable(ctx); err != nil {
-		errs = multierror.Append(errs, err, errors.Errorf("failed to create %s table", usersTable))
-	}
 
-	if err := e.initGroupsTable(ctx); err != nil {
-		errs = multierror.Append(errs, err, errors.Errorf("failed to create %s table", groupsTable))
-	}
-
-	if err := e.initAccessTable(ctx); err != nil {
-		errs = multierror.Append(errs, err, errors.Errorf("failed to create %s table", accessTable))
-	}
-
-	if err := e.initRepositoriesTable(ctx); err != nil {
-		errs = multierror.Append(errs, err, errors.Errorf("failed to create %s table", repositoriesTable))
+	errMigrate := e.migrate(ctx)
+	if errMigrate == nil {
+		errMigrate = e.updateBuiltInRoles(ctx)
 	}
 
 	// SQLite driver doesn't catch error if file doesn't exist and try to create a new database file.
@@ -90,277 +150,205 @@ func (e *Embedded) initTables(ctx context.Context) (errs error) {
 	if _, errStat := os.Stat(e.Path); os.IsNotExist(errStat) {
 		return fmt.Errorf("[ERROR] database path is invalid '%s'. Can't create database file", e.Path)
 	}
-	return errs
+	return errMigrate
 }
 
-func (e *Embedded) initGroupsTable(ctx context.Context) error {
-	if exist, err := e.isTableExist(ctx, groupsTable); err != nil || exist {
-		return ErrTableAlreadyExist
-	}
-
-	sqlText := fmt.Sprintf(`CREATE TABLE %s(
-	id    INTEGER PRIMARY KEY AUTOINCREMENT UNIQUE,	
-	name TEXT UNIQUE,
-	description TEXT)`, groupsTable)
-
-	_, err := e.db.Exec(sqlText)
-	if err != nil {
-		return errors.Wrapf(err, "failed to create %s table", groupsTable)
-	}
-
-	// create default admin group when a new database creation
-	group := store.User{
-		Name:        "default", // default login
-		Description: "Default administration group",
-	}
-
-	createUserSQL := fmt.Sprintf(`INSERT OR REPLACE INTO %s (
-		name,
-		description
-	) values(?, ?)`, groupsTable)
-
-	stmt, err := e.db.Prepare(createUserSQL)
-	if err != nil {
-		return errors.Wrapf(err, "failed to insert new default user to %s table", groupsTable)
-	}
-	defer func() { _ = stmt.Close() }()
-	_, err = stmt.Exec(group.Name, group.Description)
-	return err
+// Close exposed closed method for embedded storage instance
+func (e *Embedded) Close(_ context.Context) error {
+	return e.db.Close()
 }
 
-func (e *Embedded) initUserTable(ctx context.Context) error {
-	if exist, err := e.isTableExist(ctx, usersTable); err != nil || exist {
-		return ErrTableAlreadyExist
+// WithTx runs fn within a transaction, all storage calls with context which passed to fn are parts of it.
+// A nested call joins to the transaction already started.
+func (e *Embedded) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	key := txKey{db: e.db}
+	if _, ok := ctx.Value(key).(*sql.Tx); ok {
+		return fn(ctx)
 	}
 
-	sqlText := fmt.Sprintf(`CREATE TABLE %s(
-	id    INTEGER PRIMARY KEY AUTOINCREMENT UNIQUE,
-	login  TEXT UNIQUE,
-	name TEXT,
-	password TEXT,
-	role TEXT,
-	user_group INTEGER,
-	disabled INTEGER,
-	description TEXT)`, usersTable)
-
-	_, err := e.db.Exec(sqlText)
+	tx, err := e.db.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create %s table", usersTable)
+		return errors.Wrap(err, "failed to begin transaction")
 	}
 
-	defaultPassword := engine.GetAdminDefaultPassword(ctx)
-	if defaultPassword == "" {
-		defaultPassword = "admin"
-	}
-
-	// create default admin user if new database creation
-	user := store.User{
-		Login:       "admin", // is the default login
-		Name:        "admin",
-		Password:    defaultPassword,
-		Role:        "admin",
-		Group:       1,
-		Description: "Default user with administration role ",
-	}
-
-	// hashing user password
-	if errHash := user.HashAndSalt(); errHash != nil {
-		return errHash
-	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
 
-	createUserSQL := fmt.Sprintf(`INSERT OR REPLACE INTO %s (
-		login,
-		name,
-		password,
-		role,
-		user_group,
-		disabled,
-		description
-		
-	) values(?, ?, ?, ?, ?, ?, ?)`, usersTable)
-	stmt, err := e.db.Prepare(createUserSQL)
-	if err != nil {
-		return errors.Wrapf(err, "failed to insert new default user to %s table", usersTable)
+	if err = fn(context.WithValue(ctx, key, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
 	}
-	defer func() { _ = stmt.Close() }()
-	_, err = stmt.Exec(user.Login, user.Name, user.Password, user.Role, user.Group, 0, user.Description)
-	return err
+	return errors.Wrap(tx.Commit(), "failed to commit transaction")
 }
 
-func (e *Embedded) initAccessTable(ctx context.Context) error {
-	if exist, err := e.isTableExist(ctx, accessTable); err != nil || exist {
-		return ErrTableAlreadyExist
-	}
-
-	sqlText := fmt.Sprintf(`CREATE TABLE %s(
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		owner_id INTEGER NOT NULL,
-		is_group INTEGER,
-		name TEXT,
-		resource_type TEXT,
-		resource_name TEXT,
-		action TEXT,
-		disabled INTEGER,
-		UNIQUE(owner_id,resource_type,resource_name,action))`, accessTable)
-
-	_, err := e.db.Exec(sqlText)
-	if err != nil {
-		return multierror.Append(err, errors.Errorf("failed to create %s table", accessTable))
+// conn returns a transaction which bound to context with WithTx or the database connection pool otherwise
+func (e *Embedded) conn(ctx context.Context) sqlConn {
+	if tx, ok := ctx.Value(txKey{db: e.db}).(*sql.Tx); ok {
+		return tx
 	}
-	return nil
+	return e.db
 }
 
-func (e *Embedded) initRepositoriesTable(ctx context.Context) error {
-	if exist, err := e.isTableExist(ctx, repositoriesTable); err != nil || exist {
-		return ErrTableAlreadyExist
-	}
+// filtersBuilder parse an engine filter values and build query filter for 'embedded' implementation.
+// Filter values are never formatted into a query, they are passed to it as bound parameters, and all keys of filter
+// and sort params are checked against list of allowed fields of the table.
+// IMPORTANT: value for group by always fetch from FIRST index of 'fieldsName' list, keep in mind this when use 'group by'
+func filtersBuilder(tableName string, filter engine.QueryFilter, fieldsName ...string) (f queryFilter, err error) {
 
-	sqlText := fmt.Sprintf(`CREATE TABLE %s(
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		repository_name INTEGER NOT NULL CHECK(repository_name <> ''),
-		tag TEXT NOT NULL CHECK(tag <> ''),
-		digest TEXT NOT NULL CHECK(digest <> ''),
-		config_digest TEXT NOT NULL CHECK(config_digest <> ''),
-		size INTEGER,
-		pull_counter INTEGER,
-		timestamp INTEGER,
-		raw TEXT,
-		UNIQUE(repository_name,tag))`, repositoriesTable)
-
-	_, err := e.db.Exec(sqlText)
-	if err != nil {
-		return multierror.Append(err, errors.Errorf("failed to create %s table", repositoriesTable))
+	// skip and limit statement build
+	if filter.Range[1] > 0 {
+		f.skipLimit = fmt.Sprintf(" LIMIT %d", filter.Range[1]-filter.Range[0])
 	}
-	return nil
-}
 
-func (e *Embedded) isTableExist(_ context.Context, tableName string) (exist bool, err error) {
-
-	rows, err := e.db.Query(fmt.Sprintf("select DISTINCT tbl_name from sqlite_master where tbl_name = '%s'", tableName))
-	if err != nil {
-		return false, multierror.Append(err, errors.Errorf("can't check for %s table exist", tableName))
+	if filter.Range[0] > 0 {
+		if f.skipLimit == "" {
+			f.skipLimit = " LIMIT -1" // SQLite doesn't allow OFFSET without LIMIT
+		}
+		f.skipLimit = fmt.Sprintf("%s OFFSET %d", f.skipLimit, filter.Range[0])
 	}
 
-	defer func() { _ = rows.Close() }()
-	for rows.Next() {
-		return true, nil
+	// keys sorted for keep conditions order and order of bound params the same for each call
+	keys := make([]string, 0, len(filter.Filters))
+	for k := range filter.Filters {
+		keys = append(keys, k)
 	}
-	return false, nil
-}
-
-// Close exposed closed method for embedded storage instance
-func (e *Embedded) Close(_ context.Context) error {
-	return e.db.Close()
-}
-
-// filtersBuilder parse an engine filter values and build query filter for 'embedded' implementation
-// IMPORTANT: value for group by always fetch from FIRST index of 'fieldsName' list, keep in mind this when use 'group by'
-func filtersBuilder(filter engine.QueryFilter, fieldsName ...string) (f queryFilter) {
+	sort.Strings(keys)
 
-	var ids string
+	var conditions []string
+	for _, k := range keys {
+		v := filter.Filters[k]
 
-	// skip and limit statement build
-	skip := ""
-	if filter.Range[0] > 0 {
-		skip = fmt.Sprintf("OFFSET %d", filter.Range[0])
-		f.skipLimit = skip
-	}
-
-	if filter.Range[1] > 0 {
-		limit := fmt.Sprintf(" LIMIT %d", filter.Range[1]-filter.Range[0])
-		f.skipLimit = fmt.Sprintf("%s %s", limit, skip)
-	}
+		switch k {
+		case "ids":
+			values, ok := v.([]interface{})
+			if !ok {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+			conditions = append(conditions, inCondition("id", values, &f.args))
 
-	var (
-		like             string
-		strongConditions = make([]string, 0)
-	)
+		case "q":
+			var likeConditions []string
+			for _, field := range fieldsName {
+				likeConditions = append(likeConditions, fmt.Sprintf("%s LIKE ?", field))
+				f.args = append(f.args, fmt.Sprintf("%%%v%%", v))
+			}
+			if len(likeConditions) > 0 {
+				conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(likeConditions, " OR ")))
+			}
 
-	// search query statement and parse queryFilter value
-	for k, v := range filter.Filters {
+		case engine.RepositoriesByUserAccess:
+			if tableName != repositoriesTable {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+			conditions = append(conditions, userAccessCondition)
+			owner := castValue(v)
+			for n := strings.Count(userAccessCondition, "?"); n > 0; n-- {
+				f.args = append(f.args, owner)
+			}
 
-		// check sql value for sql-injection
-		k, v = sanitizeKeyValue(k, v)
+		case engine.AccessExpiresBefore:
+			if tableName != accessTable {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+			conditions = append(conditions, "expires_at > 0 AND expires_at <= ?")
+			f.args = append(f.args, castValue(v))
 
-		// build filter by list of IDs
-		if k == "ids" {
-			var stringIds []string
-			for _, value := range v.([]interface{}) {
-				stringIds = append(stringIds, castValueTypeToString(value))
+		default:
+			if !isAllowedField(tableName, k) {
+				return f, &engine.InvalidKeyError{Key: k}
 			}
-			ids = strings.Join(stringIds, ", ")
-			f.in = fmt.Sprintf("id IN (%s)", ids)
-			continue
-		}
 
-		if k == "q" {
-			var likeConndition []string
-			for _, val := range fieldsName {
-				if reflect.TypeOf(v).Kind() == reflect.Int {
-					likeConndition = append(likeConndition, fmt.Sprintf(" %s LIKE %d", val, v))
-					continue
-				}
-				likeConndition = append(likeConndition, fmt.Sprintf("%s LIKE '%%%s%%'", val, v))
+			if values, ok := listValues(v); ok {
+				conditions = append(conditions, inCondition(k, values, &f.args))
+				continue
 			}
-			like = strings.Join(likeConndition, " OR ")
-			continue
+			conditions = append(conditions, fmt.Sprintf("%s = ?", k))
+			f.args = append(f.args, castValue(v))
 		}
+	}
 
-		conditionValue := fmt.Sprintf("%s = %s", k, castValueTypeToString(v))
-		if k == engine.RepositoriesByUserAccess {
-			conditionValue = fmt.Sprintf("access.owner_id = %s", castValueTypeToString(v))
-		}
+	if len(conditions) > 0 {
+		f.where = "WHERE " + strings.Join(conditions, " AND ")
+	}
 
-		strongConditions = append(strongConditions, conditionValue)
+	// set value for group by clause.
+	// IMPORTANT: group by field name always fetch from first index of fieldsName option
+	if filter.GroupByField && len(fieldsName) > 0 {
+		f.groupBy = " GROUP BY " + fieldsName[0]
 	}
 
-	var strongCondition string
-	if len(strongConditions) > 0 {
-		if like != "" {
-			strongCondition = fmt.Sprintf("AND (%s)", strings.Join(strongConditions, " AND "))
-		} else {
-			strongCondition = fmt.Sprintf("(%s)", strings.Join(strongConditions, " AND "))
-		}
+	sortField, sortOrder := "id", "ASC" // default sorting
+	if len(filter.Sort) == 2 {
+		sortField, sortOrder = filter.Sort[0], strings.ToUpper(filter.Sort[1])
+	}
 
+	if !isAllowedField(tableName, sortField) {
+		return f, &engine.InvalidKeyError{Key: sortField}
 	}
 
-	if f.in != "" {
-		f.allClauses = fmt.Sprintf("WHERE %s", f.in)
+	if sortOrder != "ASC" && sortOrder != "DESC" {
+		return f, &engine.InvalidKeyError{Key: filter.Sort[1]}
 	}
 
-	if like != "" {
-		if f.allClauses == "" {
-			f.allClauses = fmt.Sprintf("WHERE (%s)", like)
-		} else {
-			f.allClauses = fmt.Sprintf("%s AND (%s)", f.allClauses, like)
-		}
+	f.order = fmt.Sprintf(" ORDER BY %s %s", sortField, sortOrder)
+	f.allClauses = f.where + f.groupBy + f.order + f.skipLimit
+	return f, nil
+}
 
-	}
+// isAllowedField checks that a field is in the list of fields which can be used in queries for the table
+func isAllowedField(tableName, field string) bool {
+	return allowedFields[tableName][field]
+}
 
-	if strongCondition != "" {
-		if f.allClauses == "" {
-			f.allClauses = fmt.Sprintf("WHERE %s", strongCondition)
-		} else {
-			f.allClauses = fmt.Sprintf("%s %s", f.allClauses, strongCondition)
+// listValues returns values of a filter which passed as a list, JSON arrays have []interface{} type
+// and lists built by the application code can have []string type
+func listValues(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = v[i]
 		}
+		return values, true
 	}
-	f.where = f.allClauses
+	return nil, false
+}
 
-	if filter.Sort == nil {
-		filter.Sort = []string{"id", "asc"} // default sorting
+// inCondition builds IN clause for list of values and appends values to args
+func inCondition(key string, values []interface{}, args *[]interface{}) string {
+	if len(values) == 0 {
+		return "FALSE"
 	}
 
-	// set value for group by clause.
-	// IMPORTANT: group by field name always fetch from first index of fieldsName option
-	if filter.GroupByField && len(fieldsName) > 0 {
-		f.groupBy = "GROUP BY " + fieldsName[0]
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = "?"
+		*args = append(*args, castValue(v))
 	}
+	return fmt.Sprintf("%s IN (%s)", key, strings.Join(placeholders, ", "))
+}
 
-	f.order = fmt.Sprintf("%s ORDER BY %s %s ", f.groupBy, filter.Sort[0], filter.Sort[1])
-
-	f.allClauses = f.allClauses + f.order + f.skipLimit
-
-	return f
+// castValue converts a filter value to type which can be bound to query param
+func castValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		// numbers from JSON always have float type, but all numeric columns store integer values
+		if v == math.Trunc(v) {
+			return int64(v)
+		}
+	case digest.Digest:
+		return string(v)
+	case []byte:
+		// raw values stored as text, but the bytes slice will be stored as blob
+		return string(v)
+	}
+	return value
 }
 
 // getTotalRecordExcludeRange return total number of records exclude range/skip clause for pagination support
@@ -368,7 +356,7 @@ func filtersBuilder(filter engine.QueryFilter, fieldsName ...string) (f queryFil
 //	tableName - specify table name for search
 //	filter - set of params for where clause in query
 //	searchFields - define list of key fields using in where clause
-func (e *Embedded) getTotalRecordsExcludeRange(tableName string, filter engine.QueryFilter, searchFields []string) int64 {
+func (e *Embedded) getTotalRecordsExcludeRange(ctx context.Context, tableName string, filter engine.QueryFilter, searchFields []string) int64 {
 	filter.Range = [2]int64{0, 0} // clear skip/offset range
 
 	// it defines request type for get total records from table with duplicates fields such like repositories
@@ -380,112 +368,17 @@ func (e *Embedded) getTotalRecordsExcludeRange(tableName string, filter engine.Q
 		countType = fmt.Sprintf("COUNT(DISTINCT %s)", searchFields[0])
 	}
 
-	f := filtersBuilder(filter, searchFields...)
-
-	//nolint:gosec // all values passed to query sanitized before past
-	queryString := fmt.Sprintf("SELECT %s FROM %s %s", countType, tableName, f.allClauses)
-
-	// check for select repositories by user access
-	if _, ok := filter.Filters["access.owner_id"]; ok {
-
-		queryString = fmt.Sprintf("SELECT %s FROM %s INNER JOIN access on repositories.repository_name=access.resource_name %s", countType, tableName, f.where)
-	}
-
-	rows, err := e.db.Query(queryString)
+	f, err := filtersBuilder(tableName, filter, searchFields...)
 	if err != nil {
 		return 0
 	}
 
-	defer func() {
-		_ = rows.Close()
-	}()
+	//nolint:gosec // all values passed to query as bound params
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", countType, tableName, f.where)
 
 	var recordsCounter int64
-	rows.Next()
-	if err = rows.Scan(&recordsCounter); err != nil {
+	if err = e.conn(ctx).QueryRowContext(ctx, queryString, f.args...).Scan(&recordsCounter); err != nil {
 		return 0
 	}
 	return recordsCounter
 }
-
-// castValueTypeToString will select appropriate type to formatting string
-func castValueTypeToString(value interface{}) string {
-	switch v := value.(type) {
-	case string, digest.Digest, []uint8:
-		return fmt.Sprintf("'%s'", v)
-	case []string:
-		if len(v) > 0 {
-			return fmt.Sprintf("'%s'", v[0])
-		}
-	case int, int64:
-		return fmt.Sprintf("%d", v)
-	case float32, float64:
-		return fmt.Sprintf("%.f", v)
-	case bool:
-		return fmt.Sprintf("%d", func(b bool) int {
-			if b {
-				return 1
-			}
-			return 0
-		}(v))
-	}
-	return ""
-}
-
-// sanitizeValue check key name and value for contain sql-injection code and cleanup ones
-func sanitizeKeyValue(key string, value interface{}) (cleanKey string, cleanValue interface{}) {
-
-	// query value input can be full text search string with white spaces and contain substring either 'OR' or 'AND'
-	// because in this regexp white spaces, substrings contain 'OR' or/and 'AND' and not will be replaced
-	// 'OR', 'AND' will replace if they wrap of white spaces
-	var queryValueRegExp = regexp.MustCompile(`(?i)[\t\r\n]|(--)|(%)|\s{2,}|(\s(OR|AND|JOIN|LEFT|RIGHT|LIKE)\s)|\)|\(|'|"|=|\*|SELECT|UPDATE|INSERT|DELETE|LIKE|WHERE|ALTER|UNION`)
-
-	// same regexp as above but include trim white spaces between words of string for ke or value
-	var keyNameValueRegExp = regexp.MustCompile(`(?i)[\t\r\n]|(--)|\s+|(%)|(\sOR\s|\sAND\s|\)|\(|'|"|=|\*|SELECT|UPDATE|INSERT|DELETE|LIKE|WHERE|ALTER|UNION)`)
-
-	// search sql-injection code in key name
-	cleanKey = key
-	for {
-		isPatternDetected := false
-		for _, match := range keyNameValueRegExp.FindAllString(cleanKey, -1) {
-			cleanKey = strings.Replace(cleanKey, match, "", -1)
-			isPatternDetected = true
-		}
-		if !isPatternDetected {
-			break
-		}
-	}
-
-	// search sql-injection code in value
-	cleanValue = value
-	switch val := value.(type) {
-	case string:
-
-		tmpString := val
-		for {
-			isPatternDetected := false
-
-			// full text query value string sanitizing
-			if cleanKey == "q" {
-				for _, match := range queryValueRegExp.FindAllString(tmpString, -1) {
-					tmpString = strings.Replace(tmpString, match, "", -1)
-					isPatternDetected = true
-				}
-
-				// sanitize a filter value
-			} else {
-				for _, match := range keyNameValueRegExp.FindAllString(tmpString, -1) {
-					tmpString = strings.Replace(tmpString, match, "", -1)
-					isPatternDetected = true
-				}
-			}
-
-			if !isPatternDetected {
-				cleanValue = tmpString
-				break
-			}
-		}
-	}
-
-	return cleanKey, cleanValue
-}
@@ -2,11 +2,16 @@ package embedded
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
 	"github.com/zebox/registry-admin/app/store/engine"
+	"github.com/zebox/registry-admin/app/store/engine/enginetest"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -17,25 +22,25 @@ func TestSQLite_Connect(t *testing.T) {
 	defer ctxCancel()
 
 	_ = os.Remove(dbPath) // clear if exist on previously tests
-	db := Embedded{Path: dbPath}
+	db := Embedded{Path: dbPath, Driver: testDriver}
 
 	err := db.Connect(ctx)
 	require.NoError(t, err)
 	assert.NotNil(t, db.db)
 
-	isExist, err := db.isTableExist(ctx, usersTable)
+	isExist, err := isTableExist(ctx, db.db, usersTable)
 	assert.NoError(t, err)
 	assert.True(t, isExist)
 
-	isExist, err = db.isTableExist(ctx, groupsTable)
+	isExist, err = isTableExist(ctx, db.db, groupsTable)
 	assert.NoError(t, err)
 	assert.True(t, isExist)
 
-	isExist, err = db.isTableExist(ctx, accessTable)
+	isExist, err = isTableExist(ctx, db.db, accessTable)
 	assert.NoError(t, err)
 	assert.True(t, isExist)
 
-	isExist, err = db.isTableExist(ctx, repositoriesTable)
+	isExist, err = isTableExist(ctx, db.db, repositoriesTable)
 	assert.NoError(t, err)
 	assert.True(t, isExist)
 
@@ -44,33 +49,11 @@ func TestSQLite_Connect(t *testing.T) {
 
 	t.Log("test with bad db path ")
 	dbPath = os.TempDir() + "/unknown_path/test.db"
-	db = Embedded{Path: dbPath}
+	db = Embedded{Path: dbPath, Driver: testDriver}
 	err = db.Connect(ctx)
 	require.Error(t, err)
 }
 
-func TestSQlite_initTables(t *testing.T) {
-	dbPath := os.TempDir() + "/test.db"
-	ctx, ctxCancel := context.WithCancel(context.Background())
-	defer ctxCancel()
-	db := Embedded{Path: dbPath}
-
-	var err error
-	db.db, err = sql.Open("sqlite3", db.Path)
-	require.NoError(t, err)
-
-	assert.NoError(t, db.initUserTable(ctx))
-	assert.NoError(t, db.initGroupsTable(ctx))
-	assert.NoError(t, db.initAccessTable(ctx))
-	assert.NoError(t, db.initRepositoriesTable(ctx))
-
-	err = db.initTables(ctx)
-	assert.Error(t, err)
-
-	assert.NoError(t, db.Close(ctx))
-	_ = os.Remove(dbPath)
-
-}
 func TestNewEmbedded(t *testing.T) {
 	testPathToDB := "/var/test/_store.db"
 	embedded := NewEmbedded(testPathToDB)
@@ -78,50 +61,202 @@ func TestNewEmbedded(t *testing.T) {
 }
 
 func TestFilterBuilder(t *testing.T) {
-
-	// Filter string in URL example: http://{host}/api/v1/sessions?filter={"status":2000,"area_id":3276989022,"q":"пр"}&range=[0,24]&sort=["start_time","DESC"]
-	filter := engine.QueryFilter{
-		Range:   [2]int64{1, 10},
-		Filters: map[string]interface{}{"q": "test", "disabled": false},
-		Sort:    []string{"id", "asc"},
+	testFilters := []struct {
+		table  string
+		filter engine.QueryFilter
+		fields []string
+		query  string
+		args   []interface{}
+		err    bool
+	}{
+		{
+			table:  usersTable,
+			filter: engine.QueryFilter{},
+			query:  " ORDER BY id ASC",
+		},
+		{
+			// Filter string in URL example: http://{host}/api/v1/users?filter={"disabled":false,"q":"test"}&range=[1,9]&sort=["id","asc"]
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Range:   [2]int64{1, 10},
+				Filters: map[string]interface{}{"q": "test", "disabled": false},
+				Sort:    []string{"id", "asc"},
+			},
+			fields: []string{"role", "login"},
+			query:  "WHERE disabled = ? AND (role LIKE ? OR login LIKE ?) ORDER BY id ASC LIMIT 9 OFFSET 1",
+			args:   []interface{}{false, "%test%", "%test%"},
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"q": "test", "disabled": true, "ids": []interface{}{float64(1019101756), float64(1334517373)}},
+				Sort:    []string{"login", "DESC"},
+			},
+			fields: []string{"role", "login"},
+			query:  "WHERE disabled = ? AND id IN (?, ?) AND (role LIKE ? OR login LIKE ?) ORDER BY login DESC",
+			args:   []interface{}{true, int64(1019101756), int64(1334517373), "%test%", "%test%"},
+		},
+		{
+			table: accessTable,
+			filter: engine.QueryFilter{
+				Range:   [2]int64{5, 0},
+				Filters: map[string]interface{}{"action": []string{"pull", "push"}, "owner_id": int64(1)},
+			},
+			query: "WHERE action IN (?, ?) AND owner_id = ? ORDER BY id ASC LIMIT -1 OFFSET 5",
+			args:  []interface{}{"pull", "push", int64(1)},
+		},
+		{
+			table: repositoriesTable,
+			filter: engine.QueryFilter{
+				Filters:      map[string]interface{}{engine.RepositoriesByUserAccess: float64(5), "tag": []interface{}{}},
+				GroupByField: true,
+			},
+			fields: []string{"repository_name", "tag"},
+			query:  "WHERE " + userAccessCondition + " AND FALSE GROUP BY repository_name ORDER BY id ASC",
+			args:   []interface{}{int64(5), int64(5), int64(5), int64(5), int64(5), int64(5), int64(5), int64(5)},
+		},
+		{
+			table:  accessTable,
+			filter: engine.QueryFilter{Filters: map[string]interface{}{engine.AccessExpiresBefore: float64(1700000000), "disabled": false}},
+			query:  "WHERE disabled = ? AND expires_at > 0 AND expires_at <= ? ORDER BY id ASC",
+			args:   []interface{}{false, int64(1700000000)},
+		},
+		{
+			// values are passed as params and aren't mangled
+			table: groupsTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"q": "' OR 1=1 --", "description": "-- LIKE AND SELECT value"},
+			},
+			fields: []string{"name"},
+			query:  "WHERE description = ? AND (name LIKE ?) ORDER BY id ASC",
+			args:   []interface{}{"-- LIKE AND SELECT value", "%' OR 1=1 --%"},
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"password": "hash"},
+			},
+			err: true,
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"name = '' OR 1": 1},
+			},
+			err: true,
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{engine.RepositoriesByUserAccess: 1},
+			},
+			err: true,
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{engine.AccessExpiresBefore: 1},
+			},
+			err: true,
+		},
+		{
+			table: groupsTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"ids": "1,2"},
+			},
+			err: true,
+		},
+		{
+			table:  groupsTable,
+			filter: engine.QueryFilter{Sort: []string{"id; DROP TABLE users", "asc"}},
+			err:    true,
+		},
+		{
+			table:  groupsTable,
+			filter: engine.QueryFilter{Sort: []string{"id", "asc; DROP TABLE users"}},
+			err:    true,
+		},
 	}
 
-	{
-		f := filtersBuilder(filter, "role", "login")
-		checkWhere := "WHERE (role LIKE '%test%' OR login LIKE '%test%') AND (disabled = 0) ORDER BY id asc  LIMIT 9 OFFSET 1"
-		assert.Equal(t, checkWhere, f.allClauses)
+	for i, tt := range testFilters {
+		t.Run(fmt.Sprintf("filter_%d", i), func(t *testing.T) {
+			f, err := filtersBuilder(tt.table, tt.filter, tt.fields...)
+			if tt.err {
+				var keyErr *engine.InvalidKeyError
+				assert.ErrorAs(t, err, &keyErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.query, f.allClauses)
+			assert.Equal(t, tt.args, f.args)
+		})
 	}
+}
 
-	{
-		filter.Filters = map[string]interface{}{"q": "test"}
-		f1 := filtersBuilder(filter, "role", "login")
-		checkWhere := "WHERE (role LIKE '%test%' OR login LIKE '%test%') ORDER BY id asc  LIMIT 9 OFFSET 1"
-		assert.Equal(t, checkWhere, f1.allClauses)
-	}
-	{
-
-		ids := []interface{}{float64(1019101756), float64(1334517373)}
-		filter.Filters = map[string]interface{}{"q": "test", "disabled": true}
-		filter.Filters["ids"] = ids
-		f2 := filtersBuilder(filter, "role", "login")
-		checkWhere2 := "WHERE id IN (1019101756, 1334517373) AND (role LIKE '%test%' OR login LIKE '%test%') AND (disabled = 1) ORDER BY id asc  LIMIT 9 OFFSET 1"
-		assert.Equal(t, checkWhere2, f2.allClauses)
-	}
-	{
-		delete(filter.Filters, "ids")
-		filter.Range = [2]int64{}
-		filter.Filters = map[string]interface{}{"q": 1}
-		f2 := filtersBuilder(filter, "role", "id")
-		checkWhere2 := "WHERE ( role LIKE 1 OR  id LIKE 1) ORDER BY id asc "
-		assert.Equal(t, checkWhere2, f2.allClauses)
-	}
+func TestEmbedded_WithTx(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
 
-	{
-		// test for sanitize key/value
-		filter.Filters = map[string]interface{}{"q select--": "test query WHERE LIKE JOIN search DELETE -- % = string ", "description": "-- LIKE AND SELECT clear_value WHERE OR"}
-		f := filtersBuilder(filter, "role", "login")
-		checkWhere := "WHERE (role LIKE '%test querysearchstring %' OR login LIKE '%test querysearchstring %') AND (description = 'ANDclear_valueOR') ORDER BY id asc "
-		assert.Equal(t, checkWhere, f.allClauses)
-	}
+	db := prepareTestDB(ctx, t, wg)
+
+	// all changes are rolled back when function returns error
+	testErr := errors.New("test error")
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, db.CreateGroup(ctx, &store.Group{Name: "tx_group"}))
+		require.NoError(t, db.CreateUser(ctx, &store.User{Login: "tx_user", Name: "tx_user", Password: "tx_password", Role: "user"}))
+
+		// changes are visible inside transaction
+		users, errFind := db.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "tx_user"}}, false)
+		require.NoError(t, errFind)
+		assert.Equal(t, int64(1), users.Total)
+		return testErr
+	})
+	assert.Equal(t, testErr, err)
+
+	users, err := db.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "tx_user"}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), users.Total)
+
+	groups, err := db.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"name": "tx_group"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), groups.Total)
+
+	// nested call joins to outer transaction and commits with it
+	err = db.WithTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, db.CreateGroup(ctx, &store.Group{Name: "tx_group"}))
+		return db.WithTx(ctx, func(ctx context.Context) error {
+			return db.CreateUser(ctx, &store.User{Login: "tx_user", Name: "tx_user", Password: "tx_password", Role: "user"})
+		})
+	})
+	require.NoError(t, err)
+
+	users, err = db.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "tx_user"}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), users.Total)
+
+	groups, err = db.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"name": "tx_group"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), groups.Total)
+
+	// transaction rolls back when function panics
+	assert.Panics(t, func() {
+		_ = db.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, db.DeleteGroup(ctx, groups.Data[0].(store.Group).ID))
+			panic("test panic")
+		})
+	})
+	_, err = db.GetGroup(ctx, groups.Data[0].(store.Group).ID)
+	assert.NoError(t, err)
+}
 
+func TestEmbedded_Conformance(t *testing.T) {
+	enginetest.Run(t, func(ctx context.Context, t *testing.T) engine.Interface {
+		db := &Embedded{Path: filepath.Join(t.TempDir(), "conformance.db"), Driver: testDriver}
+		require.NoError(t, db.Connect(ctx))
+		return db
+	})
 }
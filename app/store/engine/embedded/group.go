@@ -30,14 +30,14 @@ func (e *Embedded) CreateGroup(ctx context.Context, group *store.Group) (err err
 		name,
 		description
 	) values(?, ?)`, groupsTable)
-	stmt, err := e.db.PrepareContext(ctx, createGroupSQL)
+	stmt, err := e.conn(ctx).PrepareContext(ctx, createGroupSQL)
 	if err != nil {
 		return ErrFailedToCreateGroup
 	}
 	defer func() { _ = stmt.Close() }()
 	result, err := stmt.ExecContext(ctx, group.Name, group.Description)
 	if err != nil {
-		return err
+		return normalizeError(err)
 	}
 
 	id, err := result.LastInsertId()
@@ -51,7 +51,7 @@ func (e *Embedded) CreateGroup(ctx context.Context, group *store.Group) (err err
 func (e *Embedded) GetGroup(ctx context.Context, groupID int64) (group store.Group, err error) {
 
 	queryFilter := fmt.Sprintf("select id,name,description from %s where id = ?", groupsTable)
-	stmt, err := e.db.PrepareContext(ctx, queryFilter)
+	stmt, err := e.conn(ctx).PrepareContext(ctx, queryFilter)
 	if err != nil {
 		return group, errors.Wrap(err, "failed to prepare query for group get")
 	}
@@ -71,27 +71,30 @@ func (e *Embedded) GetGroup(ctx context.Context, groupID int64) (group store.Gro
 		emptyResult = false
 	}
 	if emptyResult {
-		return group, errors.New("group record not found")
+		return group, fmt.Errorf("group %w", engine.ErrNotFound)
 	}
 	return group, nil
 }
 
 // FindGroups fetch list of existed group
 func (e *Embedded) FindGroups(ctx context.Context, filter engine.QueryFilter) (groups engine.ListResponse, err error) {
-	f := filtersBuilder(filter, "name")                                                            // set key filed for search query
-	queryString := fmt.Sprintf("SELECT id,name,description FROM %s %s", groupsTable, f.allClauses) //nolint:gosec // query sanitizing calling before
+	f, err := filtersBuilder(groupsTable, filter, "name") // set key filed for search query
+	if err != nil {
+		return groups, err
+	}
+	queryString := fmt.Sprintf("SELECT id,name,description FROM %s %s", groupsTable, f.allClauses) //nolint:gosec // values passed as bound params
 
 	// avoid error shadowed
 	var (
 		stmt *sql.Stmt
 		rows *sql.Rows
 	)
-	stmt, err = e.db.PrepareContext(ctx, queryString)
+	stmt, err = e.conn(ctx).PrepareContext(ctx, queryString)
 	if err != nil {
 		return groups, err
 	}
 
-	rows, err = stmt.QueryContext(ctx)
+	rows, err = stmt.QueryContext(ctx, f.args...)
 	if err != nil {
 		return groups, errors.Wrap(err, "failed to get groups list")
 	}
@@ -99,7 +102,7 @@ func (e *Embedded) FindGroups(ctx context.Context, filter engine.QueryFilter) (g
 		_ = rows.Close()
 	}()
 
-	if groups.Total = e.getTotalRecordsExcludeRange(groupsTable, filter, []string{"name"}); groups.Total == 0 {
+	if groups.Total = e.getTotalRecordsExcludeRange(ctx, groupsTable, filter, []string{"name"}); groups.Total == 0 {
 		return groups, nil // may be error handler catch
 	}
 	groups.Data = []interface{}{}
@@ -116,10 +119,10 @@ func (e *Embedded) FindGroups(ctx context.Context, filter engine.QueryFilter) (g
 
 // UpdateGroup update group records data
 func (e *Embedded) UpdateGroup(ctx context.Context, group store.Group) (err error) {
-	queryString := fmt.Sprintf(`UPDATE %s SET name='%s', description='%s' WHERE id = %d`, groupsTable, group.Name, group.Description, group.ID) //nolint:gosec // query sanitizing calling before
-	res, err := e.db.ExecContext(ctx, queryString)
+	queryString := fmt.Sprintf("UPDATE %s SET name=?, description=? WHERE id = ?", groupsTable)
+	res, err := e.conn(ctx).ExecContext(ctx, queryString, group.Name, group.Description, group.ID)
 	if err != nil {
-		return errors.Wrap(err, "failed to update user data")
+		return errors.Wrap(normalizeError(err), "failed to update user data")
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -134,11 +137,74 @@ func (e *Embedded) UpdateGroup(ctx context.Context, group store.Group) (err erro
 	return err
 }
 
-// DeleteGroup delete users group record by ID
+// DeleteGroup delete users group record by ID, membership of users in the group is deleted too
 func (e *Embedded) DeleteGroup(ctx context.Context, id int64) (err error) {
-	res, err := e.db.ExecContext(ctx, "DELETE FROM groups WHERE id = ?", id)
+	return e.WithTx(ctx, func(ctx context.Context) error {
+		res, err := e.conn(ctx).ExecContext(ctx, "DELETE FROM groups WHERE id = ?", id)
+		if err != nil {
+			return errors.Wrapf(err, "failed execute query for user delete")
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return engine.ErrNotFound
+		}
+
+		_, err = e.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE group_id = ?", groupMembersTable), id)
+		return errors.Wrap(err, "failed to delete group members")
+	})
+}
+
+// AddGroupMember add a user to a users group
+func (e *Embedded) AddGroupMember(ctx context.Context, member store.GroupMember) (err error) {
+	queryString := fmt.Sprintf("INSERT INTO %s (group_id, user_id) VALUES (?, ?)", groupMembersTable)
+	if _, err = e.conn(ctx).ExecContext(ctx, queryString, member.GroupID, member.UserID); err != nil {
+		return errors.Wrap(normalizeError(err), "failed to add group member")
+	}
+	return nil
+}
+
+// FindGroupMembers fetch list of groups membership by filter values
+func (e *Embedded) FindGroupMembers(ctx context.Context, filter engine.QueryFilter) (members engine.ListResponse, err error) {
+	f, err := filtersBuilder(groupMembersTable, filter)
+	if err != nil {
+		return members, err
+	}
+	queryString := fmt.Sprintf("SELECT group_id,user_id FROM %s %s", groupMembersTable, f.allClauses) //nolint:gosec // values passed as bound params
+
+	if members.Total = e.getTotalRecordsExcludeRange(ctx, groupMembersTable, filter, nil); members.Total == 0 {
+		return members, nil
+	}
+
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, f.args...)
 	if err != nil {
-		return errors.Wrapf(err, "failed execute query for user delete")
+		return members, errors.Wrap(err, "failed to get group members list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	members.Data = []interface{}{}
+	for rows.Next() {
+		var member store.GroupMember
+		if err = rows.Scan(&member.GroupID, &member.UserID); err != nil {
+			return members, errors.Wrap(err, "failed scan group member data")
+		}
+		members.Data = append(members.Data, member)
+	}
+
+	return members, rows.Err()
+}
+
+// DeleteGroupMember remove a user from a users group
+func (e *Embedded) DeleteGroupMember(ctx context.Context, member store.GroupMember) (err error) {
+	queryString := fmt.Sprintf("DELETE FROM %s WHERE group_id = ? AND user_id = ?", groupMembersTable)
+	res, err := e.conn(ctx).ExecContext(ctx, queryString, member.GroupID, member.UserID)
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for group member delete")
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
@@ -148,6 +214,15 @@ func (e *Embedded) DeleteGroup(ctx context.Context, id int64) (err error) {
 	if rowsAffected == 0 {
 		return engine.ErrNotFound
 	}
+	return nil
+}
 
-	return err
+// addPrimaryGroupMember keeps a user as a member of own primary group, an existed membership isn't an error
+func (e *Embedded) addPrimaryGroupMember(ctx context.Context, user store.User) error {
+	if user.Group <= 0 {
+		return nil
+	}
+	queryString := fmt.Sprintf("INSERT OR IGNORE INTO %s (group_id, user_id) VALUES (?, ?)", groupMembersTable)
+	_, err := e.conn(ctx).ExecContext(ctx, queryString, user.Group, user.ID)
+	return errors.Wrap(err, "failed to add user to the primary group")
 }
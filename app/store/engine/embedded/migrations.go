@@ -0,0 +1,461 @@
+package embedded
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const schemaVersionTable = "schema_version"
+
+// migration defines a single step of a database schema change
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations is an ordered list of schema changes. A new change should be appended to the end of list with the next
+// version number, already released migrations mustn't be changed because they never run again for existing databases.
+// Every migration should be idempotent, databases created before versioning was introduced don't have any records
+// in the schema_version table and all migrations are applied to their existing tables.
+var migrations = []migration{
+	{version: 1, description: "create initial tables", up: initialSchema},
+	{version: 2, description: "add is_group to unique key of access table", up: accessOwnerKindSchema},
+	{version: 3, description: "create group members table", up: groupMembersSchema},
+	{version: 4, description: "create robots table", up: robotsSchema},
+	{version: 5, description: "add sso_id column to users table", up: usersSSOSchema},
+	{version: 6, description: "add totp columns to users table", up: usersTOTPSchema},
+	{version: 7, description: "create refresh tokens table", up: refreshTokensSchema},
+	{version: 8, description: "add validity columns to access table", up: accessValiditySchema},
+	{version: 9, description: "add effect column to access table", up: accessEffectSchema},
+	{version: 10, description: "create roles table", up: rolesSchema},
+}
+
+// migrate applies all migrations which version is greater than the current database schema version.
+// Each migration runs in own transaction together with a schema version update.
+func (e *Embedded) migrate(ctx context.Context) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		version INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at INTEGER)`, schemaVersionTable)
+
+	if _, err := e.db.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", schemaVersionTable)
+	}
+
+	currentVersion, err := e.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= currentVersion {
+			continue
+		}
+
+		if err = e.applyMigration(ctx, m); err != nil {
+			return errors.Wrapf(err, "failed to apply schema migration %d (%s)", m.version, m.description)
+		}
+		log.Printf("[INFO] schema migration %d applied: %s", m.version, m.description)
+	}
+	return nil
+}
+
+func (e *Embedded) applyMigration(ctx context.Context, m migration) (err error) {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = m.up(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, description, applied_at) VALUES (?, ?, ?)", schemaVersionTable),
+		m.version, m.description, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the latest applied migration version, zero means a database hasn't any versioned changes yet
+func (e *Embedded) schemaVersion(ctx context.Context) (version int, err error) {
+	row := e.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", schemaVersionTable))
+	if err = row.Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "failed to get schema version")
+	}
+	return version, nil
+}
+
+// initialSchema creates tables with default records, the existing tables of databases created
+// by previous versions are kept as is
+func initialSchema(ctx context.Context, tx *sql.Tx) error {
+	if err := createGroupsTable(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := createUsersTable(ctx, tx); err != nil {
+		return err
+	}
+
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL,
+		is_group INTEGER,
+		name TEXT,
+		resource_type TEXT,
+		resource_name TEXT,
+		action TEXT,
+		disabled INTEGER,
+		UNIQUE(owner_id,resource_type,resource_name,action))`, accessTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", accessTable)
+	}
+
+	sqlText = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		repository_name INTEGER NOT NULL CHECK(repository_name <> ''),
+		tag TEXT NOT NULL CHECK(tag <> ''),
+		digest TEXT NOT NULL CHECK(digest <> ''),
+		config_digest TEXT NOT NULL CHECK(config_digest <> ''),
+		size INTEGER,
+		pull_counter INTEGER,
+		timestamp INTEGER,
+		raw TEXT,
+		UNIQUE(repository_name,tag))`, repositoriesTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", repositoriesTable)
+	}
+	return nil
+}
+
+func createGroupsTable(ctx context.Context, tx *sql.Tx) error {
+	if exist, err := isTableExist(ctx, tx, groupsTable); err != nil || exist {
+		return err
+	}
+
+	sqlText := fmt.Sprintf(`CREATE TABLE %s(
+	id    INTEGER PRIMARY KEY AUTOINCREMENT UNIQUE,
+	name TEXT UNIQUE,
+	description TEXT)`, groupsTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", groupsTable)
+	}
+
+	// create default admin group when a new database creation
+	group := store.Group{
+		Name:        "default",
+		Description: "Default administration group",
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT OR REPLACE INTO %s (name, description) values(?, ?)", groupsTable),
+		group.Name, group.Description)
+	if err != nil {
+		return errors.Wrapf(err, "failed to insert default group to %s table", groupsTable)
+	}
+	return nil
+}
+
+func createUsersTable(ctx context.Context, tx *sql.Tx) error {
+	if exist, err := isTableExist(ctx, tx, usersTable); err != nil || exist {
+		return err
+	}
+
+	sqlText := fmt.Sprintf(`CREATE TABLE %s(
+	id    INTEGER PRIMARY KEY AUTOINCREMENT UNIQUE,
+	login  TEXT UNIQUE,
+	name TEXT,
+	password TEXT,
+	role TEXT,
+	user_group INTEGER,
+	disabled INTEGER,
+	description TEXT)`, usersTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", usersTable)
+	}
+
+	defaultPassword := engine.GetAdminDefaultPassword(ctx)
+	if defaultPassword == "" {
+		defaultPassword = "admin"
+	}
+
+	// create default admin user if new database creation
+	user := store.User{
+		Login:       "admin", // is the default login
+		Name:        "admin",
+		Password:    defaultPassword,
+		Role:        "admin",
+		Group:       1,
+		Description: "Default user with administration role ",
+	}
+
+	// hashing user password
+	if errHash := user.HashAndSalt(); errHash != nil {
+		return errHash
+	}
+
+	createUserSQL := fmt.Sprintf(`INSERT OR REPLACE INTO %s (
+		login,
+		name,
+		password,
+		role,
+		user_group,
+		disabled,
+		description
+	) values(?, ?, ?, ?, ?, ?, ?)`, usersTable)
+
+	_, err := tx.ExecContext(ctx, createUserSQL, user.Login, user.Name, user.Password, user.Role, user.Group, 0, user.Description)
+	if err != nil {
+		return errors.Wrapf(err, "failed to insert new default user to %s table", usersTable)
+	}
+	return nil
+}
+
+// accessOwnerKindSchema makes rules of a user and a group with the same id independent of each other,
+// before that a group rule conflicted with a rule of the user which has the same id as the group
+func accessOwnerKindSchema(ctx context.Context, tx *sql.Tx) error {
+	return rebuildAccessTable(ctx, tx, "owner_id,is_group,resource_type,resource_name,action")
+}
+
+// groupMembersSchema creates a table for many-to-many relations between users and groups
+// and copies the single group of every existed user to it
+func groupMembersSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		UNIQUE(group_id,user_id))`, groupMembersTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", groupMembersTable)
+	}
+
+	sqlText = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_user_id ON %[1]s(user_id)", groupMembersTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create index of %s table", groupMembersTable)
+	}
+
+	sqlText = fmt.Sprintf("INSERT OR IGNORE INTO %s (group_id, user_id) SELECT user_group, id FROM %s WHERE user_group > 0",
+		groupMembersTable, usersTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to copy users groups to %s table", groupMembersTable)
+	}
+	return nil
+}
+
+// robotsSchema creates a table for robot accounts
+func robotsSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL CHECK(name <> ''),
+		description TEXT,
+		secret TEXT NOT NULL,
+		disabled INTEGER,
+		expires_at INTEGER,
+		created_at INTEGER,
+		permissions TEXT)`, robotsTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", robotsTable)
+	}
+	return nil
+}
+
+// usersSSOSchema adds a column with the identity of users at single sign-on providers, local users have it empty
+func usersSSOSchema(ctx context.Context, tx *sql.Tx) error {
+	return addColumns(ctx, tx, usersTable, []columnDefinition{{"sso_id", "TEXT NOT NULL DEFAULT ''"}})
+}
+
+// usersTOTPSchema adds columns with two-factor authentication settings of users
+func usersTOTPSchema(ctx context.Context, tx *sql.Tx) error {
+	return addColumns(ctx, tx, usersTable, []columnDefinition{
+		{"totp_enabled", "INTEGER NOT NULL DEFAULT 0"},
+		{"totp_secret", "TEXT NOT NULL DEFAULT ''"},
+		{"totp_recovery_codes", "TEXT NOT NULL DEFAULT ''"},
+		{"totp_last_step", "INTEGER NOT NULL DEFAULT 0"},
+	})
+}
+
+// refreshTokensSchema creates a table for refresh tokens of registry clients
+func refreshTokensSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		client_id TEXT,
+		hash TEXT UNIQUE NOT NULL CHECK(hash <> ''),
+		created_at INTEGER,
+		expires_at INTEGER,
+		last_used_at INTEGER)`, refreshTokensTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", refreshTokensTable)
+	}
+
+	sqlText = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_user_id ON %[1]s(user_id)", refreshTokensTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create index of %s table", refreshTokensTable)
+	}
+	return nil
+}
+
+// accessValiditySchema adds columns with validity window of access rules, existed rules aren't limited
+func accessValiditySchema(ctx context.Context, tx *sql.Tx) error {
+	return addColumns(ctx, tx, accessTable, []columnDefinition{
+		{"not_before", "INTEGER NOT NULL DEFAULT 0"},
+		{"expires_at", "INTEGER NOT NULL DEFAULT 0"},
+	})
+}
+
+// accessEffectSchema adds a column with effect of access rules, existed rules allow actions. The effect is a part
+// of the unique key, so an allow rule and a deny rule can be set for the same owner, resource and action.
+func accessEffectSchema(ctx context.Context, tx *sql.Tx) error {
+	err := addColumns(ctx, tx, accessTable, []columnDefinition{
+		{"effect", fmt.Sprintf("TEXT NOT NULL DEFAULT '%s'", store.AccessEffectAllow)},
+	})
+	if err != nil {
+		return err
+	}
+	return rebuildAccessTable(ctx, tx, "owner_id,is_group,resource_type,resource_name,action,effect")
+}
+
+// rolesSchema creates a table for roles of users, built-in roles are added at every start of storage
+func rolesSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL CHECK(name <> ''),
+		description TEXT NOT NULL DEFAULT '',
+		built_in INTEGER NOT NULL DEFAULT 0,
+		permissions TEXT NOT NULL DEFAULT '[]')`, rolesTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", rolesTable)
+	}
+	return nil
+}
+
+// rebuildAccessTable recreates the access table with a new unique key and copies all rules to it,
+// SQLite doesn't allow changing constraints of an existing table. The new table has the same columns
+// as the existing one, so the rebuild doesn't depend on columns which added by other migrations.
+func rebuildAccessTable(ctx context.Context, tx *sql.Tx, uniqueKey string) error {
+	columns, definitions, err := tableColumns(ctx, tx, accessTable)
+	if err != nil {
+		return err
+	}
+
+	tmpTable := accessTable + "_new"
+	columnsList := strings.Join(columns, ",")
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", tmpTable),
+		fmt.Sprintf("CREATE TABLE %s(\n\t\t%s,\n\t\tUNIQUE(%s))", tmpTable, strings.Join(definitions, ",\n\t\t"), uniqueKey),
+		fmt.Sprintf("INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM %[3]s", tmpTable, columnsList, accessTable),
+		// rules of previous versions can have an empty owner kind, they belong to users
+		fmt.Sprintf("UPDATE %s SET is_group = 0 WHERE is_group IS NULL", tmpTable),
+		// keep the sequence of identifiers, so ids of deleted rules aren't reused
+		fmt.Sprintf("DELETE FROM sqlite_sequence WHERE name = '%s'", tmpTable),
+		fmt.Sprintf("INSERT INTO sqlite_sequence (name, seq) SELECT '%s', seq FROM sqlite_sequence WHERE name = '%s'", tmpTable, accessTable),
+		fmt.Sprintf("DROP TABLE %s", accessTable),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmpTable, accessTable),
+	}
+
+	for _, stmt := range statements {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrapf(err, "failed to rebuild %s table", accessTable)
+		}
+	}
+	return nil
+}
+
+// tableColumns returns names and definitions of columns of an existing table, the primary key column
+// is always defined as an autoincrement identifier
+func tableColumns(ctx context.Context, q sqlConn, tableName string) (names, definitions []string, err error) {
+	rows, err := q.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?)`, tableName)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can't get columns of %s table", tableName)
+	}
+
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			name, columnType string
+			notNull, pk      bool
+			defaultValue     sql.NullString
+		)
+		if err = rows.Scan(&name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, nil, errors.Wrapf(err, "can't get columns of %s table", tableName)
+		}
+
+		definition := strings.TrimSpace(name + " " + columnType)
+		switch {
+		case pk:
+			definition += " PRIMARY KEY AUTOINCREMENT"
+		case notNull:
+			definition += " NOT NULL"
+		}
+		if defaultValue.Valid {
+			definition += " DEFAULT " + defaultValue.String
+		}
+		names = append(names, name)
+		definitions = append(definitions, definition)
+	}
+	return names, definitions, rows.Err()
+}
+
+// columnDefinition is a name and a type definition of a column which is added to an existing table
+type columnDefinition struct{ name, definition string }
+
+// addColumns adds columns which the table doesn't have yet
+func addColumns(ctx context.Context, tx *sql.Tx, tableName string, columns []columnDefinition) error {
+	for _, c := range columns {
+		exist, err := isColumnExist(ctx, tx, tableName, c.name)
+		if err != nil {
+			return err
+		}
+		if exist {
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, c.name, c.definition)); err != nil {
+			return errors.Wrapf(err, "failed to add %s column to %s table", c.name, tableName)
+		}
+	}
+	return nil
+}
+
+func isColumnExist(ctx context.Context, q sqlConn, tableName, columnName string) (exist bool, err error) {
+	rows, err := q.QueryContext(ctx, "SELECT name FROM pragma_table_info(?) WHERE name = ?", tableName, columnName)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't check for %s column of %s table exist", columnName, tableName)
+	}
+
+	defer func() { _ = rows.Close() }()
+	return rows.Next(), rows.Err()
+}
+
+func isTableExist(ctx context.Context, q sqlConn, tableName string) (exist bool, err error) {
+	rows, err := q.QueryContext(ctx, "SELECT DISTINCT tbl_name FROM sqlite_master WHERE tbl_name = ?", tableName)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't check for %s table exist", tableName)
+	}
+
+	defer func() { _ = rows.Close() }()
+	return rows.Next(), rows.Err()
+}
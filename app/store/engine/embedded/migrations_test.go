@@ -0,0 +1,149 @@
+package embedded
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestEmbedded_migrate(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
+
+	db := prepareTestDB(ctx, t, wg)
+
+	version, err := db.schemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+
+	// repeated migration shouldn't change anything
+	require.NoError(t, db.migrate(ctx))
+	assertDefaultRecords(ctx, t, db)
+
+	// a database created before schema versioning has tables but doesn't have any version records
+	_, err = db.db.Exec(fmt.Sprintf("DROP TABLE %s", schemaVersionTable))
+	require.NoError(t, err)
+	require.NoError(t, db.migrate(ctx))
+	assertDefaultRecords(ctx, t, db)
+
+	version, err = db.schemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+}
+
+func TestEmbedded_migrateFailed(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
+
+	db := prepareTestDB(ctx, t, wg)
+	lastVersion := migrations[len(migrations)-1].version
+
+	defer func(m []migration) { migrations = m }(migrations)
+	migrations = append(migrations, migration{
+		version:     lastVersion + 1,
+		description: "failed migration",
+		up: func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "CREATE TABLE test_table(id INTEGER)"); err != nil {
+				return err
+			}
+			return errors.New("test error")
+		},
+	})
+
+	err := db.migrate(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed migration")
+
+	// changes of failed migration should roll back
+	exist, err := isTableExist(ctx, db.db, "test_table")
+	require.NoError(t, err)
+	assert.False(t, exist)
+
+	version, err := db.schemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, lastVersion, version)
+}
+
+func TestEmbedded_migrateGroupMembers(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
+
+	db := prepareTestDB(ctx, t, wg)
+
+	// a database with schema version 2 keeps a single group of user in the users table only
+	_, err := db.db.Exec(fmt.Sprintf("DROP TABLE %s", groupMembersTable))
+	require.NoError(t, err)
+	_, err = db.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE version > 2", schemaVersionTable))
+	require.NoError(t, err)
+	_, err = db.db.Exec(fmt.Sprintf("INSERT INTO %s (login, name, password, role, user_group, disabled, description) "+
+		"VALUES ('foo', 'foo', 'hash', 'user', 2, 0, ''), ('bar', 'bar', 'hash', 'user', 0, 0, '')", usersTable))
+	require.NoError(t, err)
+
+	require.NoError(t, db.migrate(ctx))
+
+	members, err := db.FindGroupMembers(ctx, engine.QueryFilter{Sort: []string{"user_id", "asc"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), members.Total)
+	assert.Equal(t, store.GroupMember{GroupID: 1, UserID: 1}, members.Data[0])
+	assert.Equal(t, store.GroupMember{GroupID: 2, UserID: 2}, members.Data[1])
+}
+
+func TestEmbedded_migrateAccessUniqueKey(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
+
+	db := prepareTestDB(ctx, t, wg)
+
+	kept := store.Access{Name: "kept", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, db.CreateAccess(ctx, &kept))
+	removed := store.Access{Name: "removed", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "push"}
+	require.NoError(t, db.CreateAccess(ctx, &removed))
+	require.NoError(t, db.DeleteAccess(ctx, "id", removed.ID))
+
+	// the access table is rebuilt when the migration applies to a database of the previous schema version
+	_, err := db.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE version > 1", schemaVersionTable))
+	require.NoError(t, err)
+	require.NoError(t, db.migrate(ctx))
+
+	result, err := db.GetAccess(ctx, kept.ID)
+	require.NoError(t, err)
+	assert.Equal(t, kept, result)
+
+	groupAccess := store.Access{Name: "group", Owner: 2, IsGroup: true, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, db.CreateAccess(ctx, &groupAccess))
+	assert.Greater(t, groupAccess.ID, removed.ID, "ids of deleted rules shouldn't be reused")
+}
+
+func assertDefaultRecords(ctx context.Context, t *testing.T, db *Embedded) {
+	users, err := db.FindUsers(ctx, engine.QueryFilter{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), users.Total)
+
+	groups, err := db.FindGroups(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), groups.Total)
+}
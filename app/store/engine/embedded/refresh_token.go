@@ -0,0 +1,140 @@
+package embedded
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const refreshTokenFields = "id,user_id,client_id,hash,created_at,expires_at,last_used_at"
+
+// CreateRefreshToken create a new refresh token record
+func (e *Embedded) CreateRefreshToken(ctx context.Context, token *store.RefreshToken) (err error) {
+	if token.UserID == 0 || token.Hash == "" {
+		return errors.New("required refresh token fields not set: UserID, Hash")
+	}
+
+	createTokenSQL := fmt.Sprintf(`INSERT INTO %s (
+		user_id,
+		client_id,
+		hash,
+		created_at,
+		expires_at,
+		last_used_at
+	) values(?, ?, ?, ?, ?, ?)`, refreshTokensTable)
+
+	result, err := e.conn(ctx).ExecContext(ctx, createTokenSQL, token.UserID, token.ClientID, token.Hash, token.CreatedAt,
+		token.ExpiresAt, token.LastUsedAt)
+	if err != nil {
+		return errors.Wrap(normalizeError(err), "failed to add new refresh token")
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		token.ID = id
+	}
+	return err
+}
+
+// GetRefreshToken get refresh token data by hash of the token value
+func (e *Embedded) GetRefreshToken(ctx context.Context, hash string) (token store.RefreshToken, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM %s WHERE hash = ?", refreshTokenFields, refreshTokensTable)
+	token, err = scanRefreshToken(e.conn(ctx).QueryRowContext(ctx, queryString, hash).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, fmt.Errorf("refresh token %w", engine.ErrNotFound)
+	}
+	return token, err
+}
+
+// FindRefreshTokens fetch list of refresh tokens by filter values
+func (e *Embedded) FindRefreshTokens(ctx context.Context, filter engine.QueryFilter) (tokens engine.ListResponse, err error) {
+	f, err := filtersBuilder(refreshTokensTable, filter, "client_id")
+	if err != nil {
+		return tokens, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", refreshTokenFields, refreshTokensTable, f.allClauses) //nolint:gosec // values passed as bound params
+
+	if tokens.Total = e.getTotalRecordsExcludeRange(ctx, refreshTokensTable, filter, []string{"client_id"}); tokens.Total == 0 {
+		return tokens, nil
+	}
+
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return tokens, errors.Wrap(err, "failed to get refresh tokens list")
+	}
+	defer func() { _ = rows.Close() }()
+
+	tokens.Data = []interface{}{}
+	for rows.Next() {
+		token, errScan := scanRefreshToken(rows.Scan)
+		if errScan != nil {
+			return tokens, errScan
+		}
+		tokens.Data = append(tokens.Data, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// UpdateRefreshTokenUsage update time of the last use of refresh token
+func (e *Embedded) UpdateRefreshTokenUsage(ctx context.Context, id, usedAt int64) (err error) {
+	queryString := fmt.Sprintf("UPDATE %s SET last_used_at = ? WHERE id = ?", refreshTokensTable)
+	res, err := e.conn(ctx).ExecContext(ctx, queryString, usedAt, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update refresh token usage")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("refresh token %w", engine.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteRefreshToken delete refresh tokens by key
+func (e *Embedded) DeleteRefreshToken(ctx context.Context, key string, id interface{}) (err error) {
+	if !isAllowedField(refreshTokensTable, key) {
+		return &engine.InvalidKeyError{Key: key}
+	}
+
+	//nolint:gosec // key value checked with list of allowed fields
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", refreshTokensTable, key)
+	res, err := e.conn(ctx).ExecContext(ctx, query, castValue(id))
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for refresh token delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// RefreshTokenGarbageCollector deletes refresh tokens which expired before the time
+func (e *Embedded) RefreshTokenGarbageCollector(ctx context.Context, expiredBefore int64) (err error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at < ?", refreshTokensTable)
+	if _, err = e.conn(ctx).ExecContext(ctx, query, expiredBefore); err != nil {
+		return errors.Wrap(err, "failed execute query for refresh tokens garbage collector")
+	}
+	return nil
+}
+
+func scanRefreshToken(scan func(dest ...interface{}) error) (token store.RefreshToken, err error) {
+	err = scan(&token.ID, &token.UserID, &token.ClientID, &token.Hash, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return token, errors.Wrap(err, "failed scan refresh token data")
+	}
+	return token, err
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/zebox/registry-admin/app/store"
 	"github.com/zebox/registry-admin/app/store/engine"
 	"log"
+	"sort"
 	"strings"
 )
 
@@ -24,14 +25,14 @@ func (e *Embedded) CreateRepository(ctx context.Context, entry *store.RegistryEn
 		timestamp,
 		raw
 	) values(?, ?, ?, ?, ?, ?, ?, ?)`, repositoriesTable)
-	stmt, err := e.db.PrepareContext(ctx, createRepositorySQL)
+	stmt, err := e.conn(ctx).PrepareContext(ctx, createRepositorySQL)
 	if err != nil {
 		return errors.Wrap(err, "failed to create repository entry")
 	}
 	defer func() { _ = stmt.Close() }()
 	result, err := stmt.ExecContext(ctx, entry.RepositoryName, entry.Tag, entry.Digest, entry.ConfigDigest, entry.Size, entry.PullCounter, entry.Timestamp, entry.Raw)
 	if err != nil {
-		return err
+		return normalizeError(err)
 	}
 
 	id, err := result.LastInsertId()
@@ -45,7 +46,7 @@ func (e *Embedded) CreateRepository(ctx context.Context, entry *store.RegistryEn
 func (e *Embedded) GetRepository(ctx context.Context, entryID int64) (entry store.RegistryEntry, err error) { //nolint dupl
 
 	queryFilter := fmt.Sprintf("SELECT id, repository_name, tag, digest, config_digest, size, pull_counter, timestamp,raw FROM %s WHERE id = ?", repositoriesTable)
-	stmt, err := e.db.PrepareContext(ctx, queryFilter)
+	stmt, err := e.conn(ctx).PrepareContext(ctx, queryFilter)
 	if err != nil {
 		return entry, errors.Wrap(err, "failed to prepare query for get repository data")
 	}
@@ -65,7 +66,7 @@ func (e *Embedded) GetRepository(ctx context.Context, entryID int64) (entry stor
 		emptyResult = false
 	}
 	if emptyResult {
-		return entry, errors.New("repository entry not found")
+		return entry, fmt.Errorf("repository %w", engine.ErrNotFound)
 	}
 	return entry, nil
 }
@@ -73,7 +74,10 @@ func (e *Embedded) GetRepository(ctx context.Context, entryID int64) (entry stor
 // FindRepositories fetch list of existed repositories
 func (e *Embedded) FindRepositories(ctx context.Context, filter engine.QueryFilter) (entries engine.ListResponse, err error) {
 
-	f := filtersBuilder(filter, "repository_name", "tag") // set key filed for search query
+	f, err := filtersBuilder(repositoriesTable, filter, "repository_name", "tag") // set key filed for search query
+	if err != nil {
+		return entries, err
+	}
 
 	// It needs for check request for 'groupBy', that show repositories list.
 	// When request has 'groupBy' you should calculate summary size for each repository entry.
@@ -85,41 +89,24 @@ func (e *Embedded) FindRepositories(ctx context.Context, filter engine.QueryFilt
 		return "size"
 	}
 
-	//nolint:gosec // query sanitizing calling before
+	//nolint:gosec // values passed as bound params
 	queryString := fmt.Sprintf(
 		"SELECT id,repository_name,tag,digest,config_digest,"+
 			sizeAggregateCheckerFn(filter.GroupByField)+
 			",pull_counter,timestamp,raw FROM %s %s", repositoriesTable, f.allClauses,
 	)
 
-	// check for select repositories by user access
-	if _, ok := filter.Filters["access.owner_id"]; ok {
-		queryString = fmt.Sprintf("SELECT repositories.id as id,"+
-			"repository_name,"+
-			"tag,"+
-			"digest,"+
-			"config_digest,"+
-			sizeAggregateCheckerFn(filter.GroupByField)+","+
-			"pull_counter,"+
-			"timestamp,"+
-			"raw "+
-			"FROM %s "+
-			"INNER JOIN access on repositories.repository_name=access.resource_name %s",
-			repositoriesTable, f.allClauses,
-		)
-	}
-
 	// avoid error shadowed
 	var (
 		stmt *sql.Stmt
 		rows *sql.Rows
 	)
-	stmt, err = e.db.PrepareContext(ctx, queryString)
+	stmt, err = e.conn(ctx).PrepareContext(ctx, queryString)
 	if err != nil {
 		return entries, err
 	}
 
-	rows, err = stmt.QueryContext(ctx)
+	rows, err = stmt.QueryContext(ctx, f.args...)
 	if err != nil {
 		return entries, errors.Wrap(err, "failed to get repositories list")
 	}
@@ -127,7 +114,7 @@ func (e *Embedded) FindRepositories(ctx context.Context, filter engine.QueryFilt
 		_ = rows.Close()
 	}()
 
-	if entries.Total = e.getTotalRecordsExcludeRange(repositoriesTable, filter, []string{"repository_name", "tag"}); entries.Total == 0 {
+	if entries.Total = e.getTotalRecordsExcludeRange(ctx, repositoriesTable, filter, []string{"repository_name", "tag"}); entries.Total == 0 {
 		return entries, nil // may be error handler catch
 	}
 	entries.Data = []interface{}{}
@@ -144,26 +131,24 @@ func (e *Embedded) FindRepositories(ctx context.Context, filter engine.QueryFilt
 
 // UpdateRepository update repository entry data
 func (e *Embedded) UpdateRepository(ctx context.Context, conditionClause, data map[string]interface{}) (err error) {
-	// filled fields set for sql query
-	var fields = make([]string, 0)
-	for k, v := range data {
-		fields = append(fields, fmt.Sprintf("%s=%s", k, castValueTypeToString(v)))
+	fieldSet, args, err := assignmentList(data, ", ")
+	if err != nil {
+		return err
 	}
-	fieldSet := strings.Join(fields, ", ")
 
 	// parse WHERE clause keys and values
-	var conditions = make([]string, 0)
-	for k, v := range conditionClause {
-		conditions = append(conditions, fmt.Sprintf("%s=%s", k, castValueTypeToString(v)))
+	conditionSet, conditionArgs, err := assignmentList(conditionClause, " AND ")
+	if err != nil {
+		return err
 	}
-	conditionSet := strings.Join(conditions, " AND ")
+	args = append(args, conditionArgs...)
 
-	//nolint:gosec // WHERE clause is a combination of conditions which prepared from filter and can't be paste with ? value
+	//nolint:gosec // keys checked with list of allowed fields and values passed as bound params
 	queryString := fmt.Sprintf(`UPDATE %s SET %s WHERE %s`, repositoriesTable, fieldSet, conditionSet)
 
-	res, err := e.db.ExecContext(ctx, queryString)
+	res, err := e.conn(ctx).ExecContext(ctx, queryString, args...)
 	if err != nil {
-		return errors.Wrap(err, "failed to update repository data")
+		return errors.Wrap(normalizeError(err), "failed to update repository data")
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -183,7 +168,7 @@ func (e *Embedded) DeleteRepository(ctx context.Context, repositoryName, digest
 
 	//nolint:gosec // key value not passed from user input and can be change in code only
 	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND %s = ?", repositoriesTable, store.RegistryRepositoryNameField, store.RegistryContentDigestField)
-	res, err := e.db.ExecContext(ctx, deleteSQL, repositoryName, digest)
+	res, err := e.conn(ctx).ExecContext(ctx, deleteSQL, repositoryName, digest)
 	if err != nil {
 		return errors.Wrapf(err, "failed execute query for user delete")
 	}
@@ -204,7 +189,7 @@ func (e *Embedded) RepositoryGarbageCollector(ctx context.Context, syncDate int6
 
 	//nolint:gosec // SQL query prepares from static value inside code logic and doesn't pass key name from outside
 	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s<?", repositoriesTable, store.RegistryTimestampField)
-	res, err := e.db.ExecContext(ctx, deleteSQL, syncDate)
+	res, err := e.conn(ctx).ExecContext(ctx, deleteSQL, syncDate)
 	if err != nil {
 		return errors.Wrapf(err, "failed execute query for user delete")
 	}
@@ -217,3 +202,27 @@ func (e *Embedded) RepositoryGarbageCollector(ctx context.Context, syncDate int6
 	}
 	return err
 }
+
+// assignmentList builds list of 'key=?' pairs for the repositories table fields joined with separator,
+// keys sorted for keep order of bound params the same for each call
+func assignmentList(values map[string]interface{}, sep string) (clause string, args []interface{}, err error) {
+	if len(values) == 0 {
+		return "", nil, errors.New("list of fields for update is empty")
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if !isAllowedField(repositoriesTable, k) {
+			return "", nil, &engine.InvalidKeyError{Key: k}
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=?", k)
+		args = append(args, castValue(values[k]))
+	}
+	return strings.Join(pairs, sep), args, nil
+}
@@ -373,6 +373,13 @@ func TestEmbedded_UpdateRepository(t *testing.T) {
 	fieldForUpdate = map[string]interface{}{store.RegistryTagField: "test_tag_000"}
 	assert.Error(t, db.UpdateRepository(ctx, conditionClause, fieldForUpdate))
 
+	// keys which aren't fields of repositories table rejected
+	var keyErr *engine.InvalidKeyError
+	err = db.UpdateRepository(ctx, map[string]interface{}{"1=1 OR id": 1}, fieldForUpdate)
+	assert.ErrorAs(t, err, &keyErr)
+	err = db.UpdateRepository(ctx, conditionClause, map[string]interface{}{"tag='x', raw": "y"})
+	assert.ErrorAs(t, err, &keyErr)
+
 	// try with  bad or closed connection
 	badConn := Embedded{}
 	err = badConn.Connect(ctx)
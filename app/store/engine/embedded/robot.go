@@ -0,0 +1,207 @@
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const robotFields = "id,name,description,secret,disabled,expires_at,created_at,permissions"
+
+// CreateRobot create a new robot account record
+func (e *Embedded) CreateRobot(ctx context.Context, robot *store.Robot) (err error) {
+
+	var emptyParams []string
+
+	// check required parameters filled
+	if robot.Name == "" {
+		emptyParams = append(emptyParams, "Name")
+	}
+
+	if robot.Secret == "" {
+		emptyParams = append(emptyParams, "Secret")
+	}
+
+	if len(emptyParams) > 0 {
+		return fmt.Errorf("required robot fields not set: %s", strings.Join(emptyParams, ", "))
+	}
+
+	// a secret restored from backup is already hashed
+	if !engine.IsPasswordHashed(ctx) {
+		if errHash := robot.HashSecret(); errHash != nil {
+			return errHash
+		}
+	}
+
+	if robot.CreatedAt == 0 {
+		robot.CreatedAt = time.Now().Unix()
+	}
+
+	permissions, err := json.Marshal(robot.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode robot permissions")
+	}
+
+	createRobotSQL := fmt.Sprintf(`INSERT INTO %s (
+		name,
+		description,
+		secret,
+		disabled,
+		expires_at,
+		created_at,
+		permissions
+	) values(?, ?, ?, ?, ?, ?, ?)`, robotsTable)
+
+	result, err := e.conn(ctx).ExecContext(ctx, createRobotSQL, robot.Name, robot.Description, robot.Secret, robot.Disabled,
+		robot.ExpiresAt, robot.CreatedAt, string(permissions))
+	if err != nil {
+		return errors.Wrap(normalizeError(err), "failed to add new robot")
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		robot.ID = id
+	}
+	return err
+}
+
+// GetRobot get robot account data by ID or by name
+func (e *Embedded) GetRobot(ctx context.Context, id interface{}) (robot store.Robot, err error) {
+	var queryString string
+
+	switch val := id.(type) {
+	case string:
+		queryString = fmt.Sprintf("SELECT %s FROM %s WHERE name = ?", robotFields, robotsTable)
+
+		// cast ID value when ID as string type
+		if _, errParse := strconv.ParseInt(val, 10, 64); errParse == nil {
+			queryString = fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", robotFields, robotsTable)
+		}
+	case int, int64:
+		queryString = fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", robotFields, robotsTable)
+	default:
+		return robot, errors.New("unsupported id type")
+	}
+
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, id)
+	if err != nil {
+		return robot, errors.Wrap(err, "failed to get robot")
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		return robot, fmt.Errorf("robot %w", engine.ErrNotFound)
+	}
+	return scanRobot(rows.Scan)
+}
+
+// FindRobots fetch list of robot accounts by filter values
+func (e *Embedded) FindRobots(ctx context.Context, filter engine.QueryFilter, withSecret bool) (robots engine.ListResponse, err error) {
+	f, err := filtersBuilder(robotsTable, filter, "name", "description")
+	if err != nil {
+		return robots, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", robotFields, robotsTable, f.allClauses) //nolint:gosec // values passed as bound params
+
+	if robots.Total = e.getTotalRecordsExcludeRange(ctx, robotsTable, filter, []string{"name", "description"}); robots.Total == 0 {
+		return robots, nil
+	}
+
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return robots, errors.Wrap(err, "failed to get robots list")
+	}
+	defer func() { _ = rows.Close() }()
+
+	robots.Data = []interface{}{}
+	for rows.Next() {
+		robot, errScan := scanRobot(rows.Scan)
+		if errScan != nil {
+			return robots, errScan
+		}
+
+		if !withSecret {
+			robot.Secret = ""
+		}
+		robots.Data = append(robots.Data, robot)
+	}
+
+	return robots, rows.Err()
+}
+
+// UpdateRobot update robot account data
+func (e *Embedded) UpdateRobot(ctx context.Context, robot store.Robot) (err error) {
+	if robot.Secret != "" && !engine.IsPasswordHashed(ctx) {
+		if errHash := robot.HashSecret(); errHash != nil {
+			return errHash
+		}
+	}
+
+	permissions, err := json.Marshal(robot.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode robot permissions")
+	}
+
+	queryString := fmt.Sprintf("UPDATE %s SET name=?, description=?, disabled=?, expires_at=?, permissions=?", robotsTable)
+	args := []interface{}{robot.Name, robot.Description, robot.Disabled, robot.ExpiresAt, string(permissions)}
+
+	// skip a secret field update if updating secret value is empty
+	if robot.Secret != "" {
+		queryString += ", secret=?"
+		args = append(args, robot.Secret)
+	}
+	args = append(args, robot.ID)
+
+	res, err := e.conn(ctx).ExecContext(ctx, queryString+" WHERE id = ?", args...)
+	if err != nil {
+		return errors.Wrap(normalizeError(err), "failed to update robot data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("robot %w", engine.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteRobot delete robot account record by ID
+func (e *Embedded) DeleteRobot(ctx context.Context, id int64) (err error) {
+	res, err := e.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", robotsTable), id)
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for robot delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// scanRobot reads robot data with scan function of a row, permissions of robot store as JSON text
+func scanRobot(scan func(dest ...interface{}) error) (robot store.Robot, err error) {
+	var permissions string
+	if err = scan(&robot.ID, &robot.Name, &robot.Description, &robot.Secret, &robot.Disabled, &robot.ExpiresAt,
+		&robot.CreatedAt, &permissions); err != nil {
+		return robot, errors.Wrap(err, "failed scan robot data")
+	}
+
+	if err = json.Unmarshal([]byte(permissions), &robot.Permissions); err != nil {
+		return robot, errors.Wrap(err, "failed to decode robot permissions")
+	}
+	return robot, nil
+}
@@ -0,0 +1,177 @@
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const roleFields = "id,name,description,built_in,permissions"
+
+// CreateRole create a new custom role record
+func (e *Embedded) CreateRole(ctx context.Context, role *store.Role) (err error) {
+	if role.Name == "" {
+		return errors.New("required role fields not set: Name")
+	}
+
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode role permissions")
+	}
+
+	createRoleSQL := fmt.Sprintf("INSERT INTO %s (name, description, built_in, permissions) values(?, ?, ?, ?)", rolesTable)
+	result, err := e.conn(ctx).ExecContext(ctx, createRoleSQL, role.Name, role.Description, role.BuiltIn, string(permissions))
+	if err != nil {
+		return errors.Wrap(normalizeError(err), "failed to add new role")
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		role.ID = id
+	}
+	return err
+}
+
+// GetRole get role data by ID or by name
+func (e *Embedded) GetRole(ctx context.Context, id interface{}) (role store.Role, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", roleFields, rolesTable)
+
+	switch val := id.(type) {
+	case string:
+		// cast ID value when ID has name value
+		if _, errParse := strconv.ParseInt(val, 10, 64); errParse != nil {
+			queryString = fmt.Sprintf("SELECT %s FROM %s WHERE name = ?", roleFields, rolesTable)
+		}
+	case int, int64:
+	default:
+		return role, errors.New("unsupported id type")
+	}
+
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, id)
+	if err != nil {
+		return role, errors.Wrap(err, "failed to get role")
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		return role, fmt.Errorf("role %w", engine.ErrNotFound)
+	}
+	return scanRole(rows.Scan)
+}
+
+// FindRoles fetch list of roles by filter values
+func (e *Embedded) FindRoles(ctx context.Context, filter engine.QueryFilter) (roles engine.ListResponse, err error) {
+	f, err := filtersBuilder(rolesTable, filter, "name", "description")
+	if err != nil {
+		return roles, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", roleFields, rolesTable, f.allClauses) //nolint:gosec // values passed as bound params
+
+	roles.Data = []interface{}{}
+	if roles.Total = e.getTotalRecordsExcludeRange(ctx, rolesTable, filter, []string{"name", "description"}); roles.Total == 0 {
+		return roles, nil
+	}
+
+	rows, err := e.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return roles, errors.Wrap(err, "failed to get roles list")
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		role, errScan := scanRole(rows.Scan)
+		if errScan != nil {
+			return roles, errScan
+		}
+		roles.Data = append(roles.Data, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// UpdateRole update description and permissions of role
+func (e *Embedded) UpdateRole(ctx context.Context, role store.Role) (err error) {
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode role permissions")
+	}
+
+	res, err := e.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET description=?, permissions=? WHERE id = ?", rolesTable),
+		role.Description, string(permissions), role.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update role data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("role %w", engine.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteRole delete role record by ID
+func (e *Embedded) DeleteRole(ctx context.Context, id int64) (err error) {
+	res, err := e.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", rolesTable), id)
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for role delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// updateBuiltInRoles creates built-in roles or updates them with permissions of the current version
+func (e *Embedded) updateBuiltInRoles(ctx context.Context) error {
+	upsertSQL := fmt.Sprintf("INSERT INTO %s (name, description, built_in, permissions) values(?, ?, ?, ?) "+
+		"ON CONFLICT(name) DO UPDATE SET description=excluded.description, built_in=excluded.built_in, permissions=excluded.permissions",
+		rolesTable)
+
+	for _, role := range store.BuiltInRoles() {
+		permissions, err := json.Marshal(role.Permissions)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode role permissions")
+		}
+		if _, err = e.db.ExecContext(ctx, upsertSQL, role.Name, role.Description, role.BuiltIn, string(permissions)); err != nil {
+			return errors.Wrapf(err, "failed to update built-in role %q", role.Name)
+		}
+	}
+	return nil
+}
+
+// isRoleExist checks that a role with the name exists, users can be assigned to existed roles only
+func (e *Embedded) isRoleExist(ctx context.Context, name string) (bool, error) {
+	var count int64
+	err := e.conn(ctx).QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE name = ?", rolesTable), name).Scan(&count)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check role %q", name)
+	}
+	return count > 0, nil
+}
+
+// scanRole reads role data with scan function of a row, permissions of role store as JSON text
+func scanRole(scan func(dest ...interface{}) error) (role store.Role, err error) {
+	var permissions string
+	if err = scan(&role.ID, &role.Name, &role.Description, &role.BuiltIn, &permissions); err != nil {
+		return role, errors.Wrap(err, "failed scan role data")
+	}
+
+	if err = json.Unmarshal([]byte(permissions), &role.Permissions); err != nil {
+		return role, errors.Wrap(err, "failed to decode role permissions")
+	}
+	return role, nil
+}
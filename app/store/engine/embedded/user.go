@@ -3,6 +3,7 @@ package embedded
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"strconv"
@@ -15,6 +16,10 @@ import (
 
 const minPasswordLength = 6
 
+// userFields are columns of users table in order of scanUser arguments
+const userFields = "id,login,name,password,role,user_group,disabled,description," +
+	"totp_enabled,totp_secret,totp_recovery_codes,sso_id,totp_last_step"
+
 // CreateUser create a new user record
 func (e *Embedded) CreateUser(ctx context.Context, user *store.User) (err error) {
 
@@ -28,11 +33,16 @@ func (e *Embedded) CreateUser(ctx context.Context, user *store.User) (err error)
 	if user.Name == "" {
 		emptyParams = append(emptyParams, "Name")
 	}
-	if len(user.Password) < minPasswordLength {
+	passwordHashed := engine.IsPasswordHashed(ctx)
+	if !passwordHashed && len(user.Password) < minPasswordLength {
 		emptyParams = append(emptyParams, fmt.Sprintf("password length should be equal or more %d characters", minPasswordLength))
 	}
 
-	if !store.CheckRoleInList(user.Role) {
+	roleExist, err := e.isRoleExist(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if !roleExist {
 		emptyParams = append(emptyParams, fmt.Sprintf("role '%s' not allowed", user.Role))
 	}
 
@@ -44,9 +54,11 @@ func (e *Embedded) CreateUser(ctx context.Context, user *store.User) (err error)
 		user.Group = 1
 	}
 
-	// hashing password value
-	if errHash := user.HashAndSalt(); errHash != nil {
-		return errHash
+	// hashing password value, a password restored from backup is already hashed
+	if !passwordHashed {
+		if errHash := user.HashAndSalt(); errHash != nil {
+			return errHash
+		}
 	}
 
 	createUserSQL := fmt.Sprintf(`INSERT INTO %s (
@@ -56,23 +68,28 @@ func (e *Embedded) CreateUser(ctx context.Context, user *store.User) (err error)
 		role,
 		user_group,
 		disabled,
-		description
-	) values(?, ?, ?, ?, ?, ?, ?)`, usersTable)
-	stmt, err := e.db.Prepare(createUserSQL)
-	if err != nil {
-		return multierror.Append(err, errors.New("failed to add new user"))
-	}
-	defer func() { _ = stmt.Close() }()
-	result, err := stmt.ExecContext(ctx, user.Login, user.Name, user.Password, user.Role, user.Group, user.Disabled, user.Description)
-	if err != nil {
-		return multierror.Append(err, errors.New("failed to add new user"))
-	}
+		description,
+		sso_id
+	) values(?, ?, ?, ?, ?, ?, ?, ?)`, usersTable)
+
+	// a new user becomes a member of the primary group within the same transaction
+	return e.WithTx(ctx, func(ctx context.Context) error {
+		stmt, err := e.conn(ctx).PrepareContext(ctx, createUserSQL)
+		if err != nil {
+			return multierror.Append(err, errors.New("failed to add new user"))
+		}
+		defer func() { _ = stmt.Close() }()
+		result, err := stmt.ExecContext(ctx, user.Login, user.Name, user.Password, user.Role, user.Group, user.Disabled, user.Description,
+			user.SSOID)
+		if err != nil {
+			return multierror.Append(normalizeError(err), errors.New("failed to add new user"))
+		}
 
-	id, err := result.LastInsertId()
-	if err == nil {
-		user.ID = id
-	}
-	return err
+		if user.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		return e.addPrimaryGroupMember(ctx, *user)
+	})
 }
 
 // GetUser get data by user ID
@@ -82,19 +99,19 @@ func (e *Embedded) GetUser(ctx context.Context, id interface{}) (user store.User
 	switch val := id.(type) {
 	case string:
 		// cast ID value when ID has login value
-		queryString = fmt.Sprintf("select id,login,name,password,role,user_group,disabled,description from %s where login = ?", usersTable)
+		queryString = fmt.Sprintf("SELECT %s FROM %s WHERE login = ?", userFields, usersTable)
 
 		// cast ID value when ID as string type
 		if _, errParse := strconv.ParseInt(val, 10, 64); errParse == nil {
-			queryString = fmt.Sprintf("SELECT id,login,name,password,role,user_group,disabled,description FROM  %s WHERE id = ?", usersTable)
+			queryString = fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", userFields, usersTable)
 		}
 	case int, int64:
-		queryString = fmt.Sprintf("select id,login,name,password,role,user_group,disabled,description from %s where id = ?", usersTable)
+		queryString = fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", userFields, usersTable)
 	default:
 		return user, errors.New("unsupported id type")
 	}
 
-	stmt, errPrep := e.db.PrepareContext(ctx, queryString)
+	stmt, errPrep := e.conn(ctx).PrepareContext(ctx, queryString)
 	if errPrep != nil {
 		return user, multierror.Append(err, errors.New("failed to get user"))
 	}
@@ -109,33 +126,36 @@ func (e *Embedded) GetUser(ctx context.Context, id interface{}) (user store.User
 
 	emptyResult := true
 	for rows.Next() {
-		if err = rows.Scan(&user.ID, &user.Login, &user.Name, &user.Password, &user.Role, &user.Group, &user.Disabled, &user.Description); err != nil {
+		if user, err = scanUser(rows.Scan); err != nil {
 			return user, multierror.Append(err, errors.New("failed scan user data"))
 		}
 		emptyResult = false
 	}
 	if emptyResult {
-		return user, errors.New("record not found")
+		return user, engine.ErrNotFound
 	}
 	return user, nil
 }
 
 // FindUsers fetch list of user by filter values
 func (e *Embedded) FindUsers(ctx context.Context, filter engine.QueryFilter, withPassword bool) (users engine.ListResponse, err error) {
-	f := filtersBuilder(filter, "login", "name")
-	queryString := fmt.Sprintf("SELECT id,login,name,password,role,user_group,disabled,description FROM %s %s", usersTable, f.allClauses) //nolint:gosec // query sanitizing calling before
+	f, err := filtersBuilder(usersTable, filter, "login", "name")
+	if err != nil {
+		return users, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", userFields, usersTable, f.allClauses) //nolint:gosec // values passed as bound params
 
 	// avoid error shadowed
 	var (
 		stmt *sql.Stmt
 		rows *sql.Rows
 	)
-	stmt, err = e.db.PrepareContext(ctx, queryString)
+	stmt, err = e.conn(ctx).PrepareContext(ctx, queryString)
 	if err != nil {
 		return users, err
 	}
 
-	rows, err = stmt.QueryContext(ctx)
+	rows, err = stmt.QueryContext(ctx, f.args...)
 	if err != nil {
 		return users, errors.Wrap(err, "failed to get users list")
 	}
@@ -143,19 +163,20 @@ func (e *Embedded) FindUsers(ctx context.Context, filter engine.QueryFilter, wit
 		_ = rows.Close()
 	}()
 
-	if users.Total = e.getTotalRecordsExcludeRange(usersTable, filter, []string{"login", "password"}); users.Total == 0 {
+	if users.Total = e.getTotalRecordsExcludeRange(ctx, usersTable, filter, []string{"login", "name"}); users.Total == 0 {
 		return users, nil // may be error handler catch
 	}
 	users.Data = []interface{}{}
 	for rows.Next() {
-		var user store.User
-		if err = rows.Scan(&user.ID, &user.Login, &user.Name, &user.Password, &user.Role, &user.Group, &user.Disabled, &user.Description); err != nil {
-			return users, errors.Wrap(err, "failed scan user data")
+		user, errScan := scanUser(rows.Scan)
+		if errScan != nil {
+			return users, errors.Wrap(errScan, "failed scan user data")
 		}
 
-		// clear password value when user fetch
+		// clear password value and second factor secrets when user fetch
 		if !withPassword {
 			user.Password = ""
+			user.TOTP.Secret, user.TOTP.RecoveryCodes = "", nil
 		}
 
 		users.Data = append(users.Data, user)
@@ -167,11 +188,14 @@ func (e *Embedded) FindUsers(ctx context.Context, filter engine.QueryFilter, wit
 // UpdateUser update user records data
 func (e *Embedded) UpdateUser(ctx context.Context, user store.User) (err error) {
 
-	if !store.CheckRoleInList(user.Role) {
+	roleExist, err := e.isRoleExist(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if !roleExist {
 		return errors.Errorf("role '%s' not allowed", user.Role)
 	}
-	var res sql.Result
-	if user.Password != "" {
+	if user.Password != "" && !engine.IsPasswordHashed(ctx) {
 		if len(user.Password) < minPasswordLength {
 			return fmt.Errorf("password length should be equal or more %d characters", minPasswordLength)
 		}
@@ -179,17 +203,49 @@ func (e *Embedded) UpdateUser(ctx context.Context, user store.User) (err error)
 		if errHash := user.HashAndSalt(); errHash != nil {
 			return errHash
 		}
-		res, err = e.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET name=?, password=?, role=?, user_group=?, disabled=?, description=? WHERE id = ?", usersTable),
-			user.Name, user.Password, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+	}
+
+	// a changed primary group is added to the user groups, membership in the previous one is kept
+	return e.WithTx(ctx, func(ctx context.Context) (err error) {
+		var res sql.Result
+		if user.Password != "" {
+			res, err = e.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET name=?, password=?, role=?, user_group=?, disabled=?, description=? WHERE id = ?", usersTable),
+				user.Name, user.Password, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+
+		} else {
+			// skip a password field update if updating password value is empty
+			res, err = e.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET name=?, role=?, user_group=?, disabled=?, description=? WHERE id = ?", usersTable),
+				user.Name, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+		}
+
+		if err != nil {
+			return errors.Wrap(normalizeError(err), "failed to update user data")
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return errors.New("record didn't update")
+		}
 
-	} else {
-		// skip a password field update if updating password value is empty
-		res, err = e.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET name=?, role=?, user_group=?, disabled=?, description=? WHERE id = ?", usersTable),
-			user.Name, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+		return e.addPrimaryGroupMember(ctx, user)
+	})
+}
+
+// UpdateUserTOTP update two-factor authentication settings of user, recovery codes hashes store as JSON text
+func (e *Embedded) UpdateUserTOTP(ctx context.Context, id int64, totp store.TOTP) (err error) {
+	recoveryCodes, err := json.Marshal(totp.RecoveryCodes)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recovery codes")
 	}
 
+	res, err := e.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET totp_enabled=?, totp_secret=?, totp_recovery_codes=? WHERE id = ?", usersTable),
+		totp.Enabled, totp.Secret, string(recoveryCodes), id)
 	if err != nil {
-		return errors.Wrap(err, "failed to update user data")
+		return errors.Wrap(err, "failed to update user totp")
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -198,18 +254,20 @@ func (e *Embedded) UpdateUser(ctx context.Context, user store.User) (err error)
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("record didn't update")
+		return engine.ErrNotFound
 	}
-
-	return err
+	return nil
 }
 
-// DeleteUser delete user record by ID
-func (e *Embedded) DeleteUser(ctx context.Context, id int64) (err error) {
-	res, err := e.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+// UpdateUserTOTPStep stores the time step of an accepted one-time password, the stored step is changed only when
+// it's before the new one, so concurrent requests with the same code can't both be accepted
+func (e *Embedded) UpdateUserTOTPStep(ctx context.Context, id, step int64) (err error) {
+	res, err := e.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET totp_last_step=? WHERE id = ? AND totp_last_step < ?", usersTable),
+		step, id, step)
 	if err != nil {
-		return errors.Wrapf(err, "failed execute query for user delete")
+		return errors.Wrap(err, "failed to update user totp step")
 	}
+
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return err
@@ -218,6 +276,47 @@ func (e *Embedded) DeleteUser(ctx context.Context, id int64) (err error) {
 	if rowsAffected == 0 {
 		return engine.ErrNotFound
 	}
+	return nil
+}
 
-	return err
+// DeleteUser delete user record by ID, membership of the user in groups and refresh tokens of the user are deleted too
+func (e *Embedded) DeleteUser(ctx context.Context, id int64) (err error) {
+	return e.WithTx(ctx, func(ctx context.Context) error {
+		res, err := e.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+		if err != nil {
+			return errors.Wrapf(err, "failed execute query for user delete")
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return engine.ErrNotFound
+		}
+
+		if _, err = e.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", groupMembersTable), id); err != nil {
+			return errors.Wrap(err, "failed to delete user from groups")
+		}
+
+		_, err = e.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", refreshTokensTable), id)
+		return errors.Wrap(err, "failed to delete refresh tokens of user")
+	})
+}
+
+// scanUser reads user data with scan function of a row
+func scanUser(scan func(dest ...interface{}) error) (user store.User, err error) {
+	var recoveryCodes string
+	err = scan(&user.ID, &user.Login, &user.Name, &user.Password, &user.Role, &user.Group, &user.Disabled, &user.Description,
+		&user.TOTP.Enabled, &user.TOTP.Secret, &recoveryCodes, &user.SSOID, &user.TOTP.LastStep)
+	if err != nil {
+		return user, err
+	}
+
+	if recoveryCodes != "" {
+		if err = json.Unmarshal([]byte(recoveryCodes), &user.TOTP.RecoveryCodes); err != nil {
+			return user, errors.Wrap(err, "failed to decode recovery codes")
+		}
+	}
+	return user, nil
 }
@@ -210,21 +210,28 @@ func TestEmbedded_FindUsers(t *testing.T) {
 	assert.Equal(t, result.Total, int64(5))
 	require.Equal(t, len(result.Data), 5)
 
-	// test bas query syntax
+	// keys which aren't fields of table rejected instead of a sanitizing
+	var keyErr *engine.InvalidKeyError
 	filter.Filters = map[string]interface{}{"q": "", "role LIKE": "%%%%"}
-	filter.Range = [2]int64{0, 0} // reset range
-
-	result, err = db.FindUsers(ctx, filter, false)
-	assert.NoError(t, err)
-	assert.Equal(t, 0, len(result.Data))
+	_, err = db.FindUsers(ctx, filter, false)
+	require.ErrorAs(t, err, &keyErr)
+	assert.Equal(t, "role LIKE", keyErr.Key)
 
 	// test for sql-injection
 	filter.Filters = map[string]interface{}{"q": "", "role LIKE '%%') --": "user"}
-	filter.Range = [2]int64{0, 0} // reset range
+	_, err = db.FindUsers(ctx, filter, false)
+	assert.ErrorAs(t, err, &keyErr)
+
+	_, err = db.FindUsers(ctx, engine.QueryFilter{Sort: []string{"password", "asc"}}, false)
+	assert.ErrorAs(t, err, &keyErr)
 
+	// injection in value doesn't change a query
+	filter.Filters = map[string]interface{}{"q": "' OR 1=1 --"}
 	result, err = db.FindUsers(ctx, filter, false)
 	assert.NoError(t, err)
-	assert.Equal(t, 1, len(result.Data))
+	assert.Equal(t, 0, len(result.Data))
+
+	filter.Filters = map[string]interface{}{"q": ""}
 
 	// test with connection error
 	badConn := Embedded{}
@@ -339,7 +346,7 @@ func prepareTestDB(ctx context.Context, t *testing.T, wg *sync.WaitGroup) *Embed
 
 	_ = os.Remove(testDBPath)
 
-	sqlite := NewEmbedded(testDBPath)
+	sqlite := &Embedded{Path: testDBPath, Driver: testDriver}
 	err := sqlite.Connect(ctx)
 	require.NoError(t, err)
 	wg.Add(1)
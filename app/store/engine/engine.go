@@ -6,25 +6,47 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/zebox/registry-admin/app/store"
 	"net/url"
 	"regexp"
 	"strconv"
 )
 
-// RepositoriesByUserAccess allow filtered repositories result list by assigned user access
-// it's relevant only for role 'user' only
+// RepositoriesByUserAccess allow filtered repositories result list by assigned user access,
+// accesses of all groups which the user is a member of are included too. It's relevant only for role 'user' only.
+// The same as the access check of tokens, only the most specific allow rules of the user and the user groups apply,
+// a repository is selected when one of them grants an action which deny rules don't forbid. Deny rules of the user,
+// the user groups and rules shared for all users apply regardless of specificity, a repository which they forbid
+// to pull isn't selected.
 const (
 	RepositoriesByUserAccess = "access.owner_id"
 	AnonymousUserID          = int64(-1000) // uses for share access for anonymous and not registered users
 	RegisteredUserID         = int64(-999)  // uses for share access for registered users only
+	RobotUserID              = int64(-998)  // uses as an identity of robot accounts, access rules don't apply to it
 )
 
+// AccessExpiresBefore allow filtered access list by expiry time, the result contains accesses which have
+// a validity window and expire before the time or at it (unix time)
+const AccessExpiresBefore = "expires_before"
+
 type engineOptionsCtx string
 
 // ErrNotFound return empty result error with request
 var ErrNotFound = errors.New("record not found")
 
+// ErrDuplicateEntry return when a new record violates a unique constraint of storage
+var ErrDuplicateEntry = errors.New("record already exists")
+
+// InvalidKeyError returns when a query refers to a field which isn't allowed for filter, sort or update of records
+type InvalidKeyError struct {
+	Key string
+}
+
+func (e *InvalidKeyError) Error() string {
+	return fmt.Sprintf("invalid key '%s'", e.Key)
+}
+
 // ListResponse is a container for return list of result data
 type ListResponse struct {
 	Total int64         `json:"total"`
@@ -47,6 +69,13 @@ type Interface interface {
 	// UpdateUser update user records data in application storage
 	UpdateUser(ctx context.Context, user store.User) (err error)
 
+	// UpdateUserTOTP update two-factor authentication settings of user, other fields of user are kept as is
+	UpdateUserTOTP(ctx context.Context, id int64, totp store.TOTP) (err error)
+
+	// UpdateUserTOTPStep stores the time step of an accepted one-time password of user, the step should be after
+	// the stored one, otherwise ErrNotFound returns and the code should be refused as already used
+	UpdateUserTOTPStep(ctx context.Context, id int64, step int64) (err error)
+
 	// DeleteUser delete user record by ID
 	DeleteUser(ctx context.Context, id int64) (err error)
 
@@ -65,6 +94,15 @@ type Interface interface {
 	// DeleteGroup delete users group record by ID
 	DeleteGroup(ctx context.Context, id int64) (err error)
 
+	// AddGroupMember add a user to a users group, ErrDuplicateEntry returns when the user is a member of the group already
+	AddGroupMember(ctx context.Context, member store.GroupMember) (err error)
+
+	// FindGroupMembers fetch list of groups membership by filter values, e.g. by 'group_id' or 'user_id'
+	FindGroupMembers(ctx context.Context, filter QueryFilter) (members ListResponse, err error)
+
+	// DeleteGroupMember remove a user from a users group
+	DeleteGroupMember(ctx context.Context, member store.GroupMember) (err error)
+
 	// CreateAccess create a new access record for a specific user and repository
 	CreateAccess(ctx context.Context, access *store.Access) (err error)
 
@@ -80,6 +118,10 @@ type Interface interface {
 	// DeleteAccess delete access record by ID
 	DeleteAccess(ctx context.Context, key string, id interface{}) (err error)
 
+	// DeleteOwnerAccesses deletes all accesses of a user or a group, users and groups have separate sequences of IDs,
+	// so the owner kind is required. It doesn't fail when the owner hasn't accesses.
+	DeleteOwnerAccesses(ctx context.Context, ownerID int64, isGroup bool) (err error)
+
 	// AccessGarbageCollector check outdated repositories in repositories table and delete ones from access list
 	AccessGarbageCollector(ctx context.Context) error
 
@@ -101,6 +143,59 @@ type Interface interface {
 	// RepositoryGarbageCollector deletes outdated repositories entries
 	RepositoryGarbageCollector(ctx context.Context, syncDate int64) (err error)
 
+	// CreateRobot create a new robot account record, the secret of robot is hashed before save
+	CreateRobot(ctx context.Context, robot *store.Robot) (err error)
+
+	// GetRobot get robot account data by ID or by name, a result contains hash of the secret
+	GetRobot(ctx context.Context, id interface{}) (robot store.Robot, err error)
+
+	// FindRobots fetch list of robot accounts by filter values.
+	// @withSecret defines include or not hash value of secret to result
+	FindRobots(ctx context.Context, filter QueryFilter, withSecret bool) (robots ListResponse, err error)
+
+	// UpdateRobot update robot account data, the secret is kept when a value of it is empty
+	UpdateRobot(ctx context.Context, robot store.Robot) (err error)
+
+	// DeleteRobot delete robot account record by ID
+	DeleteRobot(ctx context.Context, id int64) (err error)
+
+	// CreateRole create a new custom role record
+	CreateRole(ctx context.Context, role *store.Role) (err error)
+
+	// GetRole get role data by ID or by name
+	GetRole(ctx context.Context, id interface{}) (role store.Role, err error)
+
+	// FindRoles fetch list of roles by filter values
+	FindRoles(ctx context.Context, filter QueryFilter) (roles ListResponse, err error)
+
+	// UpdateRole update description and permissions of role, a name isn't changed because users refer to roles by name
+	UpdateRole(ctx context.Context, role store.Role) (err error)
+
+	// DeleteRole delete role record by ID
+	DeleteRole(ctx context.Context, id int64) (err error)
+
+	// CreateRefreshToken create a new refresh token record of user, the token is kept with a hash of its value only
+	CreateRefreshToken(ctx context.Context, token *store.RefreshToken) (err error)
+
+	// GetRefreshToken get refresh token data by hash of the token value
+	GetRefreshToken(ctx context.Context, hash string) (token store.RefreshToken, err error)
+
+	// FindRefreshTokens fetch list of refresh tokens by filter values, e.g. by 'user_id'
+	FindRefreshTokens(ctx context.Context, filter QueryFilter) (tokens ListResponse, err error)
+
+	// UpdateRefreshTokenUsage update time of the last use of refresh token
+	UpdateRefreshTokenUsage(ctx context.Context, id, usedAt int64) (err error)
+
+	// DeleteRefreshToken delete refresh tokens by key, e.g. by 'id' for revoke one token or by 'user_id' for revoke all tokens of user
+	DeleteRefreshToken(ctx context.Context, key string, id interface{}) (err error)
+
+	// RefreshTokenGarbageCollector deletes refresh tokens which expired before the time
+	RefreshTokenGarbageCollector(ctx context.Context, expiredBefore int64) (err error)
+
+	// WithTx runs fn in a transaction, all calls of storage with the context passed to fn are part of this transaction.
+	// The transaction commits when fn returns nil and rolls back otherwise
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+
 	// Close connection to storage instance
 	Close(ctx context.Context) error
 }
@@ -199,3 +294,30 @@ func GetAdminDefaultPassword(ctx context.Context) string {
 	}
 	return ""
 }
+
+const passwordHashedKey = "password_hashed_key"
+
+// SetPasswordHashed marks passwords of users which create or update with the returned context as already hashed,
+// storage keeps them as is without hashing and length validation. It uses for restore users from a backup.
+func SetPasswordHashed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, engineOptionsCtx(passwordHashedKey), true)
+}
+
+// IsPasswordHashed checks that passwords of users passed with context are already hashed
+func IsPasswordHashed(ctx context.Context) bool {
+	hashed, ok := ctx.Value(engineOptionsCtx(passwordHashedKey)).(bool)
+	return ok && hashed
+}
+
+// CheckRoleExists returns an error when the storage doesn't have the role, settings which refer to roles
+// accept built-in and custom roles
+func CheckRoleExists(ctx context.Context, s Interface, role string) error {
+	_, err := s.GetRole(ctx, role)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("role %q not found", role)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get role %q: %w", role, err)
+	}
+	return nil
+}
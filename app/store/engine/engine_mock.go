@@ -22,6 +22,9 @@ var _ Interface = &InterfaceMock{}
 //			AccessGarbageCollectorFunc: func(ctx context.Context) error {
 //				panic("mock out the AccessGarbageCollector method")
 //			},
+//			AddGroupMemberFunc: func(ctx context.Context, member store.GroupMember) error {
+//				panic("mock out the AddGroupMember method")
+//			},
 //			CloseFunc: func(ctx context.Context) error {
 //				panic("mock out the Close method")
 //			},
@@ -31,9 +34,18 @@ var _ Interface = &InterfaceMock{}
 //			CreateGroupFunc: func(ctx context.Context, group *store.Group) error {
 //				panic("mock out the CreateGroup method")
 //			},
+//			CreateRefreshTokenFunc: func(ctx context.Context, token *store.RefreshToken) error {
+//				panic("mock out the CreateRefreshToken method")
+//			},
 //			CreateRepositoryFunc: func(ctx context.Context, entry *store.RegistryEntry) error {
 //				panic("mock out the CreateRepository method")
 //			},
+//			CreateRobotFunc: func(ctx context.Context, robot *store.Robot) error {
+//				panic("mock out the CreateRobot method")
+//			},
+//			CreateRoleFunc: func(ctx context.Context, role *store.Role) error {
+//				panic("mock out the CreateRole method")
+//			},
 //			CreateUserFunc: func(ctx context.Context, user *store.User) error {
 //				panic("mock out the CreateUser method")
 //			},
@@ -43,21 +55,48 @@ var _ Interface = &InterfaceMock{}
 //			DeleteGroupFunc: func(ctx context.Context, id int64) error {
 //				panic("mock out the DeleteGroup method")
 //			},
+//			DeleteGroupMemberFunc: func(ctx context.Context, member store.GroupMember) error {
+//				panic("mock out the DeleteGroupMember method")
+//			},
+//			DeleteOwnerAccessesFunc: func(ctx context.Context, ownerID int64, isGroup bool) error {
+//				panic("mock out the DeleteOwnerAccesses method")
+//			},
+//			DeleteRefreshTokenFunc: func(ctx context.Context, key string, id interface{}) error {
+//				panic("mock out the DeleteRefreshToken method")
+//			},
 //			DeleteRepositoryFunc: func(ctx context.Context, repositoryName string, digest string) error {
 //				panic("mock out the DeleteRepository method")
 //			},
+//			DeleteRobotFunc: func(ctx context.Context, id int64) error {
+//				panic("mock out the DeleteRobot method")
+//			},
+//			DeleteRoleFunc: func(ctx context.Context, id int64) error {
+//				panic("mock out the DeleteRole method")
+//			},
 //			DeleteUserFunc: func(ctx context.Context, id int64) error {
 //				panic("mock out the DeleteUser method")
 //			},
 //			FindAccessesFunc: func(ctx context.Context, filter QueryFilter) (ListResponse, error) {
 //				panic("mock out the FindAccesses method")
 //			},
+//			FindGroupMembersFunc: func(ctx context.Context, filter QueryFilter) (ListResponse, error) {
+//				panic("mock out the FindGroupMembers method")
+//			},
 //			FindGroupsFunc: func(ctx context.Context, filter QueryFilter) (ListResponse, error) {
 //				panic("mock out the FindGroups method")
 //			},
+//			FindRefreshTokensFunc: func(ctx context.Context, filter QueryFilter) (ListResponse, error) {
+//				panic("mock out the FindRefreshTokens method")
+//			},
 //			FindRepositoriesFunc: func(ctx context.Context, filter QueryFilter) (ListResponse, error) {
 //				panic("mock out the FindRepositories method")
 //			},
+//			FindRobotsFunc: func(ctx context.Context, filter QueryFilter, withSecret bool) (ListResponse, error) {
+//				panic("mock out the FindRobots method")
+//			},
+//			FindRolesFunc: func(ctx context.Context, filter QueryFilter) (ListResponse, error) {
+//				panic("mock out the FindRoles method")
+//			},
 //			FindUsersFunc: func(ctx context.Context, filter QueryFilter, withPassword bool) (ListResponse, error) {
 //				panic("mock out the FindUsers method")
 //			},
@@ -67,12 +106,24 @@ var _ Interface = &InterfaceMock{}
 //			GetGroupFunc: func(ctx context.Context, id int64) (store.Group, error) {
 //				panic("mock out the GetGroup method")
 //			},
+//			GetRefreshTokenFunc: func(ctx context.Context, hash string) (store.RefreshToken, error) {
+//				panic("mock out the GetRefreshToken method")
+//			},
 //			GetRepositoryFunc: func(ctx context.Context, entryID int64) (store.RegistryEntry, error) {
 //				panic("mock out the GetRepository method")
 //			},
+//			GetRobotFunc: func(ctx context.Context, id interface{}) (store.Robot, error) {
+//				panic("mock out the GetRobot method")
+//			},
+//			GetRoleFunc: func(ctx context.Context, id interface{}) (store.Role, error) {
+//				panic("mock out the GetRole method")
+//			},
 //			GetUserFunc: func(ctx context.Context, id interface{}) (store.User, error) {
 //				panic("mock out the GetUser method")
 //			},
+//			RefreshTokenGarbageCollectorFunc: func(ctx context.Context, expiredBefore int64) error {
+//				panic("mock out the RefreshTokenGarbageCollector method")
+//			},
 //			RepositoryGarbageCollectorFunc: func(ctx context.Context, syncDate int64) error {
 //				panic("mock out the RepositoryGarbageCollector method")
 //			},
@@ -82,12 +133,30 @@ var _ Interface = &InterfaceMock{}
 //			UpdateGroupFunc: func(ctx context.Context, group store.Group) error {
 //				panic("mock out the UpdateGroup method")
 //			},
+//			UpdateRefreshTokenUsageFunc: func(ctx context.Context, id int64, usedAt int64) error {
+//				panic("mock out the UpdateRefreshTokenUsage method")
+//			},
 //			UpdateRepositoryFunc: func(ctx context.Context, conditionClause map[string]interface{}, data map[string]interface{}) error {
 //				panic("mock out the UpdateRepository method")
 //			},
+//			UpdateRobotFunc: func(ctx context.Context, robot store.Robot) error {
+//				panic("mock out the UpdateRobot method")
+//			},
+//			UpdateRoleFunc: func(ctx context.Context, role store.Role) error {
+//				panic("mock out the UpdateRole method")
+//			},
 //			UpdateUserFunc: func(ctx context.Context, user store.User) error {
 //				panic("mock out the UpdateUser method")
 //			},
+//			UpdateUserTOTPFunc: func(ctx context.Context, id int64, totp store.TOTP) error {
+//				panic("mock out the UpdateUserTOTP method")
+//			},
+//			UpdateUserTOTPStepFunc: func(ctx context.Context, id int64, step int64) error {
+//				panic("mock out the UpdateUserTOTPStep method")
+//			},
+//			WithTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+//				panic("mock out the WithTx method")
+//			},
 //		}
 //
 //		// use mockedInterface in code that requires Interface
@@ -98,6 +167,9 @@ type InterfaceMock struct {
 	// AccessGarbageCollectorFunc mocks the AccessGarbageCollector method.
 	AccessGarbageCollectorFunc func(ctx context.Context) error
 
+	// AddGroupMemberFunc mocks the AddGroupMember method.
+	AddGroupMemberFunc func(ctx context.Context, member store.GroupMember) error
+
 	// CloseFunc mocks the Close method.
 	CloseFunc func(ctx context.Context) error
 
@@ -107,9 +179,18 @@ type InterfaceMock struct {
 	// CreateGroupFunc mocks the CreateGroup method.
 	CreateGroupFunc func(ctx context.Context, group *store.Group) error
 
+	// CreateRefreshTokenFunc mocks the CreateRefreshToken method.
+	CreateRefreshTokenFunc func(ctx context.Context, token *store.RefreshToken) error
+
 	// CreateRepositoryFunc mocks the CreateRepository method.
 	CreateRepositoryFunc func(ctx context.Context, entry *store.RegistryEntry) error
 
+	// CreateRobotFunc mocks the CreateRobot method.
+	CreateRobotFunc func(ctx context.Context, robot *store.Robot) error
+
+	// CreateRoleFunc mocks the CreateRole method.
+	CreateRoleFunc func(ctx context.Context, role *store.Role) error
+
 	// CreateUserFunc mocks the CreateUser method.
 	CreateUserFunc func(ctx context.Context, user *store.User) error
 
@@ -119,21 +200,48 @@ type InterfaceMock struct {
 	// DeleteGroupFunc mocks the DeleteGroup method.
 	DeleteGroupFunc func(ctx context.Context, id int64) error
 
+	// DeleteGroupMemberFunc mocks the DeleteGroupMember method.
+	DeleteGroupMemberFunc func(ctx context.Context, member store.GroupMember) error
+
+	// DeleteOwnerAccessesFunc mocks the DeleteOwnerAccesses method.
+	DeleteOwnerAccessesFunc func(ctx context.Context, ownerID int64, isGroup bool) error
+
+	// DeleteRefreshTokenFunc mocks the DeleteRefreshToken method.
+	DeleteRefreshTokenFunc func(ctx context.Context, key string, id interface{}) error
+
 	// DeleteRepositoryFunc mocks the DeleteRepository method.
 	DeleteRepositoryFunc func(ctx context.Context, repositoryName string, digest string) error
 
+	// DeleteRobotFunc mocks the DeleteRobot method.
+	DeleteRobotFunc func(ctx context.Context, id int64) error
+
+	// DeleteRoleFunc mocks the DeleteRole method.
+	DeleteRoleFunc func(ctx context.Context, id int64) error
+
 	// DeleteUserFunc mocks the DeleteUser method.
 	DeleteUserFunc func(ctx context.Context, id int64) error
 
 	// FindAccessesFunc mocks the FindAccesses method.
 	FindAccessesFunc func(ctx context.Context, filter QueryFilter) (ListResponse, error)
 
+	// FindGroupMembersFunc mocks the FindGroupMembers method.
+	FindGroupMembersFunc func(ctx context.Context, filter QueryFilter) (ListResponse, error)
+
 	// FindGroupsFunc mocks the FindGroups method.
 	FindGroupsFunc func(ctx context.Context, filter QueryFilter) (ListResponse, error)
 
+	// FindRefreshTokensFunc mocks the FindRefreshTokens method.
+	FindRefreshTokensFunc func(ctx context.Context, filter QueryFilter) (ListResponse, error)
+
 	// FindRepositoriesFunc mocks the FindRepositories method.
 	FindRepositoriesFunc func(ctx context.Context, filter QueryFilter) (ListResponse, error)
 
+	// FindRobotsFunc mocks the FindRobots method.
+	FindRobotsFunc func(ctx context.Context, filter QueryFilter, withSecret bool) (ListResponse, error)
+
+	// FindRolesFunc mocks the FindRoles method.
+	FindRolesFunc func(ctx context.Context, filter QueryFilter) (ListResponse, error)
+
 	// FindUsersFunc mocks the FindUsers method.
 	FindUsersFunc func(ctx context.Context, filter QueryFilter, withPassword bool) (ListResponse, error)
 
@@ -143,12 +251,24 @@ type InterfaceMock struct {
 	// GetGroupFunc mocks the GetGroup method.
 	GetGroupFunc func(ctx context.Context, id int64) (store.Group, error)
 
+	// GetRefreshTokenFunc mocks the GetRefreshToken method.
+	GetRefreshTokenFunc func(ctx context.Context, hash string) (store.RefreshToken, error)
+
 	// GetRepositoryFunc mocks the GetRepository method.
 	GetRepositoryFunc func(ctx context.Context, entryID int64) (store.RegistryEntry, error)
 
+	// GetRobotFunc mocks the GetRobot method.
+	GetRobotFunc func(ctx context.Context, id interface{}) (store.Robot, error)
+
+	// GetRoleFunc mocks the GetRole method.
+	GetRoleFunc func(ctx context.Context, id interface{}) (store.Role, error)
+
 	// GetUserFunc mocks the GetUser method.
 	GetUserFunc func(ctx context.Context, id interface{}) (store.User, error)
 
+	// RefreshTokenGarbageCollectorFunc mocks the RefreshTokenGarbageCollector method.
+	RefreshTokenGarbageCollectorFunc func(ctx context.Context, expiredBefore int64) error
+
 	// RepositoryGarbageCollectorFunc mocks the RepositoryGarbageCollector method.
 	RepositoryGarbageCollectorFunc func(ctx context.Context, syncDate int64) error
 
@@ -158,12 +278,30 @@ type InterfaceMock struct {
 	// UpdateGroupFunc mocks the UpdateGroup method.
 	UpdateGroupFunc func(ctx context.Context, group store.Group) error
 
+	// UpdateRefreshTokenUsageFunc mocks the UpdateRefreshTokenUsage method.
+	UpdateRefreshTokenUsageFunc func(ctx context.Context, id int64, usedAt int64) error
+
 	// UpdateRepositoryFunc mocks the UpdateRepository method.
 	UpdateRepositoryFunc func(ctx context.Context, conditionClause map[string]interface{}, data map[string]interface{}) error
 
+	// UpdateRobotFunc mocks the UpdateRobot method.
+	UpdateRobotFunc func(ctx context.Context, robot store.Robot) error
+
+	// UpdateRoleFunc mocks the UpdateRole method.
+	UpdateRoleFunc func(ctx context.Context, role store.Role) error
+
 	// UpdateUserFunc mocks the UpdateUser method.
 	UpdateUserFunc func(ctx context.Context, user store.User) error
 
+	// UpdateUserTOTPFunc mocks the UpdateUserTOTP method.
+	UpdateUserTOTPFunc func(ctx context.Context, id int64, totp store.TOTP) error
+
+	// UpdateUserTOTPStepFunc mocks the UpdateUserTOTPStep method.
+	UpdateUserTOTPStepFunc func(ctx context.Context, id int64, step int64) error
+
+	// WithTxFunc mocks the WithTx method.
+	WithTxFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// AccessGarbageCollector holds details about calls to the AccessGarbageCollector method.
@@ -171,6 +309,13 @@ type InterfaceMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// AddGroupMember holds details about calls to the AddGroupMember method.
+		AddGroupMember []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Member is the member argument value.
+			Member store.GroupMember
+		}
 		// Close holds details about calls to the Close method.
 		Close []struct {
 			// Ctx is the ctx argument value.
@@ -190,6 +335,13 @@ type InterfaceMock struct {
 			// Group is the group argument value.
 			Group *store.Group
 		}
+		// CreateRefreshToken holds details about calls to the CreateRefreshToken method.
+		CreateRefreshToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Token is the token argument value.
+			Token *store.RefreshToken
+		}
 		// CreateRepository holds details about calls to the CreateRepository method.
 		CreateRepository []struct {
 			// Ctx is the ctx argument value.
@@ -197,6 +349,20 @@ type InterfaceMock struct {
 			// Entry is the entry argument value.
 			Entry *store.RegistryEntry
 		}
+		// CreateRobot holds details about calls to the CreateRobot method.
+		CreateRobot []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Robot is the robot argument value.
+			Robot *store.Robot
+		}
+		// CreateRole holds details about calls to the CreateRole method.
+		CreateRole []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Role is the role argument value.
+			Role *store.Role
+		}
 		// CreateUser holds details about calls to the CreateUser method.
 		CreateUser []struct {
 			// Ctx is the ctx argument value.
@@ -220,6 +386,31 @@ type InterfaceMock struct {
 			// ID is the id argument value.
 			ID int64
 		}
+		// DeleteGroupMember holds details about calls to the DeleteGroupMember method.
+		DeleteGroupMember []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Member is the member argument value.
+			Member store.GroupMember
+		}
+		// DeleteOwnerAccesses holds details about calls to the DeleteOwnerAccesses method.
+		DeleteOwnerAccesses []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// OwnerID is the ownerID argument value.
+			OwnerID int64
+			// IsGroup is the isGroup argument value.
+			IsGroup bool
+		}
+		// DeleteRefreshToken holds details about calls to the DeleteRefreshToken method.
+		DeleteRefreshToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// ID is the id argument value.
+			ID interface{}
+		}
 		// DeleteRepository holds details about calls to the DeleteRepository method.
 		DeleteRepository []struct {
 			// Ctx is the ctx argument value.
@@ -229,6 +420,20 @@ type InterfaceMock struct {
 			// Digest is the digest argument value.
 			Digest string
 		}
+		// DeleteRobot holds details about calls to the DeleteRobot method.
+		DeleteRobot []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID int64
+		}
+		// DeleteRole holds details about calls to the DeleteRole method.
+		DeleteRole []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID int64
+		}
 		// DeleteUser holds details about calls to the DeleteUser method.
 		DeleteUser []struct {
 			// Ctx is the ctx argument value.
@@ -243,6 +448,13 @@ type InterfaceMock struct {
 			// Filter is the filter argument value.
 			Filter QueryFilter
 		}
+		// FindGroupMembers holds details about calls to the FindGroupMembers method.
+		FindGroupMembers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter QueryFilter
+		}
 		// FindGroups holds details about calls to the FindGroups method.
 		FindGroups []struct {
 			// Ctx is the ctx argument value.
@@ -250,6 +462,13 @@ type InterfaceMock struct {
 			// Filter is the filter argument value.
 			Filter QueryFilter
 		}
+		// FindRefreshTokens holds details about calls to the FindRefreshTokens method.
+		FindRefreshTokens []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter QueryFilter
+		}
 		// FindRepositories holds details about calls to the FindRepositories method.
 		FindRepositories []struct {
 			// Ctx is the ctx argument value.
@@ -257,6 +476,22 @@ type InterfaceMock struct {
 			// Filter is the filter argument value.
 			Filter QueryFilter
 		}
+		// FindRobots holds details about calls to the FindRobots method.
+		FindRobots []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter QueryFilter
+			// WithSecret is the withSecret argument value.
+			WithSecret bool
+		}
+		// FindRoles holds details about calls to the FindRoles method.
+		FindRoles []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter QueryFilter
+		}
 		// FindUsers holds details about calls to the FindUsers method.
 		FindUsers []struct {
 			// Ctx is the ctx argument value.
@@ -280,6 +515,13 @@ type InterfaceMock struct {
 			// ID is the id argument value.
 			ID int64
 		}
+		// GetRefreshToken holds details about calls to the GetRefreshToken method.
+		GetRefreshToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Hash is the hash argument value.
+			Hash string
+		}
 		// GetRepository holds details about calls to the GetRepository method.
 		GetRepository []struct {
 			// Ctx is the ctx argument value.
@@ -287,6 +529,20 @@ type InterfaceMock struct {
 			// EntryID is the entryID argument value.
 			EntryID int64
 		}
+		// GetRobot holds details about calls to the GetRobot method.
+		GetRobot []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID interface{}
+		}
+		// GetRole holds details about calls to the GetRole method.
+		GetRole []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID interface{}
+		}
 		// GetUser holds details about calls to the GetUser method.
 		GetUser []struct {
 			// Ctx is the ctx argument value.
@@ -294,6 +550,13 @@ type InterfaceMock struct {
 			// ID is the id argument value.
 			ID interface{}
 		}
+		// RefreshTokenGarbageCollector holds details about calls to the RefreshTokenGarbageCollector method.
+		RefreshTokenGarbageCollector []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ExpiredBefore is the expiredBefore argument value.
+			ExpiredBefore int64
+		}
 		// RepositoryGarbageCollector holds details about calls to the RepositoryGarbageCollector method.
 		RepositoryGarbageCollector []struct {
 			// Ctx is the ctx argument value.
@@ -315,6 +578,15 @@ type InterfaceMock struct {
 			// Group is the group argument value.
 			Group store.Group
 		}
+		// UpdateRefreshTokenUsage holds details about calls to the UpdateRefreshTokenUsage method.
+		UpdateRefreshTokenUsage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID int64
+			// UsedAt is the usedAt argument value.
+			UsedAt int64
+		}
 		// UpdateRepository holds details about calls to the UpdateRepository method.
 		UpdateRepository []struct {
 			// Ctx is the ctx argument value.
@@ -324,6 +596,20 @@ type InterfaceMock struct {
 			// Data is the data argument value.
 			Data map[string]interface{}
 		}
+		// UpdateRobot holds details about calls to the UpdateRobot method.
+		UpdateRobot []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Robot is the robot argument value.
+			Robot store.Robot
+		}
+		// UpdateRole holds details about calls to the UpdateRole method.
+		UpdateRole []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Role is the role argument value.
+			Role store.Role
+		}
 		// UpdateUser holds details about calls to the UpdateUser method.
 		UpdateUser []struct {
 			// Ctx is the ctx argument value.
@@ -331,30 +617,78 @@ type InterfaceMock struct {
 			// User is the user argument value.
 			User store.User
 		}
+		// UpdateUserTOTP holds details about calls to the UpdateUserTOTP method.
+		UpdateUserTOTP []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID int64
+			// Totp is the totp argument value.
+			Totp store.TOTP
+		}
+		// UpdateUserTOTPStep holds details about calls to the UpdateUserTOTPStep method.
+		UpdateUserTOTPStep []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID int64
+			// Step is the step argument value.
+			Step int64
+		}
+		// WithTx holds details about calls to the WithTx method.
+		WithTx []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fn is the fn argument value.
+			Fn func(ctx context.Context) error
+		}
 	}
-	lockAccessGarbageCollector     sync.RWMutex
-	lockClose                      sync.RWMutex
-	lockCreateAccess               sync.RWMutex
-	lockCreateGroup                sync.RWMutex
-	lockCreateRepository           sync.RWMutex
-	lockCreateUser                 sync.RWMutex
-	lockDeleteAccess               sync.RWMutex
-	lockDeleteGroup                sync.RWMutex
-	lockDeleteRepository           sync.RWMutex
-	lockDeleteUser                 sync.RWMutex
-	lockFindAccesses               sync.RWMutex
-	lockFindGroups                 sync.RWMutex
-	lockFindRepositories           sync.RWMutex
-	lockFindUsers                  sync.RWMutex
-	lockGetAccess                  sync.RWMutex
-	lockGetGroup                   sync.RWMutex
-	lockGetRepository              sync.RWMutex
-	lockGetUser                    sync.RWMutex
-	lockRepositoryGarbageCollector sync.RWMutex
-	lockUpdateAccess               sync.RWMutex
-	lockUpdateGroup                sync.RWMutex
-	lockUpdateRepository           sync.RWMutex
-	lockUpdateUser                 sync.RWMutex
+	lockAccessGarbageCollector       sync.RWMutex
+	lockAddGroupMember               sync.RWMutex
+	lockClose                        sync.RWMutex
+	lockCreateAccess                 sync.RWMutex
+	lockCreateGroup                  sync.RWMutex
+	lockCreateRefreshToken           sync.RWMutex
+	lockCreateRepository             sync.RWMutex
+	lockCreateRobot                  sync.RWMutex
+	lockCreateRole                   sync.RWMutex
+	lockCreateUser                   sync.RWMutex
+	lockDeleteAccess                 sync.RWMutex
+	lockDeleteGroup                  sync.RWMutex
+	lockDeleteGroupMember            sync.RWMutex
+	lockDeleteOwnerAccesses          sync.RWMutex
+	lockDeleteRefreshToken           sync.RWMutex
+	lockDeleteRepository             sync.RWMutex
+	lockDeleteRobot                  sync.RWMutex
+	lockDeleteRole                   sync.RWMutex
+	lockDeleteUser                   sync.RWMutex
+	lockFindAccesses                 sync.RWMutex
+	lockFindGroupMembers             sync.RWMutex
+	lockFindGroups                   sync.RWMutex
+	lockFindRefreshTokens            sync.RWMutex
+	lockFindRepositories             sync.RWMutex
+	lockFindRobots                   sync.RWMutex
+	lockFindRoles                    sync.RWMutex
+	lockFindUsers                    sync.RWMutex
+	lockGetAccess                    sync.RWMutex
+	lockGetGroup                     sync.RWMutex
+	lockGetRefreshToken              sync.RWMutex
+	lockGetRepository                sync.RWMutex
+	lockGetRobot                     sync.RWMutex
+	lockGetRole                      sync.RWMutex
+	lockGetUser                      sync.RWMutex
+	lockRefreshTokenGarbageCollector sync.RWMutex
+	lockRepositoryGarbageCollector   sync.RWMutex
+	lockUpdateAccess                 sync.RWMutex
+	lockUpdateGroup                  sync.RWMutex
+	lockUpdateRefreshTokenUsage      sync.RWMutex
+	lockUpdateRepository             sync.RWMutex
+	lockUpdateRobot                  sync.RWMutex
+	lockUpdateRole                   sync.RWMutex
+	lockUpdateUser                   sync.RWMutex
+	lockUpdateUserTOTP               sync.RWMutex
+	lockUpdateUserTOTPStep           sync.RWMutex
+	lockWithTx                       sync.RWMutex
 }
 
 // AccessGarbageCollector calls AccessGarbageCollectorFunc.
@@ -389,6 +723,42 @@ func (mock *InterfaceMock) AccessGarbageCollectorCalls() []struct {
 	return calls
 }
 
+// AddGroupMember calls AddGroupMemberFunc.
+func (mock *InterfaceMock) AddGroupMember(ctx context.Context, member store.GroupMember) error {
+	if mock.AddGroupMemberFunc == nil {
+		panic("InterfaceMock.AddGroupMemberFunc: method is nil but Interface.AddGroupMember was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Member store.GroupMember
+	}{
+		Ctx:    ctx,
+		Member: member,
+	}
+	mock.lockAddGroupMember.Lock()
+	mock.calls.AddGroupMember = append(mock.calls.AddGroupMember, callInfo)
+	mock.lockAddGroupMember.Unlock()
+	return mock.AddGroupMemberFunc(ctx, member)
+}
+
+// AddGroupMemberCalls gets all the calls that were made to AddGroupMember.
+// Check the length with:
+//
+//	len(mockedInterface.AddGroupMemberCalls())
+func (mock *InterfaceMock) AddGroupMemberCalls() []struct {
+	Ctx    context.Context
+	Member store.GroupMember
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Member store.GroupMember
+	}
+	mock.lockAddGroupMember.RLock()
+	calls = mock.calls.AddGroupMember
+	mock.lockAddGroupMember.RUnlock()
+	return calls
+}
+
 // Close calls CloseFunc.
 func (mock *InterfaceMock) Close(ctx context.Context) error {
 	if mock.CloseFunc == nil {
@@ -493,6 +863,42 @@ func (mock *InterfaceMock) CreateGroupCalls() []struct {
 	return calls
 }
 
+// CreateRefreshToken calls CreateRefreshTokenFunc.
+func (mock *InterfaceMock) CreateRefreshToken(ctx context.Context, token *store.RefreshToken) error {
+	if mock.CreateRefreshTokenFunc == nil {
+		panic("InterfaceMock.CreateRefreshTokenFunc: method is nil but Interface.CreateRefreshToken was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Token *store.RefreshToken
+	}{
+		Ctx:   ctx,
+		Token: token,
+	}
+	mock.lockCreateRefreshToken.Lock()
+	mock.calls.CreateRefreshToken = append(mock.calls.CreateRefreshToken, callInfo)
+	mock.lockCreateRefreshToken.Unlock()
+	return mock.CreateRefreshTokenFunc(ctx, token)
+}
+
+// CreateRefreshTokenCalls gets all the calls that were made to CreateRefreshToken.
+// Check the length with:
+//
+//	len(mockedInterface.CreateRefreshTokenCalls())
+func (mock *InterfaceMock) CreateRefreshTokenCalls() []struct {
+	Ctx   context.Context
+	Token *store.RefreshToken
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Token *store.RefreshToken
+	}
+	mock.lockCreateRefreshToken.RLock()
+	calls = mock.calls.CreateRefreshToken
+	mock.lockCreateRefreshToken.RUnlock()
+	return calls
+}
+
 // CreateRepository calls CreateRepositoryFunc.
 func (mock *InterfaceMock) CreateRepository(ctx context.Context, entry *store.RegistryEntry) error {
 	if mock.CreateRepositoryFunc == nil {
@@ -529,6 +935,78 @@ func (mock *InterfaceMock) CreateRepositoryCalls() []struct {
 	return calls
 }
 
+// CreateRobot calls CreateRobotFunc.
+func (mock *InterfaceMock) CreateRobot(ctx context.Context, robot *store.Robot) error {
+	if mock.CreateRobotFunc == nil {
+		panic("InterfaceMock.CreateRobotFunc: method is nil but Interface.CreateRobot was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Robot *store.Robot
+	}{
+		Ctx:   ctx,
+		Robot: robot,
+	}
+	mock.lockCreateRobot.Lock()
+	mock.calls.CreateRobot = append(mock.calls.CreateRobot, callInfo)
+	mock.lockCreateRobot.Unlock()
+	return mock.CreateRobotFunc(ctx, robot)
+}
+
+// CreateRobotCalls gets all the calls that were made to CreateRobot.
+// Check the length with:
+//
+//	len(mockedInterface.CreateRobotCalls())
+func (mock *InterfaceMock) CreateRobotCalls() []struct {
+	Ctx   context.Context
+	Robot *store.Robot
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Robot *store.Robot
+	}
+	mock.lockCreateRobot.RLock()
+	calls = mock.calls.CreateRobot
+	mock.lockCreateRobot.RUnlock()
+	return calls
+}
+
+// CreateRole calls CreateRoleFunc.
+func (mock *InterfaceMock) CreateRole(ctx context.Context, role *store.Role) error {
+	if mock.CreateRoleFunc == nil {
+		panic("InterfaceMock.CreateRoleFunc: method is nil but Interface.CreateRole was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Role *store.Role
+	}{
+		Ctx:  ctx,
+		Role: role,
+	}
+	mock.lockCreateRole.Lock()
+	mock.calls.CreateRole = append(mock.calls.CreateRole, callInfo)
+	mock.lockCreateRole.Unlock()
+	return mock.CreateRoleFunc(ctx, role)
+}
+
+// CreateRoleCalls gets all the calls that were made to CreateRole.
+// Check the length with:
+//
+//	len(mockedInterface.CreateRoleCalls())
+func (mock *InterfaceMock) CreateRoleCalls() []struct {
+	Ctx  context.Context
+	Role *store.Role
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Role *store.Role
+	}
+	mock.lockCreateRole.RLock()
+	calls = mock.calls.CreateRole
+	mock.lockCreateRole.RUnlock()
+	return calls
+}
+
 // CreateUser calls CreateUserFunc.
 func (mock *InterfaceMock) CreateUser(ctx context.Context, user *store.User) error {
 	if mock.CreateUserFunc == nil {
@@ -641,61 +1119,249 @@ func (mock *InterfaceMock) DeleteGroupCalls() []struct {
 	return calls
 }
 
-// DeleteRepository calls DeleteRepositoryFunc.
-func (mock *InterfaceMock) DeleteRepository(ctx context.Context, repositoryName string, digest string) error {
-	if mock.DeleteRepositoryFunc == nil {
-		panic("InterfaceMock.DeleteRepositoryFunc: method is nil but Interface.DeleteRepository was just called")
+// DeleteGroupMember calls DeleteGroupMemberFunc.
+func (mock *InterfaceMock) DeleteGroupMember(ctx context.Context, member store.GroupMember) error {
+	if mock.DeleteGroupMemberFunc == nil {
+		panic("InterfaceMock.DeleteGroupMemberFunc: method is nil but Interface.DeleteGroupMember was just called")
 	}
 	callInfo := struct {
-		Ctx            context.Context
-		RepositoryName string
-		Digest         string
+		Ctx    context.Context
+		Member store.GroupMember
 	}{
-		Ctx:            ctx,
-		RepositoryName: repositoryName,
-		Digest:         digest,
+		Ctx:    ctx,
+		Member: member,
 	}
-	mock.lockDeleteRepository.Lock()
-	mock.calls.DeleteRepository = append(mock.calls.DeleteRepository, callInfo)
-	mock.lockDeleteRepository.Unlock()
-	return mock.DeleteRepositoryFunc(ctx, repositoryName, digest)
+	mock.lockDeleteGroupMember.Lock()
+	mock.calls.DeleteGroupMember = append(mock.calls.DeleteGroupMember, callInfo)
+	mock.lockDeleteGroupMember.Unlock()
+	return mock.DeleteGroupMemberFunc(ctx, member)
 }
 
-// DeleteRepositoryCalls gets all the calls that were made to DeleteRepository.
+// DeleteGroupMemberCalls gets all the calls that were made to DeleteGroupMember.
 // Check the length with:
 //
-//	len(mockedInterface.DeleteRepositoryCalls())
-func (mock *InterfaceMock) DeleteRepositoryCalls() []struct {
-	Ctx            context.Context
-	RepositoryName string
-	Digest         string
+//	len(mockedInterface.DeleteGroupMemberCalls())
+func (mock *InterfaceMock) DeleteGroupMemberCalls() []struct {
+	Ctx    context.Context
+	Member store.GroupMember
 } {
 	var calls []struct {
-		Ctx            context.Context
-		RepositoryName string
-		Digest         string
+		Ctx    context.Context
+		Member store.GroupMember
 	}
-	mock.lockDeleteRepository.RLock()
-	calls = mock.calls.DeleteRepository
-	mock.lockDeleteRepository.RUnlock()
+	mock.lockDeleteGroupMember.RLock()
+	calls = mock.calls.DeleteGroupMember
+	mock.lockDeleteGroupMember.RUnlock()
 	return calls
 }
 
-// DeleteUser calls DeleteUserFunc.
-func (mock *InterfaceMock) DeleteUser(ctx context.Context, id int64) error {
-	if mock.DeleteUserFunc == nil {
-		panic("InterfaceMock.DeleteUserFunc: method is nil but Interface.DeleteUser was just called")
+// DeleteOwnerAccesses calls DeleteOwnerAccessesFunc.
+func (mock *InterfaceMock) DeleteOwnerAccesses(ctx context.Context, ownerID int64, isGroup bool) error {
+	if mock.DeleteOwnerAccessesFunc == nil {
+		panic("InterfaceMock.DeleteOwnerAccessesFunc: method is nil but Interface.DeleteOwnerAccesses was just called")
 	}
 	callInfo := struct {
-		Ctx context.Context
-		ID  int64
+		Ctx     context.Context
+		OwnerID int64
+		IsGroup bool
 	}{
-		Ctx: ctx,
-		ID:  id,
+		Ctx:     ctx,
+		OwnerID: ownerID,
+		IsGroup: isGroup,
 	}
-	mock.lockDeleteUser.Lock()
-	mock.calls.DeleteUser = append(mock.calls.DeleteUser, callInfo)
-	mock.lockDeleteUser.Unlock()
+	mock.lockDeleteOwnerAccesses.Lock()
+	mock.calls.DeleteOwnerAccesses = append(mock.calls.DeleteOwnerAccesses, callInfo)
+	mock.lockDeleteOwnerAccesses.Unlock()
+	return mock.DeleteOwnerAccessesFunc(ctx, ownerID, isGroup)
+}
+
+// DeleteOwnerAccessesCalls gets all the calls that were made to DeleteOwnerAccesses.
+// Check the length with:
+//
+//	len(mockedInterface.DeleteOwnerAccessesCalls())
+func (mock *InterfaceMock) DeleteOwnerAccessesCalls() []struct {
+	Ctx     context.Context
+	OwnerID int64
+	IsGroup bool
+} {
+	var calls []struct {
+		Ctx     context.Context
+		OwnerID int64
+		IsGroup bool
+	}
+	mock.lockDeleteOwnerAccesses.RLock()
+	calls = mock.calls.DeleteOwnerAccesses
+	mock.lockDeleteOwnerAccesses.RUnlock()
+	return calls
+}
+
+// DeleteRefreshToken calls DeleteRefreshTokenFunc.
+func (mock *InterfaceMock) DeleteRefreshToken(ctx context.Context, key string, id interface{}) error {
+	if mock.DeleteRefreshTokenFunc == nil {
+		panic("InterfaceMock.DeleteRefreshTokenFunc: method is nil but Interface.DeleteRefreshToken was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+		ID  interface{}
+	}{
+		Ctx: ctx,
+		Key: key,
+		ID:  id,
+	}
+	mock.lockDeleteRefreshToken.Lock()
+	mock.calls.DeleteRefreshToken = append(mock.calls.DeleteRefreshToken, callInfo)
+	mock.lockDeleteRefreshToken.Unlock()
+	return mock.DeleteRefreshTokenFunc(ctx, key, id)
+}
+
+// DeleteRefreshTokenCalls gets all the calls that were made to DeleteRefreshToken.
+// Check the length with:
+//
+//	len(mockedInterface.DeleteRefreshTokenCalls())
+func (mock *InterfaceMock) DeleteRefreshTokenCalls() []struct {
+	Ctx context.Context
+	Key string
+	ID  interface{}
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+		ID  interface{}
+	}
+	mock.lockDeleteRefreshToken.RLock()
+	calls = mock.calls.DeleteRefreshToken
+	mock.lockDeleteRefreshToken.RUnlock()
+	return calls
+}
+
+// DeleteRepository calls DeleteRepositoryFunc.
+func (mock *InterfaceMock) DeleteRepository(ctx context.Context, repositoryName string, digest string) error {
+	if mock.DeleteRepositoryFunc == nil {
+		panic("InterfaceMock.DeleteRepositoryFunc: method is nil but Interface.DeleteRepository was just called")
+	}
+	callInfo := struct {
+		Ctx            context.Context
+		RepositoryName string
+		Digest         string
+	}{
+		Ctx:            ctx,
+		RepositoryName: repositoryName,
+		Digest:         digest,
+	}
+	mock.lockDeleteRepository.Lock()
+	mock.calls.DeleteRepository = append(mock.calls.DeleteRepository, callInfo)
+	mock.lockDeleteRepository.Unlock()
+	return mock.DeleteRepositoryFunc(ctx, repositoryName, digest)
+}
+
+// DeleteRepositoryCalls gets all the calls that were made to DeleteRepository.
+// Check the length with:
+//
+//	len(mockedInterface.DeleteRepositoryCalls())
+func (mock *InterfaceMock) DeleteRepositoryCalls() []struct {
+	Ctx            context.Context
+	RepositoryName string
+	Digest         string
+} {
+	var calls []struct {
+		Ctx            context.Context
+		RepositoryName string
+		Digest         string
+	}
+	mock.lockDeleteRepository.RLock()
+	calls = mock.calls.DeleteRepository
+	mock.lockDeleteRepository.RUnlock()
+	return calls
+}
+
+// DeleteRobot calls DeleteRobotFunc.
+func (mock *InterfaceMock) DeleteRobot(ctx context.Context, id int64) error {
+	if mock.DeleteRobotFunc == nil {
+		panic("InterfaceMock.DeleteRobotFunc: method is nil but Interface.DeleteRobot was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  int64
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockDeleteRobot.Lock()
+	mock.calls.DeleteRobot = append(mock.calls.DeleteRobot, callInfo)
+	mock.lockDeleteRobot.Unlock()
+	return mock.DeleteRobotFunc(ctx, id)
+}
+
+// DeleteRobotCalls gets all the calls that were made to DeleteRobot.
+// Check the length with:
+//
+//	len(mockedInterface.DeleteRobotCalls())
+func (mock *InterfaceMock) DeleteRobotCalls() []struct {
+	Ctx context.Context
+	ID  int64
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  int64
+	}
+	mock.lockDeleteRobot.RLock()
+	calls = mock.calls.DeleteRobot
+	mock.lockDeleteRobot.RUnlock()
+	return calls
+}
+
+// DeleteRole calls DeleteRoleFunc.
+func (mock *InterfaceMock) DeleteRole(ctx context.Context, id int64) error {
+	if mock.DeleteRoleFunc == nil {
+		panic("InterfaceMock.DeleteRoleFunc: method is nil but Interface.DeleteRole was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  int64
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockDeleteRole.Lock()
+	mock.calls.DeleteRole = append(mock.calls.DeleteRole, callInfo)
+	mock.lockDeleteRole.Unlock()
+	return mock.DeleteRoleFunc(ctx, id)
+}
+
+// DeleteRoleCalls gets all the calls that were made to DeleteRole.
+// Check the length with:
+//
+//	len(mockedInterface.DeleteRoleCalls())
+func (mock *InterfaceMock) DeleteRoleCalls() []struct {
+	Ctx context.Context
+	ID  int64
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  int64
+	}
+	mock.lockDeleteRole.RLock()
+	calls = mock.calls.DeleteRole
+	mock.lockDeleteRole.RUnlock()
+	return calls
+}
+
+// DeleteUser calls DeleteUserFunc.
+func (mock *InterfaceMock) DeleteUser(ctx context.Context, id int64) error {
+	if mock.DeleteUserFunc == nil {
+		panic("InterfaceMock.DeleteUserFunc: method is nil but Interface.DeleteUser was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  int64
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockDeleteUser.Lock()
+	mock.calls.DeleteUser = append(mock.calls.DeleteUser, callInfo)
+	mock.lockDeleteUser.Unlock()
 	return mock.DeleteUserFunc(ctx, id)
 }
 
@@ -753,6 +1419,42 @@ func (mock *InterfaceMock) FindAccessesCalls() []struct {
 	return calls
 }
 
+// FindGroupMembers calls FindGroupMembersFunc.
+func (mock *InterfaceMock) FindGroupMembers(ctx context.Context, filter QueryFilter) (ListResponse, error) {
+	if mock.FindGroupMembersFunc == nil {
+		panic("InterfaceMock.FindGroupMembersFunc: method is nil but Interface.FindGroupMembers was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter QueryFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockFindGroupMembers.Lock()
+	mock.calls.FindGroupMembers = append(mock.calls.FindGroupMembers, callInfo)
+	mock.lockFindGroupMembers.Unlock()
+	return mock.FindGroupMembersFunc(ctx, filter)
+}
+
+// FindGroupMembersCalls gets all the calls that were made to FindGroupMembers.
+// Check the length with:
+//
+//	len(mockedInterface.FindGroupMembersCalls())
+func (mock *InterfaceMock) FindGroupMembersCalls() []struct {
+	Ctx    context.Context
+	Filter QueryFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter QueryFilter
+	}
+	mock.lockFindGroupMembers.RLock()
+	calls = mock.calls.FindGroupMembers
+	mock.lockFindGroupMembers.RUnlock()
+	return calls
+}
+
 // FindGroups calls FindGroupsFunc.
 func (mock *InterfaceMock) FindGroups(ctx context.Context, filter QueryFilter) (ListResponse, error) {
 	if mock.FindGroupsFunc == nil {
@@ -789,6 +1491,42 @@ func (mock *InterfaceMock) FindGroupsCalls() []struct {
 	return calls
 }
 
+// FindRefreshTokens calls FindRefreshTokensFunc.
+func (mock *InterfaceMock) FindRefreshTokens(ctx context.Context, filter QueryFilter) (ListResponse, error) {
+	if mock.FindRefreshTokensFunc == nil {
+		panic("InterfaceMock.FindRefreshTokensFunc: method is nil but Interface.FindRefreshTokens was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter QueryFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockFindRefreshTokens.Lock()
+	mock.calls.FindRefreshTokens = append(mock.calls.FindRefreshTokens, callInfo)
+	mock.lockFindRefreshTokens.Unlock()
+	return mock.FindRefreshTokensFunc(ctx, filter)
+}
+
+// FindRefreshTokensCalls gets all the calls that were made to FindRefreshTokens.
+// Check the length with:
+//
+//	len(mockedInterface.FindRefreshTokensCalls())
+func (mock *InterfaceMock) FindRefreshTokensCalls() []struct {
+	Ctx    context.Context
+	Filter QueryFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter QueryFilter
+	}
+	mock.lockFindRefreshTokens.RLock()
+	calls = mock.calls.FindRefreshTokens
+	mock.lockFindRefreshTokens.RUnlock()
+	return calls
+}
+
 // FindRepositories calls FindRepositoriesFunc.
 func (mock *InterfaceMock) FindRepositories(ctx context.Context, filter QueryFilter) (ListResponse, error) {
 	if mock.FindRepositoriesFunc == nil {
@@ -825,6 +1563,82 @@ func (mock *InterfaceMock) FindRepositoriesCalls() []struct {
 	return calls
 }
 
+// FindRobots calls FindRobotsFunc.
+func (mock *InterfaceMock) FindRobots(ctx context.Context, filter QueryFilter, withSecret bool) (ListResponse, error) {
+	if mock.FindRobotsFunc == nil {
+		panic("InterfaceMock.FindRobotsFunc: method is nil but Interface.FindRobots was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		Filter     QueryFilter
+		WithSecret bool
+	}{
+		Ctx:        ctx,
+		Filter:     filter,
+		WithSecret: withSecret,
+	}
+	mock.lockFindRobots.Lock()
+	mock.calls.FindRobots = append(mock.calls.FindRobots, callInfo)
+	mock.lockFindRobots.Unlock()
+	return mock.FindRobotsFunc(ctx, filter, withSecret)
+}
+
+// FindRobotsCalls gets all the calls that were made to FindRobots.
+// Check the length with:
+//
+//	len(mockedInterface.FindRobotsCalls())
+func (mock *InterfaceMock) FindRobotsCalls() []struct {
+	Ctx        context.Context
+	Filter     QueryFilter
+	WithSecret bool
+} {
+	var calls []struct {
+		Ctx        context.Context
+		Filter     QueryFilter
+		WithSecret bool
+	}
+	mock.lockFindRobots.RLock()
+	calls = mock.calls.FindRobots
+	mock.lockFindRobots.RUnlock()
+	return calls
+}
+
+// FindRoles calls FindRolesFunc.
+func (mock *InterfaceMock) FindRoles(ctx context.Context, filter QueryFilter) (ListResponse, error) {
+	if mock.FindRolesFunc == nil {
+		panic("InterfaceMock.FindRolesFunc: method is nil but Interface.FindRoles was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter QueryFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockFindRoles.Lock()
+	mock.calls.FindRoles = append(mock.calls.FindRoles, callInfo)
+	mock.lockFindRoles.Unlock()
+	return mock.FindRolesFunc(ctx, filter)
+}
+
+// FindRolesCalls gets all the calls that were made to FindRoles.
+// Check the length with:
+//
+//	len(mockedInterface.FindRolesCalls())
+func (mock *InterfaceMock) FindRolesCalls() []struct {
+	Ctx    context.Context
+	Filter QueryFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter QueryFilter
+	}
+	mock.lockFindRoles.RLock()
+	calls = mock.calls.FindRoles
+	mock.lockFindRoles.RUnlock()
+	return calls
+}
+
 // FindUsers calls FindUsersFunc.
 func (mock *InterfaceMock) FindUsers(ctx context.Context, filter QueryFilter, withPassword bool) (ListResponse, error) {
 	if mock.FindUsersFunc == nil {
@@ -937,6 +1751,42 @@ func (mock *InterfaceMock) GetGroupCalls() []struct {
 	return calls
 }
 
+// GetRefreshToken calls GetRefreshTokenFunc.
+func (mock *InterfaceMock) GetRefreshToken(ctx context.Context, hash string) (store.RefreshToken, error) {
+	if mock.GetRefreshTokenFunc == nil {
+		panic("InterfaceMock.GetRefreshTokenFunc: method is nil but Interface.GetRefreshToken was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Hash string
+	}{
+		Ctx:  ctx,
+		Hash: hash,
+	}
+	mock.lockGetRefreshToken.Lock()
+	mock.calls.GetRefreshToken = append(mock.calls.GetRefreshToken, callInfo)
+	mock.lockGetRefreshToken.Unlock()
+	return mock.GetRefreshTokenFunc(ctx, hash)
+}
+
+// GetRefreshTokenCalls gets all the calls that were made to GetRefreshToken.
+// Check the length with:
+//
+//	len(mockedInterface.GetRefreshTokenCalls())
+func (mock *InterfaceMock) GetRefreshTokenCalls() []struct {
+	Ctx  context.Context
+	Hash string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Hash string
+	}
+	mock.lockGetRefreshToken.RLock()
+	calls = mock.calls.GetRefreshToken
+	mock.lockGetRefreshToken.RUnlock()
+	return calls
+}
+
 // GetRepository calls GetRepositoryFunc.
 func (mock *InterfaceMock) GetRepository(ctx context.Context, entryID int64) (store.RegistryEntry, error) {
 	if mock.GetRepositoryFunc == nil {
@@ -973,6 +1823,78 @@ func (mock *InterfaceMock) GetRepositoryCalls() []struct {
 	return calls
 }
 
+// GetRobot calls GetRobotFunc.
+func (mock *InterfaceMock) GetRobot(ctx context.Context, id interface{}) (store.Robot, error) {
+	if mock.GetRobotFunc == nil {
+		panic("InterfaceMock.GetRobotFunc: method is nil but Interface.GetRobot was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  interface{}
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetRobot.Lock()
+	mock.calls.GetRobot = append(mock.calls.GetRobot, callInfo)
+	mock.lockGetRobot.Unlock()
+	return mock.GetRobotFunc(ctx, id)
+}
+
+// GetRobotCalls gets all the calls that were made to GetRobot.
+// Check the length with:
+//
+//	len(mockedInterface.GetRobotCalls())
+func (mock *InterfaceMock) GetRobotCalls() []struct {
+	Ctx context.Context
+	ID  interface{}
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  interface{}
+	}
+	mock.lockGetRobot.RLock()
+	calls = mock.calls.GetRobot
+	mock.lockGetRobot.RUnlock()
+	return calls
+}
+
+// GetRole calls GetRoleFunc.
+func (mock *InterfaceMock) GetRole(ctx context.Context, id interface{}) (store.Role, error) {
+	if mock.GetRoleFunc == nil {
+		panic("InterfaceMock.GetRoleFunc: method is nil but Interface.GetRole was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  interface{}
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetRole.Lock()
+	mock.calls.GetRole = append(mock.calls.GetRole, callInfo)
+	mock.lockGetRole.Unlock()
+	return mock.GetRoleFunc(ctx, id)
+}
+
+// GetRoleCalls gets all the calls that were made to GetRole.
+// Check the length with:
+//
+//	len(mockedInterface.GetRoleCalls())
+func (mock *InterfaceMock) GetRoleCalls() []struct {
+	Ctx context.Context
+	ID  interface{}
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  interface{}
+	}
+	mock.lockGetRole.RLock()
+	calls = mock.calls.GetRole
+	mock.lockGetRole.RUnlock()
+	return calls
+}
+
 // GetUser calls GetUserFunc.
 func (mock *InterfaceMock) GetUser(ctx context.Context, id interface{}) (store.User, error) {
 	if mock.GetUserFunc == nil {
@@ -1009,6 +1931,42 @@ func (mock *InterfaceMock) GetUserCalls() []struct {
 	return calls
 }
 
+// RefreshTokenGarbageCollector calls RefreshTokenGarbageCollectorFunc.
+func (mock *InterfaceMock) RefreshTokenGarbageCollector(ctx context.Context, expiredBefore int64) error {
+	if mock.RefreshTokenGarbageCollectorFunc == nil {
+		panic("InterfaceMock.RefreshTokenGarbageCollectorFunc: method is nil but Interface.RefreshTokenGarbageCollector was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		ExpiredBefore int64
+	}{
+		Ctx:           ctx,
+		ExpiredBefore: expiredBefore,
+	}
+	mock.lockRefreshTokenGarbageCollector.Lock()
+	mock.calls.RefreshTokenGarbageCollector = append(mock.calls.RefreshTokenGarbageCollector, callInfo)
+	mock.lockRefreshTokenGarbageCollector.Unlock()
+	return mock.RefreshTokenGarbageCollectorFunc(ctx, expiredBefore)
+}
+
+// RefreshTokenGarbageCollectorCalls gets all the calls that were made to RefreshTokenGarbageCollector.
+// Check the length with:
+//
+//	len(mockedInterface.RefreshTokenGarbageCollectorCalls())
+func (mock *InterfaceMock) RefreshTokenGarbageCollectorCalls() []struct {
+	Ctx           context.Context
+	ExpiredBefore int64
+} {
+	var calls []struct {
+		Ctx           context.Context
+		ExpiredBefore int64
+	}
+	mock.lockRefreshTokenGarbageCollector.RLock()
+	calls = mock.calls.RefreshTokenGarbageCollector
+	mock.lockRefreshTokenGarbageCollector.RUnlock()
+	return calls
+}
+
 // RepositoryGarbageCollector calls RepositoryGarbageCollectorFunc.
 func (mock *InterfaceMock) RepositoryGarbageCollector(ctx context.Context, syncDate int64) error {
 	if mock.RepositoryGarbageCollectorFunc == nil {
@@ -1117,6 +2075,46 @@ func (mock *InterfaceMock) UpdateGroupCalls() []struct {
 	return calls
 }
 
+// UpdateRefreshTokenUsage calls UpdateRefreshTokenUsageFunc.
+func (mock *InterfaceMock) UpdateRefreshTokenUsage(ctx context.Context, id int64, usedAt int64) error {
+	if mock.UpdateRefreshTokenUsageFunc == nil {
+		panic("InterfaceMock.UpdateRefreshTokenUsageFunc: method is nil but Interface.UpdateRefreshTokenUsage was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		ID     int64
+		UsedAt int64
+	}{
+		Ctx:    ctx,
+		ID:     id,
+		UsedAt: usedAt,
+	}
+	mock.lockUpdateRefreshTokenUsage.Lock()
+	mock.calls.UpdateRefreshTokenUsage = append(mock.calls.UpdateRefreshTokenUsage, callInfo)
+	mock.lockUpdateRefreshTokenUsage.Unlock()
+	return mock.UpdateRefreshTokenUsageFunc(ctx, id, usedAt)
+}
+
+// UpdateRefreshTokenUsageCalls gets all the calls that were made to UpdateRefreshTokenUsage.
+// Check the length with:
+//
+//	len(mockedInterface.UpdateRefreshTokenUsageCalls())
+func (mock *InterfaceMock) UpdateRefreshTokenUsageCalls() []struct {
+	Ctx    context.Context
+	ID     int64
+	UsedAt int64
+} {
+	var calls []struct {
+		Ctx    context.Context
+		ID     int64
+		UsedAt int64
+	}
+	mock.lockUpdateRefreshTokenUsage.RLock()
+	calls = mock.calls.UpdateRefreshTokenUsage
+	mock.lockUpdateRefreshTokenUsage.RUnlock()
+	return calls
+}
+
 // UpdateRepository calls UpdateRepositoryFunc.
 func (mock *InterfaceMock) UpdateRepository(ctx context.Context, conditionClause map[string]interface{}, data map[string]interface{}) error {
 	if mock.UpdateRepositoryFunc == nil {
@@ -1157,6 +2155,78 @@ func (mock *InterfaceMock) UpdateRepositoryCalls() []struct {
 	return calls
 }
 
+// UpdateRobot calls UpdateRobotFunc.
+func (mock *InterfaceMock) UpdateRobot(ctx context.Context, robot store.Robot) error {
+	if mock.UpdateRobotFunc == nil {
+		panic("InterfaceMock.UpdateRobotFunc: method is nil but Interface.UpdateRobot was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Robot store.Robot
+	}{
+		Ctx:   ctx,
+		Robot: robot,
+	}
+	mock.lockUpdateRobot.Lock()
+	mock.calls.UpdateRobot = append(mock.calls.UpdateRobot, callInfo)
+	mock.lockUpdateRobot.Unlock()
+	return mock.UpdateRobotFunc(ctx, robot)
+}
+
+// UpdateRobotCalls gets all the calls that were made to UpdateRobot.
+// Check the length with:
+//
+//	len(mockedInterface.UpdateRobotCalls())
+func (mock *InterfaceMock) UpdateRobotCalls() []struct {
+	Ctx   context.Context
+	Robot store.Robot
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Robot store.Robot
+	}
+	mock.lockUpdateRobot.RLock()
+	calls = mock.calls.UpdateRobot
+	mock.lockUpdateRobot.RUnlock()
+	return calls
+}
+
+// UpdateRole calls UpdateRoleFunc.
+func (mock *InterfaceMock) UpdateRole(ctx context.Context, role store.Role) error {
+	if mock.UpdateRoleFunc == nil {
+		panic("InterfaceMock.UpdateRoleFunc: method is nil but Interface.UpdateRole was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Role store.Role
+	}{
+		Ctx:  ctx,
+		Role: role,
+	}
+	mock.lockUpdateRole.Lock()
+	mock.calls.UpdateRole = append(mock.calls.UpdateRole, callInfo)
+	mock.lockUpdateRole.Unlock()
+	return mock.UpdateRoleFunc(ctx, role)
+}
+
+// UpdateRoleCalls gets all the calls that were made to UpdateRole.
+// Check the length with:
+//
+//	len(mockedInterface.UpdateRoleCalls())
+func (mock *InterfaceMock) UpdateRoleCalls() []struct {
+	Ctx  context.Context
+	Role store.Role
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Role store.Role
+	}
+	mock.lockUpdateRole.RLock()
+	calls = mock.calls.UpdateRole
+	mock.lockUpdateRole.RUnlock()
+	return calls
+}
+
 // UpdateUser calls UpdateUserFunc.
 func (mock *InterfaceMock) UpdateUser(ctx context.Context, user store.User) error {
 	if mock.UpdateUserFunc == nil {
@@ -1192,3 +2262,119 @@ func (mock *InterfaceMock) UpdateUserCalls() []struct {
 	mock.lockUpdateUser.RUnlock()
 	return calls
 }
+
+// UpdateUserTOTP calls UpdateUserTOTPFunc.
+func (mock *InterfaceMock) UpdateUserTOTP(ctx context.Context, id int64, totp store.TOTP) error {
+	if mock.UpdateUserTOTPFunc == nil {
+		panic("InterfaceMock.UpdateUserTOTPFunc: method is nil but Interface.UpdateUserTOTP was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		ID   int64
+		Totp store.TOTP
+	}{
+		Ctx:  ctx,
+		ID:   id,
+		Totp: totp,
+	}
+	mock.lockUpdateUserTOTP.Lock()
+	mock.calls.UpdateUserTOTP = append(mock.calls.UpdateUserTOTP, callInfo)
+	mock.lockUpdateUserTOTP.Unlock()
+	return mock.UpdateUserTOTPFunc(ctx, id, totp)
+}
+
+// UpdateUserTOTPCalls gets all the calls that were made to UpdateUserTOTP.
+// Check the length with:
+//
+//	len(mockedInterface.UpdateUserTOTPCalls())
+func (mock *InterfaceMock) UpdateUserTOTPCalls() []struct {
+	Ctx  context.Context
+	ID   int64
+	Totp store.TOTP
+} {
+	var calls []struct {
+		Ctx  context.Context
+		ID   int64
+		Totp store.TOTP
+	}
+	mock.lockUpdateUserTOTP.RLock()
+	calls = mock.calls.UpdateUserTOTP
+	mock.lockUpdateUserTOTP.RUnlock()
+	return calls
+}
+
+// UpdateUserTOTPStep calls UpdateUserTOTPStepFunc.
+func (mock *InterfaceMock) UpdateUserTOTPStep(ctx context.Context, id int64, step int64) error {
+	if mock.UpdateUserTOTPStepFunc == nil {
+		panic("InterfaceMock.UpdateUserTOTPStepFunc: method is nil but Interface.UpdateUserTOTPStep was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		ID   int64
+		Step int64
+	}{
+		Ctx:  ctx,
+		ID:   id,
+		Step: step,
+	}
+	mock.lockUpdateUserTOTPStep.Lock()
+	mock.calls.UpdateUserTOTPStep = append(mock.calls.UpdateUserTOTPStep, callInfo)
+	mock.lockUpdateUserTOTPStep.Unlock()
+	return mock.UpdateUserTOTPStepFunc(ctx, id, step)
+}
+
+// UpdateUserTOTPStepCalls gets all the calls that were made to UpdateUserTOTPStep.
+// Check the length with:
+//
+//	len(mockedInterface.UpdateUserTOTPStepCalls())
+func (mock *InterfaceMock) UpdateUserTOTPStepCalls() []struct {
+	Ctx  context.Context
+	ID   int64
+	Step int64
+} {
+	var calls []struct {
+		Ctx  context.Context
+		ID   int64
+		Step int64
+	}
+	mock.lockUpdateUserTOTPStep.RLock()
+	calls = mock.calls.UpdateUserTOTPStep
+	mock.lockUpdateUserTOTPStep.RUnlock()
+	return calls
+}
+
+// WithTx calls WithTxFunc.
+func (mock *InterfaceMock) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mock.WithTxFunc == nil {
+		panic("InterfaceMock.WithTxFunc: method is nil but Interface.WithTx was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Fn  func(ctx context.Context) error
+	}{
+		Ctx: ctx,
+		Fn:  fn,
+	}
+	mock.lockWithTx.Lock()
+	mock.calls.WithTx = append(mock.calls.WithTx, callInfo)
+	mock.lockWithTx.Unlock()
+	return mock.WithTxFunc(ctx, fn)
+}
+
+// WithTxCalls gets all the calls that were made to WithTx.
+// Check the length with:
+//
+//	len(mockedInterface.WithTxCalls())
+func (mock *InterfaceMock) WithTxCalls() []struct {
+	Ctx context.Context
+	Fn  func(ctx context.Context) error
+} {
+	var calls []struct {
+		Ctx context.Context
+		Fn  func(ctx context.Context) error
+	}
+	mock.lockWithTx.RLock()
+	calls = mock.calls.WithTx
+	mock.lockWithTx.RUnlock()
+	return calls
+}
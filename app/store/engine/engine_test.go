@@ -2,8 +2,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
 	"net/url"
 	"testing"
 )
@@ -60,3 +62,19 @@ func TestSetGetAdminDefaultPassword(t *testing.T) {
 	p = GetAdminDefaultPassword(ctx)
 	assert.Equal(t, "", p)
 }
+
+func TestCheckRoleExists(t *testing.T) {
+	s := &InterfaceMock{GetRoleFunc: func(_ context.Context, id interface{}) (store.Role, error) {
+		switch id {
+		case "ci-operator":
+			return store.Role{Name: "ci-operator"}, nil
+		case "broken":
+			return store.Role{}, errors.New("connection lost")
+		}
+		return store.Role{}, ErrNotFound
+	}}
+
+	assert.NoError(t, CheckRoleExists(context.Background(), s, "ci-operator"))
+	assert.EqualError(t, CheckRoleExists(context.Background(), s, "root"), `role "root" not found`)
+	assert.Error(t, CheckRoleExists(context.Background(), s, "broken"))
+}
@@ -0,0 +1,210 @@
+package enginetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func testAccesses(ctx context.Context, t *testing.T, s engine.Interface) {
+	access := store.Access{Name: "foo pull", Owner: 1, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, s.CreateAccess(ctx, &access))
+	assert.Greater(t, access.ID, int64(0))
+	assert.Equal(t, store.AccessEffectAllow, access.Effect, "access without effect allows the action")
+
+	result, err := s.GetAccess(ctx, access.ID)
+	require.NoError(t, err)
+	assert.Equal(t, access, result)
+
+	_, err = s.GetAccess(ctx, -1)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	// owner, owner kind, type, resource, action and effect are unique together
+	duplicate := access
+	duplicate.Name = "duplicate"
+	assert.ErrorIs(t, s.CreateAccess(ctx, &duplicate), engine.ErrDuplicateEntry)
+
+	// a rule of a group doesn't conflict with a rule of the user which has the same id as the group
+	groupAccess := access
+	groupAccess.ID, groupAccess.Name, groupAccess.IsGroup = 0, "group foo pull", true
+	require.NoError(t, s.CreateAccess(ctx, &groupAccess))
+	result, err = s.GetAccess(ctx, access.ID)
+	require.NoError(t, err)
+	assert.Equal(t, access, result, "a rule of the user is kept")
+	require.NoError(t, s.DeleteAccess(ctx, "id", groupAccess.ID))
+
+	// a deny rule doesn't conflict with an allow rule of the same owner, resource and action
+	denyAccess := access
+	denyAccess.ID, denyAccess.Name, denyAccess.Effect = 0, "deny foo pull", store.AccessEffectDeny
+	require.NoError(t, s.CreateAccess(ctx, &denyAccess))
+	require.NoError(t, s.DeleteAccess(ctx, "id", denyAccess.ID))
+
+	assert.Error(t, s.CreateAccess(ctx, &store.Access{Owner: 1, Type: "repository", ResourceName: "foo/bar", Action: "push"}))
+	assert.Error(t, s.CreateAccess(ctx, &store.Access{Name: "without action", Owner: 1, Type: "repository", ResourceName: "foo/bar"}))
+
+	access.Name = "foo push"
+	access.Action = "push"
+	access.IsGroup = true
+	access.Disabled = true
+	access.NotBefore = time.Now().Unix()
+	access.ExpiresAt = access.NotBefore + 3600
+	access.Effect = store.AccessEffectDeny
+	require.NoError(t, s.UpdateAccess(ctx, access))
+	result, err = s.GetAccess(ctx, access.ID)
+	require.NoError(t, err)
+	assert.Equal(t, access, result)
+
+	assertInvalidKey(t, s.DeleteAccess(ctx, "1=1 OR id", access.ID))
+
+	require.NoError(t, s.DeleteAccess(ctx, "id", access.ID))
+	_, err = s.GetAccess(ctx, access.ID)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteAccess(ctx, "id", access.ID), engine.ErrNotFound)
+
+	// delete all accesses of an owner
+	for _, action := range []string{"pull", "push", "delete"} {
+		require.NoError(t, s.CreateAccess(ctx, &store.Access{Name: action, Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: action}))
+	}
+	require.NoError(t, s.CreateAccess(ctx, &store.Access{Name: "other", Owner: 3, Type: "repository", ResourceName: "foo/bar", Action: "pull"}))
+	require.NoError(t, s.DeleteAccess(ctx, "owner_id", int64(2)))
+	assertTotal(t, 1, func() (engine.ListResponse, error) { return s.FindAccesses(ctx, engine.QueryFilter{}) })
+}
+
+func testFindAccesses(ctx context.Context, t *testing.T, s engine.Interface) {
+	accesses := []store.Access{
+		{Name: "user pull", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+		{Name: "user push", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "push", Disabled: true},
+		{Name: "group pull", Owner: 2, IsGroup: true, Type: "repository", ResourceName: "foo/baz", Action: "pull"},
+		{Name: "anonymous pull", Owner: engine.AnonymousUserID, Type: "repository", ResourceName: "public/image", Action: "pull"},
+	}
+	for i := range accesses {
+		require.NoError(t, s.CreateAccess(ctx, &accesses[i]))
+	}
+
+	// values of filters come from JSON, so numbers and booleans have float64 type
+	result, err := s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{
+		"owner_id": float64(2), "is_group": float64(0), "disabled": false,
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, accesses[0], result.Data[0].(store.Access))
+
+	// a list of values matches any of them
+	assertTotal(t, 2, func() (engine.ListResponse, error) {
+		return s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{
+			"owner_id": float64(2), "is_group": false, "action": []string{"pull", "push"},
+		}})
+	})
+
+	result, err = s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"owner_id": engine.AnonymousUserID}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, accesses[3], result.Data[0].(store.Access))
+
+	// full text search by name and resource name
+	assertTotal(t, 2, func() (engine.ListResponse, error) {
+		return s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "foo/bar"}})
+	})
+	result, err = s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "pull"}, Range: [2]int64{0, 1}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	assert.Len(t, result.Data, 1)
+
+	result, err = s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"owner_id": float64(100)}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Total)
+	assert.Empty(t, result.Data)
+
+	_, err = s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR owner_id": float64(2)}})
+	assertInvalidKey(t, err)
+}
+
+func testDeleteOwnerAccesses(ctx context.Context, t *testing.T, s engine.Interface) {
+	userAccess := store.Access{Name: "user", Owner: 7, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, s.CreateAccess(ctx, &userAccess))
+	groupAccess := store.Access{Name: "group", Owner: 7, IsGroup: true, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, s.CreateAccess(ctx, &groupAccess))
+
+	// rules of a group are kept when a user with the same id is deleted
+	require.NoError(t, s.DeleteOwnerAccesses(ctx, 7, false))
+	result, err := s.FindAccesses(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, groupAccess, result.Data[0].(store.Access))
+
+	require.NoError(t, s.DeleteOwnerAccesses(ctx, 7, true))
+	result, err = s.FindAccesses(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Total)
+
+	assert.NoError(t, s.DeleteOwnerAccesses(ctx, 7, true), "an owner without accesses isn't an error")
+}
+
+func testFindExpiringAccesses(ctx context.Context, t *testing.T, s engine.Interface) {
+	now := time.Now().Unix()
+	accesses := []store.Access{
+		{Name: "unlimited", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+		{Name: "expires soon", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "push", ExpiresAt: now + 3600},
+		{Name: "expires later", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "delete", NotBefore: now, ExpiresAt: now + 86400*30},
+		{Name: "expired", Owner: 3, Type: "repository", ResourceName: "foo/bar", Action: "push", ExpiresAt: now - 3600, Disabled: true},
+	}
+	for i := range accesses {
+		require.NoError(t, s.CreateAccess(ctx, &accesses[i]))
+	}
+
+	// rules without validity window never expire
+	result, err := s.FindAccesses(ctx, engine.QueryFilter{
+		Filters: map[string]interface{}{engine.AccessExpiresBefore: float64(now + 86400)},
+		Sort:    []string{"expires_at", "asc"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Total)
+	assert.Equal(t, accesses[3], result.Data[0].(store.Access))
+	assert.Equal(t, accesses[1], result.Data[1].(store.Access))
+
+	result, err = s.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{
+		engine.AccessExpiresBefore: float64(now + 86400), "disabled": false,
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, accesses[1], result.Data[0].(store.Access))
+}
+
+func testAccessGarbageCollector(ctx context.Context, t *testing.T, s engine.Interface) {
+	entry := store.RegistryEntry{RepositoryName: "foo/bar", Tag: "latest", Digest: "sha256:1", ConfigDigest: "sha256:2", Size: 1}
+	require.NoError(t, s.CreateRepository(ctx, &entry))
+
+	kept := store.Access{Name: "kept", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, s.CreateAccess(ctx, &kept))
+	removed := store.Access{Name: "removed", Owner: 2, Type: "repository", ResourceName: "foo/removed", Action: "pull"}
+	require.NoError(t, s.CreateAccess(ctx, &removed))
+
+	// patterns, the registry catalog and deny rules made ahead of repositories are kept
+	patterns := []store.Access{
+		{Name: "segment", Owner: 2, Type: "repository", ResourceName: "team/*", Action: "pull"},
+		{Name: "namespace", Owner: 2, Type: "repository", ResourceName: "team/**", Action: "pull"},
+		{Name: "any", Owner: 2, Type: "repository", ResourceName: store.ResourceAnyName, Action: "pull"},
+		{Name: "catalog", Owner: 2, Type: store.RegistryResourceType, ResourceName: store.RegistryCatalogName, Action: "*"},
+		{Name: "deny", Owner: 2, Type: "repository", ResourceName: "foo/not-pushed", Action: "push", Effect: store.AccessEffectDeny},
+		{Name: "deny-pattern", Owner: 2, Type: "repository", ResourceName: "team/**", Action: "delete", Effect: store.AccessEffectDeny},
+	}
+	for i := range patterns {
+		require.NoError(t, s.CreateAccess(ctx, &patterns[i]))
+	}
+
+	// accesses to repositories which don't exist anymore are removed
+	require.NoError(t, s.AccessGarbageCollector(ctx))
+
+	result, err := s.FindAccesses(ctx, engine.QueryFilter{Sort: []string{"id", "asc"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), result.Total)
+	assert.Equal(t, kept, result.Data[0].(store.Access))
+	for i, access := range patterns {
+		assert.Equal(t, access, result.Data[i+1].(store.Access))
+	}
+}
@@ -0,0 +1,147 @@
+// Package enginetest provides the conformance test suite for engine.Interface implementations.
+// The suite describes the behaviour which the rest of the application relies on: ErrNotFound and ErrDuplicateEntry
+// semantics, filters validation, repositories selection by user access, GroupByField totals, garbage collectors
+// cutoffs and transactions. A storage engine proves its compatibility by calling Run from own tests:
+//
+//	func TestMyEngine_Conformance(t *testing.T) {
+//		enginetest.Run(t, func(ctx context.Context, t *testing.T) engine.Interface {
+//			db := NewMyEngine(dsn)
+//			require.NoError(t, db.Connect(ctx))
+//			t.Cleanup(func() { dropTables(dsn) })
+//			return db
+//		})
+//	}
+package enginetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// Factory returns a storage connected to a new database which contains only the default records created
+// at first connect: built-in roles and the 'admin' user with 'admin' role which is a member of the 'default' group. Passed context
+// is canceled when the test finishes, a factory is responsible for removing test data, e.g. with t.Cleanup.
+type Factory func(ctx context.Context, t *testing.T) engine.Interface
+
+type testCase struct {
+	name string
+	fn   func(ctx context.Context, t *testing.T, s engine.Interface)
+}
+
+// Run runs all tests of suite, each test gets a new storage made by newEngine.
+// Tests run sequentially, so a factory may reuse the same database for all of them.
+func Run(t *testing.T, newEngine Factory) {
+	tests := []testCase{
+		{"DefaultRecords", testDefaultRecords},
+		{"Users", testUsers},
+		{"FindUsers", testFindUsers},
+		{"UserTOTP", testUserTOTP},
+		{"UserTOTPStep", testUserTOTPStep},
+		{"UserSSOID", testUserSSOID},
+		{"Groups", testGroups},
+		{"GroupMembers", testGroupMembers},
+		{"Accesses", testAccesses},
+		{"FindAccesses", testFindAccesses},
+		{"FindExpiringAccesses", testFindExpiringAccesses},
+		{"DeleteOwnerAccesses", testDeleteOwnerAccesses},
+		{"AccessGarbageCollector", testAccessGarbageCollector},
+		{"Repositories", testRepositories},
+		{"FindRepositories", testFindRepositories},
+		{"FindRepositoriesByUserAccess", testFindRepositoriesByUserAccess},
+		{"FindRepositoriesBySpecificRules", testFindRepositoriesBySpecificRules},
+		{"UpdateRepository", testUpdateRepository},
+		{"RepositoryGarbageCollector", testRepositoryGarbageCollector},
+		{"Robots", testRobots},
+		{"Roles", testRoles},
+		{"RefreshTokens", testRefreshTokens},
+		{"WithTx", testWithTx},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tt.fn(ctx, t, newEngine(ctx, t))
+		})
+	}
+}
+
+func testDefaultRecords(ctx context.Context, t *testing.T, s engine.Interface) {
+	groups, err := s.FindGroups(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), groups.Total)
+	group := groups.Data[0].(store.Group)
+	assert.Equal(t, "default", group.Name)
+
+	users, err := s.FindUsers(ctx, engine.QueryFilter{}, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), users.Total)
+	admin := users.Data[0].(store.User)
+	assert.Equal(t, "admin", admin.Login)
+	assert.Equal(t, store.AdminRole, admin.Role)
+	assert.Equal(t, group.ID, admin.Group)
+
+	members, err := s.FindGroupMembers(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), members.Total)
+	assert.Equal(t, store.GroupMember{GroupID: group.ID, UserID: admin.ID}, members.Data[0].(store.GroupMember))
+}
+
+func testWithTx(ctx context.Context, t *testing.T, s engine.Interface) {
+	err := s.WithTx(ctx, func(ctx context.Context) error {
+		return s.CreateGroup(ctx, &store.Group{Name: "committed"})
+	})
+	require.NoError(t, err)
+	assertTotal(t, 2, func() (engine.ListResponse, error) { return s.FindGroups(ctx, engine.QueryFilter{}) })
+
+	errTest := errors.New("test error")
+	err = s.WithTx(ctx, func(ctx context.Context) error {
+		if errCreate := s.CreateGroup(ctx, &store.Group{Name: "rolled_back"}); errCreate != nil {
+			return errCreate
+		}
+
+		// nested call joins the outer transaction and rolls back with it
+		errNested := s.WithTx(ctx, func(ctx context.Context) error {
+			return s.CreateUser(ctx, &store.User{Login: "rolled_back", Name: "rolled_back", Password: "password", Role: store.UserRole})
+		})
+		if errNested != nil {
+			return errNested
+		}
+
+		// records created in transaction are visible inside it
+		groups, errFind := s.FindGroups(ctx, engine.QueryFilter{})
+		if errFind != nil {
+			return errFind
+		}
+		assert.Equal(t, int64(3), groups.Total)
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+
+	assertTotal(t, 2, func() (engine.ListResponse, error) { return s.FindGroups(ctx, engine.QueryFilter{}) })
+	_, err = s.GetUser(ctx, "rolled_back")
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+}
+
+// assertTotal checks total value of a list result which returned by find
+func assertTotal(t *testing.T, expected int64, find func() (engine.ListResponse, error)) {
+	t.Helper()
+	result, err := find()
+	require.NoError(t, err)
+	assert.Equal(t, expected, result.Total)
+	assert.Equal(t, int(expected), len(result.Data))
+}
+
+// assertInvalidKey checks that err reports about a field which isn't allowed in query
+func assertInvalidKey(t *testing.T, err error) {
+	t.Helper()
+	var keyErr *engine.InvalidKeyError
+	assert.ErrorAs(t, err, &keyErr)
+}
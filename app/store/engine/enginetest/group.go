@@ -0,0 +1,108 @@
+package enginetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func testGroups(ctx context.Context, t *testing.T, s engine.Interface) {
+	group := store.Group{Name: "developers", Description: "test group"}
+	require.NoError(t, s.CreateGroup(ctx, &group))
+	assert.Greater(t, group.ID, int64(0))
+
+	result, err := s.GetGroup(ctx, group.ID)
+	require.NoError(t, err)
+	assert.Equal(t, group, result)
+
+	_, err = s.GetGroup(ctx, -1)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	assert.Error(t, s.CreateGroup(ctx, &store.Group{Description: "without name"}))
+	assert.ErrorIs(t, s.CreateGroup(ctx, &store.Group{Name: "developers"}), engine.ErrDuplicateEntry)
+
+	require.NoError(t, s.CreateGroup(ctx, &store.Group{Name: "managers"}))
+	groups, err := s.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "develop"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), groups.Total)
+	assert.Equal(t, group, groups.Data[0].(store.Group))
+
+	groups, err = s.FindGroups(ctx, engine.QueryFilter{Range: [2]int64{0, 2}, Sort: []string{"name", "asc"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), groups.Total)
+	require.Len(t, groups.Data, 2)
+	assert.Equal(t, "default", groups.Data[0].(store.Group).Name)
+
+	_, err = s.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR name": "x"}})
+	assertInvalidKey(t, err)
+
+	group.Name = "developers_updated"
+	group.Description = "updated"
+	require.NoError(t, s.UpdateGroup(ctx, group))
+	result, err = s.GetGroup(ctx, group.ID)
+	require.NoError(t, err)
+	assert.Equal(t, group, result)
+
+	require.NoError(t, s.DeleteGroup(ctx, group.ID))
+	_, err = s.GetGroup(ctx, group.ID)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteGroup(ctx, group.ID), engine.ErrNotFound)
+}
+
+func testGroupMembers(ctx context.Context, t *testing.T, s engine.Interface) {
+	developers := store.Group{Name: "developers"}
+	require.NoError(t, s.CreateGroup(ctx, &developers))
+	testers := store.Group{Name: "testers"}
+	require.NoError(t, s.CreateGroup(ctx, &testers))
+
+	// a new user is a member of the primary group
+	user := store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, s.CreateUser(ctx, &user))
+	assertTotal(t, 1, func() (engine.ListResponse, error) {
+		return s.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}})
+	})
+
+	member := store.GroupMember{GroupID: developers.ID, UserID: user.ID}
+	require.NoError(t, s.AddGroupMember(ctx, member))
+	assert.ErrorIs(t, s.AddGroupMember(ctx, member), engine.ErrDuplicateEntry)
+
+	// values of filters come from JSON, so numbers have float64 type
+	result, err := s.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"group_id": float64(developers.ID)}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, member, result.Data[0].(store.GroupMember))
+
+	// change of the primary group keeps the previous membership and doesn't duplicate the existed one
+	user.Group = developers.ID
+	require.NoError(t, s.UpdateUser(ctx, user))
+	user.Group = testers.ID
+	require.NoError(t, s.UpdateUser(ctx, user))
+	result, err = s.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}, Sort: []string{"group_id", "asc"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), result.Total)
+	assert.Equal(t, []interface{}{
+		store.GroupMember{GroupID: 1, UserID: user.ID},
+		member,
+		store.GroupMember{GroupID: testers.ID, UserID: user.ID},
+	}, result.Data)
+
+	require.NoError(t, s.DeleteGroupMember(ctx, member))
+	assert.ErrorIs(t, s.DeleteGroupMember(ctx, member), engine.ErrNotFound)
+
+	// deleted group and user are removed from membership list
+	require.NoError(t, s.DeleteGroup(ctx, testers.ID))
+	assertTotal(t, 1, func() (engine.ListResponse, error) {
+		return s.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}})
+	})
+	require.NoError(t, s.DeleteUser(ctx, user.ID))
+	assertTotal(t, 0, func() (engine.ListResponse, error) {
+		return s.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}})
+	})
+
+	_, err = s.FindGroupMembers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR user_id": float64(1)}})
+	assertInvalidKey(t, err)
+}
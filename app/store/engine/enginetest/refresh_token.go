@@ -0,0 +1,79 @@
+package enginetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func testRefreshTokens(ctx context.Context, t *testing.T, s engine.Interface) {
+	user := store.User{Login: "john", Name: "John", Password: "john_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, s.CreateUser(ctx, &user))
+
+	now := time.Now().Unix()
+	token := store.RefreshToken{UserID: user.ID, ClientID: "docker", Hash: store.HashRefreshToken("token1"),
+		CreatedAt: now, ExpiresAt: now + 3600}
+	require.NoError(t, s.CreateRefreshToken(ctx, &token))
+	assert.Greater(t, token.ID, int64(0))
+
+	expired := store.RefreshToken{UserID: user.ID, ClientID: "containerd", Hash: store.HashRefreshToken("token2"),
+		CreatedAt: now - 7200, ExpiresAt: now - 3600}
+	require.NoError(t, s.CreateRefreshToken(ctx, &expired))
+
+	admin := store.RefreshToken{UserID: 1, ClientID: "docker", Hash: store.HashRefreshToken("token3"), CreatedAt: now, ExpiresAt: now + 3600}
+	require.NoError(t, s.CreateRefreshToken(ctx, &admin))
+
+	assert.Error(t, s.CreateRefreshToken(ctx, &store.RefreshToken{UserID: user.ID}), "hash required")
+	assert.ErrorIs(t, s.CreateRefreshToken(ctx, &store.RefreshToken{UserID: user.ID, Hash: token.Hash}), engine.ErrDuplicateEntry)
+
+	result, err := s.GetRefreshToken(ctx, store.HashRefreshToken("token1"))
+	require.NoError(t, err)
+	assert.Equal(t, token, result)
+
+	_, err = s.GetRefreshToken(ctx, store.HashRefreshToken("unknown"))
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	require.NoError(t, s.UpdateRefreshTokenUsage(ctx, token.ID, now+10))
+	require.NoError(t, s.UpdateRefreshTokenUsage(ctx, token.ID, now+10), "the same time of usage")
+	result, err = s.GetRefreshToken(ctx, token.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, now+10, result.LastUsedAt)
+	assert.ErrorIs(t, s.UpdateRefreshTokenUsage(ctx, -1, now), engine.ErrNotFound)
+
+	tokens, err := s.FindRefreshTokens(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id": user.ID}, Sort: []string{"id", "asc"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), tokens.Total)
+	assert.Equal(t, token.ID, tokens.Data[0].(store.RefreshToken).ID)
+	assert.Equal(t, expired.ID, tokens.Data[1].(store.RefreshToken).ID)
+
+	_, err = s.FindRefreshTokens(ctx, engine.QueryFilter{Filters: map[string]interface{}{"user_id; DROP TABLE users": 1}})
+	assertInvalidKey(t, err)
+
+	// expired tokens are deleted by garbage collector only
+	require.NoError(t, s.RefreshTokenGarbageCollector(ctx, now))
+	_, err = s.GetRefreshToken(ctx, expired.Hash)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	_, err = s.GetRefreshToken(ctx, token.Hash)
+	assert.NoError(t, err)
+
+	require.NoError(t, s.DeleteRefreshToken(ctx, "id", token.ID))
+	assert.ErrorIs(t, s.DeleteRefreshToken(ctx, "id", token.ID), engine.ErrNotFound)
+	assertInvalidKey(t, s.DeleteRefreshToken(ctx, "id; DROP TABLE users", 1))
+
+	// tokens of deleted user are deleted too
+	other := store.RefreshToken{UserID: user.ID, ClientID: "docker", Hash: store.HashRefreshToken("token4"), CreatedAt: now, ExpiresAt: now + 3600}
+	require.NoError(t, s.CreateRefreshToken(ctx, &other))
+	require.NoError(t, s.DeleteUser(ctx, user.ID))
+	_, err = s.GetRefreshToken(ctx, other.Hash)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	require.NoError(t, s.DeleteRefreshToken(ctx, "user_id", int64(1)))
+	tokens, err = s.FindRefreshTokens(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), tokens.Total)
+}
@@ -0,0 +1,292 @@
+package enginetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// testEntries returns repositories entries where 'foo/first' has two tags and 'foo/second', 'bar/third' have one
+func testEntries(timestamp int64) []store.RegistryEntry {
+	return []store.RegistryEntry{
+		{RepositoryName: "foo/first", Tag: "v1", Digest: "sha256:11", ConfigDigest: "sha256:c11", Size: 100, Timestamp: timestamp, Raw: `{"tag":"v1"}`},
+		{RepositoryName: "foo/first", Tag: "v2", Digest: "sha256:12", ConfigDigest: "sha256:c12", Size: 200, Timestamp: timestamp, Raw: `{"tag":"v2"}`},
+		{RepositoryName: "foo/second", Tag: "latest", Digest: "sha256:21", ConfigDigest: "sha256:c21", Size: 300, Timestamp: timestamp},
+		{RepositoryName: "bar/third", Tag: "latest", Digest: "sha256:31", ConfigDigest: "sha256:c31", Size: 400, Timestamp: timestamp},
+	}
+}
+
+func createEntries(ctx context.Context, t *testing.T, s engine.Interface, entries []store.RegistryEntry) {
+	for i := range entries {
+		require.NoError(t, s.CreateRepository(ctx, &entries[i]))
+		require.Greater(t, entries[i].ID, int64(0))
+	}
+}
+
+func testRepositories(ctx context.Context, t *testing.T, s engine.Interface) {
+	entries := testEntries(time.Now().Unix())
+	createEntries(ctx, t, s, entries)
+
+	result, err := s.GetRepository(ctx, entries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entries[0], result)
+
+	_, err = s.GetRepository(ctx, -1)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	// repository name and tag are unique together
+	duplicate := entries[0]
+	assert.ErrorIs(t, s.CreateRepository(ctx, &duplicate), engine.ErrDuplicateEntry)
+
+	assert.Error(t, s.CreateRepository(ctx, &store.RegistryEntry{Tag: "latest", Digest: "sha256:1", ConfigDigest: "sha256:2"}))
+
+	// an entry deletes by repository name and digest
+	require.NoError(t, s.DeleteRepository(ctx, entries[0].RepositoryName, entries[0].Digest))
+	_, err = s.GetRepository(ctx, entries[0].ID)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteRepository(ctx, entries[0].RepositoryName, entries[0].Digest), engine.ErrNotFound)
+
+	result, err = s.GetRepository(ctx, entries[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entries[1], result)
+}
+
+func testFindRepositories(ctx context.Context, t *testing.T, s engine.Interface) {
+	entries := testEntries(time.Now().Unix())
+	createEntries(ctx, t, s, entries)
+
+	// full text search by repository name and tag
+	filter := engine.QueryFilter{Filters: map[string]interface{}{"q": "foo/"}, Range: [2]int64{0, 2}, Sort: []string{"id", "asc"}}
+	result, err := s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, entries[0], result.Data[0].(store.RegistryEntry))
+
+	filter = engine.QueryFilter{Filters: map[string]interface{}{store.RegistryRepositoryNameField: "foo/first"}}
+	assertTotal(t, 2, func() (engine.ListResponse, error) { return s.FindRepositories(ctx, filter) })
+
+	filter = engine.QueryFilter{Filters: map[string]interface{}{store.RegistryRepositoryNameField: "unknown"}}
+	assertTotal(t, 0, func() (engine.ListResponse, error) { return s.FindRepositories(ctx, filter) })
+
+	// grouping by repository name counts unique names and summarizes size of all tags
+	filter = engine.QueryFilter{GroupByField: true, Sort: []string{"repository_name", "desc"}}
+	result, err = s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Data, 3)
+	assert.Equal(t, "foo/second", result.Data[0].(store.RegistryEntry).RepositoryName)
+
+	filter.Filters = map[string]interface{}{store.RegistryRepositoryNameField: "foo/first"}
+	result, err = s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	require.Len(t, result.Data, 1)
+	assert.Equal(t, entries[0].Size+entries[1].Size, result.Data[0].(store.RegistryEntry).Size)
+
+	filter = engine.QueryFilter{GroupByField: true, Range: [2]int64{0, 1}}
+	result, err = s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	assert.Len(t, result.Data, 1)
+
+	_, err = s.FindRepositories(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR tag": "x"}})
+	assertInvalidKey(t, err)
+
+	_, err = s.FindRepositories(ctx, engine.QueryFilter{Sort: []string{"size; --", "asc"}})
+	assertInvalidKey(t, err)
+}
+
+func testFindRepositoriesByUserAccess(ctx context.Context, t *testing.T, s engine.Interface) {
+	now := time.Now().Unix()
+	entries := testEntries(now)
+	createEntries(ctx, t, s, entries)
+
+	group := store.Group{Name: "developers"}
+	require.NoError(t, s.CreateGroup(ctx, &group))
+	secondGroup := store.Group{Name: "testers"}
+	require.NoError(t, s.CreateGroup(ctx, &secondGroup))
+
+	user := store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: store.UserRole, Group: group.ID}
+	require.NoError(t, s.CreateUser(ctx, &user))
+	require.NoError(t, s.AddGroupMember(ctx, store.GroupMember{GroupID: secondGroup.ID, UserID: user.ID}))
+
+	accesses := []store.Access{
+		{Name: "own", Owner: user.ID, Type: "repository", ResourceName: "foo/first", Action: "pull"},
+		{Name: "second user group", Owner: secondGroup.ID, IsGroup: true, Type: "repository", ResourceName: "bar/third", Action: "pull"},
+		{Name: "primary user group duplicate", Owner: group.ID, IsGroup: true, Type: "repository", ResourceName: "foo/first", Action: "push"},
+		{Name: "other group", Owner: 1, IsGroup: true, Type: "repository", ResourceName: "foo/second", Action: "pull"},
+		{Name: "other user", Owner: user.ID + 1, Type: "repository", ResourceName: "foo/second", Action: "pull"},
+		{Name: "expired", Owner: user.ID, Type: "repository", ResourceName: "foo/second", Action: "push", ExpiresAt: now - 3600},
+		{Name: "not yet", Owner: secondGroup.ID, IsGroup: true, Type: "repository", ResourceName: "foo/second", Action: "delete", NotBefore: now + 3600},
+		{Name: "limited", Owner: user.ID, Type: "repository", ResourceName: "bar/third", Action: "push", NotBefore: now - 3600, ExpiresAt: now + 3600},
+	}
+	for i := range accesses {
+		require.NoError(t, s.CreateAccess(ctx, &accesses[i]))
+	}
+
+	// entries of repositories which the user has access to by own rules or by rules of any user group are selected once,
+	// rules apply within their validity window only
+	filter := engine.QueryFilter{Filters: map[string]interface{}{engine.RepositoriesByUserAccess: user.ID}}
+	result, err := s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Data, 3)
+	for _, v := range result.Data {
+		assert.Contains(t, []string{"foo/first", "bar/third"}, v.(store.RegistryEntry).RepositoryName)
+	}
+
+	filter.GroupByField = true
+	result, err = s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+	assert.Len(t, result.Data, 2)
+
+	filter.GroupByField = false
+	filter.Filters["q"] = "third"
+	assertTotal(t, 1, func() (engine.ListResponse, error) { return s.FindRepositories(ctx, filter) })
+
+	// resource names of rules can be patterns, a segment wildcard doesn't match nested repositories
+	createEntries(ctx, t, s, []store.RegistryEntry{
+		{RepositoryName: "team.a/app", Tag: "latest", Digest: "sha256:41", ConfigDigest: "sha256:c41", Size: 1},
+		{RepositoryName: "team.a/nested/app", Tag: "latest", Digest: "sha256:42", ConfigDigest: "sha256:c42", Size: 1},
+		{RepositoryName: "teamxa/app", Tag: "latest", Digest: "sha256:43", ConfigDigest: "sha256:c43", Size: 1},
+	})
+	patterns := []store.Access{
+		{Name: "segment pattern", Owner: user.ID, Type: "repository", ResourceName: "team.a/*", Action: "pull"},
+		{Name: "namespace pattern", Owner: secondGroup.ID, IsGroup: true, Type: "repository", ResourceName: "foo/**", Action: "pull"},
+	}
+	for i := range patterns {
+		require.NoError(t, s.CreateAccess(ctx, &patterns[i]))
+	}
+
+	filter = engine.QueryFilter{Filters: map[string]interface{}{engine.RepositoriesByUserAccess: user.ID}, GroupByField: true}
+	result, err = s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Total)
+	require.Len(t, result.Data, 4)
+	for _, v := range result.Data {
+		assert.Contains(t, []string{"foo/first", "foo/second", "bar/third", "team.a/app"}, v.(store.RegistryEntry).RepositoryName)
+	}
+
+	// the whole wildcard matches all repositories
+	all := store.Access{Name: "all", Owner: user.ID, Type: "repository", ResourceName: store.ResourceAnyName, Action: "pull"}
+	require.NoError(t, s.CreateAccess(ctx, &all))
+	assertTotal(t, 6, func() (engine.ListResponse, error) { return s.FindRepositories(ctx, filter) })
+
+	// deny rules of the user, the user groups and rules shared for all users hide repositories which they forbid to pull,
+	// rules which deny other actions, disabled rules and rules of other users don't hide anything
+	denies := []store.Access{
+		{Name: "own deny", Owner: user.ID, Type: "repository", ResourceName: "team.a/app", Action: "pull", Effect: store.AccessEffectDeny},
+		{Name: "group deny", Owner: secondGroup.ID, IsGroup: true, Type: "repository", ResourceName: "teamxa/*", Action: store.ActionAll, Effect: store.AccessEffectDeny},
+		{Name: "shared deny", Owner: engine.RegisteredUserID, Type: "repository", ResourceName: "team.a/nested/app", Action: "pull", Effect: store.AccessEffectDeny},
+		{Name: "delete deny", Owner: user.ID, Type: "repository", ResourceName: "bar/third", Action: "delete", Effect: store.AccessEffectDeny},
+		{Name: "disabled deny", Owner: user.ID, Type: "repository", ResourceName: "foo/first", Action: store.ActionAll, Effect: store.AccessEffectDeny, Disabled: true},
+		{Name: "other user deny", Owner: user.ID + 1, Type: "repository", ResourceName: "foo/second", Action: store.ActionAll, Effect: store.AccessEffectDeny},
+	}
+	for i := range denies {
+		require.NoError(t, s.CreateAccess(ctx, &denies[i]))
+	}
+
+	result, err = s.FindRepositories(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Data, 3)
+	for _, v := range result.Data {
+		assert.Contains(t, []string{"foo/first", "foo/second", "bar/third"}, v.(store.RegistryEntry).RepositoryName)
+	}
+}
+
+func testFindRepositoriesBySpecificRules(ctx context.Context, t *testing.T, s engine.Interface) {
+	createEntries(ctx, t, s, []store.RegistryEntry{
+		{RepositoryName: "team-a/app", Tag: "latest", Digest: "sha256:51", ConfigDigest: "sha256:c51", Size: 1},
+		{RepositoryName: "team-a/web", Tag: "latest", Digest: "sha256:52", ConfigDigest: "sha256:c52", Size: 1},
+		{RepositoryName: "team-a/api", Tag: "latest", Digest: "sha256:53", ConfigDigest: "sha256:c53", Size: 1},
+	})
+
+	group := store.Group{Name: "developers"}
+	require.NoError(t, s.CreateGroup(ctx, &group))
+	user := store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: store.UserRole, Group: group.ID}
+	require.NoError(t, s.CreateUser(ctx, &user))
+
+	accesses := []store.Access{
+		{Name: "namespace pull", Owner: user.ID, Type: "repository", ResourceName: "team-a/*", Action: "pull"},
+		{Name: "app delete", Owner: group.ID, IsGroup: true, Type: "repository", ResourceName: "team-a/app", Action: "delete"},
+		{Name: "web push", Owner: group.ID, IsGroup: true, Type: "repository", ResourceName: "team-a/web", Action: "push"},
+		{Name: "web push deny", Owner: user.ID, Type: "repository", ResourceName: "team-a/web", Action: "push", Effect: store.AccessEffectDeny},
+		{Name: "shared api push", Owner: engine.RegisteredUserID, Type: "repository", ResourceName: "team-a/api", Action: "push"},
+	}
+	for i := range accesses {
+		require.NoError(t, s.CreateAccess(ctx, &accesses[i]))
+	}
+
+	// the same as the access check of tokens, only the most specific allow rules of the user and the user groups apply:
+	// exact rules of 'team-a/web' override the namespace rule and the only action which they grant is denied,
+	// rules shared for all users don't override rules of the user
+	result, err := s.FindRepositories(ctx, engine.QueryFilter{Filters: map[string]interface{}{engine.RepositoriesByUserAccess: user.ID}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Total)
+	for _, v := range result.Data {
+		assert.Contains(t, []string{"team-a/app", "team-a/api"}, v.(store.RegistryEntry).RepositoryName)
+	}
+}
+
+func testUpdateRepository(ctx context.Context, t *testing.T, s engine.Interface) {
+	entries := testEntries(time.Now().Unix())
+	createEntries(ctx, t, s, entries)
+
+	conditionClause := map[string]interface{}{store.RegistryRepositoryNameField: "foo/first", store.RegistryTagField: "v2"}
+	data := map[string]interface{}{
+		store.RegistryContentDigestField: "sha256:updated",
+		store.RegistryPullCounterField:   int64(10),
+		store.RegistryTimestampField:     entries[1].Timestamp + 100,
+		store.RegistryRawField:           `{"updated":true}`,
+	}
+	require.NoError(t, s.UpdateRepository(ctx, conditionClause, data))
+
+	result, err := s.GetRepository(ctx, entries[1].ID)
+	require.NoError(t, err)
+	entries[1].Digest = "sha256:updated"
+	entries[1].PullCounter = 10
+	entries[1].Timestamp += 100
+	entries[1].Raw = `{"updated":true}`
+	assert.Equal(t, entries[1], result)
+
+	// other tags of the repository keep own values
+	result, err = s.GetRepository(ctx, entries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entries[0], result)
+
+	conditionClause[store.RegistryTagField] = "unknown"
+	assert.Error(t, s.UpdateRepository(ctx, conditionClause, data))
+
+	assert.Error(t, s.UpdateRepository(ctx, conditionClause, map[string]interface{}{}))
+
+	assertInvalidKey(t, s.UpdateRepository(ctx, map[string]interface{}{"1=1 OR id": 1}, data))
+	assertInvalidKey(t, s.UpdateRepository(ctx, conditionClause, map[string]interface{}{"tag='x', raw": "y"}))
+}
+
+func testRepositoryGarbageCollector(ctx context.Context, t *testing.T, s engine.Interface) {
+	syncDate := time.Now().Unix()
+	actual := testEntries(syncDate)[:2]
+	outdated := testEntries(syncDate - 1)[2:]
+	createEntries(ctx, t, s, actual)
+	createEntries(ctx, t, s, outdated)
+
+	// entries which weren't updated since sync date are removed, the ones updated exactly at sync date are kept
+	require.NoError(t, s.RepositoryGarbageCollector(ctx, syncDate))
+
+	result, err := s.FindRepositories(ctx, engine.QueryFilter{Sort: []string{"id", "asc"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Total)
+	assert.Equal(t, actual[0], result.Data[0].(store.RegistryEntry))
+	assert.Equal(t, actual[1], result.Data[1].(store.RegistryEntry))
+
+	// repeated call without outdated entries isn't an error
+	require.NoError(t, s.RepositoryGarbageCollector(ctx, syncDate))
+}
@@ -0,0 +1,95 @@
+package enginetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func testRobots(ctx context.Context, t *testing.T, s engine.Interface) {
+	robot := store.Robot{
+		Name:        "ci-builder",
+		Description: "test robot",
+		Secret:      "robot_secret",
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+		Permissions: []store.RobotPermission{
+			{Type: store.RepositoryResourceType, ResourceName: "team-a/*", Actions: []string{"pull", "push"}},
+		},
+	}
+	require.NoError(t, s.CreateRobot(ctx, &robot))
+	assert.Greater(t, robot.ID, int64(0))
+	assert.Greater(t, robot.CreatedAt, int64(0))
+	assert.NotEqual(t, "robot_secret", robot.Secret)
+	assert.True(t, store.ComparePassword(robot.Secret, "robot_secret"))
+
+	result, err := s.GetRobot(ctx, robot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, robot, result)
+
+	result, err = s.GetRobot(ctx, "ci-builder")
+	require.NoError(t, err)
+	assert.Equal(t, robot, result)
+
+	_, err = s.GetRobot(ctx, int64(-1))
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	_, err = s.GetRobot(ctx, "unknown")
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	assert.Error(t, s.CreateRobot(ctx, &store.Robot{Name: "without_secret"}))
+	assert.Error(t, s.CreateRobot(ctx, &store.Robot{Secret: "without_name"}))
+	assert.ErrorIs(t, s.CreateRobot(ctx, &store.Robot{Name: "ci-builder", Secret: "secret"}), engine.ErrDuplicateEntry)
+
+	// secret restored from a backup is kept as is
+	deployer := store.Robot{Name: "deployer", Secret: robot.Secret, Disabled: true}
+	require.NoError(t, s.CreateRobot(engine.SetPasswordHashed(ctx), &deployer))
+	assert.Equal(t, robot.Secret, deployer.Secret)
+
+	robots, err := s.FindRobots(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "builder"}}, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), robots.Total)
+	withoutSecret := robot
+	withoutSecret.Secret = ""
+	assert.Equal(t, withoutSecret, robots.Data[0].(store.Robot))
+
+	robots, err = s.FindRobots(ctx, engine.QueryFilter{Range: [2]int64{0, 1}, Sort: []string{"name", "desc"}}, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), robots.Total)
+	require.Len(t, robots.Data, 1)
+	assert.Equal(t, deployer, robots.Data[0].(store.Robot))
+
+	_, err = s.FindRobots(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR name": "x"}}, false)
+	assertInvalidKey(t, err)
+
+	// empty secret keeps the stored one
+	robot.Description = "updated"
+	robot.Disabled = true
+	robot.Permissions = append(robot.Permissions, store.RobotPermission{
+		Type: store.RegistryResourceType, ResourceName: store.RegistryCatalogName, Actions: []string{store.ActionAll},
+	})
+	update := robot
+	update.Secret = ""
+	require.NoError(t, s.UpdateRobot(ctx, update))
+	result, err = s.GetRobot(ctx, robot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, robot, result)
+
+	update.Secret = "new_secret"
+	require.NoError(t, s.UpdateRobot(ctx, update))
+	result, err = s.GetRobot(ctx, robot.ID)
+	require.NoError(t, err)
+	assert.True(t, store.ComparePassword(result.Secret, "new_secret"))
+
+	update.Name = "deployer"
+	assert.ErrorIs(t, s.UpdateRobot(ctx, update), engine.ErrDuplicateEntry)
+	assert.ErrorIs(t, s.UpdateRobot(ctx, store.Robot{ID: -1, Name: "unknown"}), engine.ErrNotFound)
+
+	require.NoError(t, s.DeleteRobot(ctx, robot.ID))
+	_, err = s.GetRobot(ctx, robot.ID)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteRobot(ctx, robot.ID), engine.ErrNotFound)
+}
@@ -0,0 +1,87 @@
+package enginetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func testRoles(ctx context.Context, t *testing.T, s engine.Interface) {
+	// built-in roles are created at connect
+	for _, builtIn := range store.BuiltInRoles() {
+		result, err := s.GetRole(ctx, builtIn.Name)
+		require.NoError(t, err)
+		builtIn.ID = result.ID
+		assert.Equal(t, builtIn, result)
+	}
+
+	role := store.Role{
+		Name:        "auditor",
+		Description: "test role",
+		Permissions: []string{store.PermissionUsersRead, store.PermissionAccessRead},
+	}
+	require.NoError(t, s.CreateRole(ctx, &role))
+	assert.Greater(t, role.ID, int64(0))
+
+	result, err := s.GetRole(ctx, role.ID)
+	require.NoError(t, err)
+	assert.Equal(t, role, result)
+
+	result, err = s.GetRole(ctx, "auditor")
+	require.NoError(t, err)
+	assert.Equal(t, role, result)
+
+	_, err = s.GetRole(ctx, int64(-1))
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	_, err = s.GetRole(ctx, "unknown")
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	assert.Error(t, s.CreateRole(ctx, &store.Role{Description: "without name"}))
+	assert.ErrorIs(t, s.CreateRole(ctx, &store.Role{Name: store.AdminRole}), engine.ErrDuplicateEntry)
+
+	roles, err := s.FindRoles(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "audit"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), roles.Total)
+	assert.Equal(t, role, roles.Data[0].(store.Role))
+
+	roles, err = s.FindRoles(ctx, engine.QueryFilter{Filters: map[string]interface{}{"built_in": true}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(store.BuiltInRoles())), roles.Total)
+
+	roles, err = s.FindRoles(ctx, engine.QueryFilter{Range: [2]int64{0, 1}, Sort: []string{"name", "asc"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(store.BuiltInRoles())+1), roles.Total)
+	require.Len(t, roles.Data, 1)
+	assert.Equal(t, store.AdminRole, roles.Data[0].(store.Role).Name)
+
+	_, err = s.FindRoles(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR name": "x"}})
+	assertInvalidKey(t, err)
+
+	// users can be assigned to custom roles
+	user := store.User{Login: "audit", Name: "Audit", Password: "audit_password", Role: role.Name, Group: 1}
+	require.NoError(t, s.CreateUser(ctx, &user))
+
+	role.Description = "updated"
+	role.Permissions = []string{store.PermissionRegistryRead}
+	require.NoError(t, s.UpdateRole(ctx, role))
+	result, err = s.GetRole(ctx, role.ID)
+	require.NoError(t, err)
+	assert.Equal(t, role, result)
+
+	// unchanged role is updated without error
+	require.NoError(t, s.UpdateRole(ctx, role))
+	assert.ErrorIs(t, s.UpdateRole(ctx, store.Role{ID: -1, Name: "unknown"}), engine.ErrNotFound)
+
+	require.NoError(t, s.DeleteRole(ctx, role.ID))
+	_, err = s.GetRole(ctx, role.ID)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteRole(ctx, role.ID), engine.ErrNotFound)
+
+	// users can't be assigned to deleted roles
+	user.Password = ""
+	assert.Error(t, s.UpdateUser(ctx, user))
+}
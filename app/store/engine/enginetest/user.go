@@ -0,0 +1,236 @@
+package enginetest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func testUsers(ctx context.Context, t *testing.T, s engine.Interface) {
+	user := store.User{Login: "foo", Name: "Foo", Password: "foo_password", Role: store.UserRole, Group: 1, Description: "test user"}
+	require.NoError(t, s.CreateUser(ctx, &user))
+	assert.Greater(t, user.ID, int64(0))
+
+	// stored password is hashed
+	result, err := s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, "foo_password", result.Password)
+	assert.True(t, store.ComparePassword(result.Password, "foo_password"))
+	user.Password = result.Password
+	assert.Equal(t, user, result)
+
+	// user can be got by login and by ID which passed as a string
+	result, err = s.GetUser(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, user, result)
+
+	result, err = s.GetUser(ctx, strconv.FormatInt(user.ID, 10))
+	require.NoError(t, err)
+	assert.Equal(t, user, result)
+
+	_, err = s.GetUser(ctx, "unknown")
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	_, err = s.GetUser(ctx, int64(-1))
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	// login should be unique
+	duplicate := store.User{Login: "foo", Name: "Foo", Password: "foo_password", Role: store.UserRole}
+	assert.ErrorIs(t, s.CreateUser(ctx, &duplicate), engine.ErrDuplicateEntry)
+
+	// required fields validation
+	assert.Error(t, s.CreateUser(ctx, &store.User{Name: "bar", Password: "bar_password", Role: store.UserRole}))
+	assert.Error(t, s.CreateUser(ctx, &store.User{Login: "bar", Password: "bar_password", Role: store.UserRole}))
+	assert.Error(t, s.CreateUser(ctx, &store.User{Login: "bar", Name: "bar", Password: "short", Role: store.UserRole}))
+	assert.Error(t, s.CreateUser(ctx, &store.User{Login: "bar", Name: "bar", Password: "bar_password", Role: "unknown"}))
+
+	// update without password keeps the previous one
+	user.Name = "Foo Updated"
+	user.Role = store.ManagerRole
+	user.Disabled = true
+	user.Password = ""
+	require.NoError(t, s.UpdateUser(ctx, user))
+
+	result, err = s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Foo Updated", result.Name)
+	assert.Equal(t, store.ManagerRole, result.Role)
+	assert.True(t, result.Disabled)
+	assert.True(t, store.ComparePassword(result.Password, "foo_password"))
+
+	user.Password = "new_password"
+	require.NoError(t, s.UpdateUser(ctx, user))
+	result, err = s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, store.ComparePassword(result.Password, "new_password"))
+
+	user.Password = "short"
+	assert.Error(t, s.UpdateUser(ctx, user))
+
+	user.Password = ""
+	user.Role = "unknown"
+	assert.Error(t, s.UpdateUser(ctx, user))
+
+	// passwords marked as hashed are stored as is
+	hashedCtx := engine.SetPasswordHashed(ctx)
+	restored := store.User{Login: "restored", Name: "restored", Password: result.Password, Role: store.UserRole, Group: 1}
+	require.NoError(t, s.CreateUser(hashedCtx, &restored))
+	result, err = s.GetUser(ctx, "restored")
+	require.NoError(t, err)
+	assert.True(t, store.ComparePassword(result.Password, "new_password"))
+
+	require.NoError(t, s.DeleteUser(ctx, user.ID))
+	_, err = s.GetUser(ctx, user.ID)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteUser(ctx, user.ID), engine.ErrNotFound)
+}
+
+func testUserTOTPStep(ctx context.Context, t *testing.T, s engine.Interface) {
+	user := store.User{Login: "foo", Name: "Foo", Password: "foo_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, s.CreateUser(ctx, &user))
+	require.NoError(t, s.UpdateUserTOTP(ctx, user.ID, store.TOTP{Enabled: true, Secret: "JBSWY3DPEHPK3PXP"}))
+
+	require.NoError(t, s.UpdateUserTOTPStep(ctx, user.ID, 100))
+	result, err := s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), result.TOTP.LastStep)
+
+	// a step which isn't after the stored one is refused, so a code can't be accepted twice
+	assert.ErrorIs(t, s.UpdateUserTOTPStep(ctx, user.ID, 100), engine.ErrNotFound)
+	assert.ErrorIs(t, s.UpdateUserTOTPStep(ctx, user.ID, 99), engine.ErrNotFound)
+	require.NoError(t, s.UpdateUserTOTPStep(ctx, user.ID, 101))
+
+	// settings update doesn't change the step
+	require.NoError(t, s.UpdateUserTOTP(ctx, user.ID, store.TOTP{}))
+	result, err = s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), result.TOTP.LastStep)
+
+	assert.ErrorIs(t, s.UpdateUserTOTPStep(ctx, -1, 200), engine.ErrNotFound)
+}
+
+func testUserSSOID(ctx context.Context, t *testing.T, s engine.Interface) {
+	user := store.User{Login: "foo", Name: "Foo", Password: "foo_password", Role: store.UserRole, Group: 1, SSOID: "corp_0a1b2c"}
+	require.NoError(t, s.CreateUser(ctx, &user))
+
+	result, err := s.GetUser(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "corp_0a1b2c", result.SSOID)
+
+	list, err := s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "foo"}}, false)
+	require.NoError(t, err)
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "corp_0a1b2c", list.Data[0].(store.User).SSOID)
+
+	// the binding is set on create only, user update doesn't change it
+	result.SSOID, result.Password = "other_0a1b2c", ""
+	require.NoError(t, s.UpdateUser(ctx, result))
+	result, err = s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "corp_0a1b2c", result.SSOID)
+
+	local, err := s.GetUser(ctx, int64(1))
+	require.NoError(t, err)
+	assert.Empty(t, local.SSOID, "local user isn't bound to a provider")
+}
+
+func testUserTOTP(ctx context.Context, t *testing.T, s engine.Interface) {
+	user := store.User{Login: "foo", Name: "Foo", Password: "foo_password", Role: store.UserRole, Group: 1}
+	require.NoError(t, s.CreateUser(ctx, &user))
+
+	result, err := s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.TOTP{}, result.TOTP, "two-factor authentication disabled for a new user")
+
+	totp := store.TOTP{Enabled: true, Secret: "JBSWY3DPEHPK3PXP", RecoveryCodes: []string{"hash_1", "hash_2"}}
+	require.NoError(t, s.UpdateUserTOTP(ctx, user.ID, totp))
+
+	result, err = s.GetUser(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, totp, result.TOTP)
+
+	// secrets are excluded from result together with password
+	list, err := s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "foo"}}, false)
+	require.NoError(t, err)
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, store.TOTP{Enabled: true}, list.Data[0].(store.User).TOTP)
+
+	list, err = s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "foo"}}, true)
+	require.NoError(t, err)
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, totp, list.Data[0].(store.User).TOTP)
+
+	// user update doesn't change the second factor settings
+	result.Name = "Foo Updated"
+	result.TOTP = store.TOTP{}
+	result.Password = ""
+	require.NoError(t, s.UpdateUser(ctx, result))
+	result, err = s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, totp, result.TOTP)
+
+	require.NoError(t, s.UpdateUserTOTP(ctx, user.ID, store.TOTP{}))
+	result, err = s.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, result.TOTP.Enabled)
+	assert.Empty(t, result.TOTP.Secret)
+	assert.Empty(t, result.TOTP.RecoveryCodes)
+
+	assert.ErrorIs(t, s.UpdateUserTOTP(ctx, -1, totp), engine.ErrNotFound)
+}
+
+func testFindUsers(ctx context.Context, t *testing.T, s engine.Interface) {
+	for _, login := range []string{"foo_1", "foo_2", "foo_3", "bar_1"} {
+		user := store.User{Login: login, Name: login, Password: "test_password", Role: store.UserRole, Group: 1}
+		require.NoError(t, s.CreateUser(ctx, &user))
+	}
+
+	// full text search by login and name
+	filter := engine.QueryFilter{Filters: map[string]interface{}{"q": "foo"}, Sort: []string{"login", "desc"}}
+	result, err := s.FindUsers(ctx, filter, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Data, 3)
+	assert.Equal(t, "foo_3", result.Data[0].(store.User).Login)
+	for _, v := range result.Data {
+		assert.Empty(t, v.(store.User).Password, "password excluded from result")
+	}
+
+	result, err = s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "bar_1"}}, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.NotEmpty(t, result.Data[0].(store.User).Password)
+
+	// total doesn't depend on range
+	filter.Range = [2]int64{1, 3}
+	result, err = s.FindUsers(ctx, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, "foo_2", result.Data[0].(store.User).Login)
+
+	result, err = s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "unknown"}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Total)
+	assert.Empty(t, result.Data)
+
+	// list of IDs comes from JSON as well
+	result, err = s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"ids": []interface{}{float64(1)}}}, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, "admin", result.Data[0].(store.User).Login)
+
+	_, err = s.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"1=1 OR login": "foo"}}, false)
+	assertInvalidKey(t, err)
+
+	_, err = s.FindUsers(ctx, engine.QueryFilter{Sort: []string{"login; DROP TABLE users", "asc"}}, false)
+	assertInvalidKey(t, err)
+
+	_, err = s.FindUsers(ctx, engine.QueryFilter{Sort: []string{"login", "sideways"}}, false)
+	assertInvalidKey(t, err)
+}
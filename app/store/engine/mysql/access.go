@@ -0,0 +1,183 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// CreateAccess create a new user access record
+func (m *MySQL) CreateAccess(ctx context.Context, access *store.Access) (err error) {
+
+	var emptyParams []string
+
+	// check required parameters filled
+	if access.Name == "" {
+		emptyParams = append(emptyParams, "Name")
+	}
+
+	if access.Type == "" {
+		emptyParams = append(emptyParams, "Type")
+	}
+
+	if access.ResourceName == "" {
+		emptyParams = append(emptyParams, "Resource name")
+	}
+
+	if access.Action == "" {
+		emptyParams = append(emptyParams, "Action")
+	}
+
+	if access.Owner == 0 {
+		emptyParams = append(emptyParams, "Owner")
+	}
+
+	if len(emptyParams) > 0 {
+		return fmt.Errorf("required access fields not set: %s", strings.Join(emptyParams, ", "))
+	}
+
+	if access.Effect == "" {
+		access.Effect = store.AccessEffectAllow
+	}
+
+	createAccessSQL := fmt.Sprintf("INSERT INTO `%s` (owner_id, is_group, name, resource_type, resource_name, action, disabled, not_before, expires_at, effect) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", accessTable)
+
+	result, err := m.conn(ctx).ExecContext(ctx, createAccessSQL, access.Owner, access.IsGroup, access.Name, access.Type, access.ResourceName, access.Action, access.Disabled,
+		access.NotBefore, access.ExpiresAt, access.Effect)
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to add new access")
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		access.ID = id
+	}
+	return err
+}
+
+// GetAccess get data of access entry by ID
+func (m *MySQL) GetAccess(ctx context.Context, id int64) (access store.Access, err error) {
+
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` WHERE id = ?", accessColumns, accessTable)
+	err = m.conn(ctx).QueryRowContext(ctx, queryString, id).
+		Scan(&access.ID, &access.Owner, &access.IsGroup, &access.Name, &access.Type, &access.ResourceName, &access.Action, &access.Disabled,
+			&access.NotBefore, &access.ExpiresAt, &access.Effect)
+	if errors.Is(err, sql.ErrNoRows) {
+		return access, fmt.Errorf("access %w", engine.ErrNotFound)
+	}
+	if err != nil {
+		return access, errors.Wrap(err, "failed scan access data")
+	}
+	return access, nil
+}
+
+// FindAccesses get list of existed users access
+func (m *MySQL) FindAccesses(ctx context.Context, filter engine.QueryFilter) (accesses engine.ListResponse, err error) {
+	f, err := filtersBuilder(accessTable, filter, "name", "resource_name")
+	if err != nil {
+		return accesses, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` %s", accessColumns, accessTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	accesses.Data = []interface{}{}
+	if accesses.Total = m.getTotalRecordsExcludeRange(ctx, accessTable, filter, []string{"name", "resource_name"}); accesses.Total == 0 {
+		return accesses, nil // may be error handler catch
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return accesses, errors.Wrap(err, "failed to get access list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var tmpAccess store.Access
+		if err = rows.Scan(&tmpAccess.ID, &tmpAccess.Owner, &tmpAccess.IsGroup, &tmpAccess.Name, &tmpAccess.Type, &tmpAccess.ResourceName, &tmpAccess.Action, &tmpAccess.Disabled,
+			&tmpAccess.NotBefore, &tmpAccess.ExpiresAt, &tmpAccess.Effect); err != nil {
+			return accesses, errors.Wrap(err, "failed scan access data")
+		}
+		accesses.Data = append(accesses.Data, tmpAccess)
+	}
+
+	return accesses, rows.Err()
+}
+
+// UpdateAccess will update access record
+func (m *MySQL) UpdateAccess(ctx context.Context, access store.Access) (err error) {
+
+	if access.Effect == "" {
+		access.Effect = store.AccessEffectAllow
+	}
+
+	// fields order: owner_id, is_group, name, resource_type, resource_name, action, disabled, not_before, expires_at, effect
+	res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET owner_id=?, is_group=?, name=?, resource_type=?, resource_name=?, action=?, disabled=?, "+
+		"not_before=?, expires_at=?, effect=? WHERE id = ?", accessTable),
+		access.Owner, access.IsGroup, access.Name, access.Type, access.ResourceName, access.Action, access.Disabled, access.NotBefore, access.ExpiresAt,
+		access.Effect, access.ID)
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to update access data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("record access didn't update")
+	}
+
+	return nil
+}
+
+// DeleteAccess delete access record by ID
+func (m *MySQL) DeleteAccess(ctx context.Context, key string, id interface{}) (err error) {
+
+	if !isAllowedField(accessTable, key) {
+		return &engine.InvalidKeyError{Key: key}
+	}
+
+	//nolint:gosec // key value checked above and can't contain anything except a column name
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE %s = ?", accessTable, key)
+	res, err := m.conn(ctx).ExecContext(ctx, query, castValue(key, id))
+	if err != nil {
+		return errors.Wrapf(err, "failed execute query for access delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteOwnerAccesses deletes all accesses of a user or a group
+func (m *MySQL) DeleteOwnerAccesses(ctx context.Context, ownerID int64, isGroup bool) error {
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE owner_id = ? AND is_group = ?", accessTable)
+	_, err := m.conn(ctx).ExecContext(ctx, query, ownerID, isGroup)
+	return errors.Wrap(err, "failed execute query for delete accesses of owner")
+}
+
+// AccessGarbageCollector deletes accesses to repositories which don't exist in repositories table anymore
+func (m *MySQL) AccessGarbageCollector(ctx context.Context) error {
+	// patterns and resources of other types never equal names of repositories, they are kept until they're deleted with API,
+	// deny rules are kept too, they can be made before a repository is pushed and access shouldn't open up without them
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE resource_type = ? AND effect <> ? AND resource_name NOT LIKE '%%*%%' "+
+		"AND resource_name NOT IN (SELECT repository_name FROM `%s`)", accessTable, repositoriesTable)
+	if _, err := m.conn(ctx).ExecContext(ctx, query, store.RepositoryResourceType, store.AccessEffectDeny); err != nil {
+		return errors.Wrapf(err, "failed execute query for execute garbage collector for access ")
+	}
+	return nil
+}
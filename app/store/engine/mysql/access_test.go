@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestMySQL_Accesses(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	assert.EqualError(t, db.CreateAccess(ctx, &store.Access{}), "required access fields not set: Name, Type, Resource name, Action, Owner")
+
+	access := &store.Access{Name: "pull_access", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"}
+	require.NoError(t, db.CreateAccess(ctx, access))
+	assert.Greater(t, access.ID, int64(0))
+	assert.ErrorIs(t, db.CreateAccess(ctx, &store.Access{Name: "dup", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"}), engine.ErrDuplicateEntry)
+
+	groupAccess := &store.Access{Name: "push_access", Owner: 3, IsGroup: true, Type: "repository", ResourceName: "foo/baz", Action: "push", Disabled: true}
+	require.NoError(t, db.CreateAccess(ctx, groupAccess))
+
+	result, err := db.GetAccess(ctx, groupAccess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, *groupAccess, result)
+
+	_, err = db.GetAccess(ctx, -1)
+	assert.Error(t, err)
+
+	// filter values as they come from token request and from JSON
+	accesses, err := db.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{
+		"owner_id":      int64(2),
+		"resource_type": "repository",
+		"resource_name": "foo/bar",
+		"action":        []string{"pull"},
+		"disabled":      false,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), accesses.Total)
+	assert.Equal(t, *access, accesses.Data[0])
+
+	accesses, err = db.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"disabled": float64(1), "is_group": float64(1)}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), accesses.Total)
+
+	accesses, err = db.FindAccesses(ctx, engine.QueryFilter{Filters: map[string]interface{}{"owner_id": engine.AnonymousUserID}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), accesses.Total)
+	assert.Empty(t, accesses.Data)
+
+	access.Action = "push"
+	require.NoError(t, db.UpdateAccess(ctx, *access))
+	result, err = db.GetAccess(ctx, access.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "push", result.Action)
+	assert.Error(t, db.UpdateAccess(ctx, store.Access{ID: -1}))
+
+	assert.NoError(t, db.DeleteAccess(ctx, "owner_id", access.Owner))
+	assert.ErrorIs(t, db.DeleteAccess(ctx, "id", access.ID), engine.ErrNotFound)
+	assert.Error(t, db.DeleteAccess(ctx, "id = 1 OR 1", 1))
+
+	// access to not existed repository should be deleted by garbage collector
+	require.NoError(t, db.CreateRepository(ctx, &store.RegistryEntry{RepositoryName: "foo/baz", Tag: "latest",
+		Digest: "sha256:1", ConfigDigest: "sha256:2", Timestamp: time.Now().Unix()}))
+	require.NoError(t, db.CreateAccess(ctx, &store.Access{Name: "outdated", Owner: 2, Type: "repository", ResourceName: "foo/outdated", Action: "pull"}))
+	require.NoError(t, db.AccessGarbageCollector(ctx))
+
+	accesses, err = db.FindAccesses(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), accesses.Total)
+	assert.Equal(t, groupAccess.ID, accesses.Data[0].(store.Access).ID)
+
+	ctxCancel()
+	wg.Wait()
+}
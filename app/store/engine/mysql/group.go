@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+var (
+
+	// ErrRequiredFieldInGroupIsEmpty report about group name shouldn't be empty
+	ErrRequiredFieldInGroupIsEmpty = errors.New("empty group name not allowed")
+
+	// ErrFailedToCreateGroup report about error when group creating
+	ErrFailedToCreateGroup = errors.New("failed to create new group")
+)
+
+// CreateGroup create a new group record
+func (m *MySQL) CreateGroup(ctx context.Context, group *store.Group) (err error) {
+
+	// check required parameters filled
+	if group.Name == "" {
+		return ErrRequiredFieldInGroupIsEmpty
+	}
+
+	createGroupSQL := fmt.Sprintf("INSERT INTO `%s` (name, description) VALUES (?, ?)", groupsTable)
+
+	result, err := m.conn(ctx).ExecContext(ctx, createGroupSQL, group.Name, group.Description)
+	if err != nil {
+		return checkDuplicateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		group.ID = id
+	}
+	return err
+}
+
+// GetGroup get data by group ID
+func (m *MySQL) GetGroup(ctx context.Context, groupID int64) (group store.Group, err error) {
+
+	queryString := fmt.Sprintf("SELECT id,name,description FROM `%s` WHERE id = ?", groupsTable)
+	err = m.conn(ctx).QueryRowContext(ctx, queryString, groupID).Scan(&group.ID, &group.Name, &group.Description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return group, fmt.Errorf("group %w", engine.ErrNotFound)
+	}
+	if err != nil {
+		return group, errors.Wrap(err, "failed scan group data")
+	}
+	return group, nil
+}
+
+// FindGroups fetch list of existed group
+func (m *MySQL) FindGroups(ctx context.Context, filter engine.QueryFilter) (groups engine.ListResponse, err error) {
+	f, err := filtersBuilder(groupsTable, filter, "name") // set key filed for search query
+	if err != nil {
+		return groups, err
+	}
+	queryString := fmt.Sprintf("SELECT id,name,description FROM `%s` %s", groupsTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	if groups.Total = m.getTotalRecordsExcludeRange(ctx, groupsTable, filter, []string{"name"}); groups.Total == 0 {
+		return groups, nil // may be error handler catch
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return groups, errors.Wrap(err, "failed to get groups list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	groups.Data = []interface{}{}
+	for rows.Next() {
+		var group store.Group
+		if err = rows.Scan(&group.ID, &group.Name, &group.Description); err != nil {
+			return groups, errors.Wrap(err, "failed scan group data")
+		}
+		groups.Data = append(groups.Data, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// UpdateGroup update group records data
+func (m *MySQL) UpdateGroup(ctx context.Context, group store.Group) (err error) {
+	queryString := fmt.Sprintf("UPDATE `%s` SET name=?, description=? WHERE id = ?", groupsTable)
+	res, err := m.conn(ctx).ExecContext(ctx, queryString, group.Name, group.Description, group.ID)
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to update group data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("record didn't update")
+	}
+
+	return nil
+}
+
+// DeleteGroup delete users group record by ID, membership of users in the group is deleted too
+func (m *MySQL) DeleteGroup(ctx context.Context, id int64) (err error) {
+	return m.WithTx(ctx, func(ctx context.Context) error {
+		res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE id = ?", groupsTable), id)
+		if err != nil {
+			return errors.Wrapf(err, "failed execute query for group delete")
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return engine.ErrNotFound
+		}
+
+		_, err = m.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE group_id = ?", groupMembersTable), id)
+		return errors.Wrap(err, "failed to delete group members")
+	})
+}
+
+// AddGroupMember add a user to a users group
+func (m *MySQL) AddGroupMember(ctx context.Context, member store.GroupMember) (err error) {
+	queryString := fmt.Sprintf("INSERT INTO `%s` (group_id, user_id) VALUES (?, ?)", groupMembersTable)
+	if _, err = m.conn(ctx).ExecContext(ctx, queryString, member.GroupID, member.UserID); err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to add group member")
+	}
+	return nil
+}
+
+// FindGroupMembers fetch list of groups membership by filter values
+func (m *MySQL) FindGroupMembers(ctx context.Context, filter engine.QueryFilter) (members engine.ListResponse, err error) {
+	f, err := filtersBuilder(groupMembersTable, filter)
+	if err != nil {
+		return members, err
+	}
+	queryString := fmt.Sprintf("SELECT group_id,user_id FROM `%s` %s", groupMembersTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	if members.Total = m.getTotalRecordsExcludeRange(ctx, groupMembersTable, filter, nil); members.Total == 0 {
+		return members, nil
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return members, errors.Wrap(err, "failed to get group members list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	members.Data = []interface{}{}
+	for rows.Next() {
+		var member store.GroupMember
+		if err = rows.Scan(&member.GroupID, &member.UserID); err != nil {
+			return members, errors.Wrap(err, "failed scan group member data")
+		}
+		members.Data = append(members.Data, member)
+	}
+
+	return members, rows.Err()
+}
+
+// DeleteGroupMember remove a user from a users group
+func (m *MySQL) DeleteGroupMember(ctx context.Context, member store.GroupMember) (err error) {
+	queryString := fmt.Sprintf("DELETE FROM `%s` WHERE group_id = ? AND user_id = ?", groupMembersTable)
+	res, err := m.conn(ctx).ExecContext(ctx, queryString, member.GroupID, member.UserID)
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for group member delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// addPrimaryGroupMember keeps a user as a member of own primary group, an existed membership isn't an error
+func (m *MySQL) addPrimaryGroupMember(ctx context.Context, user store.User) error {
+	if user.Group <= 0 {
+		return nil
+	}
+	queryString := fmt.Sprintf("INSERT IGNORE INTO `%s` (group_id, user_id) VALUES (?, ?)", groupMembersTable)
+	_, err := m.conn(ctx).ExecContext(ctx, queryString, user.Group, user.ID)
+	return errors.Wrap(err, "failed to add user to the primary group")
+}
@@ -0,0 +1,55 @@
+package mysql
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestMySQL_Groups(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	assert.Equal(t, ErrRequiredFieldInGroupIsEmpty, db.CreateGroup(ctx, &store.Group{}))
+
+	group := &store.Group{Name: "developers", Description: "developers group"}
+	require.NoError(t, db.CreateGroup(ctx, group))
+	assert.Greater(t, group.ID, int64(1))
+	assert.ErrorIs(t, db.CreateGroup(ctx, &store.Group{Name: "developers"}), engine.ErrDuplicateEntry)
+
+	result, err := db.GetGroup(ctx, group.ID)
+	require.NoError(t, err)
+	assert.Equal(t, *group, result)
+
+	_, err = db.GetGroup(ctx, -1)
+	assert.Error(t, err)
+
+	// check that a value with quotes doesn't break the query
+	group.Description = "it's updated"
+	require.NoError(t, db.UpdateGroup(ctx, *group))
+	result, err = db.GetGroup(ctx, group.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "it's updated", result.Description)
+	assert.Error(t, db.UpdateGroup(ctx, store.Group{ID: -1, Name: "unknown"}))
+
+	groups, err := db.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "dev"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), groups.Total)
+	assert.Equal(t, *group, groups.Data[0])
+
+	groups, err = db.FindGroups(ctx, engine.QueryFilter{Filters: map[string]interface{}{"ids": []interface{}{float64(1), float64(group.ID)}}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), groups.Total)
+
+	assert.NoError(t, db.DeleteGroup(ctx, group.ID))
+	assert.ErrorIs(t, db.DeleteGroup(ctx, group.ID), engine.ErrNotFound)
+
+	ctxCancel()
+	wg.Wait()
+}
@@ -0,0 +1,397 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const schemaVersionTable = "schema_version"
+
+// migration defines a single step of a database schema change
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, q sqlConn) error
+}
+
+// migrations is an ordered list of schema changes. A new change should be appended to the end of list with the next
+// version number, already released migrations mustn't be changed because they never run again for existing databases.
+// MySQL commits DDL statements implicitly and a migration can't be rolled back, so every migration must be idempotent
+// and can be safely repeated after a failure. Databases created before versioning was introduced don't have any records
+// in the schema_version table and all migrations are applied to their existing tables.
+var migrations = []migration{
+	{version: 1, description: "create initial tables", up: initialSchema},
+	{version: 2, description: "add is_group to unique key of access table", up: accessOwnerKindSchema},
+	{version: 3, description: "create group members table", up: groupMembersSchema},
+	{version: 4, description: "create robots table", up: robotsSchema},
+	{version: 5, description: "add sso_id column to users table", up: usersSSOSchema},
+	{version: 6, description: "add totp columns to users table", up: usersTOTPSchema},
+	{version: 7, description: "create refresh tokens table", up: refreshTokensSchema},
+	{version: 8, description: "add validity columns to access table", up: accessValiditySchema},
+	{version: 9, description: "add effect column to access table", up: accessEffectSchema},
+	{version: 10, description: "create roles table", up: rolesSchema},
+}
+
+// migrate applies all migrations which version is greater than the current database schema version,
+// the schema version is updated after each successful migration
+func (m *MySQL) migrate(ctx context.Context) error {
+	sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"version INT PRIMARY KEY,"+
+		"description TEXT,"+
+		"applied_at BIGINT)", schemaVersionTable)
+
+	if _, err := m.db.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", schemaVersionTable)
+	}
+
+	currentVersion, err := m.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mg := range migrations {
+		if mg.version <= currentVersion {
+			continue
+		}
+
+		if err = mg.up(ctx, m.db); err != nil {
+			return errors.Wrapf(err, "failed to apply schema migration %d (%s)", mg.version, mg.description)
+		}
+
+		_, err = m.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO `%s` (version, description, applied_at) VALUES (?, ?, ?)", schemaVersionTable),
+			mg.version, mg.description, time.Now().Unix())
+		if err != nil {
+			return errors.Wrapf(err, "failed to update schema version to %d", mg.version)
+		}
+		log.Printf("[INFO] schema migration %d applied: %s", mg.version, mg.description)
+	}
+	return nil
+}
+
+// schemaVersion returns the latest applied migration version, zero means a database hasn't any versioned changes yet
+func (m *MySQL) schemaVersion(ctx context.Context) (version int, err error) {
+	row := m.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM `%s`", schemaVersionTable))
+	if err = row.Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "failed to get schema version")
+	}
+	return version, nil
+}
+
+// initialSchema creates tables with default records, the existing tables of databases created
+// by previous versions are kept as is
+func initialSchema(ctx context.Context, q sqlConn) error {
+	if err := createGroupsTable(ctx, q); err != nil {
+		return err
+	}
+
+	if err := createUsersTable(ctx, q); err != nil {
+		return err
+	}
+
+	sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"owner_id BIGINT NOT NULL,"+
+		"is_group BOOLEAN,"+
+		"name TEXT,"+
+		"resource_type VARCHAR(64),"+
+		"resource_name VARCHAR(255),"+
+		"action VARCHAR(64),"+
+		"disabled BOOLEAN,"+
+		"UNIQUE(owner_id,resource_type,resource_name,action))", accessTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", accessTable)
+	}
+
+	sqlText = fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"repository_name VARCHAR(255) NOT NULL CHECK(repository_name <> ''),"+
+		"tag VARCHAR(255) NOT NULL CHECK(tag <> ''),"+
+		"digest VARCHAR(255) NOT NULL CHECK(digest <> ''),"+
+		"config_digest VARCHAR(255) NOT NULL CHECK(config_digest <> ''),"+
+		"size BIGINT,"+
+		"pull_counter BIGINT,"+
+		"timestamp BIGINT,"+
+		"raw LONGTEXT,"+
+		"UNIQUE(repository_name,tag))", repositoriesTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", repositoriesTable)
+	}
+	return nil
+}
+
+func createGroupsTable(ctx context.Context, q sqlConn) error {
+	if exist, err := isTableExist(ctx, q, groupsTable); err != nil || exist {
+		return err
+	}
+
+	sqlText := fmt.Sprintf("CREATE TABLE `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"name VARCHAR(255) UNIQUE,"+
+		"description TEXT)", groupsTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", groupsTable)
+	}
+
+	// create default admin group when a new database creation
+	group := store.Group{
+		Name:        "default",
+		Description: "Default administration group",
+	}
+
+	createGroupSQL := fmt.Sprintf("INSERT IGNORE INTO `%s` (name, description) VALUES (?, ?)", groupsTable)
+	if _, err := q.ExecContext(ctx, createGroupSQL, group.Name, group.Description); err != nil {
+		return errors.Wrapf(err, "failed to insert default group to %s table", groupsTable)
+	}
+	return nil
+}
+
+func createUsersTable(ctx context.Context, q sqlConn) error {
+	if exist, err := isTableExist(ctx, q, usersTable); err != nil || exist {
+		return err
+	}
+
+	sqlText := fmt.Sprintf("CREATE TABLE `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"login VARCHAR(255) UNIQUE,"+
+		"name TEXT,"+
+		"password TEXT,"+
+		"role VARCHAR(64),"+
+		"user_group BIGINT,"+
+		"disabled BOOLEAN,"+
+		"description TEXT)", usersTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", usersTable)
+	}
+
+	defaultPassword := engine.GetAdminDefaultPassword(ctx)
+	if defaultPassword == "" {
+		defaultPassword = "admin"
+	}
+
+	// create default admin user if new database creation
+	user := store.User{
+		Login:       "admin", // is the default login
+		Name:        "admin",
+		Password:    defaultPassword,
+		Role:        "admin",
+		Group:       1,
+		Description: "Default user with administration role ",
+	}
+
+	// hashing user password
+	if errHash := user.HashAndSalt(); errHash != nil {
+		return errHash
+	}
+
+	createUserSQL := fmt.Sprintf("INSERT IGNORE INTO `%s` (login, name, password, role, user_group, disabled, description) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?)", usersTable)
+	_, err := q.ExecContext(ctx, createUserSQL, user.Login, user.Name, user.Password, user.Role, user.Group, false, user.Description)
+	if err != nil {
+		return errors.Wrapf(err, "failed to insert new default user to %s table", usersTable)
+	}
+	return nil
+}
+
+// accessOwnerKindSchema makes rules of a user and a group with the same id independent of each other,
+// before that a group rule conflicted with a rule of the user which has the same id as the group
+func accessOwnerKindSchema(ctx context.Context, q sqlConn) error {
+	return replaceAccessUniqueKey(ctx, q, "owner_id,is_group,resource_type,resource_name,action")
+}
+
+// groupMembersSchema creates a table for many-to-many relations between users and groups
+// and copies the single group of every existed user to it
+func groupMembersSchema(ctx context.Context, q sqlConn) error {
+	sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"group_id BIGINT NOT NULL,"+
+		"user_id BIGINT NOT NULL,"+
+		"UNIQUE(group_id,user_id),"+
+		"INDEX(user_id))", groupMembersTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", groupMembersTable)
+	}
+
+	sqlText = fmt.Sprintf("INSERT IGNORE INTO `%s` (group_id, user_id) SELECT user_group, id FROM `%s` WHERE user_group > 0",
+		groupMembersTable, usersTable)
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to copy users groups to %s table", groupMembersTable)
+	}
+	return nil
+}
+
+// robotsSchema creates a table for robot accounts
+func robotsSchema(ctx context.Context, q sqlConn) error {
+	sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"name VARCHAR(255) NOT NULL UNIQUE CHECK(name <> ''),"+
+		"description TEXT,"+
+		"secret VARCHAR(255) NOT NULL,"+
+		"disabled BOOLEAN,"+
+		"expires_at BIGINT,"+
+		"created_at BIGINT,"+
+		"permissions LONGTEXT)", robotsTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", robotsTable)
+	}
+	return nil
+}
+
+// usersSSOSchema adds a column with the identity of users at single sign-on providers, local users have it empty
+func usersSSOSchema(ctx context.Context, q sqlConn) error {
+	return addColumn(ctx, q, usersTable, "sso_id", "VARCHAR(255) NOT NULL DEFAULT ''")
+}
+
+// usersTOTPSchema adds columns with two-factor authentication settings of users
+func usersTOTPSchema(ctx context.Context, q sqlConn) error {
+	columns := []struct{ name, definition string }{
+		{"totp_enabled", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"totp_secret", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"totp_recovery_codes", "VARCHAR(2048) NOT NULL DEFAULT ''"},
+		{"totp_last_step", "BIGINT NOT NULL DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		if err := addColumn(ctx, q, usersTable, c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshTokensSchema creates a table for refresh tokens of registry clients
+func refreshTokensSchema(ctx context.Context, q sqlConn) error {
+	sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"user_id BIGINT NOT NULL,"+
+		"client_id VARCHAR(255),"+
+		"hash VARCHAR(64) NOT NULL UNIQUE CHECK(hash <> ''),"+
+		"created_at BIGINT,"+
+		"expires_at BIGINT,"+
+		"last_used_at BIGINT,"+
+		"INDEX(user_id))", refreshTokensTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", refreshTokensTable)
+	}
+	return nil
+}
+
+// accessValiditySchema adds columns with validity window of access rules, existed rules aren't limited
+func accessValiditySchema(ctx context.Context, q sqlConn) error {
+	for _, column := range []string{"not_before", "expires_at"} {
+		if err := addColumn(ctx, q, accessTable, column, "BIGINT NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// accessEffectSchema adds a column with effect of access rules, existed rules allow actions. The effect is a part
+// of the unique key, so an allow rule and a deny rule can be set for the same owner, resource and action.
+func accessEffectSchema(ctx context.Context, q sqlConn) error {
+	err := addColumn(ctx, q, accessTable, "effect", fmt.Sprintf("VARCHAR(16) NOT NULL DEFAULT '%s'", store.AccessEffectAllow))
+	if err != nil {
+		return err
+	}
+	return replaceAccessUniqueKey(ctx, q, "owner_id,is_group,resource_type,resource_name,action,effect")
+}
+
+// rolesSchema creates a table for roles of users, built-in roles are added at every start of storage
+func rolesSchema(ctx context.Context, q sqlConn) error {
+	sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`("+
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY,"+
+		"name VARCHAR(255) NOT NULL UNIQUE CHECK(name <> ''),"+
+		"description TEXT,"+
+		"built_in BOOLEAN NOT NULL DEFAULT FALSE,"+
+		"permissions LONGTEXT)", rolesTable)
+
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", rolesTable)
+	}
+	return nil
+}
+
+// replaceAccessUniqueKey drops the unique key of the access table, created with the table or by a previous migration,
+// and creates a new one with the columns. The key which created with the table is named by its first column.
+func replaceAccessUniqueKey(ctx context.Context, q sqlConn, columns string) error {
+	for _, index := range []string{"owner_id", "access_rule"} {
+		exist, err := isIndexExist(ctx, q, accessTable, index)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			continue
+		}
+
+		if _, err = q.ExecContext(ctx, fmt.Sprintf("ALTER TABLE `%s` DROP INDEX `%s`", accessTable, index)); err != nil {
+			return errors.Wrapf(err, "failed to drop %s index of %s table", index, accessTable)
+		}
+	}
+
+	sqlText := fmt.Sprintf("ALTER TABLE `%s` ADD UNIQUE KEY `access_rule` (%s)", accessTable, columns)
+	if _, err := q.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to change unique key of %s table", accessTable)
+	}
+	return nil
+}
+
+func isIndexExist(ctx context.Context, q sqlConn, tableName, indexName string) (bool, error) {
+	var count int64
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		tableName, indexName,
+	).Scan(&count)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't check for %s index of %s table exist", indexName, tableName)
+	}
+	return count > 0, nil
+}
+
+// addColumn adds a column to a table unless it exists, MySQL doesn't support 'ADD COLUMN IF NOT EXISTS' clause
+func addColumn(ctx context.Context, q sqlConn, tableName, columnName, definition string) error {
+	exist, err := isColumnExist(ctx, q, tableName, columnName)
+	if err != nil || exist {
+		return err
+	}
+
+	if _, err = q.ExecContext(ctx, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s %s", tableName, columnName, definition)); err != nil {
+		return errors.Wrapf(err, "failed to add %s column to %s table", columnName, tableName)
+	}
+	return nil
+}
+
+func isColumnExist(ctx context.Context, q sqlConn, tableName, columnName string) (bool, error) {
+	var count int64
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+		tableName, columnName,
+	).Scan(&count)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't check for %s column of %s table exist", columnName, tableName)
+	}
+	return count > 0, nil
+}
+
+func isTableExist(ctx context.Context, q sqlConn, tableName string) (bool, error) {
+	var count int64
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+		tableName,
+	).Scan(&count)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't check for %s table exist", tableName)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestMySQL_migrate(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
+
+	db := prepareTestDB(ctx, t, wg)
+
+	version, err := db.schemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+
+	// repeated migration shouldn't change anything
+	require.NoError(t, db.migrate(ctx))
+	assertDefaultRecords(ctx, t, db)
+
+	// a database created before schema versioning has tables but doesn't have any version records
+	_, err = db.db.Exec(fmt.Sprintf("DROP TABLE `%s`", schemaVersionTable))
+	require.NoError(t, err)
+	require.NoError(t, db.migrate(ctx))
+	assertDefaultRecords(ctx, t, db)
+
+	version, err = db.schemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+}
+
+func assertDefaultRecords(ctx context.Context, t *testing.T, db *MySQL) {
+	users, err := db.FindUsers(ctx, engine.QueryFilter{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), users.Total)
+
+	groups, err := db.FindGroups(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), groups.Total)
+}
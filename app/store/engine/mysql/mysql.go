@@ -0,0 +1,422 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// Table names are quoted in queries with backticks, because 'groups' is a reserved word since MySQL 8.0
+const (
+	usersTable         = "users"
+	groupsTable        = "groups"
+	accessTable        = "access"
+	repositoriesTable  = "repositories"
+	groupMembersTable  = "group_members"
+	robotsTable        = "robots"
+	refreshTokensTable = "refresh_tokens"
+	rolesTable         = "roles"
+
+	// duplicateEntryCode is the MySQL error number for a unique key violation
+	duplicateEntryCode = 1062
+
+	// accessColumns lists columns of access table in order which they're scanned to store.Access
+	accessColumns = "id,owner_id,is_group,name,resource_type,resource_name,action,disabled,not_before,expires_at,effect"
+
+	// currentUnixTime is SQL expression of the current unix time, validity window of rules is checked by database time
+	currentUnixTime = "UNIX_TIMESTAMP()"
+)
+
+var (
+	// boolFields lists columns which have boolean type, values for ones cast to bool before pass to query
+	boolFields = map[string]bool{"disabled": true, "is_group": true, "built_in": true}
+
+	// userAccessCondition is the condition of engine.RepositoriesByUserAccess filter, weights of patterns are compared
+	// with SQL expressions because there are no user functions
+	userAccessCondition = fmt.Sprintf("(EXISTS (%[1]s AND NOT EXISTS (%[2]s AND %[6]s > %[7]s) "+
+		"AND NOT EXISTS (%[3]s AND action IN ('%[5]s', allowed.action))) AND NOT EXISTS (%[4]s AND action IN ('%[5]s', 'pull')))",
+		matchingRulesQuery("allowed", store.AccessEffectAllow, "owner_id = ?"),
+		matchingRulesQuery("specific", store.AccessEffectAllow, "owner_id = ?"),
+		matchingRulesQuery("denied_action", store.AccessEffectDeny, deniedOwnersCondition),
+		matchingRulesQuery("denied", store.AccessEffectDeny, deniedOwnersCondition),
+		store.ActionAll, resourceSpecificity("resource_name"), resourceSpecificity("allowed.resource_name"))
+
+	// deniedOwnersCondition defines owners of deny rules which apply to a user besides the user groups: the user itself
+	// and owners of rules shared for all users
+	deniedOwnersCondition = fmt.Sprintf("owner_id IN (?, %d, %d)", engine.RegisteredUserID, engine.AnonymousUserID)
+)
+
+// matchingRulesQuery returns a query of enabled rules with the effect which match a repository within their validity
+// window, rules belong to owners which the condition defines or to groups of the user. Resource names with wildcards
+// are converted to regular expressions the same way as store.MatchResource does, a pattern can't contain regular
+// expression characters except dots, they're matched with a character class. The alias allows nested queries to refer
+// to the rules of the query.
+func matchingRulesQuery(alias, effect, ownerCondition string) string {
+	return fmt.Sprintf("SELECT 1 FROM `%[1]s` AS %[8]s WHERE effect = '%[6]s' AND NOT disabled AND "+
+		"((%[7]s AND NOT is_group) OR (is_group AND owner_id IN (SELECT group_id FROM `%[2]s` WHERE user_id = ?))) "+
+		"AND not_before <= %[5]s AND (expires_at = 0 OR expires_at > %[5]s) "+
+		"AND (resource_name = `%[3]s`.repository_name OR resource_name = '%[4]s' OR "+
+		"(resource_name LIKE '%%*%%' AND `%[3]s`.repository_name REGEXP CONCAT('^', "+
+		"REPLACE(REPLACE(REPLACE(REPLACE(resource_name, '.', '[.]'), '**', '#'), '*', '[^/]*'), '#', '.*'), '$')))",
+		accessTable, groupMembersTable, repositoriesTable, store.ResourceAnyName, currentUnixTime, effect, ownerCondition, alias)
+}
+
+// resourceSpecificity returns SQL expression of the weight of a resource name column, it's calculated the same way
+// as store.ResourceSpecificity does: an exact name is the most specific, patterns are weighted by number of literal
+// characters and the namespace wildcard decreases a weight by one
+func resourceSpecificity(column string) string {
+	return fmt.Sprintf("(CASE WHEN %[1]s NOT LIKE '%%*%%' THEN %[2]d "+
+		"ELSE 2 * CHAR_LENGTH(REPLACE(%[1]s, '*', '')) - (CASE WHEN %[1]s LIKE '%%**%%' THEN 1 ELSE 0 END) END)",
+		column, math.MaxInt32)
+}
+
+// allowedFields defines columns of each table which can be used in filter, sort and update clauses,
+// any other keys are rejected
+var allowedFields = map[string]map[string]bool{
+	usersTable: {
+		"id": true, "login": true, "name": true, "role": true, "user_group": true, "disabled": true, "description": true,
+	},
+	groupsTable: {
+		"id": true, "name": true, "description": true,
+	},
+	accessTable: {
+		"id": true, "owner_id": true, "is_group": true, "name": true, "resource_type": true, "resource_name": true,
+		"action": true, "disabled": true, "not_before": true, "expires_at": true, "effect": true,
+	},
+	repositoriesTable: {
+		"id": true, "repository_name": true, "tag": true, "digest": true, "config_digest": true, "size": true,
+		"pull_counter": true, "timestamp": true, "raw": true,
+	},
+	groupMembersTable: {
+		"id": true, "group_id": true, "user_id": true,
+	},
+	robotsTable: {
+		"id": true, "name": true, "description": true, "disabled": true, "expires_at": true, "created_at": true,
+	},
+	refreshTokensTable: {
+		"id": true, "user_id": true, "client_id": true, "created_at": true, "expires_at": true, "last_used_at": true,
+	},
+	rolesTable: {
+		"id": true, "name": true, "description": true, "built_in": true,
+	},
+}
+
+// MySQL implement data storage for users, access, group and repositories with MySQL or MariaDB server
+type MySQL struct {
+	DSN string `json:"dsn"`
+	db  *sql.DB
+}
+
+type queryFilter struct {
+	skipLimit  string        // an offset and a limit params
+	order      string        // an order by clause
+	where      string        // where without limit and offset params, return all items when math where clause
+	allClauses string        // where clause with order, skip and limit
+	args       []interface{} // values for placeholders in where clause
+}
+
+// sqlConn is a common part of sql.DB and sql.Tx which storage uses for queries
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txKey is a context key of transaction, it is bound to a database for avoid using a transaction of other storage instance
+type txKey struct {
+	db *sql.DB
+}
+
+// NewMySQL builder for create new instance of mysql storage
+func NewMySQL(dsn string) *MySQL {
+	return &MySQL{DSN: dsn}
+}
+
+// Connect init connection to mysql storage instance and creates tables if they don't exist
+func (m *MySQL) Connect(ctx context.Context) (err error) {
+	if m.DSN == "" {
+		return errors.New("mysql connection string is empty")
+	}
+
+	m.db, err = sql.Open("mysql", m.DSN)
+	if err != nil {
+		return errors.Wrap(err, "failed to open mysql connection")
+	}
+
+	if err = m.db.PingContext(ctx); err != nil {
+		_ = m.db.Close()
+		return errors.Wrap(err, "failed to connect to mysql")
+	}
+
+	// close connection global using context
+	go func() {
+		<-ctx.Done()
+		_ = m.db.Close()
+	}()
+	return m.initTables(ctx)
+}
+
+// initTables applies schema migrations, built-in roles are updated at every start,
+// so a new version applies own permissions of them
+func (m *MySQL) initTables(ctx context.Context) error {
+	if err := m.migrate(ctx); err != nil {
+		return err
+	}
+	return m.updateBuiltInRoles(ctx)
+}
+
+// Close exposed closed method for mysql storage instance
+func (m *MySQL) Close(_ context.Context) error {
+	if m.db == nil {
+		return nil // connection wasn't opened
+	}
+	return m.db.Close()
+}
+
+// WithTx runs fn within a transaction, all storage calls with context which passed to fn are parts of it.
+// A nested call joins to the transaction already started.
+func (m *MySQL) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	key := txKey{db: m.db}
+	if _, ok := ctx.Value(key).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, key, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit transaction")
+}
+
+// conn returns a transaction which bound to context with WithTx or the database connection pool otherwise
+func (m *MySQL) conn(ctx context.Context) sqlConn {
+	if tx, ok := ctx.Value(txKey{db: m.db}).(*sql.Tx); ok {
+		return tx
+	}
+	return m.db
+}
+
+// filtersBuilder parse an engine filter values and build query filter for 'mysql' implementation.
+// Values are never formatted into a query string, they are passed to query as positional parameters, and all keys
+// of filter and sort params are checked against list of allowed fields of the table.
+func filtersBuilder(tableName string, filter engine.QueryFilter, fieldsName ...string) (f queryFilter, err error) {
+
+	if filter.Range[1] > 0 {
+		f.skipLimit = fmt.Sprintf(" LIMIT %d", filter.Range[1]-filter.Range[0])
+	}
+
+	if filter.Range[0] > 0 {
+		if f.skipLimit == "" {
+			// MySQL doesn't support OFFSET without LIMIT, the maximum value of BIGINT UNSIGNED uses instead
+			f.skipLimit = " LIMIT 18446744073709551615"
+		}
+		f.skipLimit = fmt.Sprintf("%s OFFSET %d", f.skipLimit, filter.Range[0])
+	}
+
+	// keys sorted for keep conditions order and order of positional params the same for each call
+	keys := make([]string, 0, len(filter.Filters))
+	for k := range filter.Filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var conditions []string
+	for _, k := range keys {
+		v := filter.Filters[k]
+
+		switch k {
+		case "ids":
+			values, ok := v.([]interface{})
+			if !ok {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+			conditions = append(conditions, inCondition("id", values, &f.args))
+
+		case "q":
+			var likeConditions []string
+			for _, field := range fieldsName {
+				likeConditions = append(likeConditions, fmt.Sprintf("%s LIKE ?", field))
+				f.args = append(f.args, fmt.Sprintf("%%%v%%", v))
+			}
+			if len(likeConditions) > 0 {
+				conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(likeConditions, " OR ")))
+			}
+
+		case engine.RepositoriesByUserAccess:
+			if tableName != repositoriesTable {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+			conditions = append(conditions, userAccessCondition)
+			owner := castValue("owner_id", v)
+			for n := strings.Count(userAccessCondition, "?"); n > 0; n-- {
+				f.args = append(f.args, owner)
+			}
+
+		case engine.AccessExpiresBefore:
+			if tableName != accessTable {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+			conditions = append(conditions, "expires_at > 0 AND expires_at <= ?")
+			f.args = append(f.args, castValue(k, v))
+
+		default:
+			if !isAllowedField(tableName, k) {
+				return f, &engine.InvalidKeyError{Key: k}
+			}
+
+			if values, ok := listValues(v); ok {
+				conditions = append(conditions, inCondition(k, values, &f.args))
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("%s = ?", k))
+			f.args = append(f.args, castValue(k, v))
+		}
+	}
+
+	if len(conditions) > 0 {
+		f.where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sortField, sortOrder := "id", "ASC" // default sorting
+	if len(filter.Sort) == 2 {
+		sortField, sortOrder = filter.Sort[0], strings.ToUpper(filter.Sort[1])
+	}
+
+	if !isAllowedField(tableName, sortField) {
+		return f, &engine.InvalidKeyError{Key: sortField}
+	}
+
+	if sortOrder != "ASC" && sortOrder != "DESC" {
+		return f, &engine.InvalidKeyError{Key: filter.Sort[1]}
+	}
+
+	f.order = fmt.Sprintf(" ORDER BY %s %s", sortField, sortOrder)
+	f.allClauses = f.where + f.order + f.skipLimit
+	return f, nil
+}
+
+// isAllowedField checks that a field is in the list of fields which can be used in queries for the table
+func isAllowedField(tableName, field string) bool {
+	return allowedFields[tableName][field]
+}
+
+// listValues returns values of a filter which passed as a list, JSON arrays have []interface{} type
+// and lists built by the application code can have []string type
+func listValues(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = v[i]
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// inCondition builds IN clause for list of values and appends values to args
+func inCondition(key string, values []interface{}, args *[]interface{}) string {
+	if len(values) == 0 {
+		return "FALSE"
+	}
+
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = "?"
+		*args = append(*args, castValue(key, v))
+	}
+	return fmt.Sprintf("%s IN (%s)", key, strings.Join(placeholders, ", "))
+}
+
+// castValue converts values which come from JSON to types which appropriate for MySQL columns
+func castValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if boolFields[key] {
+			return v != 0
+		}
+		if v == math.Trunc(v) {
+			return int64(v)
+		}
+	case int:
+		if boolFields[key] {
+			return v != 0
+		}
+	case int64:
+		if boolFields[key] {
+			return v != 0
+		}
+	case string:
+		if boolFields[key] {
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case []byte:
+		return string(v)
+	}
+	return value
+}
+
+// checkDuplicateError wraps unique key violation errors with engine.ErrDuplicateEntry
+func checkDuplicateError(err error) error {
+	var mysqlErr *driver.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == duplicateEntryCode {
+		return fmt.Errorf("%w: %s", engine.ErrDuplicateEntry, mysqlErr.Message)
+	}
+	return err
+}
+
+// getTotalRecordExcludeRange return total number of records exclude range/skip clause for pagination support
+//
+//	tableName - specify table name for search
+//	filter - set of params for where clause in query
+//	searchFields - define list of key fields using in where clause
+func (m *MySQL) getTotalRecordsExcludeRange(ctx context.Context, tableName string, filter engine.QueryFilter, searchFields []string) int64 {
+	filter.Range = [2]int64{0, 0} // clear skip/offset range
+
+	// it defines request type for get total records from table with duplicates fields such like repositories
+	countType := "COUNT(*)"
+	if filter.GroupByField && len(searchFields) > 0 {
+		// IMPORTANT: for distinct values gets FIRST item of searchFields list
+		countType = fmt.Sprintf("COUNT(DISTINCT %s)", searchFields[0])
+	}
+
+	f, err := filtersBuilder(tableName, filter, searchFields...)
+	if err != nil {
+		return 0
+	}
+
+	//nolint:gosec // all values passed to query as params
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` %s", countType, tableName, f.where)
+
+	var recordsCounter int64
+	if err = m.conn(ctx).QueryRowContext(ctx, queryString, f.args...).Scan(&recordsCounter); err != nil {
+		return 0
+	}
+	return recordsCounter
+}
@@ -0,0 +1,280 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+	"github.com/zebox/registry-admin/app/store/engine/enginetest"
+)
+
+// testDSNEnv defines environment variable with connection string to MySQL or MariaDB instance for tests with a database,
+// these tests skip when variable not set, e.g. RA_TEST_MYSQL_DSN=root:secret@tcp(localhost:3306)/registry_test
+const testDSNEnv = "RA_TEST_MYSQL_DSN"
+
+func TestMySQL_Connect(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	emptyConn := NewMySQL("")
+	assert.Error(t, emptyConn.Connect(ctx))
+	assert.NoError(t, emptyConn.Close(ctx), "closing of storage which isn't connected shouldn't panic")
+
+	invalidConn := NewMySQL("invalid dsn string")
+	assert.Error(t, invalidConn.Connect(ctx))
+
+	badConn := NewMySQL("user:password@tcp(127.0.0.1:1)/unknown?timeout=1s")
+	assert.Error(t, badConn.Connect(ctx))
+
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg)
+
+	// second connect to initialized database shouldn't return error and create a second admin
+	secondConn := NewMySQL(db.DSN)
+	require.NoError(t, secondConn.Connect(ctx))
+
+	users, err := secondConn.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login": "admin"}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), users.Total)
+
+	groups, err := secondConn.FindGroups(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), groups.Total)
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestFilterBuilder(t *testing.T) {
+	testFilters := []struct {
+		table  string
+		filter engine.QueryFilter
+		fields []string
+		query  string
+		args   []interface{}
+		err    bool
+	}{
+		{
+			table:  usersTable,
+			filter: engine.QueryFilter{},
+			query:  " ORDER BY id ASC",
+		},
+		{
+			table: accessTable,
+			filter: engine.QueryFilter{
+				Range:   [2]int64{10, 20},
+				IDs:     nil,
+				Filters: map[string]interface{}{"q": "foo", "disabled": float64(1), "owner_id": float64(3)},
+				Sort:    []string{"name", "desc"},
+			},
+			fields: []string{"name", "resource_name"},
+			query:  "WHERE disabled = ? AND owner_id = ? AND (name LIKE ? OR resource_name LIKE ?) ORDER BY name DESC LIMIT 10 OFFSET 10",
+			args:   []interface{}{true, int64(3), "%foo%", "%foo%"},
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Range: [2]int64{5, 0},
+			},
+			query: " ORDER BY id ASC LIMIT 18446744073709551615 OFFSET 5",
+		},
+		{
+			table: accessTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"ids": []interface{}{float64(1), float64(2)}, "action": []string{"pull", "push"}},
+			},
+			query: "WHERE action IN (?, ?) AND id IN (?, ?) ORDER BY id ASC",
+			args:  []interface{}{"pull", "push", int64(1), int64(2)},
+		},
+		{
+			table: repositoriesTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{engine.RepositoriesByUserAccess: int64(5)},
+			},
+			query: "WHERE " + userAccessCondition + " ORDER BY id ASC",
+			args:  []interface{}{int64(5), int64(5), int64(5), int64(5), int64(5), int64(5), int64(5), int64(5)},
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"id": []interface{}{}},
+			},
+			query: "WHERE FALSE ORDER BY id ASC",
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"name = '' OR 1": 1},
+			},
+			err: true,
+		},
+		{
+			table: groupsTable,
+			filter: engine.QueryFilter{
+				Sort: []string{"id", "asc; DROP TABLE users"},
+			},
+			err: true,
+		},
+		{
+			table: groupsTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"ids": "1,2"},
+			},
+			err: true,
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{"password": "hash"},
+			},
+			err: true,
+		},
+		{
+			table: usersTable,
+			filter: engine.QueryFilter{
+				Filters: map[string]interface{}{engine.RepositoriesByUserAccess: 1},
+			},
+			err: true,
+		},
+		{
+			table:  groupsTable,
+			filter: engine.QueryFilter{Sort: []string{"unknown_field", "asc"}},
+			err:    true,
+		},
+	}
+
+	for i, tt := range testFilters {
+		t.Run(fmt.Sprintf("filter_%d", i), func(t *testing.T) {
+			f, err := filtersBuilder(tt.table, tt.filter, tt.fields...)
+			if tt.err {
+				var keyErr *engine.InvalidKeyError
+				assert.ErrorAs(t, err, &keyErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.query, f.allClauses)
+			assert.Equal(t, tt.args, f.args)
+		})
+	}
+}
+
+func TestCastValue(t *testing.T) {
+	assert.Equal(t, true, castValue("disabled", float64(1)))
+	assert.Equal(t, false, castValue("is_group", 0))
+	assert.Equal(t, true, castValue("disabled", "true"))
+	assert.Equal(t, int64(1019101756), castValue("timestamp", float64(1019101756)))
+	assert.Equal(t, 1.5, castValue("size", 1.5))
+	assert.Equal(t, `{"raw":"data"}`, castValue("raw", []byte(`{"raw":"data"}`)))
+	assert.Equal(t, "foo", castValue("name", "foo"))
+}
+
+func TestListValues(t *testing.T) {
+	values, ok := listValues([]string{"pull", "push"})
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"pull", "push"}, values)
+
+	values, ok = listValues([]interface{}{float64(1)})
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{float64(1)}, values)
+
+	_, ok = listValues("pull")
+	assert.False(t, ok)
+}
+
+// prepareTestDB connects to a database defined with testDSNEnv, all tables will drop when context canceled
+func prepareTestDB(ctx context.Context, t *testing.T, wg *sync.WaitGroup) *MySQL {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set, skip test with MySQL database", testDSNEnv)
+	}
+
+	testDB := NewMySQL(dsn)
+	require.NoError(t, dropTestTables(dsn))
+	require.NoError(t, testDB.Connect(ctx))
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		assert.NoError(t, dropTestTables(dsn))
+	}()
+
+	return testDB
+}
+
+// dropTestTables opens own connection and removes all tables of storage
+func dropTestTables(dsn string) error {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`, `%s`, `%s`, `%s`, `%s`, `%s`, `%s`, `%s`, `%s`", usersTable, groupsTable, accessTable, repositoriesTable,
+		groupMembersTable, robotsTable, refreshTokensTable, rolesTable, schemaVersionTable))
+	return err
+}
+
+func TestMySQL_WithTx(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	defer func() {
+		ctxCancel()
+		wg.Wait()
+	}()
+
+	db := prepareTestDB(ctx, t, wg)
+	filter := engine.QueryFilter{Filters: map[string]interface{}{"login": "tx_user"}}
+
+	testErr := errors.New("test error")
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, db.CreateUser(ctx, &store.User{Login: "tx_user", Name: "tx_user", Password: "tx_password", Role: "user"}))
+		users, errFind := db.FindUsers(ctx, filter, false)
+		require.NoError(t, errFind)
+		assert.Equal(t, int64(1), users.Total)
+		return testErr
+	})
+	assert.Equal(t, testErr, err)
+
+	users, err := db.FindUsers(ctx, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), users.Total)
+
+	// nested call joins to outer transaction
+	err = db.WithTx(ctx, func(ctx context.Context) error {
+		return db.WithTx(ctx, func(ctx context.Context) error {
+			return db.CreateUser(ctx, &store.User{Login: "tx_user", Name: "tx_user", Password: "tx_password", Role: "user"})
+		})
+	})
+	require.NoError(t, err)
+
+	users, err = db.FindUsers(ctx, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), users.Total)
+}
+
+func TestMySQL_Conformance(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set, skip test with MySQL database", testDSNEnv)
+	}
+
+	enginetest.Run(t, func(ctx context.Context, t *testing.T) engine.Interface {
+		db := NewMySQL(dsn)
+		require.NoError(t, dropTestTables(dsn))
+		require.NoError(t, db.Connect(ctx))
+		t.Cleanup(func() {
+			assert.NoError(t, db.Close(context.Background()))
+			assert.NoError(t, dropTestTables(dsn))
+		})
+		return db
+	})
+}
@@ -0,0 +1,147 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const refreshTokenFields = "id,user_id,client_id,hash,created_at,expires_at,last_used_at"
+
+// CreateRefreshToken create a new refresh token record
+func (m *MySQL) CreateRefreshToken(ctx context.Context, token *store.RefreshToken) (err error) {
+	if token.UserID == 0 || token.Hash == "" {
+		return errors.New("required refresh token fields not set: UserID, Hash")
+	}
+
+	createTokenSQL := fmt.Sprintf("INSERT INTO `%s` (user_id, client_id, hash, created_at, expires_at, last_used_at) "+
+		"VALUES (?, ?, ?, ?, ?, ?)", refreshTokensTable)
+
+	result, err := m.conn(ctx).ExecContext(ctx, createTokenSQL, token.UserID, token.ClientID, token.Hash, token.CreatedAt,
+		token.ExpiresAt, token.LastUsedAt)
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to add new refresh token")
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		token.ID = id
+	}
+	return err
+}
+
+// GetRefreshToken get refresh token data by hash of the token value
+func (m *MySQL) GetRefreshToken(ctx context.Context, hash string) (token store.RefreshToken, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` WHERE hash = ?", refreshTokenFields, refreshTokensTable)
+	token, err = scanRefreshToken(m.conn(ctx).QueryRowContext(ctx, queryString, hash).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, fmt.Errorf("refresh token %w", engine.ErrNotFound)
+	}
+	return token, err
+}
+
+// FindRefreshTokens fetch list of refresh tokens by filter values
+func (m *MySQL) FindRefreshTokens(ctx context.Context, filter engine.QueryFilter) (tokens engine.ListResponse, err error) {
+	f, err := filtersBuilder(refreshTokensTable, filter, "client_id")
+	if err != nil {
+		return tokens, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` %s", refreshTokenFields, refreshTokensTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	if tokens.Total = m.getTotalRecordsExcludeRange(ctx, refreshTokensTable, filter, []string{"client_id"}); tokens.Total == 0 {
+		return tokens, nil
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return tokens, errors.Wrap(err, "failed to get refresh tokens list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	tokens.Data = []interface{}{}
+	for rows.Next() {
+		token, errScan := scanRefreshToken(rows.Scan)
+		if errScan != nil {
+			return tokens, errScan
+		}
+		tokens.Data = append(tokens.Data, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// UpdateRefreshTokenUsage update time of the last use of refresh token
+func (m *MySQL) UpdateRefreshTokenUsage(ctx context.Context, id, usedAt int64) (err error) {
+	queryString := fmt.Sprintf("UPDATE `%s` SET last_used_at = ? WHERE id = ?", refreshTokensTable)
+	res, err := m.conn(ctx).ExecContext(ctx, queryString, usedAt, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update refresh token usage")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL doesn't count rows which values haven't changed, so zero rows means a missing token only when it isn't found
+	if rowsAffected == 0 {
+		_, err = m.getRefreshTokenByID(ctx, id)
+		return err
+	}
+	return nil
+}
+
+// DeleteRefreshToken delete refresh tokens by key
+func (m *MySQL) DeleteRefreshToken(ctx context.Context, key string, id interface{}) (err error) {
+	if !isAllowedField(refreshTokensTable, key) {
+		return &engine.InvalidKeyError{Key: key}
+	}
+
+	//nolint:gosec // key value checked above and can't contain anything except a column name
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE %s = ?", refreshTokensTable, key)
+	res, err := m.conn(ctx).ExecContext(ctx, query, castValue(key, id))
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for refresh token delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// RefreshTokenGarbageCollector deletes refresh tokens which expired before the time
+func (m *MySQL) RefreshTokenGarbageCollector(ctx context.Context, expiredBefore int64) (err error) {
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE expires_at < ?", refreshTokensTable)
+	if _, err = m.conn(ctx).ExecContext(ctx, query, expiredBefore); err != nil {
+		return errors.Wrap(err, "failed execute query for refresh tokens garbage collector")
+	}
+	return nil
+}
+
+func (m *MySQL) getRefreshTokenByID(ctx context.Context, id int64) (token store.RefreshToken, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` WHERE id = ?", refreshTokenFields, refreshTokensTable)
+	token, err = scanRefreshToken(m.conn(ctx).QueryRowContext(ctx, queryString, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, fmt.Errorf("refresh token %w", engine.ErrNotFound)
+	}
+	return token, err
+}
+
+func scanRefreshToken(scan func(dest ...interface{}) error) (token store.RefreshToken, err error) {
+	err = scan(&token.ID, &token.UserID, &token.ClientID, &token.Hash, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return token, errors.Wrap(err, "failed scan refresh token data")
+	}
+	return token, err
+}
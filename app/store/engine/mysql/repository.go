@@ -0,0 +1,191 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+// CreateRepository create a new repository record
+func (m *MySQL) CreateRepository(ctx context.Context, entry *store.RegistryEntry) (err error) {
+
+	createRepositorySQL := fmt.Sprintf("INSERT INTO `%s` (repository_name, tag, digest, config_digest, size, pull_counter, timestamp, raw) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?)", repositoriesTable)
+
+	result, err := m.conn(ctx).ExecContext(ctx, createRepositorySQL, entry.RepositoryName, entry.Tag, entry.Digest, entry.ConfigDigest, entry.Size, entry.PullCounter, entry.Timestamp, entry.Raw)
+	if err != nil {
+		return checkDuplicateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		entry.ID = id
+	}
+	return err
+}
+
+// GetRepository get repository data by ID
+func (m *MySQL) GetRepository(ctx context.Context, entryID int64) (entry store.RegistryEntry, err error) {
+
+	queryString := fmt.Sprintf("SELECT id, repository_name, tag, digest, config_digest, size, pull_counter, timestamp, raw FROM `%s` WHERE id = ?", repositoriesTable)
+	err = m.conn(ctx).QueryRowContext(ctx, queryString, entryID).
+		Scan(&entry.ID, &entry.RepositoryName, &entry.Tag, &entry.Digest, &entry.ConfigDigest, &entry.Size, &entry.PullCounter, &entry.Timestamp, &entry.Raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, fmt.Errorf("repository %w", engine.ErrNotFound)
+	}
+	if err != nil {
+		return entry, errors.Wrap(err, "failed scan repository data")
+	}
+	return entry, nil
+}
+
+// FindRepositories fetch list of existed repositories
+func (m *MySQL) FindRepositories(ctx context.Context, filter engine.QueryFilter) (entries engine.ListResponse, err error) {
+
+	f, err := filtersBuilder(repositoriesTable, filter, "repository_name", "tag") // set key filed for search query
+	if err != nil {
+		return entries, err
+	}
+
+	//nolint:gosec // all values passed to query as params
+	queryString := fmt.Sprintf("SELECT id,repository_name,tag,digest,config_digest,size,pull_counter,timestamp,raw FROM `%s` %s",
+		repositoriesTable, f.allClauses)
+
+	// When request has 'groupBy' the query returns the latest entry for each repository with summary size of all its tags.
+	// MySQL with ONLY_FULL_GROUP_BY mode (default since 5.7) doesn't allow select non-aggregated columns with GROUP BY,
+	// that's why the grouping made in a subquery. Columns have aliases for resolve of unqualified names in ORDER BY clause.
+	if filter.GroupByField {
+		//nolint:gosec // all values passed to query as params
+		queryString = fmt.Sprintf("SELECT r.id AS id,r.repository_name AS repository_name,r.tag AS tag,r.digest AS digest,"+
+			"r.config_digest AS config_digest,g.size AS size,r.pull_counter AS pull_counter,r.timestamp AS timestamp,r.raw AS raw "+
+			"FROM `%[1]s` r INNER JOIN (SELECT MAX(id) AS id, SUM(size) AS size FROM `%[1]s` %[2]s GROUP BY repository_name) g ON r.id = g.id%[3]s%[4]s",
+			repositoriesTable, f.where, f.order, f.skipLimit)
+	}
+
+	if entries.Total = m.getTotalRecordsExcludeRange(ctx, repositoriesTable, filter, []string{"repository_name", "tag"}); entries.Total == 0 {
+		return entries, nil // may be error handler catch
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return entries, errors.Wrap(err, "failed to get repositories list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	entries.Data = []interface{}{}
+	for rows.Next() {
+		var entry store.RegistryEntry
+		if err = rows.Scan(&entry.ID, &entry.RepositoryName, &entry.Tag, &entry.Digest, &entry.ConfigDigest, &entry.Size, &entry.PullCounter, &entry.Timestamp, &entry.Raw); err != nil {
+			return entries, errors.Wrap(err, "failed scan repository data")
+		}
+		entries.Data = append(entries.Data, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// UpdateRepository update repository entry data
+func (m *MySQL) UpdateRepository(ctx context.Context, conditionClause, data map[string]interface{}) (err error) {
+	var args []interface{}
+
+	fields, err := assignmentList(data, &args)
+	if err != nil {
+		return err
+	}
+
+	conditions, err := assignmentList(conditionClause, &args)
+	if err != nil {
+		return err
+	}
+
+	if len(fields) == 0 || len(conditions) == 0 {
+		return errors.New("update fields and conditions shouldn't be empty")
+	}
+
+	//nolint:gosec // keys checked by assignmentList and all values passed to query as params
+	queryString := fmt.Sprintf("UPDATE `%s` SET %s WHERE %s", repositoriesTable, strings.Join(fields, ", "), strings.Join(conditions, " AND "))
+
+	res, err := m.conn(ctx).ExecContext(ctx, queryString, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to update repository data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("record didn't update")
+	}
+
+	return nil
+}
+
+// DeleteRepository delete repository entry by ID
+func (m *MySQL) DeleteRepository(ctx context.Context, repositoryName, digest string) (err error) {
+
+	//nolint:gosec // key value not passed from user input and can be change in code only
+	deleteSQL := fmt.Sprintf("DELETE FROM `%s` WHERE %s = ? AND %s = ?", repositoriesTable, store.RegistryRepositoryNameField, store.RegistryContentDigestField)
+	res, err := m.conn(ctx).ExecContext(ctx, deleteSQL, repositoryName, digest)
+	if err != nil {
+		return errors.Wrapf(err, "failed execute query for repository delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+
+	return nil
+}
+
+// RepositoryGarbageCollector deletes outdated repositories
+func (m *MySQL) RepositoryGarbageCollector(ctx context.Context, syncDate int64) (err error) {
+
+	//nolint:gosec // SQL query prepares from static value inside code logic and doesn't pass key name from outside
+	deleteSQL := fmt.Sprintf("DELETE FROM `%s` WHERE %s < ?", repositoriesTable, store.RegistryTimestampField)
+	res, err := m.conn(ctx).ExecContext(ctx, deleteSQL, syncDate)
+	if err != nil {
+		return errors.Wrapf(err, "failed execute query for repositories garbage collector")
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		log.Printf("repositories deleted: %d", rows)
+	}
+	return nil
+}
+
+// assignmentList makes list of 'key = ?' expressions from map, values appends to args in the same order
+func assignmentList(values map[string]interface{}, args *[]interface{}) ([]string, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if !isAllowedField(repositoriesTable, k) {
+			return nil, &engine.InvalidKeyError{Key: k}
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, fmt.Sprintf("%s = ?", k))
+		*args = append(*args, castValue(k, values[k]))
+	}
+	return result, nil
+}
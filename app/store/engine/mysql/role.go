@@ -0,0 +1,177 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const roleFields = "id,name,description,built_in,permissions"
+
+// CreateRole create a new custom role record
+func (m *MySQL) CreateRole(ctx context.Context, role *store.Role) (err error) {
+	if role.Name == "" {
+		return errors.New("required role fields not set: Name")
+	}
+
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode role permissions")
+	}
+
+	createRoleSQL := fmt.Sprintf("INSERT INTO `%s` (name, description, built_in, permissions) VALUES (?, ?, ?, ?)", rolesTable)
+	result, err := m.conn(ctx).ExecContext(ctx, createRoleSQL, role.Name, role.Description, role.BuiltIn, string(permissions))
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to add new role")
+	}
+
+	id, err := result.LastInsertId()
+	if err == nil {
+		role.ID = id
+	}
+	return err
+}
+
+// GetRole get role data by ID or by name
+func (m *MySQL) GetRole(ctx context.Context, id interface{}) (role store.Role, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` WHERE id = ?", roleFields, rolesTable)
+
+	switch val := id.(type) {
+	case string:
+		// cast ID value when ID has name value
+		if _, errParse := strconv.ParseInt(val, 10, 64); errParse != nil {
+			queryString = fmt.Sprintf("SELECT %s FROM `%s` WHERE name = ?", roleFields, rolesTable)
+		}
+	case int, int64:
+	default:
+		return role, errors.New("unsupported id type")
+	}
+
+	role, err = scanRole(m.conn(ctx).QueryRowContext(ctx, queryString, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return role, fmt.Errorf("role %w", engine.ErrNotFound)
+	}
+	return role, err
+}
+
+// FindRoles fetch list of roles by filter values
+func (m *MySQL) FindRoles(ctx context.Context, filter engine.QueryFilter) (roles engine.ListResponse, err error) {
+	f, err := filtersBuilder(rolesTable, filter, "name", "description")
+	if err != nil {
+		return roles, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` %s", roleFields, rolesTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	roles.Data = []interface{}{}
+	if roles.Total = m.getTotalRecordsExcludeRange(ctx, rolesTable, filter, []string{"name", "description"}); roles.Total == 0 {
+		return roles, nil
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return roles, errors.Wrap(err, "failed to get roles list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		role, errScan := scanRole(rows.Scan)
+		if errScan != nil {
+			return roles, errScan
+		}
+		roles.Data = append(roles.Data, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// UpdateRole update description and permissions of role
+func (m *MySQL) UpdateRole(ctx context.Context, role store.Role) (err error) {
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode role permissions")
+	}
+
+	res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET description=?, permissions=? WHERE id = ?", rolesTable),
+		role.Description, string(permissions), role.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update role data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL doesn't count rows which values haven't changed, so zero rows means a missing role only when it isn't found
+	if rowsAffected == 0 {
+		_, err = m.GetRole(ctx, role.ID)
+		return err
+	}
+	return nil
+}
+
+// DeleteRole delete role record by ID
+func (m *MySQL) DeleteRole(ctx context.Context, id int64) (err error) {
+	res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE id = ?", rolesTable), id)
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for role delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// updateBuiltInRoles creates built-in roles or updates them with permissions of the current version
+func (m *MySQL) updateBuiltInRoles(ctx context.Context) error {
+	upsertSQL := fmt.Sprintf("INSERT INTO `%s` (name, description, built_in, permissions) VALUES (?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE description=VALUES(description), built_in=VALUES(built_in), permissions=VALUES(permissions)",
+		rolesTable)
+
+	for _, role := range store.BuiltInRoles() {
+		permissions, err := json.Marshal(role.Permissions)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode role permissions")
+		}
+		if _, err = m.db.ExecContext(ctx, upsertSQL, role.Name, role.Description, role.BuiltIn, string(permissions)); err != nil {
+			return errors.Wrapf(err, "failed to update built-in role %q", role.Name)
+		}
+	}
+	return nil
+}
+
+// isRoleExist checks that a role with the name exists, users can be assigned to existed roles only
+func (m *MySQL) isRoleExist(ctx context.Context, name string) (bool, error) {
+	var count int64
+	err := m.conn(ctx).QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE name = ?", rolesTable), name).Scan(&count)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check role %q", name)
+	}
+	return count > 0, nil
+}
+
+// scanRole reads role data with scan function of a row, permissions of role store as JSON text
+func scanRole(scan func(dest ...interface{}) error) (role store.Role, err error) {
+	var permissions string
+	if err = scan(&role.ID, &role.Name, &role.Description, &role.BuiltIn, &permissions); err != nil {
+		return role, errors.Wrap(err, "failed scan role data")
+	}
+
+	if err = json.Unmarshal([]byte(permissions), &role.Permissions); err != nil {
+		return role, errors.Wrap(err, "failed to decode role permissions")
+	}
+	return role, nil
+}
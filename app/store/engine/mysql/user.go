@@ -0,0 +1,281 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const minPasswordLength = 6
+
+// userFields are columns of users table in order of scanUser arguments
+const userFields = "id,login,name,password,role,user_group,disabled,description," +
+	"totp_enabled,totp_secret,totp_recovery_codes,sso_id,totp_last_step"
+
+// CreateUser create a new user record
+func (m *MySQL) CreateUser(ctx context.Context, user *store.User) (err error) {
+
+	var emptyParams []string
+
+	// check required parameters filled
+	if user.Login == "" {
+		emptyParams = append(emptyParams, "Login")
+	}
+
+	if user.Name == "" {
+		emptyParams = append(emptyParams, "Name")
+	}
+	passwordHashed := engine.IsPasswordHashed(ctx)
+	if !passwordHashed && len(user.Password) < minPasswordLength {
+		emptyParams = append(emptyParams, fmt.Sprintf("password length should be equal or more %d characters", minPasswordLength))
+	}
+
+	roleExist, err := m.isRoleExist(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if !roleExist {
+		emptyParams = append(emptyParams, fmt.Sprintf("role '%s' not allowed", user.Role))
+	}
+
+	if len(emptyParams) > 0 {
+		return fmt.Errorf("required user fields not set: %s", strings.Join(emptyParams, ", "))
+	}
+
+	if user.Group == 0 {
+		user.Group = 1
+	}
+
+	// hashing password value, a password restored from backup is already hashed
+	if !passwordHashed {
+		if errHash := user.HashAndSalt(); errHash != nil {
+			return errHash
+		}
+	}
+
+	createUserSQL := fmt.Sprintf("INSERT INTO `%s` (login, name, password, role, user_group, disabled, description, sso_id) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?)", usersTable)
+
+	// a new user becomes a member of the primary group within the same transaction
+	return m.WithTx(ctx, func(ctx context.Context) error {
+		result, err := m.conn(ctx).ExecContext(ctx, createUserSQL, user.Login, user.Name, user.Password, user.Role, user.Group, user.Disabled,
+			user.Description, user.SSOID)
+		if err != nil {
+			return multierror.Append(checkDuplicateError(err), errors.New("failed to add new user"))
+		}
+
+		if user.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		return m.addPrimaryGroupMember(ctx, *user)
+	})
+}
+
+// GetUser get data by user ID
+func (m *MySQL) GetUser(ctx context.Context, id interface{}) (user store.User, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` WHERE id = ?", userFields, usersTable)
+
+	switch val := id.(type) {
+	case string:
+		// cast ID value when ID has login value
+		if _, errParse := strconv.ParseInt(val, 10, 64); errParse != nil {
+			queryString = fmt.Sprintf("SELECT %s FROM `%s` WHERE login = ?", userFields, usersTable)
+		}
+	case int, int64:
+	default:
+		return user, errors.New("unsupported id type")
+	}
+
+	user, err = scanUser(m.conn(ctx).QueryRowContext(ctx, queryString, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, engine.ErrNotFound
+	}
+	if err != nil {
+		return user, multierror.Append(err, errors.New("failed to get user"))
+	}
+	return user, nil
+}
+
+// FindUsers fetch list of user by filter values
+func (m *MySQL) FindUsers(ctx context.Context, filter engine.QueryFilter, withPassword bool) (users engine.ListResponse, err error) {
+	f, err := filtersBuilder(usersTable, filter, "login", "name")
+	if err != nil {
+		return users, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM `%s` %s", userFields, usersTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	// total calculates before fetch rows because a transaction connection can't run a query while rows of other one are read
+	if users.Total = m.getTotalRecordsExcludeRange(ctx, usersTable, filter, []string{"login", "name"}); users.Total == 0 {
+		return users, nil // may be error handler catch
+	}
+
+	rows, err := m.conn(ctx).QueryContext(ctx, queryString, f.args...)
+	if err != nil {
+		return users, errors.Wrap(err, "failed to get users list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	users.Data = []interface{}{}
+	for rows.Next() {
+		user, errScan := scanUser(rows.Scan)
+		if errScan != nil {
+			return users, errors.Wrap(errScan, "failed scan user data")
+		}
+
+		// clear password value and second factor secrets when user fetch
+		if !withPassword {
+			user.Password = ""
+			user.TOTP.Secret, user.TOTP.RecoveryCodes = "", nil
+		}
+
+		users.Data = append(users.Data, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateUser update user records data
+func (m *MySQL) UpdateUser(ctx context.Context, user store.User) (err error) {
+
+	roleExist, err := m.isRoleExist(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if !roleExist {
+		return errors.Errorf("role '%s' not allowed", user.Role)
+	}
+	if user.Password != "" && !engine.IsPasswordHashed(ctx) {
+		if len(user.Password) < minPasswordLength {
+			return fmt.Errorf("password length should be equal or more %d characters", minPasswordLength)
+		}
+
+		if errHash := user.HashAndSalt(); errHash != nil {
+			return errHash
+		}
+	}
+
+	// a changed primary group is added to the user groups, membership in the previous one is kept
+	return m.WithTx(ctx, func(ctx context.Context) (err error) {
+		var res sql.Result
+		if user.Password != "" {
+			res, err = m.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET name=?, password=?, role=?, user_group=?, disabled=?, description=? WHERE id = ?", usersTable),
+				user.Name, user.Password, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+
+		} else {
+			// skip a password field update if updating password value is empty
+			res, err = m.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET name=?, role=?, user_group=?, disabled=?, description=? WHERE id = ?", usersTable),
+				user.Name, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to update user data")
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return errors.New("record didn't update")
+		}
+
+		return m.addPrimaryGroupMember(ctx, user)
+	})
+}
+
+// UpdateUserTOTP update two-factor authentication settings of user, recovery codes hashes store as JSON text
+func (m *MySQL) UpdateUserTOTP(ctx context.Context, id int64, totp store.TOTP) (err error) {
+	recoveryCodes, err := json.Marshal(totp.RecoveryCodes)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recovery codes")
+	}
+
+	res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET totp_enabled=?, totp_secret=?, totp_recovery_codes=? WHERE id = ?", usersTable),
+		totp.Enabled, totp.Secret, string(recoveryCodes), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update user totp")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateUserTOTPStep stores the time step of an accepted one-time password, the stored step is changed only when
+// it's before the new one, so concurrent requests with the same code can't both be accepted
+func (m *MySQL) UpdateUserTOTPStep(ctx context.Context, id, step int64) (err error) {
+	res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE `%s` SET totp_last_step=? WHERE id = ? AND totp_last_step < ?", usersTable),
+		step, id, step)
+	if err != nil {
+		return errors.Wrap(err, "failed to update user totp step")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUser delete user record by ID, membership of the user in groups and refresh tokens of the user are deleted too
+func (m *MySQL) DeleteUser(ctx context.Context, id int64) (err error) {
+	return m.WithTx(ctx, func(ctx context.Context) error {
+		res, err := m.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE id = ?", usersTable), id)
+		if err != nil {
+			return errors.Wrapf(err, "failed execute query for user delete")
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return engine.ErrNotFound
+		}
+
+		if _, err = m.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE user_id = ?", groupMembersTable), id); err != nil {
+			return errors.Wrap(err, "failed to delete user from groups")
+		}
+
+		_, err = m.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE user_id = ?", refreshTokensTable), id)
+		return errors.Wrap(err, "failed to delete refresh tokens of user")
+	})
+}
+
+// scanUser reads user data with scan function of a row
+func scanUser(scan func(dest ...interface{}) error) (user store.User, err error) {
+	var recoveryCodes string
+	err = scan(&user.ID, &user.Login, &user.Name, &user.Password, &user.Role, &user.Group, &user.Disabled, &user.Description,
+		&user.TOTP.Enabled, &user.TOTP.Secret, &recoveryCodes, &user.SSOID, &user.TOTP.LastStep)
+	if err != nil {
+		return user, err
+	}
+
+	if recoveryCodes != "" {
+		if err = json.Unmarshal([]byte(recoveryCodes), &user.TOTP.RecoveryCodes); err != nil {
+			return user, errors.Wrap(err, "failed to decode recovery codes")
+		}
+	}
+	return user, nil
+}
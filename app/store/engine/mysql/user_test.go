@@ -0,0 +1,197 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+func TestMySQL_CreateUser(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	user := store.User{
+		Login:       "test_user",
+		Name:        "test_user_name",
+		Password:    "test_user_password",
+		Role:        "admin",
+		Group:       0,
+		Disabled:    false,
+		Description: "test_description",
+	}
+
+	err := db.CreateUser(ctx, &user)
+	assert.NoError(t, err)
+	assert.Greater(t, user.ID, int64(1))
+	assert.Equal(t, int64(1), user.Group)
+	assert.NotEqual(t, user.Password, "test_user_password")
+
+	// test for duplicate login
+	user.Password = "test_user_password"
+	err = db.CreateUser(ctx, &user)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, engine.ErrDuplicateEntry))
+
+	// test with empty required fields
+	user2 := &store.User{
+		Login:       "",
+		Name:        "",
+		Password:    "",
+		Role:        "unknown",
+		Disabled:    false,
+		Description: "test_description",
+	}
+	err = db.CreateUser(ctx, user2)
+	assert.Equal(t, err, errors.New("required user fields not set: Login, Name, password length should be equal or more 6 characters, role 'unknown' not allowed"))
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestMySQL_GetUser(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	user := &store.User{
+		Login:       "test_user",
+		Name:        "test_user_name",
+		Password:    "test_user_password",
+		Role:        "admin",
+		Group:       1,
+		Disabled:    false,
+		Description: "test_description",
+	}
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	{
+		// test with int ID
+		userData, errGetUser := db.GetUser(ctx, user.ID)
+		assert.NoError(t, errGetUser)
+		assert.Equal(t, *user, userData)
+	}
+
+	{
+		// test with ID as string type
+		userData, errGetUser := db.GetUser(ctx, "2")
+		assert.NoError(t, errGetUser)
+		assert.Equal(t, *user, userData)
+	}
+
+	{
+		// test with login as ID
+		userData, errGetUser := db.GetUser(ctx, "test_user")
+		assert.NoError(t, errGetUser)
+		assert.Equal(t, *user, userData)
+	}
+
+	_, err := db.GetUser(ctx, -1)
+	assert.ErrorIs(t, err, engine.ErrNotFound)
+
+	_, err = db.GetUser(ctx, struct{}{})
+	assert.Error(t, err)
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestMySQL_FindUsers(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	users := []store.User{
+		{Login: "foo", Name: "foo", Password: "foo_password", Role: "user", Group: 1, Disabled: true},
+		{Login: "bar", Name: "bar", Password: "bar_password", Role: "user", Group: 1, Disabled: false},
+		{Login: "baz", Name: "baz", Password: "baz_password", Role: "user", Group: 1, Disabled: false},
+	}
+
+	for _, u := range users {
+		tmpUser := u
+		require.NoError(t, db.CreateUser(ctx, &tmpUser))
+	}
+
+	filter := engine.QueryFilter{
+		Range:   [2]int64{0, 1},
+		Filters: map[string]interface{}{"q": "ba", "disabled": 0},
+		Sort:    []string{"login", "desc"},
+	}
+
+	result, err := db.FindUsers(ctx, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+	require.Equal(t, 1, len(result.Data))
+	assert.Equal(t, "baz", result.Data[0].(store.User).Login)
+	assert.Empty(t, result.Data[0].(store.User).Password)
+
+	result, err = db.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"q": "unknown"}}, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Total)
+
+	_, err = db.FindUsers(ctx, engine.QueryFilter{Filters: map[string]interface{}{"login;": "foo"}}, true)
+	assert.Error(t, err)
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestMySQL_UpdateUser(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	user := &store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: "user", Group: 1}
+	require.NoError(t, db.CreateUser(ctx, user))
+	passwordHash := user.Password
+
+	user.Name = "foo_updated"
+	user.Password = ""
+	user.Disabled = true
+	require.NoError(t, db.UpdateUser(ctx, *user))
+
+	updated, err := db.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "foo_updated", updated.Name)
+	assert.Equal(t, passwordHash, updated.Password)
+	assert.True(t, updated.Disabled)
+
+	updated.Password = "new_password"
+	require.NoError(t, db.UpdateUser(ctx, updated))
+	updated, err = db.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, store.ComparePassword(updated.Password, "new_password"))
+
+	updated.Password = "short"
+	assert.Error(t, db.UpdateUser(ctx, updated))
+
+	updated.Password = ""
+	updated.Role = "unknown"
+	assert.Error(t, db.UpdateUser(ctx, updated))
+
+	assert.Error(t, db.UpdateUser(ctx, store.User{ID: -1, Role: "user"}))
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestMySQL_DeleteUser(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	user := &store.User{Login: "foo", Name: "foo", Password: "foo_password", Role: "user", Group: 1}
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	assert.NoError(t, db.DeleteUser(ctx, user.ID))
+	assert.ErrorIs(t, db.DeleteUser(ctx, user.ID), engine.ErrNotFound)
+
+	ctxCancel()
+	wg.Wait()
+}
@@ -0,0 +1,387 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const schemaVersionTable = "schema_version"
+
+// migration defines a single step of a database schema change
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations is an ordered list of schema changes. A new change should be appended to the end of list with the next
+// version number, already released migrations mustn't be changed because they never run again for existing databases.
+// Every migration should be idempotent, databases created before versioning was introduced don't have any records
+// in the schema_version table and all migrations are applied to their existing tables.
+var migrations = []migration{
+	{version: 1, description: "create initial tables", up: initialSchema},
+	{version: 2, description: "add is_group to unique key of access table", up: accessOwnerKindSchema},
+	{version: 3, description: "create group members table", up: groupMembersSchema},
+	{version: 4, description: "create robots table", up: robotsSchema},
+	{version: 5, description: "add sso_id column to users table", up: usersSSOSchema},
+	{version: 6, description: "add totp columns to users table", up: usersTOTPSchema},
+	{version: 7, description: "create refresh tokens table", up: refreshTokensSchema},
+	{version: 8, description: "add validity columns to access table", up: accessValiditySchema},
+	{version: 9, description: "add effect column to access table", up: accessEffectSchema},
+	{version: 10, description: "create roles table", up: rolesSchema},
+}
+
+// migrate applies all migrations which version is greater than the current database schema version.
+// Each migration runs in own transaction together with a schema version update.
+func (p *Postgres) migrate(ctx context.Context) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		version INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at BIGINT)`, schemaVersionTable)
+
+	if _, err := p.db.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", schemaVersionTable)
+	}
+
+	currentVersion, err := p.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= currentVersion {
+			continue
+		}
+
+		if err = p.applyMigration(ctx, m); err != nil {
+			return errors.Wrapf(err, "failed to apply schema migration %d (%s)", m.version, m.description)
+		}
+		log.Printf("[INFO] schema migration %d applied: %s", m.version, m.description)
+	}
+	return nil
+}
+
+func (p *Postgres) applyMigration(ctx context.Context, m migration) (err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = m.up(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, description, applied_at) VALUES ($1, $2, $3)", schemaVersionTable),
+		m.version, m.description, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the latest applied migration version, zero means a database hasn't any versioned changes yet
+func (p *Postgres) schemaVersion(ctx context.Context) (version int, err error) {
+	row := p.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", schemaVersionTable))
+	if err = row.Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "failed to get schema version")
+	}
+	return version, nil
+}
+
+// initialSchema creates tables with default records, the existing tables of databases created
+// by previous versions are kept as is
+func initialSchema(ctx context.Context, tx *sql.Tx) error {
+	if err := createGroupsTable(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := createUsersTable(ctx, tx); err != nil {
+		return err
+	}
+
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id BIGSERIAL PRIMARY KEY,
+		owner_id BIGINT NOT NULL,
+		is_group BOOLEAN,
+		name TEXT,
+		resource_type TEXT,
+		resource_name TEXT,
+		action TEXT,
+		disabled BOOLEAN,
+		UNIQUE(owner_id,resource_type,resource_name,action))`, accessTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", accessTable)
+	}
+
+	sqlText = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id BIGSERIAL PRIMARY KEY,
+		repository_name TEXT NOT NULL CHECK(repository_name <> ''),
+		tag TEXT NOT NULL CHECK(tag <> ''),
+		digest TEXT NOT NULL CHECK(digest <> ''),
+		config_digest TEXT NOT NULL CHECK(config_digest <> ''),
+		size BIGINT,
+		pull_counter BIGINT,
+		timestamp BIGINT,
+		raw TEXT,
+		UNIQUE(repository_name,tag))`, repositoriesTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", repositoriesTable)
+	}
+	return nil
+}
+
+func createGroupsTable(ctx context.Context, tx *sql.Tx) error {
+	if exist, err := isTableExist(ctx, tx, groupsTable); err != nil || exist {
+		return err
+	}
+
+	sqlText := fmt.Sprintf(`CREATE TABLE %s(
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE,
+	description TEXT)`, groupsTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", groupsTable)
+	}
+
+	// create default admin group when a new database creation
+	group := store.Group{
+		Name:        "default",
+		Description: "Default administration group",
+	}
+
+	createGroupSQL := fmt.Sprintf(`INSERT INTO %s (name, description) VALUES ($1, $2) ON CONFLICT DO NOTHING`, groupsTable)
+	if _, err := tx.ExecContext(ctx, createGroupSQL, group.Name, group.Description); err != nil {
+		return errors.Wrapf(err, "failed to insert default group to %s table", groupsTable)
+	}
+	return nil
+}
+
+func createUsersTable(ctx context.Context, tx *sql.Tx) error {
+	if exist, err := isTableExist(ctx, tx, usersTable); err != nil || exist {
+		return err
+	}
+
+	sqlText := fmt.Sprintf(`CREATE TABLE %s(
+	id BIGSERIAL PRIMARY KEY,
+	login TEXT UNIQUE,
+	name TEXT,
+	password TEXT,
+	role TEXT,
+	user_group BIGINT,
+	disabled BOOLEAN,
+	description TEXT)`, usersTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", usersTable)
+	}
+
+	defaultPassword := engine.GetAdminDefaultPassword(ctx)
+	if defaultPassword == "" {
+		defaultPassword = "admin"
+	}
+
+	// create default admin user if new database creation
+	user := store.User{
+		Login:       "admin", // is the default login
+		Name:        "admin",
+		Password:    defaultPassword,
+		Role:        "admin",
+		Group:       1,
+		Description: "Default user with administration role ",
+	}
+
+	// hashing user password
+	if errHash := user.HashAndSalt(); errHash != nil {
+		return errHash
+	}
+
+	createUserSQL := fmt.Sprintf(`INSERT INTO %s (
+		login,
+		name,
+		password,
+		role,
+		user_group,
+		disabled,
+		description
+	) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`, usersTable)
+
+	_, err := tx.ExecContext(ctx, createUserSQL, user.Login, user.Name, user.Password, user.Role, user.Group, false, user.Description)
+	if err != nil {
+		return errors.Wrapf(err, "failed to insert new default user to %s table", usersTable)
+	}
+	return nil
+}
+
+// accessOwnerKindSchema makes rules of a user and a group with the same id independent of each other,
+// before that a group rule conflicted with a rule of the user which has the same id as the group
+func accessOwnerKindSchema(ctx context.Context, tx *sql.Tx) error {
+	return replaceAccessUniqueKey(ctx, tx, "owner_id,is_group,resource_type,resource_name,action")
+}
+
+// groupMembersSchema creates a table for many-to-many relations between users and groups
+// and copies the single group of every existed user to it
+func groupMembersSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id BIGSERIAL PRIMARY KEY,
+		group_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		UNIQUE(group_id,user_id))`, groupMembersTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", groupMembersTable)
+	}
+
+	sqlText = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_user_id ON %[1]s(user_id)", groupMembersTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create index of %s table", groupMembersTable)
+	}
+
+	sqlText = fmt.Sprintf("INSERT INTO %s (group_id, user_id) SELECT user_group, id FROM %s WHERE user_group > 0 ON CONFLICT DO NOTHING",
+		groupMembersTable, usersTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to copy users groups to %s table", groupMembersTable)
+	}
+	return nil
+}
+
+// robotsSchema creates a table for robot accounts
+func robotsSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL CHECK(name <> ''),
+		description TEXT,
+		secret TEXT NOT NULL,
+		disabled BOOLEAN,
+		expires_at BIGINT,
+		created_at BIGINT,
+		permissions TEXT)`, robotsTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", robotsTable)
+	}
+	return nil
+}
+
+// usersSSOSchema adds a column with the identity of users at single sign-on providers, local users have it empty
+func usersSSOSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS sso_id TEXT NOT NULL DEFAULT ''", usersTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to add sso_id column to %s table", usersTable)
+	}
+	return nil
+}
+
+// usersTOTPSchema adds columns with two-factor authentication settings of users
+func usersTOTPSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`ALTER TABLE %s
+	ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS totp_secret TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS totp_recovery_codes TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0`, usersTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to add totp columns to %s table", usersTable)
+	}
+	return nil
+}
+
+// refreshTokensSchema creates a table for refresh tokens of registry clients
+func refreshTokensSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id BIGSERIAL PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		client_id TEXT,
+		hash TEXT UNIQUE NOT NULL CHECK(hash <> ''),
+		created_at BIGINT,
+		expires_at BIGINT,
+		last_used_at BIGINT)`, refreshTokensTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", refreshTokensTable)
+	}
+
+	sqlText = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_user_id ON %[1]s(user_id)", refreshTokensTable)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create index of %s table", refreshTokensTable)
+	}
+	return nil
+}
+
+// accessValiditySchema adds columns with validity window of access rules, existed rules aren't limited
+func accessValiditySchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`ALTER TABLE %s
+	ADD COLUMN IF NOT EXISTS not_before BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS expires_at BIGINT NOT NULL DEFAULT 0`, accessTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to add validity columns to %s table", accessTable)
+	}
+	return nil
+}
+
+// accessEffectSchema adds a column with effect of access rules, existed rules allow actions. The effect is a part
+// of the unique key, so an allow rule and a deny rule can be set for the same owner, resource and action.
+func accessEffectSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS effect VARCHAR(16) NOT NULL DEFAULT '%s'",
+		accessTable, store.AccessEffectAllow)
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to add effect column to %s table", accessTable)
+	}
+	return replaceAccessUniqueKey(ctx, tx, "owner_id,is_group,resource_type,resource_name,action,effect")
+}
+
+// rolesSchema creates a table for roles of users, built-in roles are added at every start of storage
+func rolesSchema(ctx context.Context, tx *sql.Tx) error {
+	sqlText := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL CHECK(name <> ''),
+		description TEXT,
+		built_in BOOLEAN NOT NULL DEFAULT FALSE,
+		permissions TEXT)`, rolesTable)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to create %s table", rolesTable)
+	}
+	return nil
+}
+
+// replaceAccessUniqueKey drops the unique key of the access table, created with the table or by a previous migration,
+// and creates a new one with the columns
+func replaceAccessUniqueKey(ctx context.Context, tx *sql.Tx, columns string) error {
+	sqlText := fmt.Sprintf(`ALTER TABLE %[1]s
+	DROP CONSTRAINT IF EXISTS %[1]s_owner_id_resource_type_resource_name_action_key,
+	DROP CONSTRAINT IF EXISTS %[1]s_rule_key,
+	ADD CONSTRAINT %[1]s_rule_key UNIQUE(%[2]s)`, accessTable, columns)
+
+	if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return errors.Wrapf(err, "failed to change unique key of %s table", accessTable)
+	}
+	return nil
+}
+
+func isTableExist(ctx context.Context, q sqlConn, tableName string) (exist bool, err error) {
+	err = q.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1)",
+		tableName,
+	).Scan(&exist)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't check for %s table exist", tableName)
+	}
+	return exist, nil
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+var entries = []store.RegistryEntry{
+	{RepositoryName: "aHello_test_1", Tag: "test_tag_1", Digest: "sha256:01", ConfigDigest: "sha256:c1", Size: 708, PullCounter: 1, Raw: `{"some":"json_1"}`},
+	{RepositoryName: "aHello_test_1", Tag: "test_tag_1_2", Digest: "sha256:02", ConfigDigest: "sha256:c1", Size: 1500, PullCounter: 1, Raw: `{"some":"json_2"}`},
+	{RepositoryName: "aHello_test_2", Tag: "test_tag_2", Digest: "sha256:03", ConfigDigest: "sha256:c2", Size: 709, PullCounter: 1, Raw: `{"some":"json_2"}`},
+	{RepositoryName: "bHello_test_3", Tag: "test_tag_3", Digest: "sha256:04", ConfigDigest: "sha256:c3", Size: 710, PullCounter: 1, Raw: `{"some":"json_3"}`},
+	{RepositoryName: "bHello_test_4", Tag: "test_tag_4", Digest: "sha256:05", ConfigDigest: "sha256:c4", Size: 711, PullCounter: 1, Raw: `{"some":"json_4"}`},
+	{RepositoryName: "bHello_test_4", Tag: "test_tag_4_1", Digest: "sha256:06", ConfigDigest: "sha256:c4", Size: 711, PullCounter: 1, Raw: `{"some":"json_4_1"}`},
+}
+
+func TestPostgres_CreateRepository(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	testEntry := entries[0]
+	require.NoError(t, db.CreateRepository(ctx, &testEntry))
+	assert.Greater(t, testEntry.ID, int64(0))
+
+	result, err := db.GetRepository(ctx, testEntry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, testEntry, result)
+
+	_, err = db.GetRepository(ctx, -1)
+	assert.Error(t, err)
+
+	// test for duplicate name entry
+	assert.ErrorIs(t, db.CreateRepository(ctx, &testEntry), engine.ErrDuplicateEntry)
+
+	// test with empty required fields
+	testEntry.RepositoryName = ""
+	assert.Error(t, db.CreateRepository(ctx, &testEntry))
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestPostgres_FindRepositories(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	for _, entry := range entries {
+		tmpEntry := entry
+		require.NoError(t, db.CreateRepository(ctx, &tmpEntry))
+	}
+
+	result, err := db.FindRepositories(ctx, engine.QueryFilter{
+		Range:   [2]int64{0, 2},
+		Filters: map[string]interface{}{"q": "aHello_test"},
+		Sort:    []string{"id", "asc"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	assert.Equal(t, 2, len(result.Data))
+
+	// group by repository name with summary size of tags
+	result, err = db.FindRepositories(ctx, engine.QueryFilter{
+		Range:        [2]int64{0, 10},
+		Sort:         []string{"repository_name", "asc"},
+		GroupByField: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Total)
+	require.Equal(t, 4, len(result.Data))
+	assert.Equal(t, "aHello_test_1", result.Data[0].(store.RegistryEntry).RepositoryName)
+	assert.Equal(t, int64(2208), result.Data[0].(store.RegistryEntry).Size)
+	assert.Equal(t, int64(1422), result.Data[3].(store.RegistryEntry).Size)
+
+	// repositories by user access
+	require.NoError(t, db.CreateAccess(ctx, &store.Access{Name: "a", Owner: 5, Type: "repository", ResourceName: "bHello_test_4", Action: "pull"}))
+	require.NoError(t, db.CreateAccess(ctx, &store.Access{Name: "b", Owner: 5, Type: "repository", ResourceName: "bHello_test_4", Action: "push"}))
+
+	result, err = db.FindRepositories(ctx, engine.QueryFilter{
+		Filters:      map[string]interface{}{engine.RepositoriesByUserAccess: int64(5)},
+		GroupByField: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	require.Equal(t, 1, len(result.Data))
+	assert.Equal(t, int64(1422), result.Data[0].(store.RegistryEntry).Size)
+
+	result, err = db.FindRepositories(ctx, engine.QueryFilter{
+		Filters: map[string]interface{}{engine.RepositoriesByUserAccess: int64(5)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+	assert.Equal(t, 2, len(result.Data))
+
+	ctxCancel()
+	wg.Wait()
+}
+
+func TestPostgres_UpdateAndDeleteRepository(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	var wg = new(sync.WaitGroup)
+	db := prepareTestDB(ctx, t, wg) // defined test store
+
+	syncDate := time.Now().Unix()
+	for _, entry := range entries {
+		tmpEntry := entry
+		tmpEntry.Timestamp = syncDate - 100
+		require.NoError(t, db.CreateRepository(ctx, &tmpEntry))
+	}
+
+	condition := map[string]interface{}{
+		store.RegistryRepositoryNameField: "aHello_test_1",
+		store.RegistryTagField:            "test_tag_1",
+	}
+	data := map[string]interface{}{
+		store.RegistrySizeNameField:  int64(1000),
+		store.RegistryTimestampField: syncDate,
+		store.RegistryRawField:       []byte(`{"some":"updated"}`),
+	}
+	require.NoError(t, db.UpdateRepository(ctx, condition, data))
+
+	result, err := db.FindRepositories(ctx, engine.QueryFilter{Filters: condition})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	updated := result.Data[0].(store.RegistryEntry)
+	assert.Equal(t, int64(1000), updated.Size)
+	assert.Equal(t, `{"some":"updated"}`, updated.Raw)
+
+	assert.Error(t, db.UpdateRepository(ctx, map[string]interface{}{"tag": "unknown"}, data))
+	assert.Error(t, db.UpdateRepository(ctx, map[string]interface{}{"tag = '' OR 1": 1}, data))
+
+	assert.NoError(t, db.DeleteRepository(ctx, "aHello_test_2", "sha256:03"))
+	assert.ErrorIs(t, db.DeleteRepository(ctx, "aHello_test_2", "sha256:03"), engine.ErrNotFound)
+
+	// all entries except updated should be removed
+	require.NoError(t, db.RepositoryGarbageCollector(ctx, syncDate))
+	result, err = db.FindRepositories(ctx, engine.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+
+	ctxCancel()
+	wg.Wait()
+}
@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const robotFields = "id,name,description,secret,disabled,expires_at,created_at,permissions"
+
+// CreateRobot create a new robot account record
+func (p *Postgres) CreateRobot(ctx context.Context, robot *store.Robot) (err error) {
+
+	var emptyParams []string
+
+	// check required parameters filled
+	if robot.Name == "" {
+		emptyParams = append(emptyParams, "Name")
+	}
+
+	if robot.Secret == "" {
+		emptyParams = append(emptyParams, "Secret")
+	}
+
+	if len(emptyParams) > 0 {
+		return fmt.Errorf("required robot fields not set: %s", strings.Join(emptyParams, ", "))
+	}
+
+	// a secret restored from backup is already hashed
+	if !engine.IsPasswordHashed(ctx) {
+		if errHash := robot.HashSecret(); errHash != nil {
+			return errHash
+		}
+	}
+
+	if robot.CreatedAt == 0 {
+		robot.CreatedAt = time.Now().Unix()
+	}
+
+	permissions, err := json.Marshal(robot.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode robot permissions")
+	}
+
+	createRobotSQL := fmt.Sprintf(`INSERT INTO %s (
+		name,
+		description,
+		secret,
+		disabled,
+		expires_at,
+		created_at,
+		permissions
+	) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`, robotsTable)
+
+	err = p.conn(ctx).QueryRowContext(ctx, createRobotSQL, robot.Name, robot.Description, robot.Secret, robot.Disabled,
+		robot.ExpiresAt, robot.CreatedAt, string(permissions)).Scan(&robot.ID)
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to add new robot")
+	}
+	return nil
+}
+
+// GetRobot get robot account data by ID or by name
+func (p *Postgres) GetRobot(ctx context.Context, id interface{}) (robot store.Robot, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", robotFields, robotsTable)
+
+	switch val := id.(type) {
+	case string:
+		// cast ID value when ID has name value
+		if _, errParse := strconv.ParseInt(val, 10, 64); errParse != nil {
+			queryString = fmt.Sprintf("SELECT %s FROM %s WHERE name = $1", robotFields, robotsTable)
+		}
+	case int, int64:
+	default:
+		return robot, errors.New("unsupported id type")
+	}
+
+	robot, err = scanRobot(p.conn(ctx).QueryRowContext(ctx, queryString, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return robot, fmt.Errorf("robot %w", engine.ErrNotFound)
+	}
+	return robot, err
+}
+
+// FindRobots fetch list of robot accounts by filter values
+func (p *Postgres) FindRobots(ctx context.Context, filter engine.QueryFilter, withSecret bool) (robots engine.ListResponse, err error) {
+	f, err := filtersBuilder(robotsTable, filter, "name", "description")
+	if err != nil {
+		return robots, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", robotFields, robotsTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	if robots.Total = p.getTotalRecordsExcludeRange(ctx, robotsTable, filter, []string{"name", "description"}); robots.Total == 0 {
+		return robots, nil
+	}
+
+	rows, err := p.conn(ctx).QueryContext(ctx, rebind(queryString), f.args...)
+	if err != nil {
+		return robots, errors.Wrap(err, "failed to get robots list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	robots.Data = []interface{}{}
+	for rows.Next() {
+		robot, errScan := scanRobot(rows.Scan)
+		if errScan != nil {
+			return robots, errScan
+		}
+
+		if !withSecret {
+			robot.Secret = ""
+		}
+		robots.Data = append(robots.Data, robot)
+	}
+
+	return robots, rows.Err()
+}
+
+// UpdateRobot update robot account data
+func (p *Postgres) UpdateRobot(ctx context.Context, robot store.Robot) (err error) {
+	if robot.Secret != "" && !engine.IsPasswordHashed(ctx) {
+		if errHash := robot.HashSecret(); errHash != nil {
+			return errHash
+		}
+	}
+
+	permissions, err := json.Marshal(robot.Permissions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode robot permissions")
+	}
+
+	queryString := fmt.Sprintf("UPDATE %s SET name=$1, description=$2, disabled=$3, expires_at=$4, permissions=$5", robotsTable)
+	args := []interface{}{robot.Name, robot.Description, robot.Disabled, robot.ExpiresAt, string(permissions)}
+
+	// skip a secret field update if updating secret value is empty
+	if robot.Secret != "" {
+		queryString += ", secret=$6 WHERE id = $7"
+		args = append(args, robot.Secret, robot.ID)
+	} else {
+		queryString += " WHERE id = $6"
+		args = append(args, robot.ID)
+	}
+
+	res, err := p.conn(ctx).ExecContext(ctx, queryString, args...)
+	if err != nil {
+		return errors.Wrap(checkDuplicateError(err), "failed to update robot data")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("robot %w", engine.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteRobot delete robot account record by ID
+func (p *Postgres) DeleteRobot(ctx context.Context, id int64) (err error) {
+	res, err := p.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", robotsTable), id)
+	if err != nil {
+		return errors.Wrap(err, "failed execute query for robot delete")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// scanRobot reads robot data with scan function of a row, permissions of robot store as JSON text
+func scanRobot(scan func(dest ...interface{}) error) (robot store.Robot, err error) {
+	var permissions string
+	if err = scan(&robot.ID, &robot.Name, &robot.Description, &robot.Secret, &robot.Disabled, &robot.ExpiresAt,
+		&robot.CreatedAt, &permissions); err != nil {
+		return robot, errors.Wrap(err, "failed scan robot data")
+	}
+
+	if err = json.Unmarshal([]byte(permissions), &robot.Permissions); err != nil {
+		return robot, errors.Wrap(err, "failed to decode robot permissions")
+	}
+	return robot, nil
+}
@@ -0,0 +1,285 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/zebox/registry-admin/app/store"
+	"github.com/zebox/registry-admin/app/store/engine"
+)
+
+const minPasswordLength = 6
+
+// userFields are columns of users table in order of scanUser arguments
+const userFields = "id,login,name,password,role,user_group,disabled,description," +
+	"totp_enabled,totp_secret,totp_recovery_codes,sso_id,totp_last_step"
+
+// CreateUser create a new user record
+func (p *Postgres) CreateUser(ctx context.Context, user *store.User) (err error) {
+
+	var emptyParams []string
+
+	// check required parameters filled
+	if user.Login == "" {
+		emptyParams = append(emptyParams, "Login")
+	}
+
+	if user.Name == "" {
+		emptyParams = append(emptyParams, "Name")
+	}
+	passwordHashed := engine.IsPasswordHashed(ctx)
+	if !passwordHashed && len(user.Password) < minPasswordLength {
+		emptyParams = append(emptyParams, fmt.Sprintf("password length should be equal or more %d characters", minPasswordLength))
+	}
+
+	roleExist, err := p.isRoleExist(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if !roleExist {
+		emptyParams = append(emptyParams, fmt.Sprintf("role '%s' not allowed", user.Role))
+	}
+
+	if len(emptyParams) > 0 {
+		return fmt.Errorf("required user fields not set: %s", strings.Join(emptyParams, ", "))
+	}
+
+	if user.Group == 0 {
+		user.Group = 1
+	}
+
+	// hashing password value, a password restored from backup is already hashed
+	if !passwordHashed {
+		if errHash := user.HashAndSalt(); errHash != nil {
+			return errHash
+		}
+	}
+
+	createUserSQL := fmt.Sprintf(`INSERT INTO %s (
+		login,
+		name,
+		password,
+		role,
+		user_group,
+		disabled,
+		description,
+		sso_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`, usersTable)
+
+	// a new user becomes a member of the primary group within the same transaction
+	return p.WithTx(ctx, func(ctx context.Context) error {
+		err := p.conn(ctx).QueryRowContext(ctx, createUserSQL, user.Login, user.Name, user.Password, user.Role, user.Group, user.Disabled,
+			user.Description, user.SSOID).Scan(&user.ID)
+		if err != nil {
+			return multierror.Append(checkDuplicateError(err), errors.New("failed to add new user"))
+		}
+		return p.addPrimaryGroupMember(ctx, *user)
+	})
+}
+
+// GetUser get data by user ID
+func (p *Postgres) GetUser(ctx context.Context, id interface{}) (user store.User, err error) {
+	queryString := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", userFields, usersTable)
+
+	switch val := id.(type) {
+	case string:
+		// cast ID value when ID has login value
+		if _, errParse := strconv.ParseInt(val, 10, 64); errParse != nil {
+			queryString = fmt.Sprintf("SELECT %s FROM %s WHERE login = $1", userFields, usersTable)
+		}
+	case int, int64:
+	default:
+		return user, errors.New("unsupported id type")
+	}
+
+	user, err = scanUser(p.conn(ctx).QueryRowContext(ctx, queryString, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, engine.ErrNotFound
+	}
+	if err != nil {
+		return user, multierror.Append(err, errors.New("failed to get user"))
+	}
+	return user, nil
+}
+
+// FindUsers fetch list of user by filter values
+func (p *Postgres) FindUsers(ctx context.Context, filter engine.QueryFilter, withPassword bool) (users engine.ListResponse, err error) {
+	f, err := filtersBuilder(usersTable, filter, "login", "name")
+	if err != nil {
+		return users, err
+	}
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s", userFields, usersTable, f.allClauses) //nolint:gosec // all values passed to query as params
+
+	// total calculates before fetch rows because a transaction connection can't run a query while rows of other one are read
+	if users.Total = p.getTotalRecordsExcludeRange(ctx, usersTable, filter, []string{"login", "name"}); users.Total == 0 {
+		return users, nil // may be error handler catch
+	}
+
+	rows, err := p.conn(ctx).QueryContext(ctx, rebind(queryString), f.args...)
+	if err != nil {
+		return users, errors.Wrap(err, "failed to get users list")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	users.Data = []interface{}{}
+	for rows.Next() {
+		user, errScan := scanUser(rows.Scan)
+		if errScan != nil {
+			return users, errors.Wrap(errScan, "failed scan user data")
+		}
+
+		// clear password value and second factor secrets when user fetch
+		if !withPassword {
+			user.Password = ""
+			user.TOTP.Secret, user.TOTP.RecoveryCodes = "", nil
+		}
+
+		users.Data = append(users.Data, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateUser update user records data
+func (p *Postgres) UpdateUser(ctx context.Context, user store.User) (err error) {
+
+	roleExist, err := p.isRoleExist(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if !roleExist {
+		return errors.Errorf("role '%s' not allowed", user.Role)
+	}
+	if user.Password != "" && !engine.IsPasswordHashed(ctx) {
+		if len(user.Password) < minPasswordLength {
+			return fmt.Errorf("password length should be equal or more %d characters", minPasswordLength)
+		}
+
+		if errHash := user.HashAndSalt(); errHash != nil {
+			return errHash
+		}
+	}
+
+	// a changed primary group is added to the user groups, membership in the previous one is kept
+	return p.WithTx(ctx, func(ctx context.Context) (err error) {
+		var res sql.Result
+		if user.Password != "" {
+			res, err = p.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET name=$1, password=$2, role=$3, user_group=$4, disabled=$5, description=$6 WHERE id = $7", usersTable),
+				user.Name, user.Password, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+
+		} else {
+			// skip a password field update if updating password value is empty
+			res, err = p.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET name=$1, role=$2, user_group=$3, disabled=$4, description=$5 WHERE id = $6", usersTable),
+				user.Name, user.Role, user.Group, user.Disabled, user.Description, user.ID)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to update user data")
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return errors.New("record didn't update")
+		}
+
+		return p.addPrimaryGroupMember(ctx, user)
+	})
+}
+
+// UpdateUserTOTP update two-factor authentication settings of user, recovery codes hashes store as JSON text
+func (p *Postgres) UpdateUserTOTP(ctx context.Context, id int64, totp store.TOTP) (err error) {
+	recoveryCodes, err := json.Marshal(totp.RecoveryCodes)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recovery codes")
+	}
+
+	res, err := p.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET totp_enabled=$1, totp_secret=$2, totp_recovery_codes=$3 WHERE id = $4", usersTable),
+		totp.Enabled, totp.Secret, string(recoveryCodes), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update user totp")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateUserTOTPStep stores the time step of an accepted one-time password, the stored step is changed only when
+// it's before the new one, so concurrent requests with the same code can't both be accepted
+func (p *Postgres) UpdateUserTOTPStep(ctx context.Context, id, step int64) (err error) {
+	res, err := p.conn(ctx).ExecContext(ctx, fmt.Sprintf("UPDATE %s SET totp_last_step=$1 WHERE id = $2 AND totp_last_step < $3", usersTable),
+		step, id, step)
+	if err != nil {
+		return errors.Wrap(err, "failed to update user totp step")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUser delete user record by ID, membership of the user in groups and refresh tokens of the user are deleted too
+func (p *Postgres) DeleteUser(ctx context.Context, id int64) (err error) {
+	return p.WithTx(ctx, func(ctx context.Context) error {
+		res, err := p.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", usersTable), id)
+		if err != nil {
+			return errors.Wrapf(err, "failed execute query for user delete")
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return engine.ErrNotFound
+		}
+
+		if _, err = p.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", groupMembersTable), id); err != nil {
+			return errors.Wrap(err, "failed to delete user from groups")
+		}
+
+		_, err = p.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", refreshTokensTable), id)
+		return errors.Wrap(err, "failed to delete refresh tokens of user")
+	})
+}
+
+// scanUser reads user data with scan function of a row
+func scanUser(scan func(dest ...interface{}) error) (user store.User, err error) {
+	var recoveryCodes string
+	err = scan(&user.ID, &user.Login, &user.Name, &user.Password, &user.Role, &user.Group, &user.Disabled, &user.Description,
+		&user.TOTP.Enabled, &user.TOTP.Secret, &recoveryCodes, &user.SSOID, &user.TOTP.LastStep)
+	if err != nil {
+		return user, err
+	}
+
+	if recoveryCodes != "" {
+		if err = json.Unmarshal([]byte(recoveryCodes), &user.TOTP.RecoveryCodes); err != nil {
+			return user, errors.Wrap(err, "failed to decode recovery codes")
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPasswordMinLength is a minimal length of passwords which users set with API
+const DefaultPasswordMinLength = 8
+
+// commonPasswords are the most frequent passwords from leaked databases, they are always denied
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "1234567", "12345", "1234", "111111", "000000", "123123",
+	"654321", "666666", "121212", "112233", "987654321", "1q2w3e4r", "1q2w3e4r5t", "1qaz2wsx", "qwerty", "qwerty123",
+	"qwertyuiop", "asdfghjkl", "zxcvbnm", "password", "password1", "password123", "passw0rd", "p@ssw0rd", "admin",
+	"admin123", "administrator", "root", "toor", "letmein", "welcome", "welcome1", "iloveyou", "monkey", "dragon",
+	"master", "sunshine", "princess", "football", "baseball", "superman", "trustno1", "abc123", "changeme", "secret",
+	"registry", "docker", "default", "guest", "test", "test123", "qazwsx", "access", "login", "starwars",
+}
+
+// PasswordPolicy defines requirements to passwords which users set with API
+type PasswordPolicy struct {
+	MinLength int
+	denied    map[string]bool
+}
+
+// NewPasswordPolicy makes a policy which denies common passwords and passwords from the extra denylist
+func NewPasswordPolicy(minLength int, denylist ...string) *PasswordPolicy {
+	p := &PasswordPolicy{MinLength: minLength, denied: make(map[string]bool, len(commonPasswords)+len(denylist))}
+	p.Deny(commonPasswords...)
+	p.Deny(denylist...)
+	return p
+}
+
+// Deny adds passwords to the denylist, it's case-insensitive
+func (p *PasswordPolicy) Deny(passwords ...string) {
+	for _, v := range passwords {
+		if v = strings.TrimSpace(v); v != "" {
+			p.denied[strings.ToLower(v)] = true
+		}
+	}
+}
+
+// ReadDenylist adds passwords to the denylist from a text with a password per line, lines started with '#' are skipped
+func (p *PasswordPolicy) ReadDenylist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := scanner.Text(); !strings.HasPrefix(line, "#") {
+			p.Deny(line)
+		}
+	}
+	return errors.Wrap(scanner.Err(), "failed to read password denylist")
+}
+
+// Validate checks a new password of the user, the password shouldn't be equal to login
+func (p *PasswordPolicy) Validate(login, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return errors.Errorf("password length should be equal or more %d characters", p.MinLength)
+	}
+
+	lower := strings.ToLower(password)
+	if p.denied[lower] {
+		return errors.New("password is too common")
+	}
+
+	if login != "" && lower == strings.ToLower(login) {
+		return errors.New("password shouldn't be equal to login")
+	}
+	return nil
+}
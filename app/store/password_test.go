@@ -0,0 +1,46 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	p := NewPasswordPolicy(DefaultPasswordMinLength, "CorpName2023")
+
+	tbl := []struct {
+		login, password string
+		err             string
+	}{
+		{"john", "correct-horse-battery", ""},
+		{"john", "пароль-для-входа", ""},
+		{"john", "short", "password length should be equal or more 8 characters"},
+		{"john", "пароль", "password length should be equal or more 8 characters"},
+		{"john", "Password123", "password is too common"},
+		{"john", "QWERTYUIOP", "password is too common"},
+		{"john", "corpname2023", "password is too common"},
+		{"john_doe", "John_Doe", "password shouldn't be equal to login"},
+	}
+
+	for i, tt := range tbl {
+		err := p.Validate(tt.login, tt.password)
+		if tt.err == "" {
+			assert.NoError(t, err, "case #%d", i)
+			continue
+		}
+		assert.EqualError(t, err, tt.err, "case #%d", i)
+	}
+}
+
+func TestPasswordPolicy_ReadDenylist(t *testing.T) {
+	p := NewPasswordPolicy(1)
+	require.NoError(t, p.ReadDenylist(strings.NewReader("# leaked passwords\nsummer2023\n\n  Winter2023  \n")))
+
+	assert.Error(t, p.Validate("john", "summer2023"))
+	assert.Error(t, p.Validate("john", "winter2023"))
+	assert.NoError(t, p.Validate("john", "# leaked passwords"), "comments aren't added")
+	assert.NoError(t, p.Validate("john", "autumn2023"))
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// refreshTokenLength is a number of random bytes of generated refresh token
+const refreshTokenLength = 32
+
+// RefreshToken allows a registry client to get access tokens of the user without credentials, e.g. docker requests
+// one with 'docker login' and keeps it instead of the password. The store keeps a hash of the token, a plain value
+// is returned to the client only once when issued.
+type RefreshToken struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	ClientID   string `json:"client_id"` // a client which requested the token, e.g. 'docker', the token is accepted from the same client only
+	Hash       string `json:"-"`
+	CreatedAt  int64  `json:"created_at"`
+	ExpiresAt  int64  `json:"expires_at"`   // unix time after which the token isn't accepted
+	LastUsedAt int64  `json:"last_used_at"` // unix time when the token was used the last time, zero means never
+}
+
+// IsExpired checks that the token expired at the moment
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return now.Unix() >= t.ExpiresAt
+}
+
+// NewRefreshToken generates a random value of refresh token and returns it with a hash which the store keeps
+func NewRefreshToken() (token, hash string, err error) {
+	b := make([]byte, refreshTokenLength)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", errors.Wrap(err, "failed to generate refresh token")
+	}
+	token = hex.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns a hash of refresh token value. Tokens have enough entropy to be looked up
+// by a fast hash unlike passwords.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
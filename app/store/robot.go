@@ -0,0 +1,122 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RobotLoginPrefix starts logins of robot accounts, it separates robots from users with the same name
+// when a client authenticates with basic credentials, e.g. 'docker login -u robot$ci-builder'
+const RobotLoginPrefix = "robot$"
+
+// robotSecretLength is a number of random bytes of generated robot secret
+const robotSecretLength = 32
+
+// robotNameRegExp allows names which can be used as a part of docker login
+var robotNameRegExp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Robot is an account for automated clients such as CI pipelines. It has own generated secret,
+// an optional expiry date and explicit list of permissions instead of access rules of users and groups.
+type Robot struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Secret      string            `json:"secret,omitempty"` // hash of the secret, a plain value is shown only once when generated
+	Disabled    bool              `json:"disabled"`
+	ExpiresAt   int64             `json:"expires_at"` // unix time after which credentials aren't accepted, zero means never
+	CreatedAt   int64             `json:"created_at"`
+	Permissions []RobotPermission `json:"permissions"`
+}
+
+// RobotPermission grants actions on resources which match the resource name, the name can be a pattern
+type RobotPermission struct {
+	Type         string   `json:"type"`
+	ResourceName string   `json:"resource_name"`
+	Actions      []string `json:"actions"`
+}
+
+// Login returns a name which robot uses for authenticate with basic credentials
+func (r *Robot) Login() string {
+	return RobotLoginPrefix + r.Name
+}
+
+// IsExpired checks that robot credentials expired at the moment
+func (r *Robot) IsExpired(now time.Time) bool {
+	return r.ExpiresAt > 0 && now.Unix() >= r.ExpiresAt
+}
+
+// Validate checks the robot name and that all permissions have known resource types, allowed actions and resource names
+func (r *Robot) Validate() error {
+	if !robotNameRegExp.MatchString(r.Name) {
+		return fmt.Errorf("robot name %q is invalid, allowed letters, digits and '.', '_', '-' characters only", r.Name)
+	}
+
+	for _, p := range r.Permissions {
+		if len(p.Actions) == 0 {
+			return fmt.Errorf("permission for %s:%s hasn't actions", p.Type, p.ResourceName)
+		}
+		for _, action := range p.Actions {
+			if err := validateResourceAction(p.Type, p.ResourceName, action); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AllowedActions returns the subset of requested actions which robot permissions grant on the resource,
+// all permissions which match the resource apply together
+func (r *Robot) AllowedActions(resourceType, resourceName string, actions []string) []string {
+	granted := make(map[string]bool)
+	for _, p := range r.Permissions {
+		if p.Type != resourceType || !MatchResource(p.ResourceName, resourceName) {
+			continue
+		}
+		for _, action := range p.Actions {
+			granted[action] = true
+		}
+	}
+
+	var allowed []string
+	for _, action := range actions {
+		if granted[action] || granted[ActionAll] {
+			allowed = append(allowed, action)
+		}
+	}
+	return allowed
+}
+
+// HashSecret encrypted robot secret
+func (r *Robot) HashSecret() error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(r.Secret), bcrypt.DefaultCost)
+	if err != nil {
+		return multierror.Append(err, errors.New("failed to crypt robot secret"))
+	}
+	r.Secret = string(hash)
+	return nil
+}
+
+// NewRobotSecret generates a random secret for robot account
+func NewRobotSecret() (string, error) {
+	b := make([]byte, robotSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate robot secret")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RobotNameFromLogin returns a robot name when login has the robot prefix
+func RobotNameFromLogin(login string) (string, bool) {
+	if !strings.HasPrefix(login, RobotLoginPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(login, RobotLoginPrefix), true
+}
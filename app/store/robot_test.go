@@ -0,0 +1,80 @@
+package store
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRobot_Validate(t *testing.T) {
+	tests := []struct {
+		robot Robot
+		valid bool
+	}{
+		{robot: Robot{Name: "ci-builder"}, valid: true},
+		{robot: Robot{Name: "ci.builder_1", Permissions: []RobotPermission{
+			{Type: RepositoryResourceType, ResourceName: "team-a/*", Actions: []string{"pull", "push"}},
+			{Type: RegistryResourceType, ResourceName: RegistryCatalogName, Actions: []string{ActionAll}},
+		}}, valid: true},
+		{robot: Robot{Name: ""}},
+		{robot: Robot{Name: "ci$builder"}},
+		{robot: Robot{Name: "ci-builder", Permissions: []RobotPermission{{Type: RepositoryResourceType, ResourceName: "team-a/app"}}}},
+		{robot: Robot{Name: "ci-builder", Permissions: []RobotPermission{
+			{Type: RepositoryResourceType, ResourceName: "team-a/app", Actions: []string{"pull", "pull:push"}},
+		}}},
+		{robot: Robot{Name: "ci-builder", Permissions: []RobotPermission{
+			{Type: RegistryResourceType, ResourceName: "team-a/app", Actions: []string{ActionAll}},
+		}}},
+	}
+
+	for i, tt := range tests {
+		err := tt.robot.Validate()
+		if tt.valid {
+			assert.NoError(t, err, "test %d", i)
+			continue
+		}
+		assert.Error(t, err, "test %d", i)
+	}
+}
+
+func TestRobot_AllowedActions(t *testing.T) {
+	robot := Robot{Permissions: []RobotPermission{
+		{Type: RepositoryResourceType, ResourceName: "team-a/**", Actions: []string{"pull"}},
+		{Type: RepositoryResourceType, ResourceName: "team-a/app", Actions: []string{"push"}},
+		{Type: RepositoryResourceType, ResourceName: "team-b/*", Actions: []string{ActionAll}},
+	}}
+
+	assert.Equal(t, []string{"pull", "push"}, robot.AllowedActions(RepositoryResourceType, "team-a/app", []string{"pull", "push", "delete"}))
+	assert.Equal(t, []string{"pull"}, robot.AllowedActions(RepositoryResourceType, "team-a/nested/app", []string{"pull", "push"}))
+	assert.Equal(t, []string{"pull", "push", "delete"}, robot.AllowedActions(RepositoryResourceType, "team-b/app", []string{"pull", "push", "delete"}))
+	assert.Empty(t, robot.AllowedActions(RepositoryResourceType, "team-c/app", []string{"pull"}))
+	assert.Empty(t, robot.AllowedActions(RegistryResourceType, RegistryCatalogName, []string{ActionAll}))
+}
+
+func TestRobot_IsExpired(t *testing.T) {
+	now := time.Now()
+	assert.False(t, (&Robot{}).IsExpired(now))
+	assert.False(t, (&Robot{ExpiresAt: now.Add(time.Hour).Unix()}).IsExpired(now))
+	assert.True(t, (&Robot{ExpiresAt: now.Add(-time.Hour).Unix()}).IsExpired(now))
+}
+
+func TestRobot_Secret(t *testing.T) {
+	secret, err := NewRobotSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 2*robotSecretLength)
+
+	robot := Robot{Name: "ci-builder", Secret: secret}
+	require.NoError(t, robot.HashSecret())
+	assert.True(t, regexp.MustCompile(`^\$2[ayb]\$.{56}$`).MatchString(robot.Secret))
+	assert.True(t, ComparePassword(robot.Secret, secret))
+
+	name, ok := RobotNameFromLogin(robot.Login())
+	assert.True(t, ok)
+	assert.Equal(t, "ci-builder", name)
+
+	_, ok = RobotNameFromLogin("ci-builder")
+	assert.False(t, ok)
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Permissions of portal API which roles grant, a permission name consists of a section and an operation
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionGroupsRead  = "groups:read"
+	PermissionGroupsWrite = "groups:write"
+	PermissionAccessRead  = "access:read"
+	PermissionAccessWrite = "access:write"
+	PermissionRobotsRead  = "robots:read"
+	PermissionRobotsWrite = "robots:write"
+	PermissionRolesRead   = "roles:read"
+	PermissionRolesWrite  = "roles:write"
+
+	// PermissionRegistryRead allows browsing all repositories of the catalog and image details,
+	// users without it see repositories which access rules allow them to pull only
+	PermissionRegistryRead   = "registry:read"
+	PermissionRegistryDelete = "registry:delete"
+	PermissionRegistrySync   = "registry:sync"
+	PermissionRegistryKeys   = "registry:keys"
+
+	// PermissionRegistryAll grants all actions on all repositories to docker clients, access rules aren't checked
+	PermissionRegistryAll = "registry:all"
+)
+
+// permissions is the list of all known permissions in the order which they're shown to users
+var permissions = []string{
+	PermissionUsersRead, PermissionUsersWrite,
+	PermissionGroupsRead, PermissionGroupsWrite,
+	PermissionAccessRead, PermissionAccessWrite,
+	PermissionRobotsRead, PermissionRobotsWrite,
+	PermissionRolesRead, PermissionRolesWrite,
+	PermissionRegistryRead, PermissionRegistryDelete, PermissionRegistrySync, PermissionRegistryKeys, PermissionRegistryAll,
+}
+
+// roleNameRegExp allows names which can be used in settings and claims mapping without quoting, a name starts
+// with a letter, so it isn't confused with an ID when a role is got by name
+var roleNameRegExp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._-]*$`)
+
+// Role is a named set of portal permissions which is assigned to users. Built-in roles are created with a storage
+// and can't be changed or deleted, custom roles are managed with API.
+type Role struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	BuiltIn     bool     `json:"built_in"`
+	Permissions []string `json:"permissions"`
+}
+
+// Permissions returns all known permissions
+func Permissions() []string {
+	return append([]string{}, permissions...)
+}
+
+// BuiltInRoles returns roles which every storage has, they keep rights of roles existed before custom roles
+func BuiltInRoles() []Role {
+	return []Role{
+		{
+			Name:        AdminRole,
+			Description: "Full access to users, accesses and entries of repositories",
+			BuiltIn:     true,
+			Permissions: Permissions(),
+		},
+		{
+			Name:        ManagerRole,
+			Description: "Browse users, accesses and entries of all repositories",
+			BuiltIn:     true,
+			Permissions: []string{PermissionUsersRead, PermissionGroupsRead, PermissionAccessRead, PermissionRobotsRead,
+				PermissionRolesRead, PermissionRegistryRead},
+		},
+		{
+			Name:        UserRole,
+			Description: "Browse entries of assigned repositories only",
+			BuiltIn:     true,
+			Permissions: []string{},
+		},
+	}
+}
+
+// Validate checks the role name and that all permissions are known and not repeated
+func (r *Role) Validate() error {
+	if !roleNameRegExp.MatchString(r.Name) {
+		return fmt.Errorf("role name %q is invalid, allowed letters, digits and '.', '_', '-' characters only, "+
+			"the first character should be a letter", r.Name)
+	}
+
+	seen := make(map[string]bool, len(r.Permissions))
+	for _, p := range r.Permissions {
+		if !containsString(permissions, p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("permission %q repeated", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// HasPermission checks that the role grants the permission
+func (r *Role) HasPermission(permission string) bool {
+	return containsString(r.Permissions, permission)
+}
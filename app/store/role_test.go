@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Validate(t *testing.T) {
+	tests := []struct {
+		role  Role
+		valid bool
+	}{
+		{role: Role{Name: "auditor", Permissions: []string{PermissionUsersRead, PermissionAccessRead}}, valid: true},
+		{role: Role{Name: "release-bot.v2", Permissions: []string{}}, valid: true},
+		{role: Role{Name: "", Permissions: []string{PermissionUsersRead}}},
+		{role: Role{Name: "bad name", Permissions: []string{PermissionUsersRead}}},
+		{role: Role{Name: "100", Permissions: []string{PermissionUsersRead}}},
+		{role: Role{Name: "auditor", Permissions: []string{"users:delete"}}},
+		{role: Role{Name: "auditor", Permissions: []string{PermissionUsersRead, PermissionUsersRead}}},
+	}
+
+	for _, tt := range tests {
+		err := tt.role.Validate()
+		if tt.valid {
+			assert.NoError(t, err, "role %+v", tt.role)
+			continue
+		}
+		assert.Error(t, err, "role %+v", tt.role)
+	}
+}
+
+func TestBuiltInRoles(t *testing.T) {
+	roles := BuiltInRoles()
+	assert.Len(t, roles, 3)
+
+	for _, r := range roles {
+		assert.True(t, r.BuiltIn)
+		assert.NoError(t, r.Validate())
+		assert.True(t, CheckRoleInList(r.Name))
+	}
+
+	admin, manager, user := roles[0], roles[1], roles[2]
+	assert.ElementsMatch(t, Permissions(), admin.Permissions)
+	assert.True(t, manager.HasPermission(PermissionRegistryRead))
+	assert.False(t, manager.HasPermission(PermissionUsersWrite))
+	assert.False(t, user.HasPermission(PermissionRegistryRead))
+}
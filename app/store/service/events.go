@@ -36,80 +36,83 @@ func (ds *DataService) RepositoryEventsProcessing(ctx context.Context, envelope
 
 // updateRepositoryEntry will create repository entry if it doesn't exist or update when already exist
 func (ds *DataService) updateRepositoryEntry(ctx context.Context, event notifications.Event) error {
-	filter := engine.QueryFilter{
-		Filters: map[string]interface{}{"repository_name": event.Target.Repository, "tag": event.Target.Tag},
-	}
-
-	result, err := ds.Storage.FindRepositories(ctx, filter)
-	if err != nil {
-		return err
-	}
-
-	// increase pull counter when repo pull
-	if event.Action == notifications.EventActionPull && result.Total == 1 {
+	// search and create or update steps run in one transaction for avoid a race with other events of the same tag
+	return ds.Storage.WithTx(ctx, func(ctx context.Context) error {
+		filter := engine.QueryFilter{
+			Filters: map[string]interface{}{"repository_name": event.Target.Repository, "tag": event.Target.Tag},
+		}
 
-		// When 'manifests' API calls, registry triggers pull event, but sync operation use this API for fetch data from
-		// registry. For avoid race between sync and pull event triggers uses check for syncing or garbage collector
-		// operation is in progress
-		if ds.isWorking.Load().(bool) {
-			return nil
+		result, err := ds.Storage.FindRepositories(ctx, filter)
+		if err != nil {
+			return err
 		}
 
-		repositoryEntry := result.Data[0].(store.RegistryEntry)
+		// increase pull counter when repo pull
+		if event.Action == notifications.EventActionPull && result.Total == 1 {
 
-		err = ds.Storage.UpdateRepository(
-			ctx,
-			map[string]interface{}{"id": repositoryEntry.ID},                        // condition
-			map[string]interface{}{"pull_counter": repositoryEntry.PullCounter + 1}, // data for update
-		)
-		return err
-	}
+			// When 'manifests' API calls, registry triggers pull event, but sync operation use this API for fetch data from
+			// registry. For avoid race between sync and pull event triggers uses check for syncing or garbage collector
+			// operation is in progress
+			if ds.isWorking.Load().(bool) {
+				return nil
+			}
 
-	eventRawBytes, errJSON := json.Marshal(event)
-	if errJSON != nil {
-		return errors.Wrap(errJSON, "failed to marshaled raw data of event")
-	}
+			repositoryEntry := result.Data[0].(store.RegistryEntry)
 
-	if result.Total == 0 {
-		digest := event.Target.Descriptor.Digest.String()
-		configDigest := ""
-		var targetSize int64
-		for _, ref := range event.Target.References {
-			targetSize += ref.Size
-			if ref.MediaType == schema2.MediaTypeImageConfig {
-				configDigest = ref.Digest.String()
-			}
+			err = ds.Storage.UpdateRepository(
+				ctx,
+				map[string]interface{}{"id": repositoryEntry.ID},                        // condition
+				map[string]interface{}{"pull_counter": repositoryEntry.PullCounter + 1}, // data for update
+			)
+			return err
 		}
-		if digest == "" || configDigest == "" || event.Target.Tag == "" {
-			log.Printf("[WARN] content or config digest is empty for repo: %s and tag %s", event.Target.Repository, event.Target.Tag)
-			return nil
+
+		eventRawBytes, errJSON := json.Marshal(event)
+		if errJSON != nil {
+			return errors.Wrap(errJSON, "failed to marshaled raw data of event")
 		}
 
-		repositoryEntry := &store.RegistryEntry{
-			RepositoryName: event.Target.Repository,
-			Tag:            event.Target.Tag,
-			Digest:         digest,
-			ConfigDigest:   configDigest,
-			Size:           targetSize,
-			Timestamp:      event.Timestamp.Unix(),
-			Raw:            string(eventRawBytes),
+		if result.Total == 0 {
+			digest := event.Target.Descriptor.Digest.String()
+			configDigest := ""
+			var targetSize int64
+			for _, ref := range event.Target.References {
+				targetSize += ref.Size
+				if ref.MediaType == schema2.MediaTypeImageConfig {
+					configDigest = ref.Digest.String()
+				}
+			}
+			if digest == "" || configDigest == "" || event.Target.Tag == "" {
+				log.Printf("[WARN] content or config digest is empty for repo: %s and tag %s", event.Target.Repository, event.Target.Tag)
+				return nil
+			}
+
+			repositoryEntry := &store.RegistryEntry{
+				RepositoryName: event.Target.Repository,
+				Tag:            event.Target.Tag,
+				Digest:         digest,
+				ConfigDigest:   configDigest,
+				Size:           targetSize,
+				Timestamp:      event.Timestamp.Unix(),
+				Raw:            string(eventRawBytes),
+			}
+			err = ds.Storage.CreateRepository(ctx, repositoryEntry)
+			return err
 		}
-		err = ds.Storage.CreateRepository(ctx, repositoryEntry)
-		return err
-	}
 
-	if result.Total == 1 {
-		repositoryEntry := result.Data[0].(store.RegistryEntry)
+		if result.Total == 1 {
+			repositoryEntry := result.Data[0].(store.RegistryEntry)
 
-		err = ds.Storage.UpdateRepository(
-			ctx,
-			map[string]interface{}{"id": repositoryEntry.ID},                                                             // condition
-			map[string]interface{}{"size": event.Target.Size, "timestamp": event.Timestamp.Unix(), "raw": eventRawBytes}, // data for update
-		)
-		return err
-	}
+			err = ds.Storage.UpdateRepository(
+				ctx,
+				map[string]interface{}{"id": repositoryEntry.ID},                                                             // condition
+				map[string]interface{}{"size": event.Target.Size, "timestamp": event.Timestamp.Unix(), "raw": eventRawBytes}, // data for update
+			)
+			return err
+		}
 
-	return errors.Errorf("query filter returned multiple result: %v+", filter.Filters)
+		return errors.Errorf("query filter returned multiple result: %v+", filter.Filters)
+	})
 }
 
 // deleteRepositoryEntry deletes repository entry by an event delete
@@ -127,9 +130,10 @@ func (ds *DataService) deleteRepositoryEntry(ctx context.Context, event notifica
 		return nil
 	}
 
-	if err := ds.Storage.DeleteRepository(ctx, event.Target.Repository, digest.String()); err != nil && err != engine.ErrNotFound {
-		return errors.Errorf("failed to delete image entry digest: %s err: %v", digest, err)
-	}
-
-	return ds.Storage.AccessGarbageCollector(ctx)
+	return ds.Storage.WithTx(ctx, func(ctx context.Context) error {
+		if err := ds.Storage.DeleteRepository(ctx, event.Target.Repository, digest.String()); err != nil && err != engine.ErrNotFound {
+			return errors.Errorf("failed to delete image entry digest: %s err: %v", digest, err)
+		}
+		return ds.Storage.AccessGarbageCollector(ctx)
+	})
 }
@@ -173,6 +173,10 @@ func prepareEngineMock() *engine.InterfaceMock {
 	}
 
 	return &engine.InterfaceMock{
+		WithTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		},
+
 		CreateRepositoryFunc: func(ctx context.Context, entry *store.RegistryEntry) error {
 
 			for _, testEntry := range testRepositoriesEntries {
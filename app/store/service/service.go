@@ -8,7 +8,6 @@ import (
 	"github.com/zebox/registry-admin/app/registry"
 	"github.com/zebox/registry-admin/app/store"
 	"github.com/zebox/registry-admin/app/store/engine"
-	"strings"
 	"sync/atomic"
 	"time"
 
@@ -115,34 +114,17 @@ func (ds *DataService) doSyncRepositories(ctx context.Context) {
 							Timestamp:      now,
 							Raw:            string(rawManifestData),
 						}
-						if errCreate := ds.Storage.CreateRepository(ctx, entry); errCreate != nil {
-							if !strings.HasPrefix(errCreate.Error(), "UNIQUE") {
-								log.Printf("[ERROR] failed to marshal manifest data from repo '%s' for tag '%s' err: %s", repo, tag, errCreate)
-								log.Printf("[ERROR] sync operation aborted")
-								return
-							}
-
-							// if repositories with specific tag already exist the service try update dynamic field and set a new timestamp.
-							// Then timestamp using for garbage collector for detect outdated data and remove one from repository store
-							log.Printf("[DEBUG] entry already exist and will update : repo: '%s', tag: '%s'", repo, tag)
-
-							condition := map[string]interface{}{
-								store.RegistryRepositoryNameField: repo,
-								store.RegistryTagField:            tag,
-							}
-
-							fieldForUpdate := map[string]interface{}{
-								store.RegistrySizeNameField:  manifest.TotalSize,
-								store.RegistryTimestampField: now,
-							}
-
-							if errUpdate := ds.Storage.UpdateRepository(ctx, condition, fieldForUpdate); errUpdate != nil {
-								log.Printf("[ERROR] sync operation aborted: repo '%s' for tag '%s' err: %s", repo, tag, errUpdate)
-								return
-							}
+						created, errSave := ds.saveSyncedEntry(ctx, entry)
+						if errSave != nil {
+							log.Printf("[ERROR] sync operation aborted: repo '%s' for tag '%s' err: %s", repo, tag, errSave)
+							return
+						}
+
+						if created {
+							log.Printf("[DEBUG] New entry added: repo: '%s', tag: '%s'", repo, tag)
 							continue
 						}
-						log.Printf("[DEBUG] New entry added: repo: '%s', tag: '%s'", repo, tag)
+						log.Printf("[DEBUG] entry already exist and updated : repo: '%s', tag: '%s'", repo, tag)
 					}
 				}
 
@@ -178,6 +160,35 @@ func (ds *DataService) doSyncRepositories(ctx context.Context) {
 	}
 }
 
+// saveSyncedEntry creates a new repository entry or updates existing one in a single transaction
+func (ds *DataService) saveSyncedEntry(ctx context.Context, entry *store.RegistryEntry) (created bool, err error) {
+	err = ds.Storage.WithTx(ctx, func(ctx context.Context) error {
+		condition := map[string]interface{}{
+			store.RegistryRepositoryNameField: entry.RepositoryName,
+			store.RegistryTagField:            entry.Tag,
+		}
+
+		existed, errFind := ds.Storage.FindRepositories(ctx, engine.QueryFilter{Filters: condition})
+		if errFind != nil {
+			return errFind
+		}
+
+		if existed.Total == 0 {
+			created = true
+			return ds.Storage.CreateRepository(ctx, entry)
+		}
+
+		// if repositories with specific tag already exist the service try update dynamic field and set a new timestamp.
+		// Then timestamp using for garbage collector for detect outdated data and remove one from repository store
+		fieldForUpdate := map[string]interface{}{
+			store.RegistrySizeNameField:  entry.Size,
+			store.RegistryTimestampField: entry.Timestamp,
+		}
+		return ds.Storage.UpdateRepository(ctx, condition, fieldForUpdate)
+	})
+	return created, err
+}
+
 // RepositoriesMaintenance check repositories for outdated or updated data in repository storage
 // with 'lastSyncDate' value. Timestamp field update at every sync call in repository storage
 // and compare with 'lastSyncDate' variable.
@@ -206,6 +217,10 @@ func (ds *DataService) RepositoriesMaintenance(ctx context.Context, timeout int6
 		if err := ds.doGarbageCollector(syncCtx); err != nil {
 			log.Printf("[ERROR] %v", err)
 		}
+
+		if err := ds.disableExpiredAccesses(syncCtx); err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
 	}
 
 	// starting garbage collector background task
@@ -231,14 +246,54 @@ func (ds *DataService) doGarbageCollector(ctx context.Context) error {
 		return errNoSyncedYet
 	}
 
-	if err := ds.Storage.RepositoryGarbageCollector(ctx, lastSyncDate); err != nil {
-		return fmt.Errorf("repositories garbage collector aborted with error: %v", err)
-	}
+	err := ds.Storage.WithTx(ctx, func(ctx context.Context) error {
+		if err := ds.Storage.RepositoryGarbageCollector(ctx, lastSyncDate); err != nil {
+			return fmt.Errorf("repositories garbage collector aborted with error: %v", err)
+		}
+
+		if err := ds.Storage.AccessGarbageCollector(ctx); err != nil {
+			return fmt.Errorf("access garbage collector aborted with error: %v", err)
+		}
 
-	if err := ds.Storage.AccessGarbageCollector(ctx); err != nil {
-		return fmt.Errorf("access garbage collector aborted with error: %v", err)
+		if err := ds.Storage.RefreshTokenGarbageCollector(ctx, time.Now().Unix()); err != nil {
+			return fmt.Errorf("refresh tokens garbage collector aborted with error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("[DEBUG] garbage collector task complete")
 	return nil
 }
+
+// disableExpiredAccesses marks access rules which validity window is over as disabled. Expired rules don't apply
+// anyway, the task makes their state visible in the access list and logs them for audit.
+func (ds *DataService) disableExpiredAccesses(ctx context.Context) error {
+	filter := engine.QueryFilter{Filters: map[string]interface{}{
+		engine.AccessExpiresBefore: time.Now().Unix(),
+		"disabled":                 false,
+	}}
+
+	accesses, err := ds.Storage.FindAccesses(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find expired accesses: %v", err)
+	}
+
+	for _, v := range accesses.Data {
+		access := v.(store.Access)
+		access.Disabled = true
+		if err = ds.Storage.UpdateAccess(ctx, access); err != nil {
+			return fmt.Errorf("failed to disable expired access %d: %v", access.ID, err)
+		}
+
+		ownerType := "user"
+		if access.IsGroup {
+			ownerType = "group"
+		}
+		log.Printf("[INFO] access '%s' (id %d) of %s %d to %s:%s:%s expired at %s and disabled", access.Name, access.ID,
+			ownerType, access.Owner, access.Type, access.ResourceName, access.Action, time.Unix(access.ExpiresAt, 0).Format(time.RFC3339))
+	}
+	return nil
+}
@@ -76,7 +76,10 @@ func TestDataService_SyncExistedRepositories(t *testing.T) {
 
 	t.Log("test with fake errors")
 
-	repositoryStore = make(map[string]store.RegistryEntry) // clear data in mock registry
+	// clear data in mock registry, the map is cleared in place because storage mock holds it
+	for k := range repositoryStore {
+		delete(repositoryStore, k)
+	}
 
 	ctx = context.WithValue(context.Background(), ctxKey, errorCreate)
 	testDS.doSyncRepositories(ctx)
@@ -136,6 +139,56 @@ func TestDataService_RepositoriesMaintaining(t *testing.T) {
 	assert.Equal(t, errNoSyncedYet, err)
 }
 
+func TestDataService_disableExpiredAccesses(t *testing.T) {
+	now := time.Now().Unix()
+	accesses := map[int64]store.Access{
+		1: {ID: 1, Name: "unlimited", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "pull"},
+		2: {ID: 2, Name: "expired", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "push", ExpiresAt: now - 60},
+		3: {ID: 3, Name: "expired group", Owner: 3, IsGroup: true, Type: "repository", ResourceName: "foo/*", Action: "pull", ExpiresAt: now - 3600},
+		4: {ID: 4, Name: "active", Owner: 2, Type: "repository", ResourceName: "foo/bar", Action: "delete", ExpiresAt: now + 3600},
+	}
+
+	storageMock := &engine.InterfaceMock{
+		FindAccessesFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+			result := engine.ListResponse{Data: []interface{}{}}
+			expiresBefore := filter.Filters[engine.AccessExpiresBefore].(int64)
+			for id := int64(1); id <= int64(len(accesses)); id++ {
+				a := accesses[id]
+				if !a.Disabled && a.ExpiresAt > 0 && a.ExpiresAt <= expiresBefore {
+					result.Data = append(result.Data, a)
+				}
+			}
+			result.Total = int64(len(result.Data))
+			return result, nil
+		},
+		UpdateAccessFunc: func(ctx context.Context, access store.Access) error {
+			accesses[access.ID] = access
+			return nil
+		},
+	}
+
+	testDS := &DataService{Storage: storageMock}
+	require.NoError(t, testDS.disableExpiredAccesses(context.Background()))
+	assert.Len(t, storageMock.UpdateAccessCalls(), 2)
+	assert.False(t, accesses[1].Disabled)
+	assert.True(t, accesses[2].Disabled)
+	assert.True(t, accesses[3].Disabled)
+	assert.False(t, accesses[4].Disabled)
+
+	// disabled accesses aren't updated again
+	require.NoError(t, testDS.disableExpiredAccesses(context.Background()))
+	assert.Len(t, storageMock.UpdateAccessCalls(), 2)
+
+	storageMock.UpdateAccessFunc = func(ctx context.Context, access store.Access) error { return errors.New("update error") }
+	accesses[5] = store.Access{ID: 5, Name: "expired", ExpiresAt: now - 1}
+	assert.Error(t, testDS.disableExpiredAccesses(context.Background()))
+
+	storageMock.FindAccessesFunc = func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+		return engine.ListResponse{}, errors.New("find error")
+	}
+	assert.Error(t, testDS.disableExpiredAccesses(context.Background()))
+}
+
 var (
 	errorCreate   = errors.New("failed to create entry in registry")
 	errorManifest = errors.New("failed to get manifest data")
@@ -261,6 +314,10 @@ func prepareStorageMock(repositoryStore map[string]store.RegistryEntry) *engine.
 	}
 
 	return &engine.InterfaceMock{
+		WithTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		},
+
 		CreateRepositoryFunc: func(ctx context.Context, entry *store.RegistryEntry) error {
 
 			// emit fake error
@@ -276,6 +333,14 @@ func prepareStorageMock(repositoryStore map[string]store.RegistryEntry) *engine.
 			return nil
 		},
 
+		FindRepositoriesFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+			entryName := fmt.Sprintf("%v_%v", filter.Filters[store.RegistryRepositoryNameField], filter.Filters[store.RegistryTagField])
+			if entry, ok := repositoryStore[entryName]; ok {
+				return engine.ListResponse{Total: 1, Data: []interface{}{entry}}, nil
+			}
+			return engine.ListResponse{}, nil
+		},
+
 		UpdateRepositoryFunc: func(ctx context.Context, conditionClause map[string]interface{}, data map[string]interface{}) error {
 
 			// search entry first
@@ -299,6 +364,14 @@ func prepareStorageMock(repositoryStore map[string]store.RegistryEntry) *engine.
 		AccessGarbageCollectorFunc: func(ctx context.Context) error {
 			return ctxCheckFn(ctx, keyAccessGcError)
 		},
+
+		RefreshTokenGarbageCollectorFunc: func(ctx context.Context, expiredBefore int64) error {
+			return nil
+		},
+
+		FindAccessesFunc: func(ctx context.Context, filter engine.QueryFilter) (engine.ListResponse, error) {
+			return engine.ListResponse{}, nil
+		},
 	}
 }
 
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // RFC 6238 default algorithm which authenticator apps support
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TOTP parameters which are compatible with common authenticator apps
+const (
+	totpSecretLength = 20 // bytes of secret, 160 bits are recommended by RFC 4226
+	totpDigits       = 6
+	totpPeriod       = 30 // seconds
+	totpSkew         = 1  // number of periods before and after the current one which codes are accepted
+
+	// TOTPRecoveryCodesCount is a number of recovery codes which are issued on enrolment
+	TOTPRecoveryCodesCount = 10
+	recoveryCodeLength     = 5 // random bytes of a recovery code, it's formatted as 'xxxxx-xxxxx'
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP holds settings of time-based one-time password which is the second factor of user authentication.
+// The secret is pending until the user confirms enrolment with a valid code.
+type TOTP struct {
+	Enabled       bool     `json:"enabled"`
+	Secret        string   `json:"-"` // base32 encoded secret
+	RecoveryCodes []string `json:"-"` // hashes of unused recovery codes
+	LastStep      int64    `json:"-"` // time step of the last accepted code, it's updated with engine UpdateUserTOTPStep only
+}
+
+// NewTOTPSecret generates a random base32 encoded secret
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate totp secret")
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// ProvisioningURI returns 'otpauth' URI which authenticator apps scan as QR code
+func (t *TOTP) ProvisioningURI(issuer, account string) string {
+	params := url.Values{}
+	params.Set("secret", t.Secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// Validate checks a code for the time, codes of adjacent periods are accepted for clock drift of devices
+func (t *TOTP) Validate(code string, now time.Time) bool {
+	_, ok := t.MatchStep(code, now)
+	return ok
+}
+
+// MatchStep returns the time step which a valid code is calculated for. Codes of the last accepted step
+// and earlier ones are refused, so a code can't be replayed when the accepted step is stored.
+func (t *TOTP) MatchStep(code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits || t.Secret == "" {
+		return 0, false
+	}
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(t.Secret))
+	if err != nil {
+		return 0, false
+	}
+
+	counter := now.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := counter + int64(i)
+		if step <= t.LastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, uint64(step))), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes replaces recovery codes with new ones and returns plain codes, they are shown to the user once
+func (t *TOTP) NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, TOTPRecoveryCodesCount)
+	hashes := make([]string, 0, TOTPRecoveryCodesCount)
+	for i := 0; i < TOTPRecoveryCodesCount; i++ {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.Wrap(err, "failed to generate recovery code")
+		}
+		code := hex.EncodeToString(b)
+		code = code[:recoveryCodeLength] + "-" + code[recoveryCodeLength:]
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to crypt recovery code")
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, string(hash))
+	}
+	t.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// UseRecoveryCode removes a matched recovery code, every code can be used once only
+func (t *TOTP) UseRecoveryCode(code string) bool {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for i, hash := range t.RecoveryCodes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			t.RecoveryCodes = append(t.RecoveryCodes[:i:i], t.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode calculates a code with HOTP algorithm (RFC 4226) for the counter
+func totpCode(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP_Validate(t *testing.T) {
+	// test vectors of RFC 6238 for SHA1, the last six digits of eight-digit codes
+	totp := TOTP{Secret: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))}
+
+	tbl := []struct {
+		ts   int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tbl {
+		assert.True(t, totp.Validate(tt.code, time.Unix(tt.ts, 0)), "code %s at %d", tt.code, tt.ts)
+	}
+
+	now := time.Unix(1111111109, 0)
+	assert.True(t, totp.Validate("081 804", now), "spaces ignored")
+	assert.True(t, totp.Validate("081804", now.Add(30*time.Second)), "previous period accepted")
+	assert.False(t, totp.Validate("081804", now.Add(90*time.Second)))
+	assert.False(t, totp.Validate("081805", now))
+	assert.False(t, totp.Validate("81804", now))
+	assert.False(t, (&TOTP{}).Validate("081804", now))
+	assert.False(t, (&TOTP{Secret: "!!!"}).Validate("081804", now))
+}
+
+func TestTOTP_MatchStep(t *testing.T) {
+	totp := TOTP{Secret: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))}
+	now := time.Unix(1111111109, 0)
+
+	step, ok := totp.MatchStep("081804", now)
+	require.True(t, ok)
+	assert.Equal(t, int64(1111111109/30), step)
+
+	// codes of the last accepted step and earlier ones are refused
+	totp.LastStep = step
+	_, ok = totp.MatchStep("081804", now)
+	assert.False(t, ok)
+	assert.False(t, totp.Validate("081804", now.Add(30*time.Second)))
+
+	totp.LastStep = step - 1
+	assert.True(t, totp.Validate("081804", now))
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	other, err := NewTOTPSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestTOTP_ProvisioningURI(t *testing.T) {
+	totp := TOTP{Secret: "JBSWY3DPEHPK3PXP"}
+	uri, err := url.Parse(totp.ProvisioningURI("Registry Admin", "john"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Registry Admin:john", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "Registry Admin", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
+
+func TestTOTP_RecoveryCodes(t *testing.T) {
+	totp := TOTP{}
+	codes, err := totp.NewRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, TOTPRecoveryCodesCount)
+	require.Len(t, totp.RecoveryCodes, TOTPRecoveryCodesCount)
+	assert.Regexp(t, `^[0-9a-f]{5}-[0-9a-f]{5}$`, codes[0])
+	assert.NotContains(t, totp.RecoveryCodes, codes[0], "codes are stored as hashes")
+
+	assert.True(t, totp.UseRecoveryCode(" "+strings.ToUpper(codes[3])+" "))
+	assert.Len(t, totp.RecoveryCodes, TOTPRecoveryCodesCount-1)
+	assert.False(t, totp.UseRecoveryCode(codes[3]), "code used once only")
+	assert.False(t, totp.UseRecoveryCode("00000-00000"))
+	assert.True(t, totp.UseRecoveryCode(codes[0]))
+	assert.Len(t, totp.RecoveryCodes, TOTPRecoveryCodesCount-2)
+}
@@ -7,9 +7,9 @@ import (
 	"log"
 )
 
-// Role which define access right for different user
+// Built-in roles which define access right for different user, permissions of them are defined with BuiltInRoles
 // admin - has full access for entire registry end-points
-// manager - allow pull,push and delete for type 'repository'
+// manager - allow browse users, accesses and all repositories
 // a repository type defined with https://docs.docker.com/registry/spec/auth/scope/
 // NOTE: don't change order of items, it's expose values by indexes
 const (
@@ -18,7 +18,7 @@ const (
 	UserRole    = "user"
 )
 
-// roles is the list for validation new user to assign with a specify role
+// roles is the list of built-in roles
 var (
 	roles = []string{AdminRole, ManagerRole, UserRole}
 )
@@ -29,10 +29,15 @@ type User struct {
 	Name        string `json:"name"`
 	Login       string `json:"login"`
 	Password    string `json:"password"`
-	Role        string `json:"role"`  // role name selected by index from roles item
-	Group       int64  `json:"group"` // reference to group ID
+	Role        string `json:"role"`  // name of a built-in or a custom role
+	Group       int64  `json:"group"` // reference to ID of the primary group, a user is always a member of it
 	Disabled    bool   `json:"blocked"`
 	Description string `json:"description"`
+	TOTP        TOTP   `json:"totp"` // second factor of authentication, it's updated with engine UpdateUserTOTP only
+
+	// SSOID binds a user provisioned by single sign-on or created from LDAP directory to the provider and the subject
+	// of identity, it's set on create only and local users have it empty
+	SSOID string `json:"sso_id,omitempty"`
 }
 
 // Group holds user group
@@ -42,6 +47,12 @@ type Group struct {
 	Description string `json:"description"`
 }
 
+// GroupMember holds membership of a user in a group, a user can be a member of several groups at once
+type GroupMember struct {
+	GroupID int64 `json:"group_id"`
+	UserID  int64 `json:"user_id"`
+}
+
 // HashAndSalt encrypted user password
 func (u *User) HashAndSalt() error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
@@ -66,7 +77,7 @@ func ComparePassword(passwordHash, passwordString string) bool {
 	return true
 }
 
-// CheckRoleInList checking role assigned to user when add or update for exist in roles (allowed role list roles)
+// CheckRoleInList checks that the role is one of built-in roles
 func CheckRoleInList(role string) bool {
 	for _, existedRole := range roles {
 		if role == existedRole {
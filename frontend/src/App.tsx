@@ -16,6 +16,7 @@ import dataProvider from './providers/dataProvider';
 import users from './users';
 import groups from './groups';
 import access from './access';
+import roles from './roles';
 import repository from './registry';
 
 const history = createBrowserHistory();
@@ -63,6 +64,7 @@ function App() {
         <Resource name="access" {...access} />
         <Resource name="users" {...users} />
         <Resource name="groups" {...groups} />
+        <Resource name="roles" {...roles} />
       </Admin>
       <Footer />
     </React.Fragment>
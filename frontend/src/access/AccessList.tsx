@@ -1,4 +1,4 @@
-import { useEffect, useState } from "react";
+import { useEffect, useMemo, useState } from "react";
 import {
     List,
     Datagrid,
@@ -20,9 +20,17 @@ import {requirePermission} from '../helpers/Helpers'
 import {Box, Card, CardContent} from '@mui/material';
 import WorkspacePremiumIcon from '@mui/icons-material/WorkspacePremium';
 import SwapVerticalCircleOutlinedIcon from '@mui/icons-material/SwapVerticalCircleOutlined';
+import EventBusyIcon from '@mui/icons-material/EventBusy';
+import {ExpiresAtField} from './ValidityInput';
+import {AccessEffectField} from './EffectInput';
+
+// expiringPeriod is a period in seconds, the 'expires soon' filter selects accesses which expire within it
+const expiringPeriod = 7 * 24 * 60 * 60;
 
 const AccessFilterSidebar = () => {
     const translate = useTranslate();
+    // the time is fixed while the sidebar is shown, otherwise the selected filter item isn't recognized after render
+    const expiresBefore = useMemo(() => Math.floor(Date.now() / 1000) + expiringPeriod, []);
     return <Box
         sx={{
             display: {
@@ -50,6 +58,11 @@ const AccessFilterSidebar = () => {
                     <FilterListItem label="- PULL" value={{action: 'pull'}}/>
                     <FilterListItem label="- PUSH" value={{action: 'push'}}/>
                 </FilterList>
+                <FilterList label={translate('resources.accesses.labels.label_validity')}
+                            icon={<EventBusyIcon/>}>
+                    <FilterListItem label={translate('resources.accesses.labels.label_expires_soon')}
+                                    value={{expires_before: expiresBefore, disabled: false}}/>
+                </FilterList>
             </CardContent>
         </Card>
     </Box>
@@ -62,7 +75,7 @@ const AccessList = (props: any) => {
 
     return <List
         aside={<AccessFilterSidebar />}
-        hasCreate={requirePermission(permissions, 'admin')}
+        hasCreate={requirePermission(permissions, 'access:write')}
         sort={{ field: 'name', order: 'ASC' }}
         title={translate(`resources.commands.access_name`)}
         perPage={25}
@@ -75,9 +88,11 @@ const AccessList = (props: any) => {
             <CustomUsersReference label={translate('resources.accesses.fields.owner_id')} />
             <TextField source="resource_name" label={translate('resources.accesses.fields.resource_name')} />
             <TextField source="action" label={translate('resources.accesses.fields.action')} />
+            <AccessEffectField source="effect" label={translate('resources.accesses.fields.effect')} />
             <TextField source="name" label={translate('resources.accesses.fields.name')} />
+            <ExpiresAtField source="expires_at" label={translate('resources.accesses.fields.expires_at')} />
             <DisabledField source="disabled" label={translate('resources.accesses.fields.disabled')} />         
-            {requirePermission(permissions, 'admin') ? <>
+            {requirePermission(permissions, 'access:write') ? <>
                 <EditButton alignIcon="right" />
                 <DeleteCustomButtonWithConfirmation source="name" {...props} />
             </> : null}
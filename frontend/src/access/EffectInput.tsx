@@ -0,0 +1,33 @@
+import { FunctionField, required, SelectInput, useTranslate } from 'react-admin';
+
+// deny accesses take precedence over allow ones, accesses without the effect allow actions
+const effectDeny = 'deny';
+
+export const AccessEffectSelector = (props: any) => {
+    const translate = useTranslate();
+
+    return <SelectInput
+        label={translate('resources.accesses.fields.effect')}
+        source="effect"
+        defaultValue="allow"
+        validate={required()}
+        helperText={translate('resources.accesses.messages.deny_precedence')}
+        choices={[
+            { id: 'allow', name: translate('resources.accesses.labels.label_effect_allow') },
+            { id: effectDeny, name: translate('resources.accesses.labels.label_effect_deny') },
+        ]}
+        {...props}
+    />
+};
+
+// AccessEffectField shows the effect of an access
+export const AccessEffectField = (props: any) => {
+    const translate = useTranslate();
+
+    return <FunctionField
+        {...props}
+        render={(record: any) => record && record.effect === effectDeny ?
+            translate('resources.accesses.labels.label_effect_deny') :
+            translate('resources.accesses.labels.label_effect_allow')}
+    />
+};
@@ -0,0 +1,45 @@
+import { useEffect } from 'react';
+import { required, SelectInput, TextInput, useTranslate } from 'react-admin';
+import { useFormContext, useWatch } from 'react-hook-form';
+import { RepositoryAutocomplete } from '../components/RepositoryAutocompleteField';
+
+// registryCatalogName is the only resource of the 'registry' type, it grants access to the catalog listing
+const registryCatalogName = 'catalog';
+
+export const ResourceTypeSelector = (props: any) => {
+    const translate = useTranslate();
+
+    return <SelectInput
+        label={translate('resources.accesses.fields.resource_type')}
+        source="type"
+        defaultValue="repository"
+        validate={required()}
+        choices={[
+            { id: 'repository', name: translate('resources.accesses.labels.label_type_repository') },
+            { id: 'registry', name: translate('resources.accesses.labels.label_type_registry') },
+        ]}
+        {...props}
+    />
+};
+
+export const ResourceNameInput = (props: any) => {
+    const translate = useTranslate();
+    const { getValues, setValue } = useFormContext();
+    const resourceType = useWatch({ name: 'type' });
+
+    useEffect(() => {
+        if (resourceType === 'registry' && getValues('resource_name') !== registryCatalogName) {
+            setValue('resource_name', registryCatalogName, { shouldDirty: true });
+        }
+    }, [resourceType, getValues, setValue]);
+
+    return resourceType === 'registry' ?
+        <TextInput
+            sx={{ width: 300 }}
+            label={translate('resources.accesses.fields.resource_name')}
+            source="resource_name"
+            defaultValue={registryCatalogName}
+            disabled
+        /> :
+        <RepositoryAutocomplete source="resource_name" validate={required()} {...props} />
+};
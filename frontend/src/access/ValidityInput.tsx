@@ -0,0 +1,50 @@
+import { DateTimeInput, FunctionField, useTranslate } from 'react-admin';
+import { Box } from '@mui/material';
+
+// validity window of an access is stored as unix time in seconds, zero means the access isn't limited
+const pad = (value: number) => value.toString().padStart(2, '0');
+
+// formatUnixTime converts unix time to a value of the 'datetime-local' input in the local timezone
+const formatUnixTime = (value: number) => {
+    if (!value) {
+        return '';
+    }
+    const date = new Date(value * 1000);
+    return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())}T${pad(date.getHours())}:${pad(date.getMinutes())}`;
+};
+
+const parseUnixTime = (value: string) => value ? Math.floor(new Date(value).getTime() / 1000) : 0;
+
+const validateValidity = (value: number, values: any) => {
+    if (value && values.not_before && value <= values.not_before) {
+        return 'resources.accesses.messages.expires_before_applies';
+    }
+    return undefined;
+};
+
+export const AccessValidityInputs = () => {
+    const translate = useTranslate();
+
+    return <Box sx={{ display: 'flex', gap: 2 }}>
+        <DateTimeInput
+            label={translate('resources.accesses.fields.not_before')}
+            source="not_before"
+            format={formatUnixTime}
+            parse={parseUnixTime}
+        />
+        <DateTimeInput
+            label={translate('resources.accesses.fields.expires_at')}
+            source="expires_at"
+            format={formatUnixTime}
+            parse={parseUnixTime}
+            validate={validateValidity}
+        />
+    </Box>
+};
+
+// ExpiresAtField shows the expiry time of an access, unlimited accesses have an empty value
+export const ExpiresAtField = (props: any) =>
+    <FunctionField
+        render={(record: any) => record && record.expires_at ? new Date(record.expires_at * 1000).toLocaleString() : ''}
+        {...props}
+    />;
@@ -1,13 +1,29 @@
+import { useEffect } from 'react';
 import FormControl from '@mui/material/FormControl';
 import FormHelperText from '@mui/material/FormHelperText';
 import Select from '@mui/material/Select';
 import InputLabel from '@mui/material/InputLabel';
 import MenuItem from '@mui/material/MenuItem';
 import { useInput, useTranslate } from 'react-admin';
+import { useFormContext, useWatch } from 'react-hook-form';
 
+// actions which can be granted for each resource type, the list is the same as the API validates
+export const resourceTypeActions: { [type: string]: string[] } = {
+    repository: ['pull', 'push', 'delete', '*'],
+    registry: ['*'],
+};
+
+const actionLabels: { [action: string]: string } = {
+    pull: 'Pull',
+    push: 'Push',
+    delete: 'Delete',
+};
 
 const RepositoryAction = (props:any) => {
     const translate = useTranslate();
+    const { setValue } = useFormContext();
+    const resourceType = useWatch({ name: 'type' }) || 'repository';
+    const actions = resourceTypeActions[resourceType] || [];
     const {
         field,
         fieldState: { isTouched,  error },
@@ -15,6 +31,13 @@ const RepositoryAction = (props:any) => {
         isRequired
     } = useInput(props);
 
+    // reset an action which isn't allowed for the selected resource type
+    useEffect(() => {
+        if (actions.length > 0 && !actions.includes(field.value)) {
+            setValue(field.name, actions[0], { shouldDirty: true });
+        }
+    }, [resourceType]); // eslint-disable-line react-hooks/exhaustive-deps
+
     return (
         <FormControl sx={{  minWidth: 180 }}>
          <InputLabel id="repository-action-select">{translate('resources.accesses.fields.action')}</InputLabel>
@@ -26,8 +49,11 @@ const RepositoryAction = (props:any) => {
             {...field}
             required={isRequired}
         >
-            <MenuItem value="pull">Pull</MenuItem>
-            <MenuItem value="push">Push</MenuItem>
+            {actions.map((action) =>
+                <MenuItem key={action} value={action}>
+                    {actionLabels[action] || translate('resources.accesses.labels.label_all_actions')}
+                </MenuItem>
+            )}
         </Select>
         <FormHelperText>{(isTouched || isSubmitted) && error ? error.message : ''}</FormHelperText>
         </FormControl>
@@ -5,7 +5,7 @@ export const GroupCreate = () => {
     const translate = useTranslate();
     const {permissions} = usePermissions();
 
-    return (requirePermission(permissions, 'admin') ?
+    return (requirePermission(permissions, 'groups:write') ?
             <Create title={translate('resources.groups.add_title')}>
                 <SimpleForm>
                     <TextInput source="name" autoComplete="new-name"/>
@@ -5,7 +5,7 @@ import {requirePermission} from "../helpers/Helpers";
 const UserEdit = () => {
     const translate = useTranslate();
     const {permissions} = usePermissions();
-    return (requirePermission(permissions, 'admin') ?
+    return (requirePermission(permissions, 'groups:write') ?
             <Edit title={translate('resources.groups.edit_title')}>
                 <SimpleForm>
                     <TextInput label="ID" source="id" disabled/>
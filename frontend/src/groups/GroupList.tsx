@@ -17,8 +17,9 @@ const GroupList = (props: any) => {
     const { permissions } = usePermissions();
 
     return (
-        requirePermission(permissions, 'admin') ?
+        requirePermission(permissions, 'groups:read') ?
             <List
+                hasCreate={requirePermission(permissions, 'groups:write')}
                 sort={{ field: 'name', order: 'ASC' }}
                 perPage={25}
                 filters={SearchFieldTranslated(translate)}
@@ -29,8 +30,10 @@ const GroupList = (props: any) => {
                     }}>
                     <TextField source="id" />
                     <TextField source="name" />
-                    <EditButton />
-                    <DeleteCustomButtonWithConfirmation source="name" {...props} />
+                    {requirePermission(permissions, 'groups:write') ? <>
+                        <EditButton />
+                        <DeleteCustomButtonWithConfirmation source="name" {...props} />
+                    </> : null}
                 </Datagrid>
             </List> : <NotFound />
     )
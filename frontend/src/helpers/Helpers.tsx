@@ -49,7 +49,9 @@ export const ParseSizeToReadable=(bytes:any,decimals:number=2):string=> {
 }
 
 
-// requirePermission call for check user permission for access to features or a UI element
-export const requirePermission = (permissions: any, role: string): boolean => {
-    return permissions && permissions.role && permissions.role === role;
+// requirePermission call for check user permission for access to features or a UI element,
+// permissions of the user role are passed with 'permissions' attribute of the token user, e.g. 'users:write'
+export const requirePermission = (permissions: any, permission: string): boolean => {
+    return Boolean(permissions && permissions.attrs && Array.isArray(permissions.attrs.permissions) &&
+        permissions.attrs.permissions.includes(permission));
 }
\ No newline at end of file
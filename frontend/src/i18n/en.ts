@@ -16,6 +16,7 @@ const customEnglishMessages: TranslationMessages = {
         commands: {
             users_name: "Users",
             groups_name: "Groups",
+            roles_name: "Roles",
             access_name: "Accesses",
             repository_name: "Repositories"
         },
@@ -44,6 +45,40 @@ const customEnglishMessages: TranslationMessages = {
 
             }
         },
+        roles: {
+            name: "Roles",
+            add_title: "Add new role",
+            edit_title: "Edit role",
+            fields: {
+                name: "Role name",
+                description: "Description",
+                permissions: "Permissions",
+                built_in: "Built-in"
+            },
+            labels: {
+                label_no_permissions: "No permissions"
+            },
+            messages: {
+                invalid_name: "Allowed letters, digits and '.', '_', '-' characters, the first character should be a letter"
+            },
+            permissions: {
+                users_read: "Browse users",
+                users_write: "Manage users",
+                groups_read: "Browse groups",
+                groups_write: "Manage groups",
+                access_read: "Browse accesses",
+                access_write: "Manage accesses",
+                robots_read: "Browse robot accounts",
+                robots_write: "Manage robot accounts",
+                roles_read: "Browse roles",
+                roles_write: "Manage roles",
+                registry_read: "Browse all repositories and image details",
+                registry_delete: "Delete image tags",
+                registry_sync: "Synchronize repositories with registry",
+                registry_keys: "Rotate token signing keys",
+                registry_all: "Push and pull any repository with docker client"
+            }
+        },
         accesses: {
             name: "Accesses",
             add_title: "Add new access",
@@ -53,16 +88,28 @@ const customEnglishMessages: TranslationMessages = {
                 owner_id: "User",
                 resource_type: "Resource type",
                 resource_name: "Repository name",
-                action: "Allowed action",
-                disabled: "Disabled"
+                action: "Action",
+                disabled: "Disabled",
+                not_before: "Valid from",
+                expires_at: "Expires at",
+                effect: "Effect"
             },
             labels: {
                 label_special_permission: "Special permission",
                 label_for_all_users: "Anonymous",
-                label_for_registered_users: "Registered user only"
+                label_for_registered_users: "Registered user only",
+                label_type_repository: "Repository",
+                label_type_registry: "Registry (catalog listing)",
+                label_all_actions: "All (*)",
+                label_validity: "Validity",
+                label_expires_soon: "Expires within a week",
+                label_effect_allow: "Allow",
+                label_effect_deny: "Deny"
             },
             messages: {
-                access_tooltip: "show repositories access"
+                access_tooltip: "show repositories access",
+                expires_before_applies: "Access should expire after the time it's valid from",
+                deny_precedence: "Deny takes precedence over any allow access of the user, the user groups and all users"
             }
         },
         repository: {
@@ -16,6 +16,7 @@ const customRussianMessages: TranslationMessages = {
         commands: {
             users_name: "Пользователи",
             groups_name: "Группы",
+            roles_name: "Роли",
             access_name: "Доступы",
             repository_name: "Репозитории"
         },
@@ -43,6 +44,40 @@ const customRussianMessages: TranslationMessages = {
 
             }
         },
+        roles: {
+            name: "Роли",
+            add_title: "Добавление роли",
+            edit_title: "Редактирование роли",
+            fields: {
+                name: "Наименование",
+                description: "Комментарий",
+                permissions: "Разрешения",
+                built_in: "Встроенная"
+            },
+            labels: {
+                label_no_permissions: "Нет разрешений"
+            },
+            messages: {
+                invalid_name: "Допустимы буквы, цифры и символы '.', '_', '-', первый символ должен быть буквой"
+            },
+            permissions: {
+                users_read: "Просмотр пользователей",
+                users_write: "Управление пользователями",
+                groups_read: "Просмотр групп",
+                groups_write: "Управление группами",
+                access_read: "Просмотр доступов",
+                access_write: "Управление доступами",
+                robots_read: "Просмотр роботов",
+                robots_write: "Управление роботами",
+                roles_read: "Просмотр ролей",
+                roles_write: "Управление ролями",
+                registry_read: "Просмотр всех репозиториев и описаний образов",
+                registry_delete: "Удаление тегов образов",
+                registry_sync: "Синхронизация репозиториев с реестром",
+                registry_keys: "Ротация ключей подписи токенов",
+                registry_all: "Загрузка и выгрузка любых репозиториев docker-клиентом"
+            }
+        },
         accesses:{
             name: "Управление доступом",
             add_title: "Добавить доступ",
@@ -53,15 +88,27 @@ const customRussianMessages: TranslationMessages = {
                 resource_type: "Тип ресурса",
                 resource_name: "Имя репозитория",
                 action:"Вид операции",
-                disabled:"Отключен"
+                disabled:"Отключен",
+                not_before:"Действует с",
+                expires_at:"Действует до",
+                effect:"Действие правила"
             },
             labels: {
                 label_special_permission:"Особые разрешения",
                 label_for_all_users: "Анонимные",
-                label_for_registered_users: "Только зарегистрированные"
+                label_for_registered_users: "Только зарегистрированные",
+                label_type_repository: "Репозиторий",
+                label_type_registry: "Реестр (список репозиториев)",
+                label_all_actions: "Все (*)",
+                label_validity: "Срок действия",
+                label_expires_soon: "Истекает в течение недели",
+                label_effect_allow: "Разрешить",
+                label_effect_deny: "Запретить"
             },
             messages:{
-                access_tooltip:"Показать доступные репозитории"
+                access_tooltip:"Показать доступные репозитории",
+                expires_before_applies:"Доступ должен истекать позже начала действия",
+                deny_precedence:"Запрет имеет приоритет над любыми разрешениями пользователя, его групп и всех пользователей"
             }
         },
         repository:{
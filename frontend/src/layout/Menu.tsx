@@ -10,6 +10,7 @@ import {
 import users from '../users';
 import groups from '../groups';
 import access from '../access';
+import roles from '../roles';
 import repository from '../registry';
 
 import { requirePermission } from '../helpers/Helpers';
@@ -42,7 +43,7 @@ const Menu = ({ dense = false }: MenuProps) => {
             leftIcon={<repository.icon />}
             dense={dense}
         />
-        {requirePermission(permissions, 'admin') || requirePermission(permissions, 'manager') ?
+        {requirePermission(permissions, 'access:read') ?
             <MenuItemLink
                 to="/access"
                 state={{ _scrollToTop: true }}
@@ -53,7 +54,7 @@ const Menu = ({ dense = false }: MenuProps) => {
                 dense={dense}
             /> : null
         }
-        {requirePermission(permissions, 'admin') ? <>
+        {requirePermission(permissions, 'users:read') ?
             <MenuItemLink
                 to="/users"
                 state={{ _scrollToTop: true }}
@@ -62,7 +63,9 @@ const Menu = ({ dense = false }: MenuProps) => {
                 })}
                 leftIcon={<users.icon />}
                 dense={dense}
-            />
+            /> : null
+        }
+        {requirePermission(permissions, 'groups:read') ?
             <MenuItemLink
                 to="/groups"
                 state={{ _scrollToTop: true }}
@@ -71,8 +74,19 @@ const Menu = ({ dense = false }: MenuProps) => {
                 })}
                 leftIcon={<groups.icon />}
                 dense={dense}
-            />
-        </> : null}
+            /> : null
+        }
+        {requirePermission(permissions, 'roles:read') ?
+            <MenuItemLink
+                to="/roles"
+                state={{ _scrollToTop: true }}
+                primaryText={translate(`resources.commands.roles_name`, {
+                    smart_count: 2,
+                })}
+                leftIcon={<roles.icon />}
+                dense={dense}
+            /> : null
+        }
     </Box>
 
 
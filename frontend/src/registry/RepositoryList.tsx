@@ -54,11 +54,11 @@ const SyncButton = () => {
     const notify = useNotify();
     const [isLoading, setLoading] = useState(false)
     const translate = useTranslate();
-    const [isAdmin, setIsAdmin] = useState(false);
+    const [isSyncAllowed, setIsSyncAllowed] = useState(false);
     const { permissions } = usePermissions();
 
     useEffect(() => {
-        setIsAdmin(requirePermission(permissions, 'admin'));
+        setIsSyncAllowed(requirePermission(permissions, 'registry:sync'));
     }, [permissions]);
 
     const doRepoSync = () => {
@@ -89,7 +89,7 @@ const SyncButton = () => {
         return <Loading />;
     }
 
-    return (isAdmin ?
+    return (isSyncAllowed ?
 
         <Button
             onClick={() => { doRepoSync() }}
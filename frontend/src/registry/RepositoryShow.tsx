@@ -40,11 +40,8 @@ const RepositoryShow = () => {
             <TagDescription source="digest" label={translate('resources.repository.fields.digest')} />
             <DateFieldFormatted source="timestamp" label={translate('resources.repository.fields.date')} />
             <SizeFieldReadable source="size" label={translate('resources.repository.fields.size')} />
-            {requirePermission(permissions, 'admin') ?
-                <>
-                    <TagDeleteButton />
-                    <ShowImageDetail />
-                </> : null}
+            {requirePermission(permissions, 'registry:delete') ? <TagDeleteButton /> : null}
+            {requirePermission(permissions, 'registry:read') ? <ShowImageDetail /> : null}
         </Datagrid>
     </TagList>
 }
@@ -0,0 +1,38 @@
+import { CheckboxGroupInput, FunctionField, useTranslate } from 'react-admin';
+
+// permissions which roles can grant, they're the same as the server checks for API endpoints
+export const rolePermissions = [
+    'users:read', 'users:write',
+    'groups:read', 'groups:write',
+    'access:read', 'access:write',
+    'robots:read', 'robots:write',
+    'roles:read', 'roles:write',
+    'registry:read', 'registry:delete', 'registry:sync', 'registry:keys', 'registry:all'
+];
+
+const permissionLabel = (translate: any, permission: string) =>
+    translate(`resources.roles.permissions.${permission.replace(':', '_')}`, { _: permission });
+
+export const RolePermissionsInput = (props: any) => {
+    const translate = useTranslate();
+
+    return <CheckboxGroupInput
+        label={translate('resources.roles.fields.permissions')}
+        source="permissions"
+        row={false}
+        defaultValue={[]}
+        choices={rolePermissions.map(p => ({ id: p, name: permissionLabel(translate, p) }))}
+        {...props}
+    />
+};
+
+// RolePermissionsField shows permissions of a role as a comma separated list
+export const RolePermissionsField = (props: any) => {
+    const translate = useTranslate();
+
+    return <FunctionField
+        {...props}
+        render={(record: any) => record && Array.isArray(record.permissions) && record.permissions.length > 0 ?
+            record.permissions.join(', ') : translate('resources.roles.labels.label_no_permissions')}
+    />
+};
@@ -0,0 +1,25 @@
+import { Create, TextInput, SimpleForm, useTranslate, usePermissions, NotFound, required, regex } from 'react-admin';
+import { requirePermission } from '../helpers/Helpers';
+import { RolePermissionsInput } from './PermissionsInput';
+
+// roleNamePattern is the same as the server checks, a role name is used in settings and claims mapping
+export const roleNamePattern = /^[a-zA-Z][a-zA-Z0-9._-]*$/;
+
+export const RoleCreate = () => {
+    const translate = useTranslate();
+    const { permissions } = usePermissions();
+
+    return (requirePermission(permissions, 'roles:write') ?
+        <Create title={translate('resources.roles.add_title')}>
+            <SimpleForm>
+                <TextInput label={translate('resources.roles.fields.name')} source="name" autoComplete="off"
+                    validate={[required(), regex(roleNamePattern, translate('resources.roles.messages.invalid_name'))]} />
+                <TextInput label={translate('resources.roles.fields.description')} source="description"
+                    autoComplete='off' fullWidth />
+                <RolePermissionsInput />
+            </SimpleForm>
+        </Create> : <NotFound />
+    )
+};
+
+export default RoleCreate;
@@ -0,0 +1,23 @@
+import * as React from "react";
+import { Edit, TextInput, SimpleForm, useTranslate, usePermissions, NotFound } from 'react-admin';
+import { requirePermission } from "../helpers/Helpers";
+import { RolePermissionsInput } from './PermissionsInput';
+
+// the role name can't be changed because users refer to roles by name
+const RoleEdit = () => {
+    const translate = useTranslate();
+    const { permissions } = usePermissions();
+    return (requirePermission(permissions, 'roles:write') ?
+        <Edit title={translate('resources.roles.edit_title')}>
+            <SimpleForm>
+                <TextInput label="ID" source="id" disabled />
+                <TextInput label={translate('resources.roles.fields.name')} source="name" disabled />
+                <TextInput label={translate('resources.roles.fields.description')} source="description"
+                    autoComplete='off' fullWidth />
+                <RolePermissionsInput />
+            </SimpleForm>
+        </Edit> : <NotFound />
+    )
+};
+
+export default RoleEdit;
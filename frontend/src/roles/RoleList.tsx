@@ -0,0 +1,51 @@
+import * as React from "react";
+import {
+    List,
+    Datagrid,
+    TextField,
+    BooleanField,
+    EditButton,
+    useRecordContext,
+    useTranslate,
+    usePermissions,
+    NotFound
+} from 'react-admin';
+import { DeleteCustomButtonWithConfirmation } from "../components/DeleteCustomButtonWithConfirmation";
+import { requirePermission, SearchFieldTranslated } from '../helpers/Helpers';
+import { RolePermissionsField } from './PermissionsInput';
+
+// built-in roles can't be changed, so edit and delete buttons are shown for custom roles only
+const CustomRoleButtons = (props: any) => {
+    const record = useRecordContext();
+
+    return record && !record.built_in ? <>
+        <EditButton />
+        <DeleteCustomButtonWithConfirmation source="name" {...props} />
+    </> : null
+}
+
+const RoleList = (props: any) => {
+    const translate = useTranslate();
+    const { permissions } = usePermissions();
+
+    return (
+        requirePermission(permissions, 'roles:read') ?
+            <List
+                hasCreate={requirePermission(permissions, 'roles:write')}
+                sort={{ field: 'name', order: 'ASC' }}
+                perPage={25}
+                filters={SearchFieldTranslated(translate)}
+            >
+                <Datagrid bulkActionButtons={false}>
+                    <TextField source="id" />
+                    <TextField source="name" label={translate('resources.roles.fields.name')} />
+                    <TextField source="description" label={translate('resources.roles.fields.description')} />
+                    <RolePermissionsField source="permissions" label={translate('resources.roles.fields.permissions')} />
+                    <BooleanField source="built_in" label={translate('resources.roles.fields.built_in')} />
+                    {requirePermission(permissions, 'roles:write') ? <CustomRoleButtons {...props} /> : null}
+                </Datagrid>
+            </List> : <NotFound />
+    )
+};
+
+export default RoleList;
@@ -0,0 +1,13 @@
+import RoleIcon from '@mui/icons-material/AdminPanelSettings';
+import RoleList from './RoleList';
+import RoleEdit from './RoleEdit';
+import RoleCreate from './RoleCreate';
+
+const roles = {
+    list: RoleList,
+    edit: RoleEdit,
+    create: RoleCreate,
+    icon: RoleIcon
+};
+
+export default roles;
@@ -12,7 +12,6 @@ import {
     required
 } from 'react-admin';
 import { requirePermission } from '../helpers/Helpers';
-import { RoleList } from "./UsersList";
 
 
 // passwordLengthForNewUsers use only for create a new user without empty or low password
@@ -27,7 +26,7 @@ export const UserCreate = (props: any) => {
     // const validatePassword = [required(), minLength(6)];
 
 
-    return (requirePermission(permissions, 'admin') ?
+    return (requirePermission(permissions, 'users:write') ?
         <Create title={translate('resources.users.add_title')}>
             <SimpleForm>
                 <TextInput label={translate('resources.users.fields.login')} source="login" {...rest} validate={required()} />
@@ -43,14 +42,15 @@ export const UserCreate = (props: any) => {
                         {...rest} validate={required()}
                     />
                 </ReferenceInput>
-                <SelectInput
-                    label={translate('resources.users.fields.role')}
-                    source="role"
-                    defaultValue={"user"}
-                    emptyValue={""}
-                    choices={RoleList}
-                    {...rest} validate={required()}
-                />
+                <ReferenceInput source="role" reference="roles">
+                    <SelectInput label={translate('resources.users.fields.role')}
+                        emptyValue={""}
+                        emptyText=''
+                        defaultValue={"user"}
+                        optionText="name" optionValue="name"
+                        {...rest} validate={required()}
+                    />
+                </ReferenceInput>
                 <BooleanInput label={translate('resources.users.fields.blocked')} source="blocked" />
                 <TextInput label={translate('resources.users.fields.description')} source="description"
                     autoComplete='off' fullWidth />
@@ -13,7 +13,6 @@ import {
     required
 } from 'react-admin';
 import { requirePermission } from "../helpers/Helpers";
-import { RoleList } from "./UsersList";
 
 const passwordLengthEditUsers = (min: number, message: string = 'ra.validation.minLength') =>
     (value: string) => {
@@ -30,7 +29,7 @@ const UserEdit = (props: any) => {
     const translate = useTranslate();
     const { source, ...rest } = props;
     const { permissions } = usePermissions();
-    return (requirePermission(permissions, 'admin') ?
+    return (requirePermission(permissions, 'users:write') ?
         <Edit title={translate('resources.users.edit_title')}>
             <SimpleForm>
                 <TextInput label="ID" source="id" disabled />
@@ -50,13 +49,15 @@ const UserEdit = (props: any) => {
                         {...rest} validate={required()}
                     />
                 </ReferenceInput>
-                <SelectInput
-                    label={translate('resources.users.fields.role')}
-                    source="role"
-                    defaultValue={"user"}
-                    emptyValue={''}
-                    choices={RoleList}
-                    {...rest} validate={required()} />
+                <ReferenceInput source="role" reference="roles">
+                    <SelectInput label={translate('resources.users.fields.role')}
+                        emptyValue={''}
+                        emptyText=''
+                        optionText="name"
+                        optionValue="name"
+                        {...rest} validate={required()}
+                    />
+                </ReferenceInput>
                 <BooleanInput label={translate('resources.users.fields.blocked')} source="blocked" />
                 <TextInput label={translate('resources.users.fields.description')} source="description"
                     autoComplete='off' fullWidth />
@@ -22,19 +22,8 @@ import {SearchFieldTranslated} from '../helpers/Helpers'
 import {requirePermission} from '../helpers/Helpers';
 import { DeleteCustomButtonWithConfirmation } from '../components/DeleteCustomButtonWithConfirmation';
 
-interface IRoleList {
-    id: string;
-    name: string;
-}
-
 export const KEY_SELECTED_USER="ctx_key_selected_user_";
 
-export const RoleList: Array<IRoleList> = [
-    { id: 'user', name: 'User' },
-    { id: 'manager', name: 'Manager' },
-    { id: 'admin', name: 'Admin' }
-]
-
 const RepositoryAccessList =()=>{
     const record = useRecordContext();
     const translate=useTranslate();
@@ -61,15 +50,15 @@ const UserList = (props:any) => {
     const translate=useTranslate();
     const {permissions} = usePermissions();
 
-    return(requirePermission(permissions,'admin') ?
+    return(requirePermission(permissions,'users:read') ?
     <List
+        hasCreate={requirePermission(permissions,'users:write')}
         sort={{ field: 'name', order: 'ASC' }}
         perPage={25}
-        filters={SearchFieldTranslated(translate,[<SelectInput
-            source="role"
-            defaultValue={"user"}
-            emptyValue=""
-            choices={RoleList} />,
+        filters={SearchFieldTranslated(translate,[
+            <ReferenceInput source="role" reference="roles" label={translate('resources.users.fields.role')}>
+               <SelectInput optionText="name" optionValue="name" emptyValue="" label={translate('resources.users.fields.role')} />
+           </ReferenceInput>,
             <ReferenceInput source="user_group" reference="groups" label={translate('resources.groups.name')}>
                <AutocompleteInput  optionText="name" optionValue="id" label={translate('resources.groups.name')} />
            </ReferenceInput>])}
@@ -84,8 +73,10 @@ const UserList = (props:any) => {
             <TextField source="role" />
             <DisabledField source="blocked" />
             <RepositoryAccessList/>
-            <EditButton />
-            <DeleteCustomButtonWithConfirmation  source="name" {...props}/>
+            {requirePermission(permissions,'users:write') ? <>
+                <EditButton />
+                <DeleteCustomButtonWithConfirmation  source="name" {...props}/>
+            </> : null}
         </Datagrid>
     </List>:<NotFound/>
 )};